@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/api"
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/history"
+	"github.com/lucasrui/neo-nas/internal/status"
+	"github.com/lucasrui/neo-nas/internal/watcher"
+	"github.com/lucasrui/neo-nas/internal/zip"
+)
+
+// daemonControl 守护进程中的任务，供状态接口和管理接口使用
+type daemonControl struct {
+	cfg     *config.NeoConfig // 重新加载配置时整体替换，不修改其中的内容
+	cfgMu   sync.RWMutex
+	wm      *WatcherManager
+	zipMgr  *zip.ZipManager
+	store   *history.Store
+	started time.Time
+	reload  sync.Mutex // 同一时间只执行一次重新加载
+}
+
+// config 返回当前使用的配置
+func (d *daemonControl) config() *config.NeoConfig {
+	d.cfgMu.RLock()
+	defer d.cfgMu.RUnlock()
+	return d.cfg
+}
+
+func (d *daemonControl) Status() status.Report {
+	return statusReport(d.config(), d.wm, d.zipMgr, d.store, d.started)
+}
+
+// statusReply 处理控制套接字上的 status 命令，回复一行 JSON 格式的状态
+func (d *daemonControl) statusReply(string) string {
+	data, err := json.Marshal(d.Status())
+	if err != nil {
+		return "error"
+	}
+	return string(data)
+}
+
+// watcher 返回序号对应的备份任务的监控
+func (d *daemonControl) watcher(id int) (*watcher.Watcher, error) {
+	cfg := d.config()
+	if id < 1 || id > len(cfg.BackupConfigs) {
+		return nil, api.ErrNotFound
+	}
+	w := d.wm.Get(cfg.BackupConfigs[id-1].SourceDir)
+	if w == nil {
+		return nil, api.ErrNotFound
+	}
+	return w, nil
+}
+
+func (d *daemonControl) Rescan(id int) error {
+	w, err := d.watcher(id)
+	if err == nil {
+		w.Rescan()
+	}
+	return err
+}
+
+func (d *daemonControl) Pause(id int) error {
+	w, err := d.watcher(id)
+	if err == nil {
+		w.Pause()
+	}
+	return err
+}
+
+func (d *daemonControl) Resume(id int) error {
+	w, err := d.watcher(id)
+	if err == nil {
+		w.Resume()
+	}
+	return err
+}
+
+// runZipNow 处理控制套接字上的 zip 命令，按目标路径在当前的配置中查找压缩任务并在后台执行
+func (d *daemonControl) runZipNow(target string) string {
+	for _, item := range d.config().ZipConfig.Items {
+		if item.Target != target {
+			continue
+		}
+		if !d.zipMgr.Trigger(item) {
+			return zipReplyBusy
+		}
+		return zipReplyOK
+	}
+	return zipReplyNotFound
+}
+
+func (d *daemonControl) RunZip(id int) error {
+	cfg := d.config()
+	if id < 1 || id > len(cfg.ZipConfig.Items) {
+		return api.ErrNotFound
+	}
+	if !d.zipMgr.Trigger(cfg.ZipConfig.Items[id-1]) {
+		return api.ErrBusy
+	}
+	return nil
+}
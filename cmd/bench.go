@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/bench"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// runBench 测试源目录的遍历速度、复制到目标目录的速度和哈希速度，按结果给出配置建议
+// 用法: bench <源目录> <目标目录> [--size <MB>] [--files <数量>]
+func runBench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	sizeMB := fs.Int64("size", 256, i18n.T("每轮复制测试的数据量（MB）"))
+	files := fs.Int("files", 20000, i18n.T("遍历源目录时最多读取的文件数量"))
+	fs.Usage = func() {
+		i18n.Fprintf(os.Stderr, "用法: %s bench <源目录> <目标目录> [选项]\n\n", filepath.Base(os.Args[0]))
+		i18n.Fprintln(os.Stderr, "复制测试在目标目录中写入临时文件，测试完成后删除")
+		i18n.Fprintln(os.Stderr, "\n选项:")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterleaved(fs, args)
+	if err != nil {
+		return exitConfig
+	}
+	if len(positional) != 2 || *sizeMB <= 0 || *files <= 0 {
+		fs.Usage()
+		return exitConfig
+	}
+	source, target := positional[0], positional[1]
+	if info, err := os.Stat(target); err != nil || !info.IsDir() {
+		logger.Warnf("目标目录不可用: %s", target)
+		return exitConfig
+	}
+	size := *sizeMB << 20
+
+	i18n.Printf("遍历源目录 %s ...\n", source)
+	st, err := bench.Stat(source, *files, 30*time.Second)
+	if err != nil {
+		logger.Error(err.Error())
+		return exitFailed
+	}
+	i18n.Printf("  %d 项, 用时 %s, 每秒 %.0f 项, 平均 %s/项\n\n", st.Files, st.Elapsed.Round(time.Millisecond), st.PerSecond(), st.Latency())
+
+	sample := st.Largest
+	var readRate float64
+	if st.MaxBytes < size {
+		size = max(st.MaxBytes, 0)
+	}
+	if size < 1<<20 {
+		// 源目录中没有足够大的文件，只测试目标的写入速度
+		sample, size = "", *sizeMB<<20
+		i18n.Printf("复制测试（源目录中没有 1 MB 以上的文件，写入 %s 随机数据）\n", formatSize(size))
+	} else {
+		r, err := bench.Read(sample, size)
+		if err != nil {
+			logger.Error(err.Error())
+			return exitFailed
+		}
+		readRate = r.BytesPerSecond()
+		i18n.Printf("读取测试（%s 的前 %s）\n  %s/s\n\n", sample, formatSize(size), formatSize(int64(readRate)))
+		i18n.Println("复制测试（源文件可能已在系统缓存中，主要反映目标的写入速度）")
+	}
+	var best bench.CopyResult
+	for _, bufSize := range bench.BufferSizes {
+		r, err := bench.Copy(sample, target, size, bufSize)
+		if err != nil {
+			logger.Error(err.Error())
+			return exitFailed
+		}
+		i18n.Printf("  缓冲区 %-8s %s/s\n", formatSize(int64(bufSize)), formatSize(int64(r.BytesPerSecond())))
+		if r.BytesPerSecond() > best.BytesPerSecond() {
+			best = r
+		}
+	}
+	fmt.Println()
+
+	i18n.Println("哈希速度（单核）")
+	hashes := bench.Hash(512 << 20)
+	for _, h := range hashes {
+		fmt.Printf("  %-8s %s/s\n", h.Algorithm, formatSize(int64(h.BytesPerSecond())))
+	}
+	fmt.Println()
+
+	copyRate := best.BytesPerSecond()
+	if readRate > 0 {
+		copyRate = min(copyRate, readRate)
+	}
+	i18n.Println("建议配置")
+	parallelism := 1
+	switch latency := st.Latency(); {
+	case latency >= 5*time.Millisecond:
+		parallelism = 8
+	case latency >= time.Millisecond:
+		parallelism = 4
+	}
+	if parallelism > 1 {
+		i18n.Printf("  \"scan_parallelism\": %d    读取文件信息的延迟较高，同时扫描多个子目录\n", parallelism)
+	} else {
+		i18n.Println("  \"scan_parallelism\": 1    读取文件信息很快，不需要并行扫描")
+	}
+	if hashes[0].BytesPerSecond() >= copyRate {
+		i18n.Println("  \"verify\": \"hash\"        哈希比复制快，比较内容不会明显拖慢校验")
+	} else {
+		i18n.Println("  \"verify\": \"quick\"       哈希慢于复制，日常校验只比较大小和时间，需要时执行 verify --hash")
+	}
+	if len(hashes) > 1 && hashes[1].BytesPerSecond() > 2*hashes[0].BytesPerSecond() && hashes[0].BytesPerSecond() < copyRate {
+		i18n.Println("  \"checksums\": {\"algorithm\": \"md5\"}    写入校验文件时 md5 明显快于 sha256")
+	}
+	i18n.Printf("  \"limit_kbps\": %d    需要为其他程序保留带宽时，可以在 bandwidth.schedules 中按实测速度的一半限速\n", int(copyRate/1024/2))
+	if copyRate < 20<<20 {
+		i18n.Println("  \"priority\": {\"io_class\": \"idle\"}    目标写入较慢，降低后台复制的 IO 优先级以免影响其他程序")
+	}
+	return exitOK
+}
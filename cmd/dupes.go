@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lucasrui/neo-nas/internal/catalog"
+	"github.com/lucasrui/neo-nas/internal/dupes"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// runDupes 按目录索引中的哈希列出所有目标中内容相同的文件和可以释放的空间
+// 用法: dupes [--min-size <字节>] [--json] [--link]
+func runDupes(args []string) int {
+	fs := flag.NewFlagSet("dupes", flag.ContinueOnError)
+	minSize := fs.Int64("min-size", 1, i18n.T("只统计不小于该大小的文件，单位字节"))
+	jsonOut := fs.Bool("json", false, i18n.T("以 JSON 格式输出"))
+	link := fs.Bool("link", false, i18n.T("把重复的文件替换为硬链接，只保留每组中的第一个文件"))
+	fs.Usage = func() {
+		i18n.Fprintf(os.Stderr, "用法: %s dupes [选项]\n\n", filepath.Base(os.Args[0]))
+		i18n.Fprintln(os.Stderr, "统计所有本地备份目标和服务端接收目录中内容相同的文件，不加 --link 时不修改任何文件")
+		i18n.Fprintln(os.Stderr, "\n选项:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitConfig
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return exitConfig
+	}
+
+	a, err := loadApp()
+	if err != nil {
+		logger.Errorf("程序已停止，%v", err)
+		return exitConfig
+	}
+	defer a.catalog.Close()
+
+	var roots []string
+	for _, task := range a.cfg.BackupConfigs {
+		if task.IsLocalTarget() {
+			roots = append(roots, task.TargetDir)
+		}
+	}
+	if a.cfg.Server.Enabled && a.cfg.Server.Root != "" {
+		roots = append(roots, a.cfg.Server.Root)
+	}
+	var entries []catalog.Entry
+	for _, root := range roots {
+		entries = append(entries, a.catalog.Entries(root)...)
+	}
+	groups := dupes.Find(entries, *minSize)
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if groups == nil {
+			groups = []dupes.Group{}
+		}
+		if err := enc.Encode(groups); err != nil {
+			logger.Errorf("输出失败: %v", err)
+			return exitFailed
+		}
+	} else {
+		var total int64
+		for _, g := range groups {
+			i18n.Printf("%s x %d, 可释放 %s, sha256 %s\n", formatSize(g.Size), len(g.Paths), formatSize(g.Reclaimable), g.SHA256)
+			for _, p := range g.Paths {
+				fmt.Printf("  %s\n", p)
+			}
+			total += g.Reclaimable
+		}
+		if len(groups) == 0 {
+			i18n.Println("没有内容相同的文件")
+		} else {
+			i18n.Printf("\n共 %d 组重复文件，可释放 %s\n", len(groups), formatSize(total))
+		}
+	}
+	if !*link {
+		return exitOK
+	}
+
+	code := exitOK
+	var freed int64
+	for _, g := range groups {
+		n, errs := dupes.Link(g)
+		for _, err := range errs {
+			logger.Error(err.Error())
+			code = exitFailed
+		}
+		freed += n
+	}
+	logger.Infof("重复文件已替换为硬链接，释放 %s", formatSize(freed))
+	return code
+}
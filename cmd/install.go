@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/service"
+)
+
+// serviceOptions 生成服务配置需要的信息
+type serviceOptions struct {
+	name      string
+	exe       string
+	configDir string
+	env       []string // KEY=VALUE，第一项为 BACKUP_CONFIG_DIR
+}
+
+// envFlags 可重复指定的 --env KEY=VALUE
+type envFlags []string
+
+func (e *envFlags) String() string { return strings.Join(*e, ",") }
+
+func (e *envFlags) Set(v string) error {
+	if k, _, ok := strings.Cut(v, "="); !ok || k == "" {
+		return i18n.Errorf("格式应为 KEY=VALUE: %s", v)
+	}
+	*e = append(*e, v)
+	return nil
+}
+
+// runInstallService 为当前平台生成并安装系统服务：Linux 为 systemd 服务，macOS 为 launchd 用户代理，Windows 为系统服务
+// 用法: install-service [--name <名称>] [--config-dir <目录>] [--env KEY=VALUE]... [--print]
+func runInstallService(args []string) int {
+	fs := flag.NewFlagSet("install-service", flag.ContinueOnError)
+	name := fs.String("name", service.Name, i18n.T("服务名称"))
+	configDir := fs.String("config-dir", "", i18n.T("服务使用的配置目录，默认为当前的 BACKUP_CONFIG_DIR 或平台默认目录"))
+	printOnly := fs.Bool("print", false, i18n.T("只输出生成的服务配置，不安装"))
+	var env envFlags
+	fs.Var(&env, "env", i18n.T("服务额外使用的环境变量，格式为 KEY=VALUE，可以重复指定"))
+	fs.Usage = func() {
+		i18n.Fprintf(os.Stderr, "用法: %s install-service [选项]\n\n", filepath.Base(os.Args[0]))
+		i18n.Fprintln(os.Stderr, "生成并安装开机自动启动的系统服务，异常退出后自动重启，需要管理员权限（macOS 安装为当前用户的代理）")
+		i18n.Fprintln(os.Stderr, "\n选项:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitConfig
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return exitConfig
+	}
+
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		logger.Errorf("获取程序路径失败: %v", err)
+		return exitFailed
+	}
+	if *configDir == "" {
+		*configDir = config.Dir()
+	}
+	dir, err := filepath.Abs(*configDir)
+	if err != nil {
+		logger.Errorf("配置目录无效: %v", err)
+		return exitConfig
+	}
+	if _, err := os.Stat(filepath.Join(dir, "config.json")); err != nil {
+		logger.Warnf("配置文件不存在: %v", err)
+		return exitConfig
+	}
+	opts := serviceOptions{name: *name, exe: exe, configDir: dir, env: []string{"BACKUP_CONFIG_DIR=" + dir}}
+	// 保留当前的时区设置，快照名称和日志时间与手动运行时一致
+	if tz, ok := os.LookupEnv("TZ"); ok {
+		opts.env = append(opts.env, "TZ="+tz)
+	}
+	opts.env = append(opts.env, env...)
+
+	if *printOnly {
+		text, err := serviceFile(opts)
+		if err != nil {
+			logger.Error(err.Error())
+			return exitConfig
+		}
+		fmt.Print(text)
+		return exitOK
+	}
+	file, err := installService(opts)
+	if err != nil {
+		logger.Errorf("安装服务失败: %v", err)
+		return exitFailed
+	}
+	i18n.Printf("已安装并启动服务 %s: %s\n", opts.name, file)
+	return exitOK
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/service"
+)
+
+// launchdLabel 默认名称使用反向域名形式的标签
+func launchdLabel(name string) string {
+	if name == service.Name {
+		return "com.lucasrui.neo-nas"
+	}
+	return name
+}
+
+// serviceFile 生成 launchd 用户代理，登录后启动，异常退出时自动重启，以低优先级读写磁盘
+func serviceFile(opts serviceOptions) (string, error) {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString("<plist version=\"1.0\">\n<dict>\n")
+	plistString(&b, "Label", launchdLabel(opts.name))
+	b.WriteString("  <key>ProgramArguments</key>\n  <array>\n")
+	fmt.Fprintf(&b, "    <string>%s</string>\n", xmlEscape(opts.exe))
+	b.WriteString("  </array>\n")
+	b.WriteString("  <key>EnvironmentVariables</key>\n  <dict>\n")
+	for _, kv := range opts.env {
+		k, v, _ := strings.Cut(kv, "=")
+		fmt.Fprintf(&b, "    <key>%s</key>\n    <string>%s</string>\n", xmlEscape(k), xmlEscape(v))
+	}
+	b.WriteString("  </dict>\n")
+	b.WriteString("  <key>RunAtLoad</key>\n  <true/>\n")
+	b.WriteString("  <key>KeepAlive</key>\n  <dict>\n    <key>SuccessfulExit</key>\n    <false/>\n  </dict>\n")
+	b.WriteString("  <key>ThrottleInterval</key>\n  <integer>60</integer>\n")
+	plistString(&b, "ProcessType", "Background")
+	b.WriteString("  <key>LowPriorityIO</key>\n  <true/>\n")
+	log := filepath.Join(opts.configDir, "neo-nas.log")
+	plistString(&b, "StandardOutPath", log)
+	plistString(&b, "StandardErrorPath", log)
+	b.WriteString("</dict>\n</plist>\n")
+	return b.String(), nil
+}
+
+func plistString(b *strings.Builder, key, value string) {
+	fmt.Fprintf(b, "  <key>%s</key>\n  <string>%s</string>\n", xmlEscape(key), xmlEscape(value))
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// installService 写入 ~/Library/LaunchAgents/<标签>.plist 并加载
+func installService(opts serviceOptions) (string, error) {
+	text, err := serviceFile(opts)
+	if err != nil {
+		return "", err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(home, "Library", "LaunchAgents")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", i18n.Errorf("创建目录失败: %w", err)
+	}
+	file := filepath.Join(dir, launchdLabel(opts.name)+".plist")
+	if err := os.WriteFile(file, []byte(text), 0600); err != nil {
+		return "", i18n.Errorf("写入服务文件失败: %w", err)
+	}
+	// 已加载的旧版本先卸载，忽略未加载时的错误
+	exec.Command("launchctl", "unload", file).Run()
+	if out, err := exec.Command("launchctl", "load", "-w", file).CombinedOutput(); err != nil {
+		return "", i18n.Errorf("launchctl load 失败: %w, %s", err, strings.TrimSpace(string(out)))
+	}
+	return file, nil
+}
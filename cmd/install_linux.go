@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// serviceFile 生成 systemd 服务，以 root 运行以便设置所有者、不可变属性和创建快照
+// 加固选项只限制系统目录和内核设置，源目录和目标目录可以位于任意位置
+func serviceFile(opts serviceOptions) (string, error) {
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	b.WriteString(i18n.T("Description=Neo NAS 备份服务\n"))
+	b.WriteString("After=network-online.target local-fs.target\n")
+	b.WriteString("Wants=network-online.target\n\n")
+	b.WriteString("[Service]\n")
+	b.WriteString("Type=simple\n")
+	fmt.Fprintf(&b, "ExecStart=%s\n", unitQuote(opts.exe))
+	b.WriteString("ExecReload=/bin/kill -HUP $MAINPID\n")
+	for _, kv := range opts.env {
+		fmt.Fprintf(&b, "Environment=%s\n", unitQuote(kv))
+	}
+	b.WriteString("Restart=on-failure\n")
+	b.WriteString("RestartSec=60\n")
+	b.WriteString("TimeoutStopSec=90\n")
+	b.WriteString("NoNewPrivileges=yes\n")
+	b.WriteString("ProtectSystem=full\n")
+	b.WriteString("ProtectKernelTunables=yes\n")
+	b.WriteString("ProtectKernelModules=yes\n")
+	b.WriteString("ProtectControlGroups=yes\n")
+	b.WriteString("RestrictSUIDSGID=yes\n")
+	b.WriteString("LockPersonality=yes\n")
+	// 配置目录位于 /etc 等只读目录下时仍需写入进度和目录索引
+	fmt.Fprintf(&b, "ReadWritePaths=%s\n\n", unitQuote(opts.configDir))
+	b.WriteString("[Install]\n")
+	b.WriteString("WantedBy=multi-user.target\n")
+	return b.String(), nil
+}
+
+// installService 写入 /etc/systemd/system/<名称>.service，设置开机启动并立即启动
+func installService(opts serviceOptions) (string, error) {
+	text, err := serviceFile(opts)
+	if err != nil {
+		return "", err
+	}
+	file := filepath.Join("/etc/systemd/system", opts.name+".service")
+	// 额外的环境变量中可能有密钥，只允许 root 读取
+	if err := os.WriteFile(file, []byte(text), 0600); err != nil {
+		return "", i18n.Errorf("写入服务文件失败: %w", err)
+	}
+	for _, args := range [][]string{{"daemon-reload"}, {"enable", "--now", opts.name + ".service"}} {
+		if out, err := exec.Command("systemctl", args...).CombinedOutput(); err != nil {
+			return "", i18n.Errorf("systemctl %s 失败: %w, %s", args[0], err, strings.TrimSpace(string(out)))
+		}
+	}
+	return file, nil
+}
+
+// unitQuote 按 systemd 的规则引用参数，% 需要转义为 %%
+func unitQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if strings.ContainsAny(s, " \t\"'\\") {
+		return strconv.Quote(s)
+	}
+	return s
+}
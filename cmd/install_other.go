@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import "github.com/lucasrui/neo-nas/internal/i18n"
+
+func serviceFile(opts serviceOptions) (string, error) {
+	return "", i18n.Errorf("当前平台不支持安装系统服务")
+}
+
+func installService(opts serviceOptions) (string, error) {
+	return "", i18n.Errorf("当前平台不支持安装系统服务")
+}
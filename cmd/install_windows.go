@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/service"
+)
+
+// serviceFile Windows 服务没有配置文件，输出等效的 sc.exe 命令
+func serviceFile(opts serviceOptions) (string, error) {
+	if len(opts.env) > 1 {
+		return "", i18n.Errorf("Windows 服务不支持 --env，请在系统环境变量中设置")
+	}
+	args := []string{syscall.EscapeArg(opts.exe)}
+	for _, arg := range serviceArgs(opts.name, opts.configDir) {
+		args = append(args, syscall.EscapeArg(arg))
+	}
+	return fmt.Sprintf("sc.exe create %s binPath= %s start= auto\n", opts.name, syscall.EscapeArg(strings.Join(args, " "))), nil
+}
+
+// installService 注册并启动 Windows 服务
+func installService(opts serviceOptions) (string, error) {
+	if _, err := serviceFile(opts); err != nil {
+		return "", err
+	}
+	if err := service.Install(opts.name, opts.exe, serviceArgs(opts.name, opts.configDir)); err != nil {
+		return "", err
+	}
+	if err := service.Start(opts.name); err != nil {
+		return "", err
+	}
+	return opts.exe, nil
+}
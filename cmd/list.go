@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/cron"
+	"github.com/lucasrui/neo-nas/internal/history"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/version"
+)
+
+// taskStatus list 命令输出的一行，--json 时原样输出
+type taskStatus struct {
+	Kind    string     `json:"kind"`
+	Source  string     `json:"source"`
+	Target  string     `json:"target"`
+	LastRun *time.Time `json:"last_run,omitempty"`
+	Result  string     `json:"result"` // success、failed 或 never
+	Error   string     `json:"error,omitempty"`
+	Total   int        `json:"total"`
+	Success int        `json:"success"`
+	Failed  int        `json:"failed"`
+	Skipped int        `json:"skipped"`
+	NextRun *time.Time `json:"next_run,omitempty"`
+	Next    string     `json:"next"` // 下次运行的说明，备份任务在源目录出现时扫描，没有固定时间
+
+	counted bool // 有运行记录，Total 等数量有效
+}
+
+const resultNever = "never"
+
+var resultLabels = map[string]string{
+	history.ResultSuccess: "成功",
+	history.ResultFailed:  "失败",
+	resultNever:           "未运行",
+}
+
+// runList 列出备份任务和压缩任务最近一次运行的结果，有任务失败时退出码为 1
+func runList(args []string) int {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	jsonOut := fs.Bool("json", false, i18n.T("以 JSON 格式输出"))
+	fs.Usage = func() {
+		i18n.Fprintf(os.Stderr, "用法: %s list [--json]\n\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitConfig
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logger.Errorf("加载配置失败: %v", err)
+		return exitConfig
+	}
+	store := history.Open(cfg.HistoryFile)
+
+	var rows []taskStatus
+	for _, task := range cfg.BackupConfigs {
+		row := taskStatus{Kind: history.KindBackup, Source: task.SourceDir, Target: task.TargetDir, Result: resultNever, Next: i18n.T("源目录出现时")}
+		if r, ok := store.Last(history.KindBackup, cfg.Hostname, task.SourceDir, task.TargetDir); ok {
+			row.fill(r)
+		}
+		rows = append(rows, row)
+	}
+	for _, item := range cfg.ZipConfig.Items {
+		row := taskStatus{Kind: history.KindZip, Source: item.Source, Target: item.Target, Result: resultNever}
+		if r, ok := store.Last(history.KindZip, "", item.Source, item.Target); ok {
+			row.fill(r)
+		}
+		if item.Cron != "" {
+			if sched, err := cron.Parse(item.Cron); err != nil {
+				row.Next = i18n.T("cron 表达式无效")
+			} else if next := sched.Next(time.Now()); !next.IsZero() {
+				row.NextRun = &next
+				row.Next = next.Format("2006-01-02 15:04:05")
+			}
+		} else if interval := time.Duration(cfg.ZipConfig.IntervalSeconds) * time.Second; interval <= 0 {
+			row.Next = i18n.T("未启用")
+		} else if row.LastRun != nil {
+			next := row.LastRun.Add(interval)
+			row.NextRun = &next
+			row.Next = next.Format("2006-01-02 15:04:05")
+		} else {
+			row.Next = i18n.Sprintf("启动后每 %s", interval)
+		}
+		rows = append(rows, row)
+	}
+
+	code := exitOK
+	for _, row := range rows {
+		if row.Result == history.ResultFailed {
+			code = exitFailed
+		}
+	}
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if rows == nil {
+			rows = []taskStatus{}
+		}
+		enc.Encode(rows)
+		return code
+	}
+	// 守护进程检查到新版本时提示，方便及时升级
+	if r, ok := version.Load(cfg.UpdateCheckFile); ok && r.Newer && version.Newer(r.Latest, version.Current()) {
+		i18n.Printf("有新版本可用: %s（当前 %s）%s\n\n", r.Latest, version.Current(), r.URL)
+	}
+	if len(rows) == 0 {
+		i18n.Println("没有配置任务")
+		return code
+	}
+
+	table := [][]string{{i18n.T("类型"), i18n.T("任务"), i18n.T("上次运行"), i18n.T("结果"), i18n.T("扫描/成功/失败/跳过"), i18n.T("下次运行")}}
+	for _, row := range rows {
+		kind, counts, last := i18n.T("备份"), "-", "-"
+		if row.Kind == history.KindZip {
+			kind = i18n.T("压缩")
+		} else if row.counted {
+			counts = fmt.Sprintf("%d/%d/%d/%d", row.Total, row.Success, row.Failed, row.Skipped)
+		}
+		if row.LastRun != nil {
+			last = row.LastRun.Format("2006-01-02 15:04:05")
+		}
+		table = append(table, []string{kind, row.Source + " -> " + row.Target, last, i18n.T(resultLabels[row.Result]), counts, row.Next})
+	}
+	printTable(table)
+	first := true
+	for _, row := range rows {
+		if row.Error == "" {
+			continue
+		}
+		if first {
+			i18n.Println("\n错误:")
+			first = false
+		}
+		fmt.Printf("  %s -> %s: %s\n", row.Source, row.Target, row.Error)
+	}
+	return code
+}
+
+func (row *taskStatus) fill(r history.Run) {
+	row.LastRun = &r.Start
+	row.Result, row.Error = r.Result, r.Error
+	row.Total, row.Success, row.Failed, row.Skipped = r.Total, r.Success, r.Failed, r.Skipped
+	row.counted = true
+}
+
+// printTable 按显示宽度对齐输出，中文字符占两列，最后一列不补空格
+func printTable(rows [][]string) {
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], displayWidth(cell))
+		}
+	}
+	for _, row := range rows {
+		var b strings.Builder
+		for i, cell := range row {
+			b.WriteString(cell)
+			if i < len(row)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-displayWidth(cell)+2))
+			}
+		}
+		fmt.Println(b.String())
+	}
+}
+
+func displayWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x1100 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
@@ -1,45 +1,83 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
+	"github.com/lucasrui/neo-nas/internal/agent"
+	"github.com/lucasrui/neo-nas/internal/api"
+	"github.com/lucasrui/neo-nas/internal/backoff"
+	"github.com/lucasrui/neo-nas/internal/backup"
+	"github.com/lucasrui/neo-nas/internal/bisync"
+	"github.com/lucasrui/neo-nas/internal/browse"
+	"github.com/lucasrui/neo-nas/internal/catalog"
 	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/dryrun"
+	"github.com/lucasrui/neo-nas/internal/history"
+	"github.com/lucasrui/neo-nas/internal/hotplug"
+	"github.com/lucasrui/neo-nas/internal/httpd"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/instance"
+	"github.com/lucasrui/neo-nas/internal/logging"
+	"github.com/lucasrui/neo-nas/internal/priority"
+	"github.com/lucasrui/neo-nas/internal/remote"
+	"github.com/lucasrui/neo-nas/internal/server"
+	"github.com/lucasrui/neo-nas/internal/smb"
+	"github.com/lucasrui/neo-nas/internal/status"
+	"github.com/lucasrui/neo-nas/internal/throttle"
+	"github.com/lucasrui/neo-nas/internal/tier"
+	"github.com/lucasrui/neo-nas/internal/version"
 	"github.com/lucasrui/neo-nas/internal/watcher"
+	"github.com/lucasrui/neo-nas/internal/webdav"
 	"github.com/lucasrui/neo-nas/internal/zip"
 )
 
+// logger main 模块的日志
+var logger = logging.New("main")
+
 type WatcherManager struct {
 	watchers map[string]*watcher.Watcher
+	configs  map[string]config.Config // 各监控使用的配置，重新加载配置时比较
+	opts     backup.Options
 	mu       sync.RWMutex
+	stopping map[string]struct{} // 正在停止、还在等待复制完成的源目录
+	stopMu   sync.Mutex
 }
 
-func NewWatcherManager() *WatcherManager {
+func NewWatcherManager(opts backup.Options) *WatcherManager {
 	return &WatcherManager{
 		watchers: make(map[string]*watcher.Watcher),
+		configs:  make(map[string]config.Config),
+		opts:     opts,
 	}
 }
 
-func (wm *WatcherManager) AddWatcher(sourceDir, targetDir, targetUser, progressFile string) error {
+func (wm *WatcherManager) AddWatcher(cfg config.Config) error {
 	// 需要校验目录合法性，如果是空字符串，则返回异常
-	if sourceDir == "" || targetDir == "" || progressFile == "" {
-		return fmt.Errorf("目录不能为空")
+	if cfg.SourceDir == "" || cfg.TargetDir == "" || wm.opts.State == nil {
+		return i18n.Errorf("目录不能为空")
 	}
 
 	wm.mu.Lock()
 	defer wm.mu.Unlock()
 
 	// 检查是否已存在
-	if _, exists := wm.watchers[sourceDir]; exists {
+	if _, exists := wm.watchers[cfg.SourceDir]; exists {
 		return nil
 	}
 
 	// 创建新的 watcher
-	w, err := watcher.NewWatcher(sourceDir, targetDir, targetUser, progressFile)
+	w, err := watcher.NewWatcher(cfg, wm.opts)
 	if err != nil {
 		return err
 	}
@@ -49,70 +87,636 @@ func (wm *WatcherManager) AddWatcher(sourceDir, targetDir, targetUser, progressF
 		return err
 	}
 
-	wm.watchers[sourceDir] = w
-	log.Printf("已添加目录监控: %s", sourceDir)
+	wm.watchers[cfg.SourceDir] = w
+	wm.configs[cfg.SourceDir] = cfg
+	logger.Infof("已添加目录监控: %s", cfg.SourceDir)
 	return nil
 }
 
+// RemoveWatcher 停止并移除源目录的监控，等待正在复制的文件完成
+func (wm *WatcherManager) RemoveWatcher(sourceDir string) {
+	wm.mu.Lock()
+	w, ok := wm.watchers[sourceDir]
+	delete(wm.watchers, sourceDir)
+	delete(wm.configs, sourceDir)
+	wm.mu.Unlock()
+	if !ok {
+		return
+	}
+	if err := w.Stop(); err != nil {
+		logger.Errorf("停止监控失败 %s: %v", sourceDir, err)
+	}
+	logger.Infof("已移除目录监控: %s", sourceDir)
+}
+
+// Config 返回源目录的监控使用的配置，未添加时返回 false
+func (wm *WatcherManager) Config(sourceDir string) (config.Config, bool) {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+	cfg, ok := wm.configs[sourceDir]
+	return cfg, ok
+}
+
+// SourceDirs 返回所有已添加监控的源目录
+func (wm *WatcherManager) SourceDirs() []string {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+	dirs := make([]string, 0, len(wm.watchers))
+	for sourceDir := range wm.watchers {
+		dirs = append(dirs, sourceDir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// Get 返回源目录对应的监控，未添加时返回 nil
+func (wm *WatcherManager) Get(sourceDir string) *watcher.Watcher {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+	return wm.watchers[sourceDir]
+}
+
+// CheckAll 请求所有监控立即检查源目录
+func (wm *WatcherManager) CheckAll() {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+	for _, w := range wm.watchers {
+		w.Check()
+	}
+}
+
+// StopAll 同时停止所有监控，等待各自正在复制的文件完成
 func (wm *WatcherManager) StopAll() {
 	wm.mu.Lock()
 	defer wm.mu.Unlock()
 
+	wm.stopMu.Lock()
+	wm.stopping = make(map[string]struct{}, len(wm.watchers))
+	for sourceDir := range wm.watchers {
+		wm.stopping[sourceDir] = struct{}{}
+	}
+	wm.stopMu.Unlock()
+
+	var wg sync.WaitGroup
 	for sourceDir, w := range wm.watchers {
-		if err := w.Stop(); err != nil {
-			log.Printf("停止监控失败 %s: %v", sourceDir, err)
-		}
+		wg.Add(1)
+		go func(sourceDir string, w *watcher.Watcher) {
+			defer wg.Done()
+			if err := w.Stop(); err != nil {
+				logger.Errorf("停止监控失败 %s: %v", sourceDir, err)
+			}
+			wm.stopMu.Lock()
+			delete(wm.stopping, sourceDir)
+			wm.stopMu.Unlock()
+		}(sourceDir, w)
 		delete(wm.watchers, sourceDir)
+		delete(wm.configs, sourceDir)
 	}
+	wg.Wait()
 }
 
-func main() {
-	log.Println("正在启动 USB 备份程序...")
+// Stopping 返回正在停止、还在等待复制完成的源目录，停止超时时记录到日志
+func (wm *WatcherManager) Stopping() []string {
+	wm.stopMu.Lock()
+	defer wm.stopMu.Unlock()
+	dirs := make([]string, 0, len(wm.stopping))
+	for sourceDir := range wm.stopping {
+		dirs = append(dirs, sourceDir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
 
-	// 加载配置
+// app 各运行模式共用的配置和组件
+type app struct {
+	cfg        *config.NeoConfig
+	catalog    *catalog.Catalog
+	state      *config.StateStore
+	opts       backup.Options
+	remoteOpts remote.Options
+}
+
+// loadApp 加载配置和目录索引，创建备份和远程存储共用的组件
+func loadApp() (*app, error) {
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatalf("程序已停止，加载配置失败: %v", err)
+		return nil, i18n.Errorf("加载配置失败: %w", err)
+	}
+	if err := applyLogConfig(cfg); err != nil {
+		return nil, i18n.Errorf("日志配置错误: %w", err)
+	}
+	if err := i18n.Check(cfg.Language); err != nil {
+		return nil, i18n.Errorf("语言配置错误: %w", err)
+	}
+	logger.Infof("成功加载配置，配置目录: %s", cfg.ConfigDir)
+
+	// 加载目标文件索引，先以只读方式打开，守护进程和单次运行获得实例锁之后才写入
+	cat, err := catalog.OpenReadOnly(cfg.CatalogFile)
+	if err != nil {
+		return nil, i18n.Errorf("加载目录索引失败: %w", err)
+	}
+
+	// 每个文件的备份状态同样先以只读方式打开
+	state, err := config.OpenStateReadOnly(cfg.StateFile)
+	if err != nil {
+		cat.Close()
+		return nil, i18n.Errorf("加载备份状态失败: %w", err)
+	}
+
+	a := &app{cfg: cfg, catalog: cat, state: state}
+	a.opts = backup.Options{
+		State:    state,
+		Hostname: cfg.Hostname,
+		Catalog:  cat,
+		History:  history.Open(cfg.HistoryFile),
+	}
+	if err := priority.Configure(cfg.Priority); err != nil {
+		cat.Close()
+		return nil, i18n.Errorf("优先级配置错误: %w", err)
+	}
+	if err := backoff.Configure(cfg.Backoff); err != nil {
+		cat.Close()
+		return nil, i18n.Errorf("退让配置错误: %w", err)
+	}
+	limiter, err := throttle.NewLimiter(cfg.Bandwidth)
+	if err != nil {
+		cat.Close()
+		return nil, i18n.Errorf("带宽限制配置错误: %w", err)
+	}
+	a.remoteOpts = remote.Options{
+		S3:         cfg.S3,
+		Rclone:     cfg.Rclone,
+		SMB:        cfg.SMB,
+		Limiter:    limiter,
+		StateFile:  filepath.Join(cfg.ConfigDir, ".s3-uploads"),
+		Encryption: cfg.Encryption,
+		IndexFile:  filepath.Join(cfg.ConfigDir, ".encryption-index"),
+	}
+	a.opts.Remote = a.remoteOpts
+	a.opts.SMB = smb.NewMounter(cfg.SMB)
+	if cfg.Encryption.KeyFile != "" {
+		if _, err := remote.LoadKey(cfg.Encryption.KeyFile); err != nil {
+			cat.Close()
+			return nil, i18n.Errorf("加密配置错误: %w", err)
+		}
+	}
+	if cfg.Agent.ServerURL != "" {
+		if a.opts.Agent, err = agent.NewClient(cfg.Agent, filepath.Join(cfg.ConfigDir, ".agent-uploads"), limiter); err != nil {
+			cat.Close()
+			return nil, i18n.Errorf("客户端模式配置错误: %w", err)
+		}
+		logger.Infof("客户端模式已启用，服务端: %s", cfg.Agent.ServerURL)
+	}
+	return a, nil
+}
+
+// mountTarget smb:// 目标挂载共享后改为本机上的路径，其他目标不变
+func (a *app) mountTarget(task *config.Config) error {
+	if !task.IsSMBTarget() {
+		return nil
+	}
+	local, err := a.opts.SMB.Mount(task.TargetDir)
+	if err != nil {
+		return err
+	}
+	task.TargetDir = local
+	return nil
+}
+
+// takeover 全局参数 --takeover，本机已有实例运行时请求其停止
+var takeover bool
+
+// lockInstance 获取本机的实例锁，避免两个实例同时写入同一份备份状态和目录索引
+// 获得锁之后以可写方式重新打开备份状态和目录索引，包括之前的实例退出前写入的记录
+// 演练模式不写入任何状态，不加锁，目录索引保持只读，可以在正式实例运行时检查配置
+func (a *app) lockInstance() (*instance.Lock, error) {
+	if dryrun.Enabled() {
+		return nil, nil
+	}
+	var lock *instance.Lock
+	var err error
+	if takeover {
+		lock, err = instance.Takeover(a.cfg.ConfigDir, a.cfg.Hostname, time.Minute)
+	} else {
+		lock, err = instance.Acquire(a.cfg.ConfigDir, a.cfg.Hostname)
+		if errors.Is(err, instance.ErrRunning) {
+			err = i18n.Errorf("%w，可以使用 --takeover 停止正在运行的实例后启动", err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	cat, err := catalog.Open(a.cfg.CatalogFile)
+	if err != nil {
+		lock.Release()
+		return nil, i18n.Errorf("加载目录索引失败: %w", err)
+	}
+	state, err := config.OpenState(a.cfg.StateFile)
+	if err != nil {
+		cat.Close()
+		lock.Release()
+		return nil, i18n.Errorf("加载备份状态失败: %w", err)
+	}
+	a.catalog, a.opts.Catalog = cat, cat
+	a.state, a.opts.State = state, state
+	return lock, nil
+}
+
+// 命令行指定的日志级别和格式，为空时使用配置中的 log
+var logLevel, logFormat string
+
+// applyLogConfig 按配置设置日志格式、日志文件、全局和各模块的日志级别
+// 命令行指定了 --log-level 时忽略配置中的级别，所有模块都使用命令行的级别；--log-format 同样优先于配置
+func applyLogConfig(cfg *config.NeoConfig) error {
+	if logFormat == "" {
+		if err := logging.SetFormat(cfg.Log.Format); err != nil {
+			return err
+		}
+	}
+	if cfg.Log.File != "" {
+		if err := logging.SetFile(logFile(cfg.Log)); err != nil {
+			return err
+		}
+	}
+	if logLevel != "" {
+		return nil
+	}
+	return logging.SetLevels(cfg.Log.Level, cfg.Log.Modules)
+}
+
+// logFile 日志文件的轮转设置
+func logFile(c config.LogConfig) logging.FileOptions {
+	return logging.FileOptions{
+		Path:     c.File,
+		MaxBytes: int64(c.MaxSizeMB) << 20,
+		Daily:    c.Daily,
+		MaxFiles: c.MaxFiles,
+		MaxAge:   time.Duration(c.MaxAgeDays) * 24 * time.Hour,
+		Compress: c.Compress,
+	}
+}
+
+func usage() {
+	i18n.Fprintf(os.Stderr, "用法: %s [通用参数] [命令] [命令的参数]\n\n", filepath.Base(os.Args[0]))
+	i18n.Fprintln(os.Stderr, "不带命令时与 run 相同，以守护进程方式运行，持续监控源目录；各命令的参数见 <命令> -h")
+	i18n.Fprintln(os.Stderr, "\n通用参数（写在命令之前）:")
+	i18n.Fprintln(os.Stderr, "  --config-dir <目录>  配置目录，优先于 BACKUP_CONFIG_DIR")
+	i18n.Fprintln(os.Stderr, "  --log-level <级别>   日志级别：debug、info（默认）、warn 或 error，优先于配置中的 log")
+	i18n.Fprintln(os.Stderr, "  --log-format <格式>  日志格式：text（默认）或 json，优先于配置中的 log")
+	i18n.Fprintln(os.Stderr, "  --language <语言>    日志、错误和命令输出的语言：zh（默认）或 en，优先于配置中的 language")
+	i18n.Fprintln(os.Stderr, "  --dry-run            演练模式，复制、删除、修改所有者和写入压缩文件等操作只记录日志，用于检查新的配置")
+	i18n.Fprintln(os.Stderr, "  --takeover           本机已有实例在运行时，请求其停止后再启动")
+	i18n.Fprintln(os.Stderr, "\n命令:")
+	i18n.Fprintln(os.Stderr, "  run         以守护进程方式运行，持续监控源目录")
+	i18n.Fprintln(os.Stderr, "  once        扫描所有任务一次，执行到期的压缩任务后输出汇总并退出，供 cron 或 systemd 定时器调用，--all-zips 执行所有压缩任务；也可以写作 run-once")
+	i18n.Fprintln(os.Stderr, "  status      查看正在运行的守护进程中各任务的状态和压缩进度，--json 以 JSON 格式输出")
+	i18n.Fprintln(os.Stderr, "  validate    检查配置文件，列出每个问题及其 JSON 路径，不启动任何任务")
+	i18n.Fprintln(os.Stderr, "  list        列出备份和压缩任务最近一次运行的结果，--json 以 JSON 格式输出")
+	i18n.Fprintln(os.Stderr, "  restore     从备份目标或快照中恢复文件，详见 restore -h")
+	i18n.Fprintln(os.Stderr, "  verify      比较源目录和目标目录，列出缺失或不一致的文件")
+	i18n.Fprintln(os.Stderr, "  scrub       重新计算目标文件的哈希，发现静默损坏的文件，--repair 修复")
+	i18n.Fprintln(os.Stderr, "  zip         立即执行指定的压缩任务，守护进程正在运行时由其在后台执行")
+	i18n.Fprintln(os.Stderr, "  prune       立即按保留策略清理旧快照和同步回收目录")
+	i18n.Fprintln(os.Stderr, "  dupes       统计所有目标中内容相同的文件，--link 替换为硬链接")
+	i18n.Fprintln(os.Stderr, "  bench       测试源目录和目标目录的读写速度，给出配置建议，详见 bench -h")
+	i18n.Fprintln(os.Stderr, "  repair      按恢复数据检查并修复压缩文件和备份文件，--check 只检查")
+	i18n.Fprintln(os.Stderr, "  version     输出当前版本，--check 查询是否有新版本")
+	i18n.Fprintln(os.Stderr, "  service     安装、卸载、启动或停止 Windows 服务，详见 service -h")
+	i18n.Fprintln(os.Stderr, "  install-service  生成并安装 systemd 服务、launchd 代理或 Windows 服务，--print 只输出配置")
+}
+
+// commands 各命令及其入口，参数为命令之后的参数，返回退出码
+var commands = map[string]func(args []string) int{
+	"run":             runRun,
+	"once":            runOnce,
+	"run-once":        runOnce,
+	"status":          runStatus,
+	"validate":        runValidate,
+	"list":            runList,
+	"restore":         runRestore,
+	"verify":          runVerify,
+	"scrub":           runScrub,
+	"zip":             runZip,
+	"prune":           runPrune,
+	"dupes":           runDupes,
+	"bench":           runBench,
+	"repair":          runRepair,
+	"version":         runVersion,
+	"service":         runService,
+	"install-service": runInstallService,
+}
+
+func main() {
+	// 通用参数写在命令之前，遇到第一个不是参数的命令时停止解析
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	// 用法在设置语言之后输出
+	fs.Usage = func() {}
+	configDir := fs.String("config-dir", "", i18n.T("配置目录"))
+	fs.StringVar(&logLevel, "log-level", "", i18n.T("日志级别"))
+	fs.StringVar(&logFormat, "log-format", "", i18n.T("日志格式"))
+	dryRun := fs.Bool("dry-run", false, i18n.T("演练模式"))
+	fs.BoolVar(&takeover, "takeover", false, i18n.T("请求已在运行的实例停止"))
+	language := fs.String("language", "", i18n.T("语言"))
+	showVersion := fs.Bool("version", false, i18n.T("输出当前版本"))
+	parseErr := fs.Parse(os.Args[1:])
+	if *configDir != "" {
+		os.Setenv("BACKUP_CONFIG_DIR", *configDir)
+	}
+	if *language != "" {
+		if err := i18n.SetLanguage(*language); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitConfig)
+		}
+	} else {
+		// 配置中的语言无效时先使用中文，由 validate 和加载配置时报告
+		i18n.SetLanguage(config.ReadLanguage())
+	}
+	if parseErr != nil {
+		usage()
+		if parseErr == flag.ErrHelp {
+			return
+		}
+		os.Exit(exitConfig)
+	}
+	if err := logging.Setup(logLevel); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitConfig)
+	}
+	if err := logging.SetFormat(logFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitConfig)
+	}
+	if *dryRun {
+		dryrun.Enable()
+	}
+
+	args := fs.Args()
+	if *showVersion {
+		args = []string{"version"}
+	}
+	if len(args) == 0 {
+		os.Exit(runDaemon(nil))
+	}
+	if args[0] == "help" {
+		usage()
 		return
 	}
-	log.Printf("成功加载配置，配置目录: %s", cfg.ConfigDir)
+	run, ok := commands[args[0]]
+	if !ok {
+		i18n.Fprintf(os.Stderr, "未知的命令: %s\n\n", args[0])
+		usage()
+		os.Exit(exitConfig)
+	}
+	os.Exit(run(args[1:]))
+}
+
+// runRun 以守护进程方式运行，与不带命令时相同
+func runRun(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.Usage = func() {
+		i18n.Fprintf(os.Stderr, "用法: %s [通用参数] run\n\n", filepath.Base(os.Args[0]))
+		i18n.Fprintln(os.Stderr, "以守护进程方式运行，持续监控源目录，收到 SIGINT 或 SIGTERM 时退出")
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitConfig
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return exitConfig
+	}
+	return runDaemon(nil)
+}
+
+// runDaemon 以守护进程方式运行，直到收到中断信号、被新实例接管或 stop 关闭
+func runDaemon(stop <-chan struct{}) int {
+	started := time.Now()
+	logger.Infof("正在启动 USB 备份程序 %s...", version.Info())
+	if dryrun.Enabled() {
+		logger.Info("演练模式：不会复制、删除或修改任何文件，只记录将要执行的操作")
+	}
+
+	// 加载配置
+	a, err := loadApp()
+	if err != nil {
+		logger.Errorf("程序已停止，%v", err)
+		return exitFailed
+	}
+	lock, err := a.lockInstance()
+	if err != nil {
+		logger.Errorf("程序已停止，%v", err)
+		return exitFailed
+	}
+	defer lock.Release()
+	defer a.catalog.Close()
+	defer a.state.Close()
+	cfg, cat, opts, remoteOpts := a.cfg, a.catalog, a.opts, a.remoteOpts
+	var shutdown <-chan struct{}
+	if lock != nil {
+		// 控制套接字不可用时仍然运行，只是无法被新实例接管
+		if shutdown, err = lock.Listen(); err != nil {
+			logger.Warnf("%v，--takeover 将无法停止本实例", err)
+		}
+	}
 
 	// 备份相关任务
-	log.Printf("已配置 %d 个备份任务:", len(cfg.BackupConfigs))
+	logger.Infof("已配置 %d 个备份任务:", len(cfg.BackupConfigs))
 
 	for i, bc := range cfg.BackupConfigs {
-		log.Printf("  任务 %d: %s -> %s", i+1, bc.SourceDir, bc.TargetDir)
+		logger.Infof("  任务 %d: %s -> %s", i+1, bc.SourceDir, bc.TargetDir)
 	}
 
 	// 创建 watcher 管理器
-	wm := NewWatcherManager()
+	wm := NewWatcherManager(opts)
 
 	// 为每个配置创建 watcher，当所有任务都失败时退出，否则继续
 	allFailed := true
 	for _, backupCfg := range cfg.BackupConfigs {
-		if err := wm.AddWatcher(backupCfg.SourceDir, backupCfg.TargetDir, backupCfg.TargetUser, cfg.ProgressFile); err != nil {
-			log.Printf("添加目录监控失败 %s: %v", backupCfg.SourceDir, err)
+		if err := wm.AddWatcher(backupCfg); err != nil {
+			logger.Errorf("添加目录监控失败 %s: %v", backupCfg.SourceDir, err)
 		} else {
 			allFailed = false
 		}
 	}
 
-	// 压缩相关任务，先校验zip配置是否存在
-	if cfg.ZipConfig.IntervalSeconds > 0 {
-		zip.StartZipManager(cfg.ZipConfig)
+	// 双向同步任务
+	var syncTasks []*bisync.Task
+	for _, syncCfg := range cfg.SyncConfigs {
+		task, err := bisync.NewTask(syncCfg, bisync.StateFile(cfg.ConfigDir, syncCfg))
+		if err != nil {
+			logger.Errorf("添加同步任务失败 %s <-> %s: %v", syncCfg.Left, syncCfg.Right, err)
+			continue
+		}
+		task.Start()
+		syncTasks = append(syncTasks, task)
+		logger.Infof("已添加同步任务: %s <-> %s", syncCfg.Left, syncCfg.Right)
+		allFailed = false
+	}
+
+	// 冷存储迁移任务
+	var tierJobs []*tier.Job
+	for _, tierCfg := range cfg.Tiering {
+		job, err := tier.NewJob(tierCfg, cat, remoteOpts)
+		if err != nil {
+			logger.Errorf("添加冷存储迁移失败 %s: %v", tierCfg.Target, err)
+			continue
+		}
+		job.Start()
+		tierJobs = append(tierJobs, job)
+		logger.Infof("已添加冷存储迁移: %s -> %s, %d 天未使用的文件", tierCfg.Target, tierCfg.Cold, tierCfg.AfterDays)
+	}
+
+	// 内置 HTTP 服务，服务端模式的任务也算作有效任务
+	var httpSrv *httpd.Server
+	if cfg.Server.Enabled && dryrun.Skip("启动服务端模式，接收客户端上传的文件") {
+		allFailed = false
+	} else if cfg.Server.Enabled {
+		srv, err := server.NewServer(cfg.Server, cat)
+		if err != nil {
+			logger.Errorf("启动服务端模式失败: %v", err)
+		} else {
+			httpSrv = httpd.NewServer(cfg.HTTP)
+			srv.Register(httpSrv)
+			allFailed = false
+		}
+	}
+	if cfg.Browser.Enabled {
+		if httpSrv == nil {
+			httpSrv = httpd.NewServer(cfg.HTTP)
+		}
+		browse.NewBrowser(httpd.TargetRoots(cfg), remoteOpts).Register(httpSrv, cfg.Browser)
+	}
+	if cfg.WebDAV.Enabled {
+		if httpSrv == nil {
+			httpSrv = httpd.NewServer(cfg.HTTP)
+		}
+		// 演练模式下 WebDAV 只读
+		webdav.NewHandler(httpd.TargetRoots(cfg), cfg.WebDAV.ReadWrite && !dryrun.Enabled()).Register(httpSrv, cfg.WebDAV)
+	}
+
+	// 压缩相关任务，未配置压缩间隔和 cron 时不定时执行，重新加载配置后可能启用
+	zipMgr := zip.NewZipManager(cfg.ZipConfig, remoteOpts, opts.History, cfg.ZipStateFile)
+	if cfg.ZipConfig.Scheduled() {
+		if len(zipMgr.Items) == 0 {
+			logger.Infof("压缩任务列表为空，不启动压缩任务")
+		} else {
+			logger.Infof("已配置 %d 个压缩任务", len(zipMgr.Items))
+			allFailed = false
+		}
+	}
+	go zipMgr.Start()
+
+	// 状态接口和管理接口
+	ctl := &daemonControl{cfg: cfg, wm: wm, zipMgr: zipMgr, store: opts.History, started: started}
+	if lock != nil {
+		// zip 命令通过控制套接字请求立即执行压缩任务，status 命令查询状态
+		lock.Handle("zip", ctl.runZipNow)
+		lock.Handle("status", ctl.statusReply)
+	}
+	if cfg.Status.Enabled {
+		if httpSrv == nil {
+			httpSrv = httpd.NewServer(cfg.HTTP)
+		}
+		status.NewHandler(ctl.Status).Register(httpSrv, cfg.Status)
+	}
+	if cfg.API.Enabled {
+		if httpSrv == nil {
+			httpSrv = httpd.NewServer(cfg.HTTP)
+		}
+		api.NewHandler(ctl, cfg.API.Token).Register(httpSrv)
+	}
+	if httpSrv != nil {
+		httpSrv.Start()
 	}
 
 	if allFailed {
-		log.Fatal("程序已停止，所有任务都失败")
-		return
+		logger.Error("程序已停止，所有任务都失败")
+		return exitFailed
+	}
+
+	// 定期检查新版本，结果显示在 list 命令中
+	updateStop := make(chan struct{})
+	version.Start(cfg.UpdateCheck, cfg.UpdateCheckFile, updateStop)
+	// 每天按保留策略清理一次旧快照和同步回收目录
+	startPrune(ctl.config, updateStop)
+	// 按 scrub.interval_days 定期校验目标文件
+	startScrub(a, ctl.config, updateStop)
+	// 插入或拔出磁盘后立即检查源目录，不等待 poll_interval_seconds
+	if err := hotplug.Watch(wm.CheckAll, updateStop); err != nil && err != hotplug.ErrUnsupported {
+		logger.Warnf("无法监听磁盘插拔，只按间隔检查源目录: %v", err)
 	}
 
-	// 等待中断信号
+	// 等待中断信号，SIGHUP 时重新加载配置
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+wait:
+	for {
+		select {
+		case <-hupChan:
+			if err := ctl.Reload(); err != nil {
+				logger.Errorf("重新加载配置失败，继续使用原来的配置: %v", err)
+			}
+		case <-sigChan:
+			break wait
+		case <-shutdown:
+			break wait
+		case <-stop:
+			break wait
+		}
+	}
+	signal.Stop(hupChan)
+	close(updateStop)
+
+	// 超过等待时间或再次收到中断信号时强制退出，已复制完成的文件和目录索引不受影响
+	timeout := time.Duration(cfg.ShutdownTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	logger.Infof("正在停止，等待进行中的任务完成，最长 %s", timeout)
+	go func() {
+		select {
+		case <-time.After(timeout):
+			logger.Warnf("等待任务完成超时，强制退出")
+			if busy := wm.Stopping(); len(busy) > 0 {
+				// 这些任务中正在复制的文件只留下临时文件，下次扫描时重新复制
+				logger.Infof("仍有文件正在复制的任务: %s", strings.Join(busy, ", "))
+			}
+		case <-sigChan:
+			logger.Warnf("再次收到中断信号，强制退出")
+		}
+		a.state.Close()
+		a.catalog.Close()
+		os.Exit(exitFailed)
+	}()
+
+	// 压缩任务与其他任务同时停止，临近超时仍未完成时中止，留出删除不完整压缩文件的时间
+	zipStopped := make(chan struct{})
+	go func() {
+		defer close(zipStopped)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout*9/10)
+		zipMgr.Stop(ctx)
+		cancel()
+	}()
 
-	// 停止所有监控
+	// 先停止 HTTP 服务，不再接收客户端上传，等待进行中的请求完成
+	if httpSrv != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		if err := httpSrv.Shutdown(ctx); err != nil {
+			logger.Errorf("停止 HTTP 服务失败: %v", err)
+		}
+		cancel()
+	}
+	// 停止所有监控，中止扫描并等待正在复制的文件完成
 	wm.StopAll()
-	log.Println("程序已停止")
+	for _, task := range syncTasks {
+		task.Stop()
+	}
+	for _, job := range tierJobs {
+		job.Stop()
+	}
+	<-zipStopped
+	a.opts.SMB.UnmountAll()
+	logger.Info("程序已停止")
+	return exitOK
 }
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/bisync"
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/dryrun"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/snapshot"
+)
+
+// runPrune 立即按保留策略清理旧快照和同步回收目录，列出删除的内容和释放的空间
+// 用法: prune [--dry-run]
+func runPrune(args []string) int {
+	fs := flag.NewFlagSet("prune", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, i18n.T("只列出将要删除的内容，不删除"))
+	fs.Usage = func() {
+		i18n.Fprintf(os.Stderr, "用法: %s prune [选项]\n\n", filepath.Base(os.Args[0]))
+		i18n.Fprintln(os.Stderr, "按 snapshot 的 keep、keep_daily、keep_weekly 清理旧快照，按 trash_days 清理双向同步的回收目录")
+		i18n.Fprintln(os.Stderr, "\n选项:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitConfig
+	}
+	if dryrun.Enabled() {
+		*dryRun = true
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return exitConfig
+	}
+
+	a, err := loadApp()
+	if err != nil {
+		logger.Errorf("程序已停止，%v", err)
+		return exitConfig
+	}
+	defer a.catalog.Close()
+
+	label, verb := i18n.T("已删除"), i18n.T("删除")
+	if *dryRun {
+		label, verb = i18n.T("将删除"), i18n.T("将删除")
+	}
+	r := pruneExpired(a.cfg, *dryRun, func(item string) {
+		fmt.Printf("[%s] %s\n", label, item)
+	})
+	code := exitOK
+	if r.failed {
+		code = exitFailed
+	}
+	if r.count == 0 {
+		i18n.Println("没有需要清理的内容")
+		return code
+	}
+	i18n.Printf("\n共%s %d 项，释放 %s\n", verb, r.count, r.total())
+	return code
+}
+
+// pruneResult 一次清理的结果
+type pruneResult struct {
+	count   int
+	freed   int64
+	unknown bool // 部分快照无法统计大小
+	failed  bool
+}
+
+func (r pruneResult) total() string {
+	total := formatSize(r.freed)
+	if r.unknown {
+		total += i18n.T(" 以上（部分快照无法统计大小）")
+	}
+	return total
+}
+
+// pruneExpired 按保留策略清理旧快照和同步回收目录，每删除（演练时为将要删除）一项调用一次 report
+func pruneExpired(cfg *config.NeoConfig, dryRun bool, report func(item string)) pruneResult {
+	var r pruneResult
+	for _, task := range cfg.BackupConfigs {
+		if task.Snapshot.Type == "" || !task.IsLocalTarget() {
+			continue
+		}
+		sn, err := snapshot.New(task.Snapshot, task.TargetDir, cfg.Hostname)
+		if err != nil || sn == nil {
+			if err != nil {
+				logger.Errorf("快照配置错误 %s: %v", task.TargetDir, err)
+				r.failed = true
+			}
+			continue
+		}
+		if !sn.Retains() {
+			continue
+		}
+		expired, err := sn.Expired()
+		if err != nil {
+			logger.Errorf("%s: %v", task.TargetDir, err)
+			r.failed = true
+			continue
+		}
+		for _, snap := range expired {
+			size := sn.Size(snap.Name)
+			if !dryRun {
+				if err := sn.Remove(snap.Name); err != nil {
+					logger.Error(err.Error())
+					r.failed = true
+					continue
+				}
+			}
+			report(i18n.Sprintf("快照 %s@%s, %s", task.TargetDir, snap.Name, sizeText(size)))
+			r.count++
+			if size < 0 {
+				r.unknown = true
+			} else {
+				r.freed += size
+			}
+		}
+	}
+
+	now := time.Now()
+	for _, syncCfg := range cfg.SyncConfigs {
+		if syncCfg.TrashDays <= 0 {
+			continue
+		}
+		task, err := bisync.NewTask(syncCfg, bisync.StateFile(cfg.ConfigDir, syncCfg))
+		if err != nil {
+			logger.Errorf("同步任务配置错误 %s <-> %s: %v", syncCfg.Left, syncCfg.Right, err)
+			r.failed = true
+			continue
+		}
+		expired, err := task.ExpiredTrash(now)
+		if err != nil {
+			logger.Error(err.Error())
+			r.failed = true
+			continue
+		}
+		for _, b := range expired {
+			if !dryRun {
+				if err := task.PurgeTrash([]bisync.TrashBatch{b}); err != nil {
+					logger.Error(err.Error())
+					r.failed = true
+					continue
+				}
+			}
+			report(i18n.Sprintf("回收目录 %s, %d 个文件, %s", b.Dir, b.Files, sizeText(b.Size)))
+			r.count++
+			r.freed += b.Size
+		}
+	}
+	return r
+}
+
+// 守护进程定期清理的间隔，启动后先等待一段时间，避开启动时的首次扫描
+const (
+	pruneInterval = 24 * time.Hour
+	pruneDelay    = 10 * time.Minute
+)
+
+// startPrune 在后台定期按保留策略清理，扫描后没有新文件、不会创建快照的任务同样按时清理
+// current 返回当前的配置，重新加载配置后按新的保留策略清理
+func startPrune(current func() *config.NeoConfig, stop <-chan struct{}) {
+	go func() {
+		timer := time.NewTimer(pruneDelay)
+		defer timer.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-timer.C:
+			}
+			label := i18n.T("已删除")
+			if dryrun.Enabled() {
+				label = i18n.T("将删除")
+			}
+			r := pruneExpired(current(), dryrun.Enabled(), func(item string) {
+				logger.Infof("定期清理，%s%s", label, item)
+			})
+			if r.count > 0 {
+				logger.Infof("定期清理完成，共 %d 项，释放 %s", r.count, r.total())
+			}
+			timer.Reset(pruneInterval)
+		}
+	}()
+}
+
+// sizeText 大小未知时返回说明文字
+func sizeText(size int64) string {
+	if size < 0 {
+		return i18n.T("大小未知")
+	}
+	return formatSize(size)
+}
+
+func formatSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(size)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
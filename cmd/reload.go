@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// Reload 重新读取配置文件，按新的配置添加、移除或重新启动备份任务，并替换压缩任务
+// 配置没有变化的备份任务继续运行，不中断正在进行的扫描；其他设置需要重启后生效
+func (d *daemonControl) Reload() error {
+	d.reload.Lock()
+	defer d.reload.Unlock()
+
+	loaded, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	old := d.config()
+	next := *old
+	next.BackupConfigs, next.ZipConfig, next.Log = loaded.BackupConfigs, loaded.ZipConfig, loaded.Log
+	if err := applyLogConfig(&next); err != nil {
+		return i18n.Errorf("日志配置错误: %w", err)
+	}
+	logger.Infof("重新加载配置: %d 个备份任务, %d 个压缩任务", len(next.BackupConfigs), len(next.ZipConfig.Items))
+
+	// 只有备份和压缩任务以及日志设置可以重新加载，其余设置与启动时不同时提示需要重启
+	cmp := *loaded
+	cmp.BackupConfigs, cmp.ZipConfig, cmp.Log = old.BackupConfigs, old.ZipConfig, old.Log
+	if !reflect.DeepEqual(cmp, *old) {
+		logger.Infof("除备份、压缩任务和日志设置以外的设置有变化，需要重启后生效")
+	}
+
+	wanted := make(map[string]config.Config, len(next.BackupConfigs))
+	for _, bc := range next.BackupConfigs {
+		// 与启动时相同，同一源目录只使用第一个任务的配置
+		if _, dup := wanted[bc.SourceDir]; !dup {
+			wanted[bc.SourceDir] = bc
+		}
+	}
+
+	// 先移除已删除或有变化的任务，等待其正在复制的文件完成
+	for _, sourceDir := range d.wm.SourceDirs() {
+		cur, _ := d.wm.Config(sourceDir)
+		if bc, ok := wanted[sourceDir]; ok && reflect.DeepEqual(bc, cur) {
+			continue
+		}
+		d.wm.RemoveWatcher(sourceDir)
+	}
+	for _, bc := range next.BackupConfigs {
+		if d.wm.Get(bc.SourceDir) != nil || !reflect.DeepEqual(bc, wanted[bc.SourceDir]) {
+			continue
+		}
+		if err := d.wm.AddWatcher(bc); err != nil {
+			logger.Errorf("添加目录监控失败 %s: %v", bc.SourceDir, err)
+		}
+	}
+
+	if !reflect.DeepEqual(next.ZipConfig, old.ZipConfig) {
+		d.zipMgr.Update(next.ZipConfig)
+		logger.Infof("已更新压缩任务，压缩间隔 %d 秒", next.ZipConfig.IntervalSeconds)
+	}
+
+	d.cfgMu.Lock()
+	d.cfg = &next
+	d.cfgMu.Unlock()
+	logger.Infof("配置已重新加载")
+	return nil
+}
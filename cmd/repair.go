@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/dryrun"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/parity"
+)
+
+// runRepair 按恢复数据检查文件，有损坏时修复，有无法修复的文件时退出码为 1
+// 用法: repair [--check] <文件或目录...>
+func runRepair(args []string) int {
+	fs := flag.NewFlagSet("repair", flag.ContinueOnError)
+	checkOnly := fs.Bool("check", false, i18n.T("只检查，不修复"))
+	fs.Usage = func() {
+		i18n.Fprintf(os.Stderr, "用法: %s repair [--check] <文件或目录...>\n\n", filepath.Base(os.Args[0]))
+		i18n.Fprintln(os.Stderr, "指定目录时检查其中所有有恢复数据的文件")
+		i18n.Fprintln(os.Stderr, "\n选项:")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterleaved(fs, args)
+	if err != nil {
+		return exitConfig
+	}
+	if len(positional) == 0 {
+		fs.Usage()
+		return exitConfig
+	}
+	if dryrun.Enabled() {
+		*checkOnly = true
+	}
+
+	var files []string
+	for _, arg := range positional {
+		info, err := os.Stat(arg)
+		if err != nil {
+			logger.Warnf("路径不存在: %s", arg)
+			return exitConfig
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		found, err := sidecars(arg)
+		if err != nil {
+			logger.Error(err.Error())
+			return exitFailed
+		}
+		files = append(files, found...)
+	}
+
+	code := exitOK
+	var ok, repaired, damaged int
+	for _, file := range files {
+		var r parity.Report
+		if *checkOnly {
+			r, err = parity.Check(file)
+		} else {
+			r, err = parity.Repair(file)
+		}
+		switch {
+		case errors.Is(err, parity.ErrNoSidecar):
+			i18n.Printf("[无恢复数据] %s\n", file)
+			code = exitFailed
+		case err != nil:
+			i18n.Printf("[无法修复] %s: %v\n", file, err)
+			damaged++
+			code = exitFailed
+		case r.Repaired:
+			i18n.Printf("[已修复] %s, %s\n", file, damageText(r))
+			repaired++
+		case !r.OK():
+			state := i18n.T("可以修复")
+			if !r.Recoverable {
+				state = i18n.T("无法修复")
+			}
+			i18n.Printf("[损坏] %s, %s, %s\n", file, damageText(r), state)
+			damaged++
+			code = exitFailed
+		default:
+			ok++
+		}
+	}
+	i18n.Printf("\n共检查 %d 个文件，完好 %d，已修复 %d，损坏 %d\n", len(files), ok, repaired, damaged)
+	return code
+}
+
+// damageText 损坏情况的说明
+func damageText(r parity.Report) string {
+	var parts []string
+	if len(r.BadBlocks) > 0 {
+		parts = append(parts, i18n.Sprintf("%d/%d 块数据损坏", len(r.BadBlocks), r.Blocks))
+	}
+	if r.SizeChanged {
+		parts = append(parts, i18n.T("文件大小改变"))
+	}
+	if r.BadParity > 0 {
+		parts = append(parts, i18n.Sprintf("%d/%d 块恢复数据损坏", r.BadParity, r.ParityBlocks))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sidecars 查找目录中所有有恢复数据的文件
+func sidecars(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if src := parity.Source(path); src != "" && !strings.HasSuffix(path, ".tmp") {
+			files = append(files, src)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, i18n.Errorf("遍历目录失败: %w", err)
+	}
+	return files, nil
+}
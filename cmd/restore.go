@@ -0,0 +1,320 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/backup"
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/dryrun"
+	"github.com/lucasrui/neo-nas/internal/history"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/restore"
+	"github.com/lucasrui/neo-nas/internal/snapshot"
+	"github.com/lucasrui/neo-nas/internal/zip"
+)
+
+// runRestore 把备份任务目标目录、快照或压缩文件中的文件复制回源目录或指定目录
+// 用法: restore <任务> [路径...] [--to <目录>]
+func runRestore(args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	taskArg := fs.String("task", "", i18n.T("要恢复的备份任务，序号或源目录、目标目录，也可以写在第一个参数中"))
+	to := fs.String("to", "", i18n.T("恢复到的目录，默认恢复到任务的源目录"))
+	var patterns []string
+	fs.Func("path", i18n.T("只恢复匹配规则的文件，规则与 include 相同，例如 '*.jpg'、'photos/2023/**'，可以指定多次"), func(v string) error {
+		patterns = append(patterns, v)
+		return nil
+	})
+	archive := fs.String("archive", "", i18n.T("从压缩任务生成的压缩文件中恢复，默认恢复到压缩任务的源目录"))
+	snap := fs.String("snapshot", "", i18n.T("从指定名称的快照中恢复，默认从目标目录恢复"))
+	overwrite := fs.String("overwrite", restore.OverwriteNever, i18n.T("已有同名文件时的处理: never、newer 或 always"))
+	dryRun := fs.Bool("dry-run", false, i18n.T("只列出将要恢复的文件，不写入"))
+	asOf := fs.String("as-of", "", i18n.T("恢复指定时间点的文件，例如 2024-05-01T00:00"))
+	host := fs.String("host", "", i18n.T("开启 host_namespace 时恢复哪台机器的备份，默认本机"))
+	list := fs.Bool("list", false, i18n.T("列出任务可以恢复到的时间点，不恢复文件"))
+	fs.Usage = func() {
+		i18n.Fprintf(os.Stderr, "用法: %s restore <任务序号或目录> [路径...] [--to <目录>] [选项]\n", filepath.Base(os.Args[0]))
+		i18n.Fprintf(os.Stderr, "      %s restore --archive <压缩文件> [路径...] [--to <目录>] [选项]\n\n", filepath.Base(os.Args[0]))
+		i18n.Fprintln(os.Stderr, "路径相对于备份根目录，不指定时恢复全部文件")
+		i18n.Fprintln(os.Stderr, "\n选项:")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterleaved(fs, args)
+	if err != nil {
+		return exitConfig
+	}
+	if dryrun.Enabled() {
+		*dryRun = true
+	}
+	paths := positional
+	if *taskArg == "" && *archive == "" {
+		if len(positional) == 0 {
+			fs.Usage()
+			return exitConfig
+		}
+		*taskArg, paths = positional[0], positional[1:]
+	}
+	if *archive != "" && (*taskArg != "" || *snap != "" || *asOf != "") {
+		i18n.Fprintln(os.Stderr, "--archive 不能与任务、--snapshot 或 --as-of 同时使用")
+		return exitConfig
+	}
+	match, err := backup.NewMatcher(patterns)
+	if err != nil {
+		i18n.Fprintf(os.Stderr, "--path 规则无效: %v\n", err)
+		return exitConfig
+	}
+	opts := restore.Options{
+		To:        *to,
+		Paths:     paths,
+		Overwrite: *overwrite,
+		DryRun:    *dryRun,
+	}
+	if len(match) > 0 {
+		opts.Match = match.Match
+	}
+	var asOfTime time.Time
+	if *asOf != "" {
+		if *snap != "" {
+			i18n.Fprintln(os.Stderr, "--as-of 和 --snapshot 不能同时使用")
+			return exitConfig
+		}
+		if asOfTime, err = parseTime(*asOf); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitConfig
+		}
+	}
+
+	a, err := loadApp()
+	if err != nil {
+		logger.Errorf("程序已停止，%v", err)
+		return exitConfig
+	}
+	defer a.catalog.Close()
+	opts.Remote = a.remoteOpts
+
+	if *archive != "" {
+		return restoreArchive(a.cfg, *archive, opts)
+	}
+	task, err := findTask(a.cfg, *taskArg)
+	if err != nil {
+		logger.Error(err.Error())
+		return exitConfig
+	}
+	if task.IsAgentTarget() {
+		logger.Warnf("推送到服务端的任务需要在服务端恢复: %s", task.TargetDir)
+		return exitConfig
+	}
+	if task.IsStoreTarget() {
+		logger.Warnf("暂不支持从 S3 或 rclone 目标恢复，可以使用 S3 工具或 rclone 下载: %s", task.TargetDir)
+		return exitConfig
+	}
+	if *host == "" {
+		*host = a.cfg.Hostname
+	}
+	if *list {
+		return listRestorePoints(a, task, *host)
+	}
+	if opts.To == "" {
+		// 其他机器的备份不能默认恢复到本机的源目录
+		if task.HostNamespace && *host != a.cfg.Hostname {
+			i18n.Fprintln(os.Stderr, "恢复其他机器的备份时需要用 --to 指定恢复到的目录")
+			return exitConfig
+		}
+		to, err := task.ResolveSource()
+		if err != nil {
+			i18n.Fprintf(os.Stderr, "找不到源目录 %s: %v，可以用 --to 指定恢复到的目录\n", task.SourceDir, err)
+			return exitFailed
+		}
+		opts.To = to
+		logger.Infof("未指定 --to，恢复到源目录: %s", opts.To)
+	}
+	defer a.opts.SMB.UnmountAll()
+	if err := a.mountTarget(&task); err != nil {
+		logger.Error(err.Error())
+		return exitConfig
+	}
+
+	root := task.TargetDir
+	if *snap != "" {
+		sn, err := snapshot.New(task.Snapshot, task.TargetDir, *host)
+		if err == nil && sn == nil {
+			err = i18n.Errorf("任务未配置快照: %s", task.TargetDir)
+		}
+		if err == nil {
+			root, err = sn.Path(*snap)
+		}
+		if err != nil {
+			logger.Error(err.Error())
+			return exitConfig
+		}
+	}
+	var snapRoot string
+	if !asOfTime.IsZero() {
+		if snapRoot, err = snapshotAsOf(task, *host, asOfTime); err != nil {
+			logger.Error(err.Error())
+			return exitConfig
+		}
+	}
+	if task.HostNamespace {
+		root = filepath.Join(root, *host)
+		if snapRoot != "" {
+			snapRoot = filepath.Join(snapRoot, *host)
+		}
+	}
+
+	logger.Infof("开始恢复: %s -> %s", root, opts.To)
+	opts.AsOf, opts.Catalog, opts.Snapshot = asOfTime, a.catalog, snapRoot
+	stats, err := restore.Run(root, opts)
+	return restoreResult(stats, err, opts.DryRun)
+}
+
+// restoreArchive 从压缩文件中恢复，压缩文件属于某个压缩任务时使用其密钥，并默认恢复到其源目录
+func restoreArchive(cfg *config.NeoConfig, archive string, opts restore.Options) int {
+	var key string
+	format := zip.FormatOf(archive)
+	for _, item := range cfg.ZipConfig.Items {
+		if zip.Produces(item, archive) {
+			key = item.Key
+			if item.Format != "" {
+				format = item.Format
+			}
+			if opts.To == "" {
+				opts.To = item.Source
+				logger.Infof("未指定 --to，恢复到压缩任务的源目录: %s", opts.To)
+			}
+			break
+		}
+	}
+	if opts.To == "" {
+		i18n.Fprintln(os.Stderr, "压缩文件不属于任何压缩任务，需要用 --to 指定恢复到的目录")
+		return exitConfig
+	}
+	logger.Infof("开始恢复: %s -> %s", archive, opts.To)
+	stats, err := restore.RunArchive(archive, format, key, opts)
+	return restoreResult(stats, err, opts.DryRun)
+}
+
+// restoreResult 输出恢复结果并返回退出码
+func restoreResult(stats restore.Stats, err error, dryRun bool) int {
+	if err != nil {
+		logger.Errorf("恢复失败: %v", err)
+		return exitConfig
+	}
+	verb := i18n.T("已恢复")
+	if dryRun {
+		verb = i18n.T("将恢复")
+	}
+	i18n.Printf("\n恢复完成，%s: %d (%d 字节), 跳过: %d, 失败: %d\n", verb, stats.Restored, stats.Bytes, stats.Skipped, stats.Failed)
+	if stats.Failed > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
+
+// listRestorePoints 列出任务可以恢复到的时间点：目标目录中的最新备份和每个快照，从新到旧排列
+func listRestorePoints(a *app, task config.Config, host string) int {
+	i18n.Printf("%s -> %s 可以恢复到的时间点:\n", task.SourceDir, task.TargetDir)
+	latest := i18n.T("  最新备份")
+	if r, ok := a.opts.History.Last(history.KindBackup, host, task.SourceDir, task.TargetDir); ok {
+		latest += i18n.T("，最近一次扫描完成于 ") + r.End.Format("2006-01-02 15:04:05")
+	}
+	fmt.Println(latest)
+
+	defer a.opts.SMB.UnmountAll()
+	if err := a.mountTarget(&task); err != nil {
+		logger.Error(err.Error())
+		return exitConfig
+	}
+	sn, err := snapshot.New(task.Snapshot, task.TargetDir, host)
+	if err != nil {
+		logger.Error(err.Error())
+		return exitConfig
+	}
+	if sn == nil {
+		i18n.Println("任务未配置快照，只能按目录索引中的备份时间恢复")
+		return exitOK
+	}
+	snaps, err := sn.List()
+	if err != nil {
+		logger.Errorf("列出快照失败: %v", err)
+		return exitFailed
+	}
+	for i := len(snaps) - 1; i >= 0; i-- {
+		t := i18n.T("时间未知")
+		if !snaps[i].Time.IsZero() {
+			t = snaps[i].Time.Format("2006-01-02 15:04:05")
+		}
+		i18n.Printf("  %s  快照 %s\n", t, snaps[i].Name)
+	}
+	i18n.Println("\n使用 --as-of <时间> 恢复某个时间点的文件，或 --snapshot <名称> 恢复某个快照")
+	return exitOK
+}
+
+// snapshotAsOf 返回恢复时间点之前最近的快照对应的目录，任务未配置快照或没有更早的快照时返回空
+func snapshotAsOf(task config.Config, host string, t time.Time) (string, error) {
+	sn, err := snapshot.New(task.Snapshot, task.TargetDir, host)
+	if err != nil || sn == nil {
+		return "", err
+	}
+	snaps, err := sn.List()
+	if err != nil {
+		return "", err
+	}
+	for i := len(snaps) - 1; i >= 0; i-- {
+		if !snaps[i].Time.IsZero() && !snaps[i].Time.After(t) {
+			logger.Infof("使用快照补充已从目标目录删除的文件: %s", snaps[i].Name)
+			return sn.Path(snaps[i].Name)
+		}
+	}
+	logger.Infof("恢复时间点之前没有快照，只按目录索引中的备份时间恢复")
+	return "", nil
+}
+
+// parseTime 按本地时区解析时间，可以只写日期或精确到分钟
+func parseTime(v string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Time{}, i18n.Errorf("无法解析时间: %s，格式例如 2024-05-01T00:00", v)
+}
+
+// parseInterleaved 解析选项，允许选项出现在位置参数之后
+func parseInterleaved(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// findTask 按序号（从 1 开始，与启动日志一致）或源目录、目标目录查找备份任务
+func findTask(cfg *config.NeoConfig, arg string) (config.Config, error) {
+	if n, err := strconv.Atoi(arg); err == nil {
+		if n < 1 || n > len(cfg.BackupConfigs) {
+			return config.Config{}, i18n.Errorf("任务序号超出范围: %d，共 %d 个备份任务", n, len(cfg.BackupConfigs))
+		}
+		return cfg.BackupConfigs[n-1], nil
+	}
+	dir := filepath.Clean(arg)
+	for _, bc := range cfg.BackupConfigs {
+		if filepath.Clean(bc.SourceDir) == dir || filepath.Clean(bc.TargetDir) == dir {
+			return bc, nil
+		}
+	}
+	return config.Config{}, i18n.Errorf("未找到备份任务: %s", arg)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/bisync"
+	"github.com/lucasrui/neo-nas/internal/dryrun"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/priority"
+	"github.com/lucasrui/neo-nas/internal/tier"
+	"github.com/lucasrui/neo-nas/internal/watcher"
+	"github.com/lucasrui/neo-nas/internal/zip"
+)
+
+// 单次运行模式的退出码
+const (
+	exitOK     = 0 // 所有任务都成功
+	exitFailed = 1 // 至少一个任务或文件失败
+	exitConfig = 2 // 配置错误，没有执行任何任务
+)
+
+// runResult 单个任务的执行结果，用于输出汇总
+type runResult struct {
+	kind   string
+	name   string
+	status watcher.DirectoryStatus
+	err    error
+}
+
+func (r runResult) failed() bool {
+	return r.err != nil && r.err != watcher.ErrSourceMissing || r.status.FailedFiles > 0
+}
+
+// runOnce 依次执行所有备份任务、双向同步、冷存储迁移和到期的压缩任务，全部完成后输出汇总并退出
+// 用法: once [--all-zips]，run-once 为同一命令
+func runOnce(args []string) int {
+	fs := flag.NewFlagSet("once", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, i18n.T("演练模式，只记录将要执行的操作"))
+	allZips := fs.Bool("all-zips", false, i18n.T("执行所有压缩任务，不管是否到期，没有设置压缩间隔和 cron 时也执行"))
+	if err := fs.Parse(args); err != nil {
+		return exitConfig
+	}
+	if *dryRun {
+		dryrun.Enable()
+	}
+	logger.Info("单次运行模式")
+	if dryrun.Enabled() {
+		logger.Info("演练模式：不会复制、删除或修改任何文件，只记录将要执行的操作")
+	}
+	a, err := loadApp()
+	if err != nil {
+		logger.Errorf("程序已停止，%v", err)
+		return exitConfig
+	}
+	defer a.opts.SMB.UnmountAll()
+	lock, err := a.lockInstance()
+	if err != nil {
+		logger.Errorf("程序已停止，%v", err)
+		return exitConfig
+	}
+	defer lock.Release()
+	defer a.catalog.Close()
+	defer a.state.Close()
+	priority.Lower()
+	cfg := a.cfg
+	start := time.Now()
+
+	var results []runResult
+	for _, backupCfg := range cfg.BackupConfigs {
+		r := runResult{kind: "备份", name: backupCfg.SourceDir + " -> " + backupCfg.TargetDir}
+		w, err := watcher.NewWatcher(backupCfg, a.opts)
+		if err == nil {
+			r.status, err = w.RunOnce()
+		}
+		r.err = err
+		results = append(results, r)
+	}
+
+	for _, syncCfg := range cfg.SyncConfigs {
+		r := runResult{kind: "同步", name: syncCfg.Left + " <-> " + syncCfg.Right}
+		task, err := bisync.NewTask(syncCfg, bisync.StateFile(cfg.ConfigDir, syncCfg))
+		if err == nil {
+			err = task.Run()
+		}
+		r.err = err
+		results = append(results, r)
+	}
+
+	for _, tierCfg := range cfg.Tiering {
+		r := runResult{kind: "冷存储", name: tierCfg.Target + " -> " + tierCfg.Cold}
+		job, err := tier.NewJob(tierCfg, a.catalog, a.remoteOpts)
+		if err == nil {
+			err = job.Run()
+		}
+		r.err = err
+		results = append(results, r)
+	}
+
+	if cfg.ZipConfig.Scheduled() || *allZips {
+		z := zip.NewZipManager(cfg.ZipConfig, a.remoteOpts, a.opts.History, cfg.ZipStateFile)
+		for _, item := range z.Items {
+			if !*allZips && !z.Due(item, start) {
+				logger.Warnf("压缩任务未到期，跳过: %s", item.Target)
+				continue
+			}
+			results = append(results, runResult{kind: "压缩", name: item.Source + " -> " + item.Target, err: z.Zip(item)})
+		}
+	}
+
+	code := exitOK
+	i18n.Printf("\n运行完成，用时 %s\n", time.Since(start).Round(time.Second))
+	for _, r := range results {
+		state := i18n.T("成功")
+		if r.err == watcher.ErrSourceMissing {
+			state = i18n.T("跳过")
+		}
+		if r.failed() {
+			state = i18n.T("失败")
+			code = exitFailed
+		}
+		line := fmt.Sprintf("[%s] %s %s", state, i18n.T(r.kind), r.name)
+		if r.kind == "备份" {
+			s := r.status
+			line += i18n.Sprintf(", 扫描: %d, 成功: %d, 失败: %d, 跳过: %d", s.TotalFiles, s.SuccessFiles, s.FailedFiles, s.SkippedFiles)
+		}
+		if r.err == watcher.ErrSourceMissing {
+			line += ", " + r.err.Error()
+		} else if r.err != nil {
+			line += i18n.T(", 错误: ") + r.err.Error()
+		}
+		fmt.Println(line)
+	}
+	if len(results) == 0 {
+		i18n.Println("没有需要执行的任务")
+	}
+	return code
+}
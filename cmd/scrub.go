@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/dryrun"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/scrub"
+)
+
+// runScrub 重新计算目标目录中已备份文件的哈希，列出损坏或缺失的文件，有未修复的问题时退出码为 1
+// 用法: scrub [任务...] [--repair] [--report <文件>]
+func runScrub(args []string) int {
+	fs := flag.NewFlagSet("scrub", flag.ContinueOnError)
+	repair := fs.Bool("repair", false, i18n.T("按恢复数据修复损坏的文件，没有恢复数据时在源文件没有变化时重新复制"))
+	report := fs.String("report", "", i18n.T("把校验结果以 JSON 格式写入文件，默认写入配置目录中的 .scrub-report"))
+	fs.Usage = func() {
+		i18n.Fprintf(os.Stderr, "用法: %s scrub [任务序号或目录...] [选项]\n\n", filepath.Base(os.Args[0]))
+		i18n.Fprintln(os.Stderr, "不指定任务时校验所有本地和 SMB 备份任务的目标目录，与目录索引中记录的哈希比较")
+		i18n.Fprintln(os.Stderr, "\n选项:")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterleaved(fs, args)
+	if err != nil {
+		return exitConfig
+	}
+
+	a, err := loadApp()
+	if err != nil {
+		logger.Errorf("程序已停止，%v", err)
+		return exitConfig
+	}
+	defer a.catalog.Close()
+	defer a.opts.SMB.UnmountAll()
+
+	var tasks []config.Config
+	for _, arg := range positional {
+		task, err := findTask(a.cfg, arg)
+		if err != nil {
+			logger.Error(err.Error())
+			return exitConfig
+		}
+		if !task.IsLocalTarget() && !task.IsSMBTarget() {
+			logger.Warnf("只能校验本地或 SMB 目标: %s", task.TargetDir)
+			return exitConfig
+		}
+		tasks = append(tasks, task)
+	}
+	if len(positional) == 0 {
+		tasks = scrubTasks(a.cfg)
+	}
+	if len(tasks) == 0 {
+		i18n.Println("没有可以校验的备份任务")
+		return exitOK
+	}
+
+	reports, failed := scrubTargets(a, tasks, scrub.Options{Catalog: a.catalog, Repair: *repair, DryRun: dryrun.Enabled()})
+	code := exitOK
+	if failed {
+		code = exitFailed
+	}
+	for _, r := range reports {
+		for _, p := range r.Problems {
+			fmt.Printf("[%s] %s\n", i18n.T(p.Kind), p.String())
+		}
+		i18n.Printf("校验完成: %s, 文件: %d, 大小: %s, 问题: %d, 未修复: %d, 用时: %s\n",
+			r.Target, r.Checked, formatSize(r.Bytes), len(r.Problems), r.Unrepaired(), r.End.Sub(r.Start).Round(time.Second))
+		if r.Unrepaired() > 0 {
+			code = exitFailed
+		}
+	}
+
+	file := *report
+	if file == "" && !dryrun.Enabled() {
+		file = a.cfg.ScrubReportFile
+	}
+	if file != "" {
+		if err := scrub.SaveReports(file, reports); err != nil {
+			logger.Error(err.Error())
+			code = exitFailed
+		}
+	}
+	return code
+}
+
+// scrubTasks 可以校验的任务：本地和 SMB 目标
+func scrubTasks(cfg *config.NeoConfig) []config.Config {
+	var tasks []config.Config
+	for _, task := range cfg.BackupConfigs {
+		if task.IsLocalTarget() || task.IsSMBTarget() {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
+
+// scrubTargets 依次校验各任务的目标目录，多个任务使用同一目标目录时只校验一次
+// 校验被中止时返回已完成的结果，挂载或读取失败时 failed 为 true
+func scrubTargets(a *app, tasks []config.Config, opts scrub.Options) (reports []*scrub.Report, failed bool) {
+	seen := make(map[string]bool)
+	for _, task := range tasks {
+		if err := a.mountTarget(&task); err != nil {
+			logger.Errorf("校验失败 %s: %v", task.TargetDir, err)
+			failed = true
+			continue
+		}
+		target := filepath.Clean(task.TargetDir)
+		if seen[target] {
+			continue
+		}
+		seen[target] = true
+		logger.Infof("开始校验目标文件: %s", target)
+		r, err := scrub.Run(target, opts)
+		if errors.Is(err, scrub.ErrStopped) {
+			logger.Infof("校验已中止: %s", target)
+			return reports, failed
+		}
+		if err != nil {
+			logger.Errorf("校验失败 %s: %v", target, err)
+			failed = true
+			continue
+		}
+		reports = append(reports, r)
+	}
+	return reports, failed
+}
+
+// scrubDelay 守护进程启动后首次定期校验前等待的时间，避开启动时的首次扫描
+const scrubDelay = 30 * time.Minute
+
+// startScrub 按 scrub.interval_days 在后台定期校验目标文件，距上一次校验不足间隔时等到间隔后再校验
+// current 返回当前的配置，重新加载配置后按新的间隔和任务校验
+func startScrub(a *app, current func() *config.NeoConfig, stop <-chan struct{}) {
+	go func() {
+		timer := time.NewTimer(nextScrub(current()))
+		defer timer.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-timer.C:
+			}
+			cfg := current()
+			if cfg.Scrub.IntervalDays <= 0 {
+				timer.Reset(nextScrub(cfg))
+				continue
+			}
+			reports, _ := scrubTargets(a, scrubTasks(cfg), scrub.Options{
+				Catalog: a.catalog,
+				Repair:  cfg.Scrub.Repair,
+				DryRun:  dryrun.Enabled(),
+				Stop:    stop,
+			})
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			problems, unrepaired := 0, 0
+			for _, r := range reports {
+				problems += len(r.Problems)
+				unrepaired += r.Unrepaired()
+			}
+			logger.Infof("定期校验完成，目标目录: %d, 问题: %d, 未修复: %d", len(reports), problems, unrepaired)
+			if !dryrun.Enabled() {
+				if err := scrub.SaveReports(cfg.ScrubReportFile, reports); err != nil {
+					logger.Error(err.Error())
+				}
+			}
+			timer.Reset(nextScrub(cfg))
+		}
+	}()
+}
+
+// nextScrub 距下一次定期校验的时间，按上一次校验结束的时间计算，未开启定期校验时一天后再检查配置
+func nextScrub(cfg *config.NeoConfig) time.Duration {
+	if cfg.Scrub.IntervalDays <= 0 {
+		return 24 * time.Hour
+	}
+	interval := time.Duration(cfg.Scrub.IntervalDays) * 24 * time.Hour
+	reports, err := scrub.LoadReports(cfg.ScrubReportFile)
+	if err != nil {
+		logger.Errorf("读取上一次的校验结果失败: %v", err)
+	}
+	var last time.Time
+	for _, r := range reports {
+		if r.End.After(last) {
+			last = r.End
+		}
+	}
+	wait := time.Until(last.Add(interval))
+	if wait < scrubDelay {
+		wait = scrubDelay
+	}
+	return wait
+}
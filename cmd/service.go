@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+	"github.com/lucasrui/neo-nas/internal/service"
+)
+
+// runService 管理 Windows 服务
+// 用法: service install|uninstall|start|stop [--name <服务名>] [--config-dir <目录>]
+// service run 由服务控制管理器调用，不需要手动执行
+func runService(args []string) int {
+	fs := flag.NewFlagSet("service", flag.ContinueOnError)
+	name := fs.String("name", service.Name, i18n.T("服务名称"))
+	configDir := fs.String("config-dir", "", i18n.T("服务使用的配置目录，默认为 BACKUP_CONFIG_DIR 或 %ProgramData%\\neo-nas"))
+	fs.Usage = func() {
+		i18n.Fprintf(os.Stderr, "用法: %s service <install|uninstall|start|stop> [选项]\n\n", filepath.Base(os.Args[0]))
+		i18n.Fprintln(os.Stderr, "install 注册开机自动启动的服务，异常退出后自动重启，需要管理员权限")
+		i18n.Fprintln(os.Stderr, "\n选项:")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterleaved(fs, args)
+	if err != nil {
+		return exitConfig
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return exitConfig
+	}
+	if *configDir == "" {
+		*configDir = config.Dir()
+	}
+
+	switch positional[0] {
+	case "install":
+		exe, err := os.Executable()
+		if err != nil {
+			logger.Errorf("获取程序路径失败: %v", err)
+			return exitFailed
+		}
+		dir, err := filepath.Abs(*configDir)
+		if err != nil {
+			logger.Errorf("配置目录无效: %v", err)
+			return exitConfig
+		}
+		if _, err := os.Stat(filepath.Join(dir, "config.json")); err != nil {
+			logger.Warnf("配置文件不存在: %v", err)
+			return exitConfig
+		}
+		err = service.Install(*name, exe, serviceArgs(*name, dir))
+		return serviceResult(err, "已安装服务 %s，配置目录: %s", *name, dir)
+	case "uninstall":
+		return serviceResult(service.Uninstall(*name), "已卸载服务 %s", *name)
+	case "start":
+		return serviceResult(service.Start(*name), "已启动服务 %s", *name)
+	case "stop":
+		return serviceResult(service.Stop(*name), "已停止服务 %s", *name)
+	case "run":
+		os.Setenv("BACKUP_CONFIG_DIR", *configDir)
+		// 服务没有控制台，日志写入配置目录并按默认设置轮转，配置了 log.file 时改为写入该文件
+		logging.SetFile(logging.FileOptions{Path: filepath.Join(*configDir, "neo-nas.log")})
+		if err := service.Run(*name, runDaemon); err != nil {
+			logger.Error(err.Error())
+			return exitFailed
+		}
+		return exitOK
+	default:
+		i18n.Fprintf(os.Stderr, "未知的服务操作: %s\n\n", positional[0])
+		fs.Usage()
+		return exitConfig
+	}
+}
+
+// serviceArgs 服务控制管理器启动程序时的参数
+func serviceArgs(name, configDir string) []string {
+	return []string{"service", "run", "--name", name, "--config-dir", configDir}
+}
+
+func serviceResult(err error, format string, args ...any) int {
+	if err != nil {
+		logger.Error(err.Error())
+		return exitFailed
+	}
+	fmt.Println(i18n.Sprintf(format, args...))
+	return exitOK
+}
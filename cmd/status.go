@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/cron"
+	"github.com/lucasrui/neo-nas/internal/history"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/instance"
+	"github.com/lucasrui/neo-nas/internal/status"
+	"github.com/lucasrui/neo-nas/internal/version"
+	"github.com/lucasrui/neo-nas/internal/zip"
+)
+
+// statusReport 汇总守护进程中各任务的状态，供状态接口输出
+// zipMgr 为空表示未启用压缩任务
+func statusReport(cfg *config.NeoConfig, wm *WatcherManager, zipMgr *zip.ZipManager, store *history.Store, started time.Time) status.Report {
+	report := status.Report{
+		Version:  version.Current(),
+		Build:    version.Info(),
+		Hostname: cfg.Hostname,
+		Started:  started,
+		Healthy:  true,
+	}
+	if cfg.UpdateCheck.Enabled {
+		if r, ok := version.Load(cfg.UpdateCheckFile); ok {
+			r.Newer = r.Newer && version.Newer(r.Latest, version.Current())
+			report.Update = &r
+		}
+	}
+
+	for i, task := range cfg.BackupConfigs {
+		b := status.Backup{ID: i + 1, Source: task.SourceDir, Target: task.TargetDir}
+		if w := wm.Get(task.SourceDir); w != nil {
+			st := w.Status()
+			b.Active = true
+			b.Online, b.BackingUp, b.SourceDown, b.Paused, b.Ejected = st.IsLastCheckExists, st.IsBackingUp, st.IsSourceDown, st.Paused, st.SafeToUnplug
+			b.Total, b.Success, b.Failed, b.Skipped = st.TotalFiles, st.SuccessFiles, st.FailedFiles, st.SkippedFiles
+			if !st.LastSync.IsZero() {
+				b.LastSync = &st.LastSync
+			}
+		}
+		if r, ok := store.Last(history.KindBackup, cfg.Hostname, task.SourceDir, task.TargetDir); ok {
+			b.LastRun = &r
+		}
+		if !b.Active || (b.LastRun != nil && b.LastRun.Result == history.ResultFailed) {
+			report.Healthy = false
+		}
+		report.Backups = append(report.Backups, b)
+	}
+
+	var running string
+	var progress zip.Progress
+	var inProgress bool
+	if zipMgr != nil {
+		running = zipMgr.Running()
+		progress, inProgress = zipMgr.Progress()
+	}
+	interval := time.Duration(cfg.ZipConfig.IntervalSeconds) * time.Second
+	for i, item := range cfg.ZipConfig.Items {
+		z := status.Zip{ID: i + 1, Source: item.Source, Target: item.Target, Running: running != "" && running == item.Target}
+		if z.Running && inProgress && progress.Target == item.Target {
+			z.Progress = &status.ZipProgress{File: progress.File, BytesDone: progress.Done, BytesTotal: progress.Total, Percent: progress.Percent(), Started: progress.Started}
+			if d, ok := progress.Remaining(); ok {
+				eta := time.Now().Add(d)
+				z.Progress.ETA = &eta
+			}
+		}
+		if r, ok := store.Last(history.KindZip, "", item.Source, item.Target); ok {
+			z.LastRun = &r
+			if interval > 0 && item.Cron == "" {
+				next := r.Start.Add(interval)
+				z.NextRun = &next
+			}
+			if r.Result == history.ResultFailed {
+				report.Healthy = false
+			}
+		}
+		if sched, err := cron.Parse(item.Cron); item.Cron != "" && err == nil {
+			if next := sched.Next(time.Now()); !next.IsZero() {
+				z.NextRun = &next
+			}
+		}
+		report.Zips = append(report.Zips, z)
+	}
+	return report
+}
+
+// runStatus 通过控制套接字查询正在运行的守护进程的状态，包括正在压缩的进度
+// 守护进程没有运行时退出码为 1，最近一次运行的结果可以用 list 查看
+func runStatus(args []string) int {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	jsonOut := fs.Bool("json", false, i18n.T("以 JSON 格式输出，与状态接口的内容相同"))
+	fs.Usage = func() {
+		i18n.Fprintf(os.Stderr, "用法: %s status [--json]\n\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitConfig
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logger.Errorf("加载配置失败: %v", err)
+		return exitConfig
+	}
+
+	lock, err := instance.Acquire(cfg.ConfigDir, cfg.Hostname)
+	if err == nil {
+		lock.Release()
+		i18n.Println("守护进程没有运行，可以用 list 查看最近一次运行的结果")
+		return exitFailed
+	}
+	if !errors.Is(err, instance.ErrRunning) {
+		logger.Error(err.Error())
+		return exitFailed
+	}
+	reply, err := instance.Send(cfg.ConfigDir, cfg.Hostname, "status")
+	if err != nil {
+		logger.Error(err.Error())
+		return exitFailed
+	}
+	var report status.Report
+	if err := json.Unmarshal([]byte(reply), &report); err != nil {
+		i18n.Printf("守护进程不支持查询状态，可能是旧版本: %s\n", reply)
+		return exitFailed
+	}
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+		return exitOK
+	}
+
+	health := i18n.T("正常")
+	if !report.Healthy {
+		health = i18n.T("有任务失败或未启动")
+	}
+	// 旧版本的守护进程没有返回构建信息
+	build := report.Version
+	if report.Build.Version != "" {
+		build = report.Build.String()
+	}
+	i18n.Printf("neo-nas %s，机器: %s，启动于 %s，状态: %s\n", build, report.Hostname, report.Started.Local().Format("2006-01-02 15:04:05"), health)
+	for _, b := range report.Backups {
+		state := i18n.T("等待源目录")
+		switch {
+		case !b.Active:
+			state = i18n.T("未启动")
+		case b.Paused:
+			state = i18n.T("已暂停")
+		case b.SourceDown:
+			state = i18n.T("源目录暂时不可用")
+		case b.BackingUp:
+			state = i18n.T("正在备份")
+		case b.Online:
+			state = i18n.T("在线")
+		case b.Ejected:
+			state = i18n.T("已弹出，可以拔出")
+		}
+		i18n.Printf("[备份 %d] %s -> %s: %s, 扫描: %d, 成功: %d, 失败: %d, 跳过: %d\n", b.ID, b.Source, b.Target, state, b.Total, b.Success, b.Failed, b.Skipped)
+	}
+	for _, z := range report.Zips {
+		line := i18n.Sprintf("[压缩 %d] %s -> %s: ", z.ID, z.Source, z.Target)
+		switch p := z.Progress; {
+		case p != nil:
+			line += i18n.Sprintf("正在压缩 %d%% (%d / %d 字节)", p.Percent, p.BytesDone, p.BytesTotal)
+			if p.File != "" {
+				line += i18n.T(", 当前文件: ") + p.File
+			} else if p.BytesDone >= p.BytesTotal {
+				line += i18n.T(", 正在校验")
+			}
+			if p.ETA != nil {
+				line += i18n.T(", 预计完成: ") + p.ETA.Local().Format("2006-01-02 15:04:05")
+			}
+		case z.Running:
+			line += i18n.T("正在压缩")
+		case z.NextRun != nil:
+			line += i18n.T("下次运行: ") + z.NextRun.Local().Format("2006-01-02 15:04:05")
+		default:
+			line += i18n.T("等待运行")
+		}
+		fmt.Println(line)
+	}
+	return exitOK
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/cron"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/zip"
+)
+
+// runValidate 检查配置文件，列出所有发现的问题及其 JSON 路径，不启动任何任务
+// 有错误时退出码为 1，只有警告时为 0，配置文件无法读取时为 2
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	jsonOut := fs.Bool("json", false, i18n.T("以 JSON 格式输出"))
+	fs.Usage = func() {
+		i18n.Fprintf(os.Stderr, "用法: %s validate [--json]\n\n", filepath.Base(os.Args[0]))
+		i18n.Fprintln(os.Stderr, "检查配置目录中的 config.json，列出语法错误、未知的字段、空路径、不存在的目录、互相包含的源和目标、重复的任务等问题")
+		i18n.Fprintln(os.Stderr, "\n选项:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitConfig
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return exitConfig
+	}
+
+	file := filepath.Join(config.Dir(), "config.json")
+	data, err := os.ReadFile(file)
+	if err != nil {
+		i18n.Fprintf(os.Stderr, "读取配置文件失败: %v\n", err)
+		return exitConfig
+	}
+	problems := validate(data)
+
+	errs, warns := 0, 0
+	for _, p := range problems {
+		if p.Warning {
+			warns++
+		} else {
+			errs++
+		}
+	}
+	if *jsonOut {
+		if problems == nil {
+			problems = []config.Problem{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(struct {
+			File     string           `json:"file"`
+			Valid    bool             `json:"valid"`
+			Problems []config.Problem `json:"problems"`
+		}{file, errs == 0, problems})
+	} else {
+		i18n.Printf("配置文件: %s\n", file)
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+		if len(problems) == 0 {
+			i18n.Println("没有发现问题")
+		} else {
+			i18n.Printf("共 %d 个错误，%d 个警告\n", errs, warns)
+		}
+	}
+	if errs > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
+
+// validate 依次检查语法和字段、加载配置时的检查、各任务的配置，以及压缩格式和 cron 表达式
+func validate(data []byte) []config.Problem {
+	problems, ok := config.ParseProblems(data)
+	if !ok {
+		// 无法解析时后面的检查没有意义
+		return problems
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return append(problems, config.Problem{Message: err.Error()})
+	}
+	problems = append(problems, cfg.Validate()...)
+	for i, item := range cfg.ZipConfig.Items {
+		path := fmt.Sprintf("zip_config.items[%d]", i)
+		if item.Cron != "" {
+			if _, err := cron.Parse(item.Cron); err != nil {
+				problems = append(problems, config.Problem{Path: path + ".cron", Message: err.Error()})
+			}
+		}
+		if err := zip.Check(item); err != nil {
+			problems = append(problems, config.Problem{Path: path, Message: err.Error()})
+		}
+	}
+	return problems
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lucasrui/neo-nas/internal/backup"
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/verify"
+)
+
+// kindLabels 差异类型的显示名称
+var kindLabels = map[string]string{
+	verify.Missing: "缺失",
+	verify.Size:    "大小不同",
+	verify.ModTime: "时间不同",
+	verify.Content: "内容不同",
+	verify.Error:   "错误",
+}
+
+// runVerify 比较备份任务的源目录和目标目录，输出差异，有差异时退出码为 1
+// 用法: verify [任务...] [--hash] [--report <文件>]
+func runVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	hash := fs.Bool("hash", false, i18n.T("比较文件内容，默认使用任务配置的 verify 方式"))
+	report := fs.String("report", "", i18n.T("把差异报告以 JSON 格式写入文件，校验多个任务时文件名前依次加上序号"))
+	extra := fs.Bool("extra", false, i18n.T("同时列出只存在于目标目录中的文件"))
+	host := fs.String("host", "", i18n.T("开启 host_namespace 时校验哪台机器的备份，默认本机"))
+	fs.Usage = func() {
+		i18n.Fprintf(os.Stderr, "用法: %s verify [任务序号或目录...] [选项]\n\n", filepath.Base(os.Args[0]))
+		i18n.Fprintln(os.Stderr, "不指定任务时校验所有本地备份任务")
+		i18n.Fprintln(os.Stderr, "\n选项:")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterleaved(fs, args)
+	if err != nil {
+		return exitConfig
+	}
+
+	a, err := loadApp()
+	if err != nil {
+		logger.Errorf("程序已停止，%v", err)
+		return exitConfig
+	}
+	defer a.catalog.Close()
+	defer a.opts.SMB.UnmountAll()
+	if *host == "" {
+		*host = a.cfg.Hostname
+	}
+
+	var tasks []config.Config
+	for _, arg := range positional {
+		task, err := findTask(a.cfg, arg)
+		if err != nil {
+			logger.Error(err.Error())
+			return exitConfig
+		}
+		tasks = append(tasks, task)
+	}
+	if len(positional) == 0 {
+		for _, task := range a.cfg.BackupConfigs {
+			if task.IsLocalTarget() || task.IsSMBTarget() {
+				tasks = append(tasks, task)
+			}
+		}
+	}
+
+	code := exitOK
+	for i, task := range tasks {
+		if task.IsAgentTarget() {
+			logger.Warnf("推送到服务端的任务需要在服务端校验: %s", task.TargetDir)
+			return exitConfig
+		}
+		if task.IsStoreTarget() {
+			logger.Warnf("暂不支持校验 S3 或 rclone 目标: %s", task.TargetDir)
+			return exitConfig
+		}
+		if err := a.mountTarget(&task); err != nil {
+			i18n.Printf("[失败] %s -> %s, 错误: %v\n", task.SourceDir, task.TargetDir, err)
+			code = exitFailed
+			continue
+		}
+		// 按卷指定的源目录与卷当前的挂载点比较
+		source, err := task.ResolveSource()
+		if err != nil {
+			i18n.Printf("[失败] %s -> %s, 错误: %v\n", task.SourceDir, task.TargetDir, err)
+			code = exitFailed
+			continue
+		}
+		task.SourceDir = source
+		target := task.TargetDir
+		if task.HostNamespace {
+			target = filepath.Join(target, *host)
+		}
+		mode := task.Verify
+		if *hash {
+			mode = verify.ModeHash
+		}
+
+		logger.Infof("开始校验: %s -> %s", task.SourceDir, target)
+		// 与备份一致，默认不比较 macOS 系统元数据，同样按 include 和 exclude 规则跳过
+		filter, err := backup.NewFilter(task)
+		if err != nil {
+			i18n.Printf("[失败] %s -> %s, 错误: %v\n", task.SourceDir, target, err)
+			code = exitFailed
+			continue
+		}
+		skip := func(p string) bool {
+			if filter.Excluded(p) {
+				return true
+			}
+			info, err := os.Stat(p)
+			return err == nil && !info.IsDir() && !filter.Included(p)
+		}
+		r, err := verify.Run(task.SourceDir, target, mode, skip)
+		if err != nil {
+			i18n.Printf("[失败] %s -> %s, 错误: %v\n", task.SourceDir, target, err)
+			code = exitFailed
+			continue
+		}
+		for _, d := range r.Discrepancies {
+			line := fmt.Sprintf("[%s] %s", i18n.T(kindLabels[d.Kind]), d.Path)
+			if d.Detail != "" {
+				line += ", " + d.Detail
+			}
+			fmt.Println(line)
+		}
+		if *extra {
+			for _, p := range r.Extra {
+				i18n.Printf("[仅在目标中] %s\n", p)
+			}
+		}
+		i18n.Printf("校验完成: %s -> %s, 方式: %s, 文件: %d, 差异: %d, 仅在目标中: %d\n", r.Source, r.Target, r.Mode, r.Checked, len(r.Discrepancies), len(r.Extra))
+		if !r.OK() {
+			code = exitFailed
+		}
+
+		if *report != "" {
+			file := *report
+			if len(tasks) > 1 {
+				file = filepath.Join(filepath.Dir(file), fmt.Sprintf("%d-%s", i+1, filepath.Base(file)))
+			}
+			if err := r.Save(file); err != nil {
+				logger.Error(err.Error())
+				code = exitFailed
+			}
+		}
+	}
+	if len(tasks) == 0 {
+		i18n.Println("没有可以校验的备份任务")
+	}
+	return code
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/version"
+)
+
+// runVersion 输出当前版本，--check 时查询是否有新版本，查询失败时退出码为 1
+// 用法: version [--check] [--json]
+func runVersion(args []string) int {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	check := fs.Bool("check", false, i18n.T("查询是否有新版本"))
+	jsonOut := fs.Bool("json", false, i18n.T("以 JSON 格式输出"))
+	fs.Usage = func() {
+		i18n.Fprintf(os.Stderr, "用法: %s version [--check] [--json]\n\n", filepath.Base(os.Args[0]))
+		i18n.Fprintln(os.Stderr, "查询时使用配置文件中的 update_check.feed 和代理设置，没有配置文件时使用默认值")
+		i18n.Fprintln(os.Stderr, "\n选项:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitConfig
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return exitConfig
+	}
+
+	build := version.Info()
+	if !*check {
+		if *jsonOut {
+			json.NewEncoder(os.Stdout).Encode(version.Result{Current: version.Current(), Build: &build})
+		} else {
+			fmt.Printf("neo-nas %s\n", build)
+		}
+		return exitOK
+	}
+
+	var checkCfg config.UpdateCheckConfig
+	resultFile := ""
+	if cfg, err := config.LoadConfig(); err == nil {
+		checkCfg, resultFile = cfg.UpdateCheck, cfg.UpdateCheckFile
+	}
+	r := version.Check(checkCfg)
+	if resultFile != "" {
+		if err := r.Save(resultFile); err != nil {
+			logger.Errorf("保存版本检查结果失败: %v", err)
+		}
+	}
+	r.Build = &build
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(r)
+	} else {
+		i18n.Printf("当前版本: %s\n", build)
+		switch {
+		case r.Error != "":
+			i18n.Printf("检查失败: %s\n", r.Error)
+		case r.Newer:
+			i18n.Printf("有新版本: %s %s\n", r.Latest, r.URL)
+		default:
+			i18n.Printf("最新版本: %s，已是最新\n", r.Latest)
+		}
+	}
+	if r.Error != "" {
+		return exitFailed
+	}
+	return exitOK
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/dryrun"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/instance"
+	"github.com/lucasrui/neo-nas/internal/zip"
+)
+
+// 控制套接字上 zip 命令的回复
+const (
+	zipReplyOK       = "ok"
+	zipReplyBusy     = "busy"
+	zipReplyNotFound = "notfound"
+)
+
+// runZip 立即执行指定的压缩任务，不管是否到期；守护进程正在运行时交给守护进程在后台执行，否则在前台执行并等待完成
+// 用法: zip <任务...>
+func runZip(args []string) int {
+	fs := flag.NewFlagSet("zip", flag.ContinueOnError)
+	fs.Usage = func() {
+		i18n.Fprintf(os.Stderr, "用法: %s zip <压缩任务序号或目标路径...>\n\n", filepath.Base(os.Args[0]))
+		i18n.Fprintln(os.Stderr, "立即执行压缩任务，不管是否到期；本机有守护进程运行时由守护进程执行，结果见 list 或日志")
+	}
+	positional, err := parseInterleaved(fs, args)
+	if err != nil {
+		return exitConfig
+	}
+	if len(positional) == 0 {
+		fs.Usage()
+		return exitConfig
+	}
+
+	a, err := loadApp()
+	if err != nil {
+		logger.Errorf("程序已停止，%v", err)
+		return exitConfig
+	}
+	defer a.catalog.Close()
+	defer a.opts.SMB.UnmountAll()
+	var items []config.ZipItem
+	for _, arg := range positional {
+		item, err := findZip(a.cfg, arg)
+		if err != nil {
+			logger.Error(err.Error())
+			return exitConfig
+		}
+		items = append(items, item)
+	}
+
+	// 演练模式不交给守护进程，在前台只记录将要执行的操作
+	if !dryrun.Enabled() {
+		lock, err := instance.Acquire(a.cfg.ConfigDir, a.cfg.Hostname)
+		if errors.Is(err, instance.ErrRunning) {
+			return triggerZip(a.cfg, items)
+		}
+		if err != nil {
+			logger.Errorf("程序已停止，%v", err)
+			return exitConfig
+		}
+		defer lock.Release()
+	}
+
+	z := zip.NewZipManager(a.cfg.ZipConfig, a.remoteOpts, a.opts.History, a.cfg.ZipStateFile)
+	code := exitOK
+	for _, item := range items {
+		if err := z.Zip(item); err != nil {
+			i18n.Printf("[失败] 压缩 %s -> %s, 错误: %v\n", item.Source, item.Target, err)
+			code = exitFailed
+			continue
+		}
+		i18n.Printf("[成功] 压缩 %s -> %s\n", item.Source, item.Target)
+	}
+	return code
+}
+
+// triggerZip 请求正在运行的守护进程执行压缩任务，守护进程同一时间只执行一个压缩任务
+func triggerZip(cfg *config.NeoConfig, items []config.ZipItem) int {
+	code := exitOK
+	for _, item := range items {
+		reply, err := instance.Send(cfg.ConfigDir, cfg.Hostname, "zip "+item.Target)
+		switch {
+		case err != nil:
+			logger.Error(err.Error())
+			return exitFailed
+		case reply == zipReplyOK:
+			i18n.Printf("已请求守护进程立即执行压缩任务: %s -> %s\n", item.Source, item.Target)
+		case reply == zipReplyBusy:
+			i18n.Printf("守护进程正在执行其他压缩任务，请稍后再试: %s -> %s\n", item.Source, item.Target)
+			code = exitFailed
+		case reply == zipReplyNotFound:
+			i18n.Printf("守护进程的配置中没有这个压缩任务，修改配置后需要先重新加载: %s\n", item.Target)
+			code = exitFailed
+		default:
+			i18n.Printf("守护进程不支持立即执行压缩任务，可能是旧版本: %s\n", reply)
+			return exitFailed
+		}
+	}
+	return code
+}
+
+// findZip 按序号（与 list 中的顺序一致）、目标路径或源路径查找压缩任务
+func findZip(cfg *config.NeoConfig, arg string) (config.ZipItem, error) {
+	items := cfg.ZipConfig.Items
+	if n, err := strconv.Atoi(arg); err == nil {
+		if n < 1 || n > len(items) {
+			return config.ZipItem{}, i18n.Errorf("压缩任务序号超出范围: %d，共 %d 个压缩任务", n, len(items))
+		}
+		return items[n-1], nil
+	}
+	for _, item := range items {
+		if item.Target == arg || filepath.Clean(item.Target) == filepath.Clean(arg) {
+			return item, nil
+		}
+	}
+	for _, item := range items {
+		if filepath.Clean(item.Source) == filepath.Clean(arg) {
+			return item, nil
+		}
+	}
+	return config.ZipItem{}, i18n.Errorf("未找到压缩任务: %s", arg)
+}
@@ -0,0 +1,213 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+	"github.com/lucasrui/neo-nas/internal/proxy"
+	"github.com/lucasrui/neo-nas/internal/server"
+	"github.com/lucasrui/neo-nas/internal/throttle"
+)
+
+// logger agent 模块的日志
+var logger = logging.New("agent")
+
+// Client 客户端模式下将文件推送到服务端
+type Client struct {
+	serverURL string
+	token     string
+	http      *http.Client
+	resumable int64 // 超过该大小的文件使用可续传上传
+	chunkSize int64
+	deltaMin  int64 // 超过该大小的已有文件使用增量上传，小于 0 表示关闭
+	limiter   *throttle.Limiter
+	state     *uploadState
+}
+
+// NewClient 创建客户端，stateFile 用于保存未完成的可续传上传，以便重启后继续
+// limiter 限制上传带宽，为 nil 时不限速
+func NewClient(cfg config.AgentConfig, stateFile string, limiter *throttle.Limiter) (*Client, error) {
+	if cfg.ServerURL == "" || cfg.Token == "" {
+		return nil, i18n.Errorf("客户端模式需要配置服务端地址和令牌")
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, i18n.Errorf("读取 CA 文件失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, i18n.Errorf("CA 文件无效: %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	proxyFunc, err := proxy.Func(cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	state, err := loadUploadState(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		serverURL: strings.TrimRight(cfg.ServerURL, "/"),
+		token:     cfg.Token,
+		http: &http.Client{
+			Transport: &http.Transport{
+				Proxy:           proxyFunc,
+				TLSClientConfig: tlsConfig,
+			},
+		},
+		resumable: int64(cfg.ResumableMB) << 20,
+		chunkSize: int64(cfg.ChunkMB) << 20,
+		deltaMin:  int64(cfg.DeltaMB) << 20,
+		limiter:   limiter,
+		state:     state,
+	}
+	if c.resumable <= 0 {
+		c.resumable = 64 << 20
+	}
+	if c.chunkSize <= 0 {
+		c.chunkSize = 8 << 20
+	}
+	if c.deltaMin == 0 {
+		c.deltaMin = 16 << 20
+	}
+	return c, nil
+}
+
+func (c *Client) fileURL(namespace, relPath string) string {
+	return c.apiURL(server.FilesPath, namespace, relPath)
+}
+
+func (c *Client) apiURL(prefix, namespace, relPath string) string {
+	return c.serverURL + prefix + url.PathEscape(namespace) + "/" + escapePath(relPath)
+}
+
+// Stat 查询服务端上的文件，不存在时返回 nil
+func (c *Client) Stat(namespace, relPath string) (*server.FileInfo, error) {
+	req, err := http.NewRequest(http.MethodGet, c.fileURL(namespace, relPath), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, i18n.Errorf("查询服务端文件失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var fi server.FileInfo
+		if err := json.NewDecoder(resp.Body).Decode(&fi); err != nil {
+			return nil, i18n.Errorf("解析服务端响应失败: %w", err)
+		}
+		return &fi, nil
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, responseError(resp)
+	}
+}
+
+// Upload 上传文件，大文件使用可续传上传，其余文件一次性上传
+func (c *Client) Upload(namespace, relPath, sourcePath string) (*server.FileInfo, error) {
+	f, err := os.Open(sourcePath)
+	if err != nil {
+		return nil, i18n.Errorf("打开源文件失败: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, i18n.Errorf("获取源文件信息失败: %w", err)
+	}
+	if info.Size() >= c.resumable {
+		return c.uploadResumable(namespace, relPath, sourcePath, f, info)
+	}
+	return c.uploadWhole(namespace, relPath, sourcePath, f, info)
+}
+
+// uploadWhole 内容哈希在上传过程中计算并作为 trailer 发送，服务端校验通过后才会落盘
+func (c *Client) uploadWhole(namespace, relPath, sourcePath string, f *os.File, info os.FileInfo) (*server.FileInfo, error) {
+	body := &hashReader{r: c.limiter.Reader(f), hash: sha256.New()}
+	req, err := http.NewRequest(http.MethodPut, c.fileURL(namespace, relPath), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set(server.HeaderModTime, info.ModTime().Format(time.RFC3339Nano))
+	req.Header.Set(server.HeaderMode, strconv.FormatUint(uint64(info.Mode().Perm()), 8))
+	req.Header.Set(server.HeaderSource, sourcePath)
+	req.Trailer = http.Header{server.HeaderSHA256: nil}
+	// trailer 需要分块传输，因此不设置 ContentLength
+	req.ContentLength = -1
+	body.onEOF = func(sum string) { req.Trailer.Set(server.HeaderSHA256, sum) }
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, i18n.Errorf("上传文件失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return nil, responseError(resp)
+	}
+
+	var fi server.FileInfo
+	if err := json.NewDecoder(resp.Body).Decode(&fi); err != nil {
+		return nil, i18n.Errorf("解析服务端响应失败: %w", err)
+	}
+	if fi.SHA256 != body.sum {
+		return nil, i18n.Errorf("服务端返回的哈希不匹配: %s", fi.SHA256)
+	}
+	return &fi, nil
+}
+
+// hashReader 读取时同步计算哈希，读到末尾时回调
+type hashReader struct {
+	r     io.Reader
+	hash  hash.Hash
+	sum   string
+	onEOF func(sum string)
+}
+
+func (h *hashReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	h.hash.Write(p[:n])
+	if err == io.EOF && h.sum == "" {
+		h.sum = hex.EncodeToString(h.hash.Sum(nil))
+		if h.onEOF != nil {
+			h.onEOF(h.sum)
+		}
+	}
+	return n, err
+}
+
+func escapePath(relPath string) string {
+	parts := strings.Split(path.Clean(strings.ReplaceAll(relPath, `\`, "/")), "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+func responseError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return i18n.Errorf("服务端返回 %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+}
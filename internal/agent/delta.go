@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/delta"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/server"
+)
+
+// ErrBaseChanged 服务端的已有文件在增量上传过程中被改动或删除，需要改为完整上传
+var ErrBaseChanged = i18n.New("服务端已有文件已变化")
+
+// UseDelta 判断服务端已有旧版本时是否值得使用增量上传
+func (c *Client) UseDelta(size int64) bool {
+	return c.deltaMin >= 0 && size >= c.deltaMin
+}
+
+// UploadDelta 获取服务端已有文件的块签名，只上传变化的数据
+func (c *Client) UploadDelta(namespace, relPath, sourcePath string) (*server.FileInfo, delta.Stats, error) {
+	var stats delta.Stats
+	sig, base, err := c.signature(namespace, relPath)
+	if err != nil {
+		return nil, stats, err
+	}
+
+	f, err := os.Open(sourcePath)
+	if err != nil {
+		return nil, stats, i18n.Errorf("打开源文件失败: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, stats, i18n.Errorf("获取源文件信息失败: %w", err)
+	}
+
+	// 增量数据边读源文件边生成，哈希在源文件读完时作为 trailer 发送
+	src := &hashReader{r: f, hash: sha256.New()}
+	pr, pw := io.Pipe()
+	// 源文件中未变化的块只发送引用，限速作用于实际发送的增量数据
+	req, err := http.NewRequest(http.MethodPut, c.apiURL(server.DeltasPath, namespace, relPath), c.limiter.Reader(pr))
+	if err != nil {
+		return nil, stats, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set(server.HeaderModTime, info.ModTime().Format(time.RFC3339Nano))
+	req.Header.Set(server.HeaderMode, strconv.FormatUint(uint64(info.Mode().Perm()), 8))
+	req.Header.Set(server.HeaderSource, sourcePath)
+	req.Header.Set(server.HeaderBase, base)
+	req.Trailer = http.Header{server.HeaderSHA256: nil}
+	req.ContentLength = -1
+	src.onEOF = func(sum string) { req.Trailer.Set(server.HeaderSHA256, sum) }
+
+	diffDone := make(chan error, 1)
+	go func() {
+		var err error
+		stats, err = delta.Diff(sig, src, pw)
+		pw.CloseWithError(err)
+		diffDone <- err
+	}()
+
+	resp, err := c.http.Do(req)
+	pr.Close()
+	diffErr := <-diffDone
+	if err != nil {
+		return nil, stats, i18n.Errorf("增量上传失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return nil, stats, ErrBaseChanged
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return nil, stats, responseError(resp)
+	}
+	if diffErr != nil {
+		return nil, stats, diffErr
+	}
+
+	var fi server.FileInfo
+	if err := json.NewDecoder(resp.Body).Decode(&fi); err != nil {
+		return nil, stats, i18n.Errorf("解析服务端响应失败: %w", err)
+	}
+	if fi.SHA256 != src.sum {
+		return nil, stats, i18n.Errorf("服务端返回的哈希不匹配: %s", fi.SHA256)
+	}
+	return &fi, stats, nil
+}
+
+// signature 获取服务端已有文件的块签名及其版本
+func (c *Client) signature(namespace, relPath string) (*delta.Signature, string, error) {
+	req, err := http.NewRequest(http.MethodGet, c.apiURL(server.SignaturesPath, namespace, relPath), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, "", i18n.Errorf("获取文件签名失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, "", ErrBaseChanged
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", responseError(resp)
+	}
+	sig, err := delta.ReadSignature(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return sig, resp.Header.Get(server.HeaderBase), nil
+}
@@ -0,0 +1,216 @@
+package agent
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/catalog"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/server"
+)
+
+// 单个分块的最大重试次数
+const maxChunkRetries = 5
+
+// pendingUpload 未完成的可续传上传，源文件未变化时可以继续使用
+type pendingUpload struct {
+	ID      string    `json:"id"`
+	Target  string    `json:"target"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	SHA256  string    `json:"sha256"`
+}
+
+// uploadState 以源文件路径为键保存未完成的上传
+type uploadState struct {
+	file    string
+	mu      sync.Mutex
+	Uploads map[string]pendingUpload `json:"uploads"`
+}
+
+func loadUploadState(file string) (*uploadState, error) {
+	s := &uploadState{file: file, Uploads: make(map[string]pendingUpload)}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, i18n.Errorf("读取上传状态失败: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		logger.Warnf("上传状态文件损坏，已忽略: %v", err)
+		s.Uploads = make(map[string]pendingUpload)
+	}
+	return s, nil
+}
+
+func (s *uploadState) get(source string) (pendingUpload, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.Uploads[source]
+	return p, ok
+}
+
+func (s *uploadState) set(source string, p *pendingUpload) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p == nil {
+		delete(s.Uploads, source)
+	} else {
+		s.Uploads[source] = *p
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err == nil {
+		err = os.WriteFile(s.file, data, 0644)
+	}
+	if err != nil {
+		logger.Errorf("保存上传状态失败: %v", err)
+	}
+}
+
+// uploadResumable 分块上传，每个分块失败后按退避时间重试，进度由服务端记录
+// 上传会话保存在本地状态文件中，程序重启或网络恢复后从服务端已接收的位置继续
+func (c *Client) uploadResumable(namespace, relPath, sourcePath string, f *os.File, info os.FileInfo) (*server.FileInfo, error) {
+	target := namespace + "/" + path.Clean(strings.ReplaceAll(relPath, `\`, "/"))
+
+	var offset int64 = -1
+	pending, ok := c.state.get(sourcePath)
+	if ok && pending.Target == target && pending.Size == info.Size() && pending.ModTime.Equal(info.ModTime()) {
+		if o, err := c.queryOffset(pending.ID); err == nil {
+			offset = o
+			logger.Infof("继续上传: %s, 已完成 %d/%d", sourcePath, offset, pending.Size)
+		}
+	}
+	if offset < 0 {
+		sum, err := catalog.HashFile(sourcePath)
+		if err != nil {
+			return nil, i18n.Errorf("计算文件哈希失败: %w", err)
+		}
+		id, err := c.createUpload(target, sourcePath, info, sum)
+		if err != nil {
+			return nil, err
+		}
+		pending = pendingUpload{ID: id, Target: target, Size: info.Size(), ModTime: info.ModTime(), SHA256: sum}
+		c.state.set(sourcePath, &pending)
+		offset = 0
+	}
+
+	retries := 0
+	for {
+		fi, next, err := c.sendChunk(pending.ID, f, offset, pending.Size)
+		if err == nil {
+			retries = 0
+			offset = next
+			if fi != nil {
+				c.state.set(sourcePath, nil)
+				if fi.SHA256 != pending.SHA256 {
+					return nil, i18n.Errorf("服务端返回的哈希不匹配: %s", fi.SHA256)
+				}
+				return fi, nil
+			}
+			continue
+		}
+
+		retries++
+		if retries > maxChunkRetries {
+			return nil, i18n.Errorf("分块上传多次失败，下次扫描时继续: %w", err)
+		}
+		wait := time.Duration(1<<uint(retries-1)) * time.Second
+		logger.Warnf("分块上传失败 %s: %v, %s 后重试", sourcePath, err, wait)
+		time.Sleep(wait)
+		// 以服务端记录的偏移量为准
+		if o, qerr := c.queryOffset(pending.ID); qerr == nil {
+			offset = o
+		}
+	}
+}
+
+func (c *Client) createUpload(target, sourcePath string, info os.FileInfo, sum string) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, c.serverURL+server.UploadsPath, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set(server.HeaderTarget, target)
+	req.Header.Set(server.HeaderUploadLength, strconv.FormatInt(info.Size(), 10))
+	req.Header.Set(server.HeaderModTime, info.ModTime().Format(time.RFC3339Nano))
+	req.Header.Set(server.HeaderMode, strconv.FormatUint(uint64(info.Mode().Perm()), 8))
+	req.Header.Set(server.HeaderSHA256, sum)
+	req.Header.Set(server.HeaderSource, sourcePath)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", i18n.Errorf("创建上传会话失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", responseError(resp)
+	}
+	var session server.UploadSession
+	if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
+		return "", i18n.Errorf("解析服务端响应失败: %w", err)
+	}
+	return session.ID, nil
+}
+
+// queryOffset 查询服务端已接收的字节数，会话不存在时返回错误
+func (c *Client) queryOffset(id string) (int64, error) {
+	req, err := http.NewRequest(http.MethodHead, c.serverURL+server.UploadsPath+id, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, i18n.Errorf("查询上传进度失败: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, i18n.Errorf("上传会话不存在: %s", resp.Status)
+	}
+	return strconv.ParseInt(resp.Header.Get(server.HeaderUploadOffset), 10, 64)
+}
+
+// sendChunk 从 offset 发送一个分块，上传完成时返回服务端的文件信息
+func (c *Client) sendChunk(id string, f *os.File, offset, size int64) (*server.FileInfo, int64, error) {
+	n := c.chunkSize
+	if size-offset < n {
+		n = size - offset
+	}
+	req, err := http.NewRequest(http.MethodPatch, c.serverURL+server.UploadsPath+id, c.limiter.Reader(io.NewSectionReader(f, offset, n)))
+	if err != nil {
+		return nil, offset, err
+	}
+	req.ContentLength = n
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set(server.HeaderUploadOffset, strconv.FormatInt(offset, 10))
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, offset, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		next, err := strconv.ParseInt(resp.Header.Get(server.HeaderUploadOffset), 10, 64)
+		if err != nil {
+			return nil, offset, i18n.Errorf("服务端未返回偏移量")
+		}
+		return nil, next, nil
+	case http.StatusCreated:
+		var fi server.FileInfo
+		if err := json.NewDecoder(resp.Body).Decode(&fi); err != nil {
+			return nil, offset, i18n.Errorf("解析服务端响应失败: %w", err)
+		}
+		return &fi, size, nil
+	default:
+		return nil, offset, responseError(resp)
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/httpd"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+	"github.com/lucasrui/neo-nas/internal/status"
+)
+
+// logger api 模块的日志
+var logger = logging.New("api")
+
+// Prefix 管理接口的路径前缀
+const Prefix = "/api/"
+
+var (
+	// ErrNotFound 任务不存在或未启动
+	ErrNotFound = i18n.New("任务不存在")
+	// ErrBusy 已有压缩任务正在执行
+	ErrBusy = i18n.New("已有压缩任务正在执行")
+)
+
+// Controller 守护进程提供给管理接口的操作，任务序号从 1 开始，与配置中的顺序相同
+type Controller interface {
+	Status() status.Report
+	Rescan(id int) error
+	Pause(id int) error
+	Resume(id int) error
+	RunZip(id int) error
+	Reload() error
+}
+
+// Handler 管理接口：
+//
+//	GET  /api/tasks                 所有任务的状态，与状态接口相同
+//	POST /api/backups/<序号>/rescan 立即扫描
+//	POST /api/backups/<序号>/pause  暂停
+//	POST /api/backups/<序号>/resume 恢复
+//	POST /api/zips/<序号>/run       立即执行压缩任务
+//	POST /api/reload                重新加载配置文件
+type Handler struct {
+	ctl   Controller
+	token string
+}
+
+func NewHandler(ctl Controller, token string) *Handler {
+	return &Handler{ctl: ctl, token: token}
+}
+
+// Register 在内置 HTTP 服务上注册管理接口
+func (h *Handler) Register(s *httpd.Server) {
+	s.Handle(Prefix, h)
+	logger.Infof("管理接口已启用: %s", Prefix)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="neo-nas"`)
+		writeError(w, http.StatusUnauthorized, i18n.T("未授权"))
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, Prefix), "/"), "/")
+	if len(parts) == 1 && parts[0] == "tasks" {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, i18n.T("只支持 GET"))
+			return
+		}
+		writeJSON(w, http.StatusOK, h.ctl.Status())
+		return
+	}
+	if len(parts) == 1 && parts[0] == "reload" {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, i18n.T("只支持 POST"))
+			return
+		}
+		logger.Infof("管理接口: %s %s", r.Method, r.URL.Path)
+		if err := h.ctl.Reload(); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"result": "reloaded"})
+		return
+	}
+	if len(parts) != 3 {
+		writeError(w, http.StatusNotFound, i18n.T("未知的接口"))
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, i18n.T("只支持 POST"))
+		return
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		writeError(w, http.StatusNotFound, i18n.T("任务序号无效"))
+		return
+	}
+
+	var action func(int) error
+	switch parts[0] + "/" + parts[2] {
+	case "backups/rescan":
+		action = h.ctl.Rescan
+	case "backups/pause":
+		action = h.ctl.Pause
+	case "backups/resume":
+		action = h.ctl.Resume
+	case "zips/run":
+		action = h.ctl.RunZip
+	default:
+		writeError(w, http.StatusNotFound, i18n.T("未知的接口"))
+		return
+	}
+	switch err := action(id); {
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrBusy):
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		logger.Infof("管理接口: %s %s", r.Method, r.URL.Path)
+		writeJSON(w, http.StatusAccepted, map[string]string{"result": "accepted"})
+	}
+}
+
+// authorized 检查 Authorization: Bearer <令牌>
+func (h *Handler) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && h.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
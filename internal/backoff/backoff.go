@@ -0,0 +1,113 @@
+package backoff
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+)
+
+// logger backoff 模块的日志
+var logger = logging.New("backoff")
+
+// 条件不满足时的处理方式
+const (
+	ActionPause = "pause" // 暂停，条件恢复后继续（默认）
+	ActionSlow  = "slow"  // 每个文件之间等待一段时间
+)
+
+const (
+	checkInterval = 10 * time.Second       // 负载和电源状态的检查间隔
+	slowDelay     = 500 * time.Millisecond // 放慢时每个文件之间的等待时间
+)
+
+var (
+	mu       sync.Mutex
+	settings config.BackoffConfig
+	checked  time.Time
+	reason   string // 当前需要退让的原因，为空表示正常运行
+	errOnce  sync.Once
+)
+
+// Configure 校验并保存配置，max_load 和 on_battery 都未设置时不做任何检查
+func Configure(cfg config.BackoffConfig) error {
+	if cfg.MaxLoad < 0 {
+		return i18n.Errorf("max_load 不能小于 0: %v", cfg.MaxLoad)
+	}
+	switch cfg.Action {
+	case "":
+		cfg.Action = ActionPause
+	case ActionPause, ActionSlow:
+	default:
+		return i18n.Errorf("未知的处理方式: %s", cfg.Action)
+	}
+	mu.Lock()
+	settings, checked, reason = cfg, time.Time{}, ""
+	mu.Unlock()
+	return nil
+}
+
+// Wait 在复制每个文件之前调用，系统负载过高或使用电池供电时暂停或放慢，stop 关闭时立即返回
+func Wait(stop <-chan struct{}) {
+	for {
+		r, action := state()
+		if r == "" {
+			return
+		}
+		delay := checkInterval
+		if action == ActionSlow {
+			delay = slowDelay
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+		if action == ActionSlow {
+			return
+		}
+	}
+}
+
+// state 返回需要退让的原因，每 checkInterval 重新检查一次
+func state() (string, string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if settings.MaxLoad == 0 && !settings.OnBattery {
+		return "", ""
+	}
+	if time.Since(checked) < checkInterval {
+		return reason, settings.Action
+	}
+	checked = time.Now()
+
+	r := ""
+	if settings.MaxLoad > 0 {
+		if load, err := loadAverage(); err != nil {
+			errOnce.Do(func() { logger.Warnf("无法读取系统负载: %v", err) })
+		} else if load > settings.MaxLoad {
+			r = i18n.Sprintf("系统负载 %.2f 超过 %.2f", load, settings.MaxLoad)
+		}
+	}
+	if r == "" && settings.OnBattery {
+		if battery, err := onBattery(); err != nil {
+			errOnce.Do(func() { logger.Warnf("无法读取电源状态: %v", err) })
+		} else if battery {
+			r = i18n.T("正在使用电池供电")
+		}
+	}
+
+	verb := i18n.T("暂停备份")
+	if settings.Action == ActionSlow {
+		verb = i18n.T("放慢备份")
+	}
+	if r != "" && reason == "" {
+		logger.Warnf("%s，%s", r, verb)
+	} else if r == "" && reason != "" {
+		logger.Infof("系统负载和电源状态已恢复，继续备份")
+	}
+	reason = r
+	return reason, settings.Action
+}
@@ -0,0 +1,31 @@
+package backoff
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// loadAverage 读取 1 分钟平均负载，sysctl 输出格式为 { 1.23 1.10 1.05 }
+func loadAverage() (float64, error) {
+	out, err := exec.Command("sysctl", "-n", "vm.loadavg").Output()
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(strings.Trim(strings.TrimSpace(string(out)), "{}"))
+	if len(fields) == 0 {
+		return 0, i18n.Errorf("无法解析 vm.loadavg: %s", out)
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// onBattery 根据 pmset 的输出判断是否使用电池供电
+func onBattery() (bool, error) {
+	out, err := exec.Command("pmset", "-g", "batt").Output()
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(string(out), "'Battery Power'"), nil
+}
@@ -0,0 +1,48 @@
+package backoff
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// loadAverage 读取 1 分钟平均负载
+func loadAverage() (float64, error) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, i18n.Errorf("无法解析 /proc/loadavg")
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// onBattery 有电池且没有接通的外接电源时返回 true，台式机和 NAS 没有电池，始终返回 false
+func onBattery() (bool, error) {
+	supplies, err := filepath.Glob("/sys/class/power_supply/*")
+	if err != nil {
+		return false, err
+	}
+	hasBattery := false
+	for _, dir := range supplies {
+		switch read(filepath.Join(dir, "type")) {
+		case "Battery":
+			hasBattery = true
+		case "Mains", "USB", "USB_C", "USB_PD":
+			if read(filepath.Join(dir, "online")) == "1" {
+				return false, nil
+			}
+		}
+	}
+	return hasBattery, nil
+}
+
+func read(file string) string {
+	data, _ := os.ReadFile(file)
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !windows
+
+package backoff
+
+import "github.com/lucasrui/neo-nas/internal/i18n"
+
+func loadAverage() (float64, error) {
+	return 0, i18n.Errorf("当前平台不支持读取系统负载")
+}
+
+func onBattery() (bool, error) {
+	return false, i18n.Errorf("当前平台不支持读取电源状态")
+}
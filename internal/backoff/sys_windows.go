@@ -0,0 +1,34 @@
+package backoff
+
+import (
+	"syscall"
+	"unsafe"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+var procGetSystemPowerStatus = syscall.NewLazyDLL("kernel32.dll").NewProc("GetSystemPowerStatus")
+
+// systemPowerStatus 对应 SYSTEM_POWER_STATUS
+type systemPowerStatus struct {
+	ACLineStatus        byte
+	BatteryFlag         byte
+	BatteryLifePercent  byte
+	SystemStatusFlag    byte
+	BatteryLifeTime     uint32
+	BatteryFullLifeTime uint32
+}
+
+// loadAverage Windows 没有平均负载
+func loadAverage() (float64, error) {
+	return 0, i18n.Errorf("Windows 不支持 max_load")
+}
+
+// onBattery ACLineStatus 为 0 表示没有接通外接电源
+func onBattery() (bool, error) {
+	var st systemPowerStatus
+	if r, _, err := procGetSystemPowerStatus.Call(uintptr(unsafe.Pointer(&st))); r == 0 {
+		return false, err
+	}
+	return st.ACLineStatus == 0, nil
+}
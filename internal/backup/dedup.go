@@ -0,0 +1,46 @@
+package backup
+
+import (
+	"os"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/catalog"
+)
+
+// linkDuplicate 目标根目录下已有内容相同的备份时创建硬链接，不再复制一份
+// 只有索引中存在相同大小的文件时才计算源文件的哈希；已有备份的修改时间与源文件不同时仍然复制，保证 verify 的快速比较不会报告差异
+func (m *Manager) linkDuplicate(src string, info os.FileInfo, dst string) bool {
+	if !m.dedup || info.Size() == 0 || !m.catalog.HasSize(info.Size()) {
+		return false
+	}
+	sum, err := catalog.HashFile(src)
+	if err != nil {
+		logger.Errorf("计算源文件哈希失败 %s: %v", src, err)
+		return false
+	}
+	for _, e := range m.catalog.FindHash(m.dedupRoot, sum, info.Size()) {
+		st, err := os.Stat(e.Path)
+		if err != nil || st.Size() != info.Size() || !st.ModTime().Equal(info.ModTime()) {
+			continue
+		}
+		if err := os.Link(e.Path, dst); err != nil {
+			// 不可变文件和跨文件系统的路径不能创建硬链接，尝试下一个
+			continue
+		}
+		if err := m.catalog.Put(catalog.Entry{
+			Path:       dst,
+			Source:     src,
+			Size:       info.Size(),
+			ModTime:    info.ModTime(),
+			SHA256:     sum,
+			BackupTime: time.Now(),
+		}); err != nil {
+			logger.Errorf("更新目录索引失败: %v", err)
+		}
+		m.checksums.Add(dst, sum)
+		m.linkParity(e.Path, dst)
+		logger.Infof("已有相同内容的备份，创建硬链接: %s -> %s", dst, e.Path)
+		return true
+	}
+	return false
+}
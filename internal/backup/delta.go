@@ -0,0 +1,82 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"io"
+	"os"
+
+	"github.com/lucasrui/neo-nas/internal/delta"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// deltaMinSize 开启 delta 后不小于该大小的文件有变化时只写入变化的块
+const deltaMinSize = 64 << 20
+
+// errNoReflink 目标文件系统不支持克隆文件，改为完整复制
+var errNoReflink = i18n.New("目标文件系统不支持克隆文件")
+
+// regularFile 目标路径是否已经有普通文件
+func regularFile(p string) bool {
+	info, err := os.Lstat(p)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// copyDelta 先把目标中的旧版本克隆为临时文件，与旧版本共用数据块，再用滚动校验和找出源文件中变化的部分，
+// 只把这些部分写入临时文件，磁盘映像等大文件只改动了少量内容时大部分数据不需要重新写入
+// 目标文件系统不支持克隆（btrfs、XFS、ZFS 2.2 等支持）时返回 errNoReflink
+func (m *Manager) copyDelta(srcFile *os.File, info os.FileInfo, tmp, dst string) ([]byte, error) {
+	base, err := os.Open(dst)
+	if err != nil {
+		return nil, i18n.Errorf("打开目标文件失败: %w", err)
+	}
+	defer base.Close()
+	baseInfo, err := base.Stat()
+	if err != nil {
+		return nil, i18n.Errorf("获取目标文件信息失败: %w", err)
+	}
+
+	dstFile, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, i18n.Errorf("创建目标文件失败: %w", err)
+	}
+	fail := func(err error) ([]byte, error) {
+		dstFile.Close()
+		os.Remove(tmp)
+		return nil, err
+	}
+	if err := reflink(dstFile, base); err != nil {
+		return fail(errNoReflink)
+	}
+
+	sig, err := delta.NewSignature(base, delta.BlockSizeFor(baseInfo.Size()))
+	if err != nil {
+		return fail(err)
+	}
+	hash := sha256.New()
+	src := io.TeeReader(m.limiter.Reader(srcFile), hash)
+	pr, pw := io.Pipe()
+	var stats delta.Stats
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var err error
+		stats, err = delta.Diff(sig, src, pw)
+		pw.CloseWithError(err)
+	}()
+	written, err := delta.Patch(base, pr, dstFile)
+	// 提前失败时让生成增量数据的协程退出
+	pr.CloseWithError(err)
+	<-done
+	if err != nil {
+		return fail(i18n.Errorf("复制文件内容失败: %w", err))
+	}
+	if err := dstFile.Truncate(info.Size()); err != nil {
+		return fail(i18n.Errorf("写入目标文件失败: %w", err))
+	}
+	if err := dstFile.Close(); err != nil {
+		os.Remove(tmp)
+		return nil, i18n.Errorf("写入目标文件失败: %w", err)
+	}
+	logger.Infof("增量复制 %s: 复用 %d 字节, 变化 %d 字节, 写入 %d 字节", srcFile.Name(), stats.Matched, stats.Literal, written)
+	return hash.Sum(nil), nil
+}
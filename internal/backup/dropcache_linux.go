@@ -0,0 +1,15 @@
+//go:build linux && (amd64 || arm64)
+
+package backup
+
+import (
+	"os"
+	"syscall"
+)
+
+const fadvDontNeed = 4 // POSIX_FADV_DONTNEED
+
+// dropCache 丢弃文件在页缓存中的内容，之后的读取来自磁盘
+func dropCache(f *os.File) {
+	syscall.Syscall6(syscall.SYS_FADVISE64, f.Fd(), 0, 0, fadvDontNeed, 0, 0)
+}
@@ -0,0 +1,8 @@
+//go:build !linux || !(amd64 || arm64)
+
+package backup
+
+import "os"
+
+// dropCache 其他平台无法丢弃缓存，重新读取的内容可能来自内存
+func dropCache(f *os.File) {}
@@ -0,0 +1,160 @@
+package backup
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// pattern 编译后的路径规则，按 / 分隔的每一段分别用 path.Match 匹配，** 匹配任意多段
+// 以 / 开头的规则从源目录开始匹配，否则可以匹配任意一层目录，例如 *.tmp、node_modules/**
+type pattern struct {
+	segments []string
+}
+
+// compilePatterns 检查并编译 include 或 exclude 中的规则
+func compilePatterns(raw []string) ([]pattern, error) {
+	patterns := make([]pattern, 0, len(raw))
+	for _, r := range raw {
+		p := strings.TrimSuffix(filepath.ToSlash(strings.TrimSpace(r)), "/")
+		if p == "" || p == "/" {
+			return nil, i18n.Errorf("规则为空: %q", r)
+		}
+		anchored := strings.HasPrefix(p, "/")
+		segments := strings.Split(strings.TrimPrefix(p, "/"), "/")
+		for _, s := range segments {
+			if s == "" {
+				return nil, i18n.Errorf("规则中有空的路径段: %q", r)
+			}
+			if _, err := path.Match(s, ""); err != nil {
+				return nil, i18n.Errorf("规则格式错误 %q: %w", r, err)
+			}
+		}
+		if !anchored && segments[0] != "**" {
+			segments = append([]string{"**"}, segments...)
+		}
+		patterns = append(patterns, pattern{segments: segments})
+	}
+	return patterns, nil
+}
+
+// match 判断按 / 分隔的相对路径是否匹配规则
+func (p pattern) match(parts []string) bool {
+	return matchSegments(p.segments, parts)
+}
+
+func matchSegments(segments, parts []string) bool {
+	for len(segments) > 0 {
+		if segments[0] == "**" {
+			// 连续的 ** 与一个相同
+			for len(segments) > 0 && segments[0] == "**" {
+				segments = segments[1:]
+			}
+			if len(segments) == 0 {
+				return true
+			}
+			for i := range parts {
+				if matchSegments(segments, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(segments[0], parts[0]); !ok {
+			return false
+		}
+		segments, parts = segments[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// matchAny 路径是否匹配其中任意一条规则
+func matchAny(patterns []pattern, parts []string) bool {
+	for _, p := range patterns {
+		if p.match(parts) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter 按任务的 include 和 exclude 规则以及是否备份系统元数据判断源目录中的路径是否需要备份
+type Filter struct {
+	sourceDir string
+	metadata  bool
+	include   []pattern
+	exclude   []pattern
+}
+
+// NewFilter 检查任务中的规则并创建 Filter
+func NewFilter(cfg config.Config) (*Filter, error) {
+	f := &Filter{sourceDir: cfg.SourceDir, metadata: cfg.SystemMetadata}
+	var err error
+	if f.include, err = compilePatterns(cfg.Include); err != nil {
+		return nil, i18n.Errorf("include 配置无效: %w", err)
+	}
+	if f.exclude, err = compilePatterns(cfg.Exclude); err != nil {
+		return nil, i18n.Errorf("exclude 配置无效: %w", err)
+	}
+	return f, nil
+}
+
+// relParts 源目录中的路径相对源目录按 / 分隔的各段，不在源目录中时返回 nil
+func (f *Filter) relParts(sourcePath string) []string {
+	rel, err := filepath.Rel(f.sourceDir, sourcePath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return nil
+	}
+	return strings.Split(filepath.ToSlash(rel), "/")
+}
+
+// matchPrefix 路径本身或者所在的某一层目录是否匹配其中任意一条规则
+func (f *Filter) matchPrefix(patterns []pattern, sourcePath string) bool {
+	parts := f.relParts(sourcePath)
+	for i := 1; i <= len(parts); i++ {
+		if matchAny(patterns, parts[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Excluded 判断源目录中的路径是否不需要备份，目录被排除时其中的内容同样不备份
+func (f *Filter) Excluded(sourcePath string) bool {
+	if !f.metadata && IsSystemMetadata(filepath.Base(sourcePath)) {
+		return true
+	}
+	return len(f.exclude) > 0 && f.matchPrefix(f.exclude, sourcePath)
+}
+
+// Included 判断源目录中的文件是否需要备份，设置了 include 时只备份匹配其中规则的文件
+// 目录不按 include 过滤，其中可能有需要备份的文件
+func (f *Filter) Included(sourcePath string) bool {
+	return len(f.include) == 0 || f.matchPrefix(f.include, sourcePath)
+}
+
+// Matcher 按与 include 相同的规则匹配相对路径，例如 restore 的 --path
+type Matcher []pattern
+
+// NewMatcher 检查并编译规则
+func NewMatcher(raw []string) (Matcher, error) {
+	patterns, err := compilePatterns(raw)
+	return Matcher(patterns), err
+}
+
+// Match 相对路径本身或者所在的某一层目录是否匹配其中任意一条规则
+func (m Matcher) Match(rel string) bool {
+	parts := strings.Split(filepath.ToSlash(filepath.Clean(rel)), "/")
+	for i := 1; i <= len(parts); i++ {
+		if matchAny(m, parts[:i]) {
+			return true
+		}
+	}
+	return false
+}
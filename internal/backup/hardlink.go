@@ -0,0 +1,96 @@
+package backup
+
+import (
+	"os"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/catalog"
+	"github.com/lucasrui/neo-nas/internal/parity"
+)
+
+// fileKey 源文件的设备号和 inode，用于识别互为硬链接的文件
+type fileKey struct {
+	dev, ino uint64
+}
+
+// linkedTarget 已备份的硬链接文件在目标中的路径
+type linkedTarget struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// ResetLinks 清空上次扫描记录的硬链接，每次扫描开始时调用
+func (m *Manager) ResetLinks() {
+	m.linksMu.Lock()
+	m.links = nil
+	m.linksMu.Unlock()
+}
+
+// rememberLink 记录有多个硬链接的源文件在目标中的路径，之后遇到同一文件的其他路径时在目标中创建硬链接
+func (m *Manager) rememberLink(info os.FileInfo, targetPath string) {
+	key, ok := linkKey(info)
+	if !ok {
+		return
+	}
+	m.linksMu.Lock()
+	defer m.linksMu.Unlock()
+	if m.links == nil {
+		m.links = make(map[fileKey]linkedTarget)
+	}
+	if _, exists := m.links[key]; !exists {
+		m.links[key] = linkedTarget{path: targetPath, size: info.Size(), modTime: info.ModTime()}
+	}
+}
+
+// linkExisting 源文件与本次扫描中已备份的文件互为硬链接时，在目标中创建指向已有备份的硬链接
+// 已有备份的大小或时间与源文件不一致，或者目标文件系统不支持硬链接时返回 false，改为复制
+func (m *Manager) linkExisting(src string, info os.FileInfo, dst string) bool {
+	key, ok := linkKey(info)
+	if !ok {
+		return false
+	}
+	m.linksMu.Lock()
+	existing, ok := m.links[key]
+	m.linksMu.Unlock()
+	if !ok || existing.size != info.Size() || !existing.modTime.Equal(info.ModTime()) {
+		return false
+	}
+	if st, err := os.Stat(existing.path); err != nil || st.Size() != info.Size() {
+		return false
+	}
+	if err := os.Link(existing.path, dst); err != nil {
+		logger.Warnf("创建硬链接失败，改为复制 %s: %v", dst, err)
+		return false
+	}
+
+	// 硬链接与已有备份内容相同，沿用其哈希
+	if e, ok := m.catalog.Get(existing.path); ok {
+		if err := m.catalog.Put(catalog.Entry{
+			Path:       dst,
+			Source:     src,
+			Size:       e.Size,
+			ModTime:    e.ModTime,
+			SHA256:     e.SHA256,
+			BackupTime: time.Now(),
+		}); err != nil {
+			logger.Errorf("更新目录索引失败: %v", err)
+		}
+		m.checksums.Add(dst, e.SHA256)
+	}
+	m.linkParity(existing.path, dst)
+	logger.Infof("创建硬链接完成: %s -> %s", dst, existing.path)
+	return true
+}
+
+// linkParity 硬链接的文件内容相同，恢复数据同样创建硬链接，不需要重新生成
+func (m *Manager) linkParity(existing, dst string) {
+	if m.parity == 0 {
+		return
+	}
+	if err := os.Link(parity.Sidecar(existing), parity.Sidecar(dst)); err != nil {
+		if err := parity.Create(dst, m.parity); err != nil {
+			logger.Errorf("生成恢复数据失败 %s: %v", dst, err)
+		}
+	}
+}
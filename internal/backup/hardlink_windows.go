@@ -0,0 +1,8 @@
+package backup
+
+import "os"
+
+// linkKey Windows 上 os.Stat 不提供文件编号，不保留硬链接
+func linkKey(info os.FileInfo) (fileKey, bool) {
+	return fileKey{}, false
+}
@@ -1,9 +1,9 @@
 package backup
 
 import (
-	"fmt"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -11,9 +11,30 @@ import (
 	"sync"
 	"time"
 
+	"github.com/lucasrui/neo-nas/internal/agent"
+	"github.com/lucasrui/neo-nas/internal/catalog"
+	"github.com/lucasrui/neo-nas/internal/checksum"
 	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/dryrun"
+	"github.com/lucasrui/neo-nas/internal/history"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/immutable"
+	"github.com/lucasrui/neo-nas/internal/logging"
+	"github.com/lucasrui/neo-nas/internal/parity"
+	"github.com/lucasrui/neo-nas/internal/remote"
+	"github.com/lucasrui/neo-nas/internal/smb"
+	"github.com/lucasrui/neo-nas/internal/sparse"
+	"github.com/lucasrui/neo-nas/internal/throttle"
+	"github.com/lucasrui/neo-nas/internal/tier"
+	"github.com/lucasrui/neo-nas/internal/xattr"
 )
 
+// logger backup 模块的日志
+var logger = logging.New("backup")
+
+// partialPrefix 复制中的临时文件名前缀，与其他内部文件一样以 .neo- 开头，扫描目标目录时跳过
+const partialPrefix = ".neo-partial-"
+
 // 定义备份状态码
 type BackupStatus int
 
@@ -23,52 +44,192 @@ const (
 	Skipped
 )
 
+// Options 备份管理器依赖的全局组件
+type Options struct {
+	State    *config.StateStore // 每个文件的备份状态
+	Hostname string             // 本机名称，用于区分各机器的备份状态和共用目标中的目录
+	Catalog  *catalog.Catalog   // 目标文件索引
+	Agent    *agent.Client      // 客户端模式下的服务端连接，仅 agent:// 目标使用
+	Remote   remote.Options     // 远程存储的连接配置，仅 s3://、rclone: 和 smb:// 目标使用
+	SMB      *smb.Mounter       // 挂载 smb:// 目标所在的共享
+	History  *history.Store     // 记录每次扫描的结果
+}
+
 type Manager struct {
-	sourceDir    string
+	sourceDir    string // 源目录的路径，按卷指定时为卷当前的挂载点
+	sourceKey    string // 配置中的源目录，用于记录备份状态和日志，按卷指定时不随挂载点变化
 	targetDir    string
+	namespace    string // 客户端模式下服务端的命名空间，为空表示本地目标
+	hostname     string
 	targetUid    int
 	targetGid    int
-	progressFile string
-	progress     *config.ProgressConfig
+	state        *config.StateStore
+	catalog      *catalog.Catalog
+	agent        *agent.Client
+	store        remote.Backend // s3://、rclone: 和 smb:// 目标的存储，为 nil 表示写入本地目录
+	immutable    bool
+	immutableErr sync.Once // 不支持不可变属性时只记录一次日志
+	checksums    *checksum.Writer
+	seed         bool
+	filter       *Filter // 系统元数据和 include、exclude 规则
+	specialFiles string  // 命名管道、设备文件等特殊文件的处理方式
+	dedup        bool    // 按内容哈希查找已有的备份，找到时创建硬链接
+	dedupRoot    string  // 查找已有备份的范围，即配置的目标目录
+	parity       int     // 恢复数据的冗余比例，0 表示不生成
+	update       bool    // 源文件有变化时覆盖目标中已有的文件
+	delta        bool    // 有变化的大文件只写入变化的块
+	verifyWrites bool    // 复制后重新读取目标文件核对哈希
+	reflinkErr   sync.Once
+	dryRun       dryrun.Task
+	limiter      *throttle.Limiter // 复制文件时读取源文件的速度限制，为 nil 时不限速
+	links        map[fileKey]linkedTarget
+	linksMu      sync.Mutex
 	activeOps    sync.WaitGroup
-	progressLock sync.Mutex
 }
 
-func NewManager(sourceDir, targetDir, targetUser, progressFile string) (*Manager, error) {
-	// 确保目标目录存在
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
-		log.Printf("创建目标目录失败: %v", err)
-		return nil, err
+func NewManager(cfg config.Config, opts Options) (*Manager, error) {
+	m := &Manager{
+		sourceDir:    cfg.SourceDir,
+		sourceKey:    cfg.SourceDir,
+		targetDir:    cfg.TargetDir,
+		state:        opts.State,
+		hostname:     opts.Hostname,
+		catalog:      opts.Catalog,
+		immutable:    cfg.Immutable,
+		seed:         cfg.SeedFromTarget,
+		specialFiles: cfg.SpecialFiles,
+		dedup:        cfg.Dedup,
+		dedupRoot:    cfg.TargetDir,
+		parity:       cfg.ParityPercent,
+		update:       cfg.UpdateChanged,
+		delta:        cfg.Delta,
+		verifyWrites: cfg.VerifyWrites,
+		dryRun:       dryrun.Task(cfg.DryRun),
+		limiter:      throttle.NewFixed(cfg.MaxBytesPerSec),
 	}
 
-	// 从targetUser中解析出uid和gid，格式为uid:gid
-	targetUid, targetGid := 0, 0
-	if targetUser != "" {
-		uidGid := strings.Split(targetUser, ":")
-		if len(uidGid) == 2 {
-			targetUid, _ = strconv.Atoi(uidGid[0])
-			targetGid, _ = strconv.Atoi(uidGid[1])
-		}
+	if cfg.DryRun {
+		logger.Infof("任务处于演练模式，只记录将要执行的操作: %s -> %s", cfg.SourceDir, cfg.TargetDir)
 	}
 
-	m := &Manager{
-		sourceDir:    sourceDir,
-		targetDir:    targetDir,
-		targetUid:    targetUid,
-		targetGid:    targetGid,
-		progressFile: progressFile,
+	if cfg.IsAgentTarget() {
+		if opts.Agent == nil {
+			return nil, i18n.Errorf("目标 %s 需要配置客户端模式", cfg.TargetDir)
+		}
+		m.namespace = cfg.AgentNamespace()
+		if m.namespace == "" || strings.Contains(m.namespace, "/") {
+			return nil, i18n.Errorf("客户端命名空间无效: %s", cfg.TargetDir)
+		}
+		m.agent = opts.Agent
+	} else if cfg.IsStoreTarget() || cfg.IsSMBTarget() {
+		location := strings.TrimSuffix(cfg.TargetDir, "/")
+		if cfg.HostNamespace {
+			if m.hostname == "" {
+				return nil, i18n.Errorf("目标 %s 按主机名存放，但未设置主机名", cfg.TargetDir)
+			}
+			location += "/" + m.hostname
+		}
+		var err error
+		if m.store, err = remote.Open(location, opts.Remote); err != nil {
+			return nil, i18n.Errorf("打开远程存储目标失败: %w", err)
+		}
+		m.targetDir = location
+	} else {
+		// 服务端已经按主机名存放客户端的文件，本地目标需要单独开启
+		if cfg.HostNamespace {
+			if m.hostname == "" {
+				return nil, i18n.Errorf("目标 %s 按主机名存放，但未设置主机名", cfg.TargetDir)
+			}
+			m.targetDir = filepath.Join(cfg.TargetDir, m.hostname)
+			logger.Infof("按主机名存放备份: %s", m.targetDir)
+		}
+		// 确保目标目录存在，演练模式下只记录
+		if m.dryRun.Enabled() {
+			if _, err := os.Stat(m.targetDir); err != nil {
+				m.dryRun.Skip("创建目标目录: %s", m.targetDir)
+			}
+		} else if err := os.MkdirAll(m.targetDir, 0755); err != nil {
+			logger.Errorf("创建目标目录失败: %v", err)
+			return nil, err
+		}
 	}
 
-	// 加载上次同步时间
-	if err := m.loadProgress(); err != nil {
-		log.Printf("加载进度文件失败: %v", err)
+	if cfg.Checksums.Algorithm != "" && m.IsRemote() {
+		return nil, i18n.Errorf("推送到服务端或远程存储的目标不支持写入校验文件")
+	}
+	switch cfg.SpecialFiles {
+	case "", config.SpecialSkip, config.SpecialRecreate:
+	default:
+		return nil, i18n.Errorf("未知的特殊文件处理方式: %s", cfg.SpecialFiles)
+	}
+	var err error
+	if m.filter, err = NewFilter(cfg); err != nil {
 		return nil, err
 	}
+	if cfg.ParityPercent < 0 || cfg.ParityPercent > 100 {
+		return nil, i18n.Errorf("恢复数据的冗余比例应为 0 到 100: %d", cfg.ParityPercent)
+	}
+	if cfg.ParityPercent > 0 && m.IsRemote() {
+		return nil, i18n.Errorf("推送到服务端或远程存储的目标不支持生成恢复数据")
+	}
+	if cfg.Dedup && m.IsRemote() {
+		return nil, i18n.Errorf("推送到服务端或远程存储的目标不支持按内容去重")
+	}
+	if cfg.MaxBytesPerSec < 0 {
+		return nil, i18n.Errorf("复制速度限制不能为负数: %d", cfg.MaxBytesPerSec)
+	}
+	if cfg.MaxBytesPerSec > 0 && m.IsRemote() {
+		return nil, i18n.Errorf("推送到服务端或远程存储的目标按 bandwidth 配置限制上传带宽，不支持 max_bytes_per_sec")
+	}
+	if cfg.VerifyWrites && m.IsRemote() {
+		return nil, i18n.Errorf("推送到服务端或远程存储的目标在上传后另行校验，不支持 verify_writes")
+	}
+	if cfg.Delta && m.IsRemote() {
+		return nil, i18n.Errorf("delta 只用于本地目标，推送到服务端时已自动使用增量上传")
+	}
+	if cfg.Delta && !cfg.UpdateChanged {
+		return nil, i18n.Errorf("delta 需要同时开启 update_changed，否则目标中已有的文件不会更新")
+	}
+	if cfg.SeedFromTarget && m.IsRemote() {
+		return nil, i18n.Errorf("推送到服务端或远程存储的目标不支持从目标目录导入")
+	}
+	if m.checksums, err = checksum.New(cfg.Checksums, m.targetDir, m.catalog); err != nil {
+		return nil, err
+	}
+
+	// 从targetUser中解析出uid和gid，格式为uid:gid
+	if cfg.TargetUser != "" {
+		uidGid := strings.Split(cfg.TargetUser, ":")
+		if len(uidGid) == 2 {
+			m.targetUid, _ = strconv.Atoi(uidGid[0])
+			m.targetGid, _ = strconv.Atoi(uidGid[1])
+		}
+	}
 	return m, nil
 }
 
+// DryRun 任务的演练模式，开启时只记录将要执行的操作
+func (m *Manager) DryRun() dryrun.Task {
+	return m.dryRun
+}
+
+// IsRemote 目标是否位于服务端、S3 兼容存储、rclone 远程存储或未挂载的 SMB 共享，此时本地不存在目标目录
+func (m *Manager) IsRemote() bool {
+	return m.agent != nil || m.store != nil
+}
+
 // 返回一个状态码，用于表示备份结果，可能是成功，失败，或者跳过
 func (m *Manager) Backup(sourcePath string) BackupStatus {
+	return m.backup(sourcePath, true)
+}
+
+// BackupChanged 备份实时监控通知有变化的文件，不按状态记录跳过，文件可能被改写后恢复了原来的大小和修改时间
+func (m *Manager) BackupChanged(sourcePath string) BackupStatus {
+	return m.backup(sourcePath, false)
+}
+
+// backup 状态记录中大小和修改时间都没有变化的文件直接跳过，否则检查目标后备份，成功或确认已备份后更新状态记录
+func (m *Manager) backup(sourcePath string, checkState bool) BackupStatus {
 	m.activeOps.Add(1)
 	defer m.activeOps.Done()
 
@@ -84,28 +245,233 @@ func (m *Manager) Backup(sourcePath string) BackupStatus {
 		return Failed
 	}
 
-	// 获取对应配置的同步时间
-	lastSyncTime := m.getLastSyncTime()
-	if lastSyncTime != nil {
-		// 使用修改时间作为判断依据
-		fileTime := fileInfo.ModTime()
-		if fileTime.Before(*lastSyncTime) {
+	statePath := m.statePath(targetPath)
+	if checkState {
+		if st, ok := m.state.Get(m.hostname, statePath); ok && st.Size == fileInfo.Size() && st.ModTime.Equal(fileInfo.ModTime()) {
 			return Skipped
 		}
 	}
+	status := m.backupFile(sourcePath, targetPath, fileInfo)
+	if status != Failed && !m.dryRun.Enabled() {
+		if err := m.state.Put(config.FileState{
+			Host:       m.hostname,
+			Path:       statePath,
+			Source:     sourcePath,
+			Size:       fileInfo.Size(),
+			ModTime:    fileInfo.ModTime(),
+			BackupTime: time.Now(),
+		}); err != nil {
+			logger.Errorf("更新备份状态失败: %v", err)
+		}
+	}
+	return status
+}
+
+// statePath 目标文件在状态记录中的路径，客户端模式下为 agent://<命名空间>/<相对路径>
+func (m *Manager) statePath(targetPath string) string {
+	if m.agent != nil {
+		return config.AgentTargetPrefix + m.namespace + "/" + targetPath
+	}
+	return m.indexPath(targetPath)
+}
+
+// backupFile 检查目标文件后复制、上传或跳过
+func (m *Manager) backupFile(sourcePath, targetPath string, fileInfo os.FileInfo) BackupStatus {
+	if !fileInfo.Mode().IsRegular() && m.IsRemote() {
+		logger.Warnf("跳过特殊文件（%s），远程目标只接收普通文件: %s", specialKind(fileInfo.Mode()), sourcePath)
+		return Skipped
+	}
+	if m.store != nil {
+		return m.put(sourcePath, targetPath, fileInfo)
+	}
+	if m.IsRemote() {
+		return m.upload(sourcePath, targetPath, fileInfo)
+	}
 
-	// 检查目标文件是否存在，如果存在就跳过
-	_, err = os.Stat(targetPath)
-	if err == nil {
+	// 检查目标文件是否存在，如果存在且没有变化就跳过
+	replace := false
+	if targetInfo, err := os.Lstat(targetPath); err == nil {
+		if !m.update || !fileInfo.Mode().IsRegular() || !targetInfo.Mode().IsRegular() || !sourceChanged(fileInfo, targetInfo) {
+			m.rememberLink(fileInfo, targetPath)
+			return Skipped
+		}
+		replace = true
+	}
+	// 已迁移到冷存储的文件同样视为已备份
+	if tier.HasStub(targetPath) {
 		return Skipped
 	}
+	// 特殊文件没有内容，打开命名管道还会一直阻塞
+	if !fileInfo.Mode().IsRegular() {
+		return m.backupSpecial(sourcePath, targetPath, fileInfo)
+	}
+
+	if replace {
+		return m.replaceFile(sourcePath, targetPath, fileInfo)
+	}
+
+	if m.dryRun.Skip("复制文件: %s -> %s", sourcePath, targetPath) {
+		return Success
+	}
+
+	// 与已备份的文件互为硬链接时只创建硬链接
+	if m.linkExisting(sourcePath, fileInfo, targetPath) {
+		return Success
+	}
+	// 目标中已有相同内容的文件时同样只创建硬链接
+	if m.linkDuplicate(sourcePath, fileInfo, targetPath) {
+		m.rememberLink(fileInfo, targetPath)
+		return Success
+	}
 
 	// 执行备份（覆盖已存在的文件）
+	start := time.Now()
 	if err := m.copyFile(sourcePath, targetPath); err != nil {
 		return Failed
 	}
+	m.rememberLink(fileInfo, targetPath)
+
+	m.fileLog(sourcePath, fileInfo, start).Infof("文件备份完成: %s -> %s", sourcePath, targetPath)
+	return Success
+}
+
+// replaceFile 源文件有变化时重新复制，替换目标中的旧版本
+// 设置了不可变属性的旧版本先清除属性，复制完成后重新设置
+func (m *Manager) replaceFile(sourcePath, targetPath string, fileInfo os.FileInfo) BackupStatus {
+	if m.dryRun.Skip("更新文件: %s -> %s", sourcePath, targetPath) {
+		return Success
+	}
+	if err := immutable.Clear(targetPath); err != nil {
+		logger.Errorf("清除目标文件的不可变属性失败 %s: %v", targetPath, err)
+		return Failed
+	}
+	start := time.Now()
+	if err := m.copyFile(sourcePath, targetPath); err != nil {
+		logger.Errorf("更新文件失败 %s: %v", targetPath, err)
+		// 复制失败时旧版本保持原样，恢复其不可变属性
+		if m.immutable {
+			if err := immutable.Set(targetPath); err != nil {
+				m.immutableErr.Do(func() { logger.Errorf("设置不可变属性失败: %s: %v", targetPath, err) })
+			}
+		}
+		return Failed
+	}
+	m.rememberLink(fileInfo, targetPath)
+
+	m.fileLog(sourcePath, fileInfo, start).Infof("文件更新完成: %s -> %s", sourcePath, targetPath)
+	return Success
+}
+
+// fileLog 附带任务、文件、大小、用时和结果的日志，记录单个文件备份成功
+func (m *Manager) fileLog(sourcePath string, info os.FileInfo, start time.Time) *logging.Logger {
+	return logger.With(logging.KeyTask, m.sourceKey, logging.KeyFile, sourcePath, logging.KeyBytes, info.Size(),
+		logging.KeyDuration, time.Since(start), logging.KeyStatus, logging.StatusSuccess)
+}
+
+// modTimeSlack 比较修改时间时允许的误差，FAT 和 exFAT 只精确到 2 秒
+const modTimeSlack = 2 * time.Second
+
+// sourceChanged 源文件比目标中已有的文件新，或者修改时间相同但大小不同时返回 true
+// 复制时目标文件的时间设置为源文件的时间，目标更新说明在目标中修改过，这时不覆盖
+func sourceChanged(src, dst os.FileInfo) bool {
+	diff := src.ModTime().Sub(dst.ModTime())
+	if diff > modTimeSlack {
+		return true
+	}
+	return diff >= -modTimeSlack && src.Size() != dst.Size()
+}
+
+// backedUp 目录索引中是否记录了目标文件，且大小和修改时间与源文件一致
+func (m *Manager) backedUp(targetPath string, fileInfo os.FileInfo) bool {
+	e, ok := m.catalog.Get(targetPath)
+	return ok && e.Size == fileInfo.Size() && e.ModTime.Equal(fileInfo.ModTime())
+}
+
+// indexPath 目标文件在目录索引中的路径，对象存储目标为 <存储位置>/<对象键>
+func (m *Manager) indexPath(targetPath string) string {
+	if m.store != nil {
+		return m.targetDir + "/" + targetPath
+	}
+	return targetPath
+}
+
+// put 上传到 S3 兼容存储、rclone 远程存储或 SMB 共享，对象键为文件相对源目录的路径
+// 存储上的对象不一定保留修改时间，目录索引中已有大小和修改时间相同的记录时视为已备份
+func (m *Manager) put(sourcePath, key string, fileInfo os.FileInfo) BackupStatus {
+	location := m.indexPath(key)
+	if m.backedUp(location, fileInfo) {
+		return Skipped
+	}
+	if m.dryRun.Skip("上传文件: %s -> %s", sourcePath, location) {
+		return Success
+	}
+	start := time.Now()
+	sum, err := catalog.HashFile(sourcePath)
+	if err != nil {
+		logger.Errorf("计算源文件哈希失败 %s: %v", sourcePath, err)
+		return Failed
+	}
+	f, err := os.Open(sourcePath)
+	if err != nil {
+		logger.Errorf("打开源文件失败 %s: %v", sourcePath, err)
+		return Failed
+	}
+	defer f.Close()
+	if tp, ok := m.store.(remote.TimePutter); ok {
+		err = tp.PutWithModTime(key, f, fileInfo.ModTime())
+	} else {
+		err = m.store.Put(key, f)
+	}
+	if err != nil {
+		logger.Errorf("上传文件失败 %s: %v", sourcePath, err)
+		return Failed
+	}
+	if err := m.catalog.Put(catalog.Entry{
+		Path:       location,
+		Source:     sourcePath,
+		Size:       fileInfo.Size(),
+		ModTime:    fileInfo.ModTime(),
+		SHA256:     sum,
+		BackupTime: time.Now(),
+	}); err != nil {
+		logger.Errorf("更新目录索引失败: %v", err)
+	}
+	m.fileLog(sourcePath, fileInfo, start).Infof("文件上传完成: %s -> %s", sourcePath, location)
+	return Success
+}
+
+// upload 客户端模式下推送文件，服务端已有相同大小和修改时间的文件时跳过
+func (m *Manager) upload(sourcePath, relPath string, fileInfo os.FileInfo) BackupStatus {
+	remote, err := m.agent.Stat(m.namespace, relPath)
+	if err != nil {
+		logger.Errorf("查询服务端文件失败 %s: %v", sourcePath, err)
+		return Failed
+	}
+	if remote != nil && remote.Size == fileInfo.Size() && remote.ModTime.Equal(fileInfo.ModTime()) {
+		return Skipped
+	}
+
+	if m.dryRun.Skip("上传文件: %s -> %s%s/%s", sourcePath, config.AgentTargetPrefix, m.namespace, relPath) {
+		return Success
+	}
+
+	// 服务端已有旧版本的大文件只上传变化的部分，失败时改为完整上传
+	start := time.Now()
+	if remote != nil && m.agent.UseDelta(fileInfo.Size()) {
+		_, stats, err := m.agent.UploadDelta(m.namespace, relPath, sourcePath)
+		if err == nil {
+			m.fileLog(sourcePath, fileInfo, start).Infof("增量上传完成: %s -> %s%s/%s, 复用 %d 字节, 传输 %d 字节", sourcePath, config.AgentTargetPrefix, m.namespace, relPath, stats.Matched, stats.Literal)
+			return Success
+		}
+		logger.Warnf("增量上传失败，改为完整上传 %s: %v", sourcePath, err)
+	}
+
+	if _, err := m.agent.Upload(m.namespace, relPath, sourcePath); err != nil {
+		logger.Errorf("上传文件失败 %s: %v", sourcePath, err)
+		return Failed
+	}
 
-	log.Printf("文件备份完成: %s -> %s", sourcePath, targetPath)
+	m.fileLog(sourcePath, fileInfo, start).Infof("文件上传完成: %s -> %s%s/%s", sourcePath, config.AgentTargetPrefix, m.namespace, relPath)
 	return Success
 }
 
@@ -124,137 +490,183 @@ func (m *Manager) Backup(sourcePath string) BackupStatus {
 // 	return hex.EncodeToString(hash.Sum(nil)), nil
 // }
 
+// copyWhole 把源文件的内容完整复制到临时文件，同时计算哈希写入目录索引，源文件中的空洞保留为空洞
+// 失败时删除临时文件
+func (m *Manager) copyWhole(srcFile *os.File, tmp string) ([]byte, error) {
+	dstFile, err := os.Create(tmp)
+	if err != nil {
+		return nil, i18n.Errorf("创建目标文件失败: %w", err)
+	}
+	hash := sha256.New()
+	if _, err := sparse.CopyLimited(dstFile, srcFile, hash, m.limiter); err != nil {
+		dstFile.Close()
+		os.Remove(tmp)
+		return nil, i18n.Errorf("复制文件内容失败: %w", err)
+	}
+	if err := dstFile.Close(); err != nil {
+		os.Remove(tmp)
+		return nil, i18n.Errorf("写入目标文件失败: %w", err)
+	}
+	return hash.Sum(nil), nil
+}
+
 func (m *Manager) copyFile(src, dst string) error {
 	// 打开源文件
 	srcFile, err := os.Open(src)
 	if err != nil {
-		return fmt.Errorf("打开源文件失败: %w", err)
+		return i18n.Errorf("打开源文件失败: %w", err)
 	}
 	defer srcFile.Close()
 
-	// 创建目标文件
-	dstFile, err := os.Create(dst)
+	// 先写入临时文件，完成后再改名，中途退出不会在目标路径留下不完整的文件
+	// 临时文件名固定，强制退出后残留的临时文件在下次复制时覆盖，大文件则从断点继续
+	tmp := filepath.Join(filepath.Dir(dst), partialPrefix+filepath.Base(dst))
+	info, err := srcFile.Stat()
 	if err != nil {
-		return fmt.Errorf("创建目标文件失败: %w", err)
+		return i18n.Errorf("获取源文件信息失败: %w", err)
+	}
+	var digest []byte
+	err = errNoReflink
+	if m.delta && info.Size() >= deltaMinSize && regularFile(dst) {
+		if digest, err = m.copyDelta(srcFile, info, tmp, dst); err == errNoReflink {
+			m.reflinkErr.Do(func() {
+				logger.Infof("目标文件系统不支持克隆文件，delta 改为完整复制: %s", m.targetDir)
+			})
+			if _, err := srcFile.Seek(0, io.SeekStart); err != nil {
+				return i18n.Errorf("读取源文件失败: %w", err)
+			}
+		}
+	}
+	if err == errNoReflink {
+		if info.Size() >= resumeMinSize {
+			digest, err = m.copyResumable(srcFile, info, tmp, dst)
+		} else {
+			digest, err = m.copyWhole(srcFile, tmp)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	if m.verifyWrites {
+		if err := checkWritten(tmp, digest); err != nil {
+			return i18n.Errorf("写入校验失败，下次扫描时重新复制: %w", err)
+		}
 	}
-	defer dstFile.Close()
 
-	// 复制文件内容
-	if _, err := io.Copy(dstFile, srcFile); err != nil {
-		return fmt.Errorf("复制文件内容失败: %w", err)
+	// 扩展属性需要在设置权限之前写入，只读文件无法修改扩展属性
+	if err := xattr.Copy(src, tmp); err != nil {
+		logger.Errorf("复制扩展属性失败 %s: %v", src, err)
 	}
 
 	// 获取源文件信息
 	srcInfo, err := os.Stat(src)
 	if err != nil {
-		return fmt.Errorf("获取源文件信息失败: %w", err)
+		return i18n.Errorf("获取源文件信息失败: %w", err)
 	}
 
 	// 设置目标文件权限
-	if err := os.Chmod(dst, srcInfo.Mode()); err != nil {
-		log.Printf("设置目标文件权限失败: %v", err)
+	if err := os.Chmod(tmp, srcInfo.Mode()); err != nil {
+		logger.Errorf("设置目标文件权限失败: %v", err)
 	}
 
 	// 设置目标文件时间
-	if err := os.Chtimes(dst, srcInfo.ModTime(), srcInfo.ModTime()); err != nil {
-		log.Printf("设置目标文件时间失败: %v", err)
+	if err := os.Chtimes(tmp, srcInfo.ModTime(), srcInfo.ModTime()); err != nil {
+		logger.Errorf("设置目标文件时间失败: %v", err)
 	}
 
 	// 设置目标文件的 UID 和 GID
 	if m.targetUid != 0 || m.targetGid != 0 {
-		if err := os.Chown(dst, m.targetUid, m.targetGid); err != nil {
-			log.Printf("设置目标文件 UID 和 GID 失败: %v", err)
+		if err := os.Chown(tmp, m.targetUid, m.targetGid); err != nil {
+			logger.Errorf("设置目标文件 UID 和 GID 失败: %v", err)
 		}
 	}
 
-	return nil
-}
-
-func (m *Manager) loadProgress() error {
-	log.Printf("尝试加载进度文件: %s", m.progressFile)
-
-	progress, err := config.LoadProgress(m.progressFile)
-	if err != nil {
-		return err
+	if err := os.Rename(tmp, dst); err != nil {
+		return i18n.Errorf("写入目标文件失败: %w", err)
 	}
 
-	m.progress = progress
-	log.Printf("成功加载进度配置")
-	return nil
-}
-
-func (m *Manager) SaveProgress() error {
-	m.progressLock.Lock()
-	defer m.progressLock.Unlock()
-
-	// 检查源目录是否存在
-	if _, err := os.Stat(m.sourceDir); err != nil {
-		log.Printf("源目录不存在，跳过保存进度: %s", m.sourceDir)
-		return nil
+	sum := hex.EncodeToString(digest)
+	if err := m.catalog.Put(catalog.Entry{
+		Path:       dst,
+		Source:     src,
+		Size:       srcInfo.Size(),
+		ModTime:    srcInfo.ModTime(),
+		SHA256:     sum,
+		BackupTime: time.Now(),
+	}); err != nil {
+		logger.Errorf("更新目录索引失败: %v", err)
 	}
+	m.checksums.Add(dst, sum)
 
-	// 更新同步时间
-	now := time.Now()
-	m.updateProgressTime(now)
-
-	// 保存进度
-	if err := m.progress.Save(m.progressFile); err != nil {
-		return fmt.Errorf("保存进度失败: %w", err)
+	if m.parity > 0 {
+		if err := parity.Create(dst, m.parity); err != nil {
+			logger.Errorf("生成恢复数据失败 %s: %v", dst, err)
+		}
 	}
 
-	log.Printf("成功保存进度配置")
-	return nil
-}
-
-func (m *Manager) updateProgressTime(time time.Time) {
-	for i, item := range m.progress.BackupConfigs {
-		if item.SourceDir == m.sourceDir {
-			m.progress.BackupConfigs[i].ProgressTime = time
-			return
+	// 权限、时间和所有者都设置完成后才能设置不可变属性
+	if m.immutable {
+		if err := immutable.Set(dst); err != nil {
+			m.immutableErr.Do(func() { logger.Errorf("设置不可变属性失败: %s: %v", dst, err) })
 		}
 	}
-	// 如果没有找到，添加新的
-	m.progress.BackupConfigs = append(m.progress.BackupConfigs, config.ProgressConfigItem{
-		SourceDir:    m.sourceDir,
-		ProgressTime: time,
-	})
+
+	return nil
 }
 
-func (m *Manager) getLastSyncTime() *time.Time {
-	// 检查源目录是否存在
-	if _, err := os.Stat(m.sourceDir); err != nil {
-		log.Printf("源目录不存在，不检查上次同步时间: %s", m.sourceDir)
+// SaveState 每次扫描完成后把状态记录和目录索引同步到磁盘
+func (m *Manager) SaveState() error {
+	if m.dryRun.Skip("保存备份状态: %s", m.sourceDir) {
 		return nil
 	}
-
-	// 查找对应的进度时间
-	for _, item := range m.progress.BackupConfigs {
-		if item.SourceDir == m.sourceDir {
-			// 检查源目录是否仍然存在
-			if _, err := os.Stat(m.sourceDir); err != nil {
-				log.Printf("源目录已不存在，不检查上次同步时间: %s", m.sourceDir)
-				return nil
-			}
-			return &item.ProgressTime
-		}
+	if err := m.state.Save(); err != nil {
+		return i18n.Errorf("保存备份状态失败: %w", err)
+	}
+	if err := m.catalog.Save(); err != nil {
+		return i18n.Errorf("保存目录索引失败: %w", err)
 	}
-
 	return nil
 }
 
-// BuildTargetPath 构建目标路径
+// SetSourceDir 按卷指定源目录时设置卷当前的挂载点，只在没有扫描和复制时调用
+func (m *Manager) SetSourceDir(dir string) {
+	m.sourceDir = dir
+	m.filter.sourceDir = dir
+}
+
+// BuildTargetPath 构建目标路径，客户端模式下返回服务端命名空间内的相对路径
 func (m *Manager) BuildTargetPath(sourcePath string) string {
 	// 获取相对路径
 	relPath, err := filepath.Rel(m.sourceDir, sourcePath)
 	if err != nil {
-		log.Printf("无法获取相对路径: %v", err)
+		logger.Errorf("无法获取相对路径: %v", err)
 		return ""
 	}
+	if m.IsRemote() {
+		return filepath.ToSlash(relPath)
+	}
 
 	return filepath.Join(m.targetDir, relPath)
 }
 
-// 添加 WaitForCompletion 方法
+// HasBackups 目标目录中是否已经有备份过的文件
+func (m *Manager) HasBackups() bool {
+	if m.IsRemote() {
+		return false
+	}
+	return m.catalog.HasEntries(m.targetDir)
+}
+
+// WriteChecksums 扫描完成后写入校验文件，未配置时不做任何处理
+func (m *Manager) WriteChecksums() error {
+	if m.checksums != nil && m.dryRun.Skip("写入校验文件: %s", m.targetDir) {
+		return nil
+	}
+	return m.checksums.Flush()
+}
+
+// WaitForCompletion 等待正在复制或上传的文件完成
 func (m *Manager) WaitForCompletion() {
 	m.activeOps.Wait()
 }
@@ -264,27 +676,27 @@ func (m *Manager) SyncDirectory(sourcePath string) error {
 	// 构建目标目录路径
 	targetPath := m.BuildTargetPath(sourcePath)
 	if targetPath == "" {
-		return fmt.Errorf("无法构建目标路径: %s", sourcePath)
+		return i18n.Errorf("无法构建目标路径: %s", sourcePath)
 	}
 
 	// 获取源目录信息
 	srcInfo, err := os.Stat(sourcePath)
 	if err != nil {
-		return fmt.Errorf("获取源目录信息失败: %w", err)
+		return i18n.Errorf("获取源目录信息失败: %w", err)
 	}
 
 	// 确保目标目录存在
 	if err := os.MkdirAll(targetPath, srcInfo.Mode()); err != nil {
-		return fmt.Errorf("创建目标目录失败: %w", err)
+		return i18n.Errorf("创建目标目录失败: %w", err)
 	}
 
 	// 设置目录时间
 	atime := srcInfo.ModTime() // 使用修改时间作为访问时间
 	mtime := srcInfo.ModTime() // 修改时间
 	if err := os.Chtimes(targetPath, atime, mtime); err != nil {
-		log.Printf("设置目录时间失败: %v", err)
+		logger.Errorf("设置目录时间失败: %v", err)
 	}
 
-	log.Printf("目录同步完成: %s -> %s", sourcePath, targetPath)
+	logger.Infof("目录同步完成: %s -> %s", sourcePath, targetPath)
 	return nil
 }
@@ -0,0 +1,34 @@
+package backup
+
+// systemMetadata macOS 和 Windows 在卷和目录中生成的系统元数据，恢复后没有意义，默认不备份
+// Windows 的这些目录通常只有 SYSTEM 可以读取，以盘符根目录为源目录时不跳过会在每次扫描时报错
+var systemMetadata = map[string]bool{
+	".Spotlight-V100":                     true, // Spotlight 索引
+	".fseventsd":                          true, // 文件系统事件日志
+	".Trashes":                            true, // 外接卷的废纸篓
+	".TemporaryItems":                     true,
+	".DocumentRevisions-V100":             true, // 文稿的历史版本
+	".MobileBackups":                      true, // Time Machine 本地快照
+	"Backups.backupdb":                    true, // Time Machine 备份
+	".com.apple.timemachine.donotpresent": true,
+	".com.apple.timemachine.supported":    true,
+	"$RECYCLE.BIN":                        true, // Windows 的回收站
+	"$Recycle.Bin":                        true,
+	"RECYCLER":                            true, // Windows XP 的回收站
+	"System Volume Information":           true, // 还原点、卷影副本和索引数据
+}
+
+// IsSystemMetadata 判断文件或目录名是否为 macOS 或 Windows 的系统元数据
+func IsSystemMetadata(name string) bool {
+	return systemMetadata[name]
+}
+
+// Excluded 判断源目录中的路径是否不需要备份，目录被排除时其中的内容同样不备份
+func (m *Manager) Excluded(path string) bool {
+	return m.filter.Excluded(path)
+}
+
+// Included 判断源目录中的文件是否匹配 include 规则，没有设置 include 时全部备份
+func (m *Manager) Included(path string) bool {
+	return m.filter.Included(path)
+}
@@ -0,0 +1,18 @@
+package backup
+
+import (
+	"os"
+	"syscall"
+)
+
+// ficlone 即 FICLONE ioctl
+const ficlone = 0x40049409
+
+// reflink 把 src 的内容克隆到 dst，两个文件共用数据块直到其中一个被修改
+func reflink(dst, src *os.File) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dst.Fd(), ficlone, src.Fd())
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package backup
+
+import (
+	"errors"
+	"os"
+)
+
+// reflink 其他平台暂不支持克隆文件
+func reflink(dst, src *os.File) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,162 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+const (
+	resumePrefix       = ".neo-resume-" // 断点文件名前缀，与临时文件放在同一目录
+	resumeMinSize      = 256 << 20      // 不小于该大小的文件复制时保存断点，中断后从断点继续
+	checkpointInterval = 64 << 20       // 每复制这么多字节保存一次断点
+	resumeBlock        = 1 << 20        // 每次读取的字节数，全为零的块在目标中留下空洞
+)
+
+// checkpoint 大文件复制的断点，源文件的大小或修改时间变化后作废
+type checkpoint struct {
+	Source  string    `json:"source"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Offset  int64     `json:"offset"` // 临时文件中已经写入磁盘的字节数
+	Hash    []byte    `json:"hash"`   // 已复制部分的 SHA-256 中间状态
+}
+
+// checkpointPath 目标文件对应的断点文件
+func checkpointPath(dst string) string {
+	return filepath.Join(filepath.Dir(dst), resumePrefix+filepath.Base(dst))
+}
+
+// copyResumable 复制大文件，定期把已复制的位置和哈希的中间状态写入断点文件
+// U 盘被拔出等原因中断时保留临时文件和断点，下次复制同一文件时从断点继续，完成后删除断点
+func (m *Manager) copyResumable(srcFile *os.File, info os.FileInfo, tmp, dst string) ([]byte, error) {
+	cpFile := checkpointPath(dst)
+	cp := checkpoint{Source: srcFile.Name(), Size: info.Size(), ModTime: info.ModTime()}
+	hash := sha256.New()
+	if prev, ok := loadCheckpoint(cpFile, tmp, cp); ok {
+		if err := hash.(encoding.BinaryUnmarshaler).UnmarshalBinary(prev.Hash); err == nil {
+			cp.Offset = prev.Offset
+		} else {
+			hash.Reset()
+		}
+	}
+
+	flag := os.O_WRONLY | os.O_CREATE
+	if cp.Offset == 0 {
+		flag |= os.O_TRUNC
+	}
+	dstFile, err := os.OpenFile(tmp, flag, 0644)
+	if err != nil {
+		return nil, i18n.Errorf("创建目标文件失败: %w", err)
+	}
+	defer dstFile.Close()
+	if cp.Offset > 0 {
+		logger.Infof("从断点继续复制 %s: 已复制 %d/%d 字节", cp.Source, cp.Offset, cp.Size)
+		if _, err := srcFile.Seek(cp.Offset, io.SeekStart); err != nil {
+			return nil, i18n.Errorf("读取源文件失败: %w", err)
+		}
+		if _, err := dstFile.Seek(cp.Offset, io.SeekStart); err != nil {
+			return nil, i18n.Errorf("写入目标文件失败: %w", err)
+		}
+	}
+
+	in := m.limiter.Reader(srcFile)
+	buf := make([]byte, resumeBlock)
+	next := cp.Offset + checkpointInterval
+	for cp.Offset < cp.Size {
+		n, err := io.ReadFull(in, buf[:min(int64(len(buf)), cp.Size-cp.Offset)])
+		if err != nil {
+			return nil, i18n.Errorf("复制文件内容失败，下次从 %d 字节处继续: %w", cp.Offset, err)
+		}
+		chunk := buf[:n]
+		hash.Write(chunk)
+		if zeroBlock(chunk) {
+			_, err = dstFile.Seek(int64(n), io.SeekCurrent)
+		} else {
+			_, err = dstFile.Write(chunk)
+		}
+		if err != nil {
+			return nil, i18n.Errorf("写入目标文件失败: %w", err)
+		}
+		cp.Offset += int64(n)
+		if cp.Offset >= next && cp.Offset < cp.Size {
+			if err := saveCheckpoint(dstFile, cpFile, cp, hash.(encoding.BinaryMarshaler)); err != nil {
+				logger.Errorf("保存复制断点失败 %s: %v", cp.Source, err)
+			}
+			next = cp.Offset + checkpointInterval
+		}
+	}
+
+	// 末尾的空洞需要通过设置长度生成
+	if err := dstFile.Truncate(cp.Size); err != nil {
+		return nil, i18n.Errorf("写入目标文件失败: %w", err)
+	}
+	if err := dstFile.Close(); err != nil {
+		return nil, i18n.Errorf("写入目标文件失败: %w", err)
+	}
+	os.Remove(cpFile)
+	return hash.Sum(nil), nil
+}
+
+// loadCheckpoint 读取断点，断点属于同一个源文件且源文件没有变化、临时文件仍然完整时返回 true
+func loadCheckpoint(cpFile, tmp string, want checkpoint) (checkpoint, bool) {
+	var cp checkpoint
+	data, err := os.ReadFile(cpFile)
+	if err != nil {
+		return cp, false
+	}
+	if err := json.Unmarshal(data, &cp); err != nil {
+		logger.Warnf("复制断点损坏，重新复制: %s", cpFile)
+		return cp, false
+	}
+	if cp.Source != want.Source || cp.Size != want.Size || !cp.ModTime.Equal(want.ModTime) {
+		logger.Infof("源文件已变化，重新复制: %s", want.Source)
+		return cp, false
+	}
+	st, err := os.Stat(tmp)
+	if err != nil || st.Size() < cp.Offset || cp.Offset <= 0 || cp.Offset > cp.Size {
+		return cp, false
+	}
+	return cp, true
+}
+
+// saveCheckpoint 把临时文件中已写入的内容同步到磁盘后再保存断点，断点中的位置之前的内容都是完整的
+func saveCheckpoint(dstFile *os.File, cpFile string, cp checkpoint, hash encoding.BinaryMarshaler) error {
+	// 末尾是空洞时文件长度还没有到达断点的位置
+	if err := dstFile.Truncate(cp.Offset); err != nil {
+		return err
+	}
+	if err := dstFile.Sync(); err != nil {
+		return err
+	}
+	state, err := hash.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	cp.Hash = state
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := cpFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, cpFile)
+}
+
+// zeroBlock 块中的内容是否全为零
+func zeroBlock(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
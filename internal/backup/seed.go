@@ -0,0 +1,151 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/catalog"
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/tier"
+)
+
+// SeedStats 导入已有目标目录的结果
+type SeedStats struct {
+	Imported int // 新写入目录索引的文件数量
+	Existing int // 源文件在目标中已有备份的数量
+	Missing  int // 源文件在目标中没有备份的数量，由之后的扫描复制
+	Mismatch int // 目标中已有同名文件但大小不同的数量，不会覆盖
+}
+
+// NeedsSeed 开启了从目标目录导入且本机还没有写入该目标的状态记录时返回 true
+func (m *Manager) NeedsSeed() bool {
+	if !m.seed || m.IsRemote() {
+		return false
+	}
+	return !m.state.HasTarget(m.hostname, m.targetDir)
+}
+
+// Seed 从已有的目标目录（例如多年来用 rsync 备份的目录）建立目录索引和每个文件的状态记录
+// 目标中的文件全部写入目录索引，目标中已有备份的源文件写入状态记录，
+// 这样首次扫描只需要复制缺失的文件，已有的文件不会重新复制或逐个比较
+func (m *Manager) Seed() (SeedStats, error) {
+	var stats SeedStats
+	start := time.Now()
+	logger.Infof("开始从目标目录导入已有备份: %s", m.targetDir)
+
+	err := filepath.WalkDir(m.targetDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			logger.Errorf("访问路径失败 %s: %v", p, err)
+			return nil
+		}
+		// 跳过程序内部使用的文件和目录，例如快照目录、校验文件目录和冷存储记录文件
+		if p != m.targetDir && strings.HasPrefix(d.Name(), ".neo-") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasSuffix(p, tier.StubSuffix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if e, ok := m.catalog.Get(p); ok && e.Size == info.Size() && e.ModTime.Equal(info.ModTime()) {
+			return nil
+		}
+		sum, err := hashFile(p)
+		if err != nil {
+			logger.Errorf("计算文件哈希失败 %s: %v", p, err)
+			return nil
+		}
+		entry := catalog.Entry{
+			Path:       p,
+			Size:       info.Size(),
+			ModTime:    info.ModTime(),
+			SHA256:     sum,
+			BackupTime: start,
+		}
+		if rel, err := filepath.Rel(m.targetDir, p); err == nil {
+			src := filepath.Join(m.sourceDir, rel)
+			if si, err := os.Stat(src); err == nil && si.Size() == info.Size() {
+				entry.Source = src
+			}
+		}
+		if err := m.catalog.Put(entry); err != nil {
+			return err
+		}
+		stats.Imported++
+		return nil
+	})
+	if err != nil {
+		return stats, i18n.Errorf("导入目标目录失败: %w", err)
+	}
+
+	err = filepath.WalkDir(m.sourceDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		targetPath := m.BuildTargetPath(p)
+		ti, err := os.Stat(targetPath)
+		switch {
+		case err == nil && ti.Size() != info.Size():
+			stats.Mismatch++
+			logger.Warnf("目标中已有同名文件但大小不同，不会覆盖: %s", targetPath)
+		case err == nil || tier.HasStub(targetPath):
+			stats.Existing++
+			return m.state.Put(config.FileState{
+				Host:       m.hostname,
+				Path:       targetPath,
+				Source:     p,
+				Size:       info.Size(),
+				ModTime:    info.ModTime(),
+				BackupTime: start,
+			})
+		default:
+			stats.Missing++
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, i18n.Errorf("扫描源目录失败: %w", err)
+	}
+
+	if err := m.state.Save(); err != nil {
+		return stats, i18n.Errorf("保存备份状态失败: %w", err)
+	}
+	if err := m.catalog.Save(); err != nil {
+		return stats, i18n.Errorf("保存目录索引失败: %w", err)
+	}
+	logger.Infof("导入完成: %s, 写入目录索引: %d, 已有备份: %d, 缺失: %d, 大小不一致: %d",
+		m.targetDir, stats.Imported, stats.Existing, stats.Missing, stats.Mismatch)
+	return stats, nil
+}
+
+func hashFile(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,53 @@
+package backup
+
+import (
+	"os"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// specialKind 返回特殊文件的类型名称
+func specialKind(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		return i18n.T("命名管道")
+	case mode&os.ModeSocket != 0:
+		return i18n.T("套接字")
+	case mode&os.ModeCharDevice != 0:
+		return i18n.T("字符设备")
+	case mode&os.ModeDevice != 0:
+		return i18n.T("块设备")
+	default:
+		return i18n.T("未知类型")
+	}
+}
+
+// backupSpecial 处理命名管道、套接字和设备文件，这些文件没有可以复制的内容
+// 默认跳过并记录日志；special_files 为 recreate 时在目标中重新创建命名管道，以 root 运行时同时重新创建设备文件
+// 套接字只在有进程监听时才有意义，始终跳过
+func (m *Manager) backupSpecial(src, dst string, info os.FileInfo) BackupStatus {
+	kind := specialKind(info.Mode())
+	if m.specialFiles != config.SpecialRecreate || info.Mode()&os.ModeSocket != 0 {
+		logger.Warnf("跳过特殊文件（%s）: %s", kind, src)
+		return Skipped
+	}
+	if info.Mode()&os.ModeDevice != 0 && os.Geteuid() != 0 {
+		logger.Warnf("跳过特殊文件（%s），重新创建设备文件需要 root 权限: %s", kind, src)
+		return Skipped
+	}
+	if m.dryRun.Skip("创建特殊文件（%s）: %s", kind, dst) {
+		return Success
+	}
+	if err := recreateSpecial(dst, info); err != nil {
+		logger.Errorf("创建特殊文件失败（%s）%s: %v", kind, dst, err)
+		return Failed
+	}
+	if err := os.Chtimes(dst, info.ModTime(), info.ModTime()); err != nil {
+		logger.Errorf("设置目标文件时间失败: %v", err)
+	}
+	logger.Infof("特殊文件已创建（%s）: %s -> %s", kind, src, dst)
+	return Success
+}
+
+var errSpecialUnsupported = i18n.Errorf("当前平台不支持创建特殊文件")
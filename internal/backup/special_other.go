@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package backup
+
+import "os"
+
+func recreateSpecial(dst string, info os.FileInfo) error {
+	return errSpecialUnsupported
+}
@@ -0,0 +1,17 @@
+//go:build linux || darwin
+
+package backup
+
+import (
+	"os"
+	"syscall"
+)
+
+// recreateSpecial 按源文件的类型、权限和设备号创建特殊文件
+func recreateSpecial(dst string, info os.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return errSpecialUnsupported
+	}
+	return syscall.Mknod(dst, uint32(st.Mode), int(st.Rdev))
+}
@@ -0,0 +1,32 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"os"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// checkWritten 把刚写入的临时文件同步到磁盘并丢弃缓存后重新读取，与复制时源文件的哈希比较
+// 用于发现 USB 硬盘盒等不可靠的设备上没有报告错误的写入失败
+func checkWritten(tmp string, digest []byte) error {
+	f, err := os.Open(tmp)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := f.Sync(); err != nil {
+		return i18n.Errorf("同步到磁盘失败: %w", err)
+	}
+	dropCache(f)
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return i18n.Errorf("重新读取失败: %w", err)
+	}
+	if !bytes.Equal(hash.Sum(nil), digest) {
+		return i18n.Errorf("写入的内容与源文件不一致")
+	}
+	return nil
+}
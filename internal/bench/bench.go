@@ -0,0 +1,201 @@
+package bench
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// BufferSizes 测试复制速度时依次使用的缓冲区大小
+var BufferSizes = []int{32 << 10, 256 << 10, 1 << 20, 4 << 20}
+
+// errEnough 采样数量或时间已达到上限，停止遍历
+var errEnough = i18n.New("采样完成")
+
+// StatResult 遍历源目录的结果
+type StatResult struct {
+	Files    int           // 读取信息的文件和目录数量
+	Elapsed  time.Duration // 用时
+	Largest  string        // 采样范围内最大的普通文件，用于测试复制速度
+	MaxBytes int64         // 最大文件的大小
+}
+
+// PerSecond 每秒读取文件信息的数量
+func (r StatResult) PerSecond() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Files) / r.Elapsed.Seconds()
+}
+
+// Latency 读取一个文件信息的平均用时
+func (r StatResult) Latency() time.Duration {
+	if r.Files == 0 {
+		return 0
+	}
+	return r.Elapsed / time.Duration(r.Files)
+}
+
+// Stat 遍历源目录并读取每一项的信息，达到 limit 项或 timeout 后停止
+func Stat(dir string, limit int, timeout time.Duration) (StatResult, error) {
+	var r StatResult
+	start := time.Now()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil
+		}
+		info, err := os.Lstat(path)
+		if err != nil {
+			return nil
+		}
+		r.Files++
+		if info.Mode().IsRegular() && info.Size() > r.MaxBytes {
+			r.Largest, r.MaxBytes = path, info.Size()
+		}
+		if r.Files >= limit || time.Since(start) >= timeout {
+			return errEnough
+		}
+		return nil
+	})
+	r.Elapsed = time.Since(start)
+	if err != nil && !errors.Is(err, errEnough) {
+		return r, i18n.Errorf("遍历源目录失败: %w", err)
+	}
+	return r, nil
+}
+
+// CopyResult 一种缓冲区大小的复制结果
+type CopyResult struct {
+	BufferSize int
+	Bytes      int64
+	Elapsed    time.Duration
+}
+
+// BytesPerSecond 每秒复制的字节数
+func (r CopyResult) BytesPerSecond() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / r.Elapsed.Seconds()
+}
+
+// Copy 从 src 读取最多 size 字节写入目标目录中的临时文件，写完后同步到磁盘并删除临时文件
+// src 为空时写入随机数据，只测试目标的写入速度
+func Copy(src, targetDir string, size int64, bufSize int) (CopyResult, error) {
+	r := CopyResult{BufferSize: bufSize}
+	var in io.Reader = newPattern()
+	if src != "" {
+		f, err := os.Open(src)
+		if err != nil {
+			return r, i18n.Errorf("打开源文件失败: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	tmp := filepath.Join(targetDir, fmt.Sprintf(".neo-bench-%d", os.Getpid()))
+	out, err := os.Create(tmp)
+	if err != nil {
+		return r, i18n.Errorf("创建测试文件失败: %w", err)
+	}
+	defer os.Remove(tmp)
+
+	start := time.Now()
+	n, err := io.CopyBuffer(out, io.LimitReader(in, size), make([]byte, bufSize))
+	if err == nil {
+		err = out.Sync()
+	}
+	cerr := out.Close()
+	r.Bytes, r.Elapsed = n, time.Since(start)
+	if err != nil {
+		return r, i18n.Errorf("写入测试文件失败: %w", err)
+	}
+	if cerr != nil {
+		return r, i18n.Errorf("写入测试文件失败: %w", cerr)
+	}
+	return r, nil
+}
+
+// Read 读取 src 的前 size 字节，测试源目录的读取速度，之后的复制测试可能从系统缓存读取
+func Read(src string, size int64) (CopyResult, error) {
+	r := CopyResult{BufferSize: 1 << 20}
+	f, err := os.Open(src)
+	if err != nil {
+		return r, i18n.Errorf("打开源文件失败: %w", err)
+	}
+	defer f.Close()
+	start := time.Now()
+	n, err := io.CopyBuffer(io.Discard, io.LimitReader(f, size), make([]byte, r.BufferSize))
+	r.Bytes, r.Elapsed = n, time.Since(start)
+	if err != nil {
+		return r, i18n.Errorf("读取源文件失败: %w", err)
+	}
+	return r, nil
+}
+
+// pattern 重复输出一段随机数据，避免生成随机数的开销计入写入速度，也不会被文件系统压缩
+type pattern struct {
+	buf []byte
+	off int
+}
+
+func newPattern() *pattern {
+	p := &pattern{buf: make([]byte, 1<<20)}
+	rand.Read(p.buf)
+	return p
+}
+
+func (p *pattern) Read(b []byte) (int, error) {
+	n := copy(b, p.buf[p.off:])
+	p.off = (p.off + n) % len(p.buf)
+	return n, nil
+}
+
+// HashResult 一种哈希算法的计算速度
+type HashResult struct {
+	Algorithm string
+	Bytes     int64
+	Elapsed   time.Duration
+}
+
+// BytesPerSecond 每秒计算的字节数
+func (r HashResult) BytesPerSecond() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / r.Elapsed.Seconds()
+}
+
+// Hash 在内存中计算 sha256 和 md5，测试单个 CPU 核心的哈希速度
+func Hash(size int64) []HashResult {
+	buf := make([]byte, 1<<20)
+	rand.Read(buf)
+	var results []HashResult
+	for _, alg := range []struct {
+		name string
+		new  func() hash.Hash
+	}{{"sha256", sha256.New}, {"md5", md5.New}} {
+		h := alg.new()
+		start := time.Now()
+		var n int64
+		for n < size {
+			h.Write(buf)
+			n += int64(len(buf))
+		}
+		h.Sum(nil)
+		results = append(results, HashResult{Algorithm: alg.name, Bytes: n, Elapsed: time.Since(start)})
+	}
+	return results
+}
@@ -0,0 +1,73 @@
+package bisync
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// FileState 上次同步完成时某一侧文件的大小和修改时间
+type FileState struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// PathState 上次同步完成时两侧的状态，用于判断之后哪一侧发生了变化
+type PathState struct {
+	Left  FileState `json:"left"`
+	Right FileState `json:"right"`
+}
+
+// State 持久化的同步状态，以相对路径为键
+type State struct {
+	Left     string               `json:"left"`
+	Right    string               `json:"right"`
+	LastSync time.Time            `json:"last_sync"`
+	Paths    map[string]PathState `json:"paths"`
+}
+
+// StateFile 每个同步任务在配置目录下使用独立的状态文件，文件名由两侧路径决定
+func StateFile(configDir string, cfg config.SyncConfig) string {
+	sum := sha1.Sum([]byte(filepath.Clean(cfg.Left) + "\x00" + filepath.Clean(cfg.Right)))
+	return filepath.Join(configDir, ".sync-"+hex.EncodeToString(sum[:6]))
+}
+
+func loadState(file string) (*State, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &State{Paths: make(map[string]PathState)}, nil
+		}
+		return nil, i18n.Errorf("读取同步状态失败: %w", err)
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, i18n.Errorf("解析同步状态失败: %w", err)
+	}
+	if state.Paths == nil {
+		state.Paths = make(map[string]PathState)
+	}
+	return &state, nil
+}
+
+// save 先写临时文件再替换，避免中途崩溃导致状态丢失后把所有文件都当作冲突
+func (s *State) save(file string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return i18n.Errorf("序列化同步状态失败: %w", err)
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return i18n.Errorf("保存同步状态失败: %w", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return i18n.Errorf("保存同步状态失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,531 @@
+package bisync
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/dryrun"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+	"github.com/lucasrui/neo-nas/internal/mount"
+	"github.com/lucasrui/neo-nas/internal/priority"
+)
+
+// logger bisync 模块的日志
+var logger = logging.New("bisync")
+
+// TrashDir 被同步删除的文件移动到各侧根目录下的该目录中，而不是直接删除
+const TrashDir = ".neo-nas-trash"
+
+// FAT/exFAT 的修改时间精度为 2 秒，比较时允许这个误差
+const mtimeTolerance = 2 * time.Second
+
+// 已同步文件少于该数量时不做批量删除检查
+const minGuardedPaths = 10
+
+type actionKind int
+
+const (
+	actCopyToRight actionKind = iota
+	actCopyToLeft
+	actDeleteLeft
+	actDeleteRight
+	actConflict
+	actRecord // 两侧相同但状态缺失或过期，只更新状态
+	actForget // 两侧都已不存在，删除状态
+)
+
+type action struct {
+	kind actionKind
+	rel  string
+}
+
+// Task 一个双向同步任务，定时比较两侧与上次同步的状态，把变化同步到另一侧
+type Task struct {
+	left         string
+	right        string
+	policy       string
+	interval     time.Duration
+	maxDeletePct int
+	network      bool
+	probeTimeout time.Duration
+	stateFile    string
+	trashDays    int
+	stopChan     chan struct{}
+	done         chan struct{} // 定时协程退出后关闭
+}
+
+func NewTask(cfg config.SyncConfig, stateFile string) (*Task, error) {
+	if cfg.Left == "" || cfg.Right == "" {
+		return nil, i18n.Errorf("同步目录不能为空")
+	}
+	left, right := filepath.Clean(cfg.Left), filepath.Clean(cfg.Right)
+	if left == right || strings.HasPrefix(left, right+string(filepath.Separator)) || strings.HasPrefix(right, left+string(filepath.Separator)) {
+		return nil, i18n.Errorf("同步目录不能相同或互相包含: %s, %s", left, right)
+	}
+	t := &Task{
+		left:         left,
+		right:        right,
+		policy:       cfg.ConflictPolicy,
+		interval:     time.Duration(cfg.IntervalSeconds) * time.Second,
+		maxDeletePct: cfg.MaxDeletePct,
+		network:      cfg.NetworkSource,
+		probeTimeout: time.Duration(cfg.ProbeTimeout) * time.Second,
+		stateFile:    stateFile,
+		trashDays:    cfg.TrashDays,
+		stopChan:     make(chan struct{}),
+	}
+	switch t.policy {
+	case "":
+		t.policy = config.ConflictKeepBoth
+	case config.ConflictKeepBoth, config.ConflictNewerWins:
+	default:
+		return nil, i18n.Errorf("未知的冲突处理策略: %s", cfg.ConflictPolicy)
+	}
+	if t.interval <= 0 {
+		t.interval = 60 * time.Second
+	}
+	if t.maxDeletePct <= 0 {
+		t.maxDeletePct = 50
+	}
+	if t.probeTimeout <= 0 {
+		t.probeTimeout = 10 * time.Second
+	}
+	return t, nil
+}
+
+func (t *Task) Start() {
+	t.done = make(chan struct{})
+	go func() {
+		priority.Lower()
+		defer close(t.done)
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+		t.runLogged()
+		for {
+			select {
+			case <-ticker.C:
+				t.runLogged()
+			case <-t.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止定时执行，正在进行的同步完成后返回
+func (t *Task) Stop() {
+	close(t.stopChan)
+	if t.done != nil {
+		<-t.done
+	}
+	logger.Infof("停止同步任务: %s <-> %s", t.left, t.right)
+}
+
+func (t *Task) runLogged() {
+	if err := t.Run(); err != nil {
+		logger.With(logging.KeyTask, t.left+" <-> "+t.right, logging.KeyStatus, logging.StatusFailed, logging.KeyError, err.Error()).
+			Errorf("同步失败 %s <-> %s: %v", t.left, t.right, err)
+	}
+}
+
+// Run 执行一次同步
+func (t *Task) Run() error {
+	state, err := loadState(t.stateFile)
+	if err != nil {
+		return err
+	}
+	if err := t.checkAvailable(t.left, state); err != nil {
+		return err
+	}
+	if err := t.checkAvailable(t.right, state); err != nil {
+		return err
+	}
+
+	leftFiles, err := scan(t.left)
+	if err != nil {
+		return i18n.Errorf("扫描 %s 失败: %w", t.left, err)
+	}
+	rightFiles, err := scan(t.right)
+	if err != nil {
+		return i18n.Errorf("扫描 %s 失败: %w", t.right, err)
+	}
+
+	actions := plan(leftFiles, rightFiles, state)
+	if err := t.checkDeletions(actions, state); err != nil {
+		return err
+	}
+
+	if dryrun.Enabled() {
+		t.simulate(actions)
+		return nil
+	}
+
+	state.Left, state.Right = t.left, t.right
+	start := time.Now()
+	var copied, deleted, conflicts, failed int
+	for _, a := range actions {
+		var err error
+		switch a.kind {
+		case actCopyToRight:
+			err = t.transfer(state, a.rel, true)
+			copied++
+		case actCopyToLeft:
+			err = t.transfer(state, a.rel, false)
+			copied++
+		case actDeleteLeft:
+			err = t.remove(state, t.left, a.rel)
+			deleted++
+		case actDeleteRight:
+			err = t.remove(state, t.right, a.rel)
+			deleted++
+		case actConflict:
+			err = t.resolveConflict(state, a.rel, leftFiles[a.rel], rightFiles[a.rel])
+			conflicts++
+		case actRecord:
+			// 首次同步时大小和时间相同不代表内容相同，需要逐字节比较
+			if _, synced := state.Paths[a.rel]; !synced && !sameContent(filepath.Join(t.left, filepath.FromSlash(a.rel)), filepath.Join(t.right, filepath.FromSlash(a.rel))) {
+				err = t.resolveConflict(state, a.rel, leftFiles[a.rel], rightFiles[a.rel])
+				conflicts++
+				break
+			}
+			state.Paths[a.rel] = PathState{Left: leftFiles[a.rel], Right: rightFiles[a.rel]}
+		case actForget:
+			delete(state.Paths, a.rel)
+		}
+		if err != nil {
+			failed++
+			logger.Errorf("同步文件失败 %s: %v", a.rel, err)
+		}
+	}
+
+	state.LastSync = time.Now()
+	if err := state.save(t.stateFile); err != nil {
+		return err
+	}
+	if copied+deleted+conflicts+failed > 0 {
+		status := logging.StatusSuccess
+		if failed > 0 {
+			status = logging.StatusFailed
+		}
+		logger.With(logging.KeyTask, t.left+" <-> "+t.right, logging.KeyDuration, time.Since(start), logging.KeyStatus, status,
+			"copied", copied, "deleted", deleted, "conflicts", conflicts, "failed", failed).
+			Infof("同步完成: %s <-> %s, 复制: %d, 删除: %d, 冲突: %d, 失败: %d", t.left, t.right, copied, deleted, conflicts, failed)
+	}
+	t.purgeExpiredTrash()
+	return nil
+}
+
+// simulate 演练模式下只记录同步计划，不修改两侧的文件和同步状态
+func (t *Task) simulate(actions []action) {
+	for _, a := range actions {
+		switch a.kind {
+		case actCopyToRight:
+			dryrun.Skip("同步文件: %s -> %s", filepath.Join(t.left, filepath.FromSlash(a.rel)), filepath.Join(t.right, filepath.FromSlash(a.rel)))
+		case actCopyToLeft:
+			dryrun.Skip("同步文件: %s -> %s", filepath.Join(t.right, filepath.FromSlash(a.rel)), filepath.Join(t.left, filepath.FromSlash(a.rel)))
+		case actDeleteLeft:
+			dryrun.Skip("同步删除，移动到回收目录: %s", filepath.Join(t.left, filepath.FromSlash(a.rel)))
+		case actDeleteRight:
+			dryrun.Skip("同步删除，移动到回收目录: %s", filepath.Join(t.right, filepath.FromSlash(a.rel)))
+		case actConflict:
+			dryrun.Skip("处理冲突 (%s): %s", t.policy, a.rel)
+		}
+	}
+	expired, err := t.ExpiredTrash(time.Now())
+	if err == nil {
+		for _, b := range expired {
+			dryrun.Skip("清理回收目录: %s, %d 个文件", b.Dir, b.Files)
+		}
+	}
+}
+
+// checkAvailable 一侧不存在、共享不可用或突然变空时中止同步，避免把另一侧的文件全部删除
+func (t *Task) checkAvailable(root string, state *State) error {
+	if t.network {
+		res, err := mount.ProbeShare(root, t.probeTimeout)
+		if err != nil {
+			return i18n.Errorf("目录暂时不可用: %w", err)
+		}
+		if !res.Exists {
+			return i18n.Errorf("目录不存在: %s", root)
+		}
+		if res.Empty && len(state.Paths) > 0 {
+			return i18n.Errorf("目录为空但上次同步时有 %d 个文件，可能未挂载: %s", len(state.Paths), root)
+		}
+		return nil
+	}
+	info, err := os.Stat(root)
+	if err != nil || !info.IsDir() {
+		return i18n.Errorf("目录不存在: %s", root)
+	}
+	return nil
+}
+
+// checkDeletions 计划删除的文件比例过高时中止，需要用户确认后手动处理
+func (t *Task) checkDeletions(actions []action, state *State) error {
+	if len(state.Paths) < minGuardedPaths {
+		return nil
+	}
+	deletions := 0
+	for _, a := range actions {
+		if a.kind == actDeleteLeft || a.kind == actDeleteRight {
+			deletions++
+		}
+	}
+	if deletions*100 > len(state.Paths)*t.maxDeletePct {
+		return i18n.Errorf("计划删除 %d 个文件，超过已同步文件数 %d 的 %d%%，已中止同步", deletions, len(state.Paths), t.maxDeletePct)
+	}
+	return nil
+}
+
+// plan 与上次同步的状态比较，确定每个路径的处理方式
+func plan(left, right map[string]FileState, state *State) []action {
+	paths := make(map[string]bool)
+	for p := range left {
+		paths[p] = true
+	}
+	for p := range right {
+		paths[p] = true
+	}
+	for p := range state.Paths {
+		paths[p] = true
+	}
+
+	var actions []action
+	for p := range paths {
+		l, inLeft := left[p]
+		r, inRight := right[p]
+		prev, synced := state.Paths[p]
+		leftChanged := !synced || !inLeft || !same(l, prev.Left)
+		rightChanged := !synced || !inRight || !same(r, prev.Right)
+
+		switch {
+		case !inLeft && !inRight:
+			if synced {
+				actions = append(actions, action{actForget, p})
+			}
+		case inLeft && !inRight:
+			if synced && !leftChanged {
+				// 右侧删除，左侧未修改
+				actions = append(actions, action{actDeleteLeft, p})
+			} else {
+				// 新文件，或右侧删除但左侧已修改，保留修改
+				actions = append(actions, action{actCopyToRight, p})
+			}
+		case !inLeft && inRight:
+			if synced && !rightChanged {
+				actions = append(actions, action{actDeleteRight, p})
+			} else {
+				actions = append(actions, action{actCopyToLeft, p})
+			}
+		default:
+			switch {
+			case same(l, r):
+				if leftChanged || rightChanged {
+					actions = append(actions, action{actRecord, p})
+				}
+			case !leftChanged && !rightChanged:
+			case leftChanged && !rightChanged:
+				actions = append(actions, action{actCopyToRight, p})
+			case !leftChanged && rightChanged:
+				actions = append(actions, action{actCopyToLeft, p})
+			default:
+				actions = append(actions, action{actConflict, p})
+			}
+		}
+	}
+	return actions
+}
+
+func same(a, b FileState) bool {
+	if a.Size != b.Size {
+		return false
+	}
+	diff := a.ModTime.Sub(b.ModTime)
+	return diff < mtimeTolerance && diff > -mtimeTolerance
+}
+
+func sameContent(a, b string) bool {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false
+	}
+	defer fa.Close()
+	fb, err := os.Open(b)
+	if err != nil {
+		return false
+	}
+	defer fb.Close()
+
+	bufA, bufB := make([]byte, 64*1024), make([]byte, 64*1024)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if na != nb || !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == errA
+		}
+		if errA != nil || errB != nil {
+			return false
+		}
+	}
+}
+
+// resolveConflict 两侧都修改了同一文件
+func (t *Task) resolveConflict(state *State, rel string, l, r FileState) error {
+	leftNewer := !l.ModTime.Before(r.ModTime)
+	if t.policy == config.ConflictNewerWins {
+		logger.Warnf("同步冲突，保留较新的版本: %s", rel)
+		return t.transfer(state, rel, leftNewer)
+	}
+
+	// keep_both: 较旧的版本在原侧加后缀重命名并复制到另一侧，较新的版本覆盖原路径
+	olderRoot := t.right
+	if !leftNewer {
+		olderRoot = t.left
+	}
+	renamed := conflictName(rel, time.Now())
+	if err := os.Rename(filepath.Join(olderRoot, filepath.FromSlash(rel)), filepath.Join(olderRoot, filepath.FromSlash(renamed))); err != nil {
+		return i18n.Errorf("重命名冲突文件失败: %w", err)
+	}
+	logger.Warnf("同步冲突，两个版本都保留: %s, 较旧的版本重命名为 %s", rel, renamed)
+	if err := t.transfer(state, renamed, olderRoot == t.left); err != nil {
+		return err
+	}
+	return t.transfer(state, rel, leftNewer)
+}
+
+// conflictName 例如 a/report.docx -> a/report.conflict-20240501-150405.docx
+func conflictName(rel string, now time.Time) string {
+	ext := filepath.Ext(rel)
+	return strings.TrimSuffix(rel, ext) + ".conflict-" + now.Format("20060102-150405") + ext
+}
+
+// transfer 复制文件到另一侧并记录两侧的状态
+func (t *Task) transfer(state *State, rel string, toRight bool) error {
+	src, dst := t.left, t.right
+	if !toRight {
+		src, dst = t.right, t.left
+	}
+	srcPath := filepath.Join(src, filepath.FromSlash(rel))
+	dstPath := filepath.Join(dst, filepath.FromSlash(rel))
+	if err := copyFile(srcPath, dstPath); err != nil {
+		return err
+	}
+
+	srcInfo, err := os.Stat(srcPath)
+	if err != nil {
+		return err
+	}
+	dstInfo, err := os.Stat(dstPath)
+	if err != nil {
+		return err
+	}
+	srcState := FileState{Size: srcInfo.Size(), ModTime: srcInfo.ModTime()}
+	dstState := FileState{Size: dstInfo.Size(), ModTime: dstInfo.ModTime()}
+	if toRight {
+		state.Paths[rel] = PathState{Left: srcState, Right: dstState}
+	} else {
+		state.Paths[rel] = PathState{Left: dstState, Right: srcState}
+	}
+	logger.Infof("同步文件: %s -> %s", srcPath, dstPath)
+	return nil
+}
+
+// remove 将文件移动到该侧的回收目录，保留目录结构
+func (t *Task) remove(state *State, root, rel string) error {
+	src := filepath.Join(root, filepath.FromSlash(rel))
+	dst := filepath.Join(root, TrashDir, time.Now().Format("20060102-150405"), filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return i18n.Errorf("创建回收目录失败: %w", err)
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return i18n.Errorf("移动到回收目录失败: %w", err)
+	}
+	delete(state.Paths, rel)
+	logger.Infof("同步删除，已移动到回收目录: %s -> %s", src, dst)
+	return nil
+}
+
+// scan 列出目录下所有普通文件，跳过回收目录和同步临时文件
+func scan(root string) (map[string]FileState, error) {
+	files := make(map[string]FileState)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 扫描不完整时不能继续，否则缺失的文件会被当作已删除
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == TrashDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".neo-sync-") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = FileState{Size: info.Size(), ModTime: info.ModTime()}
+		return nil
+	})
+	return files, err
+}
+
+// copyFile 先写入目标目录下的临时文件，完成后替换，保留权限和修改时间
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return i18n.Errorf("打开源文件失败: %w", err)
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return i18n.Errorf("获取源文件信息失败: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return i18n.Errorf("创建目标目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".neo-sync-*")
+	if err != nil {
+		return i18n.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return i18n.Errorf("复制文件内容失败: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return i18n.Errorf("同步临时文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return i18n.Errorf("关闭临时文件失败: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		logger.Errorf("设置目标文件权限失败: %v", err)
+	}
+	if err := os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime()); err != nil {
+		logger.Errorf("设置目标文件时间失败: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return i18n.Errorf("替换目标文件失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package bisync
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// TrashBatch 回收目录中一次同步删除的文件，按删除时间分目录存放
+type TrashBatch struct {
+	Dir   string
+	Time  time.Time
+	Files int
+	Size  int64
+}
+
+// ExpiredTrash 返回两侧回收目录中超过保留天数的批次，未设置保留天数时返回空
+func (t *Task) ExpiredTrash(now time.Time) ([]TrashBatch, error) {
+	if t.trashDays <= 0 {
+		return nil, nil
+	}
+	cutoff := now.AddDate(0, 0, -t.trashDays)
+	var expired []TrashBatch
+	for _, root := range []string{t.left, t.right} {
+		batches, err := listTrash(root)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range batches {
+			if b.Time.Before(cutoff) {
+				expired = append(expired, b)
+			}
+		}
+	}
+	return expired, nil
+}
+
+// PurgeTrash 删除回收目录中的批次
+func (t *Task) PurgeTrash(batches []TrashBatch) error {
+	for _, b := range batches {
+		if err := os.RemoveAll(b.Dir); err != nil {
+			return i18n.Errorf("清理回收目录失败 %s: %w", b.Dir, err)
+		}
+		logger.Infof("已清理回收目录: %s, %d 个文件", b.Dir, b.Files)
+	}
+	return nil
+}
+
+// purgeExpiredTrash 同步完成后清理超过保留天数的回收目录，失败不影响同步结果
+func (t *Task) purgeExpiredTrash() {
+	expired, err := t.ExpiredTrash(time.Now())
+	if err == nil {
+		err = t.PurgeTrash(expired)
+	}
+	if err != nil {
+		logger.Errorf("清理回收目录失败 %s <-> %s: %v", t.left, t.right, err)
+	}
+}
+
+// listTrash 列出回收目录中的批次，目录名不是删除时间的跳过
+func listTrash(root string) ([]TrashBatch, error) {
+	dir := filepath.Join(root, TrashDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, i18n.Errorf("读取回收目录失败: %w", err)
+	}
+	var batches []TrashBatch
+	for _, e := range entries {
+		t, err := time.ParseInLocation("20060102-150405", e.Name(), time.Local)
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		b := TrashBatch{Dir: filepath.Join(dir, e.Name()), Time: t}
+		filepath.WalkDir(b.Dir, func(p string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				if info, err := d.Info(); err == nil {
+					b.Files++
+					b.Size += info.Size()
+				}
+			}
+			return nil
+		})
+		batches = append(batches, b)
+	}
+	sort.Slice(batches, func(i, j int) bool { return batches[i].Time.Before(batches[j].Time) })
+	return batches, nil
+}
@@ -0,0 +1,222 @@
+package browse
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/httpd"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+	"github.com/lucasrui/neo-nas/internal/remote"
+	"github.com/lucasrui/neo-nas/internal/tier"
+)
+
+// logger browse 模块的日志
+var logger = logging.New("browse")
+
+// Prefix 文件浏览的 URL 前缀
+const Prefix = "/browse/"
+
+// Browser 只读文件浏览，支持目录列表和文件下载（含断点续传的 Range 请求）
+type Browser struct {
+	roots  []httpd.Root
+	remote remote.Options // 从冷存储取回文件时使用
+}
+
+func NewBrowser(roots []httpd.Root, opts remote.Options) *Browser {
+	return &Browser{roots: roots, remote: opts}
+}
+
+// Register 在内置 HTTP 服务上注册路由
+func (b *Browser) Register(h *httpd.Server, cfg config.BrowserConfig) {
+	h.Handle(Prefix, httpd.BasicAuth(cfg.Username, cfg.Password, "neo-nas", b))
+	logger.Infof("文件浏览已启用，共 %d 个目录", len(b.roots))
+}
+
+type listItem struct {
+	Name    string
+	Href    string
+	IsDir   bool
+	Size    int64
+	ModTime time.Time
+	Cold    bool // 已迁移到冷存储，下载时自动取回
+}
+
+type listPage struct {
+	Title  string
+	Parent string
+	Items  []listItem
+}
+
+var listTemplate = template.Must(template.New("list").Funcs(template.FuncMap{
+	"size": formatSize,
+	"t":    i18n.T,
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width">
+<title>{{.Title}}</title>
+<style>body{font-family:sans-serif;margin:1em}td{padding:2px 12px}td.n{text-align:right}</style>
+</head><body>
+<h3>{{.Title}}</h3>
+<table>
+{{if .Parent}}<tr><td><a href="{{.Parent}}">../</a></td><td></td><td></td></tr>{{end}}
+{{range .Items}}<tr><td><a href="{{.Href}}">{{.Name}}{{if .IsDir}}/{{end}}</a>{{if .Cold}} ({{t "冷存储"}}){{end}}</td><td class="n">{{if not .IsDir}}{{size .Size}}{{end}}</td><td>{{if not .ModTime.IsZero}}{{.ModTime.Format "2006-01-02 15:04:05"}}{{end}}</td></tr>
+{{end}}</table>
+</body></html>
+`))
+
+func (b *Browser) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rel := strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(r.URL.Path, Prefix)), "/")
+	if rel == "" {
+		b.serveRoots(w)
+		return
+	}
+
+	for _, part := range strings.Split(rel, "/") {
+		if httpd.Hidden(part) {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	name, sub, _ := strings.Cut(rel, "/")
+	root, ok := b.findRoot(name)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	fullPath, ok := httpd.Resolve(root.Path, sub)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil && tier.HasStub(fullPath) {
+		// 已迁移到冷存储的文件先取回再下载
+		if err := tier.Recall(fullPath, b.remote); err != nil {
+			logger.Errorf("从冷存储取回文件失败 %s: %v", fullPath, err)
+			http.Error(w, i18n.T("从冷存储取回文件失败"), http.StatusServiceUnavailable)
+			return
+		}
+		info, err = os.Stat(fullPath)
+	}
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if !info.IsDir() {
+		f, err := os.Open(fullPath)
+		if err != nil {
+			http.Error(w, i18n.T("无法读取文件"), http.StatusForbidden)
+			return
+		}
+		defer f.Close()
+		// ServeContent 会处理 Range、If-Modified-Since 等请求头
+		w.Header().Set("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(info.Name()))
+		http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+		return
+	}
+
+	// 目录链接统一以 / 结尾，方便使用相对路径
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+		return
+	}
+	b.serveDir(w, rel, fullPath)
+}
+
+func (b *Browser) serveRoots(w http.ResponseWriter) {
+	page := listPage{Title: "neo-nas"}
+	for _, root := range b.roots {
+		page.Items = append(page.Items, listItem{Name: root.Name, Href: url.PathEscape(root.Name) + "/", IsDir: true})
+	}
+	render(w, page)
+}
+
+func (b *Browser) serveDir(w http.ResponseWriter, rel, fullPath string) {
+	entries, err := os.ReadDir(fullPath)
+	if err != nil {
+		http.Error(w, i18n.T("无法读取目录"), http.StatusForbidden)
+		return
+	}
+
+	page := listPage{Title: "/" + rel, Parent: "../"}
+	for _, e := range entries {
+		if httpd.Hidden(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		item := listItem{
+			Name:    e.Name(),
+			Href:    url.PathEscape(e.Name()),
+			IsDir:   info.IsDir(),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		}
+		if item.IsDir {
+			item.Href += "/"
+		}
+		if name, ok := strings.CutSuffix(e.Name(), tier.StubSuffix); ok && !item.IsDir {
+			stub, err := tier.ReadStub(filepath.Join(fullPath, name))
+			if err != nil {
+				continue
+			}
+			item.Name, item.Href, item.Cold = name, url.PathEscape(name), true
+			item.Size, item.ModTime = stub.Size, stub.ModTime
+		}
+		page.Items = append(page.Items, item)
+	}
+	sort.Slice(page.Items, func(i, j int) bool {
+		if page.Items[i].IsDir != page.Items[j].IsDir {
+			return page.Items[i].IsDir
+		}
+		return page.Items[i].Name < page.Items[j].Name
+	})
+	render(w, page)
+}
+
+func (b *Browser) findRoot(name string) (httpd.Root, bool) {
+	for _, root := range b.roots {
+		if root.Name == name {
+			return root, true
+		}
+	}
+	return httpd.Root{}, false
+}
+
+func render(w http.ResponseWriter, page listPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := listTemplate.Execute(w, page); err != nil {
+		logger.Errorf("渲染目录列表失败: %v", err)
+	}
+}
+
+func formatSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(size)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
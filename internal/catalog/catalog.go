@@ -0,0 +1,325 @@
+package catalog
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// Entry 目标目录中一个已备份文件的记录
+type Entry struct {
+	Path       string    `json:"path"`             // 目标文件绝对路径
+	Source     string    `json:"source,omitempty"` // 源文件路径（客户端上传时为客户端路径）
+	Size       int64     `json:"size"`             // 文件大小
+	ModTime    time.Time `json:"mod_time"`         // 源文件修改时间
+	SHA256     string    `json:"sha256,omitempty"` // 文件内容哈希
+	BackupTime time.Time `json:"backup_time"`      // 备份时间
+}
+
+// 日志中的一条记录，put 为新增或更新，del 为删除
+type record struct {
+	Op    string `json:"op"`
+	Entry *Entry `json:"entry,omitempty"`
+	Path  string `json:"path,omitempty"`
+}
+
+// Catalog 记录所有目标文件的大小、时间和哈希，用于校验和去重
+// 持久化为追加写入的 JSON Lines 日志，记录数膨胀后自动压缩
+type Catalog struct {
+	file    string
+	mu      sync.RWMutex
+	entries map[string]*Entry
+	hashes  map[string]map[string]struct{} // 哈希到路径的索引，用于按内容查找已备份的文件
+	sizes   map[int64]int                  // 每种大小的记录数，计算哈希之前先排除不可能重复的文件
+	journal *os.File                       // 为 nil 表示以只读方式打开，修改只保存在内存中
+	records int
+}
+
+// Open 加载目录索引文件，不存在时创建空索引
+func Open(file string) (*Catalog, error) {
+	c := &Catalog{
+		file:    file,
+		entries: make(map[string]*Entry),
+		hashes:  make(map[string]map[string]struct{}),
+		sizes:   make(map[int64]int),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	journal, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, i18n.Errorf("打开目录索引失败: %w", err)
+	}
+	c.journal = journal
+	return c, nil
+}
+
+// OpenReadOnly 加载目录索引但不写入文件，修改只保存在内存中
+// 用于没有持有实例锁的进程，避免与正在运行的实例同时追加记录，或者在关闭时重写索引丢掉其记录
+func OpenReadOnly(file string) (*Catalog, error) {
+	c := &Catalog{
+		file:    file,
+		entries: make(map[string]*Entry),
+		hashes:  make(map[string]map[string]struct{}),
+		sizes:   make(map[int64]int),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Catalog) load() error {
+	f, err := os.Open(c.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return i18n.Errorf("读取目录索引失败: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// 崩溃时最后一行可能不完整，忽略即可
+			continue
+		}
+		c.records++
+		switch rec.Op {
+		case "put":
+			if rec.Entry != nil {
+				c.set(rec.Entry)
+			}
+		case "del":
+			c.remove(rec.Path)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return i18n.Errorf("解析目录索引失败: %w", err)
+	}
+	return nil
+}
+
+// Get 查询目标文件的记录
+func (c *Catalog) Get(path string) (Entry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e, ok := c.entries[filepath.Clean(path)]
+	if !ok {
+		return Entry{}, false
+	}
+	return *e, true
+}
+
+// Put 新增或更新一条记录，并立即追加到日志
+func (c *Catalog) Put(e Entry) error {
+	e.Path = filepath.Clean(e.Path)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(&e)
+	return c.append(record{Op: "put", Entry: &e})
+}
+
+// Delete 删除一条记录
+func (c *Catalog) Delete(path string) error {
+	path = filepath.Clean(path)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[path]; !ok {
+		return nil
+	}
+	c.remove(path)
+	return c.append(record{Op: "del", Path: path})
+}
+
+// set 写入一条记录并更新索引，调用方持有写锁
+func (c *Catalog) set(e *Entry) {
+	c.remove(e.Path)
+	c.entries[e.Path] = e
+	c.sizes[e.Size]++
+	if e.SHA256 != "" {
+		if c.hashes[e.SHA256] == nil {
+			c.hashes[e.SHA256] = make(map[string]struct{})
+		}
+		c.hashes[e.SHA256][e.Path] = struct{}{}
+	}
+}
+
+// remove 删除一条记录并更新索引，调用方持有写锁
+func (c *Catalog) remove(path string) {
+	e, ok := c.entries[path]
+	if !ok {
+		return
+	}
+	delete(c.entries, path)
+	if c.sizes[e.Size]--; c.sizes[e.Size] <= 0 {
+		delete(c.sizes, e.Size)
+	}
+	if paths := c.hashes[e.SHA256]; paths != nil {
+		delete(paths, path)
+		if len(paths) == 0 {
+			delete(c.hashes, e.SHA256)
+		}
+	}
+}
+
+// HasSize 是否有任何记录的大小等于 size
+func (c *Catalog) HasSize(size int64) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.sizes[size] > 0
+}
+
+// FindHash 返回指定目录下内容哈希和大小都相同的记录，按路径排序
+func (c *Catalog) FindHash(root, sum string, size int64) []Entry {
+	root = filepath.Clean(root)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var result []Entry
+	for path := range c.hashes[sum] {
+		e := c.entries[path]
+		if e.Size == size && (path == root || strings.HasPrefix(path, root+string(filepath.Separator))) {
+			result = append(result, *e)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result
+}
+
+// Entries 返回指定目录下的所有记录，按路径排序
+func (c *Catalog) Entries(root string) []Entry {
+	root = filepath.Clean(root)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var result []Entry
+	for path, e := range c.entries {
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+			result = append(result, *e)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result
+}
+
+func (c *Catalog) append(rec record) error {
+	if c.journal == nil {
+		return nil
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return i18n.Errorf("序列化目录索引失败: %w", err)
+	}
+	if _, err := c.journal.Write(append(data, '\n')); err != nil {
+		return i18n.Errorf("写入目录索引失败: %w", err)
+	}
+	c.records++
+	return nil
+}
+
+// Save 将日志同步到磁盘，日志记录数远大于实际条目数时重写压缩
+func (c *Catalog) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.journal == nil {
+		return nil
+	}
+	if c.records > 2*len(c.entries)+1024 {
+		return c.compact()
+	}
+	if err := c.journal.Sync(); err != nil {
+		return i18n.Errorf("同步目录索引失败: %w", err)
+	}
+	return nil
+}
+
+// compact 将当前条目写入临时文件后替换日志
+func (c *Catalog) compact() error {
+	tmpFile := c.file + ".tmp"
+	f, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return i18n.Errorf("创建目录索引临时文件失败: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range c.entries {
+		if err := enc.Encode(record{Op: "put", Entry: e}); err != nil {
+			f.Close()
+			return i18n.Errorf("写入目录索引失败: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return i18n.Errorf("写入目录索引失败: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return i18n.Errorf("同步目录索引失败: %w", err)
+	}
+	f.Close()
+
+	if err := os.Rename(tmpFile, c.file); err != nil {
+		return i18n.Errorf("替换目录索引失败: %w", err)
+	}
+	journal, err := os.OpenFile(c.file, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return i18n.Errorf("打开目录索引失败: %w", err)
+	}
+	c.journal.Close()
+	c.journal = journal
+	c.records = len(c.entries)
+	return nil
+}
+
+// Close 同步并关闭日志文件
+func (c *Catalog) Close() error {
+	if err := c.Save(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.journal == nil {
+		return nil
+	}
+	return c.journal.Close()
+}
+
+// HashFile 计算文件的 SHA-256
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// HasEntries 指定目录下是否有任何记录
+func (c *Catalog) HasEntries(root string) bool {
+	root = filepath.Clean(root)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for path := range c.entries {
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
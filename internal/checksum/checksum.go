@@ -0,0 +1,324 @@
+package checksum
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/catalog"
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+	"github.com/lucasrui/neo-nas/internal/tier"
+)
+
+// logger checksum 模块的日志
+var logger = logging.New("checksum")
+
+// RunDir 按次写入的校验文件存放在目标目录下的该目录中
+const RunDir = ".neo-checksums"
+
+// 校验文件的作用范围
+const (
+	ScopeDir = "dir" // 每个目录一个校验文件，覆盖该目录中的文件
+	ScopeRun = "run" // 每次扫描一个校验文件，覆盖本次备份的文件
+)
+
+// Writer 记录本次扫描备份的文件，扫描完成后写入与 sha256sum/md5sum 兼容的校验文件
+type Writer struct {
+	target  string
+	algo    string
+	scope   string
+	catalog *catalog.Catalog
+	mu      sync.Mutex
+	changed map[string]string // 本次备份的文件路径 -> SHA256
+}
+
+// New 根据配置创建校验文件写入器，未配置算法时返回 nil
+func New(cfg config.ChecksumConfig, target string, cat *catalog.Catalog) (*Writer, error) {
+	if cfg.Algorithm == "" {
+		return nil, nil
+	}
+	algo := strings.ToLower(cfg.Algorithm)
+	if algo != "sha256" && algo != "md5" {
+		return nil, i18n.Errorf("不支持的校验算法: %s", cfg.Algorithm)
+	}
+	scope := cfg.Scope
+	if scope == "" {
+		scope = ScopeDir
+	}
+	if scope != ScopeDir && scope != ScopeRun {
+		return nil, i18n.Errorf("不支持的校验文件范围: %s", cfg.Scope)
+	}
+	return &Writer{
+		target:  target,
+		algo:    algo,
+		scope:   scope,
+		catalog: cat,
+		changed: make(map[string]string),
+	}, nil
+}
+
+// IsSumsFile 判断文件是否为程序写入的校验文件
+func IsSumsFile(name string) bool {
+	return name == "SHA256SUMS" || name == "MD5SUMS"
+}
+
+// FileName 返回每个目录中校验文件的名称，与 coreutils 的惯例一致
+func (w *Writer) FileName() string {
+	return strings.ToUpper(w.algo) + "SUMS"
+}
+
+// Add 记录一个备份完成的文件，sha256 为复制时计算的哈希
+func (w *Writer) Add(path, sha256 string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.changed[filepath.Clean(path)] = sha256
+	w.mu.Unlock()
+}
+
+// Flush 写入校验文件并清空本次记录
+func (w *Writer) Flush() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	changed := w.changed
+	w.changed = make(map[string]string)
+	w.mu.Unlock()
+
+	if w.scope == ScopeRun {
+		return w.writeRun(changed)
+	}
+	return w.writeDirs(changed)
+}
+
+// writeRun 把本次备份的文件写入一个按时间命名的校验文件，路径相对于目标目录
+// 在目标目录中执行 sha256sum -c .neo-checksums/<文件名> 即可校验
+func (w *Writer) writeRun(changed map[string]string) error {
+	if len(changed) == 0 {
+		return nil
+	}
+	var lines []string
+	for p, sum := range changed {
+		rel, err := filepath.Rel(w.target, p)
+		if err != nil {
+			continue
+		}
+		if sum, err = w.sum(p, sum); err != nil {
+			logger.Errorf("计算校验值失败 %s: %v", p, err)
+			continue
+		}
+		lines = append(lines, line(sum, filepath.ToSlash(rel)))
+	}
+	sort.Slice(lines, func(i, j int) bool { return name(lines[i]) < name(lines[j]) })
+
+	dir := filepath.Join(w.target, RunDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return i18n.Errorf("创建校验文件目录失败: %w", err)
+	}
+	file := filepath.Join(dir, time.Now().Format("20060102-150405")+"."+w.algo)
+	if err := writeFile(file, lines); err != nil {
+		return err
+	}
+	logger.Infof("已写入校验文件: %s, 共 %d 个文件", file, len(lines))
+	return nil
+}
+
+// writeDirs 重新生成本次有文件备份的目录以及还没有校验文件的目录中的校验文件
+func (w *Writer) writeDirs(changed map[string]string) error {
+	dirs := make(map[string]bool)
+	for p := range changed {
+		dirs[filepath.Dir(p)] = true
+	}
+	var written int
+	err := filepath.WalkDir(w.target, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if p != w.target && strings.HasPrefix(d.Name(), ".neo-") {
+			return filepath.SkipDir
+		}
+		if !dirs[p] {
+			if _, err := os.Stat(filepath.Join(p, w.FileName())); err == nil {
+				return nil
+			}
+		}
+		n, err := w.writeDir(p, changed)
+		if err != nil {
+			logger.Errorf("写入校验文件失败 %s: %v", p, err)
+			return nil
+		}
+		if n > 0 {
+			written++
+		}
+		return nil
+	})
+	if err != nil {
+		return i18n.Errorf("扫描目标目录失败: %w", err)
+	}
+	if written > 0 {
+		logger.Infof("已更新 %d 个目录的校验文件: %s", written, w.target)
+	}
+	return nil
+}
+
+// writeDir 生成一个目录的校验文件，返回写入的文件数量，目录中没有文件时不写入
+func (w *Writer) writeDir(dir string, changed map[string]string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	sumsFile := filepath.Join(dir, w.FileName())
+	old := readSums(sumsFile)
+
+	var lines []string
+	for _, e := range entries {
+		if !e.Type().IsRegular() || !w.include(e.Name()) {
+			continue
+		}
+		p := filepath.Join(dir, e.Name())
+		sum, ok := changed[p]
+		if !ok {
+			// 没有变化的文件优先沿用已有的校验值，避免每次都重新读取
+			if sum, ok = old[e.Name()]; !ok {
+				sum = w.fromCatalog(p, e)
+			}
+		} else if sum, err = w.sum(p, sum); err != nil {
+			logger.Errorf("计算校验值失败 %s: %v", p, err)
+			continue
+		}
+		if sum == "" {
+			if sum, err = hashFile(p, w.newHash()); err != nil {
+				logger.Errorf("计算校验值失败 %s: %v", p, err)
+				continue
+			}
+		}
+		lines = append(lines, line(sum, e.Name()))
+	}
+	if len(lines) == 0 {
+		return 0, nil
+	}
+	return len(lines), writeFile(sumsFile, lines)
+}
+
+// include 判断文件是否写入校验文件，跳过校验文件本身和程序内部使用的文件
+func (w *Writer) include(name string) bool {
+	return name != w.FileName() && !strings.HasPrefix(name, ".neo-") && !strings.HasSuffix(name, tier.StubSuffix)
+}
+
+// sum 返回文件的校验值，sha256 直接使用复制时计算的哈希
+func (w *Writer) sum(p, sha256 string) (string, error) {
+	if w.algo == "sha256" && sha256 != "" {
+		return sha256, nil
+	}
+	return hashFile(p, w.newHash())
+}
+
+// fromCatalog 大小和修改时间与目录索引一致时使用索引中的 SHA256
+func (w *Writer) fromCatalog(p string, d fs.DirEntry) string {
+	if w.algo != "sha256" {
+		return ""
+	}
+	e, ok := w.catalog.Get(p)
+	if !ok || e.SHA256 == "" {
+		return ""
+	}
+	info, err := d.Info()
+	if err != nil || info.Size() != e.Size || !info.ModTime().Equal(e.ModTime) {
+		return ""
+	}
+	return e.SHA256
+}
+
+func (w *Writer) newHash() hash.Hash {
+	if w.algo == "md5" {
+		return md5.New()
+	}
+	return sha256.New()
+}
+
+func hashFile(p string, h hash.Hash) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// line 按 coreutils 的格式输出一行，文件名包含反斜杠或换行时需要转义，并在行首加反斜杠
+func line(sum, name string) string {
+	if strings.ContainsAny(name, "\\\n") {
+		name = strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(name)
+		return "\\" + sum + "  " + name
+	}
+	return sum + "  " + name
+}
+
+// name 从一行中取出文件名，返回转义前的原始名称
+func name(l string) string {
+	escaped := strings.HasPrefix(l, "\\")
+	l = strings.TrimPrefix(l, "\\")
+	i := strings.Index(l, " ")
+	if i < 0 || i+2 > len(l) {
+		return ""
+	}
+	n := l[i+2:]
+	if escaped {
+		n = strings.NewReplacer("\\\\", "\\", "\\n", "\n").Replace(n)
+	}
+	return n
+}
+
+// readSums 读取已有的校验文件，返回文件名到校验值的映射，文件不存在或无法解析的行忽略
+func readSums(file string) map[string]string {
+	sums := make(map[string]string)
+	f, err := os.Open(file)
+	if err != nil {
+		return sums
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		l := scanner.Text()
+		n := name(l)
+		if n == "" {
+			continue
+		}
+		sum, _, _ := strings.Cut(strings.TrimPrefix(l, "\\"), " ")
+		sums[n] = sum
+	}
+	return sums
+}
+
+func writeFile(file string, lines []string) error {
+	tmp := file + ".tmp"
+	data := strings.Join(lines, "\n") + "\n"
+	if err := os.WriteFile(tmp, []byte(data), 0644); err != nil {
+		return i18n.Errorf("写入校验文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return i18n.Errorf("写入校验文件失败: %w", err)
+	}
+	return nil
+}
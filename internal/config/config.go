@@ -2,23 +2,376 @@ package config
 
 import (
 	"encoding/json"
-	"fmt"
 	"os"
 	"path/filepath"
-	"time"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+	"github.com/lucasrui/neo-nas/internal/mount"
 )
 
+// logger config 模块的日志
+var logger = logging.New("config")
+
 type NeoConfig struct {
-	ConfigDir     string    `json:"config_dir"`     // 配置文件目录
-	BackupConfigs []Config  `json:"backup_configs"` // 备份配置列表
-	ZipConfig     ZipConfig `json:"zip_config"`     // 压缩配置列表
-	ProgressFile  string    `json:"progress_file"`  // 进度文件路径
+	ConfigDir       string            `json:"config_dir"`               // 配置文件目录
+	Hostname        string            `json:"hostname"`                 // 本机名称，多台机器备份到同一目标时用于区分，默认使用系统主机名
+	BackupConfigs   []Config          `json:"backup_configs"`           // 备份配置列表
+	SyncConfigs     []SyncConfig      `json:"sync_configs"`             // 双向同步配置列表
+	Tiering         []TierConfig      `json:"tiering"`                  // 冷存储迁移配置列表
+	ZipConfig       ZipConfig         `json:"zip_config"`               // 压缩配置列表
+	StateFile       string            `json:"state_file"`               // 每个文件的备份状态，扫描时跳过没有变化的文件
+	CatalogFile     string            `json:"catalog_file"`             // 文件目录索引路径
+	HistoryFile     string            `json:"history_file"`             // 各任务最近一次运行结果的记录文件
+	HTTP            HTTPConfig        `json:"http"`                     // 内置 HTTP 服务配置
+	Server          ServerConfig      `json:"server"`                   // 服务端模式配置，接收远程客户端的备份
+	Agent           AgentConfig       `json:"agent"`                    // 客户端模式配置，将备份推送到服务端
+	Browser         BrowserConfig     `json:"browser"`                  // 只读文件浏览配置
+	WebDAV          WebDAVConfig      `json:"webdav"`                   // WebDAV 服务配置
+	Status          StatusConfig      `json:"status"`                   // 守护进程状态接口配置
+	API             APIConfig         `json:"api"`                      // 管理接口配置
+	Bandwidth       BandwidthConfig   `json:"bandwidth"`                // 远程传输的带宽限制
+	S3              S3Config          `json:"s3"`                       // S3 兼容存储的连接配置，s3:// 开头的存储位置使用
+	SMB             SMBConfig         `json:"smb"`                      // SMB 共享的登录配置，smb:// 开头的目标使用
+	Rclone          RcloneConfig      `json:"rclone"`                   // rclone 命令的配置，rclone: 开头的存储位置使用
+	Proxy           string            `json:"proxy"`                    // 访问 S3、服务端等远程地址时使用的代理，例如 http://192.168.1.1:7890 或 socks5://127.0.0.1:1080
+	Encryption      EncryptionConfig  `json:"encryption"`               // 写入远程存储前在本地加密
+	ShutdownTimeout int               `json:"shutdown_timeout_seconds"` // 停止时等待正在复制的文件和进行中的任务完成的最长时间（秒），默认 60
+	Priority        PriorityConfig    `json:"priority"`                 // 扫描、复制和压缩等后台工作的 CPU 和 IO 优先级
+	Backoff         BackoffConfig     `json:"backoff"`                  // 系统负载过高或使用电池供电时暂停或放慢备份
+	UpdateCheck     UpdateCheckConfig `json:"update_check"`             // 定期检查是否有新版本
+	UpdateCheckFile string            `json:"update_check_file"`        // 最近一次检查新版本的结果
+	Scrub           ScrubConfig       `json:"scrub"`                    // 定期重新计算目标文件的哈希，发现静默损坏
+	ScrubReportFile string            `json:"scrub_report_file"`        // 最近一次校验目标文件的结果
+	ZipStateFile    string            `json:"zip_state_file"`           // 各压缩任务最近一次运行的时间，用于增量压缩
+	Log             LogConfig         `json:"log"`                      // 日志级别和格式
+	Language        string            `json:"language"`                 // 日志、错误和命令输出的语言：zh（默认）或 en
 }
 
 type Config struct {
-	SourceDir  string `json:"source_dir"`  // 源目录
-	TargetDir  string `json:"target_dir"`  // 目标目录
-	TargetUser string `json:"target_user"` // 目标用户
+	SourceDir        string          `json:"source_dir"`            // 源目录，也可以写作 UUID=<uuid>/子目录 或 LABEL=<卷标>/子目录，按卷查找当前的挂载点
+	TargetDir        string          `json:"target_dir"`            // 目标目录，以 agent:// 开头时推送到服务端，以 s3:// 开头时上传到 S3 兼容存储，以 rclone: 开头时通过 rclone 上传，以 smb:// 开头时写入 SMB 共享
+	TargetUser       string          `json:"target_user"`           // 目标用户
+	NetworkSource    bool            `json:"network_source"`        // 源目录是否位于网络共享上，启用后区分共享掉线与目录为空
+	ProbeTimeout     int             `json:"probe_timeout_seconds"` // 网络共享探测超时（秒），默认 10
+	PollInterval     int             `json:"poll_interval_seconds"` // 检查源目录是否出现或离线的间隔（秒），默认 5，转速较慢的机械硬盘可以设置为几分钟
+	HostNamespace    bool            `json:"host_namespace"`        // 多台机器共用目标目录时开启，备份存放到 <目标目录>/<主机名>/ 下
+	Snapshot         SnapshotConfig  `json:"snapshot"`              // 扫描成功后为目标目录创建快照
+	Immutable        bool            `json:"immutable"`             // 备份完成的文件设置不可变属性（chattr +i），仅 Linux 且需要 root 权限
+	Checksums        ChecksumConfig  `json:"checksums"`             // 扫描完成后在目标目录中写入校验文件
+	SeedFromTarget   bool            `json:"seed_from_target"`      // 首次扫描前从已有的目标目录导入目录索引和同步时间，避免重新比较已有的备份
+	Verify           string          `json:"verify"`                // verify 命令的比较方式，quick（默认）比较大小和修改时间，hash 另外比较内容
+	SystemMetadata   bool            `json:"system_metadata"`       // 同时备份 macOS 的 Spotlight 索引、Time Machine 数据和 Windows 的回收站等系统元数据，默认跳过
+	ScanParallelism  int             `json:"scan_parallelism"`      // 同时扫描的子目录数量，网络共享和 U 盘等访问延迟较高的源目录可以调大，默认 1
+	Concurrency      int             `json:"concurrency"`           // 同时复制的文件数量，大量小文件时可以调大，默认 1
+	MaxBytesPerSec   int64           `json:"max_bytes_per_sec"`     // 复制文件时读取源文件的速度上限（字节/秒），同时复制的文件共享限额，0 表示不限速
+	VerifyWrites     bool            `json:"verify_writes"`         // 每个文件写入后同步到磁盘并重新读取，与源文件的哈希一致才算备份成功，会降低复制速度
+	Delta            bool            `json:"delta"`                 // 64MB 以上的文件有变化时只写入变化的块，需要目标文件系统支持克隆文件（btrfs、XFS 等）
+	SpecialFiles     string          `json:"special_files"`         // 命名管道、套接字和设备文件的处理方式：skip（默认）跳过并记录日志，recreate 在目标中重新创建
+	Dedup            bool            `json:"dedup"`                 // 复制前按内容哈希查找目标目录中已有的相同文件，找到时创建硬链接，适合反复导入内容有重叠的存储卡
+	ParityPercent    int             `json:"parity_percent"`        // 为每个备份的文件生成恢复数据的冗余比例（1 到 100），0 表示不生成，适合长期存放的冷数据
+	Realtime         bool            `json:"realtime"`              // 源目录出现后实时监控其中的变化，文件写入完成后立即备份，不必等到下次插入或挂载（Linux、macOS 和 Windows）
+	UpdateChanged    bool            `json:"update_changed"`        // 目标中已有的文件大小不同或源文件更新时重新复制并覆盖，默认已存在的文件不再更新
+	Include          []string        `json:"include"`               // 只备份匹配其中规则的文件，例如 "*.jpg"、"DCIM/**"，为空表示全部备份
+	Exclude          []string        `json:"exclude"`               // 不备份匹配其中规则的文件和目录，例如 "*.tmp"、"node_modules/**"、".Trash-*"
+	DryRun           bool            `json:"dry_run"`               // 演练模式，只记录将要复制、更新的文件，不写入目标目录和状态文件，新任务确认无误后再关闭
+	Automount        AutomountConfig `json:"automount"`             // 按卷指定的源目录插入后没有挂载时由程序挂载，扫描完成后卸载，用于没有自动挂载服务的系统（仅 Linux）
+	EjectAfterBackup bool            `json:"eject_after_backup"`    // 扫描成功后同步、卸载并断开源目录所在 USB 设备的电源，之后可以直接拔出（Linux 和 macOS）
+	EjectHook        string          `json:"eject_hook"`            // 可以拔出的状态变化时执行的命令，例如控制 LED，参数为 on（已弹出，可以拔出）或 off（重新插入）
+}
+
+// AutomountConfig 自动挂载按 UUID 或卷标指定的源目录所在的卷
+type AutomountConfig struct {
+	Enabled  bool   `json:"enabled"`   // 卷插入后没有被系统挂载时自动挂载
+	FSType   string `json:"fs_type"`   // 文件系统类型，例如 vfat、exfat、ntfs3、ext4，为空时由 mount 命令识别
+	Options  string `json:"options"`   // 挂载选项，默认 ro,nosuid,nodev,noexec 只读挂载
+	MountDir string `json:"mount_dir"` // 挂载点所在的目录，默认为配置目录下的 .neo-mnt，每个卷挂载到以 UUID 或卷标命名的子目录
+}
+
+// DefaultAutomountOptions 没有配置挂载选项时只读挂载，备份不会修改源目录
+const DefaultAutomountOptions = "ro,nosuid,nodev,noexec"
+
+// PriorityConfig 后台工作的优先级，全部为默认值时不做调整
+type PriorityConfig struct {
+	Nice    int    `json:"nice"`     // 0 到 19，越大优先级越低
+	IOClass string `json:"io_class"` // IO 调度类别：best-effort 或 idle（只在磁盘空闲时读写），为空表示不调整
+	IOLevel int    `json:"io_level"` // best-effort 类别中的级别，0 到 7，越大优先级越低
+}
+
+// BackoffConfig 按系统负载和电源状态暂停或放慢备份
+type BackoffConfig struct {
+	MaxLoad   float64 `json:"max_load"`   // 1 分钟平均负载超过该值时退让，0 表示不检查
+	OnBattery bool    `json:"on_battery"` // 使用电池供电时退让，用于作为源或客户端的笔记本电脑
+	Action    string  `json:"action"`     // pause（默认）暂停直到恢复，slow 每个文件之间等待一段时间
+}
+
+// LogConfig 日志级别和格式，命令行的 --log-level 和 --log-format 优先于这里的配置
+type LogConfig struct {
+	Level      string            `json:"level"`        // 全局的日志级别：debug、info（默认）、warn 或 error
+	Modules    map[string]string `json:"modules"`      // 单独设置部分模块的级别，例如 {"backup": "warn"} 不再记录每个复制的文件，但保留警告和错误
+	Format     string            `json:"format"`       // 日志格式：text（默认）或 json，json 每行一个对象，附带 task、file、bytes、duration、status 等字段
+	File       string            `json:"file"`         // 日志文件路径，相对路径位于配置目录中；为空时输出到标准错误
+	MaxSizeMB  int               `json:"max_size_mb"`  // 日志文件超过该大小（MB）后轮转，默认 10
+	Daily      bool              `json:"daily"`        // 每天轮转一次，每个文件只包含一天的日志
+	MaxFiles   int               `json:"max_files"`    // 最多保留的旧日志文件数量，默认 10
+	MaxAgeDays int               `json:"max_age_days"` // 删除超过该天数的旧日志，0 表示只按数量删除
+	Compress   bool              `json:"compress"`     // 用 gzip 压缩旧日志
+}
+
+// UpdateCheckConfig 检查新版本，守护进程按间隔查询发布信息，结果显示在 list 命令中
+type UpdateCheckConfig struct {
+	Enabled       bool   `json:"enabled"`        // 是否定期检查，version --check 不受此开关影响
+	IntervalHours int    `json:"interval_hours"` // 检查间隔（小时），默认 24
+	Feed          string `json:"feed"`           // 发布信息地址，默认使用 GitHub releases
+	Proxy         string `json:"proxy"`          // 查询使用的代理，默认使用全局代理，direct 表示直接连接
+}
+
+// ScrubConfig 定期校验目标目录中已备份的文件，与目录索引中记录的哈希比较
+type ScrubConfig struct {
+	IntervalDays int  `json:"interval_days"` // 校验间隔（天），0 表示不定期校验，仍可以使用 scrub 命令
+	Repair       bool `json:"repair"`        // 发现损坏或缺失的文件时按恢复数据修复，或在源文件没有变化时重新复制
+}
+
+// 特殊文件的处理方式
+const (
+	SpecialSkip     = "skip"     // 跳过并记录日志
+	SpecialRecreate = "recreate" // 重新创建命名管道，以 root 运行时同时重新创建设备文件
+)
+
+// ChecksumConfig 与 sha256sum/md5sum 兼容的校验文件
+type ChecksumConfig struct {
+	Algorithm string `json:"algorithm"` // sha256 或 md5，为空表示不写入校验文件
+	Scope     string `json:"scope"`     // dir（默认）在每个目录写入 SHA256SUMS，run 为每次扫描备份的文件单独写入一个校验文件
+}
+
+type SnapshotConfig struct {
+	Type       string `json:"type"`        // 快照类型：btrfs、zfs、hardlink（任意支持硬链接的文件系统）或 auto（按目标文件系统自动选择 btrfs 或 zfs），为空表示不创建快照
+	Dir        string `json:"dir"`         // btrfs 和 hardlink 快照存放目录，默认 <目标目录>/.neo-snapshots
+	Dataset    string `json:"dataset"`     // zfs 数据集，默认为目标目录所在的数据集
+	Name       string `json:"name"`        // 快照名称模板，支持 {date}、{time}、{host}，默认 neo-{date}-{time}
+	Keep       int    `json:"keep"`        // 保留最近的快照数量
+	KeepDaily  int    `json:"keep_daily"`  // 最近这么多天中每天保留最新的一个快照
+	KeepWeekly int    `json:"keep_weekly"` // 最近这么多周中每周保留最新的一个快照，三项都为 0 时不清理
+}
+
+// 双向同步的冲突处理策略
+const (
+	ConflictNewerWins = "newer_wins" // 保留修改时间较新的版本
+	ConflictKeepBoth  = "keep_both"  // 两个版本都保留，较旧的版本加后缀重命名
+)
+
+type SyncConfig struct {
+	Left            string `json:"left"`                  // 一侧目录，例如 NAS 上的工作目录
+	Right           string `json:"right"`                 // 另一侧目录，例如移动硬盘上的目录
+	ConflictPolicy  string `json:"conflict_policy"`       // 冲突处理策略，newer_wins 或 keep_both（默认）
+	IntervalSeconds int    `json:"interval_seconds"`      // 同步间隔（秒），默认 60
+	MaxDeletePct    int    `json:"max_delete_percent"`    // 单次删除文件超过已同步文件的该比例时中止同步，默认 50
+	NetworkSource   bool   `json:"network_source"`        // 任一侧位于网络共享上时开启，共享不可用时跳过同步
+	ProbeTimeout    int    `json:"probe_timeout_seconds"` // 网络共享探测超时（秒），默认 10
+	TrashDays       int    `json:"trash_days"`            // 回收目录中的文件保留天数，同步完成后清理，0 表示不清理
+}
+
+type TierConfig struct {
+	Target        string `json:"target"`         // 备份目标目录
+	Cold          string `json:"cold"`           // 冷存储位置，例如另一块硬盘上的目录
+	AfterDays     int    `json:"after_days"`     // 超过该天数未修改且未访问的文件迁移到冷存储
+	MinSizeKB     int    `json:"min_size_kb"`    // 只迁移不小于该大小（KB）的文件，默认 0
+	IntervalHours int    `json:"interval_hours"` // 检查间隔（小时），默认 24
+}
+
+// 按卷指定源目录的前缀，格式为 UUID=<文件系统 UUID>[/子目录] 或 LABEL=<卷标>[/子目录]
+// 卷每次插入时可能挂载在不同的位置，例如 /media/usb1 或 /media/usb2，检查源目录时才查找当前的挂载点
+const (
+	SourceUUIDPrefix  = "UUID="
+	SourceLabelPrefix = "LABEL="
+)
+
+// IsVolumeSource 判断源目录是否按卷的 UUID 或卷标指定
+func (c Config) IsVolumeSource() bool {
+	return strings.HasPrefix(c.SourceDir, SourceUUIDPrefix) || strings.HasPrefix(c.SourceDir, SourceLabelPrefix)
+}
+
+// SourceVolume 返回按卷指定的源目录中的 UUID 或卷标（另一个为空）以及卷中的子目录
+func (c Config) SourceVolume() (uuid, label, sub string) {
+	spec, isUUID := strings.CutPrefix(c.SourceDir, SourceUUIDPrefix)
+	if !isUUID {
+		spec = strings.TrimPrefix(c.SourceDir, SourceLabelPrefix)
+	}
+	id, sub, _ := strings.Cut(filepath.ToSlash(spec), "/")
+	if isUUID {
+		return id, "", sub
+	}
+	return "", id, sub
+}
+
+// ResolveSource 返回源目录当前的路径，按卷指定时查找卷的挂载点，没有插入时返回 mount.ErrVolumeMissing
+func (c Config) ResolveSource() (string, error) {
+	if !c.IsVolumeSource() {
+		return c.SourceDir, nil
+	}
+	uuid, label, sub := c.SourceVolume()
+	dir, err := mount.VolumeDir(uuid, label)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.FromSlash(sub)), nil
+}
+
+// AgentTargetPrefix 客户端模式的目标前缀，格式为 agent://<命名空间>
+const AgentTargetPrefix = "agent://"
+
+// IsAgentTarget 判断目标是否需要推送到服务端
+func (c Config) IsAgentTarget() bool {
+	return strings.HasPrefix(c.TargetDir, AgentTargetPrefix)
+}
+
+// S3TargetPrefix S3 兼容存储目标的前缀，格式为 s3://<存储桶>/<前缀>
+const S3TargetPrefix = "s3://"
+
+// IsS3Target 判断目标是否为 S3 兼容存储，连接配置使用全局的 s3
+func (c Config) IsS3Target() bool {
+	return strings.HasPrefix(c.TargetDir, S3TargetPrefix)
+}
+
+// RcloneTargetPrefix 通过 rclone 访问的存储的前缀，格式为 rclone:<远程名>:<路径>
+const RcloneTargetPrefix = "rclone:"
+
+// IsRcloneTarget 判断目标是否通过 rclone 上传，远程名需要在 rclone 的配置文件中定义
+func (c Config) IsRcloneTarget() bool {
+	return strings.HasPrefix(c.TargetDir, RcloneTargetPrefix)
+}
+
+// IsStoreTarget 判断目标是否为 S3 或 rclone 等对象存储，文件逐个上传为对象
+// 不挂载的 smb:// 目标同样逐个上传，但登录配置在全局的 smb 中，由 IsSMBTarget 判断
+func (c Config) IsStoreTarget() bool {
+	return c.IsS3Target() || c.IsRcloneTarget()
+}
+
+// SMBTargetPrefix SMB 共享目标的前缀，格式为 smb://<服务器>/<共享>/<路径>
+const SMBTargetPrefix = "smb://"
+
+// IsSMBTarget 判断目标是否为 SMB 共享，登录配置使用全局的 smb
+func (c Config) IsSMBTarget() bool {
+	return strings.HasPrefix(c.TargetDir, SMBTargetPrefix)
+}
+
+// IsLocalTarget 判断目标是否为本机上的目录，SMB 共享在挂载之前不算本机目录
+func (c Config) IsLocalTarget() bool {
+	return !c.IsAgentTarget() && !c.IsStoreTarget() && !c.IsSMBTarget()
+}
+
+// AgentNamespace 返回客户端模式下服务端的命名空间
+func (c Config) AgentNamespace() string {
+	return strings.Trim(strings.TrimPrefix(c.TargetDir, AgentTargetPrefix), "/")
+}
+
+type HTTPConfig struct {
+	Listen  string `json:"listen"`   // 监听地址，例如 :8443
+	TLSCert string `json:"tls_cert"` // TLS 证书路径，为空时使用 HTTP
+	TLSKey  string `json:"tls_key"`  // TLS 私钥路径
+}
+
+type BrowserConfig struct {
+	Enabled  bool   `json:"enabled"`  // 是否启用只读文件浏览
+	Username string `json:"username"` // 访问用户名，为空时不需要认证
+	Password string `json:"password"` // 访问密码
+}
+
+type WebDAVConfig struct {
+	Enabled   bool   `json:"enabled"`    // 是否启用 WebDAV
+	ReadWrite bool   `json:"read_write"` // 是否允许写入，默认只读
+	Username  string `json:"username"`   // 访问用户名，为空时不需要认证
+	Password  string `json:"password"`   // 访问密码
+}
+
+// StatusConfig 状态接口，以 JSON 输出各任务的状态，监听地址使用 http.listen
+type StatusConfig struct {
+	Enabled  bool   `json:"enabled"`  // 是否启用状态接口
+	Username string `json:"username"` // 访问用户名，为空时不需要认证
+	Password string `json:"password"` // 访问密码
+}
+
+// APIConfig 管理接口，可以立即扫描、暂停或恢复备份任务和立即执行压缩任务，监听地址使用 http.listen
+type APIConfig struct {
+	Enabled bool   `json:"enabled"` // 是否启用管理接口
+	Token   string `json:"token"`   // 访问令牌，请求时放在 Authorization: Bearer 头中，至少 16 个字符
+}
+
+type ServerConfig struct {
+	Enabled   bool           `json:"enabled"`   // 是否启用服务端模式
+	Root      string         `json:"root"`      // 接收文件的根目录，按主机名分目录存放
+	Clients   []ServerClient `json:"clients"`   // 允许连接的客户端列表
+	Immutable bool           `json:"immutable"` // 接收完成的文件设置不可变属性（chattr +i）
+}
+
+type ServerClient struct {
+	Host  string `json:"host"`  // 客户端主机名，决定存放目录
+	Token string `json:"token"` // 客户端认证令牌
+}
+
+type AgentConfig struct {
+	ServerURL          string `json:"server_url"`           // 服务端地址，例如 https://nas.local:8443
+	Token              string `json:"token"`                // 认证令牌
+	CAFile             string `json:"ca_file"`              // 自签名证书的 CA 文件（可选）
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"` // 跳过证书校验（不推荐）
+	ResumableMB        int    `json:"resumable_mb"`         // 超过该大小（MB）的文件使用可续传上传，默认 64
+	ChunkMB            int    `json:"chunk_mb"`             // 可续传上传的分块大小（MB），默认 8
+	DeltaMB            int    `json:"delta_mb"`             // 服务端已有旧版本且文件超过该大小（MB）时使用增量上传，默认 16，-1 关闭
+	Proxy              string `json:"proxy"`                // 连接服务端使用的代理，默认使用全局代理，direct 表示直接连接
+}
+
+type S3Config struct {
+	Endpoint     string           `json:"endpoint"`      // 服务地址，默认为 AWS，使用 MinIO 等兼容存储时填写
+	Region       string           `json:"region"`        // 区域，默认 us-east-1
+	AccessKey    string           `json:"access_key"`    // 访问密钥，默认读取环境变量 AWS_ACCESS_KEY_ID
+	SecretKey    string           `json:"secret_key"`    // 私有密钥，默认读取环境变量 AWS_SECRET_ACCESS_KEY
+	PathStyle    bool             `json:"path_style"`    // 使用 <endpoint>/<bucket> 形式的地址，MinIO 等通常需要开启
+	StorageClass string           `json:"storage_class"` // 存储类型，例如 STANDARD_IA、GLACIER，为空使用存储桶的默认值
+	ObjectLock   ObjectLockConfig `json:"object_lock"`   // 上传对象的保留策略，存储桶需要已开启对象锁定
+	PartMB       int              `json:"part_mb"`       // 分块上传的分块大小（MB），超过该大小的对象分块上传，默认 16，不小于 5
+	Proxy        string           `json:"proxy"`         // 访问存储使用的代理，默认使用全局代理，direct 表示直接连接
+}
+
+// SMBConfig SMB 共享的登录配置，所有 smb:// 目标共用
+type SMBConfig struct {
+	Username        string `json:"username"`         // 用户名，为空时以来宾身份连接
+	Password        string `json:"password"`         // 密码，默认读取环境变量 SMB_PASSWORD
+	Domain          string `json:"domain"`           // 域或工作组，可以为空
+	CredentialsFile string `json:"credentials_file"` // 保存登录信息的文件，每行一项 username=、password=、domain=，设置后忽略上面三项
+	Mount           bool   `json:"mount"`            // 挂载共享后按本机目录备份（Linux 上需要 root 和 cifs-utils），默认使用内置的 SMB2/3 客户端直接写入
+	Options         string `json:"options"`          // 附加的挂载选项，例如 vers=3.0，仅 Linux 使用
+	MountDir        string `json:"mount_dir"`        // 挂载共享的目录，默认为配置目录下的 .neo-smb
+}
+
+// RcloneConfig rclone 命令的配置，所有 rclone: 位置共用
+type RcloneConfig struct {
+	Binary     string   `json:"binary"`      // rclone 可执行文件，默认在 PATH 中查找
+	ConfigFile string   `json:"config_file"` // rclone 的配置文件，默认使用 rclone 自己的默认位置
+	Flags      []string `json:"flags"`       // 每次执行时附加的参数，例如 ["--transfers", "1"]
+}
+
+// EncryptionConfig 远程存储的客户端加密，配置密钥文件后写入 S3 的对象全部加密
+type EncryptionConfig struct {
+	KeyFile        string `json:"key_file"`        // 密钥文件，内容为 32 字节密钥的十六进制或 base64 编码
+	ObfuscateNames bool   `json:"obfuscate_names"` // 存储上的对象名称替换为不可读的名称，原始名称记录在本地索引中
+	Local          bool   `json:"local"`           // 写入本地目录（例如冷存储硬盘）时同样加密
+}
+
+type ObjectLockConfig struct {
+	Mode       string `json:"mode"`        // GOVERNANCE 或 COMPLIANCE，为空表示不设置
+	RetainDays int    `json:"retain_days"` // 保留天数，期间对象不能被删除或覆盖
+}
+
+type BandwidthConfig struct {
+	Schedules []BandwidthSchedule `json:"schedules"` // 按时段限速，第一个匹配的时段生效，不在任何时段内时不限速
+}
+
+type BandwidthSchedule struct {
+	Start     string   `json:"start"`      // 开始时间，HH:MM
+	End       string   `json:"end"`        // 结束时间，HH:MM，早于开始时间表示跨过午夜
+	Days      []string `json:"days"`       // 生效的星期，例如 ["mon", "fri"]，为空表示每天
+	LimitKBps int      `json:"limit_kbps"` // 限速（KB/s），0 表示不限速
 }
 
 type ZipConfig struct {
@@ -26,34 +379,85 @@ type ZipConfig struct {
 	Items           []ZipItem `json:"items"`            // 压缩配置列表
 }
 
+// Scheduled 压缩间隔大于 0 或有压缩任务设置了 cron 时守护进程和 run-once 会定时压缩
+func (c ZipConfig) Scheduled() bool {
+	if c.IntervalSeconds > 0 {
+		return true
+	}
+	for _, item := range c.Items {
+		if item.Cron != "" {
+			return true
+		}
+	}
+	return false
+}
+
 type ZipItem struct {
-	Source     string `json:"source"`      // 源文件
-	Target     string `json:"target"`      // 目标文件
-	Key        string `json:"key"`         // 密钥
-	TargetUser string `json:"target_user"` // 目标用户（格式：uid:gid）
+	Source           string `json:"source"`             // 源文件
+	Target           string `json:"target"`             // 目标文件，可以使用 {date}、{time} 和 {source_base} 占位符，s3:// 或 rclone: 开头时边压缩边上传，不在本地保存
+	Key              string `json:"key"`                // 密钥
+	TargetUser       string `json:"target_user"`        // 目标用户（格式：uid:gid）
+	Upload           string `json:"upload"`             // 压缩完成后上传到的存储位置，例如 s3://bucket/archives（可选）
+	ParityPercent    int    `json:"parity_percent"`     // 为压缩文件生成恢复数据的冗余比例（1 到 100），0 表示不生成
+	Format           string `json:"format"`             // 压缩格式：zip（默认）、tar.gz 或 tar.zst，tar.zst 需要安装 zstd 命令，tar 格式不支持加密
+	Incremental      bool   `json:"incremental"`        // 增量压缩，只把上次压缩之后修改的文件写入 <名称>-inc-<时间> 压缩文件，按 full_interval_days 生成完整的压缩文件
+	FullIntervalDays int    `json:"full_interval_days"` // 增量压缩时生成完整压缩文件的间隔（天），默认 7，生成后删除之前的增量压缩文件
+	Level            string `json:"level"`              // 压缩级别：store 不压缩，适合照片、视频等已压缩的文件；fast 最快；best 压缩率最高，适合文本；默认使用各格式的默认级别
+	Cron             string `json:"cron"`               // 按 cron 表达式定时压缩，例如 "0 3 * * *" 每天凌晨 3 点，设置后不再按 interval_seconds 执行
+	Keep             int    `json:"keep"`               // 保留最近几个压缩文件，设置后压缩文件名中加上生成时间，0 表示每次覆盖 target
+	MaxTotalBytes    int64  `json:"max_total_bytes"`    // 所有压缩文件（含增量压缩文件和恢复数据）的总大小上限（字节），超过时从最早的开始删除，0 表示不限制
+	RemoteKeep       int    `json:"remote_keep"`        // 上传位置保留最近几个压缩文件（含其增量压缩文件）的副本，0 表示不删除上传的副本；keep 和 max_total_bytes 只删除本地的压缩文件
+	Workers          int    `json:"workers"`            // tar.gz 和 tar.zst 压缩使用的线程数，默认使用全部 CPU 核心，1 表示单线程；zip 格式总是单线程
 }
 
-type ProgressConfig struct {
-	BackupConfigs []ProgressConfigItem `json:"backup_configs"`
+// IsRemoteTarget 判断压缩文件是否直接写入 S3 或 rclone 存储，不在本地保存
+func (z ZipItem) IsRemoteTarget() bool {
+	return strings.HasPrefix(z.Target, S3TargetPrefix) || strings.HasPrefix(z.Target, RcloneTargetPrefix)
 }
 
-type ProgressConfigItem struct {
-	SourceDir    string    `json:"source_dir"`
-	TargetDir    string    `json:"target_dir"`
-	ProgressTime time.Time `json:"progress_time"`
+// 压缩任务的压缩级别
+const (
+	LevelStore = "store"
+	LevelFast  = "fast"
+	LevelBest  = "best"
+)
+
+// 压缩任务的压缩格式
+const (
+	FormatZip    = "zip"
+	FormatTarGz  = "tar.gz"
+	FormatTarZst = "tar.zst"
+)
+
+// Dir 返回配置目录，优先使用环境变量 BACKUP_CONFIG_DIR
+func Dir() string {
+	if dir := os.Getenv("BACKUP_CONFIG_DIR"); dir != "" {
+		return dir
+	}
+	// 如果环境变量未设置，使用默认目录
+	return defaultDir()
 }
 
-func LoadConfig() (*NeoConfig, error) {
-	// 首先从环境变量读取配置目录
-	configDir := os.Getenv("BACKUP_CONFIG_DIR")
-	if configDir == "" {
-		// 如果环境变量未设置，使用默认目录
-		configDir = "/config"
+// ReadLanguage 只读取配置文件中的 language，在加载配置之前设置语言，使加载配置时的错误也使用该语言
+// 配置文件不存在或无法解析时返回空字符串，错误由之后的 LoadConfig 报告
+func ReadLanguage() string {
+	data, err := os.ReadFile(filepath.Join(Dir(), "config.json"))
+	if err != nil {
+		return ""
+	}
+	var c struct {
+		Language string `json:"language"`
 	}
+	json.Unmarshal(data, &c)
+	return c.Language
+}
+
+func LoadConfig() (*NeoConfig, error) {
+	configDir := Dir()
 
 	// 判断配置目录是否存在，不存在直接返回异常
 	if _, err := os.Stat(configDir); err != nil {
-		return nil, fmt.Errorf("配置目录: %s 不存在, %w", configDir, err)
+		return nil, i18n.Errorf("配置目录: %s 不存在, %w", configDir, err)
 	}
 
 	// 读取配置文件
@@ -62,49 +466,67 @@ func LoadConfig() (*NeoConfig, error) {
 	if err != nil {
 		if os.IsNotExist(err) {
 			// 如果配置文件不存在，直接返回异常
-			return nil, fmt.Errorf("配置文件: %s 不存在, %w", configPath, err)
+			return nil, i18n.Errorf("配置文件: %s 不存在, %w", configPath, err)
 		}
-		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+		return nil, i18n.Errorf("读取配置文件失败: %w", err)
 	}
 
 	var config NeoConfig
 	if err := json.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+		return nil, i18n.Errorf("解析配置文件失败: %w", err)
 	}
 
-	// 确保配置目录和进度文件路径正确
+	// 确保配置目录和状态文件路径正确
 	config.ConfigDir = configDir
-	config.ProgressFile = filepath.Join(configDir, ".backup-progress")
-
-	return &config, nil
-}
-
-func LoadProgress(progressFile string) (*ProgressConfig, error) {
-	data, err := os.ReadFile(progressFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return &ProgressConfig{}, nil
+	config.StateFile = filepath.Join(configDir, ".backup-state")
+	config.CatalogFile = filepath.Join(configDir, ".backup-catalog")
+	config.HistoryFile = filepath.Join(configDir, ".last-runs")
+	config.UpdateCheckFile = filepath.Join(configDir, ".update-check")
+	config.ScrubReportFile = filepath.Join(configDir, ".scrub-report")
+	config.ZipStateFile = filepath.Join(configDir, ".zip-state")
+	if config.Log.File != "" && !filepath.IsAbs(config.Log.File) {
+		config.Log.File = filepath.Join(configDir, config.Log.File)
+	}
+	if config.HTTP.Listen == "" {
+		config.HTTP.Listen = ":8080"
+	}
+	if config.Hostname == "" {
+		if config.Hostname, err = os.Hostname(); err != nil {
+			return nil, i18n.Errorf("获取主机名失败: %w", err)
 		}
-		return nil, fmt.Errorf("读取进度文件失败: %w", err)
+		// 只取主机名的第一段，例如 laptop.local 取 laptop
+		config.Hostname, _, _ = strings.Cut(config.Hostname, ".")
 	}
-
-	var progress ProgressConfig
-	if err := json.Unmarshal(data, &progress); err != nil {
-		return nil, fmt.Errorf("解析进度文件失败: %w", err)
+	// 未单独配置代理时使用全局代理
+	if config.S3.Proxy == "" {
+		config.S3.Proxy = config.Proxy
 	}
-
-	return &progress, nil
-}
-
-func (p *ProgressConfig) Save(progressFile string) error {
-	data, err := json.MarshalIndent(p, "", "  ")
-	if err != nil {
-		return fmt.Errorf("序列化进度失败: %w", err)
+	if config.Agent.Proxy == "" {
+		config.Agent.Proxy = config.Proxy
 	}
-
-	if err := os.WriteFile(progressFile, data, 0644); err != nil {
-		return fmt.Errorf("保存进度文件失败: %w", err)
+	if config.UpdateCheck.Proxy == "" {
+		config.UpdateCheck.Proxy = config.Proxy
+	}
+	if config.SMB.MountDir == "" {
+		config.SMB.MountDir = filepath.Join(configDir, ".neo-smb")
+	}
+	for i := range config.BackupConfigs {
+		if a := &config.BackupConfigs[i].Automount; a.Enabled {
+			if a.Options == "" {
+				a.Options = DefaultAutomountOptions
+			}
+			if a.MountDir == "" {
+				a.MountDir = filepath.Join(configDir, ".neo-mnt")
+			}
+		}
 	}
+	if config.Hostname == "" || strings.HasPrefix(config.Hostname, ".") || strings.ContainsAny(config.Hostname, `/\:`) {
+		return nil, i18n.Errorf("主机名无效: %q", config.Hostname)
+	}
+	if config.API.Enabled && len(config.API.Token) < 16 {
+		return nil, i18n.Errorf("管理接口的令牌至少需要 16 个字符")
+	}
+	checkPlatform(&config)
 
-	return nil
+	return &config, nil
 }
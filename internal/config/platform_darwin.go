@@ -0,0 +1,17 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// defaultDir macOS 上默认使用 ~/Library/Application Support/neo-nas，以 launchd 用户代理运行时不需要 root 权限
+func defaultDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "/config"
+	}
+	return filepath.Join(home, "Library", "Application Support", "neo-nas")
+}
+
+func checkPlatform(config *NeoConfig) {}
@@ -0,0 +1,9 @@
+//go:build !windows && !darwin
+
+package config
+
+func defaultDir() string {
+	return "/config"
+}
+
+func checkPlatform(config *NeoConfig) {}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// defaultDir Windows 上默认使用 %ProgramData%\neo-nas
+func defaultDir() string {
+	return filepath.Join(os.Getenv("ProgramData"), "neo-nas")
+}
+
+// checkPlatform Windows 不支持按 uid:gid 设置所有者，忽略 target_user，避免每个文件都记录失败
+// 只写盘符的目录（例如 E:）表示该盘上的当前目录，服务的当前目录不确定，改为盘符的根目录 E:\
+func checkPlatform(config *NeoConfig) {
+	ignored := false
+	for i := range config.BackupConfigs {
+		config.BackupConfigs[i].SourceDir = driveRoot(config.BackupConfigs[i].SourceDir)
+		config.BackupConfigs[i].TargetDir = driveRoot(config.BackupConfigs[i].TargetDir)
+		if config.BackupConfigs[i].TargetUser != "" {
+			config.BackupConfigs[i].TargetUser = ""
+			ignored = true
+		}
+	}
+	for i := range config.SyncConfigs {
+		config.SyncConfigs[i].Left = driveRoot(config.SyncConfigs[i].Left)
+		config.SyncConfigs[i].Right = driveRoot(config.SyncConfigs[i].Right)
+	}
+	for i := range config.ZipConfig.Items {
+		config.ZipConfig.Items[i].Source = driveRoot(config.ZipConfig.Items[i].Source)
+		if config.ZipConfig.Items[i].TargetUser != "" {
+			config.ZipConfig.Items[i].TargetUser = ""
+			ignored = true
+		}
+	}
+	if ignored {
+		logger.Warn("Windows 不支持设置文件所有者，已忽略 target_user 配置")
+	}
+}
+
+// driveRoot 把只有盘符的路径改为该盘的根目录，其他路径不变
+func driveRoot(path string) string {
+	if len(path) == 2 && path[1] == ':' && filepath.VolumeName(path) == path {
+		return path + `\`
+	}
+	return path
+}
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// FileState 一个源文件最近一次备份时的状态，大小和修改时间都没有变化时扫描直接跳过
+type FileState struct {
+	Host       string    `json:"host,omitempty"` // 备份所在的机器，配置目录被多台机器共用时区分各自的状态
+	Path       string    `json:"path"`           // 目标文件，本地目标为绝对路径，远程目标为 <存储位置>/<对象键>
+	Source     string    `json:"source"`         // 源文件路径
+	Size       int64     `json:"size"`           // 备份时源文件的大小
+	ModTime    time.Time `json:"mod_time"`       // 备份时源文件的修改时间
+	BackupTime time.Time `json:"backup_time"`    // 最近一次备份或确认已备份的时间
+}
+
+// StateStore 每个文件的备份状态，按文件而不是按上次同步时间判断是否需要备份
+// 持久化为追加写入的 JSON Lines 日志，每行一条记录，后面的记录覆盖同一目标文件之前的记录，记录数膨胀后自动压缩
+type StateStore struct {
+	file    string
+	mu      sync.RWMutex
+	files   map[string]*FileState // 键为 <主机名>\x00<目标文件>
+	journal *os.File              // 为 nil 表示以只读方式打开，修改只保存在内存中
+	records int
+}
+
+func stateKey(host, path string) string {
+	return host + "\x00" + path
+}
+
+// OpenState 加载状态文件，不存在时创建空的状态
+func OpenState(file string) (*StateStore, error) {
+	s, err := OpenStateReadOnly(file)
+	if err != nil {
+		return nil, err
+	}
+	journal, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, i18n.Errorf("打开状态文件失败: %w", err)
+	}
+	s.journal = journal
+	return s, nil
+}
+
+// OpenStateReadOnly 加载状态文件但不写入，用于没有持有实例锁的进程和演练模式
+func OpenStateReadOnly(file string) (*StateStore, error) {
+	s := &StateStore{file: file, files: make(map[string]*FileState)}
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, i18n.Errorf("读取状态文件失败: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var st FileState
+		if err := json.Unmarshal(scanner.Bytes(), &st); err != nil || st.Path == "" {
+			// 崩溃时最后一行可能不完整，忽略即可
+			continue
+		}
+		s.records++
+		s.files[stateKey(st.Host, st.Path)] = &st
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, i18n.Errorf("解析状态文件失败: %w", err)
+	}
+	return s, nil
+}
+
+// Get 查询本机备份到目标文件 path 时记录的状态
+func (s *StateStore) Get(host, path string) (FileState, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	st, ok := s.files[stateKey(host, path)]
+	if !ok {
+		return FileState{}, false
+	}
+	return *st, true
+}
+
+// Put 新增或更新一条记录，并立即追加到日志
+func (s *StateStore) Put(st FileState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[stateKey(st.Host, st.Path)] = &st
+	if s.journal == nil {
+		return nil
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return i18n.Errorf("序列化状态失败: %w", err)
+	}
+	if _, err := s.journal.Write(append(data, '\n')); err != nil {
+		return i18n.Errorf("写入状态文件失败: %w", err)
+	}
+	s.records++
+	return nil
+}
+
+// HasTarget 本机是否有写入目标 target 中的记录，用于判断任务是否备份过
+func (s *StateStore) HasTarget(host, target string) bool {
+	prefix := stateKey(host, strings.TrimSuffix(target, "/"))
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for key := range s.files {
+		if strings.HasPrefix(key, prefix) {
+			if rest := key[len(prefix):]; rest == "" || rest[0] == '/' || rest[0] == os.PathSeparator {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Save 将日志同步到磁盘，日志记录数远大于实际条目数时重写压缩
+func (s *StateStore) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.journal == nil {
+		return nil
+	}
+	if s.records > 2*len(s.files)+1024 {
+		return s.compact()
+	}
+	if err := s.journal.Sync(); err != nil {
+		return i18n.Errorf("同步状态文件失败: %w", err)
+	}
+	return nil
+}
+
+// compact 将当前条目写入临时文件后替换日志
+func (s *StateStore) compact() error {
+	tmpFile := s.file + ".tmp"
+	f, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return i18n.Errorf("保存状态文件失败: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, st := range s.files {
+		if err := enc.Encode(st); err != nil {
+			f.Close()
+			return i18n.Errorf("保存状态文件失败: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return i18n.Errorf("保存状态文件失败: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return i18n.Errorf("同步状态文件失败: %w", err)
+	}
+	f.Close()
+
+	if err := os.Rename(tmpFile, s.file); err != nil {
+		return i18n.Errorf("保存状态文件失败: %w", err)
+	}
+	journal, err := os.OpenFile(s.file, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return i18n.Errorf("打开状态文件失败: %w", err)
+	}
+	s.journal.Close()
+	s.journal = journal
+	s.records = len(s.files)
+	return nil
+}
+
+// Close 同步并关闭日志文件
+func (s *StateStore) Close() error {
+	if err := s.Save(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.journal == nil {
+		return nil
+	}
+	err := s.journal.Close()
+	s.journal = nil
+	return err
+}
@@ -0,0 +1,515 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+	"github.com/lucasrui/neo-nas/internal/mount"
+)
+
+// Problem 配置中发现的一个问题
+type Problem struct {
+	Path    string `json:"path"` // JSON 路径，例如 backup_configs[0].source_dir，整个文件的问题为空
+	Message string `json:"message"`
+	Warning bool   `json:"warning"` // 只是提醒，不影响运行，例如 U 盘未插入时源目录不存在
+}
+
+func (p Problem) String() string {
+	level := i18n.T("错误")
+	if p.Warning {
+		level = i18n.T("警告")
+	}
+	if p.Path == "" {
+		return fmt.Sprintf("[%s] %s", level, p.Message)
+	}
+	return fmt.Sprintf("[%s] %s: %s", level, p.Path, p.Message)
+}
+
+// problems 收集检查过程中发现的问题
+type problems []Problem
+
+func (ps *problems) errorf(path, format string, args ...interface{}) {
+	*ps = append(*ps, Problem{Path: path, Message: i18n.Sprintf(format, args...)})
+}
+
+func (ps *problems) warnf(path, format string, args ...interface{}) {
+	*ps = append(*ps, Problem{Path: path, Message: i18n.Sprintf(format, args...), Warning: true})
+}
+
+// nonNegative 检查数值不是负数
+func (ps *problems) nonNegative(path string, v int64) {
+	if v < 0 {
+		ps.errorf(path, "不能小于 0: %d", v)
+	}
+}
+
+// ParseProblems 检查配置文件的内容能否解析，并列出配置中没有的字段，通常是拼写错误
+// 语法错误和类型错误的问题中包含行号和列号，ok 为 false 表示内容无法解析为配置
+func ParseProblems(data []byte) (ps []Problem, ok bool) {
+	var found problems
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			line, col := position(data, syntax.Offset)
+			found.errorf("", "第 %d 行第 %d 列: %v", line, col, err)
+		} else {
+			found.errorf("", "解析配置文件失败: %v", err)
+		}
+		return found, false
+	}
+	var config NeoConfig
+	ok = true
+	if err := json.Unmarshal(data, &config); err != nil {
+		ok = false
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			line, col := position(data, typeErr.Offset)
+			found.errorf(fieldPath(typeErr.Field), "第 %d 行第 %d 列: 类型错误，需要 %s，实际为 %s", line, col, typeErr.Type, typeErr.Value)
+		} else {
+			found.errorf("", "解析配置文件失败: %v", err)
+		}
+	}
+	unknownFields(raw, reflect.TypeOf(config), "", &found)
+	return found, ok
+}
+
+// fieldPath 把 encoding/json 的字段路径 backup_configs.0.source_dir 转换为 backup_configs[0].source_dir
+func fieldPath(field string) string {
+	var b strings.Builder
+	for i, part := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(part); err == nil {
+			fmt.Fprintf(&b, "[%s]", part)
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// position 把字节偏移转换为从 1 开始的行号和列号
+func position(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	line, col := 1, 1
+	for _, b := range data[:offset] {
+		if b == '\n' {
+			line, col = line+1, 1
+		} else {
+			col++
+		}
+	}
+	return line, col
+}
+
+// 自行解析的类型（例如 time.Time）不再检查其中的字段
+var (
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// unknownFields 按配置结构的 json 标签检查 v 中的字段，与 encoding/json 一样不区分大小写
+func unknownFields(v interface{}, t reflect.Type, path string, ps *problems) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if pt := reflect.PointerTo(t); pt.Implements(textUnmarshaler) || pt.Implements(jsonUnmarshaler) {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := make(map[string]reflect.StructField)
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" || !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			fields[strings.ToLower(name)] = f
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			p := key
+			if path != "" {
+				p = path + "." + key
+			}
+			f, ok := fields[strings.ToLower(key)]
+			if !ok {
+				if hint := similarField(key, fields); hint != "" {
+					ps.errorf(p, "未知的字段，是否应为 %s", hint)
+				} else {
+					ps.errorf(p, "未知的字段")
+				}
+				continue
+			}
+			unknownFields(obj[key], f.Type, p, ps)
+		}
+	case reflect.Slice, reflect.Array:
+		arr, ok := v.([]interface{})
+		if !ok {
+			return
+		}
+		for i, e := range arr {
+			unknownFields(e, t.Elem(), fmt.Sprintf("%s[%d]", path, i), ps)
+		}
+	case reflect.Map:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for key, e := range obj {
+			unknownFields(e, t.Elem(), fmt.Sprintf("%s[%q]", path, key), ps)
+		}
+	}
+}
+
+// similarField 查找忽略下划线、大小写和结尾的 s 后与 key 相同的字段，例如 zip_configs 对应 zip_config
+func similarField(key string, fields map[string]reflect.StructField) string {
+	norm := func(s string) string {
+		s = strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(s))
+		return strings.TrimSuffix(s, "s")
+	}
+	want := norm(key)
+	for _, f := range fields {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name != "" && norm(name) == want {
+			return name
+		}
+	}
+	return ""
+}
+
+// Validate 检查配置中的空路径、不存在的目录、源和目标互相包含、重复的任务、无法解析的 target_user 和负数的间隔等问题
+// 返回所有发现的问题，不在第一个问题处停止
+func (c *NeoConfig) Validate() []Problem {
+	var ps problems
+	sources := make(map[string]int)
+	pairs := make(map[string]int)
+	smbChecked := false
+	for i, task := range c.BackupConfigs {
+		path := fmt.Sprintf("backup_configs[%d]", i)
+		switch {
+		case task.SourceDir == "":
+			ps.errorf(path+".source_dir", "不能为空")
+		case task.IsVolumeSource():
+			checkVolume(&ps, path+".source_dir", task)
+			if j, ok := sources[task.SourceDir]; ok {
+				ps.errorf(path+".source_dir", "与 backup_configs[%d] 的源目录相同，只有第一个任务生效", j)
+			} else {
+				sources[task.SourceDir] = i
+			}
+		default:
+			checkDir(&ps, path+".source_dir", task.SourceDir, "源目录不存在，插入或挂载后才会备份")
+			key := cleanPath(task.SourceDir)
+			if j, ok := sources[key]; ok {
+				ps.errorf(path+".source_dir", "与 backup_configs[%d] 的源目录相同，只有第一个任务生效", j)
+			} else {
+				sources[key] = i
+			}
+		}
+		if task.TargetDir == "" {
+			ps.errorf(path+".target_dir", "不能为空")
+		} else if task.IsLocalTarget() {
+			checkDir(&ps, path+".target_dir", task.TargetDir, "目标目录不存在，备份时自动创建；目标为外接硬盘时确认已经挂载，否则会写入系统盘")
+			if task.SourceDir != "" && !task.IsVolumeSource() && overlaps(task.SourceDir, task.TargetDir) {
+				ps.errorf(path+".target_dir", "源目录与目标目录相同或互相包含: %s, %s", task.SourceDir, task.TargetDir)
+			}
+		} else if task.IsSMBTarget() && c.SMB.Mount && !smbChecked {
+			// 所有 smb:// 目标的挂载方式相同，只在第一个任务处提示
+			smbChecked = true
+			checkSMB(&ps, path+".target_dir")
+		}
+		if task.SourceDir != "" && task.TargetDir != "" {
+			key := cleanPath(task.SourceDir) + "\x00" + task.TargetDir
+			if j, ok := pairs[key]; ok {
+				ps.errorf(path, "与 backup_configs[%d] 重复", j)
+			} else {
+				pairs[key] = i
+			}
+		}
+		if task.Automount.Enabled {
+			switch {
+			case !task.IsVolumeSource():
+				ps.errorf(path+".automount", "只能用于按 UUID 或卷标指定的源目录")
+			case !mount.AutomountSupported:
+				ps.errorf(path+".automount", "%v", mount.ErrAutomountUnsupported)
+			case task.Realtime:
+				ps.errorf(path+".automount", "自动挂载的卷扫描完成后卸载，不能同时开启 realtime")
+			}
+		}
+		if task.EjectAfterBackup {
+			switch {
+			case !mount.EjectSupported:
+				ps.errorf(path+".eject_after_backup", "%v", mount.ErrEjectUnsupported)
+			case task.NetworkSource:
+				ps.errorf(path+".eject_after_backup", "网络共享不能弹出")
+			case task.Realtime:
+				ps.errorf(path+".eject_after_backup", "扫描完成后弹出设备，不能同时开启 realtime")
+			}
+		} else if task.EjectHook != "" {
+			ps.warnf(path+".eject_hook", "没有开启 eject_after_backup，不会执行")
+		}
+		checkUser(&ps, path+".target_user", task.TargetUser)
+		ps.nonNegative(path+".probe_timeout_seconds", int64(task.ProbeTimeout))
+		ps.nonNegative(path+".poll_interval_seconds", int64(task.PollInterval))
+		ps.nonNegative(path+".scan_parallelism", int64(task.ScanParallelism))
+		ps.nonNegative(path+".concurrency", int64(task.Concurrency))
+		ps.nonNegative(path+".max_bytes_per_sec", task.MaxBytesPerSec)
+		checkPercent(&ps, path+".parity_percent", task.ParityPercent)
+	}
+
+	syncs := make(map[string]int)
+	for i, s := range c.SyncConfigs {
+		path := fmt.Sprintf("sync_configs[%d]", i)
+		if s.Left == "" {
+			ps.errorf(path+".left", "不能为空")
+		}
+		if s.Right == "" {
+			ps.errorf(path+".right", "不能为空")
+		}
+		if s.Left != "" && s.Right != "" {
+			if overlaps(s.Left, s.Right) {
+				ps.errorf(path, "两侧目录相同或互相包含: %s, %s", s.Left, s.Right)
+			}
+			a, b := cleanPath(s.Left), cleanPath(s.Right)
+			if a > b {
+				a, b = b, a
+			}
+			if j, ok := syncs[a+"\x00"+b]; ok {
+				ps.errorf(path, "与 sync_configs[%d] 重复", j)
+			} else {
+				syncs[a+"\x00"+b] = i
+			}
+		}
+		ps.nonNegative(path+".interval_seconds", int64(s.IntervalSeconds))
+		ps.nonNegative(path+".probe_timeout_seconds", int64(s.ProbeTimeout))
+		ps.nonNegative(path+".trash_days", int64(s.TrashDays))
+		if s.MaxDeletePct < 0 || s.MaxDeletePct > 100 {
+			ps.errorf(path+".max_delete_percent", "需要在 0 到 100 之间: %d", s.MaxDeletePct)
+		}
+	}
+
+	for i, t := range c.Tiering {
+		path := fmt.Sprintf("tiering[%d]", i)
+		if t.Target == "" {
+			ps.errorf(path+".target", "不能为空")
+		}
+		if t.Cold == "" {
+			ps.errorf(path+".cold", "不能为空")
+		} else if t.Target != "" && !strings.Contains(t.Cold, "://") && !strings.HasPrefix(t.Cold, RcloneTargetPrefix) && overlaps(t.Target, t.Cold) {
+			ps.errorf(path+".cold", "冷存储目录不能与目标目录相同或互相包含: %s", t.Cold)
+		}
+		if t.AfterDays <= 0 {
+			ps.errorf(path+".after_days", "必须大于 0: %d", t.AfterDays)
+		}
+		ps.nonNegative(path+".min_size_kb", int64(t.MinSizeKB))
+		ps.nonNegative(path+".interval_hours", int64(t.IntervalHours))
+	}
+
+	z := c.ZipConfig
+	ps.nonNegative("zip_config.interval_seconds", int64(z.IntervalSeconds))
+	if len(z.Items) > 0 && !z.Scheduled() {
+		ps.warnf("zip_config.interval_seconds", "为 0 且压缩任务都没有设置 cron，压缩任务不会定时执行，只能通过 zip 或 once --all-zips 命令执行")
+	}
+	targets := make(map[string]int)
+	for i, item := range z.Items {
+		path := fmt.Sprintf("zip_config.items[%d]", i)
+		if item.Source == "" {
+			ps.errorf(path+".source", "不能为空")
+		} else if _, err := os.Stat(item.Source); err != nil {
+			ps.warnf(path+".source", "源路径不存在，压缩任务会失败: %s", item.Source)
+		}
+		if item.Target == "" {
+			ps.errorf(path+".target", "不能为空")
+		} else {
+			if j, ok := targets[item.Target]; ok {
+				ps.errorf(path+".target", "与 zip_config.items[%d] 的目标路径相同", j)
+			} else {
+				targets[item.Target] = i
+			}
+			if !item.IsRemoteTarget() && item.Source != "" && within(item.Source, filepath.Dir(item.Target)) {
+				ps.errorf(path+".target", "压缩文件位于源路径中，会把之前的压缩文件也压缩进去: %s", item.Target)
+			}
+		}
+		checkUser(&ps, path+".target_user", item.TargetUser)
+		checkPercent(&ps, path+".parity_percent", item.ParityPercent)
+		ps.nonNegative(path+".full_interval_days", int64(item.FullIntervalDays))
+		ps.nonNegative(path+".keep", int64(item.Keep))
+		ps.nonNegative(path+".workers", int64(item.Workers))
+		ps.nonNegative(path+".max_total_bytes", item.MaxTotalBytes)
+		ps.nonNegative(path+".remote_keep", int64(item.RemoteKeep))
+		if item.RemoteKeep > 0 {
+			switch {
+			case item.Upload == "":
+				ps.errorf(path+".remote_keep", "没有设置 upload，不会删除任何副本")
+			case item.Keep == 0 && item.MaxTotalBytes == 0:
+				ps.errorf(path+".remote_keep", "需要同时设置 keep 或 max_total_bytes，否则每次上传都覆盖同名的副本")
+			}
+		}
+	}
+
+	ps.nonNegative("shutdown_timeout_seconds", int64(c.ShutdownTimeout))
+	ps.nonNegative("update_check.interval_hours", int64(c.UpdateCheck.IntervalHours))
+	ps.nonNegative("scrub.interval_days", int64(c.Scrub.IntervalDays))
+
+	if err := i18n.Check(c.Language); err != nil {
+		ps.errorf("language", "%v", err)
+	}
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		ps.errorf("log.level", "%v", err)
+	}
+	if err := logging.CheckFormat(c.Log.Format); err != nil {
+		ps.errorf("log.format", "%v", err)
+	}
+	if c.Log.File != "" {
+		checkDir(&ps, "log.file", filepath.Dir(c.Log.File), "日志目录不存在，启动时自动创建")
+	}
+	ps.nonNegative("log.max_size_mb", int64(c.Log.MaxSizeMB))
+	ps.nonNegative("log.max_files", int64(c.Log.MaxFiles))
+	ps.nonNegative("log.max_age_days", int64(c.Log.MaxAgeDays))
+	modules := make([]string, 0, len(c.Log.Modules))
+	for name := range c.Log.Modules {
+		modules = append(modules, name)
+	}
+	sort.Strings(modules)
+	for _, name := range modules {
+		if _, err := logging.ParseLevel(c.Log.Modules[name]); err != nil {
+			ps.errorf("log.modules."+name, "%v", err)
+		}
+	}
+	return ps
+}
+
+// checkDir 检查路径是否为目录，不存在时给出 missing 提醒
+func checkDir(ps *problems, path, dir, missing string) {
+	info, err := os.Stat(dir)
+	switch {
+	case os.IsNotExist(err):
+		ps.warnf(path, "%s: %s", i18n.T(missing), dir)
+	case err != nil:
+		ps.errorf(path, "无法访问: %v", err)
+	case !info.IsDir():
+		ps.errorf(path, "不是目录: %s", dir)
+	}
+}
+
+// checkSMB 检查挂载 smb:// 目标需要的条件
+// 程序不包含 SMB 协议的实现：Linux 通过 cifs-utils 的 mount.cifs 挂载，需要以 root 运行；macOS 和 Windows 使用系统自带的命令
+func checkSMB(ps *problems, path string) {
+	switch runtime.GOOS {
+	case "darwin", "windows":
+	case "linux":
+		if os.Geteuid() != 0 {
+			ps.errorf(path, "Linux 上通过 mount.cifs 挂载 SMB 共享，需要以 root 运行；也可以先挂载共享后使用本地目录")
+		}
+		if !hasMountCifs() {
+			ps.errorf(path, "Linux 上挂载 SMB 共享需要安装 cifs-utils（Debian/Ubuntu 为 apt install cifs-utils）；也可以先挂载共享后使用本地目录")
+		}
+	default:
+		ps.errorf(path, "当前系统不支持 smb:// 目标，请先挂载共享后使用本地目录")
+	}
+}
+
+// hasMountCifs mount 在 /sbin 中查找挂载助手，PATH 中没有 /sbin 时同样能找到
+func hasMountCifs() bool {
+	if _, err := exec.LookPath("mount.cifs"); err == nil {
+		return true
+	}
+	for _, dir := range []string{"/sbin", "/usr/sbin"} {
+		if _, err := os.Stat(filepath.Join(dir, "mount.cifs")); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// checkVolume 检查按卷指定的源目录，卷没有插入时只是提示
+func checkVolume(ps *problems, path string, task Config) {
+	uuid, label, _ := task.SourceVolume()
+	if uuid == "" && label == "" {
+		ps.errorf(path, "UUID 或卷标不能为空: %s", task.SourceDir)
+		return
+	}
+	dir, err := task.ResolveSource()
+	switch {
+	case errors.Is(err, mount.ErrVolumeMissing) && task.Automount.Enabled:
+		ps.warnf(path, "卷没有挂载，插入后自动挂载并备份: %s", task.SourceDir)
+	case errors.Is(err, mount.ErrVolumeMissing):
+		ps.warnf(path, "卷没有插入或没有挂载，插入后才会备份: %s", task.SourceDir)
+	case err != nil:
+		ps.errorf(path, "%v", err)
+	default:
+		checkDir(ps, path, dir, "卷中没有该目录，创建后才会备份")
+	}
+}
+
+// checkUser 检查 target_user 的格式为 uid:gid，都是非负整数
+func checkUser(ps *problems, path, user string) {
+	if user == "" {
+		return
+	}
+	uid, gid, ok := strings.Cut(user, ":")
+	if !ok {
+		ps.errorf(path, "格式应为 uid:gid，例如 1000:1000: %q", user)
+		return
+	}
+	for _, s := range []string{uid, gid} {
+		if n, err := strconv.Atoi(s); err != nil || n < 0 {
+			ps.errorf(path, "uid 和 gid 需要是非负整数，例如 1000:1000: %q", user)
+			return
+		}
+	}
+}
+
+// checkPercent 检查恢复数据的冗余比例在 0 到 100 之间
+func checkPercent(ps *problems, path string, pct int) {
+	if pct < 0 || pct > 100 {
+		ps.errorf(path, "需要在 0 到 100 之间: %d", pct)
+	}
+}
+
+// cleanPath 转换为绝对路径并清理，用于比较两个路径是否相同
+func cleanPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return filepath.Clean(p)
+}
+
+// within 判断 child 是否为 parent 或位于其中
+func within(parent, child string) bool {
+	rel, err := filepath.Rel(cleanPath(parent), cleanPath(child))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// overlaps 判断两个目录是否相同或互相包含
+func overlaps(a, b string) bool {
+	return within(a, b) || within(b, a)
+}
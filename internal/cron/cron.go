@@ -0,0 +1,166 @@
+// Package cron 解析 cron 表达式并计算下一次运行的时间
+// 支持标准的 5 个字段（分 时 日 月 周），每个字段可以是 *、数字、范围 a-b、步长 */n 或 a-b/n 以及用逗号分隔的列表
+// 月和周可以使用英文缩写（jan、mon 等），周日为 0 或 7；另外支持 @hourly、@daily、@weekly、@monthly 和 @yearly
+package cron
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// Schedule 解析后的 cron 表达式
+type Schedule struct {
+	minute, hour, dom, month, dow uint64 // 每个字段允许的取值，按位记录
+	domStar, dowStar              bool   // 日和周是否为 *，两者都有限制时满足其一即可
+}
+
+// field 一个字段的取值范围
+type field struct {
+	name     string
+	min, max int
+	names    []string // 从 min 开始的英文缩写
+}
+
+var fields = []field{
+	{name: "分", min: 0, max: 59},
+	{name: "时", min: 0, max: 23},
+	{name: "日", min: 1, max: 31},
+	{name: "月", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "周", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+var shortcuts = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+// Parse 解析 cron 表达式，时间使用本地时区
+func Parse(expr string) (*Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	if s, ok := shortcuts[strings.ToLower(spec)]; ok {
+		spec = s
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, i18n.Errorf("cron 表达式应有 5 个字段（分 时 日 月 周）: %q", expr)
+	}
+	var bits [5]uint64
+	for i, part := range parts {
+		b, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, i18n.Errorf("cron 表达式 %q 的%s字段无效: %w", expr, i18n.T(fields[i].name), err)
+		}
+		bits[i] = b
+	}
+	// 周日可以写为 0 或 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+	return &Schedule{
+		minute: bits[0], hour: bits[1], dom: bits[2], month: bits[3], dow: bits[4],
+		domStar: strings.HasPrefix(parts[2], "*"), dowStar: strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+// parseField 解析一个字段中用逗号分隔的各项
+func parseField(s string, f field) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(s, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, i18n.Errorf("步长无效: %s", item)
+			}
+			step = n
+		}
+		lo, hi := f.min, f.max
+		if rangePart != "*" {
+			a, b, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = value(a, f); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = value(b, f); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// 5/15 表示从 5 开始每 15 一次
+				hi = f.max
+			}
+			if lo > hi {
+				return 0, i18n.Errorf("范围无效: %s", item)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// value 解析数字或英文缩写
+func value(s string, f field) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < f.min || n > f.max {
+		return 0, i18n.Errorf("取值应在 %d 到 %d 之间: %s", f.min, f.max, s)
+	}
+	return n, nil
+}
+
+// Next 返回 t 之后（不含 t 所在的分钟）第一个满足表达式的时间，5 年内没有满足的时间时返回零值
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			// 夏令时结束时同一小时出现两次，time.Date 可能返回更早的时间
+			if !next.After(t) {
+				next = t.Add(time.Hour - time.Duration(t.Minute())*time.Minute)
+			}
+			t = next
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches 日和周都有限制时满足其一即可，与常见的 cron 实现一致
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,299 @@
+// Package delta 实现类似 rsync 的增量传输：接收方发送基准文件的块签名，
+// 发送方用滚动校验和找出未变化的块，只传输引用和变化的数据
+package delta
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+const (
+	deltaMagic = "NEOD"
+
+	opEnd  = 0
+	opCopy = 1 // 复制基准文件中的一段：偏移量、长度
+	opData = 2 // 新数据：长度、内容
+
+	// 连续未匹配的数据累积到该大小时先发送，避免占用过多内存
+	maxLiteral = 1 << 20
+)
+
+// Stats 一次增量传输中复用和新传输的字节数
+type Stats struct {
+	Matched int64
+	Literal int64
+}
+
+// Diff 读取新文件，根据签名生成增量数据写入 w
+func Diff(sig *Signature, r io.Reader, w io.Writer) (Stats, error) {
+	var stats Stats
+	bs := sig.BlockSize
+	index := make(map[uint32][]int, len(sig.Blocks))
+	for i, b := range sig.Blocks {
+		index[b.Weak] = append(index[b.Weak], i)
+	}
+
+	enc := newEncoder(w)
+	br := bufio.NewReaderSize(r, 256<<10)
+	// buf[:lit] 是尚未发送的新数据，buf[lit:] 是当前比较的窗口
+	buf := make([]byte, 0, maxLiteral+bs)
+	lit := 0
+	var a, b uint32
+	eof := false
+
+	refill := func() error {
+		n, err := io.ReadFull(br, buf[len(buf):len(buf)+bs])
+		buf = buf[:len(buf)+n]
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			eof = true
+		} else if err != nil {
+			return i18n.Errorf("读取文件失败: %w", err)
+		}
+		a, b = sums(buf[lit:])
+		return nil
+	}
+	flush := func() error {
+		if lit == 0 {
+			return nil
+		}
+		stats.Literal += int64(lit)
+		if err := enc.data(buf[:lit]); err != nil {
+			return err
+		}
+		buf = append(buf[:0], buf[lit:]...)
+		lit = 0
+		return nil
+	}
+
+	if err := refill(); err != nil {
+		return stats, err
+	}
+	for {
+		win := buf[lit:]
+		if len(win) > 0 {
+			if idx, ok := sig.match(index, win, a&0xffff|b<<16); ok {
+				if err := flush(); err != nil {
+					return stats, err
+				}
+				if err := enc.copy(int64(idx)*int64(bs), int64(len(win))); err != nil {
+					return stats, err
+				}
+				stats.Matched += int64(len(win))
+				buf = buf[:0]
+				if eof {
+					break
+				}
+				if err := refill(); err != nil {
+					return stats, err
+				}
+				continue
+			}
+		}
+
+		if eof {
+			if len(win) == 0 {
+				break
+			}
+			// 文件末尾不足一个窗口，逐字节缩小窗口，仍可能与基准文件的最后一块匹配
+			out := uint32(win[0])
+			a -= out
+			b -= uint32(len(win)) * out
+			lit++
+			continue
+		}
+
+		c, err := br.ReadByte()
+		if err == io.EOF {
+			eof = true
+			continue
+		}
+		if err != nil {
+			return stats, i18n.Errorf("读取文件失败: %w", err)
+		}
+		out := uint32(buf[lit])
+		buf = append(buf, c)
+		lit++
+		a = a - out + uint32(c)
+		b = b - uint32(bs)*out + a
+		if lit >= maxLiteral {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return stats, err
+	}
+	return stats, enc.end()
+}
+
+// match 弱校验和相同时再比较强校验和，确认窗口与基准文件中的某个块内容相同
+func (s *Signature) match(index map[uint32][]int, win []byte, weak uint32) (int, bool) {
+	candidates := index[weak]
+	if len(candidates) == 0 {
+		return 0, false
+	}
+	strong := strongSum(win)
+	for _, i := range candidates {
+		if s.blockLen(i) == len(win) && s.Blocks[i].Strong == strong {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// encoder 写出增量数据，相邻的复制操作合并为一个
+type encoder struct {
+	w       *bufio.Writer
+	started bool
+	copyOff int64
+	copyLen int64
+}
+
+func newEncoder(w io.Writer) *encoder {
+	return &encoder{w: bufio.NewWriterSize(w, 64<<10)}
+}
+
+func (e *encoder) header() {
+	if !e.started {
+		e.w.WriteString(deltaMagic)
+		e.started = true
+	}
+}
+
+func (e *encoder) uvarint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	e.w.Write(tmp[:binary.PutUvarint(tmp[:], v)])
+}
+
+func (e *encoder) copy(off, n int64) error {
+	e.header()
+	if e.copyLen > 0 && e.copyOff+e.copyLen == off {
+		e.copyLen += n
+		return nil
+	}
+	if err := e.flushCopy(); err != nil {
+		return err
+	}
+	e.copyOff, e.copyLen = off, n
+	return nil
+}
+
+func (e *encoder) flushCopy() error {
+	if e.copyLen == 0 {
+		return nil
+	}
+	e.w.WriteByte(opCopy)
+	e.uvarint(uint64(e.copyOff))
+	e.uvarint(uint64(e.copyLen))
+	e.copyLen = 0
+	return nil
+}
+
+func (e *encoder) data(p []byte) error {
+	e.header()
+	if err := e.flushCopy(); err != nil {
+		return err
+	}
+	e.w.WriteByte(opData)
+	e.uvarint(uint64(len(p)))
+	_, err := e.w.Write(p)
+	return err
+}
+
+func (e *encoder) end() error {
+	e.header()
+	e.flushCopy()
+	e.w.WriteByte(opEnd)
+	return e.w.Flush()
+}
+
+// Apply 根据基准文件和增量数据重建新文件写入 w
+func Apply(base io.ReaderAt, r io.Reader, w io.Writer) error {
+	return decode(r, func(off, n int64) error {
+		copied, err := io.Copy(w, io.NewSectionReader(base, off, n))
+		if err != nil {
+			return i18n.Errorf("复制基准数据失败: %w", err)
+		}
+		if copied != n {
+			return i18n.New("增量数据引用超出基准文件范围")
+		}
+		return nil
+	}, func(data io.Reader, n int64) error {
+		_, err := io.CopyN(w, data, n)
+		return err
+	})
+}
+
+// Patch 与 Apply 相同，但 w 中已经是基准文件的内容（例如基准文件的克隆），
+// 引用的位置与写入的位置相同时跳过，只写入变化和移动过的数据，返回写入的字节数
+func Patch(base io.ReaderAt, r io.Reader, w io.WriterAt) (int64, error) {
+	var pos, written int64
+	err := decode(r, func(off, n int64) error {
+		if off != pos {
+			copied, err := io.Copy(io.NewOffsetWriter(w, pos), io.NewSectionReader(base, off, n))
+			if err != nil {
+				return i18n.Errorf("复制基准数据失败: %w", err)
+			}
+			if copied != n {
+				return i18n.New("增量数据引用超出基准文件范围")
+			}
+			written += n
+		}
+		pos += n
+		return nil
+	}, func(data io.Reader, n int64) error {
+		if _, err := io.CopyN(io.NewOffsetWriter(w, pos), data, n); err != nil {
+			return err
+		}
+		pos += n
+		written += n
+		return nil
+	})
+	return written, err
+}
+
+// decode 读取增量数据，依次对每个操作调用 copyFn 或 dataFn，dataFn 需要从 data 中读取 n 字节
+func decode(r io.Reader, copyFn func(off, n int64) error, dataFn func(data io.Reader, n int64) error) error {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(deltaMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != deltaMagic {
+		return i18n.New("增量数据格式无效")
+	}
+	for {
+		op, err := br.ReadByte()
+		if err != nil {
+			return i18n.Errorf("读取增量数据失败: %w", err)
+		}
+		switch op {
+		case opEnd:
+			return nil
+		case opCopy:
+			off, err := binary.ReadUvarint(br)
+			if err != nil {
+				return i18n.Errorf("读取增量数据失败: %w", err)
+			}
+			n, err := binary.ReadUvarint(br)
+			if err != nil {
+				return i18n.Errorf("读取增量数据失败: %w", err)
+			}
+			if err := copyFn(int64(off), int64(n)); err != nil {
+				return err
+			}
+		case opData:
+			n, err := binary.ReadUvarint(br)
+			if err != nil {
+				return i18n.Errorf("读取增量数据失败: %w", err)
+			}
+			if err := dataFn(br, int64(n)); err != nil {
+				return i18n.Errorf("读取增量数据失败: %w", err)
+			}
+		default:
+			return i18n.Errorf("未知的增量操作: %d", op)
+		}
+	}
+}
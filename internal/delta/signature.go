@@ -0,0 +1,144 @@
+package delta
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+const (
+	minBlockSize = 4 << 10
+	maxBlockSize = 1 << 20
+
+	signatureMagic = "NEOS"
+)
+
+// Block 基准文件中一个块的弱校验和与强校验和
+type Block struct {
+	Weak   uint32
+	Strong [16]byte
+}
+
+// Signature 基准文件的块签名，接收方生成后发给发送方用于查找未变化的块
+type Signature struct {
+	BlockSize int
+	FileSize  int64
+	Blocks    []Block
+}
+
+// BlockSizeFor 块大小取文件大小的平方根，使签名大小和匹配粒度保持平衡
+func BlockSizeFor(size int64) int {
+	bs := int(math.Sqrt(float64(size)))
+	bs = (bs + 1023) &^ 1023
+	return min(max(bs, minBlockSize), maxBlockSize)
+}
+
+// NewSignature 读取基准文件并计算每个块的校验和
+func NewSignature(r io.Reader, blockSize int) (*Signature, error) {
+	if blockSize <= 0 {
+		return nil, i18n.Errorf("块大小无效: %d", blockSize)
+	}
+	sig := &Signature{BlockSize: blockSize}
+	buf := make([]byte, blockSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			sig.Blocks = append(sig.Blocks, Block{Weak: weakSum(buf[:n]), Strong: strongSum(buf[:n])})
+			sig.FileSize += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return sig, nil
+		}
+		if err != nil {
+			return nil, i18n.Errorf("读取基准文件失败: %w", err)
+		}
+	}
+}
+
+// blockLen 返回第 i 个块的长度，最后一个块可能不足一个块大小
+func (s *Signature) blockLen(i int) int {
+	if i == len(s.Blocks)-1 {
+		return int(s.FileSize - int64(i)*int64(s.BlockSize))
+	}
+	return s.BlockSize
+}
+
+// WriteTo 按二进制格式写出签名
+func (s *Signature) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(signatureMagic)
+	var tmp [binary.MaxVarintLen64]byte
+	bw.Write(tmp[:binary.PutUvarint(tmp[:], uint64(s.BlockSize))])
+	bw.Write(tmp[:binary.PutUvarint(tmp[:], uint64(s.FileSize))])
+	bw.Write(tmp[:binary.PutUvarint(tmp[:], uint64(len(s.Blocks)))])
+	for _, b := range s.Blocks {
+		binary.BigEndian.PutUint32(tmp[:4], b.Weak)
+		bw.Write(tmp[:4])
+		bw.Write(b.Strong[:])
+	}
+	return 0, bw.Flush()
+}
+
+// ReadSignature 读取 WriteTo 写出的签名
+func ReadSignature(r io.Reader) (*Signature, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(signatureMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != signatureMagic {
+		return nil, i18n.New("签名格式无效")
+	}
+	blockSize, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, i18n.Errorf("读取签名失败: %w", err)
+	}
+	fileSize, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, i18n.Errorf("读取签名失败: %w", err)
+	}
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, i18n.Errorf("读取签名失败: %w", err)
+	}
+	if blockSize == 0 || blockSize > maxBlockSize || count != (fileSize+blockSize-1)/blockSize {
+		return nil, i18n.New("签名格式无效")
+	}
+
+	sig := &Signature{BlockSize: int(blockSize), FileSize: int64(fileSize), Blocks: make([]Block, 0, count)}
+	var rec [20]byte
+	for i := uint64(0); i < count; i++ {
+		if _, err := io.ReadFull(br, rec[:]); err != nil {
+			return nil, i18n.Errorf("读取签名失败: %w", err)
+		}
+		b := Block{Weak: binary.BigEndian.Uint32(rec[:4])}
+		copy(b.Strong[:], rec[4:])
+		sig.Blocks = append(sig.Blocks, b)
+	}
+	return sig, nil
+}
+
+// weakSum 类似 rsync 的滚动校验和，窗口移动一个字节时可以 O(1) 更新
+func weakSum(p []byte) uint32 {
+	a, b := sums(p)
+	return a&0xffff | b<<16
+}
+
+// sums 返回滚动校验和的两个分量，滚动更新时需要保留完整的中间值
+func sums(p []byte) (a, b uint32) {
+	n := uint32(len(p))
+	for i, c := range p {
+		a += uint32(c)
+		b += (n - uint32(i)) * uint32(c)
+	}
+	return a, b
+}
+
+// strongSum 弱校验和匹配后用于确认块内容，取 SHA-256 的前 16 字节
+func strongSum(p []byte) [16]byte {
+	sum := sha256.Sum256(p)
+	var s [16]byte
+	copy(s[:], sum[:16])
+	return s
+}
@@ -0,0 +1,50 @@
+package dryrun
+
+import (
+	"sync/atomic"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+)
+
+// logger dryrun 模块的日志
+var logger = logging.New("dryrun")
+
+// 演练模式在启动时由命令行开启，对整个进程生效
+var enabled atomic.Bool
+
+// Enable 开启演练模式，之后复制、删除、修改所有者和写入压缩文件等操作只记录日志，不实际执行
+func Enable() {
+	enabled.Store(true)
+}
+
+// Enabled 是否处于演练模式
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Skip 处于演练模式时记录本应执行的操作并返回 true，调用者据此跳过该操作
+func Skip(format string, args ...any) bool {
+	if !enabled.Load() {
+		return false
+	}
+	logger.Infof("[演练] %s", i18n.Sprintf(format, args...))
+	return true
+}
+
+// Task 单个任务的演练模式，任务配置了演练或整个进程处于演练模式时生效
+type Task bool
+
+// Enabled 该任务是否处于演练模式
+func (t Task) Enabled() bool {
+	return bool(t) || enabled.Load()
+}
+
+// Skip 与 Skip 相同，只是同时考虑任务自己的配置
+func (t Task) Skip(format string, args ...any) bool {
+	if !t.Enabled() {
+		return false
+	}
+	logger.Infof("[演练] %s", i18n.Sprintf(format, args...))
+	return true
+}
@@ -0,0 +1,130 @@
+package dupes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/lucasrui/neo-nas/internal/catalog"
+	"github.com/lucasrui/neo-nas/internal/dryrun"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// Group 内容相同的一组已备份文件
+type Group struct {
+	SHA256      string   `json:"sha256"`
+	Size        int64    `json:"size"`
+	Paths       []string `json:"paths"`       // 目标文件路径，第一个为保留的文件
+	Reclaimable int64    `json:"reclaimable"` // 把其余路径替换为硬链接后可以释放的空间，已经互为硬链接的路径不计入
+}
+
+// Find 按目录索引中的哈希找出内容相同的文件，按可释放空间从大到小排列
+// 小于 minSize 的文件和已不存在的文件不计入
+func Find(entries []catalog.Entry, minSize int64) []Group {
+	byHash := make(map[string][]catalog.Entry)
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		if e.SHA256 == "" || e.Size <= 0 || e.Size < minSize || seen[e.Path] {
+			continue
+		}
+		seen[e.Path] = true
+		key := fmt.Sprintf("%s:%d", e.SHA256, e.Size)
+		byHash[key] = append(byHash[key], e)
+	}
+
+	var groups []Group
+	for _, list := range byHash {
+		if len(list) < 2 {
+			continue
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+		g := Group{SHA256: list[0].SHA256, Size: list[0].Size}
+		var files []os.FileInfo
+		for _, e := range list {
+			info, err := os.Stat(e.Path)
+			if err != nil || info.Size() != e.Size {
+				continue
+			}
+			g.Paths = append(g.Paths, e.Path)
+			if !sameAsAny(info, files) {
+				files = append(files, info)
+			}
+		}
+		if len(g.Paths) < 2 {
+			continue
+		}
+		g.Reclaimable = int64(len(files)-1) * g.Size
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Reclaimable != groups[j].Reclaimable {
+			return groups[i].Reclaimable > groups[j].Reclaimable
+		}
+		return groups[i].Paths[0] < groups[j].Paths[0]
+	})
+	return groups
+}
+
+func sameAsAny(info os.FileInfo, files []os.FileInfo) bool {
+	for _, f := range files {
+		if os.SameFile(info, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// Link 把组内其余路径替换为指向第一个文件的硬链接，返回释放的空间
+// 替换前重新计算哈希，目录索引过期或文件已被修改时跳过；跨文件系统、不可变的文件无法替换，返回错误并继续处理其余路径
+func Link(g Group) (int64, []error) {
+	keep := g.Paths[0]
+	keepInfo, err := os.Stat(keep)
+	if err != nil {
+		return 0, []error{i18n.Errorf("读取文件信息失败: %w", err)}
+	}
+	if sum, err := catalog.HashFile(keep); err != nil || sum != g.SHA256 {
+		return 0, []error{i18n.Errorf("文件内容与目录索引不一致，跳过: %s", keep)}
+	}
+
+	var freed int64
+	var errs []error
+	for _, path := range g.Paths[1:] {
+		info, err := os.Stat(path)
+		if err != nil {
+			errs = append(errs, i18n.Errorf("读取文件信息失败: %w", err))
+			continue
+		}
+		if os.SameFile(keepInfo, info) {
+			continue
+		}
+		if sum, err := catalog.HashFile(path); err != nil || sum != g.SHA256 {
+			errs = append(errs, i18n.Errorf("文件内容与目录索引不一致，跳过: %s", path))
+			continue
+		}
+		if dryrun.Skip("替换为硬链接: %s -> %s", path, keep) {
+			freed += g.Size
+			continue
+		}
+		if err := replaceWithLink(keep, path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		freed += g.Size
+	}
+	return freed, errs
+}
+
+// replaceWithLink 先在同一目录中创建临时硬链接再重命名覆盖，失败时原文件保持不变
+func replaceWithLink(keep, path string) error {
+	tmp := filepath.Join(filepath.Dir(path), ".neo-link-"+filepath.Base(path))
+	os.Remove(tmp)
+	if err := os.Link(keep, tmp); err != nil {
+		return i18n.Errorf("创建硬链接失败 %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return i18n.Errorf("替换文件失败 %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package history
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/logging"
+)
+
+// logger history 模块的日志
+var logger = logging.New("history")
+
+// 任务类型
+const (
+	KindBackup = "backup"
+	KindZip    = "zip"
+)
+
+// 运行结果
+const (
+	ResultSuccess = "success"
+	ResultFailed  = "failed"
+)
+
+// Run 一个任务最近一次运行的结果
+type Run struct {
+	Kind    string    `json:"kind"`
+	Host    string    `json:"host,omitempty"` // 配置目录被多台机器共用时区分各自的记录
+	Source  string    `json:"source"`
+	Target  string    `json:"target"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Result  string    `json:"result"`
+	Error   string    `json:"error,omitempty"`
+	Total   int       `json:"total,omitempty"`
+	Success int       `json:"success,omitempty"`
+	Failed  int       `json:"failed,omitempty"`
+	Skipped int       `json:"skipped,omitempty"`
+}
+
+func (r Run) key() string {
+	return r.Kind + "|" + r.Host + "|" + r.Source + "|" + r.Target
+}
+
+// Store 把每个任务最近一次运行的结果保存在配置目录中，供 list 命令读取
+// 守护进程和单次运行可能同时写入，每次记录都重新读取后写回
+type Store struct {
+	file string
+}
+
+var mu sync.Mutex
+
+// Open 返回记录文件对应的存储，file 为空时不记录
+func Open(file string) *Store {
+	return &Store{file: file}
+}
+
+func (s *Store) load() map[string]Run {
+	runs := make(map[string]Run)
+	data, err := os.ReadFile(s.file)
+	if err != nil {
+		return runs
+	}
+	if err := json.Unmarshal(data, &runs); err != nil {
+		logger.Warnf("运行记录文件损坏，已忽略: %v", err)
+	}
+	return runs
+}
+
+// Record 保存一次运行的结果，覆盖同一任务之前的记录
+func (s *Store) Record(r Run) {
+	if s == nil || s.file == "" {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	runs := s.load()
+	runs[r.key()] = r
+	data, err := json.MarshalIndent(runs, "", "  ")
+	if err == nil {
+		tmp := s.file + ".tmp"
+		if err = os.WriteFile(tmp, data, 0644); err == nil {
+			err = os.Rename(tmp, s.file)
+		}
+	}
+	if err != nil {
+		logger.Errorf("保存运行记录失败: %v", err)
+	}
+}
+
+// Last 返回任务最近一次运行的结果
+func (s *Store) Last(kind, host, source, target string) (Run, bool) {
+	if s == nil || s.file == "" {
+		return Run{}, false
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	r, ok := s.load()[Run{Kind: kind, Host: host, Source: source, Target: target}.key()]
+	return r, ok
+}
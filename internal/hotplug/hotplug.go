@@ -0,0 +1,18 @@
+// Package hotplug 在插入或拔出磁盘时通知监控立即检查源目录，不需要等到下一次定期检查
+package hotplug
+
+import (
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+)
+
+// logger hotplug 模块的日志
+var logger = logging.New("hotplug")
+
+// ErrUnsupported 当前平台不支持磁盘插拔通知，只按 poll_interval_seconds 定期检查
+var ErrUnsupported = i18n.New("当前平台不支持磁盘插拔通知")
+
+// Watch 在后台监听磁盘的插入和拔出，每次变化后调用 changed，stop 关闭后停止
+func Watch(changed func(), stop <-chan struct{}) error {
+	return watch(changed, stop)
+}
@@ -0,0 +1,138 @@
+package hotplug
+
+import (
+	"bytes"
+	"syscall"
+)
+
+// 插入 U 盘时内核先发出块设备的 uevent，之后 udev、udisks 或 fstab 才会挂载，挂载完成时 mountinfo 变化
+// 两者都监听：uevent 用于及时发现拔出（挂载点可能还在），mountinfo 用于在挂载完成后立即开始扫描
+// 容器中通常收不到 uevent，只监听 mountinfo 也能工作
+
+// ueventGroup 内核 uevent 的多播组，udev 处理后转发的消息在组 2，格式不同
+const ueventGroup = 1
+
+func watch(changed func(), stop <-chan struct{}) error {
+	ep, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return err
+	}
+	// mountinfo 在挂载表变化时报告 EPOLLPRI
+	// 不使用 os.Open，否则描述符同时加入运行时的 epoll，变化可能被运行时的 epoll 先取走
+	mounts, mountErr := syscall.Open("/proc/self/mountinfo", syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if mountErr != nil {
+		mounts = -1
+	} else {
+		mountErr = syscall.EpollCtl(ep, syscall.EPOLL_CTL_ADD, mounts, &syscall.EpollEvent{Events: syscall.EPOLLPRI | syscall.EPOLLERR, Fd: int32(mounts)})
+	}
+	uevent, ueventErr := openUevent()
+	if ueventErr == nil {
+		ueventErr = syscall.EpollCtl(ep, syscall.EPOLL_CTL_ADD, uevent, &syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: int32(uevent)})
+	}
+	if ueventErr != nil {
+		logger.Debugf("无法接收内核的设备通知: %v", ueventErr)
+	}
+	if mountErr != nil && ueventErr != nil {
+		closeAll(ep, mounts, uevent)
+		return mountErr
+	}
+	// stop 关闭后关闭管道的写端，读端变为可读，唤醒 EpollWait
+	var wake [2]int
+	if err := syscall.Pipe2(wake[:], syscall.O_CLOEXEC); err != nil {
+		closeAll(ep, mounts, uevent)
+		return err
+	}
+	if err := syscall.EpollCtl(ep, syscall.EPOLL_CTL_ADD, wake[0], &syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: int32(wake[0])}); err != nil {
+		closeAll(ep, mounts, uevent, wake[0], wake[1])
+		return err
+	}
+	go func() {
+		<-stop
+		syscall.Close(wake[1])
+	}()
+	go func() {
+		defer closeAll(ep, mounts, uevent, wake[0])
+		events := make([]syscall.EpollEvent, 4)
+		buf := make([]byte, 16<<10)
+		for {
+			n, err := syscall.EpollWait(ep, events, -1)
+			if err == syscall.EINTR {
+				continue
+			}
+			if err != nil {
+				logger.Warnf("监听磁盘插拔失败: %v", err)
+				return
+			}
+			notify := false
+			for _, ev := range events[:n] {
+				switch int(ev.Fd) {
+				case wake[0]:
+					return
+				case mounts:
+					logger.Debugf("挂载点发生变化")
+					notify = true
+				case uevent:
+					size, _, err := syscall.Recvfrom(uevent, buf, 0)
+					if err == nil && blockEvent(buf[:size]) {
+						notify = true
+					}
+				}
+			}
+			if notify {
+				changed()
+			}
+		}
+	}()
+	return nil
+}
+
+// openUevent 打开接收内核 uevent 的 netlink 套接字，返回描述符，失败时返回 -1
+func openUevent() (int, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK, syscall.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return -1, err
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: ueventGroup}); err != nil {
+		syscall.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+// blockEvent 解析一条 uevent，块设备的插入和拔出记录日志并返回 true
+// 消息为 "动作@路径" 加上以 \0 分隔的 KEY=VALUE
+func blockEvent(msg []byte) bool {
+	fields := bytes.Split(msg, []byte{0})
+	if len(fields) == 0 || !bytes.Contains(fields[0], []byte("@")) {
+		return false
+	}
+	env := make(map[string]string, len(fields))
+	for _, f := range fields[1:] {
+		if k, v, ok := bytes.Cut(f, []byte("=")); ok {
+			env[string(k)] = string(v)
+		}
+	}
+	if env["SUBSYSTEM"] != "block" {
+		return false
+	}
+	switch env["ACTION"] {
+	case "add":
+		logger.Infof("检测到磁盘插入: %s", "/dev/"+env["DEVNAME"])
+	case "remove":
+		logger.Infof("检测到磁盘拔出: %s", "/dev/"+env["DEVNAME"])
+	case "change":
+		// 读卡器插入或取出存储卡
+	default:
+		return false
+	}
+	return true
+}
+
+// closeAll 关闭描述符，打开失败的为 -1
+func closeAll(fds ...int) {
+	for _, fd := range fds {
+		if fd >= 0 {
+			syscall.Close(fd)
+		}
+	}
+}
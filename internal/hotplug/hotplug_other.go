@@ -0,0 +1,7 @@
+//go:build !linux && !windows
+
+package hotplug
+
+func watch(changed func(), stop <-chan struct{}) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,56 @@
+package hotplug
+
+import (
+	"syscall"
+	"time"
+)
+
+var procGetLogicalDrives = syscall.NewLazyDLL("kernel32.dll").NewProc("GetLogicalDrives")
+
+// driveInterval 检查盘符变化的间隔
+// WM_DEVICECHANGE 需要窗口消息循环，服务中也不一定能收到，盘符的位掩码读取很快，直接定期比较
+const driveInterval = time.Second
+
+func watch(changed func(), stop <-chan struct{}) error {
+	last, err := logicalDrives()
+	if err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(driveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+			drives, err := logicalDrives()
+			if err != nil || drives == last {
+				continue
+			}
+			for i := 0; i < 26; i++ {
+				bit := uint32(1) << i
+				letter := string(rune('A'+i)) + ":"
+				switch {
+				case drives&bit != 0 && last&bit == 0:
+					logger.Infof("检测到磁盘插入: %s", letter)
+				case drives&bit == 0 && last&bit != 0:
+					logger.Infof("检测到磁盘拔出: %s", letter)
+				}
+			}
+			last = drives
+			changed()
+		}
+	}()
+	return nil
+}
+
+// logicalDrives 当前存在的盘符，第 0 位为 A:
+func logicalDrives() (uint32, error) {
+	r, _, err := procGetLogicalDrives.Call()
+	if r == 0 {
+		return 0, err
+	}
+	return uint32(r), nil
+}
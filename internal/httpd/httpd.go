@@ -0,0 +1,61 @@
+package httpd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/logging"
+)
+
+// logger httpd 模块的日志
+var logger = logging.New("httpd")
+
+// Server 内置 HTTP 服务，服务端模式等功能在其上注册各自的路由
+type Server struct {
+	cfg config.HTTPConfig
+	mux *http.ServeMux
+	srv *http.Server
+}
+
+func NewServer(cfg config.HTTPConfig) *Server {
+	mux := http.NewServeMux()
+	return &Server{
+		cfg: cfg,
+		mux: mux,
+		srv: &http.Server{
+			Addr:              cfg.Listen,
+			Handler:           mux,
+			ReadHeaderTimeout: 30 * time.Second,
+		},
+	}
+}
+
+// Handle 注册路由
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start 在后台启动监听
+func (s *Server) Start() {
+	go func() {
+		var err error
+		if s.cfg.TLSCert != "" && s.cfg.TLSKey != "" {
+			logger.Infof("HTTPS 服务已启动: %s", s.cfg.Listen)
+			err = s.srv.ListenAndServeTLS(s.cfg.TLSCert, s.cfg.TLSKey)
+		} else {
+			logger.Infof("HTTP 服务已启动: %s", s.cfg.Listen)
+			err = s.srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Errorf("HTTP 服务异常退出: %v", err)
+		}
+	}()
+}
+
+// Shutdown 停止接收新请求并等待处理中的请求结束
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
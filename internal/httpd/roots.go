@@ -0,0 +1,91 @@
+package httpd
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+)
+
+// Root 对外暴露的一个目录，Name 作为 URL 中的第一级路径
+type Root struct {
+	Name string
+	Path string
+}
+
+// TargetRoots 返回所有本地备份目标目录和服务端接收目录，重名时追加序号
+func TargetRoots(cfg *config.NeoConfig) []Root {
+	var roots []Root
+	seen := make(map[string]bool)
+	add := func(path string) {
+		path = filepath.Clean(path)
+		name := filepath.Base(path)
+		for i := 2; seen[name]; i++ {
+			name = fmt.Sprintf("%s-%d", filepath.Base(path), i)
+		}
+		seen[name] = true
+		roots = append(roots, Root{Name: name, Path: path})
+	}
+	for _, bc := range cfg.BackupConfigs {
+		if bc.TargetDir != "" && bc.IsLocalTarget() {
+			add(bc.TargetDir)
+		}
+	}
+	if cfg.Server.Enabled && cfg.Server.Root != "" {
+		add(cfg.Server.Root)
+	}
+	return roots
+}
+
+// BasicAuth 用户名为空时不做认证
+func BasicAuth(username, password, realm string, next http.Handler) http.Handler {
+	if username == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(u), []byte(username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(p), []byte(password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="`+realm+`"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Resolve 将 URL 中的相对路径转换为根目录下的文件路径
+// 路径可以不存在，但其已存在的最深一级父目录解析符号链接后必须仍在根目录内
+func Resolve(rootPath, sub string) (string, bool) {
+	realRoot, err := filepath.EvalSymlinks(rootPath)
+	if err != nil {
+		return "", false
+	}
+	fullPath := filepath.Join(realRoot, filepath.FromSlash(path.Clean("/"+sub)))
+
+	existing, rest := fullPath, ""
+	for {
+		realPath, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			if realPath != realRoot && !strings.HasPrefix(realPath, realRoot+string(filepath.Separator)) {
+				return "", false
+			}
+			return filepath.Join(realPath, rest), true
+		}
+		if !os.IsNotExist(err) || existing == realRoot {
+			return "", false
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = filepath.Dir(existing)
+	}
+}
+
+// Hidden 程序内部使用的临时文件和目录，不对外暴露
+func Hidden(name string) bool {
+	return strings.HasPrefix(name, ".neo-") || name == ".uploads"
+}
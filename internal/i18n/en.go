@@ -0,0 +1,1142 @@
+package i18n
+
+// en 英文译文，键为源码中的中文原文，按所在的包分组
+var en = map[string]string{
+	// cmd
+	"加载备份状态失败: %w": "Failed to load backup state: %w",
+	"已弹出，可以拔出":     "ejected, safe to unplug",
+	"找不到源目录 %s: %v，可以用 --to 指定恢复到的目录\n":  "Source %s not found: %v, use --to to choose where to restore\n",
+	"每轮复制测试的数据量（MB）":                     "Amount of data per copy test round (MB)",
+	"遍历源目录时最多读取的文件数量":                    "Maximum number of files to read while walking the source directory",
+	"用法: %s bench <源目录> <目标目录> [选项]\n\n": "Usage: %s bench <source> <target> [options]\n\n",
+	"复制测试在目标目录中写入临时文件，测试完成后删除":           "The copy test writes a temporary file in the target directory and deletes it afterwards",
+	"\n选项:":          "\nOptions:",
+	"目标目录不可用: %s":    "Target directory unavailable: %s",
+	"遍历源目录 %s ...\n": "Walking source directory %s ...\n",
+	"  %d 项, 用时 %s, 每秒 %.0f 项, 平均 %s/项\n\n": "  %d entries, took %s, %.0f entries/s, %s per entry\n\n",
+	"复制测试（源目录中没有 1 MB 以上的文件，写入 %s 随机数据）\n":  "Copy test (no file over 1 MB in the source directory, writing %s of random data)\n",
+	"读取测试（%s 的前 %s）\n  %s/s\n\n":            "Read test (%s, first %s)\n  %s/s\n\n",
+	"复制测试（源文件可能已在系统缓存中，主要反映目标的写入速度）":        "Copy test (source files may already be cached by the system, so this mostly reflects target write speed)",
+	"  缓冲区 %-8s %s/s\n": "  buffer %-8s %s/s\n",
+	"哈希速度（单核）":          "Hash speed (single core)",
+	"建议配置":              "Suggested settings",
+	"  \"scan_parallelism\": %d    读取文件信息的延迟较高，同时扫描多个子目录\n":                      "  \"scan_parallelism\": %d    file metadata is slow to read, scan several subdirectories at once\n",
+	"  \"scan_parallelism\": 1    读取文件信息很快，不需要并行扫描":                              "  \"scan_parallelism\": 1    file metadata is fast to read, parallel scanning is not needed",
+	"  \"verify\": \"hash\"        哈希比复制快，比较内容不会明显拖慢校验":                          "  \"verify\": \"hash\"        hashing is faster than copying, comparing contents will not noticeably slow verification",
+	"  \"verify\": \"quick\"       哈希慢于复制，日常校验只比较大小和时间，需要时执行 verify --hash":      "  \"verify\": \"quick\"       hashing is slower than copying, routine checks compare size and time only; run verify --hash when needed",
+	"  \"checksums\": {\"algorithm\": \"md5\"}    写入校验文件时 md5 明显快于 sha256":       "  \"checksums\": {\"algorithm\": \"md5\"}    md5 is noticeably faster than sha256 for writing checksum files",
+	"  \"limit_kbps\": %d    需要为其他程序保留带宽时，可以在 bandwidth.schedules 中按实测速度的一半限速\n": "  \"limit_kbps\": %d    to leave bandwidth for other programs, limit to half the measured speed in bandwidth.schedules\n",
+	"  \"priority\": {\"io_class\": \"idle\"}    目标写入较慢，降低后台复制的 IO 优先级以免影响其他程序":  "  \"priority\": {\"io_class\": \"idle\"}    target writes are slow, lower the IO priority of background copies so other programs are not affected",
+	"只统计不小于该大小的文件，单位字节":                                                          "Only count files at least this size, in bytes",
+	"以 JSON 格式输出": "Output as JSON",
+	"把重复的文件替换为硬链接，只保留每组中的第一个文件":                     "Replace duplicate files with hard links, keeping the first file of each group",
+	"用法: %s dupes [选项]\n\n":                         "Usage: %s dupes [options]\n\n",
+	"统计所有本地备份目标和服务端接收目录中内容相同的文件，不加 --link 时不修改任何文件": "Find files with identical content across all local backup targets and server receive directories; nothing is modified without --link",
+	"程序已停止，%v":                     "Program stopped, %v",
+	"输出失败: %v":                     "Output failed: %v",
+	"%s x %d, 可释放 %s, sha256 %s\n": "%s x %d, %s reclaimable, sha256 %s\n",
+	"没有内容相同的文件":                    "No files with identical content",
+	"\n共 %d 组重复文件，可释放 %s\n":        "\n%d groups of duplicate files, %s reclaimable\n",
+	"重复文件已替换为硬链接，释放 %s":            "Duplicate files replaced with hard links, freed %s",
+	"格式应为 KEY=VALUE: %s":           "Expected KEY=VALUE: %s",
+	"服务名称":                         "Service name",
+	"服务使用的配置目录，默认为当前的 BACKUP_CONFIG_DIR 或平台默认目录": "Configuration directory used by the service, defaults to the current BACKUP_CONFIG_DIR or the platform default",
+	"只输出生成的服务配置，不安装":                             "Only print the generated service configuration, do not install",
+	"服务额外使用的环境变量，格式为 KEY=VALUE，可以重复指定":           "Extra environment variable for the service, as KEY=VALUE; may be repeated",
+	"用法: %s install-service [选项]\n\n":            "Usage: %s install-service [options]\n\n",
+	"生成并安装开机自动启动的系统服务，异常退出后自动重启，需要管理员权限（macOS 安装为当前用户的代理）": "Generate and install a system service that starts at boot and restarts after crashes; requires administrator rights (on macOS it is installed as an agent of the current user)",
+	"获取程序路径失败: %v":                    "Failed to get program path: %v",
+	"配置目录无效: %v":                      "Invalid configuration directory: %v",
+	"配置文件不存在: %v":                     "Configuration file not found: %v",
+	"安装服务失败: %v":                      "Failed to install service: %v",
+	"已安装并启动服务 %s: %s\n":               "Service %s installed and started: %s\n",
+	"创建目录失败: %w":                      "Failed to create directory: %w",
+	"写入服务文件失败: %w":                    "Failed to write service file: %w",
+	"launchctl load 失败: %w, %s":       "launchctl load failed: %w, %s",
+	"Description=Neo NAS 备份服务\n":      "Description=Neo NAS backup service\n",
+	"systemctl %s 失败: %w, %s":         "systemctl %s failed: %w, %s",
+	"当前平台不支持安装系统服务":                   "Installing a system service is not supported on this platform",
+	"Windows 服务不支持 --env，请在系统环境变量中设置": "Windows services do not support --env, set the variables in the system environment instead",
+	"成功":                       "success",
+	"失败":                       "failed",
+	"未运行":                      "not run",
+	"用法: %s list [--json]\n\n": "Usage: %s list [--json]\n\n",
+	"加载配置失败: %v":               "Failed to load configuration: %v",
+	"源目录出现时":                   "when source appears",
+	"cron 表达式无效":               "invalid cron expression",
+	"未启用":                      "disabled",
+	"启动后每 %s":                  "every %s after start",
+	"有新版本可用: %s（当前 %s）%s\n\n": "A new version is available: %s (current %s) %s\n\n",
+	"没有配置任务":                  "No tasks configured",
+	"类型":                      "Type",
+	"任务":                      "Task",
+	"上次运行":                    "Last run",
+	"结果":                      "Result",
+	"扫描/成功/失败/跳过":             "Scanned/OK/Failed/Skipped",
+	"下次运行":                    "Next run",
+	"备份":                      "backup",
+	"压缩":                      "zip",
+	"\n错误:":                   "\nErrors:",
+	"目录不能为空":                  "directory must not be empty",
+	"已添加目录监控: %s":             "Started watching directory: %s",
+	"停止监控失败 %s: %v":           "Failed to stop watching %s: %v",
+	"已移除目录监控: %s":             "Stopped watching directory: %s",
+	"加载配置失败: %w":              "Failed to load configuration: %w",
+	"日志配置错误: %w":              "Invalid log settings: %w",
+	"语言配置错误: %w":              "Invalid language setting: %w",
+	"成功加载配置，配置目录: %s":         "Configuration loaded, config directory: %s",
+	"加载目录索引失败: %w":            "Failed to load directory catalog: %w",
+	"优先级配置错误: %w":             "Invalid priority settings: %w",
+	"退让配置错误: %w":              "Invalid backoff settings: %w",
+	"带宽限制配置错误: %w":            "Invalid bandwidth limit settings: %w",
+	"加密配置错误: %w":              "Invalid encryption settings: %w",
+	"客户端模式配置错误: %w":           "Invalid client mode settings: %w",
+	"客户端模式已启用，服务端: %s":        "Client mode enabled, server: %s",
+	"%w，可以使用 --takeover 停止正在运行的实例后启动":                                   "%w; use --takeover to stop the running instance and start",
+	"用法: %s [通用参数] [命令] [命令的参数]\n\n":                                    "Usage: %s [global options] [command] [command options]\n\n",
+	"不带命令时与 run 相同，以守护进程方式运行，持续监控源目录；各命令的参数见 <命令> -h":                   "Without a command it behaves like run, staying in the foreground as a daemon that watches the source directories; see <command> -h for each command's options",
+	"\n通用参数（写在命令之前）:":                                                   "\nGlobal options (before the command):",
+	"  --config-dir <目录>  配置目录，优先于 BACKUP_CONFIG_DIR":                   "  --config-dir <dir>   configuration directory, takes precedence over BACKUP_CONFIG_DIR",
+	"  --log-level <级别>   日志级别：debug、info（默认）、warn 或 error，优先于配置中的 log": "  --log-level <level>  log level: debug, info (default), warn or error, takes precedence over log in the configuration",
+	"  --log-format <格式>  日志格式：text（默认）或 json，优先于配置中的 log":              "  --log-format <fmt>   log format: text (default) or json, takes precedence over log in the configuration",
+	"  --language <语言>    日志、错误和命令输出的语言：zh（默认）或 en，优先于配置中的 language":    "  --language <lang>    language of logs, errors and command output: zh (default) or en, takes precedence over language in the configuration",
+	"  --dry-run            演练模式，复制、删除、修改所有者和写入压缩文件等操作只记录日志，用于检查新的配置":   "  --dry-run            dry run: copies, deletions, ownership changes and zip writes are only logged, for checking a new configuration",
+	"  --takeover           本机已有实例在运行时，请求其停止后再启动":                       "  --takeover           if an instance is already running on this machine, ask it to stop and then start",
+	"\n命令:": "\nCommands:",
+	"  run         以守护进程方式运行，持续监控源目录": "  run         run as a daemon, continuously watching the source directories",
+	"  once        扫描所有任务一次，执行到期的压缩任务后输出汇总并退出，供 cron 或 systemd 定时器调用，--all-zips 执行所有压缩任务；也可以写作 run-once": "  once        scan all tasks once, run due zip tasks, print a summary and exit, for cron or systemd timers; --all-zips runs every zip task; also available as run-once",
+	"  status      查看正在运行的守护进程中各任务的状态和压缩进度，--json 以 JSON 格式输出":                                           "  status      show the state of each task and zip progress in the running daemon, --json for JSON output",
+	"  validate    检查配置文件，列出每个问题及其 JSON 路径，不启动任何任务":                                                      "  validate    check the configuration file, listing each problem with its JSON path, without starting any task",
+	"  list        列出备份和压缩任务最近一次运行的结果，--json 以 JSON 格式输出":                                                "  list        list the result of the last run of each backup and zip task, --json for JSON output",
+	"  restore     从备份目标或快照中恢复文件，详见 restore -h":                                                          "  restore     restore files from a backup target or snapshot, see restore -h",
+	"  verify      比较源目录和目标目录，列出缺失或不一致的文件":                                                               "  verify      compare source and target directories, listing missing or mismatched files",
+	"  scrub       重新计算目标文件的哈希，发现静默损坏的文件，--repair 修复":                                                    "  scrub       re-hash target files to find silent corruption, --repair to fix it",
+	"  zip         立即执行指定的压缩任务，守护进程正在运行时由其在后台执行":                                                         "  zip         run the given zip tasks now; if the daemon is running it runs them in the background",
+	"  prune       立即按保留策略清理旧快照和同步回收目录":                                                                  "  prune       apply retention now, removing old snapshots and sync trash",
+	"  dupes       统计所有目标中内容相同的文件，--link 替换为硬链接":                                                         "  dupes       find files with identical content across all targets, --link to replace them with hard links",
+	"  bench       测试源目录和目标目录的读写速度，给出配置建议，详见 bench -h":                                                   "  bench       measure read and write speed of the source and target, with suggested settings, see bench -h",
+	"  repair      按恢复数据检查并修复压缩文件和备份文件，--check 只检查":                                                      "  repair      check and repair zip and backup files using recovery data, --check to only check",
+	"  version     输出当前版本，--check 查询是否有新版本":                                                              "  version     print the current version, --check to look for a new version",
+	"  service     安装、卸载、启动或停止 Windows 服务，详见 service -h":                                                 "  service     install, uninstall, start or stop the Windows service, see service -h",
+	"  install-service  生成并安装 systemd 服务、launchd 代理或 Windows 服务，--print 只输出配置":                           "  install-service  generate and install a systemd service, launchd agent or Windows service, --print to only print the configuration",
+	"配置目录":                  "configuration directory",
+	"日志级别":                  "log level",
+	"日志格式":                  "log format",
+	"演练模式":                  "dry run",
+	"请求已在运行的实例停止":           "ask the running instance to stop",
+	"语言":                    "language",
+	"输出当前版本":                "print the current version",
+	"未知的命令: %s\n\n":         "Unknown command: %s\n\n",
+	"用法: %s [通用参数] run\n\n": "Usage: %s [global options] run\n\n",
+	"以守护进程方式运行，持续监控源目录，收到 SIGINT 或 SIGTERM 时退出": "Run as a daemon, continuously watching the source directories; exits on SIGINT or SIGTERM",
+	"正在启动 USB 备份程序 %s...":                       "Starting USB backup program %s...",
+	"演练模式：不会复制、删除或修改任何文件，只记录将要执行的操作":            "Dry run: no files will be copied, deleted or modified, planned operations are only logged",
+	"%v，--takeover 将无法停止本实例":                    "%v; --takeover will not be able to stop this instance",
+	"已配置 %d 个备份任务:":                             "%d backup tasks configured:",
+	"  任务 %d: %s -> %s":                         "  task %d: %s -> %s",
+	"添加目录监控失败 %s: %v":                           "Failed to watch directory %s: %v",
+	"添加同步任务失败 %s <-> %s: %v":                    "Failed to add sync task %s <-> %s: %v",
+	"已添加同步任务: %s <-> %s":                        "Added sync task: %s <-> %s",
+	"添加冷存储迁移失败 %s: %v":                          "Failed to add cold storage tiering %s: %v",
+	"已添加冷存储迁移: %s -> %s, %d 天未使用的文件":            "Added cold storage tiering: %s -> %s, files unused for %d days",
+	"启动服务端模式，接收客户端上传的文件":                        "Starting server mode, receiving files uploaded by clients",
+	"启动服务端模式失败: %v":                             "Failed to start server mode: %v",
+	"压缩任务列表为空，不启动压缩任务":                          "Zip task list is empty, zip tasks not started",
+	"已配置 %d 个压缩任务":                              "%d zip tasks configured",
+	"程序已停止，所有任务都失败":                             "Program stopped, all tasks failed",
+	"重新加载配置失败，继续使用原来的配置: %v":                    "Failed to reload configuration, keeping the previous configuration: %v",
+	"正在停止，等待进行中的任务完成，最长 %s":                     "Stopping, waiting up to %s for running tasks to finish",
+	"等待任务完成超时，强制退出":                             "Timed out waiting for tasks to finish, forcing exit",
+	"仍有文件正在复制的任务: %s":                           "Tasks still copying files: %s",
+	"再次收到中断信号，强制退出":                             "Interrupt received again, forcing exit",
+	"停止 HTTP 服务失败: %v":                          "Failed to stop HTTP server: %v",
+	"程序已停止":                                     "Program stopped",
+	"只列出将要删除的内容，不删除":                            "Only list what would be deleted, do not delete",
+	"用法: %s prune [选项]\n\n":                     "Usage: %s prune [options]\n\n",
+	"按 snapshot 的 keep、keep_daily、keep_weekly 清理旧快照，按 trash_days 清理双向同步的回收目录": "Remove old snapshots according to snapshot keep, keep_daily and keep_weekly, and bidirectional sync trash according to trash_days",
+	"已删除":                "deleted",
+	"删除":                 "deleted",
+	"将删除":                "would delete",
+	"没有需要清理的内容":          "Nothing to clean up",
+	"\n共%s %d 项，释放 %s\n": "\nTotal: %s %d items, freeing %s\n",
+	" 以上（部分快照无法统计大小）":            " or more (some snapshot sizes could not be measured)",
+	"快照配置错误 %s: %v":              "Invalid snapshot settings %s: %v",
+	"快照 %s@%s, %s":               "snapshot %s@%s, %s",
+	"同步任务配置错误 %s <-> %s: %v":     "Invalid sync task settings %s <-> %s: %v",
+	"回收目录 %s, %d 个文件, %s":        "trash %s, %d files, %s",
+	"定期清理，%s%s":                  "Scheduled cleanup, %s%s",
+	"定期清理完成，共 %d 项，释放 %s":        "Scheduled cleanup finished, %d items, freed %s",
+	"大小未知":                       "size unknown",
+	"重新加载配置: %d 个备份任务, %d 个压缩任务": "Reloading configuration: %d backup tasks, %d zip tasks",
+	"除备份、压缩任务和日志设置以外的设置有变化，需要重启后生效": "Settings other than backup tasks, zip tasks and logging have changed and take effect after a restart",
+	"已更新压缩任务，压缩间隔 %d 秒":             "Zip tasks updated, zip interval %d seconds",
+	"配置已重新加载":                                "Configuration reloaded",
+	"只检查，不修复":                                "Only check, do not repair",
+	"用法: %s repair [--check] <文件或目录...>\n\n": "Usage: %s repair [--check] <file or directory...>\n\n",
+	"指定目录时检查其中所有有恢复数据的文件":                    "For a directory, checks every file in it that has recovery data",
+	"路径不存在: %s":                              "Path does not exist: %s",
+	"[无恢复数据] %s\n":                           "[no recovery data] %s\n",
+	"[无法修复] %s: %v\n":                        "[unrepairable] %s: %v\n",
+	"[已修复] %s, %s\n":                         "[repaired] %s, %s\n",
+	"可以修复":                                   "repairable",
+	"无法修复":                                   "unrepairable",
+	"[损坏] %s, %s, %s\n":                      "[damaged] %s, %s, %s\n",
+	"\n共检查 %d 个文件，完好 %d，已修复 %d，损坏 %d\n": "\nChecked %d files: %d intact, %d repaired, %d damaged\n",
+	"%d/%d 块数据损坏":   "%d/%d data blocks damaged",
+	"文件大小改变":        "file size changed",
+	"%d/%d 块恢复数据损坏": "%d/%d recovery blocks damaged",
+	"遍历目录失败: %w":    "Failed to walk directory: %w",
+	"要恢复的备份任务，序号或源目录、目标目录，也可以写在第一个参数中":                               "Backup task to restore, by number, source directory or target directory; may also be given as the first argument",
+	"恢复到的目录，默认恢复到任务的源目录":                                             "Directory to restore to, defaults to the task's source directory",
+	"只恢复匹配规则的文件，规则与 include 相同，例如 '*.jpg'、'photos/2023/**'，可以指定多次":   "Only restore files matching the pattern, same syntax as include, e.g. '*.jpg', 'photos/2023/**'; may be repeated",
+	"从压缩任务生成的压缩文件中恢复，默认恢复到压缩任务的源目录":                                  "Restore from a zip file created by a zip task, defaults to the zip task's source directory",
+	"从指定名称的快照中恢复，默认从目标目录恢复":                                          "Restore from the named snapshot, defaults to the target directory",
+	"已有同名文件时的处理: never、newer 或 always":                               "What to do with existing files of the same name: never, newer or always",
+	"只列出将要恢复的文件，不写入":                                                 "Only list the files that would be restored, do not write",
+	"恢复指定时间点的文件，例如 2024-05-01T00:00":                                 "Restore files as of the given time, e.g. 2024-05-01T00:00",
+	"开启 host_namespace 时恢复哪台机器的备份，默认本机":                              "With host_namespace enabled, which machine's backup to restore, defaults to this machine",
+	"列出任务可以恢复到的时间点，不恢复文件":                                            "List the points in time the task can be restored to, do not restore files",
+	"用法: %s restore <任务序号或目录> [路径...] [--to <目录>] [选项]\n":            "Usage: %s restore <task number or directory> [path...] [--to <dir>] [options]\n",
+	"      %s restore --archive <压缩文件> [路径...] [--to <目录>] [选项]\n\n": "       %s restore --archive <zip file> [path...] [--to <dir>] [options]\n\n",
+	"路径相对于备份根目录，不指定时恢复全部文件":                                          "Paths are relative to the backup root; all files are restored when none are given",
+	"--archive 不能与任务、--snapshot 或 --as-of 同时使用":                      "--archive cannot be combined with a task, --snapshot or --as-of",
+	"--path 规则无效: %v\n":                                "Invalid --path pattern: %v\n",
+	"--as-of 和 --snapshot 不能同时使用":                      "--as-of and --snapshot cannot be used together",
+	"推送到服务端的任务需要在服务端恢复: %s":                            "Tasks pushed to a server must be restored on the server: %s",
+	"暂不支持从 S3 或 rclone 目标恢复，可以使用 S3 工具或 rclone 下载: %s": "Restoring from S3 or rclone targets is not supported yet, download with an S3 tool or rclone: %s",
+	"恢复其他机器的备份时需要用 --to 指定恢复到的目录":                      "Restoring another machine's backup requires --to to specify the destination directory",
+	"未指定 --to，恢复到源目录: %s":                              "--to not given, restoring to the source directory: %s",
+	"任务未配置快照: %s":                                      "Task has no snapshots configured: %s",
+	"开始恢复: %s -> %s":                                   "Starting restore: %s -> %s",
+	"未指定 --to，恢复到压缩任务的源目录: %s":                         "--to not given, restoring to the zip task's source directory: %s",
+	"压缩文件不属于任何压缩任务，需要用 --to 指定恢复到的目录":                  "The zip file does not belong to any zip task, use --to to specify the destination directory",
+	"恢复失败: %v": "Restore failed: %v",
+	"已恢复":      "restored",
+	"将恢复":      "would restore",
+	"\n恢复完成，%s: %d (%d 字节), 跳过: %d, 失败: %d\n": "\nRestore finished, %s: %d (%d bytes), skipped: %d, failed: %d\n",
+	"%s -> %s 可以恢复到的时间点:\n":                   "Points in time %s -> %s can be restored to:\n",
+	"  最新备份":      "  latest backup",
+	"，最近一次扫描完成于 ": ", last scan finished at ",
+	"任务未配置快照，只能按目录索引中的备份时间恢复": "Task has no snapshots configured, can only restore by backup time in the directory catalog",
+	"列出快照失败: %v":    "Failed to list snapshots: %v",
+	"时间未知":          "time unknown",
+	"  %s  快照 %s\n": "  %s  snapshot %s\n",
+	"\n使用 --as-of <时间> 恢复某个时间点的文件，或 --snapshot <名称> 恢复某个快照": "\nUse --as-of <time> to restore files as of a point in time, or --snapshot <name> to restore a snapshot",
+	"使用快照补充已从目标目录删除的文件: %s":                                 "Using snapshots to fill in files deleted from the target directory: %s",
+	"恢复时间点之前没有快照，只按目录索引中的备份时间恢复":                            "No snapshot before the restore point, restoring only by backup time in the directory catalog",
+	"无法解析时间: %s，格式例如 2024-05-01T00:00":                      "Cannot parse time: %s, expected e.g. 2024-05-01T00:00",
+	"任务序号超出范围: %d，共 %d 个备份任务":                               "Task number out of range: %d, there are %d backup tasks",
+	"未找到备份任务: %s":                                           "Backup task not found: %s",
+	"演练模式，只记录将要执行的操作":                                       "Dry run, only log planned operations",
+	"执行所有压缩任务，不管是否到期，没有设置压缩间隔和 cron 时也执行":                   "Run all zip tasks whether due or not, including tasks without a zip interval or cron",
+	"单次运行模式":         "Run-once mode",
+	"同步":             "sync",
+	"冷存储":            "cold storage",
+	"压缩任务未到期，跳过: %s": "Zip task not due, skipped: %s",
+	"\n运行完成，用时 %s\n": "\nRun finished, took %s\n",
+	"跳过":             "skipped",
+	", 扫描: %d, 成功: %d, 失败: %d, 跳过: %d": ", scanned: %d, succeeded: %d, failed: %d, skipped: %d",
+	", 错误: ":    ", error: ",
+	"没有需要执行的任务": "No tasks to run",
+	"按恢复数据修复损坏的文件，没有恢复数据时在源文件没有变化时重新复制":                   "Repair damaged files using recovery data; without recovery data, copy again if the source file is unchanged",
+	"把校验结果以 JSON 格式写入文件，默认写入配置目录中的 .scrub-report":         "Write the scrub result as JSON to a file, defaults to .scrub-report in the configuration directory",
+	"用法: %s scrub [任务序号或目录...] [选项]\n\n":                  "Usage: %s scrub [task number or directory...] [options]\n\n",
+	"不指定任务时校验所有本地和 SMB 备份任务的目标目录，与目录索引中记录的哈希比较":           "Without tasks, scrubs the target directories of all local and SMB backup tasks, comparing against the hashes recorded in the directory catalog",
+	"只能校验本地或 SMB 目标: %s":                                  "Only local or SMB targets can be scrubbed: %s",
+	"没有可以校验的备份任务":                                         "No backup tasks to scrub",
+	"校验完成: %s, 文件: %d, 大小: %s, 问题: %d, 未修复: %d, 用时: %s\n": "Scrub finished: %s, files: %d, size: %s, problems: %d, unrepaired: %d, took: %s\n",
+	"校验失败 %s: %v":  "Scrub failed %s: %v",
+	"开始校验目标文件: %s": "Starting to scrub target files: %s",
+	"校验已中止: %s":    "Scrub aborted: %s",
+	"定期校验完成，目标目录: %d, 问题: %d, 未修复: %d":                         "Scheduled scrub finished, target directories: %d, problems: %d, unrepaired: %d",
+	"读取上一次的校验结果失败: %v":                                         "Failed to read the previous scrub result: %v",
+	"服务使用的配置目录，默认为 BACKUP_CONFIG_DIR 或 %ProgramData%\\neo-nas": "Configuration directory used by the service, defaults to BACKUP_CONFIG_DIR or %ProgramData%\\neo-nas",
+	"用法: %s service <install|uninstall|start|stop> [选项]\n\n":   "Usage: %s service <install|uninstall|start|stop> [options]\n\n",
+	"install 注册开机自动启动的服务，异常退出后自动重启，需要管理员权限":                    "install registers a service that starts at boot and restarts after crashes; requires administrator rights",
+	"已安装服务 %s，配置目录: %s":                                        "Service %s installed, configuration directory: %s",
+	"已卸载服务 %s":                                                 "Service %s uninstalled",
+	"已启动服务 %s":                                                 "Service %s started",
+	"已停止服务 %s":                                                 "Service %s stopped",
+	"未知的服务操作: %s\n\n":                                          "Unknown service action: %s\n\n",
+	"以 JSON 格式输出，与状态接口的内容相同":                                   "Output as JSON, same content as the status API",
+	"用法: %s status [--json]\n\n":                               "Usage: %s status [--json]\n\n",
+	"守护进程没有运行，可以用 list 查看最近一次运行的结果":                            "The daemon is not running; use list to see the results of the last run",
+	"守护进程不支持查询状态，可能是旧版本: %s\n":                                 "The daemon does not support status queries, it may be an older version: %s\n",
+	"正常":        "OK",
+	"有任务失败或未启动": "some tasks failed or did not start",
+	"neo-nas %s，机器: %s，启动于 %s，状态: %s\n": "neo-nas %s, host: %s, started at %s, status: %s\n",
+	"等待源目录":    "waiting for source",
+	"未启动":      "not started",
+	"已暂停":      "paused",
+	"源目录暂时不可用": "source temporarily unavailable",
+	"正在备份":     "backing up",
+	"在线":       "online",
+	"[备份 %d] %s -> %s: %s, 扫描: %d, 成功: %d, 失败: %d, 跳过: %d\n": "[backup %d] %s -> %s: %s, scanned: %d, succeeded: %d, failed: %d, skipped: %d\n",
+	"[压缩 %d] %s -> %s: ":           "[zip %d] %s -> %s: ",
+	"正在压缩 %d%% (%d / %d 字节)":       "zipping %d%% (%d / %d bytes)",
+	", 当前文件: ":                     ", current file: ",
+	", 正在校验":                       ", verifying",
+	", 预计完成: ":                     ", estimated completion: ",
+	"正在压缩":                         "zipping",
+	"下次运行: ":                       "next run: ",
+	"等待运行":                         "waiting to run",
+	"用法: %s validate [--json]\n\n": "Usage: %s validate [--json]\n\n",
+	"检查配置目录中的 config.json，列出语法错误、未知的字段、空路径、不存在的目录、互相包含的源和目标、重复的任务等问题": "Check config.json in the configuration directory, listing syntax errors, unknown fields, empty paths, missing directories, sources and targets that contain each other, duplicate tasks and other problems",
+	"读取配置文件失败: %v\n":    "Failed to read configuration file: %v\n",
+	"配置文件: %s\n":        "Configuration file: %s\n",
+	"没有发现问题":            "No problems found",
+	"共 %d 个错误，%d 个警告\n": "%d errors, %d warnings\n",
+	"缺失":                "missing",
+	"大小不同":              "size differs",
+	"时间不同":              "time differs",
+	"内容不同":              "content differs",
+	"错误":                "error",
+	"比较文件内容，默认使用任务配置的 verify 方式":                          "Compare file contents, defaults to the task's verify setting",
+	"把差异报告以 JSON 格式写入文件，校验多个任务时文件名前依次加上序号":                "Write the difference report as JSON to a file; when verifying several tasks the file names are prefixed with a number",
+	"同时列出只存在于目标目录中的文件":                                    "Also list files that exist only in the target directory",
+	"开启 host_namespace 时校验哪台机器的备份，默认本机":                   "With host_namespace enabled, which machine's backup to verify, defaults to this machine",
+	"用法: %s verify [任务序号或目录...] [选项]\n\n":                 "Usage: %s verify [task number or directory...] [options]\n\n",
+	"不指定任务时校验所有本地备份任务":                                    "Without tasks, verifies all local backup tasks",
+	"推送到服务端的任务需要在服务端校验: %s":                               "Tasks pushed to a server must be verified on the server: %s",
+	"暂不支持校验 S3 或 rclone 目标: %s":                           "Verifying S3 or rclone targets is not supported yet: %s",
+	"[失败] %s -> %s, 错误: %v\n":                             "[failed] %s -> %s, error: %v\n",
+	"开始校验: %s -> %s":                                      "Starting verification: %s -> %s",
+	"[仅在目标中] %s\n":                                        "[only in target] %s\n",
+	"校验完成: %s -> %s, 方式: %s, 文件: %d, 差异: %d, 仅在目标中: %d\n": "Verification finished: %s -> %s, method: %s, files: %d, differences: %d, only in target: %d\n",
+	"查询是否有新版本":                                            "Check for a new version",
+	"用法: %s version [--check] [--json]\n\n":               "Usage: %s version [--check] [--json]\n\n",
+	"查询时使用配置文件中的 update_check.feed 和代理设置，没有配置文件时使用默认值":    "The check uses update_check.feed and proxy settings from the configuration file, or defaults when there is none",
+	"保存版本检查结果失败: %v":                                      "Failed to save version check result: %v",
+	"当前版本: %s\n":                                          "Current version: %s\n",
+	"检查失败: %s\n":                                          "Check failed: %s\n",
+	"有新版本: %s %s\n":                                       "New version available: %s %s\n",
+	"最新版本: %s，已是最新\n":                                     "Latest version: %s, already up to date\n",
+	"用法: %s zip <压缩任务序号或目标路径...>\n\n":                     "Usage: %s zip <zip task number or target path...>\n\n",
+	"立即执行压缩任务，不管是否到期；本机有守护进程运行时由守护进程执行，结果见 list 或日志": "Run zip tasks now whether due or not; if a daemon is running on this machine it runs them, see list or the log for results",
+	"[失败] 压缩 %s -> %s, 错误: %v\n":          "[failed] zip %s -> %s, error: %v\n",
+	"[成功] 压缩 %s -> %s\n":                  "[success] zip %s -> %s\n",
+	"已请求守护进程立即执行压缩任务: %s -> %s\n":         "Asked the daemon to run the zip task now: %s -> %s\n",
+	"守护进程正在执行其他压缩任务，请稍后再试: %s -> %s\n":    "The daemon is running another zip task, try again later: %s -> %s\n",
+	"守护进程的配置中没有这个压缩任务，修改配置后需要先重新加载: %s\n": "The daemon's configuration has no such zip task, reload after changing the configuration: %s\n",
+	"守护进程不支持立即执行压缩任务，可能是旧版本: %s\n":        "The daemon does not support running zip tasks on demand, it may be an older version: %s\n",
+	"压缩任务序号超出范围: %d，共 %d 个压缩任务":           "Zip task number out of range: %d, there are %d zip tasks",
+	"未找到压缩任务: %s":                         "Zip task not found: %s",
+	"无法监听磁盘插拔，只按间隔检查源目录: %v":              "Cannot listen for disk hot-plug, checking sources at the poll interval only: %v",
+
+	// agent
+	"客户端模式需要配置服务端地址和令牌":     "Client mode requires a server address and token",
+	"读取 CA 文件失败: %w":        "Failed to read CA file: %w",
+	"CA 文件无效: %s":           "Invalid CA file: %s",
+	"查询服务端文件失败: %w":         "Failed to query server files: %w",
+	"解析服务端响应失败: %w":         "Failed to parse server response: %w",
+	"打开源文件失败: %w":           "Failed to open source file: %w",
+	"获取源文件信息失败: %w":         "Failed to stat source file: %w",
+	"上传文件失败: %w":            "Failed to upload file: %w",
+	"服务端返回的哈希不匹配: %s":       "Hash returned by the server does not match: %s",
+	"服务端返回 %s: %s":          "Server returned %s: %s",
+	"服务端已有文件已变化":            "The file on the server has changed",
+	"增量上传失败: %w":            "Delta upload failed: %w",
+	"获取文件签名失败: %w":          "Failed to get file signature: %w",
+	"读取上传状态失败: %w":          "Failed to read upload state: %w",
+	"上传状态文件损坏，已忽略: %v":      "Upload state file is corrupt, ignored: %v",
+	"保存上传状态失败: %v":          "Failed to save upload state: %v",
+	"继续上传: %s, 已完成 %d/%d":   "Resuming upload: %s, %d/%d done",
+	"计算文件哈希失败: %w":          "Failed to hash file: %w",
+	"分块上传多次失败，下次扫描时继续: %w":  "Chunked upload failed repeatedly, will continue on the next scan: %w",
+	"分块上传失败 %s: %v, %s 后重试": "Chunk upload failed %s: %v, retrying in %s",
+	"创建上传会话失败: %w":          "Failed to create upload session: %w",
+	"查询上传进度失败: %w":          "Failed to query upload progress: %w",
+	"上传会话不存在: %s":           "Upload session does not exist: %s",
+	"服务端未返回偏移量":             "Server did not return an offset",
+
+	// api
+	"任务不存在":       "task not found",
+	"已有压缩任务正在执行":  "a zip task is already running",
+	"管理接口已启用: %s": "Management API enabled: %s",
+	"未授权":         "unauthorized",
+	"只支持 GET":     "only GET is supported",
+	"只支持 POST":    "only POST is supported",
+	"管理接口: %s %s": "Management API: %s %s",
+	"未知的接口":       "unknown endpoint",
+	"任务序号无效":      "invalid task number",
+
+	// backoff
+	"max_load 不能小于 0: %v":  "max_load must not be less than 0: %v",
+	"未知的处理方式: %s":          "Unknown action: %s",
+	"无法读取系统负载: %v":         "Cannot read system load: %v",
+	"系统负载 %.2f 超过 %.2f":    "system load %.2f exceeds %.2f",
+	"无法读取电源状态: %v":         "Cannot read power status: %v",
+	"正在使用电池供电":             "running on battery",
+	"暂停备份":                 "pausing backups",
+	"放慢备份":                 "slowing down backups",
+	"系统负载和电源状态已恢复，继续备份":    "System load and power status recovered, resuming backups",
+	"无法解析 vm.loadavg: %s":  "Cannot parse vm.loadavg: %s",
+	"无法解析 /proc/loadavg":   "Cannot parse /proc/loadavg",
+	"当前平台不支持读取系统负载":        "Reading system load is not supported on this platform",
+	"当前平台不支持读取电源状态":        "Reading power status is not supported on this platform",
+	"Windows 不支持 max_load": "max_load is not supported on Windows",
+	"%s，%s":                "%s, %s",
+
+	// backup
+	"保存备份状态: %s":                            "Saving backup state: %s",
+	"保存备份状态失败: %w":                          "Failed to save backup state: %w",
+	"更新备份状态失败: %v":                          "Failed to update backup state: %v",
+	"计算源文件哈希失败 %s: %v":                      "Failed to hash source file %s: %v",
+	"更新目录索引失败: %v":                          "Failed to update directory catalog: %v",
+	"已有相同内容的备份，创建硬链接: %s -> %s":             "A backup with identical content exists, creating hard link: %s -> %s",
+	"目标文件系统不支持克隆文件":                         "The target file system does not support cloning files",
+	"打开目标文件失败: %w":                          "Failed to open target file: %w",
+	"获取目标文件信息失败: %w":                        "Failed to stat target file: %w",
+	"创建目标文件失败: %w":                          "Failed to create target file: %w",
+	"复制文件内容失败: %w":                          "Failed to copy file contents: %w",
+	"写入目标文件失败: %w":                          "Failed to write target file: %w",
+	"增量复制 %s: 复用 %d 字节, 变化 %d 字节, 写入 %d 字节": "Delta copy %s: reused %d bytes, changed %d bytes, wrote %d bytes",
+	"规则为空: %q":                              "Empty pattern: %q",
+	"规则中有空的路径段: %q":                         "Pattern has an empty path segment: %q",
+	"规则格式错误 %q: %w":                         "Malformed pattern %q: %w",
+	"include 配置无效: %w":                      "Invalid include setting: %w",
+	"exclude 配置无效: %w":                      "Invalid exclude setting: %w",
+	"创建硬链接失败，改为复制 %s: %v":                   "Failed to create hard link, copying instead %s: %v",
+	"创建硬链接完成: %s -> %s":                     "Hard link created: %s -> %s",
+	"生成恢复数据失败 %s: %v":                       "Failed to generate recovery data %s: %v",
+	"任务处于演练模式，只记录将要执行的操作: %s -> %s":         "Task is in dry-run mode, planned operations are only logged: %s -> %s",
+	"目标 %s 需要配置客户端模式":                       "Target %s requires client mode to be configured",
+	"客户端命名空间无效: %s":                         "Invalid client namespace: %s",
+	"目标 %s 按主机名存放，但未设置主机名":                  "Target %s is stored per host name, but no host name is set",
+	"打开远程存储目标失败: %w":                        "Failed to open remote storage target: %w",
+	"按主机名存放备份: %s":                          "Storing backups per host name: %s",
+	"创建目标目录: %s":                            "Creating target directory: %s",
+	"创建目标目录失败: %v":                          "Failed to create target directory: %v",
+	"推送到服务端或远程存储的目标不支持写入校验文件":               "Targets pushed to a server or remote storage do not support writing checksum files",
+	"未知的特殊文件处理方式: %s":                       "Unknown special file handling: %s",
+	"恢复数据的冗余比例应为 0 到 100: %d":               "Recovery data redundancy must be between 0 and 100: %d",
+	"推送到服务端或远程存储的目标不支持生成恢复数据":               "Targets pushed to a server or remote storage do not support generating recovery data",
+	"推送到服务端或远程存储的目标不支持按内容去重":                "Targets pushed to a server or remote storage do not support content deduplication",
+	"复制速度限制不能为负数: %d":                       "Copy speed limit must not be negative: %d",
+	"推送到服务端或远程存储的目标按 bandwidth 配置限制上传带宽，不支持 max_bytes_per_sec": "Targets pushed to a server or remote storage limit upload bandwidth with the bandwidth settings, max_bytes_per_sec is not supported",
+	"推送到服务端或远程存储的目标在上传后另行校验，不支持 verify_writes":                 "Targets pushed to a server or remote storage are verified separately after upload, verify_writes is not supported",
+	"delta 只用于本地目标，推送到服务端时已自动使用增量上传":                           "delta only applies to local targets, uploads to a server already use delta transfer automatically",
+	"delta 需要同时开启 update_changed，否则目标中已有的文件不会更新":               "delta requires update_changed as well, otherwise existing files in the target are never updated",
+	"推送到服务端或远程存储的目标不支持从目标目录导入":                                 "Targets pushed to a server or remote storage do not support seeding from the target directory",
+	"跳过特殊文件（%s），远程目标只接收普通文件: %s":                               "Skipping special file (%s), remote targets only accept regular files: %s",
+	"复制文件: %s -> %s":                            "Copying file: %s -> %s",
+	"文件备份完成: %s -> %s":                          "File backed up: %s -> %s",
+	"更新文件: %s -> %s":                            "Updating file: %s -> %s",
+	"清除目标文件的不可变属性失败 %s: %v":                     "Failed to clear the immutable attribute of target file %s: %v",
+	"更新文件失败 %s: %v":                             "Failed to update file %s: %v",
+	"设置不可变属性失败: %s: %v":                         "Failed to set immutable attribute: %s: %v",
+	"文件更新完成: %s -> %s":                          "File updated: %s -> %s",
+	"上传文件: %s -> %s":                            "Uploading file: %s -> %s",
+	"打开源文件失败 %s: %v":                            "Failed to open source file %s: %v",
+	"上传文件失败 %s: %v":                             "Failed to upload file %s: %v",
+	"文件上传完成: %s -> %s":                          "File uploaded: %s -> %s",
+	"查询服务端文件失败 %s: %v":                          "Failed to query server file %s: %v",
+	"上传文件: %s -> %s%s/%s":                       "Uploading file: %s -> %s%s/%s",
+	"增量上传完成: %s -> %s%s/%s, 复用 %d 字节, 传输 %d 字节": "Delta upload finished: %s -> %s%s/%s, reused %d bytes, transferred %d bytes",
+	"增量上传失败，改为完整上传 %s: %v":                      "Delta upload failed, falling back to a full upload %s: %v",
+	"文件上传完成: %s -> %s%s/%s":                     "File uploaded: %s -> %s%s/%s",
+	"目标文件系统不支持克隆文件，delta 改为完整复制: %s":            "The target file system does not support cloning files, delta falls back to a full copy: %s",
+	"读取源文件失败: %w":                               "Failed to read source file: %w",
+	"写入校验失败，下次扫描时重新复制: %w":                      "Write verification failed, will copy again on the next scan: %w",
+	"复制扩展属性失败 %s: %v":                           "Failed to copy extended attributes %s: %v",
+	"设置目标文件权限失败: %v":                            "Failed to set target file permissions: %v",
+	"设置目标文件时间失败: %v":                            "Failed to set target file times: %v",
+	"设置目标文件 UID 和 GID 失败: %v":                   "Failed to set target file UID and GID: %v",
+	"保存目录索引失败: %w":                              "Failed to save directory catalog: %w",
+	"源目录不存在，不检查上次同步时间: %s":                      "Source directory does not exist, not checking the last sync time: %s",
+	"无法获取相对路径: %v":                              "Cannot get relative path: %v",
+	"写入校验文件: %s":                                "Writing checksum file: %s",
+	"无法构建目标路径: %s":                              "Cannot build target path: %s",
+	"获取源目录信息失败: %w":                             "Failed to stat source directory: %w",
+	"创建目标目录失败: %w":                              "Failed to create target directory: %w",
+	"设置目录时间失败: %v":                              "Failed to set directory times: %v",
+	"目录同步完成: %s -> %s":                          "Directory synced: %s -> %s",
+	"从断点继续复制 %s: 已复制 %d/%d 字节":                  "Resuming copy of %s from checkpoint: %d/%d bytes copied",
+	"复制文件内容失败，下次从 %d 字节处继续: %w":                 "Failed to copy file contents, will resume from byte %d next time: %w",
+	"保存复制断点失败 %s: %v":                           "Failed to save copy checkpoint %s: %v",
+	"复制断点损坏，重新复制: %s":                           "Copy checkpoint is corrupt, copying again: %s",
+	"源文件已变化，重新复制: %s":                           "Source file has changed, copying again: %s",
+	"开始从目标目录导入已有备份: %s":                         "Seeding existing backups from the target directory: %s",
+	"访问路径失败 %s: %v":                             "Failed to access path %s: %v",
+	"计算文件哈希失败 %s: %v":                           "Failed to hash file %s: %v",
+	"导入目标目录失败: %w":                              "Failed to seed from target directory: %w",
+	"目标中已有同名文件但大小不同，不会覆盖: %s":                   "A file with the same name but a different size exists in the target and will not be overwritten: %s",
+	"扫描源目录失败: %w":                               "Failed to scan source directory: %w",
+	"导入完成: %s, 写入目录索引: %d, 已有备份: %d, 缺失: %d, 大小不一致: %d, 同步基线: %s": "Seeding finished: %s, catalog entries written: %d, already backed up: %d, missing: %d, size mismatch: %d, sync baseline: %s",
+	"命名管道":           "named pipe",
+	"套接字":            "socket",
+	"字符设备":           "character device",
+	"块设备":            "block device",
+	"未知类型":           "unknown type",
+	"跳过特殊文件（%s）: %s": "Skipping special file (%s): %s",
+	"跳过特殊文件（%s），重新创建设备文件需要 root 权限: %s": "Skipping special file (%s), recreating device files requires root: %s",
+	"创建特殊文件（%s）: %s":                    "Creating special file (%s): %s",
+	"创建特殊文件失败（%s）%s: %v":                "Failed to create special file (%s) %s: %v",
+	"特殊文件已创建（%s）: %s -> %s":             "Special file created (%s): %s -> %s",
+	"当前平台不支持创建特殊文件":                     "Creating special files is not supported on this platform",
+	"同步到磁盘失败: %w":                       "Failed to sync to disk: %w",
+	"重新读取失败: %w":                        "Failed to read back: %w",
+	"写入的内容与源文件不一致":                      "Written content does not match the source file",
+
+	// bench
+	"采样完成":         "sampling finished",
+	"遍历源目录失败: %w":  "Failed to walk source directory: %w",
+	"创建测试文件失败: %w": "Failed to create test file: %w",
+	"写入测试文件失败: %w": "Failed to write test file: %w",
+
+	// bisync
+	"读取同步状态失败: %w":                                    "Failed to read sync state: %w",
+	"解析同步状态失败: %w":                                    "Failed to parse sync state: %w",
+	"序列化同步状态失败: %w":                                   "Failed to serialize sync state: %w",
+	"保存同步状态失败: %w":                                    "Failed to save sync state: %w",
+	"同步目录不能为空":                                        "Sync directories must not be empty",
+	"同步目录不能相同或互相包含: %s, %s":                           "Sync directories must not be the same or contain each other: %s, %s",
+	"未知的冲突处理策略: %s":                                   "Unknown conflict strategy: %s",
+	"停止同步任务: %s <-> %s":                               "Stopping sync task: %s <-> %s",
+	"同步失败 %s <-> %s: %v":                              "Sync failed %s <-> %s: %v",
+	"扫描 %s 失败: %w":                                    "Failed to scan %s: %w",
+	"同步文件失败 %s: %v":                                   "Failed to sync file %s: %v",
+	"同步完成: %s <-> %s, 复制: %d, 删除: %d, 冲突: %d, 失败: %d": "Sync finished: %s <-> %s, copied: %d, deleted: %d, conflicts: %d, failed: %d",
+	"同步文件: %s -> %s":                                  "Syncing file: %s -> %s",
+	"同步删除，移动到回收目录: %s":                                "Syncing deletion, moving to trash: %s",
+	"处理冲突 (%s): %s":                                   "Resolving conflict (%s): %s",
+	"清理回收目录: %s, %d 个文件":                              "Cleaning trash: %s, %d files",
+	"目录暂时不可用: %w":                                     "Directory temporarily unavailable: %w",
+	"目录不存在: %s":                                       "Directory does not exist: %s",
+	"目录为空但上次同步时有 %d 个文件，可能未挂载: %s":                    "Directory is empty but had %d files at the last sync, it may not be mounted: %s",
+	"计划删除 %d 个文件，超过已同步文件数 %d 的 %d%%，已中止同步": "Planned to delete %d files, more than the limit of the %d synced files (%d%%), sync aborted",
+	"同步冲突，保留较新的版本: %s":                     "Sync conflict, keeping the newer version: %s",
+	"重命名冲突文件失败: %w":                        "Failed to rename conflicting file: %w",
+	"同步冲突，两个版本都保留: %s, 较旧的版本重命名为 %s":       "Sync conflict, keeping both versions: %s, the older version was renamed to %s",
+	"创建回收目录失败: %w":                         "Failed to create trash directory: %w",
+	"移动到回收目录失败: %w":                        "Failed to move to trash: %w",
+	"同步删除，已移动到回收目录: %s -> %s":              "Syncing deletion, moved to trash: %s -> %s",
+	"创建临时文件失败: %w":                         "Failed to create temporary file: %w",
+	"同步临时文件失败: %w":                         "Failed to sync temporary file: %w",
+	"关闭临时文件失败: %w":                         "Failed to close temporary file: %w",
+	"替换目标文件失败: %w":                         "Failed to replace target file: %w",
+	"清理回收目录失败 %s: %w":                      "Failed to clean trash %s: %w",
+	"已清理回收目录: %s, %d 个文件":                  "Trash cleaned: %s, %d files",
+	"清理回收目录失败 %s <-> %s: %v":               "Failed to clean trash %s <-> %s: %v",
+	"读取回收目录失败: %w":                         "Failed to read trash directory: %w",
+
+	// browse
+	"文件浏览已启用，共 %d 个目录":  "File browsing enabled, %d directories",
+	"从冷存储取回文件失败 %s: %v": "Failed to retrieve file from cold storage %s: %v",
+	"从冷存储取回文件失败":        "Failed to retrieve file from cold storage",
+	"无法读取文件":            "Cannot read file",
+	"无法读取目录":            "Cannot read directory",
+	"渲染目录列表失败: %v":      "Failed to render directory listing: %v",
+
+	// catalog
+	"打开目录索引失败: %w":     "Failed to open directory catalog: %w",
+	"读取目录索引失败: %w":     "Failed to read directory catalog: %w",
+	"解析目录索引失败: %w":     "Failed to parse directory catalog: %w",
+	"序列化目录索引失败: %w":    "Failed to serialize directory catalog: %w",
+	"写入目录索引失败: %w":     "Failed to write directory catalog: %w",
+	"同步目录索引失败: %w":     "Failed to sync directory catalog: %w",
+	"创建目录索引临时文件失败: %w": "Failed to create temporary directory catalog file: %w",
+	"替换目录索引失败: %w":     "Failed to replace directory catalog: %w",
+
+	// checksum
+	"不支持的校验算法: %s":          "Unsupported checksum algorithm: %s",
+	"不支持的校验文件范围: %s":        "Unsupported checksum file scope: %s",
+	"计算校验值失败 %s: %v":        "Failed to compute checksum %s: %v",
+	"创建校验文件目录失败: %w":        "Failed to create checksum file directory: %w",
+	"已写入校验文件: %s, 共 %d 个文件": "Checksum file written: %s, %d files",
+	"写入校验文件失败 %s: %v":       "Failed to write checksum file %s: %v",
+	"扫描目标目录失败: %w":          "Failed to scan target directory: %w",
+	"已更新 %d 个目录的校验文件: %s":   "Updated checksum files for %d directories: %s",
+	"写入校验文件失败: %w":          "Failed to write checksum file: %w",
+
+	// config
+	"保存状态文件失败: %w": "Failed to save state file: %w",
+	"同步状态文件失败: %w": "Failed to sync state file: %w",
+	"写入状态文件失败: %w": "Failed to write state file: %w",
+	"序列化状态失败: %w":  "Failed to serialize state: %w",
+	"解析状态文件失败: %w": "Failed to parse state file: %w",
+	"读取状态文件失败: %w": "Failed to read state file: %w",
+	"打开状态文件失败: %w": "Failed to open state file: %w",
+	"需要同时设置 keep 或 max_total_bytes，否则每次上传都覆盖同名的副本":                                            "Requires keep or max_total_bytes, otherwise every upload overwrites the copy with the same name",
+	"没有设置 upload，不会删除任何副本":                                                                    "upload is not set, no copies will be deleted",
+	"Linux 上挂载 SMB 共享需要安装 cifs-utils（Debian/Ubuntu 为 apt install cifs-utils）；也可以先挂载共享后使用本地目录": "Mounting SMB shares on Linux requires cifs-utils (apt install cifs-utils on Debian/Ubuntu); alternatively mount the share first and use a local directory",
+	"Linux 上通过 mount.cifs 挂载 SMB 共享，需要以 root 运行；也可以先挂载共享后使用本地目录":                              "On Linux SMB shares are mounted with mount.cifs, which requires running as root; alternatively mount the share first and use a local directory",
+	"没有开启 eject_after_backup，不会执行":                                                            "eject_after_backup is not enabled, so this is never run",
+	"扫描完成后弹出设备，不能同时开启 realtime":                                                               "The device is ejected after the scan, so realtime cannot be enabled at the same time",
+	"网络共享不能弹出":                              "Network shares cannot be ejected",
+	"卷没有挂载，插入后自动挂载并备份: %s":                  "Volume is not mounted, it will be mounted and backed up once plugged in: %s",
+	"自动挂载的卷扫描完成后卸载，不能同时开启 realtime":         "An automounted volume is unmounted after the scan, so realtime cannot be enabled at the same time",
+	"只能用于按 UUID 或卷标指定的源目录":                  "Can only be used with a source given by UUID or label",
+	"卷中没有该目录，创建后才会备份":                       "The directory does not exist on the volume, it will be backed up once created",
+	"卷没有插入或没有挂载，插入后才会备份: %s":                "The volume is not inserted or not mounted, it will be backed up once inserted: %s",
+	"UUID 或卷标不能为空: %s":                      "UUID or label must not be empty: %s",
+	"配置目录: %s 不存在, %w":                      "Configuration directory %s does not exist, %w",
+	"配置文件: %s 不存在, %w":                      "Configuration file %s does not exist, %w",
+	"读取配置文件失败: %w":                          "Failed to read configuration file: %w",
+	"解析配置文件失败: %w":                          "Failed to parse configuration file: %w",
+	"获取主机名失败: %w":                           "Failed to get host name: %w",
+	"主机名无效: %q":                             "Invalid host name: %q",
+	"管理接口的令牌至少需要 16 个字符":                    "The management API token must be at least 16 characters",
+	"Windows 不支持设置文件所有者，已忽略 target_user 配置": "Windows does not support setting file owners, target_user ignored",
+	"警告":               "warning",
+	"不能小于 0: %d":       "must not be less than 0: %d",
+	"第 %d 行第 %d 列: %v": "line %d, column %d: %v",
+	"解析配置文件失败: %v":     "Failed to parse configuration file: %v",
+	"第 %d 行第 %d 列: 类型错误，需要 %s，实际为 %s":       "line %d, column %d: wrong type, expected %s, got %s",
+	"未知的字段，是否应为 %s":                         "unknown field, did you mean %s",
+	"未知的字段":                                 "unknown field",
+	"不能为空":                                  "must not be empty",
+	"源目录不存在，插入或挂载后才会备份":                     "source directory does not exist, it will be backed up once inserted or mounted",
+	"与 backup_configs[%d] 的源目录相同，只有第一个任务生效": "same source directory as backup_configs[%d], only the first task takes effect",
+	"目标目录不存在，备份时自动创建；目标为外接硬盘时确认已经挂载，否则会写入系统盘": "target directory does not exist and will be created on backup; if the target is an external drive make sure it is mounted, otherwise the system disk will be written",
+	"源目录与目标目录相同或互相包含: %s, %s":                 "source and target directories are the same or contain each other: %s, %s",
+	"与 backup_configs[%d] 重复": "duplicate of backup_configs[%d]",
+	"两侧目录相同或互相包含: %s, %s":     "the two directories are the same or contain each other: %s, %s",
+	"与 sync_configs[%d] 重复":   "duplicate of sync_configs[%d]",
+	"需要在 0 到 100 之间: %d":      "must be between 0 and 100: %d",
+	"冷存储目录不能与目标目录相同或互相包含: %s": "cold storage directory must not be the same as or contain the target directory: %s",
+	"必须大于 0: %d":              "must be greater than 0: %d",
+	"为 0 且压缩任务都没有设置 cron，压缩任务不会定时执行，只能通过 zip 或 once --all-zips 命令执行": "is 0 and no zip task has cron set, so zip tasks never run on a schedule, only through the zip or once --all-zips commands",
+	"源路径不存在，压缩任务会失败: %s":             "source path does not exist, the zip task will fail: %s",
+	"与 zip_config.items[%d] 的目标路径相同": "same target path as zip_config.items[%d]",
+	"压缩文件位于源路径中，会把之前的压缩文件也压缩进去: %s":  "the zip file is inside the source path, earlier zip files will be zipped too: %s",
+	"日志目录不存在，启动时自动创建":                "log directory does not exist and will be created on start",
+	"无法访问: %v": "cannot access: %v",
+	"不是目录: %s": "not a directory: %s",
+	"格式应为 uid:gid，例如 1000:1000: %q":      "expected uid:gid, e.g. 1000:1000: %q",
+	"uid 和 gid 需要是非负整数，例如 1000:1000: %q": "uid and gid must be non-negative integers, e.g. 1000:1000: %q",
+
+	// cron
+	"分": "minute",
+	"时": "hour",
+	"日": "day",
+	"月": "month",
+	"周": "weekday",
+	"cron 表达式应有 5 个字段（分 时 日 月 周）: %q": "cron expression must have 5 fields (minute hour day month weekday): %q",
+	"cron 表达式 %q 的%s字段无效: %w":         "invalid cron expression %q, %s field: %w",
+	"步长无效: %s":            "invalid step: %s",
+	"范围无效: %s":            "invalid range: %s",
+	"取值应在 %d 到 %d 之间: %s": "value must be between %d and %d: %s",
+
+	// delta
+	"读取文件失败: %w":     "Failed to read file: %w",
+	"复制基准数据失败: %w":   "Failed to copy base data: %w",
+	"增量数据引用超出基准文件范围": "Delta data references beyond the base file",
+	"增量数据格式无效":       "Invalid delta data format",
+	"读取增量数据失败: %w":   "Failed to read delta data: %w",
+	"未知的增量操作: %d":    "Unknown delta operation: %d",
+	"块大小无效: %d":      "Invalid block size: %d",
+	"读取基准文件失败: %w":   "Failed to read base file: %w",
+	"签名格式无效":         "Invalid signature format",
+	"读取签名失败: %w":     "Failed to read signature: %w",
+
+	// dryrun
+	"[演练] %s": "[dry run] %s",
+
+	// dupes
+	"读取文件信息失败: %w":        "Failed to stat file: %w",
+	"文件内容与目录索引不一致，跳过: %s": "File content does not match the directory catalog, skipped: %s",
+	"替换为硬链接: %s -> %s":    "Replacing with hard link: %s -> %s",
+	"创建硬链接失败 %s: %w":      "Failed to create hard link %s: %w",
+	"替换文件失败 %s: %w":       "Failed to replace file %s: %w",
+
+	// history
+	"运行记录文件损坏，已忽略: %v": "Run history file is corrupt, ignored: %v",
+	"保存运行记录失败: %v":     "Failed to save run history: %v",
+
+	// hotplug
+	"当前平台不支持磁盘插拔通知":   "Disk hot-plug notifications are not supported on this platform",
+	"检测到磁盘插入: %s":     "Disk inserted: %s",
+	"检测到磁盘拔出: %s":     "Disk removed: %s",
+	"无法接收内核的设备通知: %v": "Cannot receive device notifications from the kernel: %v",
+	"监听磁盘插拔失败: %v":    "Listening for disk hot-plug failed: %v",
+	"挂载点发生变化":         "Mount table changed",
+
+	// httpd
+	"HTTPS 服务已启动: %s": "HTTPS server started: %s",
+	"HTTP 服务已启动: %s":  "HTTP server started: %s",
+	"HTTP 服务异常退出: %v": "HTTP server exited unexpectedly: %v",
+
+	// i18n
+	"不支持的语言: %s，可选 zh 或 en": "Unsupported language: %s, choose zh or en",
+
+	// immutable
+	"不支持不可变属性": "immutable attribute not supported",
+
+	// instance
+	"已有实例在使用该配置目录运行": "another instance is already running with this configuration directory",
+	"锁已被占用":              "lock is held",
+	"打开锁文件失败: %w":        "Failed to open lock file: %w",
+	"%w，进程号: %d":         "%w, PID: %d",
+	"获取锁失败: %w":          "Failed to acquire lock: %w",
+	"打开控制套接字失败: %w":      "Failed to open control socket: %w",
+	"收到新实例的接管请求，准备停止":    "Takeover request received from a new instance, stopping",
+	"正在运行的实例拒绝了停止请求":     "The running instance refused to stop",
+	"已请求正在运行的实例停止，等待其退出": "Asked the running instance to stop, waiting for it to exit",
+	"等待正在运行的实例退出超时: %w":  "Timed out waiting for the running instance to exit: %w",
+	"无法连接正在运行的实例，可能是单次运行模式或旧版本: %w": "Cannot connect to the running instance, it may be in run-once mode or an older version: %w",
+
+	// logging
+	"创建日志目录失败: %w":                            "Failed to create log directory: %w",
+	"打开日志文件失败: %w":                            "Failed to open log file: %w",
+	"轮转日志文件失败: %v\n":                          "Failed to rotate log file: %v\n",
+	"压缩旧日志失败 %s: %v\n":                        "Failed to compress old log %s: %v\n",
+	"模块 %s: %w":                               "module %s: %w",
+	"不支持的日志格式: %s，可选 text 或 json":             "Unsupported log format: %s, choose text or json",
+	"不支持的日志级别: %s，可选 debug、info、warn 或 error": "Unsupported log level: %s, choose debug, info, warn or error",
+	"[错误] ": "[ERROR] ",
+	"[警告] ": "[WARN] ",
+	"[调试] ": "[DEBUG] ",
+
+	// mount
+	"不是块设备: %s":              "Not a block device: %s",
+	"断开 USB 设备 %s 的电源失败: %w": "Failed to power off USB device %s: %w",
+	"从系统中删除磁盘 %s 失败: %w":     "Failed to remove disk %s from the system: %w",
+	"%s 不是 USB 设备，无法断开电源":    "%s is not a USB device and cannot be powered off",
+	"磁盘 %s 的 %s 仍然挂载在 %s":    "%[2]s on disk %[1]s is still mounted at %[3]s",
+	"卸载 %s 失败: %w":           "Failed to unmount %s: %w",
+	"%s 所在的 %s 不是可以热插拔的设备":   "%s is on %s, which is not a hot-pluggable device",
+	"%s 不在可以弹出的设备上":          "%s is not on an ejectable device",
+	"当前系统不支持弹出设备":            "Ejecting devices is not supported on this system",
+	"当前系统不支持自动挂载卷":           "Automounting volumes is not supported on this system",
+	"当前平台不支持按 UUID 或卷标指定源目录": "Specifying the source by UUID or label is not supported on this platform",
+	"卷没有插入或没有挂载":             "The volume is not inserted or not mounted",
+	"探测超时":                   "probe timed out",
+	"共享未挂载":                  "share not mounted",
+	"%s 位于 %s 文件系统: %w":      "%s is on a %s file system: %w",
+	"读取挂载信息失败: %w":           "Failed to read mount information: %w",
+
+	// parity
+	"没有恢复数据":             "no recovery data",
+	"冗余比例应为 1 到 100: %d": "Redundancy must be between 1 and 100: %d",
+	"打开文件失败: %w":         "Failed to open file: %w",
+	"获取文件信息失败: %w":       "Failed to stat file: %w",
+	"创建恢复数据失败: %w":       "Failed to create recovery data: %w",
+	"写入恢复数据失败: %w":       "Failed to write recovery data: %w",
+	"损坏的数据块过多，%d 块损坏，只有 %d 块完好的校验数据": "Too many damaged data blocks: %d damaged, only %d intact parity blocks",
+	"清除不可变属性失败: %w":                  "Failed to clear immutable attribute: %w",
+	"修复后仍有 %d 块数据与恢复数据不一致":           "After repair %d data blocks still do not match the recovery data",
+	"打开恢复数据失败: %w":                   "Failed to open recovery data: %w",
+	"恢复文件大小失败: %w":                   "Failed to restore file size: %w",
+	"恢复数据无效":                         "Invalid recovery data",
+	"写入修复的数据失败: %w":                  "Failed to write repaired data: %w",
+	"恢复数据已损坏 %s: %w":                 "Recovery data is damaged %s: %w",
+	"文件头无效":                          "Invalid file header",
+	"说明信息长度无效":                       "Invalid description length",
+	"说明信息无效":                         "Invalid description",
+
+	// priority
+	"nice 应在 0 到 19 之间: %d":    "nice must be between 0 and 19: %d",
+	"未知的 IO 调度类别: %s":          "Unknown IO scheduling class: %s",
+	"io_level 应在 0 到 7 之间: %d": "io_level must be between 0 and 7: %d",
+	"降低优先级失败: %v":              "Failed to lower priority: %v",
+	"设置 nice: %w":              "setting nice: %w",
+	"设置 IO 优先级: %w":            "setting IO priority: %w",
+	"设置进程优先级: %w":              "setting process priority: %w",
+
+	// proxy
+	"代理地址无效: %s":   "Invalid proxy address: %s",
+	"不支持的代理类型: %s": "Unsupported proxy type: %s",
+
+	// remote
+	"设置修改时间失败: %w":                            "failed to set modification time: %w",
+	"解密失败，密钥不正确或对象已损坏":                        "Decryption failed, the key is wrong or the object is damaged",
+	"读取密钥文件失败: %w":                            "Failed to read key file: %w",
+	"密钥文件格式无效，应为 32 字节密钥的十六进制或 base64 编码: %s": "Invalid key file format, expected a 32-byte key in hex or base64: %s",
+	"对象键无效: %s":                               "Invalid object key: %s",
+	"对象未加密，按原样读取: %s/%s":                      "Object is not encrypted, reading as is: %s/%s",
+	"加密索引文件损坏，已忽略: %v":                        "Encryption index file is corrupt, ignored: %v",
+	"保存加密索引失败: %v":                            "Failed to save encryption index: %v",
+	"存储目录不能为空":                                "Storage directory must not be empty",
+	"解析存储目录失败: %w":                            "Failed to resolve storage directory: %w",
+	"创建存储目录失败: %w":                            "Failed to create storage directory: %w",
+	"写入对象失败: %w":                              "Failed to write object: %w",
+	"同步对象失败: %w":                              "Failed to sync object: %w",
+	"关闭对象失败: %w":                              "Failed to close object: %w",
+	"保存对象失败: %w":                              "Failed to save object: %w",
+	"删除对象失败: %w":                              "Failed to delete object: %w",
+	"分块上传状态文件损坏，已忽略: %v":                      "Multipart upload state file is corrupt, ignored: %v",
+	"保存分块上传状态失败: %v":                          "Failed to save multipart upload state: %v",
+	"继续分块上传: %s/%s, 已完成 %d 个分块":               "Resuming multipart upload: %s/%s, %d parts done",
+	"无法继续之前的分块上传，重新上传 %s/%s: %v":              "Cannot resume the previous multipart upload, uploading %s/%s again: %v",
+	"放弃分块上传失败 %s/%s: %v":                      "Failed to abort multipart upload %s/%s: %v",
+	"分块上传多次失败，下次上传时继续: %w":                    "Multipart upload failed repeatedly, will continue on the next upload: %w",
+	"分块上传 %s/%s: %d/%d":                       "Multipart upload %s/%s: %d/%d",
+	"创建分块上传失败: %w":                            "Failed to create multipart upload: %w",
+	"解析分块上传响应失败: %v":                          "Failed to parse multipart upload response: %v",
+	"上传分块 %d 失败 %s/%s: %v, %s 后重试":            "Failed to upload part %d %s/%s: %v, retrying in %s",
+	"读取上传内容失败: %w":                            "Failed to read upload content: %w",
+	"S3 未返回分块的 ETag":                          "S3 did not return an ETag for the part",
+	"解析分块列表失败: %w":                            "Failed to parse part list: %w",
+	"完成分块上传失败: %w":                            "Failed to complete multipart upload: %w",
+	"完成分块上传失败: %s: %s":                        "Failed to complete multipart upload: %s: %s",
+	"rclone 存储位置格式应为 rclone:<远程名>:<路径>: %s":   "rclone location must be rclone:<remote>:<path>: %s",
+	"未找到 rclone 命令: %w":                       "rclone command not found: %w",
+	"上传对象失败: %w":                              "Failed to upload object: %w",
+	"读取对象失败: %w":                              "Failed to read object: %w",
+	"查询对象失败: %w":                              "Failed to query object: %w",
+	"解析 rclone 输出失败: %w":                      "Failed to parse rclone output: %w",
+	"对象不存在":                                   "object does not exist",
+	"存储不支持查询对象信息":                             "storage does not support querying object information",
+	"查询远程对象失败: %w":                            "Failed to query remote object: %w",
+	"远程对象大小不匹配: 期望 %d, 实际 %d":                 "Remote object size mismatch: expected %d, got %d",
+	"远程对象哈希不匹配: 期望 %s, 实际 %s":                 "Remote object hash mismatch: expected %s, got %s",
+	"读取远程对象失败: %w":                            "Failed to read remote object: %w",
+	"存储位置不能为空":                                "Storage location must not be empty",
+	"不支持的存储位置: %s":                            "Unsupported storage location: %s",
+	"S3 存储位置缺少存储桶: %s":                        "S3 location is missing a bucket: %s",
+	"S3 分块大小不能小于 %d MB":                       "S3 part size must not be less than %d MB",
+	"S3 存储缺少访问密钥":                             "S3 storage is missing access keys",
+	"S3 服务地址无效: %s":                           "Invalid S3 endpoint: %s",
+	"对象锁定需要设置保留天数":                            "Object lock requires retention days",
+	"未知的对象锁定模式: %s":                           "Unknown object lock mode: %s",
+	"请求 S3 失败: %w":                            "S3 request failed: %w",
+	"S3 返回 %s: %s: %s":                        "S3 returned %s: %s: %s",
+	"S3 返回 %s":                                "S3 returned %s",
+	"对象超过 S3 的分块数量上限 %d":                      "Object exceeds the S3 limit of %d parts",
+	"分块上传多次失败: %w":                            "Multipart upload failed repeatedly: %w",
+	"分块上传 %s/%s: %d":                          "Multipart upload %s/%s: %d",
+
+	// restore
+	"压缩文件已损坏，已恢复的文件可能不完整: %w": "Archive is corrupt, restored files may be incomplete: %w",
+	"跳过压缩文件中不支持的条目: %s":       "Skipping unsupported entry in archive: %s",
+	"打开压缩文件失败: %w":            "Failed to open zip file: %w",
+	"跳过压缩文件中路径无效的条目: %s":      "Skipping zip entry with an invalid path: %s",
+	"创建目录失败 %s: %v":           "Failed to create directory %s: %v",
+	"压缩文件中不存在: %s":            "Not found in zip file: %s",
+	"设置目录权限失败: %v":            "Failed to set directory permissions: %v",
+	"恢复文件失败 %s: %v":           "Failed to restore file %s: %v",
+	"已恢复: %s":                 "Restored: %s",
+	"解压文件内容失败: %w":            "Failed to extract file contents: %w",
+	"关闭文件失败: %w":              "Failed to close file: %w",
+	"设置文件权限失败: %v":            "Failed to set file permissions: %v",
+	"设置文件时间失败: %v":            "Failed to set file times: %v",
+	"写入文件失败: %w":              "Failed to write file: %w",
+	"设置文件所有者失败: %v":           "Failed to set file owner: %v",
+	"未知的覆盖策略: %s":             "Unknown overwrite policy: %s",
+	"需要指定恢复到的目录":              "A directory to restore to is required",
+	"解析恢复目录失败: %w":            "Failed to resolve restore directory: %w",
+	"恢复目录不能位于备份目录中: %s":       "The restore directory must not be inside the backup directory: %s",
+	"路径必须相对于备份目录: %s":         "Paths must be relative to the backup directory: %s",
+	"备份中不存在: %s":              "Not found in backup: %s",
+	"读取冷存储记录失败 %s: %v":        "Failed to read cold storage record %s: %v",
+	"将恢复: %s (%d 字节)\n":       "Would restore: %s (%d bytes)\n",
+	"打开备份文件失败: %w":            "Failed to open backup file: %w",
+	"恢复扩展属性失败 %s: %v":         "Failed to restore extended attributes %s: %v",
+
+	// scrub
+	"损坏":                 "damaged",
+	"已修改":                "modified",
+	"校验已中止":              "scrub aborted",
+	"写入校验报告失败: %w":       "Failed to write scrub report: %w",
+	"解析校验报告失败: %w":       "Failed to parse scrub report: %w",
+	"校验进度 %s: %d/%d 个文件": "Scrub progress %s: %d/%d files",
+	"校验发现问题 [%s] %s":     "Scrub found a problem [%s] %s",
+	"记录的哈希 %s, 实际 %s":    "recorded hash %s, actual %s",
+	"记录的大小 %d，实际 %d":     "recorded size %d, actual %d",
+	"将按恢复数据修复":           "will repair from recovery data",
+	"已按恢复数据修复":           "repaired from recovery data",
+	"无法修复: ":             "unrepairable: ",
+	"将从源文件重新复制":          "will copy again from the source file",
+	"已从源文件重新复制":          "copied again from the source file",
+	"没有记录源文件":            "no source file recorded",
+	"源文件不可用: %w":         "source file unavailable: %w",
+	"源文件已在备份后修改":         "source file was modified after the backup",
+	"源文件的内容与备份时不同":       "source file content differs from the backup",
+	"写入失败: %w":           "write failed: %w",
+	"重新复制后内容仍不一致":        "content still differs after copying again",
+
+	// server
+	"已有文件不存在":                            "existing file does not exist",
+	"已有文件已被改动":                           "existing file has been modified",
+	"合并增量数据失败 %s: %v":                    "Failed to apply delta %s: %v",
+	"服务端根目录不能为空":                         "Server root directory must not be empty",
+	"服务端未配置任何客户端":                        "No clients configured for the server",
+	"客户端配置无效: %q":                        "Invalid client settings: %q",
+	"创建服务端根目录失败: %w":                     "Failed to create server root directory: %w",
+	"服务端模式已启用，接收目录: %s":                  "Server mode enabled, receive directory: %s",
+	"请求路径无效: %s":                         "Invalid request path: %s",
+	"接收客户端文件失败 %s: %v":                   "Failed to receive client file %s: %v",
+	"缺少修改时间":                             "missing modification time",
+	"已接收客户端文件: %s -> %s":                 "Received client file: %s -> %s",
+	"接收文件内容失败: %w":                       "Failed to receive file contents: %w",
+	"缺少文件哈希":                             "missing file hash",
+	"文件哈希不匹配: 期望 %s, 实际 %s":              "File hash mismatch: expected %s, got %s",
+	"清除目标文件的不可变属性失败: %w":                 "Failed to clear the immutable attribute of the target file: %w",
+	"文件大小无效":                             "invalid file size",
+	"创建上传会话: %s, 主机: %s, 目标: %s, 大小: %d": "Created upload session: %s, host: %s, target: %s, size: %d",
+	"偏移量无效":                              "invalid offset",
+	"偏移量不一致":                             "offset mismatch",
+	"接收数据中断":                             "data transfer interrupted",
+	"完成上传失败 %s: %v":                      "Failed to complete upload %s: %v",
+	"读取上传数据失败: %w":                       "Failed to read upload data: %w",
+	"清理过期的上传会话: %s":                      "Removing expired upload session: %s",
+	"打开上传数据失败: %w":                       "Failed to open upload data: %w",
+	"移动上传数据失败: %w":                       "Failed to move upload data: %w",
+
+	// service
+	"当前平台不支持 Windows 服务":         "Windows services are not supported on this platform",
+	"连接服务控制管理器失败，请通过服务管理器启动: %w": "Failed to connect to the service control manager, start it through the service manager: %w",
+	"注册服务控制处理函数失败: %v":           "Failed to register the service control handler: %v",
+	"更新服务状态失败: %v":               "Failed to update service status: %v",
+	"Neo NAS 备份服务，监控源目录并自动备份":    "Neo NAS backup service, watches source directories and backs them up automatically",
+	"sc %s 失败: %w, %s":           "sc %s failed: %w, %s",
+
+	// smb
+	"服务器的 NTLM 质询格式错误":       "malformed NTLM challenge from the server",
+	"SMB 连接 %s 已断开，重新连接: %v": "SMB connection %s was lost, reconnecting: %v",
+	"收到格式错误的 SMB 消息":         "received a malformed SMB message",
+	"解密 SMB 消息失败":            "failed to decrypt SMB message",
+	"收到无法解密的 SMB 消息":         "received an SMB message that cannot be decrypted",
+	"SMB 响应长度不足":             "SMB response is too short",
+	"共享要求加密，但会话没有协商加密，请配置用户名和密码并使用 SMB 3.1.1":            "the share requires encryption but the session did not negotiate it; configure a username and password and use SMB 3.1.1",
+	"服务器要求加密，但没有协商到支持的加密算法（只支持 SMB 3.1.1 的 AES-128-GCM）": "the server requires encryption but no supported cipher was negotiated (only AES-128-GCM with SMB 3.1.1 is supported)",
+	"服务器响应的签名无效": "invalid signature on server response",
+	"服务器要求加密，来宾或匿名登录无法加密，请配置用户名和密码":        "the server requires encryption, which is not possible with a guest or anonymous login; configure a username and password",
+	"服务器的认证响应格式错误":                         "malformed authentication response from the server",
+	"服务器不支持 SMB2 或更高版本的协议":                 "the server does not support SMB2 or later",
+	"连接 SMB 共享 %s 失败: %w":                  "failed to connect to SMB share %s: %w",
+	"登录 SMB 服务器 %s 失败: %w":                 "failed to log in to SMB server %s: %w",
+	"与 SMB 服务器 %s 协商协议失败: %w":              "failed to negotiate protocol with SMB server %s: %w",
+	"连接 SMB 服务器 %s 失败: %w":                 "failed to connect to SMB server %s: %w",
+	"SMB 连接已断开，文件句柄已失效":                    "SMB connection was lost, the file handle is no longer valid",
+	"不是 SMB 目标: %s":                        "Not an SMB target: %s",
+	"SMB 目标格式应为 smb://<服务器>/<共享>/<路径>: %s": "SMB target must be smb://<server>/<share>/<path>: %s",
+	"SMB 目标路径无效: %s":                       "Invalid SMB target path: %s",
+	"读取 SMB 登录信息失败: %w":                    "Failed to read SMB credentials: %w",
+	"SMB 登录信息格式错误: %s":                     "Malformed SMB credentials: %s",
+	"挂载 SMB 共享 %s 失败: %w":                  "Failed to mount SMB share %s: %w",
+	"已挂载 SMB 共享 %s 到 %s":                   "Mounted SMB share %s at %s",
+	"卸载 SMB 共享 %s 失败: %v":                  "Failed to unmount SMB share %s: %v",
+	"已卸载 SMB 共享 %s":                        "Unmounted SMB share %s",
+	"创建挂载点失败: %w":                          "Failed to create mount point: %w",
+	"当前系统不支持 smb:// 目标，请先挂载共享后使用本地目录":      "smb:// targets are not supported on this system, mount the share first and use a local directory",
+
+	// snapshot
+	"获取目标文件系统类型失败: %w":               "Failed to get target file system type: %w",
+	"目标目录不在 btrfs 上: %s (%s)":        "Target directory is not on btrfs: %s (%s)",
+	"目标目录不是 btrfs 子卷: %w":            "Target directory is not a btrfs subvolume: %w",
+	"创建快照目录失败: %w":                   "Failed to create snapshot directory: %w",
+	"快照已存在: %s":                      "Snapshot already exists: %s",
+	"设置快照目录时间失败: %v":                 "Failed to set snapshot directory times: %v",
+	"创建硬链接失败: %w":                    "Failed to create hard link: %w",
+	"目标文件系统不支持快照，不创建快照: %s (%s)":     "The target file system does not support snapshots, not creating one: %s (%s)",
+	"不支持的快照类型: %s":                   "Unsupported snapshot type: %s",
+	"快照保留数量不能为负数":                    "Snapshot keep count must not be negative",
+	"快照名称模板必须包含 {date} 和 {time}: %s": "Snapshot name template must contain {date} and {time}: %s",
+	"快照名称模板不能包含 /、@ 或空格: %s":         "Snapshot name template must not contain /, @ or spaces: %s",
+	"创建快照失败: %w":                     "Failed to create snapshot: %w",
+	"已创建快照: %s@%s":                   "Snapshot created: %s@%s",
+	"列出快照失败: %w":                     "Failed to list snapshots: %w",
+	"快照名称无效: %s":                     "Invalid snapshot name: %s",
+	"快照不存在: %s":                      "Snapshot does not exist: %s",
+	"删除快照失败 %s: %w":                  "Failed to delete snapshot %s: %w",
+	"已删除旧快照: %s@%s":                  "Old snapshot deleted: %s@%s",
+	"找不到目标目录所在的 zfs 数据集: %w":         "Cannot find the zfs dataset of the target directory: %w",
+	"zfs 数据集不存在: %w":                 "zfs dataset does not exist: %w",
+	"找不到目标目录所在的 zfs 数据集: %s":         "Cannot find the zfs dataset of the target directory: %s",
+	"获取 zfs 挂载点失败: %w":               "Failed to get zfs mount point: %w",
+	"zfs 数据集没有挂载: %s (%s)":           "zfs dataset is not mounted: %s (%s)",
+	"目标目录不在数据集的挂载点下: %s":             "Target directory is not under the dataset's mount point: %s",
+
+	// status
+	"状态接口已启用: %s": "Status API enabled: %s",
+
+	// throttle
+	"带宽限制不能为负数: %d":       "Bandwidth limit must not be negative: %d",
+	"无法识别的星期: %s":         "Unrecognized weekday: %s",
+	"时间格式无效，应为 HH:MM: %s": "Invalid time format, expected HH:MM: %s",
+	"带宽限制: %d KB/s":       "Bandwidth limit: %d KB/s",
+	"带宽限制已解除":             "Bandwidth limit lifted",
+
+	// tier
+	"迁移目标目录不能为空":       "Tiering target directory must not be empty",
+	"迁移天数必须大于 0":       "Tiering days must be greater than 0",
+	"解析目标目录失败: %w":     "Failed to resolve target directory: %w",
+	"停止冷存储迁移: %s":      "Stopping cold storage tiering: %s",
+	"冷存储迁移失败 %s: %v":   "Cold storage tiering failed %s: %v",
+	"目标目录不存在: %s":      "Target directory does not exist: %s",
+	"迁移到冷存储: %s -> %s": "Moving to cold storage: %s -> %s",
+	"迁移到冷存储失败 %s: %v":  "Failed to move to cold storage %s: %v",
+	"冷存储迁移完成: %s -> %s, 迁移: %d 个文件, 共 %d 字节, 失败: %d": "Cold storage tiering finished: %s -> %s, moved: %d files, %d bytes in total, failed: %d",
+	"文件哈希与目录索引不一致":                                   "File hash does not match the directory catalog",
+	"删除原文件失败: %w":                                    "Failed to delete original file: %w",
+	"已迁移到冷存储: %s -> %s/%s":                           "Moved to cold storage: %s -> %s/%s",
+	"序列化记录文件失败: %w":                                  "Failed to serialize record file: %w",
+	"写入记录文件失败: %w":                                   "Failed to write record file: %w",
+	"解析记录文件失败: %w":                                   "Failed to parse record file: %w",
+	"删除记录文件失败: %v":                                   "Failed to delete record file: %v",
+	"从冷存储读取失败: %w":                                   "Failed to read from cold storage: %w",
+	"冷存储中的文件哈希不匹配: 期望 %s, 实际 %s":                     "File hash in cold storage does not match: expected %s, got %s",
+	"恢复文件失败: %w":                                     "Failed to restore file: %w",
+	"已从冷存储取回: %s/%s -> %s":                           "Retrieved from cold storage: %s/%s -> %s",
+
+	// verify
+	"未知的校验方式: %s":       "Unknown verify method: %s",
+	"源目录不可用: %w":        "Source directory unavailable: %w",
+	"目标目录不可用: %w":       "Target directory unavailable: %w",
+	"读取冷存储记录失败: %v":     "Failed to read cold storage record: %v",
+	"源 %d 字节, 备份 %d 字节": "source %d bytes, backup %d bytes",
+	"源 %s, 备份 %s":       "source %s, backup %s",
+	"读取源文件失败: %v":       "failed to read source file: %v",
+	"读取备份失败: %v":        "failed to read backup: %v",
+
+	// version
+	"发布信息地址无效: %w":        "Invalid release feed address: %w",
+	"查询最新版本失败: %w":        "Failed to query the latest version: %w",
+	"查询最新版本失败: %s":        "Failed to query the latest version: %s",
+	"解析发布信息失败: %w":        "Failed to parse release information: %w",
+	"发布信息中没有版本号":          "No version number in the release information",
+	"检查新版本失败: %s":         "Failed to check for a new version: %s",
+	"有新版本可用: %s（当前 %s）%s": "A new version is available: %s (current %s) %s",
+
+	// watcher
+	"保存备份状态失败: %v":                   "Failed to save backup state: %v",
+	"执行 eject_hook 失败 %s %s: %v: %s": "eject_hook failed %s %s: %v: %s",
+	"备份完成，已弹出 %s，可以拔出: %s":           "Backup finished, ejected %s, safe to unplug: %s",
+	"已卸载 %s，但没有断开电源: %v":             "Unmounted %s but did not power it off: %v",
+	"弹出源目录所在的设备失败 %s: %v":            "Failed to eject the device holding the source directory %s: %v",
+	"弹出源目录所在的设备: %s":                 "Ejecting the device holding the source directory: %s",
+	"扫描完成，已卸载 %s":                    "Scan finished, unmounted %s",
+	"卸载 %s 失败: %v":                   "Failed to unmount %s: %v",
+	"已挂载 %s 到 %s":                    "Mounted %s at %s",
+	"挂载 %s 到 %s 失败: %w":              "Failed to mount %s at %s: %w",
+	"自动挂载只能用于按 UUID 或卷标指定的源目录":       "Automount can only be used with a source given by UUID or label",
+	"源目录 %s 当前位于: %s":                "Source %s is currently at: %s",
+	"查找源目录所在的卷失败: %w":                "Failed to find the volume of the source: %w",
+	"监控已暂停，忽略扫描请求: %s":               "Watching is paused, ignoring scan request: %s",
+	"收到扫描请求: %s":                     "Scan request received: %s",
+	"检查目录失败: %v":                     "Failed to check directory: %v",
+	"已暂停监控: %s":                      "Watching paused: %s",
+	"已恢复监控: %s":                      "Watching resumed: %s",
+	"当前平台不支持实时监控":                    "Real-time watching is not supported on this platform",
+	"初始化 inotify 失败: %w":             "Failed to initialize inotify: %w",
+	"监控的目录数量达到上限，可以调大 fs.inotify.max_user_watches: %w": "The number of watched directories reached the limit, consider raising fs.inotify.max_user_watches: %w",
+	"启动 FSEvents 监控失败: %s":                             "Failed to start FSEvents watching: %s",
+	"编译时没有启用 cgo，无法使用 FSEvents":                        "Built without cgo, FSEvents is not available",
+	"创建完成端口失败: %w":                                     "Failed to create I/O completion port: %w",
+	"无法启用实时监控，只在源目录出现时扫描 %s: %v":                       "Cannot enable real-time watching, scanning %s only when the source appears: %v",
+	"源目录位于网络共享上，其他机器上的修改不会产生通知: %s":                    "The source directory is on a network share, changes made on other machines produce no notifications: %s",
+	"已启用实时监控: %s":                                      "Real-time watching enabled: %s",
+	"添加实时监控失败，部分目录的变化要等到下次扫描才会备份 %s: %v":               "Failed to add real-time watches, changes in some directories will be backed up at the next scan %s: %v",
+	"实时监控的通知过多，部分变化已丢失，重新扫描: %s":                       "Too many real-time notifications, some changes were lost, rescanning: %s",
+	"备份文件失败: %v":                                       "Failed to back up file: %v",
+	"创建目录: %s":                                         "Creating directory: %s",
+	"扫描新目录失败 %s: %v":                                   "Failed to scan new directory %s: %v",
+	"源目录不存在":                                           "source directory does not exist",
+	"扫描已中止":                                            "scan aborted",
+	"未配置 SMB 挂载: %s":                                   "SMB mount not configured: %s",
+	"推送到服务端或远程存储的目标不支持快照":                              "Targets pushed to a server or remote storage do not support snapshots",
+	"硬链接快照不能与不可变属性同时使用":                                "Hard link snapshots cannot be combined with the immutable attribute",
+	"停止监控目录: %s":                                       "Stopped watching directory: %s",
+	"实时监控已停止: %s":                                      "Real-time watching stopped: %s",
+	"检测到源目录已离线：%s":                                     "Source directory went offline: %s",
+	"检查源目录失败: %w":                                      "Failed to check source directory: %w",
+	"检测到源目录已创建或挂载，开始监控: %s":                            "Source directory created or mounted, starting to watch: %s",
+	"源目录为空，但目标中已有备份，可能是共享未挂载":                          "the source directory is empty but the target already has backups, the share may not be mounted",
+	"网络共享暂时不可用，等待恢复: %s, 原因: %v":                       "Network share temporarily unavailable, waiting for it to recover: %s, reason: %v",
+	"网络共享已恢复: %s":                                      "Network share recovered: %s",
+	"网络共享不可用: %s":                                      "Network share unavailable: %s",
+	"从目标目录导入已有备份: %s":                                  "Seeding existing backups from the target directory: %s",
+	"从目标目录导入失败: %v":                                    "Failed to seed from the target directory: %v",
+	"开始扫描目录: %s":                                       "Starting directory scan: %s",
+	"程序正在停止，扫描已中止: %s, 扫描数量: %d, 同步成功: %d, 失败: %d, 跳过: %d":     "Program stopping, scan aborted: %s, scanned: %d, synced: %d, failed: %d, skipped: %d",
+	"目录扫描失败: %s, 扫描数量: %d, 同步成功: %d, 失败: %d, 跳过: %d, 错误原因: %v": "Directory scan failed: %s, scanned: %d, synced: %d, failed: %d, skipped: %d, reason: %v",
+	"目录扫描完成: %s, 扫描数量: %d, 同步成功: %d, 失败: %d, 跳过: %d":           "Directory scan finished: %s, scanned: %d, synced: %d, failed: %d, skipped: %d",
+	"写入校验文件失败: %v": "Failed to write checksum file: %v",
+	"创建快照: %s":     "Creating snapshot: %s",
+	"快照失败: %v":     "Snapshot failed: %v",
+	"删除目标目录失败: %v": "Failed to delete target directory: %v",
+
+	// webdav
+	"只读": "read-only",
+	"读写": "read-write",
+	"WebDAV 已启用（%s），共 %d 个目录": "WebDAV enabled (%s), %d directories",
+	"父目录不存在":                  "parent directory does not exist",
+	"WebDAV 写入文件: %s":         "WebDAV wrote file: %s",
+	"WebDAV 删除: %s":           "WebDAV deleted: %s",
+	"Destination 无效":          "invalid Destination",
+	"源与目标相同":                  "source and destination are the same",
+	"目标已存在":                   "destination already exists",
+
+	// xattr
+	"读取扩展属性失败: %w":     "Failed to read extended attributes: %w",
+	"读取扩展属性 %s 失败: %w": "Failed to read extended attribute %s: %w",
+	"写入扩展属性 %s 失败: %w": "Failed to write extended attribute %s: %w",
+
+	// zip
+	"按 remote_keep %d 删除了上传位置中的旧压缩文件: %s/%s": "Deleted old archive from upload location per remote_keep %d: %s/%s",
+	"生成随机数失败: %w":                          "Failed to generate random data: %w",
+	"条目已加密，需要密钥: %s":                       "Entry is encrypted and requires a key: %s",
+	"加密条目已损坏: %s":                          "Encrypted entry is damaged: %s",
+	"读取加密条目失败: %w":                         "Failed to read encrypted entry: %w",
+	"密钥错误: %s":                             "Wrong key: %s",
+	"不支持的压缩方法 %d: %s":                      "Unsupported compression method %d: %s",
+	"只支持 AES-256 加密":                       "Only AES-256 encryption is supported",
+	"缺少 AES 扩展字段":                          "Missing AES extra field",
+	"读取校验码失败: %w":                          "Failed to read authentication code: %w",
+	"校验码不匹配，内容可能已损坏":                       "Authentication code mismatch, the content may be damaged",
+	"%s 格式不支持加密，需要加密时使用 zip 格式":            "The %s format does not support encryption, use the zip format when encryption is needed",
+	"tar.zst 格式需要安装 zstd 命令: %w":           "The tar.zst format requires the zstd command: %w",
+	"不支持的压缩格式: %s，可选 zip、tar.gz 或 tar.zst": "Unsupported archive format: %s, choose zip, tar.gz or tar.zst",
+	"压缩线程数不能小于 0: %d":                      "Compression thread count must not be less than 0: %d",
+	"max_total_bytes 不能小于 0: %d":           "max_total_bytes must not be less than 0: %d",
+	"不支持的压缩级别: %s，可选 store、fast 或 best":    "Unsupported compression level: %s, choose store, fast or best",
+	"创建压缩文件中的目录失败: %w":                     "Failed to create directory in the zip file: %w",
+	"创建压缩文件中的文件失败: %w":                     "Failed to create file in the zip file: %w",
+	"复制文件内容到压缩文件失败: %w":                    "Failed to copy file contents into the zip file: %w",
+	"复制文件内容到压缩文件失败: 源文件在压缩过程中变短: %s":       "Failed to copy file contents into the zip file: the source file shrank while being zipped: %s",
+	"启动 zstd 失败: %w":                       "Failed to start zstd: %w",
+	"读取压缩任务状态失败: %v":                       "Failed to read zip task state: %v",
+	"解析压缩任务状态失败，下次压缩时生成完整的压缩文件: %v":        "Failed to parse zip task state, the next run will create a full zip file: %v",
+	"写入压缩任务状态失败: %w":                       "Failed to write zip task state: %w",
+	"删除旧的增量压缩文件失败: %v":                     "Failed to delete old incremental zip file: %v",
+	"已删除旧的增量压缩文件: %s":                      "Old incremental zip file deleted: %s",
+	"压缩任务的 cron 表达式无效，不定时执行 %s: %v":        "Invalid cron expression for zip task, not scheduling it %s: %v",
+	"等待压缩任务完成超时，中止压缩: %s":                  "Timed out waiting for the zip task to finish, aborting: %s",
+	"压缩任务失败，源路径: %s, 目标路径: %s, 错误原因: %v":   "Zip task failed, source: %s, target: %s, reason: %v",
+	"源路径不存在: %w":                           "Source path does not exist: %w",
+	"写入压缩文件: %s -> %s":                     "Writing zip file: %s -> %s",
+	"上传压缩文件: %s -> %s":                     "Uploading zip file: %s -> %s",
+	"执行压缩任务，源路径: %s, 目标路径: %s":             "Running zip task, source: %s, target: %s",
+	"增量压缩，只写入 %s 之后修改的文件: %s":              "Incremental zip, only writing files modified after %s: %s",
+	"上次压缩之后没有修改的文件，不生成增量压缩文件: %s":          "No files modified since the last zip, no incremental zip file created: %s",
+	"设置压缩文件所有者失败: %v":                      "Failed to set zip file owner: %v",
+	"压缩任务完成，源路径: %s, 目标路径: %s, 文件: %d":     "Zip task finished, source: %s, target: %s, files: %d",
+	"生成恢复数据失败: %w":                         "Failed to generate recovery data: %w",
+	"恢复数据已生成: %s":                          "Recovery data generated: %s",
+	"上传压缩文件失败: %w":                         "Failed to upload zip file: %w",
+	"创建压缩文件所在的目录失败: %w":                    "Failed to create the zip file's directory: %w",
+	"创建压缩文件失败: %w":                         "Failed to create zip file: %w",
+	"关闭压缩文件失败: %w":                         "Failed to close zip file: %w",
+	"校验压缩文件失败: %w":                         "Failed to verify zip file: %w",
+	"替换压缩文件失败: %w":                         "Failed to replace zip file: %w",
+	"跳过不是普通文件的源文件: %s":                     "Skipping source entry that is not a regular file: %s",
+	"程序正在停止，压缩已中止，已删除不完整的压缩文件":             "Program stopping, zip aborted, incomplete zip file deleted",
+	"压缩文件失败: %w":                           "Failed to zip file: %w",
+	"创建加密文件失败: %w":                         "Failed to create encrypted file: %w",
+	"写入加密文件失败: %w":                         "Failed to write encrypted file: %w",
+	"压缩文件已上传并校验: %s -> %s/%s":              "Zip file uploaded and verified: %s -> %s/%s",
+	"未知": "unknown",
+	"压缩进度: %s, %d%% (%d / %d 字节), 当前文件: %s, 预计剩余: %s": "Zip progress: %s, %d%% (%d / %d bytes), current file: %s, time remaining: %s",
+	"最新的压缩文件已超过 max_total_bytes %d: %s, 大小: %d":       "The newest zip file already exceeds max_total_bytes %d: %s, size: %d",
+	"打开上传位置失败，不删除旧压缩文件的副本: %v":                        "Failed to open upload location, not deleting copies of old zip files: %v",
+	"删除旧压缩文件失败: %v":                                   "Failed to delete old zip file: %v",
+	"已删除旧压缩文件: %s":                                    "Old zip file deleted: %s",
+	"删除上传的旧压缩文件失败: %v":                                "Failed to delete uploaded old zip file: %v",
+	"上传已结束": "upload finished",
+	"目标为远程存储时不支持增量压缩":                                           "Incremental zips are not supported for remote storage targets",
+	"目标为远程存储时不支持 keep，可以在 target 中使用 {date} 或 {time} 每次生成不同的对象": "keep is not supported for remote storage targets, use {date} or {time} in target to create a different object each time",
+	"目标为远程存储时不支持 max_total_bytes":                               "max_total_bytes is not supported for remote storage targets",
+	"目标为远程存储时不支持生成恢复数据":                                         "Generating recovery data is not supported for remote storage targets",
+	"目标为远程存储时不支持设置所有者":                                          "Setting the owner is not supported for remote storage targets",
+	"目标为远程存储时不需要设置 upload":                                      "upload is not needed for remote storage targets",
+	"远程压缩文件路径中缺少文件名: %s":                                        "Remote zip path is missing a file name: %s",
+	"校验远程压缩文件失败: %w":                                            "Failed to verify remote zip file: %w",
+	"压缩文件已上传并校验: %s, 大小: %d":                                    "Zip file uploaded and verified: %s, size: %d",
+	"%s: 大小与记录的不一致":                                             "%s: size does not match the record",
+	"读取压缩文件失败: %w":                                              "Failed to read zip file: %w",
+	"压缩文件中有多余的条目: %s":                                           "Unexpected entry in zip file: %s",
+	"压缩文件中缺少条目: %s":                                             "Entry missing from zip file: %s",
+	"压缩文件中有重复的条目":                                               "Duplicate entries in zip file",
+}
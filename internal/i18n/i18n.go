@@ -0,0 +1,129 @@
+package i18n
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+)
+
+// 界面语言的名称
+const (
+	Chinese = "zh"
+	English = "en"
+)
+
+// english 是否输出英文，默认输出中文
+var english atomic.Bool
+
+// SetLanguage 设置日志、错误和命令输出的语言，为空时使用中文
+func SetLanguage(name string) error {
+	if err := Check(name); err != nil {
+		return err
+	}
+	english.Store(strings.HasPrefix(strings.ToLower(name), English))
+	return nil
+}
+
+// Check 检查语言名称，可选 zh 或 en，也接受 zh-CN、en-US 等带地区的写法
+func Check(name string) error {
+	lang, _, _ := strings.Cut(strings.ToLower(name), "-")
+	lang, _, _ = strings.Cut(lang, "_")
+	switch lang {
+	case "", Chinese, English:
+		return nil
+	default:
+		return Errorf("不支持的语言: %s，可选 zh 或 en", name)
+	}
+}
+
+// T 翻译一条消息，s 为源码中的中文原文；当前为中文或没有对应的译文时原样返回
+func T(s string) string {
+	if !english.Load() {
+		return s
+	}
+	if t, ok := en[s]; ok {
+		return t
+	}
+	return s
+}
+
+// Sprintf 与 fmt.Sprintf 相同，先翻译 format
+func Sprintf(format string, args ...any) string {
+	return fmt.Sprintf(T(format), args...)
+}
+
+// Printf 与 fmt.Printf 相同，先翻译 format
+func Printf(format string, args ...any) (int, error) {
+	return fmt.Printf(T(format), args...)
+}
+
+// Fprintf 与 fmt.Fprintf 相同，先翻译 format
+func Fprintf(w io.Writer, format string, args ...any) (int, error) {
+	return fmt.Fprintf(w, T(format), args...)
+}
+
+// Println 与 fmt.Println 相同，翻译第一个参数
+func Println(args ...any) (int, error) {
+	return fmt.Println(translateFirst(args)...)
+}
+
+// Fprintln 与 fmt.Fprintln 相同，翻译第一个参数
+func Fprintln(w io.Writer, args ...any) (int, error) {
+	return fmt.Fprintln(w, translateFirst(args)...)
+}
+
+func translateFirst(args []any) []any {
+	if len(args) > 0 {
+		if s, ok := args[0].(string); ok {
+			args = append([]any{T(s)}, args[1:]...)
+		}
+	}
+	return args
+}
+
+// Errorf 与 fmt.Errorf 相同，errors.Is 和 errors.As 照常使用；输出时才翻译，语言在错误产生之后设置时同样生效
+func Errorf(format string, args ...any) error {
+	return &message{format: format, args: args, err: fmt.Errorf(format, args...)}
+}
+
+// New 与 errors.New 相同，可以用于包级别的错误变量，输出时才翻译
+func New(text string) error {
+	return &message{format: text, literal: true, err: fmt.Errorf("%s", text)}
+}
+
+// message 输出时按当前语言翻译的错误
+type message struct {
+	format  string
+	args    []any
+	literal bool  // 由 New 创建，format 不是格式字符串
+	err     error // fmt.Errorf 生成的中文错误，用于中文输出和 Unwrap
+}
+
+func (m *message) Error() string {
+	if !english.Load() {
+		return m.err.Error()
+	}
+	t, ok := en[m.format]
+	if !ok {
+		return m.err.Error()
+	}
+	if m.literal {
+		return t
+	}
+	// 被包装的错误以 %v 输出，其中的 message 同样按当前语言翻译
+	return fmt.Sprintf(strings.ReplaceAll(t, "%w", "%v"), m.args...)
+}
+
+// Unwrap 返回 format 中 %w 对应的错误
+func (m *message) Unwrap() []error {
+	switch u := m.err.(type) {
+	case interface{ Unwrap() []error }:
+		return u.Unwrap()
+	case interface{ Unwrap() error }:
+		if err := u.Unwrap(); err != nil {
+			return []error{err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package immutable
+
+import "github.com/lucasrui/neo-nas/internal/i18n"
+
+// ErrUnsupported 当前平台或文件系统不支持不可变属性
+var ErrUnsupported = i18n.New("不支持不可变属性")
+
+// Clear 清除不可变属性，文件不存在或不支持时不报错，程序自身需要修改或删除文件前调用
+func Clear(path string) error {
+	set, err := IsSet(path)
+	if err != nil || !set {
+		return nil
+	}
+	return setFlag(path, false)
+}
+
+// Set 设置不可变属性，之后文件不能被修改、删除或重命名，包括 root 用户
+func Set(path string) error {
+	return setFlag(path, true)
+}
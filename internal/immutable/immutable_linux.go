@@ -0,0 +1,67 @@
+package immutable
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// 对应内核的 FS_IOC_GETFLAGS / FS_IOC_SETFLAGS，参数大小按 long 计算
+var (
+	iocGetFlags = 2<<30 | unsafe.Sizeof(uintptr(0))<<16 | 'f'<<8 | 1
+	iocSetFlags = 1<<30 | unsafe.Sizeof(uintptr(0))<<16 | 'f'<<8 | 2
+)
+
+// 对应内核的 FS_IMMUTABLE_FL，即 chattr +i
+const immutableFlag = 0x00000010
+
+func getFlags(f *os.File) (int32, error) {
+	var flags int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), iocGetFlags, uintptr(unsafe.Pointer(&flags))); errno != 0 {
+		return 0, convert(f.Name(), errno)
+	}
+	return flags, nil
+}
+
+// IsSet 判断文件是否设置了不可变属性
+func IsSet(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	flags, err := getFlags(f)
+	if err != nil {
+		return false, err
+	}
+	return flags&immutableFlag != 0, nil
+}
+
+// setFlag 需要 CAP_LINUX_IMMUTABLE 权限，通常只有 root 用户可以设置
+func setFlag(path string, on bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	flags, err := getFlags(f)
+	if err != nil {
+		return err
+	}
+	if on {
+		flags |= immutableFlag
+	} else {
+		flags &^= immutableFlag
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), iocSetFlags, uintptr(unsafe.Pointer(&flags))); errno != 0 {
+		return convert(f.Name(), errno)
+	}
+	return nil
+}
+
+func convert(path string, errno syscall.Errno) error {
+	if errno == syscall.ENOTTY || errno == syscall.EOPNOTSUPP || errno == syscall.EINVAL {
+		return ErrUnsupported
+	}
+	return &os.PathError{Op: "ioctl", Path: path, Err: errno}
+}
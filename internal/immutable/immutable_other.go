@@ -0,0 +1,12 @@
+//go:build !linux
+
+package immutable
+
+// IsSet 只有 Linux 支持不可变属性，其他平台始终返回 false
+func IsSet(path string) (bool, error) {
+	return false, nil
+}
+
+func setFlag(path string, on bool) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,184 @@
+package instance
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+)
+
+// logger instance 模块的日志
+var logger = logging.New("instance")
+
+// 锁文件和控制套接字都放在配置目录中，按机器名区分
+// 配置目录可以被多台机器共用，同一台机器同时只能运行一个实例
+func lockFile(dir, host string) string { return filepath.Join(dir, ".neo-nas-"+host+".lock") }
+
+func socketFile(dir, host string) string { return filepath.Join(dir, ".neo-nas-"+host+".sock") }
+
+// ErrRunning 已有实例使用同一配置目录运行
+var ErrRunning = i18n.New("已有实例在使用该配置目录运行")
+
+// errLocked 锁已被其他进程持有
+var errLocked = i18n.New("锁已被占用")
+
+// Lock 配置目录的单实例锁，进程退出时由系统自动释放
+type Lock struct {
+	dir      string
+	host     string
+	file     *os.File
+	ln       net.Listener
+	mu       sync.Mutex
+	handlers map[string]func(arg string) string
+}
+
+// Acquire 获取配置目录的锁，已有实例运行时返回包含其进程号的 ErrRunning
+func Acquire(dir, host string) (*Lock, error) {
+	file := lockFile(dir, host)
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, i18n.Errorf("打开锁文件失败: %w", err)
+	}
+	if err := lock(f); err != nil {
+		f.Close()
+		if err == errLocked {
+			if pid := readPid(file); pid > 0 {
+				return nil, i18n.Errorf("%w，进程号: %d", ErrRunning, pid)
+			}
+			return nil, ErrRunning
+		}
+		return nil, i18n.Errorf("获取锁失败: %w", err)
+	}
+	// 锁文件中记录进程号，只用于提示，是否有实例运行以锁为准
+	f.Truncate(0)
+	f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return &Lock{dir: dir, host: host, file: f}, nil
+}
+
+func readPid(file string) int {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
+
+// Handle 注册控制套接字上的命令，收到 "<name> <参数>" 时调用 fn，返回值作为回复
+func (l *Lock) Handle(name string, fn func(arg string) string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.handlers == nil {
+		l.handlers = make(map[string]func(arg string) string)
+	}
+	l.handlers[name] = fn
+}
+
+func (l *Lock) handler(name string) func(arg string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.handlers[name]
+}
+
+// Listen 打开控制套接字，收到其他实例的停止请求时关闭返回的通道，其他命令交给 Handle 注册的函数处理
+func (l *Lock) Listen() (<-chan struct{}, error) {
+	path := socketFile(l.dir, l.host)
+	// 持有锁说明之前的实例已经退出，残留的套接字文件可以直接删除
+	os.Remove(path)
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, i18n.Errorf("打开控制套接字失败: %w", err)
+	}
+	l.ln = ln
+	shutdown := make(chan struct{})
+	go func() {
+		closed := false
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			cmd, _ := bufio.NewReader(conn).ReadString('\n')
+			switch strings.TrimSpace(cmd) {
+			case "shutdown":
+				fmt.Fprintln(conn, "ok")
+				if !closed {
+					logger.Info("收到新实例的接管请求，准备停止")
+					close(shutdown)
+					closed = true
+				}
+			default:
+				name, arg, _ := strings.Cut(strings.TrimSpace(cmd), " ")
+				if fn := l.handler(name); fn != nil {
+					fmt.Fprintln(conn, fn(arg))
+				} else {
+					fmt.Fprintln(conn, "unknown")
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return shutdown, nil
+}
+
+// Release 关闭控制套接字并释放锁，l 为 nil 时不做任何操作
+func (l *Lock) Release() {
+	if l == nil {
+		return
+	}
+	if l.ln != nil {
+		l.ln.Close()
+		os.Remove(socketFile(l.dir, l.host))
+	}
+	l.file.Close()
+}
+
+// Takeover 请求正在运行的实例停止，并在 timeout 内等待其释放锁
+func Takeover(dir, host string, timeout time.Duration) (*Lock, error) {
+	l, err := Acquire(dir, host)
+	if !errors.Is(err, ErrRunning) {
+		return l, err
+	}
+	reply, err := Send(dir, host, "shutdown")
+	if err != nil {
+		return nil, err
+	}
+	if reply != "ok" {
+		return nil, i18n.Errorf("正在运行的实例拒绝了停止请求")
+	}
+	logger.Info("已请求正在运行的实例停止，等待其退出")
+
+	deadline := time.Now().Add(timeout)
+	for {
+		l, err := Acquire(dir, host)
+		if !errors.Is(err, ErrRunning) {
+			return l, err
+		}
+		if time.Now().After(deadline) {
+			return nil, i18n.Errorf("等待正在运行的实例退出超时: %w", err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// Send 通过控制套接字向正在运行的实例发送一行命令，返回其回复
+func Send(dir, host, cmd string) (string, error) {
+	conn, err := net.DialTimeout("unix", socketFile(dir, host), 5*time.Second)
+	if err != nil {
+		return "", i18n.Errorf("无法连接正在运行的实例，可能是单次运行模式或旧版本: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprintln(conn, cmd)
+	reply, _ := bufio.NewReader(conn).ReadString('\n')
+	return strings.TrimSpace(reply), nil
+}
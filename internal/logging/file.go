@@ -0,0 +1,243 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// 日志文件轮转的默认设置
+const (
+	DefaultMaxBytes = 10 << 20 // 单个日志文件的大小上限
+	DefaultMaxFiles = 10       // 保留的旧日志文件数量
+)
+
+// FileOptions 日志文件及其轮转设置
+type FileOptions struct {
+	Path     string        // 日志文件路径
+	MaxBytes int64         // 超过后轮转，0 使用 DefaultMaxBytes
+	Daily    bool          // 日期变化后轮转，每个文件只包含一天的日志
+	MaxFiles int           // 最多保留的旧日志文件数量，0 使用 DefaultMaxFiles
+	MaxAge   time.Duration // 删除轮转时间早于该时长的旧日志，0 表示不按时间删除
+	Compress bool          // 轮转后用 gzip 压缩旧日志
+}
+
+// file 当前写入的日志文件，没有写入文件时为 nil
+var (
+	fileMu sync.Mutex
+	file   *rotator
+)
+
+// SetFile 把日志写入文件，按 o 轮转和清理旧日志；设置与当前相同时继续使用已打开的文件
+func SetFile(o FileOptions) error {
+	if o.MaxBytes <= 0 {
+		o.MaxBytes = DefaultMaxBytes
+	}
+	if o.MaxFiles <= 0 {
+		o.MaxFiles = DefaultMaxFiles
+	}
+	fileMu.Lock()
+	defer fileMu.Unlock()
+	if file != nil && file.o == o {
+		return nil
+	}
+	r := &rotator{o: o}
+	if err := r.open(); err != nil {
+		return err
+	}
+	old := file
+	file = r
+	SetOutput(r)
+	if old != nil {
+		// SetOutput 之后不会再写入旧文件
+		old.close()
+	}
+	r.tidy()
+	return nil
+}
+
+// rotator 按大小和日期轮转的日志文件，Write 由 output 串行调用
+type rotator struct {
+	o    FileOptions
+	f    *os.File
+	size int64
+	day  string // 最后一次写入的日期，Daily 时用于判断是否轮转
+
+	bg sync.Mutex // 串行执行压缩和清理
+}
+
+func (r *rotator) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.o.Path), 0755); err != nil {
+		return i18n.Errorf("创建日志目录失败: %w", err)
+	}
+	f, err := os.OpenFile(r.o.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return i18n.Errorf("打开日志文件失败: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return i18n.Errorf("打开日志文件失败: %w", err)
+	}
+	r.f, r.size = f, info.Size()
+	r.day = today(info.ModTime())
+	if info.Size() == 0 {
+		r.day = today(time.Now())
+	}
+	return nil
+}
+
+func (r *rotator) close() {
+	if r.f != nil {
+		r.f.Close()
+		r.f = nil
+	}
+}
+
+func (r *rotator) Write(p []byte) (int, error) {
+	now := time.Now()
+	if r.size > 0 && (r.size+int64(len(p)) > r.o.MaxBytes || r.o.Daily && today(now) != r.day) {
+		r.rotate(now)
+	}
+	if r.f == nil {
+		// 上一次轮转后没能重新打开，再试一次
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	r.day = today(now)
+	return n, err
+}
+
+// rotate 把当前文件改名为 <名称>-<时间><扩展名>，然后重新打开；失败时继续写入原来的文件
+// 日志系统自身出错时无法写日志，只输出到标准错误
+func (r *rotator) rotate(now time.Time) {
+	ext := filepath.Ext(r.o.Path)
+	base := strings.TrimSuffix(r.o.Path, ext)
+	name := base + "-" + now.Format("20060102-150405") + ext
+	for i := 1; exists(name) || exists(name+".gz"); i++ {
+		name = fmt.Sprintf("%s-%s.%d%s", base, now.Format("20060102-150405"), i, ext)
+	}
+	r.f.Close()
+	if err := os.Rename(r.o.Path, name); err != nil {
+		i18n.Fprintf(os.Stderr, "轮转日志文件失败: %v\n", err)
+		name = ""
+	}
+	if err := r.open(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		r.f = nil
+		return
+	}
+	if name != "" {
+		go r.tidy()
+	}
+}
+
+// tidy 压缩还没有压缩的旧日志，包括之前的程序退出时没有压缩完的，然后按数量和时间删除旧日志
+func (r *rotator) tidy() {
+	r.bg.Lock()
+	defer r.bg.Unlock()
+	old := r.rotated()
+	if r.o.Compress {
+		for i, name := range old {
+			if strings.HasSuffix(name, ".gz") {
+				continue
+			}
+			if err := compress(name); err != nil {
+				i18n.Fprintf(os.Stderr, "压缩旧日志失败 %s: %v\n", name, err)
+				continue
+			}
+			old[i] = name + ".gz"
+		}
+	}
+	// 按修改时间即轮转时间排序，最新的在前
+	modTimes := make(map[string]time.Time, len(old))
+	for _, name := range old {
+		if info, err := os.Stat(name); err == nil {
+			modTimes[name] = info.ModTime()
+		}
+	}
+	sort.Slice(old, func(i, j int) bool { return modTimes[old[i]].After(modTimes[old[j]]) })
+	for i, name := range old {
+		if i >= r.o.MaxFiles || r.o.MaxAge > 0 && time.Since(modTimes[name]) > r.o.MaxAge {
+			os.Remove(name)
+		}
+	}
+}
+
+// rotated 列出轮转后的旧日志文件
+func (r *rotator) rotated() []string {
+	ext := filepath.Ext(r.o.Path)
+	prefix := filepath.Base(strings.TrimSuffix(r.o.Path, ext)) + "-"
+	entries, err := os.ReadDir(filepath.Dir(r.o.Path))
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		n := e.Name()
+		if e.Type().IsRegular() && strings.HasPrefix(n, prefix) && (strings.HasSuffix(n, ext) || strings.HasSuffix(n, ext+".gz")) {
+			names = append(names, filepath.Join(filepath.Dir(r.o.Path), n))
+		}
+	}
+	return names
+}
+
+// compress 把 name 压缩为 name.gz，先写入临时文件，完成后删除原文件
+func compress(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	tmp := name + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(name)
+	zw.ModTime = info.ModTime()
+	_, err = io.Copy(zw, src)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	// 保留原文件的修改时间，按时间清理时以轮转时间为准
+	os.Chtimes(tmp, info.ModTime(), info.ModTime())
+	if err := os.Rename(tmp, name+".gz"); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	src.Close()
+	return os.Remove(name)
+}
+
+func today(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func exists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
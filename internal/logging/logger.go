@@ -0,0 +1,47 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// Logger 一个模块的日志，在 slog.Logger 的基础上提供按格式输出的方法，消息按 i18n 设置的语言翻译
+type Logger struct {
+	*slog.Logger
+}
+
+// New 创建模块 module 的日志，级别按 SetLevels 中该模块的设置过滤，没有单独设置时使用全局级别
+func New(module string) *Logger {
+	return &Logger{slog.New(&handler{module: module})}
+}
+
+// With 返回附带属性 args 的日志
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{l.Logger.With(args...)}
+}
+
+func (l *Logger) Debug(msg string, args ...any) { l.Logger.Debug(i18n.T(msg), args...) }
+func (l *Logger) Info(msg string, args ...any)  { l.Logger.Info(i18n.T(msg), args...) }
+func (l *Logger) Warn(msg string, args ...any)  { l.Logger.Warn(i18n.T(msg), args...) }
+func (l *Logger) Error(msg string, args ...any) { l.Logger.Error(i18n.T(msg), args...) }
+
+func (l *Logger) Debugf(format string, args ...any) { l.logf(slog.LevelDebug, format, args...) }
+func (l *Logger) Infof(format string, args ...any)  { l.logf(slog.LevelInfo, format, args...) }
+func (l *Logger) Warnf(format string, args ...any)  { l.logf(slog.LevelWarn, format, args...) }
+func (l *Logger) Errorf(format string, args ...any) { l.logf(slog.LevelError, format, args...) }
+
+// logf 级别已关闭时不格式化消息
+func (l *Logger) logf(level slog.Level, format string, args ...any) {
+	ctx := context.Background()
+	if !l.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // 跳过 Callers、logf 和 Infof 等方法
+	r := slog.NewRecord(time.Now(), level, i18n.Sprintf(format, args...), pcs[0])
+	l.Handler().Handle(ctx, r)
+}
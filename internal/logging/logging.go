@@ -0,0 +1,250 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// 日志级别的名称
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+// 日志格式的名称
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// 结构化日志中常用的属性名，JSON 格式的日志可以直接按这些字段过滤和统计
+const (
+	KeyTask     = "task"     // 任务，备份和冷存储迁移为目录，压缩任务为目标路径，同步任务为 "左 <-> 右"
+	KeyFile     = "file"     // 文件路径
+	KeyBytes    = "bytes"    // 字节数
+	KeyDuration = "duration" // 用时，JSON 格式中以秒为单位
+	KeyStatus   = "status"   // 结果，取值为下面的 Status 常量
+	KeyError    = "error"    // 错误原因
+)
+
+// status 属性的取值
+const (
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+	StatusAborted = "aborted"
+)
+
+// jsonFormat 是否以 JSON 格式输出，每条日志一行
+var jsonFormat atomic.Bool
+
+// level 全局的日志级别，低于该级别的日志不输出
+var level = new(slog.LevelVar)
+
+// modules 单独设置了级别的模块，优先于全局级别
+var (
+	modulesMu sync.RWMutex
+	modules   map[string]slog.Level
+)
+
+// out 日志的输出位置，所有 handler 共用
+var out = &output{w: os.Stderr}
+
+// Setup 按级别名称设置全局的日志级别，并把标准库 log 的输出也交给同一个 handler，作为 info 级别输出
+func Setup(name string) error {
+	l, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	level.Set(l)
+	slog.SetDefault(slog.New(&handler{}))
+	return nil
+}
+
+// SetLevels 设置全局的日志级别和各模块的日志级别，moduleLevels 的键为模块名，例如 backup、watcher、zip
+// 出错时不修改当前的级别
+func SetLevels(global string, moduleLevels map[string]string) error {
+	g, err := ParseLevel(global)
+	if err != nil {
+		return err
+	}
+	m := make(map[string]slog.Level, len(moduleLevels))
+	for name, l := range moduleLevels {
+		if m[name], err = ParseLevel(l); err != nil {
+			return i18n.Errorf("模块 %s: %w", name, err)
+		}
+	}
+	level.Set(g)
+	modulesMu.Lock()
+	modules = m
+	modulesMu.Unlock()
+	return nil
+}
+
+// levelFor 模块当前的日志级别
+func levelFor(module string) slog.Level {
+	if module != "" {
+		modulesMu.RLock()
+		l, ok := modules[module]
+		modulesMu.RUnlock()
+		if ok {
+			return l
+		}
+	}
+	return level.Level()
+}
+
+// SetFormat 设置日志格式：text（默认）与标准库 log 的格式相同，json 每行一个 JSON 对象，便于导入 Loki、ELK 等系统
+func SetFormat(name string) error {
+	if err := CheckFormat(name); err != nil {
+		return err
+	}
+	jsonFormat.Store(strings.ToLower(name) == FormatJSON)
+	return nil
+}
+
+// CheckFormat 检查日志格式名称，为空时使用 text
+func CheckFormat(name string) error {
+	switch strings.ToLower(name) {
+	case "", FormatText, FormatJSON:
+		return nil
+	default:
+		return i18n.Errorf("不支持的日志格式: %s，可选 text 或 json", name)
+	}
+}
+
+// ParseLevel 解析日志级别名称，为空时使用 info
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case LevelDebug:
+		return slog.LevelDebug, nil
+	case "", LevelInfo:
+		return slog.LevelInfo, nil
+	case LevelWarn, "warning":
+		return slog.LevelWarn, nil
+	case LevelError:
+		return slog.LevelError, nil
+	default:
+		return 0, i18n.Errorf("不支持的日志级别: %s，可选 debug、info、warn 或 error", name)
+	}
+}
+
+// SetOutput 设置日志的输出位置，SetFile 通过它切换到日志文件
+// 调用 Setup 之后标准库 log 的输出已交给 handler，不能再使用 log.SetOutput
+func SetOutput(w io.Writer) {
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	out.w = w
+}
+
+// output 串行写入日志，每条日志一次写入
+type output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (o *output) write(p []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.w.Write(p)
+}
+
+// Write 供 slog.JSONHandler 使用，JSONHandler 每条日志调用一次
+func (o *output) Write(p []byte) (int, error) {
+	o.write(p)
+	return len(p), nil
+}
+
+// handler 输出与标准库 log 相同格式的日志，info 以外的级别在消息前标明级别
+// 文本格式的消息中已经包含了任务、文件等信息，属性只在 JSON 格式中输出
+type handler struct {
+	module string // 日志所属的模块，按模块的级别过滤
+	attrs  []slog.Attr
+	prefix string // WithGroup 设置的属性名前缀
+}
+
+func (h *handler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= levelFor(h.module)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if jsonFormat.Load() {
+		return h.handleJSON(ctx, r)
+	}
+	var b strings.Builder
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	b.WriteString(t.Format("2006/01/02 15:04:05 "))
+	switch {
+	case r.Level >= slog.LevelError:
+		b.WriteString(i18n.T("[错误] "))
+	case r.Level >= slog.LevelWarn:
+		b.WriteString(i18n.T("[警告] "))
+	case r.Level < slog.LevelInfo:
+		b.WriteString(i18n.T("[调试] "))
+	}
+	b.WriteString(strings.TrimSuffix(r.Message, "\n"))
+	b.WriteByte('\n')
+	out.write([]byte(b.String()))
+	return nil
+}
+
+// handleJSON 交给 slog.JSONHandler 输出，附带模块名；WithGroup 的前缀与文本格式一样加在属性名前
+func (h *handler) handleJSON(ctx context.Context, r slog.Record) error {
+	attrs := h.attrs
+	if h.module != "" {
+		attrs = append([]slog.Attr{slog.String("module", h.module)}, attrs...)
+	}
+	if h.prefix != "" {
+		n := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+		r.Attrs(func(a slog.Attr) bool {
+			a.Key = h.prefix + a.Key
+			n.AddAttrs(a)
+			return true
+		})
+		r = n
+	}
+	jh := slog.NewJSONHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: replaceJSON})
+	return jh.WithAttrs(attrs).Handle(ctx, r)
+}
+
+// replaceJSON 级别使用与配置相同的小写名称，用时转换为秒
+func replaceJSON(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.LevelKey {
+		if l, ok := a.Value.Any().(slog.Level); ok {
+			return slog.String(slog.LevelKey, strings.ToLower(l.String()))
+		}
+	}
+	if a.Value.Kind() == slog.KindDuration {
+		return slog.Float64(a.Key, a.Value.Duration().Seconds())
+	}
+	return a
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	n := &handler{module: h.module, prefix: h.prefix, attrs: append([]slog.Attr(nil), h.attrs...)}
+	for _, a := range attrs {
+		a.Key = h.prefix + a.Key
+		n.attrs = append(n.attrs, a)
+	}
+	return n
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &handler{module: h.module, attrs: h.attrs, prefix: h.prefix + name + "."}
+}
@@ -0,0 +1,59 @@
+package mount
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// AutomountSupported 当前平台是否支持自动挂载卷
+const AutomountSupported = true
+
+// VolumeDevice 返回 UUID 或卷标为指定值的块设备，例如 /dev/sdb1，没有插入时返回 ErrVolumeMissing
+func VolumeDevice(uuid, label string) (string, error) {
+	name, err := volumeDevice(uuid, label)
+	if err != nil {
+		return "", err
+	}
+	return "/dev/" + name, nil
+}
+
+// MountVolume 使用 mount 命令把设备挂载到 dir，dir 不存在时创建；fsType 为空时由 mount 识别文件系统类型
+func MountVolume(device, dir, fsType, options string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return i18n.Errorf("创建挂载点失败: %w", err)
+	}
+	var args []string
+	if fsType != "" {
+		args = append(args, "-t", fsType)
+	}
+	if options != "" {
+		args = append(args, "-o", options)
+	}
+	if err := run("mount", append(args, device, dir)...); err != nil {
+		os.Remove(dir)
+		return err
+	}
+	return nil
+}
+
+// UnmountVolume 卸载 dir，卸载时写回所有缓存的数据，然后删除空的挂载点
+func UnmountVolume(dir string) error {
+	if err := run("umount", dir); err != nil {
+		return err
+	}
+	os.Remove(dir)
+	return nil
+}
+
+// run 执行外部命令，失败时把命令输出附加到错误信息中
+func run(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package mount
+
+// AutomountSupported 当前平台是否支持自动挂载卷
+const AutomountSupported = false
+
+// VolumeDevice 当前平台不支持自动挂载，不查找设备
+func VolumeDevice(uuid, label string) (string, error) {
+	return "", ErrAutomountUnsupported
+}
+
+// MountVolume 当前平台不支持自动挂载
+func MountVolume(device, dir, fsType, options string) error {
+	return ErrAutomountUnsupported
+}
+
+// UnmountVolume 当前平台不支持自动挂载
+func UnmountVolume(dir string) error {
+	return ErrAutomountUnsupported
+}
@@ -0,0 +1,51 @@
+package mount
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// EjectSupported 当前平台是否支持备份完成后弹出设备
+const EjectSupported = true
+
+// Eject 同步并卸载 path 所在的卷，返回其设备，例如 /dev/disk4s1；内置磁盘上的卷不会卸载
+func Eject(path string) (string, error) {
+	out, err := exec.Command("diskutil", "info", "-plist", path).Output()
+	if err != nil {
+		return "", i18n.Errorf("%s 不在可以弹出的设备上", path)
+	}
+	point, device := plistString(out, "MountPoint"), plistString(out, "DeviceNode")
+	if point == "" || device == "" || plistBool(out, "Internal") {
+		return "", i18n.Errorf("%s 不在可以弹出的设备上", path)
+	}
+	syscall.Sync()
+	if err := diskutil("unmount", point); err != nil {
+		return "", i18n.Errorf("卸载 %s 失败: %w", point, err)
+	}
+	return device, nil
+}
+
+// PowerOff 通过 diskutil eject 弹出设备所在的整个磁盘，与访达中的推出相同，磁盘上的其他卷一并推出
+func PowerOff(device string) error {
+	return diskutil("eject", device)
+}
+
+// plistBool 从 diskutil 输出的 plist 中取出 key 对应的布尔值
+func plistBool(plist []byte, key string) bool {
+	_, rest, ok := bytes.Cut(plist, []byte("<key>"+key+"</key>"))
+	return ok && bytes.HasPrefix(bytes.TrimSpace(rest), []byte("<true/>"))
+}
+
+// diskutil 执行 diskutil 命令，失败时把命令输出附加到错误信息中
+func diskutil(args ...string) error {
+	out, err := exec.Command("diskutil", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("diskutil: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
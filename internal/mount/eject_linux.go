@@ -0,0 +1,132 @@
+package mount
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// EjectSupported 当前平台是否支持备份完成后弹出设备
+const EjectSupported = true
+
+// Eject 同步并卸载 path 所在的文件系统，返回其设备，例如 /dev/sdb1；同一设备的其他挂载点一并卸载
+// 只弹出 USB 等可以热插拔的设备，避免源目录位于系统盘上时卸载系统的目录；磁盘的其他分区仍然挂载时不弹出
+func Eject(path string) (string, error) {
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	entries, err := mountInfo()
+	if err != nil {
+		return "", err
+	}
+	var best mountEntry
+	for _, e := range entries {
+		if within(realPath, e.point) && len(e.point) >= len(best.point) {
+			best = e
+		}
+	}
+	device := devicePath(best.source)
+	disk, sys, err := diskOf(device)
+	if err != nil || best.point == "/" {
+		return "", i18n.Errorf("%s 不在可以弹出的设备上", path)
+	}
+	if !removable(disk) {
+		return "", i18n.Errorf("%s 所在的 %s 不是可以热插拔的设备", path, device)
+	}
+	for _, e := range entries {
+		name := filepath.Base(devicePath(e.source))
+		if e.dev == best.dev || !strings.HasPrefix(e.source, "/dev/") || name != disk && !exists(filepath.Join(sys, name)) {
+			continue
+		}
+		return "", i18n.Errorf("磁盘 %s 的 %s 仍然挂载在 %s", disk, e.source, e.point)
+	}
+	syscall.Sync()
+	// bind 挂载等情况下同一设备有多个挂载点，从最深的开始卸载
+	var points []string
+	for _, e := range entries {
+		if e.dev == best.dev {
+			points = append(points, e.point)
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return len(points[i]) > len(points[j]) })
+	for _, p := range points {
+		if err := run("umount", p); err != nil {
+			return "", i18n.Errorf("卸载 %s 失败: %w", p, err)
+		}
+	}
+	return device, nil
+}
+
+// PowerOff 断开 Eject 卸载的设备所在磁盘的电源，与 udisksctl power-off 相同：先从内核中删除磁盘，再移除所在的 USB 设备
+func PowerOff(device string) error {
+	disk, sys, err := diskOf(device)
+	if err != nil {
+		return err
+	}
+	usb := usbDevice(sys)
+	if usb == "" {
+		return i18n.Errorf("%s 不是 USB 设备，无法断开电源", disk)
+	}
+	if err := os.WriteFile(filepath.Join(sys, "device", "delete"), []byte("1"), 0); err != nil {
+		return i18n.Errorf("从系统中删除磁盘 %s 失败: %w", disk, err)
+	}
+	if err := os.WriteFile(filepath.Join(usb, "remove"), []byte("1"), 0); err != nil {
+		return i18n.Errorf("断开 USB 设备 %s 的电源失败: %w", filepath.Base(usb), err)
+	}
+	return nil
+}
+
+// devicePath 解析 /dev/mapper/<名称>、/dev/disk/by-uuid/<UUID> 等符号链接，得到内核中的设备名
+func devicePath(source string) string {
+	if p, err := filepath.EvalSymlinks(source); err == nil {
+		return p
+	}
+	return source
+}
+
+// diskOf 返回分区所在的磁盘名（例如 sdb1 所在的 sdb）及其在 /sys 中的目录，设备本身是整个磁盘时返回其自身
+func diskOf(device string) (string, string, error) {
+	if !strings.HasPrefix(device, "/dev/") {
+		return "", "", i18n.Errorf("不是块设备: %s", device)
+	}
+	sys, err := filepath.EvalSymlinks(filepath.Join("/sys/class/block", filepath.Base(device)))
+	if err != nil {
+		return "", "", i18n.Errorf("不是块设备: %s", device)
+	}
+	if exists(filepath.Join(sys, "partition")) {
+		sys = filepath.Dir(sys)
+	}
+	return filepath.Base(sys), sys, nil
+}
+
+// removable 磁盘标记为可移动，或者通过 USB 连接（移动硬盘通常不标记为可移动）
+func removable(disk string) bool {
+	sys, err := filepath.EvalSymlinks(filepath.Join("/sys/block", disk))
+	if err != nil {
+		return false
+	}
+	if data, err := os.ReadFile(filepath.Join(sys, "removable")); err == nil && strings.TrimSpace(string(data)) == "1" {
+		return true
+	}
+	return usbDevice(sys) != ""
+}
+
+// usbDevice 从磁盘在 /sys 中的目录向上查找所在的 USB 设备，即带有 idVendor 和 remove 属性的目录
+func usbDevice(sys string) string {
+	for dir := sys; dir != "/sys/devices" && dir != "/" && dir != "."; dir = filepath.Dir(dir) {
+		if exists(filepath.Join(dir, "idVendor")) && exists(filepath.Join(dir, "remove")) {
+			return dir
+		}
+	}
+	return ""
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
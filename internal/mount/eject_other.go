@@ -0,0 +1,16 @@
+//go:build !linux && !darwin
+
+package mount
+
+// EjectSupported 当前平台是否支持备份完成后弹出设备
+const EjectSupported = false
+
+// Eject 当前平台不支持弹出设备
+func Eject(path string) (string, error) {
+	return "", ErrEjectUnsupported
+}
+
+// PowerOff 当前平台不支持弹出设备
+func PowerOff(device string) error {
+	return ErrEjectUnsupported
+}
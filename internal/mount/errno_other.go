@@ -0,0 +1,7 @@
+//go:build !windows
+
+package mount
+
+import "syscall"
+
+func unavailableErrno(errno syscall.Errno) bool { return false }
@@ -0,0 +1,24 @@
+package mount
+
+import "syscall"
+
+// Windows 的错误码，syscall 中没有定义
+const (
+	errorNotReady           = 21   // ERROR_NOT_READY，盘符存在但没有介质，例如读卡器中没有存储卡
+	errorBadNetpath         = 53   // ERROR_BAD_NETPATH
+	errorUnexpNetErr        = 59   // ERROR_UNEXP_NET_ERR
+	errorNetnameDeleted     = 64   // ERROR_NETNAME_DELETED，共享在使用中断开
+	errorSemTimeout         = 121  // ERROR_SEM_TIMEOUT
+	errorDeviceNotConnected = 1167 // ERROR_DEVICE_NOT_CONNECTED，使用中拔出了 U 盘
+	errorNetworkUnreachable = 1231 // ERROR_NETWORK_UNREACHABLE
+)
+
+// unavailableErrno 补充 Windows 上表示设备或网络共享暂时不可用的错误码
+func unavailableErrno(errno syscall.Errno) bool {
+	switch errno {
+	case errorNotReady, errorBadNetpath, errorUnexpNetErr, errorNetnameDeleted, errorSemTimeout,
+		errorDeviceNotConnected, errorNetworkUnreachable:
+		return true
+	}
+	return false
+}
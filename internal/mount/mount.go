@@ -0,0 +1,120 @@
+package mount
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// ErrTimeout 探测网络共享超时，通常是服务器无响应
+var ErrTimeout = i18n.New("探测超时")
+
+// ErrNotMounted 目录存在但不在网络文件系统上，说明共享未挂载，看到的只是空的挂载点
+var ErrNotMounted = i18n.New("共享未挂载")
+
+// 网络文件系统类型
+var networkFSTypes = []string{"cifs", "smb3", "smbfs", "nfs", "nfs4", "afs", "9p", "ceph", "glusterfs", "davfs", "fuse.sshfs", "fuse.rclone", "fuse.davfs2"}
+
+// IsNetworkFS 判断文件系统类型是否为网络文件系统
+func IsNetworkFS(fsType string) bool {
+	for _, t := range networkFSTypes {
+		if fsType == t {
+			return true
+		}
+	}
+	return strings.HasPrefix(fsType, "fuse.")
+}
+
+// ErrVolumeMissing 按 UUID 或卷标查找的卷没有插入或还没有挂载
+var ErrVolumeMissing = i18n.New("卷没有插入或没有挂载")
+
+// ErrAutomountUnsupported 只有 Linux 支持由程序挂载卷
+var ErrAutomountUnsupported = i18n.New("当前系统不支持自动挂载卷")
+
+// ErrEjectUnsupported 当前平台不支持备份完成后弹出设备
+var ErrEjectUnsupported = i18n.New("当前系统不支持弹出设备")
+
+// IsUnavailable 判断错误是否表示网络共享或设备暂时不可用（而不是目录不存在或没有权限）
+// 包括 Windows 上拔出 U 盘或读卡器中没有存储卡时盘符返回的“设备未就绪”
+func IsUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrTimeout) || errors.Is(err, ErrNotMounted) {
+		return true
+	}
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	switch errno {
+	case syscall.ENOTCONN, syscall.EHOSTDOWN, syscall.EHOSTUNREACH, syscall.ENETDOWN, syscall.ENETUNREACH,
+		syscall.ESTALE, syscall.EIO, syscall.ETIMEDOUT, syscall.ECONNREFUSED, syscall.ECONNRESET, syscall.ECONNABORTED:
+		return true
+	}
+	return unavailableErrno(errno)
+}
+
+// ProbeResult 网络共享的探测结果
+type ProbeResult struct {
+	Exists bool // 目录是否存在
+	Empty  bool // 目录是否为空
+}
+
+// ProbeShare 在超时时间内检查网络共享目录：是否存在、是否真的挂载、是否为空
+// 挂载卡死时 stat 可能永久阻塞，探测在独立的 goroutine 中进行，超时后返回 ErrTimeout
+func ProbeShare(path string, timeout time.Duration) (ProbeResult, error) {
+	type result struct {
+		res ProbeResult
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		res, err := probe(path)
+		done <- result{res, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.res, r.err
+	case <-time.After(timeout):
+		return ProbeResult{}, fmt.Errorf("%s: %w", path, ErrTimeout)
+	}
+}
+
+func probe(path string) (ProbeResult, error) {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return ProbeResult{}, nil
+		}
+		return ProbeResult{}, err
+	}
+
+	fsType, err := FSType(path)
+	if err != nil {
+		return ProbeResult{}, err
+	}
+	// 无法获取文件系统类型的平台上跳过挂载检查
+	if fsType != "" && !IsNetworkFS(fsType) {
+		return ProbeResult{Exists: true}, i18n.Errorf("%s 位于 %s 文件系统: %w", path, fsType, ErrNotMounted)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return ProbeResult{}, err
+	}
+	defer f.Close()
+	if _, err := f.ReadDir(1); err != nil {
+		if err == io.EOF {
+			return ProbeResult{Exists: true, Empty: true}, nil
+		}
+		return ProbeResult{}, err
+	}
+	return ProbeResult{Exists: true}, nil
+}
@@ -0,0 +1,161 @@
+package mount
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// FSType 从 /proc/self/mountinfo 中找到包含 path 的最深挂载点，返回其文件系统类型
+func FSType(path string) (string, error) {
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	entries, err := mountInfo()
+	if err != nil {
+		return "", err
+	}
+	best, fsType := "", ""
+	for _, e := range entries {
+		if !within(realPath, e.point) || len(e.point) < len(best) {
+			continue
+		}
+		best, fsType = e.point, e.fsType
+	}
+	return fsType, nil
+}
+
+// mountEntry /proc/self/mountinfo 中的一行
+type mountEntry struct {
+	dev    string // 设备号 major:minor
+	root   string // 挂载的是文件系统中的哪个目录，bind 挂载时不是 /
+	point  string // 挂载点
+	fsType string
+	source string // 设备，例如 /dev/sdb1
+}
+
+// mountInfo 读取当前的挂载表
+func mountInfo() ([]mountEntry, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, i18n.Errorf("读取挂载信息失败: %w", err)
+	}
+	defer f.Close()
+
+	var entries []mountEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 格式: id parent major:minor root mountpoint options [optional...] - fstype source superoptions
+		fields := strings.Fields(scanner.Text())
+		sep := -1
+		for i, field := range fields {
+			if field == "-" {
+				sep = i
+				break
+			}
+		}
+		if len(fields) < 5 || sep < 0 || sep+1 >= len(fields) {
+			continue
+		}
+		e := mountEntry{dev: fields[2], root: unescape(fields[3]), point: unescape(fields[4]), fsType: fields[sep+1]}
+		if sep+2 < len(fields) {
+			e.source = unescape(fields[sep+2])
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, i18n.Errorf("读取挂载信息失败: %w", err)
+	}
+	return entries, nil
+}
+
+// VolumeDir 返回 UUID 或卷标为指定值的文件系统当前的挂载点，没有插入或没有挂载时返回 ErrVolumeMissing
+// 通过 udev 创建的 /dev/disk/by-uuid 和 /dev/disk/by-label 找到设备，再按设备号在挂载表中查找，不需要访问设备文件
+func VolumeDir(uuid, label string) (string, error) {
+	name, err := volumeDevice(uuid, label)
+	if err != nil {
+		return "", err
+	}
+	dev, err := os.ReadFile(filepath.Join("/sys/class/block", name, "dev"))
+	if err != nil {
+		return "", ErrVolumeMissing
+	}
+	entries, err := mountInfo()
+	if err != nil {
+		return "", err
+	}
+	// btrfs 等文件系统在挂载表中的设备号不是块设备的设备号，同时按设备名比较
+	devNum := strings.TrimSpace(string(dev))
+	for _, e := range entries {
+		if e.root == "/" && (e.dev == devNum || e.source == "/dev/"+name) {
+			return e.point, nil
+		}
+	}
+	return "", ErrVolumeMissing
+}
+
+// volumeDevice 在 /dev/disk 中查找卷对应的块设备名，例如 sdb1；UUID 不区分大小写
+func volumeDevice(uuid, label string) (string, error) {
+	dir, name := "/dev/disk/by-uuid", uuid
+	if uuid == "" {
+		dir, name = "/dev/disk/by-label", encodeLabel(label)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		// 还没有任何带 UUID 或卷标的设备时目录不存在
+		return "", ErrVolumeMissing
+	}
+	for _, e := range entries {
+		if e.Name() == name || uuid != "" && strings.EqualFold(e.Name(), name) {
+			target, err := os.Readlink(filepath.Join(dir, e.Name()))
+			if err != nil {
+				return "", ErrVolumeMissing
+			}
+			return filepath.Base(target), nil
+		}
+	}
+	return "", ErrVolumeMissing
+}
+
+// encodeLabel 按 udev 的规则转义卷标，空格、斜杠等字符写作 \x20 的形式，非 ASCII 字符保持不变
+func encodeLabel(label string) string {
+	var b strings.Builder
+	for i := 0; i < len(label); i++ {
+		c := label[i]
+		if c >= 0x80 || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || strings.IndexByte("#+-.:=@_", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, `\x%02x`, c)
+	}
+	return b.String()
+}
+
+func within(path, mountPoint string) bool {
+	return mountPoint == "/" || path == mountPoint || strings.HasPrefix(path, mountPoint+"/")
+}
+
+// unescape mountinfo 中的空格等字符以 \040 形式转义
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			var c byte
+			if _, err := fmt.Sscanf(s[i+1:i+4], "%o", &c); err == nil {
+				b.WriteByte(c)
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package mount
+
+// FSType 非 Linux 平台暂不识别文件系统类型，返回空字符串
+func FSType(path string) (string, error) {
+	return "", nil
+}
@@ -0,0 +1,54 @@
+package mount
+
+import (
+	"bytes"
+	"html"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// VolumeDir 返回 UUID 或卷标为指定值的卷当前的挂载点，没有插入或没有挂载时返回 ErrVolumeMissing
+// 卷标直接对应 /Volumes 下的目录，UUID 通过 diskutil 查询
+func VolumeDir(uuid, label string) (string, error) {
+	if uuid == "" {
+		dir := filepath.Join("/Volumes", label)
+		if !isMountPoint(dir) {
+			return "", ErrVolumeMissing
+		}
+		return dir, nil
+	}
+	out, err := exec.Command("diskutil", "info", "-plist", uuid).Output()
+	if err != nil {
+		// 找不到该 UUID 的卷时 diskutil 以非零状态退出
+		return "", ErrVolumeMissing
+	}
+	dir := plistString(out, "MountPoint")
+	if dir == "" {
+		return "", ErrVolumeMissing
+	}
+	return dir, nil
+}
+
+// isMountPoint 目录与上级目录不在同一个设备上，卷拔出后残留的空目录不算
+func isMountPoint(dir string) bool {
+	var st, parent syscall.Stat_t
+	if syscall.Stat(dir, &st) != nil || syscall.Stat(filepath.Dir(dir), &parent) != nil {
+		return false
+	}
+	return st.Dev != parent.Dev
+}
+
+// plistString 从 diskutil 输出的 plist 中取出 key 对应的字符串
+func plistString(plist []byte, key string) string {
+	_, rest, ok := bytes.Cut(plist, []byte("<key>"+key+"</key>"))
+	if !ok {
+		return ""
+	}
+	_, rest, ok = bytes.Cut(rest, []byte("<string>"))
+	if !ok {
+		return ""
+	}
+	value, _, _ := bytes.Cut(rest, []byte("</string>"))
+	return html.UnescapeString(string(bytes.TrimSpace(value)))
+}
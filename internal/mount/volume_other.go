@@ -0,0 +1,10 @@
+//go:build !linux && !windows && !darwin
+
+package mount
+
+import "github.com/lucasrui/neo-nas/internal/i18n"
+
+// VolumeDir 当前平台不支持按 UUID 或卷标查找卷
+func VolumeDir(uuid, label string) (string, error) {
+	return "", i18n.Errorf("当前平台不支持按 UUID 或卷标指定源目录")
+}
@@ -0,0 +1,54 @@
+package mount
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procGetLogicalDrives      = syscall.NewLazyDLL("kernel32.dll").NewProc("GetLogicalDrives")
+	procGetVolumeInformationW = syscall.NewLazyDLL("kernel32.dll").NewProc("GetVolumeInformationW")
+)
+
+// VolumeDir 返回序列号或卷标为指定值的卷当前的盘符根目录，例如 E:\，没有插入时返回 ErrVolumeMissing
+// UUID 即 Linux 上 blkid 显示的卷序列号：FAT 和 exFAT 为 ABCD-1234，NTFS 为 16 位十六进制数，Windows 只能读到其中的低 8 位
+func VolumeDir(uuid, label string) (string, error) {
+	drives, _, _ := procGetLogicalDrives.Call()
+	want := strings.ToUpper(strings.ReplaceAll(uuid, "-", ""))
+	for i := 0; i < 26; i++ {
+		if drives&(1<<i) == 0 {
+			continue
+		}
+		// A: 和 B: 通常是软驱，跳过以免等待
+		if i < 2 {
+			continue
+		}
+		root := string(rune('A'+i)) + `:\`
+		name, serial, ok := volumeInfo(root)
+		if !ok {
+			continue
+		}
+		if uuid != "" && len(want) >= 8 && strings.HasSuffix(want, fmt.Sprintf("%08X", serial)) || uuid == "" && strings.EqualFold(name, label) {
+			return root, nil
+		}
+	}
+	return "", ErrVolumeMissing
+}
+
+// volumeInfo 读取卷标和卷序列号，驱动器中没有介质时返回 false
+func volumeInfo(root string) (string, uint32, bool) {
+	p, err := syscall.UTF16PtrFromString(root)
+	if err != nil {
+		return "", 0, false
+	}
+	var name [261]uint16
+	var serial uint32
+	r, _, _ := procGetVolumeInformationW.Call(uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&name[0])), uintptr(len(name)),
+		uintptr(unsafe.Pointer(&serial)), 0, 0, 0, 0)
+	if r == 0 {
+		return "", 0, false
+	}
+	return syscall.UTF16ToString(name[:]), serial, true
+}
@@ -0,0 +1,98 @@
+package parity
+
+// GF(2^8) 上的运算，生成多项式 x^8 + x^4 + x^3 + x^2 + 1，与常见的 Reed-Solomon 实现相同
+var (
+	expTable [512]byte
+	logTable [256]byte
+	mulTable [256][256]byte // mulTable[c][v] = c * v，按行查表比每个字节查两次对数表快
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		expTable[i] = byte(x)
+		logTable[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for i := 255; i < len(expTable); i++ {
+		expTable[i] = expTable[i-255]
+	}
+	for c := 1; c < 256; c++ {
+		for v := 1; v < 256; v++ {
+			mulTable[c][v] = expTable[int(logTable[c])+int(logTable[v])]
+		}
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+func gfInv(a byte) byte {
+	return expTable[255-int(logTable[a])]
+}
+
+// mulAdd dst ^= c * src
+func mulAdd(dst, src []byte, c byte) {
+	if c == 0 {
+		return
+	}
+	t := &mulTable[c]
+	dst = dst[:len(src)]
+	for i, v := range src {
+		dst[i] ^= t[v]
+	}
+}
+
+// cauchy 校验块 j 中数据块 i 的系数，任意方阵子矩阵都可逆，丢失的块不超过校验块数量时都能恢复
+func cauchy(j, i, dataBlocks int) byte {
+	return gfInv(byte(dataBlocks+j) ^ byte(i))
+}
+
+// invert 求方阵的逆矩阵，矩阵不可逆时返回 false
+func invert(m [][]byte) ([][]byte, bool) {
+	n := len(m)
+	a := make([][]byte, n)
+	inv := make([][]byte, n)
+	for i := range m {
+		a[i] = append([]byte(nil), m[i]...)
+		inv[i] = make([]byte, n)
+		inv[i][i] = 1
+	}
+	for col := 0; col < n; col++ {
+		pivot := -1
+		for r := col; r < n; r++ {
+			if a[r][col] != 0 {
+				pivot = r
+				break
+			}
+		}
+		if pivot < 0 {
+			return nil, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		inv[col], inv[pivot] = inv[pivot], inv[col]
+		c := gfInv(a[col][col])
+		for k := 0; k < n; k++ {
+			a[col][k] = gfMul(a[col][k], c)
+			inv[col][k] = gfMul(inv[col][k], c)
+		}
+		for r := 0; r < n; r++ {
+			if r == col || a[r][col] == 0 {
+				continue
+			}
+			f := a[r][col]
+			for k := 0; k < n; k++ {
+				a[r][k] ^= gfMul(f, a[col][k])
+				inv[r][k] ^= gfMul(f, inv[col][k])
+			}
+		}
+	}
+	return inv, true
+}
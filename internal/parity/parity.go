@@ -0,0 +1,458 @@
+package parity
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/immutable"
+)
+
+// Prefix 恢复数据文件名前缀，与其他内部文件一样以 .neo- 开头，扫描目标目录时跳过
+const Prefix = ".neo-parity-"
+
+const (
+	magic     = "NEOPAR1\n"
+	maxBlocks = 256      // GF(2^8) 中数据块和校验块的总数上限
+	minBlock  = 512      // 最小块大小，与磁盘扇区对齐
+	window    = 64 << 10 // 每次读取每个块中的一段，内存占用与文件大小无关
+)
+
+// ErrNoSidecar 文件没有恢复数据
+var ErrNoSidecar = i18n.New("没有恢复数据")
+
+// header 恢复数据文件末尾的说明，校验块紧跟在文件头之后
+type header struct {
+	Size         int64    `json:"size"`
+	BlockSize    int64    `json:"block_size"`
+	DataBlocks   int      `json:"data_blocks"`
+	ParityBlocks int      `json:"parity_blocks"`
+	DataSHA256   []string `json:"data_sha256"`
+	ParitySHA256 []string `json:"parity_sha256"`
+}
+
+// Report 检查或修复的结果
+type Report struct {
+	Blocks       int   // 数据块数量
+	BadBlocks    []int // 损坏的数据块序号
+	BadParity    int   // 损坏的校验块数量
+	SizeChanged  bool  // 文件被截短或加长
+	Recoverable  bool  // 损坏的数据块不超过完好的校验块，可以修复
+	Repaired     bool  // 已修复
+	ParityBlocks int   // 校验块数量
+}
+
+// OK 文件和恢复数据都完好
+func (r Report) OK() bool {
+	return len(r.BadBlocks) == 0 && r.BadParity == 0 && !r.SizeChanged
+}
+
+// Sidecar 返回文件对应的恢复数据路径
+func Sidecar(path string) string {
+	return filepath.Join(filepath.Dir(path), Prefix+filepath.Base(path))
+}
+
+// Source 返回恢复数据对应的文件路径，不是恢复数据时返回空字符串
+func Source(sidecar string) string {
+	name, ok := strings.CutPrefix(filepath.Base(sidecar), Prefix)
+	if !ok || name == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(sidecar), name)
+}
+
+// layout 按冗余比例选择块大小，数据块和校验块的总数不超过 256
+func layout(size int64, percent int) (blockSize int64, dataBlocks, parityBlocks int) {
+	n := int(min((size+minBlock-1)/minBlock, int64(maxBlocks)))
+	for {
+		m := max((n*percent+99)/100, 1)
+		if n+m <= maxBlocks {
+			blockSize = (size + int64(n) - 1) / int64(n)
+			blockSize = (blockSize + minBlock - 1) / minBlock * minBlock
+			n = int((size + blockSize - 1) / blockSize)
+			return blockSize, n, max((n*percent+99)/100, 1)
+		}
+		n--
+	}
+}
+
+// Create 为文件生成恢复数据，冗余比例为 percent%，最多可以恢复同样比例的损坏数据块
+// 先写入临时文件再改名，文件已有的恢复数据在完成后才被替换
+func Create(path string, percent int) error {
+	if percent < 1 || percent > 100 {
+		return i18n.Errorf("冗余比例应为 1 到 100: %d", percent)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return i18n.Errorf("打开文件失败: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return i18n.Errorf("获取文件信息失败: %w", err)
+	}
+	if info.Size() == 0 {
+		return nil
+	}
+
+	h := header{Size: info.Size()}
+	h.BlockSize, h.DataBlocks, h.ParityBlocks = layout(info.Size(), percent)
+
+	sidecar := Sidecar(path)
+	tmp := sidecar + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return i18n.Errorf("创建恢复数据失败: %w", err)
+	}
+	defer os.Remove(tmp)
+	defer out.Close()
+	if _, err := out.WriteString(magic); err != nil {
+		return i18n.Errorf("写入恢复数据失败: %w", err)
+	}
+
+	dataHash := newHashes(h.DataBlocks)
+	parityHash := newHashes(h.ParityBlocks)
+	buf := make([]byte, window)
+	parity := make([][]byte, h.ParityBlocks)
+	for j := range parity {
+		parity[j] = make([]byte, window)
+	}
+	for off := int64(0); off < h.BlockSize; off += window {
+		w := min(window, h.BlockSize-off)
+		for j := range parity {
+			clear(parity[j][:w])
+		}
+		for i := 0; i < h.DataBlocks; i++ {
+			if err := readBlock(f, int64(i)*h.BlockSize+off, buf[:w]); err != nil {
+				return err
+			}
+			dataHash[i].Write(buf[:w])
+			for j := range parity {
+				mulAdd(parity[j][:w], buf[:w], cauchy(j, i, h.DataBlocks))
+			}
+		}
+		for j := range parity {
+			parityHash[j].Write(parity[j][:w])
+			if _, err := out.WriteAt(parity[j][:w], int64(len(magic))+int64(j)*h.BlockSize+off); err != nil {
+				return i18n.Errorf("写入恢复数据失败: %w", err)
+			}
+		}
+	}
+	h.DataSHA256, h.ParitySHA256 = sums(dataHash), sums(parityHash)
+
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	end := int64(len(magic)) + int64(h.ParityBlocks)*h.BlockSize
+	trailer := binary.BigEndian.AppendUint64(data, uint64(len(data)))
+	if _, err := out.WriteAt(trailer, end); err != nil {
+		return i18n.Errorf("写入恢复数据失败: %w", err)
+	}
+	if err := out.Sync(); err != nil {
+		return i18n.Errorf("写入恢复数据失败: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return i18n.Errorf("写入恢复数据失败: %w", err)
+	}
+	if err := os.Rename(tmp, sidecar); err != nil {
+		return i18n.Errorf("写入恢复数据失败: %w", err)
+	}
+	return nil
+}
+
+// Check 按恢复数据中记录的哈希检查文件和校验块，不修改任何文件
+func Check(path string) (Report, error) {
+	r, _, err := check(path)
+	return r, err
+}
+
+// Repair 检查文件，有损坏的数据块时用校验块恢复后写回原文件，并重新检查
+// 文件设置了不可变属性时先清除，修复完成后重新设置；校验块损坏时按原来的冗余比例重新生成恢复数据
+func Repair(path string) (Report, error) {
+	r, h, err := check(path)
+	if err != nil {
+		return r, err
+	}
+	if len(r.BadBlocks) == 0 && !r.SizeChanged {
+		if r.BadParity > 0 {
+			if err := rebuild(path, r); err != nil {
+				return r, err
+			}
+			r.Repaired = true
+		}
+		return r, nil
+	}
+	if !r.Recoverable {
+		return r, i18n.Errorf("损坏的数据块过多，%d 块损坏，只有 %d 块完好的校验数据", len(r.BadBlocks), r.ParityBlocks-r.BadParity)
+	}
+
+	locked, _ := immutable.IsSet(path)
+	if locked {
+		if err := immutable.Clear(path); err != nil {
+			return r, i18n.Errorf("清除不可变属性失败: %w", err)
+		}
+		defer immutable.Set(path)
+	}
+	if err := restore(path, h, r.BadBlocks); err != nil {
+		return r, err
+	}
+
+	after, _, err := check(path)
+	if err != nil {
+		return r, err
+	}
+	if len(after.BadBlocks) > 0 || after.SizeChanged {
+		return r, i18n.Errorf("修复后仍有 %d 块数据与恢复数据不一致", len(after.BadBlocks))
+	}
+	if r.BadParity > 0 {
+		if err := rebuild(path, r); err != nil {
+			return r, err
+		}
+	}
+	r.Repaired = true
+	return r, nil
+}
+
+// rebuild 文件完好但校验块损坏时重新生成恢复数据
+func rebuild(path string, r Report) error {
+	return Create(path, min(max(r.ParityBlocks*100/r.Blocks, 1), 100))
+}
+
+func check(path string) (Report, *sidecarFile, error) {
+	s, err := openSidecar(Sidecar(path))
+	if err != nil {
+		return Report{}, nil, err
+	}
+	defer s.f.Close()
+	h := s.header
+	r := Report{Blocks: h.DataBlocks, ParityBlocks: h.ParityBlocks}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return r, nil, i18n.Errorf("打开文件失败: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return r, nil, i18n.Errorf("获取文件信息失败: %w", err)
+	}
+
+	dataHash := newHashes(h.DataBlocks)
+	parityHash := newHashes(h.ParityBlocks)
+	buf := make([]byte, window)
+	for off := int64(0); off < h.BlockSize; off += window {
+		w := min(window, h.BlockSize-off)
+		for i := 0; i < h.DataBlocks; i++ {
+			if err := readBlock(f, int64(i)*h.BlockSize+off, buf[:w]); err != nil {
+				return r, nil, err
+			}
+			dataHash[i].Write(buf[:w])
+		}
+		for j := 0; j < h.ParityBlocks; j++ {
+			if err := readBlock(s.f, s.parityOffset(j)+off, buf[:w]); err != nil {
+				return r, nil, err
+			}
+			parityHash[j].Write(buf[:w])
+		}
+	}
+	for i, sum := range sums(dataHash) {
+		if sum != h.DataSHA256[i] {
+			r.BadBlocks = append(r.BadBlocks, i)
+		}
+	}
+	r.SizeChanged = info.Size() != h.Size
+	for j, sum := range sums(parityHash) {
+		if sum != h.ParitySHA256[j] {
+			r.BadParity++
+			s.bad = append(s.bad, j)
+		}
+	}
+	r.Recoverable = len(r.BadBlocks) <= h.ParityBlocks-r.BadParity
+	return r, s, nil
+}
+
+// restore 用完好的校验块解出损坏的数据块：每个校验块减去完好数据块的贡献后，
+// 剩下的是损坏数据块的线性组合，对应的柯西子矩阵一定可逆
+func restore(path string, s *sidecarFile, bad []int) error {
+	sf, err := os.Open(s.path)
+	if err != nil {
+		return i18n.Errorf("打开恢复数据失败: %w", err)
+	}
+	defer sf.Close()
+	h := s.header
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return i18n.Errorf("打开文件失败: %w", err)
+	}
+	defer f.Close()
+	if err := f.Truncate(h.Size); err != nil {
+		return i18n.Errorf("恢复文件大小失败: %w", err)
+	}
+
+	isBad := make(map[int]bool)
+	for _, i := range bad {
+		isBad[i] = true
+	}
+	skip := make(map[int]bool)
+	for _, j := range s.bad {
+		skip[j] = true
+	}
+	var rows []int
+	for j := 0; j < h.ParityBlocks && len(rows) < len(bad); j++ {
+		if !skip[j] {
+			rows = append(rows, j)
+		}
+	}
+	m := make([][]byte, len(rows))
+	for r, j := range rows {
+		m[r] = make([]byte, len(bad))
+		for k, i := range bad {
+			m[r][k] = cauchy(j, i, h.DataBlocks)
+		}
+	}
+	inv, ok := invert(m)
+	if !ok {
+		return i18n.Errorf("恢复数据无效")
+	}
+
+	buf := make([]byte, window)
+	syndrome := make([][]byte, len(rows))
+	for r := range syndrome {
+		syndrome[r] = make([]byte, window)
+	}
+	out := make([]byte, window)
+	for off := int64(0); off < h.BlockSize; off += window {
+		w := min(window, h.BlockSize-off)
+		for r, j := range rows {
+			if err := readBlock(sf, s.parityOffset(j)+off, syndrome[r][:w]); err != nil {
+				return err
+			}
+		}
+		for i := 0; i < h.DataBlocks; i++ {
+			if isBad[i] {
+				continue
+			}
+			if err := readBlock(f, int64(i)*h.BlockSize+off, buf[:w]); err != nil {
+				return err
+			}
+			for r, j := range rows {
+				mulAdd(syndrome[r][:w], buf[:w], cauchy(j, i, h.DataBlocks))
+			}
+		}
+		for k, i := range bad {
+			clear(out[:w])
+			for r := range rows {
+				mulAdd(out[:w], syndrome[r][:w], inv[k][r])
+			}
+			// 最后一块超出文件大小的部分是补齐的零，不写入
+			pos := int64(i)*h.BlockSize + off
+			n := min(w, h.Size-pos)
+			if n <= 0 {
+				continue
+			}
+			if _, err := f.WriteAt(out[:n], pos); err != nil {
+				return i18n.Errorf("写入修复的数据失败: %w", err)
+			}
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return i18n.Errorf("写入修复的数据失败: %w", err)
+	}
+	return nil
+}
+
+// sidecarFile 打开的恢复数据
+type sidecarFile struct {
+	path   string
+	f      *os.File
+	header header
+	bad    []int // 损坏的校验块
+}
+
+func (s *sidecarFile) parityOffset(j int) int64 {
+	return int64(len(magic)) + int64(j)*s.header.BlockSize
+}
+
+func openSidecar(path string) (*sidecarFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoSidecar
+		}
+		return nil, i18n.Errorf("打开恢复数据失败: %w", err)
+	}
+	s := &sidecarFile{path: path, f: f}
+	if err := s.readHeader(); err != nil {
+		f.Close()
+		return nil, i18n.Errorf("恢复数据已损坏 %s: %w", path, err)
+	}
+	return s, nil
+}
+
+func (s *sidecarFile) readHeader() error {
+	info, err := s.f.Stat()
+	if err != nil {
+		return err
+	}
+	head := make([]byte, len(magic))
+	if _, err := s.f.ReadAt(head, 0); err != nil || string(head) != magic {
+		return i18n.New("文件头无效")
+	}
+	var n [8]byte
+	if _, err := s.f.ReadAt(n[:], info.Size()-8); err != nil {
+		return err
+	}
+	size := int64(binary.BigEndian.Uint64(n[:]))
+	if size <= 0 || size > info.Size()-8-int64(len(magic)) {
+		return i18n.New("说明信息长度无效")
+	}
+	data := make([]byte, size)
+	if _, err := s.f.ReadAt(data, info.Size()-8-size); err != nil {
+		return err
+	}
+	h := &s.header
+	if err := json.Unmarshal(data, h); err != nil {
+		return err
+	}
+	if h.BlockSize <= 0 || h.DataBlocks <= 0 || h.ParityBlocks <= 0 || h.DataBlocks+h.ParityBlocks > maxBlocks ||
+		len(h.DataSHA256) != h.DataBlocks || len(h.ParitySHA256) != h.ParityBlocks ||
+		s.parityOffset(h.ParityBlocks) != info.Size()-8-size {
+		return i18n.New("说明信息无效")
+	}
+	return nil
+}
+
+// readBlock 读取 f 中 off 处的 len(buf) 字节，超出文件末尾的部分补零
+func readBlock(f *os.File, off int64, buf []byte) error {
+	n, err := f.ReadAt(buf, off)
+	if err != nil && err != io.EOF {
+		return i18n.Errorf("读取文件失败: %w", err)
+	}
+	clear(buf[n:])
+	return nil
+}
+
+func newHashes(n int) []hash.Hash {
+	hashes := make([]hash.Hash, n)
+	for i := range hashes {
+		hashes[i] = sha256.New()
+	}
+	return hashes
+}
+
+func sums(hashes []hash.Hash) []string {
+	result := make([]string, len(hashes))
+	for i, h := range hashes {
+		result[i] = hex.EncodeToString(h.Sum(nil))
+	}
+	return result
+}
@@ -0,0 +1,224 @@
+package parity
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeProtected 写入随机内容并生成恢复数据，返回文件路径、内容和恢复数据的说明
+func writeProtected(t *testing.T, size, percent int) (string, []byte, header) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "file.bin")
+	data := make([]byte, size)
+	rand.Read(data)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Create(path, percent); err != nil {
+		t.Fatal(err)
+	}
+	s, err := openSidecar(Sidecar(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.f.Close()
+	return path, data, s.header
+}
+
+// modify 读取文件，用 fn 修改内容后写回
+func modify(t *testing.T, path string, fn func([]byte) []byte) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, fn(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// repaired 修复文件，确认内容与原来一致且重新检查完好
+func repaired(t *testing.T, path string, want []byte) Report {
+	t.Helper()
+	r, err := Repair(path)
+	if err != nil {
+		t.Fatalf("repair: %v (report %+v)", err, r)
+	}
+	if !r.Repaired {
+		t.Fatalf("report %+v, want repaired", r)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("repaired content differs (%d bytes, want %d)", len(got), len(want))
+	}
+	after, err := Check(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !after.OK() {
+		t.Fatalf("check after repair: %+v", after)
+	}
+	return r
+}
+
+func TestLayout(t *testing.T) {
+	for _, size := range []int64{1, 511, 512, 513, 100 << 10, 1<<20 + 123, 10 << 30} {
+		for _, percent := range []int{1, 5, 10, 50, 100} {
+			blockSize, n, m := layout(size, percent)
+			if n+m > maxBlocks || n < 1 || m < 1 {
+				t.Errorf("layout(%d, %d) = %d data + %d parity blocks", size, percent, n, m)
+			}
+			if blockSize%minBlock != 0 || blockSize*int64(n) < size || blockSize*int64(n-1) >= size {
+				t.Errorf("layout(%d, %d) = block size %d for %d blocks", size, percent, blockSize, n)
+			}
+			if m*100 < n*percent {
+				t.Errorf("layout(%d, %d) = %d parity blocks for %d data blocks", size, percent, m, n)
+			}
+		}
+	}
+}
+
+func TestCreateCheck(t *testing.T) {
+	path, _, h := writeProtected(t, 200<<10+123, 10)
+	r, err := Check(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.OK() || r.Blocks != h.DataBlocks || r.ParityBlocks != h.ParityBlocks {
+		t.Fatalf("check: %+v", r)
+	}
+	if got := Source(Sidecar(path)); got != path {
+		t.Fatalf("Source(Sidecar(%s)) = %s", path, got)
+	}
+	if _, err := Check(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, ErrNoSidecar) {
+		t.Fatalf("check without sidecar: %v", err)
+	}
+}
+
+func TestRepairCorrupted(t *testing.T) {
+	path, data, h := writeProtected(t, 200<<10+123, 10)
+	// 损坏的数据块数量与校验块相同，包括第一块和不满一块的最后一块
+	modify(t, path, func(b []byte) []byte {
+		for k := 0; k < h.ParityBlocks; k++ {
+			i := k * (h.DataBlocks - 1) / (h.ParityBlocks - 1)
+			b[int64(i)*h.BlockSize+int64(k)] ^= 0xff
+		}
+		return b
+	})
+	r := repaired(t, path, data)
+	if len(r.BadBlocks) != h.ParityBlocks {
+		t.Fatalf("bad blocks %v, want %d", r.BadBlocks, h.ParityBlocks)
+	}
+}
+
+func TestRepairTruncated(t *testing.T) {
+	path, data, h := writeProtected(t, 200<<10+123, 10)
+	cut := int(h.BlockSize) + 100
+	modify(t, path, func(b []byte) []byte { return b[:len(b)-cut] })
+	r := repaired(t, path, data)
+	if !r.SizeChanged {
+		t.Fatalf("report %+v, want size changed", r)
+	}
+
+	// 加长的文件截回原来的大小
+	modify(t, path, func(b []byte) []byte { return append(b, make([]byte, 3*int(h.BlockSize))...) })
+	repaired(t, path, data)
+}
+
+func TestRepairReordered(t *testing.T) {
+	path, data, h := writeProtected(t, 200<<10+123, 10)
+	modify(t, path, func(b []byte) []byte {
+		x, y := b[h.BlockSize:2*h.BlockSize], b[3*h.BlockSize:4*h.BlockSize]
+		tmp := append([]byte(nil), x...)
+		copy(x, y)
+		copy(y, tmp)
+		return b
+	})
+	r := repaired(t, path, data)
+	if len(r.BadBlocks) != 2 || r.BadBlocks[0] != 1 || r.BadBlocks[1] != 3 {
+		t.Fatalf("bad blocks %v, want [1 3]", r.BadBlocks)
+	}
+}
+
+func TestRepairBadParity(t *testing.T) {
+	path, data, h := writeProtected(t, 200<<10+123, 10)
+	sidecar := Sidecar(path)
+	modify(t, sidecar, func(b []byte) []byte {
+		b[len(magic)+int(h.BlockSize)+7] ^= 0xff
+		return b
+	})
+	// 一个校验块损坏时，其余的校验块仍然可以修复同样多的数据块
+	modify(t, path, func(b []byte) []byte {
+		for i := 0; i < h.ParityBlocks-1; i++ {
+			b[int64(i)*h.BlockSize] ^= 0xff
+		}
+		return b
+	})
+	r := repaired(t, path, data)
+	if r.BadParity != 1 || len(r.BadBlocks) != h.ParityBlocks-1 {
+		t.Fatalf("report %+v", r)
+	}
+}
+
+func TestRepairTooManyBad(t *testing.T) {
+	path, _, h := writeProtected(t, 200<<10+123, 10)
+	modify(t, path, func(b []byte) []byte {
+		for i := 0; i <= h.ParityBlocks; i++ {
+			b[int64(i)*h.BlockSize] ^= 0xff
+		}
+		return b
+	})
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := Repair(path)
+	if err == nil || r.Recoverable || r.Repaired {
+		t.Fatalf("repair: %v, report %+v", err, r)
+	}
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Fatal("unrecoverable file was modified")
+	}
+}
+
+func TestInvertCauchy(t *testing.T) {
+	const dataBlocks = 200
+	rows, cols := []int{0, 3, 7, 55}, []int{1, 50, 120, 199}
+	m := make([][]byte, len(rows))
+	for r, j := range rows {
+		m[r] = make([]byte, len(cols))
+		for k, i := range cols {
+			m[r][k] = cauchy(j, i, dataBlocks)
+		}
+	}
+	inv, ok := invert(m)
+	if !ok {
+		t.Fatal("cauchy submatrix not invertible")
+	}
+	for r := range m {
+		for c := range m {
+			var v byte
+			for k := range m {
+				v ^= gfMul(m[r][k], inv[k][c])
+			}
+			want := byte(0)
+			if r == c {
+				want = 1
+			}
+			if v != want {
+				t.Fatalf("m * inv [%d][%d] = %d", r, c, v)
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package priority
+
+import (
+	"sync"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+)
+
+// logger priority 模块的日志
+var logger = logging.New("priority")
+
+// IO 调度类别，与 ionice 一致
+const (
+	ClassBestEffort = "best-effort"
+	ClassIdle       = "idle" // 只在磁盘空闲时读写
+)
+
+var (
+	settings config.PriorityConfig
+	errOnce  sync.Once // 设置失败时只记录一次日志
+)
+
+// Configure 校验并保存优先级配置，需要在启动扫描和压缩任务之前调用
+func Configure(cfg config.PriorityConfig) error {
+	if cfg.Nice < 0 || cfg.Nice > 19 {
+		return i18n.Errorf("nice 应在 0 到 19 之间: %d", cfg.Nice)
+	}
+	switch cfg.IOClass {
+	case "", ClassBestEffort, ClassIdle:
+	default:
+		return i18n.Errorf("未知的 IO 调度类别: %s", cfg.IOClass)
+	}
+	if cfg.IOLevel < 0 || cfg.IOLevel > 7 {
+		return i18n.Errorf("io_level 应在 0 到 7 之间: %d", cfg.IOLevel)
+	}
+	settings = cfg
+	return nil
+}
+
+// Lower 降低当前协程所做工作的 CPU 和 IO 优先级，在扫描、复制和压缩等后台工作的协程开始时调用
+// Linux 上优先级按线程生效，调用后协程固定在当前线程上，协程退出时线程随之结束，不影响 HTTP 服务等其他工作
+func Lower() {
+	if settings.Nice == 0 && settings.IOClass == "" {
+		return
+	}
+	if err := lower(settings); err != nil {
+		errOnce.Do(func() { logger.Errorf("降低优先级失败: %v", err) })
+	}
+}
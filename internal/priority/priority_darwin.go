@@ -0,0 +1,24 @@
+package priority
+
+import (
+	"sync"
+	"syscall"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+var processOnce sync.Once
+
+// lower macOS 上 nice 对整个进程生效，只设置一次；IO 优先级由 launchd 的 LowPriorityIO 控制
+func lower(cfg config.PriorityConfig) error {
+	var err error
+	processOnce.Do(func() {
+		if cfg.Nice > 0 {
+			if e := syscall.Setpriority(syscall.PRIO_PROCESS, 0, cfg.Nice); e != nil {
+				err = i18n.Errorf("设置 nice: %w", e)
+			}
+		}
+	})
+	return err
+}
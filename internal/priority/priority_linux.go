@@ -0,0 +1,40 @@
+package priority
+
+import (
+	"runtime"
+	"syscall"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+	ioprioClassBE    = 2
+	ioprioClassIdle  = 3
+)
+
+// lower Linux 的 nice 和 IO 优先级都按线程设置，Go 运行时不会从锁定的线程创建新线程，其他线程不受影响
+func lower(cfg config.PriorityConfig) error {
+	runtime.LockOSThread()
+	tid := syscall.Gettid()
+	if cfg.Nice > 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, cfg.Nice); err != nil {
+			return i18n.Errorf("设置 nice: %w", err)
+		}
+	}
+	var prio uintptr
+	switch cfg.IOClass {
+	case ClassBestEffort:
+		prio = ioprioClassBE<<ioprioClassShift | uintptr(cfg.IOLevel)
+	case ClassIdle:
+		prio = ioprioClassIdle << ioprioClassShift
+	default:
+		return nil
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), prio); errno != 0 {
+		return i18n.Errorf("设置 IO 优先级: %w", errno)
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !windows
+
+package priority
+
+import "github.com/lucasrui/neo-nas/internal/config"
+
+// lower 其他平台暂不支持调整优先级
+func lower(cfg config.PriorityConfig) error {
+	return nil
+}
@@ -0,0 +1,50 @@
+package priority
+
+import (
+	"sync"
+	"syscall"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+var (
+	procSetPriorityClass = syscall.NewLazyDLL("kernel32.dll").NewProc("SetPriorityClass")
+	processOnce          sync.Once
+)
+
+const (
+	belowNormalPriorityClass = 0x00004000
+	idlePriorityClass        = 0x00000040
+	processModeBackground    = 0x00100000 // 同时降低 IO 和内存优先级
+)
+
+// lower Windows 上按进程设置优先级类别，只设置一次；io_class 为 idle 时进入后台模式
+func lower(cfg config.PriorityConfig) error {
+	var err error
+	processOnce.Do(func() {
+		h, e := syscall.GetCurrentProcess()
+		if e != nil {
+			err = e
+			return
+		}
+		var classes []uintptr
+		if cfg.Nice > 0 {
+			class := uintptr(belowNormalPriorityClass)
+			if cfg.Nice >= 15 {
+				class = idlePriorityClass
+			}
+			classes = append(classes, class)
+		}
+		if cfg.IOClass == ClassIdle {
+			classes = append(classes, processModeBackground)
+		}
+		for _, class := range classes {
+			if r, _, e := procSetPriorityClass.Call(uintptr(h), class); r == 0 {
+				err = i18n.Errorf("设置进程优先级: %w", e)
+				return
+			}
+		}
+	})
+	return err
+}
@@ -0,0 +1,32 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// Direct 不使用代理，也不读取环境变量
+const Direct = "direct"
+
+// Func 返回 http.Transport 使用的代理函数，支持 http://、https://、socks5:// 和 socks5h:// 代理
+// location 为空时读取 HTTPS_PROXY、HTTP_PROXY、NO_PROXY 环境变量，为 direct 时直接连接
+func Func(location string) (func(*http.Request) (*url.URL, error), error) {
+	switch location {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case Direct:
+		return nil, nil
+	}
+	u, err := url.Parse(location)
+	if err != nil || u.Host == "" {
+		return nil, i18n.Errorf("代理地址无效: %s", location)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, i18n.Errorf("不支持的代理类型: %s", u.Scheme)
+	}
+	return http.ProxyURL(u), nil
+}
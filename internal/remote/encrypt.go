@@ -0,0 +1,381 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// 加密对象的格式：魔数、16 字节随机盐，之后是固定大小的 AES-256-GCM 分块
+// 每个文件的密钥由主密钥和盐派生，分块序号和是否为最后一块写入 nonce，分块被截断、重排或替换时都无法解密
+var encMagic = []byte("NEOE1")
+
+const (
+	encSaltSize  = 16
+	encChunkSize = 64 << 10
+	encHeader    = 5 + encSaltSize
+)
+
+// ErrDecrypt 对象无法用当前密钥解密
+var ErrDecrypt = i18n.New("解密失败，密钥不正确或对象已损坏")
+
+// LoadKey 读取密钥文件，内容为 64 个十六进制字符或 base64 编码的 32 字节密钥
+// 可以用 openssl rand -hex 32 生成
+func LoadKey(file string) ([]byte, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, i18n.Errorf("读取密钥文件失败: %w", err)
+	}
+	v := strings.TrimSpace(string(data))
+	if key, err := hex.DecodeString(v); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(v); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, i18n.Errorf("密钥文件格式无效，应为 32 字节密钥的十六进制或 base64 编码: %s", file)
+}
+
+// Encrypted 在写入不可信的远程存储前加密内容，可选把对象键替换为不可读的名称
+type Encrypted struct {
+	inner     Backend
+	key       []byte
+	obfuscate bool
+	index     *encIndex
+}
+
+// NewEncrypted 包装后端，indexFile 记录对象的原始名称和哈希，为空时不记录
+func NewEncrypted(inner Backend, cfg config.EncryptionConfig, indexFile string) (*Encrypted, error) {
+	key, err := LoadKey(cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &Encrypted{inner: inner, key: key, obfuscate: cfg.ObfuscateNames, index: &encIndex{file: indexFile}}, nil
+}
+
+func (e *Encrypted) String() string {
+	return e.inner.String()
+}
+
+// Unwrap 返回被包装的后端
+func (e *Encrypted) Unwrap() Backend {
+	return e.inner
+}
+
+// storedKey 返回对象在存储上的键，开启名称混淆时为原始键的 HMAC，相同的键总是得到相同的名称
+func (e *Encrypted) storedKey(key string) string {
+	if !e.obfuscate {
+		return key + ".enc"
+	}
+	mac := hmac.New(sha256.New, e.subKey("name"))
+	mac.Write([]byte(key))
+	sum := hex.EncodeToString(mac.Sum(nil))
+	// 按前两个字符分目录，避免单个目录中的对象过多
+	return sum[:2] + "/" + sum[2:]
+}
+
+// subKey 由主密钥派生不同用途的密钥
+func (e *Encrypted) subKey(purpose string) []byte {
+	mac := hmac.New(sha256.New, e.key)
+	mac.Write([]byte("neo-nas/" + purpose))
+	return mac.Sum(nil)
+}
+
+func (e *Encrypted) fileAEAD(salt []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, e.subKey("file"))
+	mac.Write(salt)
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Put 加密后写入，同时在本地索引中记录原始名称、明文和密文的哈希
+func (e *Encrypted) Put(key string, r io.Reader) error {
+	return e.put(key, r, e.inner.Put)
+}
+
+// put 加密后通过 put 写入内部的后端
+func (e *Encrypted) put(key string, r io.Reader, put func(key string, r io.Reader) error) error {
+	if !validKey(key) {
+		return i18n.Errorf("对象键无效: %s", key)
+	}
+	stored := e.storedKey(key)
+	pr, pw := io.Pipe()
+	plainHash, cipherHash := sha256.New(), sha256.New()
+	var plainSize int64
+	go func() {
+		n, err := e.encrypt(io.MultiWriter(pw, cipherHash), io.TeeReader(r, plainHash))
+		plainSize = n
+		pw.CloseWithError(err)
+	}()
+	if err := put(stored, pr); err != nil {
+		pr.CloseWithError(err)
+		return err
+	}
+	e.index.set(e.inner.String()+"/"+stored, &encEntry{
+		Key:          key,
+		Size:         plainSize,
+		SHA256:       hex.EncodeToString(plainHash.Sum(nil)),
+		CipherSHA256: hex.EncodeToString(cipherHash.Sum(nil)),
+		Time:         time.Now(),
+	})
+	return nil
+}
+
+// encrypt 把 r 的内容加密写入 w，返回明文字节数
+func (e *Encrypted) encrypt(w io.Writer, r io.Reader) (int64, error) {
+	salt := make([]byte, encSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return 0, err
+	}
+	aead, err := e.fileAEAD(salt)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := w.Write(append(append([]byte{}, encMagic...), salt...)); err != nil {
+		return 0, err
+	}
+
+	// 多读一个字节判断当前分块是否为最后一块
+	br := bufio.NewReaderSize(r, encChunkSize+1)
+	buf := make([]byte, encChunkSize)
+	out := make([]byte, 0, encChunkSize+aead.Overhead())
+	var total int64
+	for counter := uint64(0); ; counter++ {
+		n, err := io.ReadFull(br, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return total, err
+		}
+		_, peekErr := br.Peek(1)
+		last := peekErr != nil
+		if peekErr != nil && peekErr != io.EOF {
+			return total, peekErr
+		}
+		total += int64(n)
+		out = aead.Seal(out[:0], chunkNonce(counter, last), buf[:n], nil)
+		if _, err := w.Write(out); err != nil {
+			return total, err
+		}
+		if last {
+			return total, nil
+		}
+	}
+}
+
+func chunkNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// Get 读取并解密，开启加密前写入的对象按原始名称读取且不解密，并记录日志
+func (e *Encrypted) Get(key string) (io.ReadCloser, error) {
+	if !validKey(key) {
+		return nil, i18n.Errorf("对象键无效: %s", key)
+	}
+	rc, err := e.inner.Get(e.storedKey(key))
+	if err == ErrNotFound {
+		if rc, err = e.inner.Get(key); err == nil {
+			logger.Infof("对象未加密，按原样读取: %s/%s", e.inner, key)
+			return rc, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, encHeader)
+	if _, err := io.ReadFull(rc, header); err != nil || !bytes.Equal(header[:len(encMagic)], encMagic) {
+		rc.Close()
+		return nil, ErrDecrypt
+	}
+	aead, err := e.fileAEAD(header[len(encMagic):])
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return &decryptReader{src: bufio.NewReaderSize(rc, encChunkSize+aead.Overhead()+1), closer: rc, aead: aead}, nil
+}
+
+// decryptReader 按分块解密，只有校验通过的分块才会返回给调用者
+type decryptReader struct {
+	src     *bufio.Reader
+	closer  io.Closer
+	aead    cipher.AEAD
+	counter uint64
+	buf     []byte
+	plain   []byte
+	done    bool
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+func (d *decryptReader) next() error {
+	if d.buf == nil {
+		d.buf = make([]byte, encChunkSize+d.aead.Overhead())
+	}
+	n, err := io.ReadFull(d.src, d.buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		if err == io.EOF {
+			// 没有读到标记为最后一块的分块，对象被截断
+			return ErrDecrypt
+		}
+		return err
+	}
+	_, peekErr := d.src.Peek(1)
+	last := peekErr == io.EOF
+	if peekErr != nil && !last {
+		return peekErr
+	}
+	plain, err := d.aead.Open(d.buf[:0], chunkNonce(d.counter, last), d.buf[:n], nil)
+	if err != nil {
+		return ErrDecrypt
+	}
+	d.counter++
+	d.plain = plain
+	d.done = last
+	return nil
+}
+
+func (d *decryptReader) Close() error {
+	return d.closer.Close()
+}
+
+func (e *Encrypted) Delete(key string) error {
+	if !validKey(key) {
+		return i18n.Errorf("对象键无效: %s", key)
+	}
+	stored := e.storedKey(key)
+	if err := e.inner.Delete(stored); err != nil {
+		return err
+	}
+	e.index.set(e.inner.String()+"/"+stored, nil)
+	return nil
+}
+
+// Stat 密文与本地索引中记录的一致时返回明文的大小和哈希，否则只返回按密文推算的大小，由调用者下载校验
+func (e *Encrypted) Stat(key string) (Info, error) {
+	stored := e.storedKey(key)
+	s, ok := e.inner.(Stater)
+	if !ok {
+		return Info{}, errNoStat
+	}
+	info, err := s.Stat(stored)
+	if err != nil {
+		return Info{}, err
+	}
+	if entry, ok := e.index.get(e.inner.String() + "/" + stored); ok && info.SHA256 != "" && info.SHA256 == entry.CipherSHA256 {
+		return Info{Size: entry.Size, SHA256: entry.SHA256}, nil
+	}
+	return Info{Size: plainSize(info.Size)}, nil
+}
+
+// plainSize 由密文大小推算明文大小
+func plainSize(size int64) int64 {
+	const overhead = 16
+	body := size - encHeader
+	chunks := (body + encChunkSize + overhead - 1) / (encChunkSize + overhead)
+	if chunks < 1 {
+		chunks = 1
+	}
+	return body - chunks*overhead
+}
+
+// encEntry 本地索引中的一条记录，开启名称混淆时据此找回原始名称
+type encEntry struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	SHA256       string    `json:"sha256"`
+	CipherSHA256 string    `json:"cipher_sha256"`
+	Time         time.Time `json:"time"`
+}
+
+// encIndex 以 <存储位置>/<存储上的键> 为键记录加密对象
+type encIndex struct {
+	file string
+}
+
+var encIndexMu sync.Mutex
+
+func (x *encIndex) load() map[string]encEntry {
+	entries := make(map[string]encEntry)
+	if x.file == "" {
+		return entries
+	}
+	data, err := os.ReadFile(x.file)
+	if err != nil {
+		return entries
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		logger.Warnf("加密索引文件损坏，已忽略: %v", err)
+	}
+	return entries
+}
+
+func (x *encIndex) get(id string) (encEntry, bool) {
+	encIndexMu.Lock()
+	defer encIndexMu.Unlock()
+	e, ok := x.load()[id]
+	return e, ok
+}
+
+func (x *encIndex) set(id string, e *encEntry) {
+	if x.file == "" {
+		return
+	}
+	encIndexMu.Lock()
+	defer encIndexMu.Unlock()
+	entries := x.load()
+	if e == nil {
+		delete(entries, id)
+	} else {
+		entries[id] = *e
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(x.file, data)
+	}
+	if err != nil {
+		logger.Errorf("保存加密索引失败: %v", err)
+	}
+}
+
+func writeFileAtomic(file string, data []byte) error {
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
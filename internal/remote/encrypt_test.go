@@ -0,0 +1,191 @@
+package remote
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+)
+
+const chunkCipherSize = encChunkSize + 16
+
+// newTestEncrypted 在临时目录中创建本地存储和随机密钥
+func newTestEncrypted(t *testing.T) (*Encrypted, *Local) {
+	t.Helper()
+	dir := t.TempDir()
+	key := make([]byte, 32)
+	rand.Read(key)
+	keyFile := filepath.Join(dir, "key")
+	if err := os.WriteFile(keyFile, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	local, err := NewLocal(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEncrypted(local, config.EncryptionConfig{KeyFile: keyFile}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e, local
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
+
+// sealed 加密 plain，返回存储上的密文
+func sealed(t *testing.T, e *Encrypted, plain []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	n, err := e.encrypt(&buf, bytes.NewReader(plain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(plain)) {
+		t.Fatalf("encrypt returned %d, want %d", n, len(plain))
+	}
+	return buf.Bytes()
+}
+
+// opened 把密文原样写入存储，再通过 Get 解密读取
+func opened(t *testing.T, e *Encrypted, local *Local, data []byte) ([]byte, error) {
+	t.Helper()
+	if err := local.Put(e.storedKey("a/b.bin"), bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	rc, err := e.Get("a/b.bin")
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	e, _ := newTestEncrypted(t)
+	sizes := []int{0, 1, encChunkSize - 1, encChunkSize, encChunkSize + 1, 3*encChunkSize + 5}
+	for _, size := range sizes {
+		plain := randomBytes(size)
+		if err := e.Put("a/b.bin", bytes.NewReader(plain)); err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		rc, err := e.Get("a/b.bin")
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		got, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Fatalf("size %d: decrypted content differs", size)
+		}
+		if n := plainSize(int64(len(sealed(t, e, plain)))); n != int64(size) {
+			t.Errorf("size %d: plainSize = %d", size, n)
+		}
+	}
+}
+
+func TestEncryptUniqueSalt(t *testing.T) {
+	e, _ := newTestEncrypted(t)
+	plain := []byte("same content")
+	if bytes.Equal(sealed(t, e, plain), sealed(t, e, plain)) {
+		t.Fatal("identical ciphertext for identical content")
+	}
+}
+
+func TestDecryptTruncated(t *testing.T) {
+	e, local := newTestEncrypted(t)
+	data := sealed(t, e, randomBytes(3*encChunkSize+5))
+	cases := map[string]int{
+		"header":        encHeader,
+		"partial":       encHeader - 1,
+		"chunk":         encHeader + chunkCipherSize,
+		"last chunk":    encHeader + 3*chunkCipherSize,
+		"mid chunk":     encHeader + chunkCipherSize + 100,
+		"last byte cut": len(data) - 1,
+	}
+	for name, n := range cases {
+		if _, err := opened(t, e, local, data[:n]); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("%s: got %v, want ErrDecrypt", name, err)
+		}
+	}
+}
+
+func TestDecryptReordered(t *testing.T) {
+	e, local := newTestEncrypted(t)
+	data := sealed(t, e, randomBytes(3*encChunkSize+5))
+	chunk := func(i int) []byte {
+		start := encHeader + i*chunkCipherSize
+		return data[start : start+chunkCipherSize]
+	}
+	swapped := append([]byte(nil), data[:encHeader]...)
+	swapped = append(swapped, chunk(1)...)
+	swapped = append(swapped, chunk(0)...)
+	swapped = append(swapped, data[encHeader+2*chunkCipherSize:]...)
+	if _, err := opened(t, e, local, swapped); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("swapped chunks: got %v, want ErrDecrypt", err)
+	}
+
+	// 去掉中间的分块，其余分块的序号与 nonce 不再对应
+	dropped := append([]byte(nil), data[:encHeader+chunkCipherSize]...)
+	dropped = append(dropped, data[encHeader+2*chunkCipherSize:]...)
+	if _, err := opened(t, e, local, dropped); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("dropped chunk: got %v, want ErrDecrypt", err)
+	}
+
+	// 其他对象的分块即使使用同一个密钥也无法替换进来
+	other := sealed(t, e, randomBytes(3*encChunkSize+5))
+	replaced := append([]byte(nil), data...)
+	copy(replaced[encHeader+chunkCipherSize:], other[encHeader+chunkCipherSize:encHeader+2*chunkCipherSize])
+	if _, err := opened(t, e, local, replaced); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("replaced chunk: got %v, want ErrDecrypt", err)
+	}
+}
+
+func TestDecryptCorrupted(t *testing.T) {
+	e, local := newTestEncrypted(t)
+	data := sealed(t, e, randomBytes(2*encChunkSize))
+	for _, offset := range []int{0, len(encMagic), encHeader + 10, len(data) - 1} {
+		corrupted := append([]byte(nil), data...)
+		corrupted[offset] ^= 0x01
+		if _, err := opened(t, e, local, corrupted); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("offset %d: got %v, want ErrDecrypt", offset, err)
+		}
+	}
+
+	other, otherLocal := newTestEncrypted(t)
+	if _, err := opened(t, other, otherLocal, data); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("wrong key: got %v, want ErrDecrypt", err)
+	}
+}
+
+func TestDecryptPlainFallback(t *testing.T) {
+	e, local := newTestEncrypted(t)
+	plain := []byte("written before encryption was enabled")
+	if err := local.Put("old.txt", bytes.NewReader(plain)); err != nil {
+		t.Fatal(err)
+	}
+	rc, err := e.Get("old.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Fatalf("got %q, want %q", got, plain)
+	}
+}
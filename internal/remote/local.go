@@ -0,0 +1,90 @@
+package remote
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// Local 本地目录后端，例如另一块硬盘或挂载的共享
+type Local struct {
+	root string
+}
+
+func NewLocal(root string) (*Local, error) {
+	if root == "" {
+		return nil, i18n.Errorf("存储目录不能为空")
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, i18n.Errorf("解析存储目录失败: %w", err)
+	}
+	return &Local{root: root}, nil
+}
+
+func (l *Local) String() string {
+	return l.root
+}
+
+func (l *Local) path(key string) (string, error) {
+	if !validKey(key) {
+		return "", i18n.Errorf("对象键无效: %s", key)
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+// Put 先写入同目录下的临时文件，同步到磁盘后再替换
+func (l *Local) Put(key string, r io.Reader) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return i18n.Errorf("创建存储目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".neo-put-*")
+	if err != nil {
+		return i18n.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		return i18n.Errorf("写入对象失败: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return i18n.Errorf("同步对象失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return i18n.Errorf("关闭对象失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return i18n.Errorf("保存对象失败: %w", err)
+	}
+	return nil
+}
+
+func (l *Local) Get(key string) (io.ReadCloser, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (l *Local) Delete(key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return i18n.Errorf("删除对象失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,328 @@
+package remote
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+const (
+	defaultPartSize = 16 << 20 // 默认分块大小
+	minPartSize     = 5 << 20  // S3 要求除最后一块外每块不小于 5MB
+	maxParts        = 10000    // S3 单个对象最多的分块数量
+	maxPartRetries  = 5        // 单个分块的最大重试次数
+)
+
+// pendingMultipart 未完成的分块上传，同一对象的内容未变化时可以继续使用
+type pendingMultipart struct {
+	UploadID string    `json:"upload_id"`
+	Size     int64     `json:"size"`
+	SHA256   string    `json:"sha256"`
+	PartSize int64     `json:"part_size"`
+	Started  time.Time `json:"started"`
+}
+
+// multipartState 以 <存储桶>/<对象键> 为键保存未完成的分块上传，程序重启后继续上传
+// 同时打开的多个后端可能共用一个状态文件，每次修改都重新读取后写回
+type multipartState struct {
+	file string
+}
+
+var multipartMu sync.Mutex
+
+func (m *multipartState) load() map[string]pendingMultipart {
+	uploads := make(map[string]pendingMultipart)
+	if m.file == "" {
+		return uploads
+	}
+	data, err := os.ReadFile(m.file)
+	if err != nil {
+		return uploads
+	}
+	if err := json.Unmarshal(data, &uploads); err != nil {
+		logger.Warnf("分块上传状态文件损坏，已忽略: %v", err)
+	}
+	return uploads
+}
+
+func (m *multipartState) get(id string) (pendingMultipart, bool) {
+	multipartMu.Lock()
+	defer multipartMu.Unlock()
+	p, ok := m.load()[id]
+	return p, ok
+}
+
+func (m *multipartState) set(id string, p *pendingMultipart) {
+	if m.file == "" {
+		return
+	}
+	multipartMu.Lock()
+	defer multipartMu.Unlock()
+	uploads := m.load()
+	if p == nil {
+		delete(uploads, id)
+	} else {
+		uploads[id] = *p
+	}
+	data, err := json.MarshalIndent(uploads, "", "  ")
+	if err == nil {
+		err = os.WriteFile(m.file, data, 0644)
+	}
+	if err != nil {
+		logger.Errorf("保存分块上传状态失败: %v", err)
+	}
+}
+
+// partSizeFor 返回对象使用的分块大小，分块数量超过上限时按 MB 向上取整增大
+func (s *S3) partSizeFor(size int64) int64 {
+	n := s.partSize
+	if size > n*maxParts {
+		n = ((size/maxParts)>>20 + 1) << 20
+	}
+	return n
+}
+
+// putMultipart 分块上传，每个分块失败后按退避时间重试
+// 上传会话记录在状态文件中，多次重试仍失败时保留已上传的分块，下次上传相同内容时从服务端已有的分块继续
+func (s *S3) putMultipart(key string, body io.ReaderAt, size int64, sum string) error {
+	id := s.bucket + "/" + s.fullKey(key)
+	pending, ok := s.uploads.get(id)
+	var done map[int]string
+	if ok && pending.Size == size && pending.SHA256 == sum {
+		parts, err := s.listParts(key, pending.UploadID)
+		if err == nil {
+			done = parts
+			logger.Infof("继续分块上传: %s/%s, 已完成 %d 个分块", s, key, len(done))
+		} else {
+			logger.Warnf("无法继续之前的分块上传，重新上传 %s/%s: %v", s, key, err)
+			ok = false
+		}
+	} else if ok {
+		// 内容已变化，放弃之前的上传，释放存储上已上传的分块
+		if err := s.abortMultipart(key, pending.UploadID); err != nil {
+			logger.Errorf("放弃分块上传失败 %s/%s: %v", s, key, err)
+		}
+		ok = false
+	}
+	if !ok {
+		uploadID, err := s.createMultipart(key, sum)
+		if err != nil {
+			return err
+		}
+		pending = pendingMultipart{UploadID: uploadID, Size: size, SHA256: sum, PartSize: s.partSizeFor(size), Started: time.Now()}
+		s.uploads.set(id, &pending)
+		done = make(map[int]string)
+	}
+
+	count := int((size + pending.PartSize - 1) / pending.PartSize)
+	etags := make([]string, count)
+	for i := 0; i < count; i++ {
+		number := i + 1
+		if etag, ok := done[number]; ok {
+			etags[i] = etag
+			continue
+		}
+		offset := int64(i) * pending.PartSize
+		part := io.NewSectionReader(body, offset, min(pending.PartSize, size-offset))
+		etag, err := s.uploadPartRetry(key, pending.UploadID, number, part)
+		if err != nil {
+			return i18n.Errorf("分块上传多次失败，下次上传时继续: %w", err)
+		}
+		etags[i] = etag
+		logger.Infof("分块上传 %s/%s: %d/%d", s, key, number, count)
+	}
+
+	if err := s.completeMultipart(key, pending.UploadID, etags); err != nil {
+		return err
+	}
+	s.uploads.set(id, nil)
+	return nil
+}
+
+func (s *S3) fullKey(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return s.prefix + "/" + key
+}
+
+// multipartURL 返回带查询参数的对象地址，查询参数按签名规则编码
+func (s *S3) multipartURL(key string, query url.Values) string {
+	u := s.objectURL(key)
+	u.RawQuery = canonicalQuery(query)
+	return u.String()
+}
+
+func (s *S3) createMultipart(key, sum string) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, s.multipartURL(key, url.Values{"uploads": {""}}), nil)
+	if err != nil {
+		return "", err
+	}
+	// 流式上传时事先不知道哈希，不记录
+	if sum != "" {
+		req.Header.Set(metaSHA256, sum)
+	}
+	s.setObjectHeaders(req)
+	resp, err := s.do(req, emptySHA256)
+	if err != nil {
+		return "", i18n.Errorf("创建分块上传失败: %w", err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil || result.UploadID == "" {
+		return "", i18n.Errorf("解析分块上传响应失败: %v", err)
+	}
+	return result.UploadID, nil
+}
+
+func (s *S3) uploadPartRetry(key, uploadID string, number int, part *io.SectionReader) (string, error) {
+	var err error
+	for retries := 0; ; retries++ {
+		var etag string
+		if etag, err = s.uploadPart(key, uploadID, number, part); err == nil {
+			return etag, nil
+		}
+		if retries >= maxPartRetries {
+			return "", err
+		}
+		wait := time.Duration(1<<uint(retries)) * time.Second
+		logger.Warnf("上传分块 %d 失败 %s/%s: %v, %s 后重试", number, s, key, err, wait)
+		time.Sleep(wait)
+	}
+}
+
+// uploadPart 上传一个分块，返回存储生成的 ETag
+func (s *S3) uploadPart(key, uploadID string, number int, part *io.SectionReader) (string, error) {
+	sha, md := sha256.New(), md5.New()
+	if _, err := io.Copy(io.MultiWriter(sha, md), io.NewSectionReader(part, 0, part.Size())); err != nil {
+		return "", i18n.Errorf("读取上传内容失败: %w", err)
+	}
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+	body := io.NopCloser(s.limiter.Reader(io.NewSectionReader(part, 0, part.Size())))
+	req, err := http.NewRequest(http.MethodPut, s.multipartURL(key, query), body)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = part.Size()
+	// 开启对象锁定的存储桶要求每个分块都带有 Content-MD5
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(md.Sum(nil)))
+	resp, err := s.do(req, hex.EncodeToString(sha.Sum(nil)))
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		return "", i18n.Errorf("S3 未返回分块的 ETag")
+	}
+	return etag, nil
+}
+
+// listParts 查询服务端已接收的分块，上传会话不存在时返回错误
+func (s *S3) listParts(key, uploadID string) (map[int]string, error) {
+	parts := make(map[int]string)
+	marker := ""
+	for {
+		query := url.Values{"uploadId": {uploadID}}
+		if marker != "" {
+			query.Set("part-number-marker", marker)
+		}
+		req, err := http.NewRequest(http.MethodGet, s.multipartURL(key, query), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req, emptySHA256)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			IsTruncated          bool   `xml:"IsTruncated"`
+			NextPartNumberMarker string `xml:"NextPartNumberMarker"`
+			Parts                []struct {
+				PartNumber int    `xml:"PartNumber"`
+				ETag       string `xml:"ETag"`
+			} `xml:"Part"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, i18n.Errorf("解析分块列表失败: %w", err)
+		}
+		for _, p := range result.Parts {
+			parts[p.PartNumber] = p.ETag
+		}
+		if !result.IsTruncated || result.NextPartNumberMarker == "" {
+			return parts, nil
+		}
+		marker = result.NextPartNumberMarker
+	}
+}
+
+func (s *S3) completeMultipart(key, uploadID string, etags []string) error {
+	type part struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	var body struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}
+	for i, etag := range etags {
+		body.Parts = append(body.Parts, part{PartNumber: i + 1, ETag: etag})
+	}
+	data, err := xml.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.multipartURL(key, url.Values{"uploadId": {uploadID}}), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(data)
+	resp, err := s.do(req, hex.EncodeToString(hash[:]))
+	if err != nil {
+		return i18n.Errorf("完成分块上传失败: %w", err)
+	}
+	defer resp.Body.Close()
+	// 合并分块时出错也可能返回 200，错误信息在响应内容中
+	var e struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.NewDecoder(resp.Body).Decode(&e) == nil && e.XMLName.Local == "Error" {
+		return i18n.Errorf("完成分块上传失败: %s: %s", e.Code, e.Message)
+	}
+	return nil
+}
+
+func (s *S3) abortMultipart(key, uploadID string) error {
+	req, err := http.NewRequest(http.MethodDelete, s.multipartURL(key, url.Values{"uploadId": {uploadID}}), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, emptySHA256)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
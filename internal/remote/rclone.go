@@ -0,0 +1,213 @@
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/throttle"
+)
+
+// rclone 的退出码：3 表示目录不存在，4 表示文件不存在
+const (
+	rcloneDirNotFound  = 3
+	rcloneFileNotFound = 4
+)
+
+// Rclone 通过 rclone 命令读写的后端，位置格式为 rclone:<远程名>:<路径>，远程名在 rclone 的配置文件中定义
+// 可以使用 rclone 支持的各种网盘和云存储，传输由 rclone 完成，监控、过滤和进度仍由本程序处理
+type Rclone struct {
+	remote  string   // <远程名>:<路径>
+	binary  string   // rclone 可执行文件
+	args    []string // 每次执行时附加的参数
+	limiter *throttle.Limiter
+}
+
+func NewRclone(location string, cfg config.RcloneConfig, limiter *throttle.Limiter) (*Rclone, error) {
+	remote := strings.TrimSuffix(strings.TrimPrefix(location, config.RcloneTargetPrefix), "/")
+	if name, _, ok := strings.Cut(remote, ":"); !ok || name == "" {
+		return nil, i18n.Errorf("rclone 存储位置格式应为 rclone:<远程名>:<路径>: %s", location)
+	}
+	r := &Rclone{remote: remote, binary: cfg.Binary, limiter: limiter}
+	if r.binary == "" {
+		r.binary = "rclone"
+	}
+	if _, err := exec.LookPath(r.binary); err != nil {
+		return nil, i18n.Errorf("未找到 rclone 命令: %w", err)
+	}
+	if cfg.ConfigFile != "" {
+		r.args = append(r.args, "--config", cfg.ConfigFile)
+	}
+	r.args = append(r.args, cfg.Flags...)
+	return r, nil
+}
+
+func (r *Rclone) String() string {
+	return config.RcloneTargetPrefix + r.remote
+}
+
+func (r *Rclone) path(key string) (string, error) {
+	if !validKey(key) {
+		return "", i18n.Errorf("对象键无效: %s", key)
+	}
+	if strings.HasSuffix(r.remote, ":") {
+		return r.remote + key, nil
+	}
+	return r.remote + "/" + key, nil
+}
+
+func (r *Rclone) command(args ...string) *exec.Cmd {
+	return exec.Command(r.binary, append(append([]string{}, r.args...), args...)...)
+}
+
+// run 执行 rclone，失败时把错误输出附加到错误信息中
+func (r *Rclone) run(cmd *exec.Cmd) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, &rcloneError{args: cmd.Args[1:], err: err, stderr: strings.TrimSpace(stderr.String())}
+	}
+	return out, nil
+}
+
+// rcloneError rclone 执行失败
+type rcloneError struct {
+	args   []string
+	err    error
+	stderr string
+}
+
+func (e *rcloneError) Error() string {
+	return fmt.Sprintf("rclone %s: %v: %s", strings.Join(e.args, " "), e.err, e.stderr)
+}
+
+func (e *rcloneError) Unwrap() error {
+	return e.err
+}
+
+// notFound 判断 rclone 是否因为文件或目录不存在而失败
+func notFound(err error) bool {
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) {
+		return false
+	}
+	return exit.ExitCode() == rcloneDirNotFound || exit.ExitCode() == rcloneFileNotFound
+}
+
+// Put 使用 rclone rcat 从标准输入上传，上传带宽按 bandwidth 配置限制
+func (r *Rclone) Put(key string, data io.Reader) error {
+	p, err := r.path(key)
+	if err != nil {
+		return err
+	}
+	cmd := r.command("rcat", p)
+	cmd.Stdin = &killOnError{r: r.limiter.Reader(data), cmd: cmd}
+	if _, err := r.run(cmd); err != nil {
+		return i18n.Errorf("上传对象失败: %w", err)
+	}
+	return nil
+}
+
+func (r *Rclone) Get(key string) (io.ReadCloser, error) {
+	if _, err := r.Stat(key); err != nil {
+		return nil, err
+	}
+	p, err := r.path(key)
+	if err != nil {
+		return nil, err
+	}
+	cmd := r.command("cat", p)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, i18n.Errorf("读取对象失败: %w", err)
+	}
+	return &rcloneReader{cmd: cmd, out: out, stderr: &stderr}, nil
+}
+
+// rcloneReader 读取 rclone cat 的输出，rclone 中途失败时在读到末尾时返回错误
+type rcloneReader struct {
+	cmd    *exec.Cmd
+	out    io.ReadCloser
+	stderr *bytes.Buffer
+	done   bool
+	err    error
+}
+
+func (c *rcloneReader) Read(p []byte) (int, error) {
+	n, err := c.out.Read(p)
+	if err == io.EOF {
+		if werr := c.wait(); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (c *rcloneReader) wait() error {
+	if !c.done {
+		c.done = true
+		if err := c.cmd.Wait(); err != nil {
+			c.err = i18n.Errorf("读取对象失败: %w", &rcloneError{args: c.cmd.Args[1:], err: err, stderr: strings.TrimSpace(c.stderr.String())})
+		}
+	}
+	return c.err
+}
+
+// Close 没有读完时结束 rclone
+func (c *rcloneReader) Close() error {
+	if !c.done {
+		c.cmd.Process.Kill()
+		c.done = true
+		c.cmd.Wait()
+	}
+	return nil
+}
+
+func (r *Rclone) Delete(key string) error {
+	p, err := r.path(key)
+	if err != nil {
+		return err
+	}
+	if _, err := r.run(r.command("deletefile", p)); err != nil && !notFound(err) {
+		return i18n.Errorf("删除对象失败: %w", err)
+	}
+	return nil
+}
+
+// Stat 通过 rclone lsjson 查询大小，远程存储支持 SHA-256 时一并返回
+func (r *Rclone) Stat(key string) (Info, error) {
+	p, err := r.path(key)
+	if err != nil {
+		return Info{}, err
+	}
+	out, err := r.run(r.command("lsjson", "--files-only", "--hash", "--hash-type", "sha256", p))
+	if err != nil {
+		if notFound(err) {
+			return Info{}, ErrNotFound
+		}
+		return Info{}, i18n.Errorf("查询对象失败: %w", err)
+	}
+	var items []struct {
+		Size   int64             `json:"Size"`
+		Hashes map[string]string `json:"Hashes"`
+	}
+	if err := json.Unmarshal(out, &items); err != nil {
+		return Info{}, i18n.Errorf("解析 rclone 输出失败: %w", err)
+	}
+	if len(items) == 0 {
+		return Info{}, ErrNotFound
+	}
+	return Info{Size: items[0].Size, SHA256: items[0].Hashes["sha256"]}, nil
+}
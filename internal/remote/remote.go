@@ -0,0 +1,146 @@
+package remote
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+	"github.com/lucasrui/neo-nas/internal/throttle"
+)
+
+// logger remote 模块的日志
+var logger = logging.New("remote")
+
+// ErrNotFound 对象不存在
+var ErrNotFound = i18n.New("对象不存在")
+
+// Backend 远程存储后端，对象以 / 分隔的键存放
+type Backend interface {
+	// Put 写入对象，写入完成前其他读取者看不到不完整的内容
+	Put(key string, r io.Reader) error
+	// Get 读取对象，不存在时返回 ErrNotFound
+	Get(key string) (io.ReadCloser, error)
+	// Delete 删除对象，不存在时不报错
+	Delete(key string) error
+	// String 返回后端位置，用于日志和记录
+	String() string
+}
+
+// Info 对象的大小和内容哈希
+type Info struct {
+	Size   int64
+	SHA256 string // 上传时记录的哈希，没有记录时为空
+}
+
+// errNoStat 后端无法直接查询对象信息，需要下载校验
+var errNoStat = i18n.New("存储不支持查询对象信息")
+
+// Stater 不需要下载就能查询对象信息的后端
+type Stater interface {
+	// Stat 查询对象信息，不存在时返回 ErrNotFound
+	Stat(key string) (Info, error)
+}
+
+// Verify 上传完成后校验远程对象与本地内容一致，校验通过后才能删除本地文件
+// 后端能直接查询哈希时只比较大小和哈希，否则下载对象重新计算
+func Verify(b Backend, key string, size int64, sum string) error {
+	if s, ok := b.(Stater); ok {
+		info, err := s.Stat(key)
+		if err != nil && err != errNoStat {
+			return i18n.Errorf("查询远程对象失败: %w", err)
+		}
+		if err == errNoStat {
+			info = Info{Size: size}
+		}
+		if info.Size != size {
+			return i18n.Errorf("远程对象大小不匹配: 期望 %d, 实际 %d", size, info.Size)
+		}
+		if info.SHA256 != "" {
+			if info.SHA256 != sum {
+				return i18n.Errorf("远程对象哈希不匹配: 期望 %s, 实际 %s", sum, info.SHA256)
+			}
+			return nil
+		}
+	}
+
+	r, err := b.Get(key)
+	if err != nil {
+		return i18n.Errorf("读取远程对象失败: %w", err)
+	}
+	defer r.Close()
+	hash := sha256.New()
+	n, err := io.Copy(hash, r)
+	if err != nil {
+		return i18n.Errorf("读取远程对象失败: %w", err)
+	}
+	if n != size {
+		return i18n.Errorf("远程对象大小不匹配: 期望 %d, 实际 %d", size, n)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != sum {
+		return i18n.Errorf("远程对象哈希不匹配: 期望 %s, 实际 %s", sum, got)
+	}
+	return nil
+}
+
+// Options 打开后端时使用的全局配置
+type Options struct {
+	S3         config.S3Config         // s3:// 位置的连接配置
+	Rclone     config.RcloneConfig     // rclone: 位置使用的 rclone 命令
+	SMB        config.SMBConfig        // smb:// 位置的登录配置
+	Limiter    *throttle.Limiter       // 上传带宽限制，为 nil 时不限速
+	StateFile  string                  // 未完成的分块上传记录，为空时中断后重新上传
+	Encryption config.EncryptionConfig // 客户端加密，未配置密钥文件时不加密
+	IndexFile  string                  // 加密对象的本地索引
+}
+
+// Open 根据位置打开后端，支持本地目录（路径或 file:// 开头）、s3://<bucket>/<前缀>、rclone: 和 smb://
+// 配置了加密密钥时 S3 和 rclone 后端自动加密，本地目录和 SMB 共享需要单独开启
+func Open(location string, opts Options) (Backend, error) {
+	b, err := open(location, opts)
+	if err != nil || opts.Encryption.KeyFile == "" {
+		return b, err
+	}
+	switch b.(type) {
+	case *Local, *SMB:
+		if !opts.Encryption.Local {
+			return b, nil
+		}
+	}
+	return NewEncrypted(b, opts.Encryption, opts.IndexFile)
+}
+
+func open(location string, opts Options) (Backend, error) {
+	switch {
+	case location == "":
+		return nil, i18n.Errorf("存储位置不能为空")
+	case strings.HasPrefix(location, "file://"):
+		return NewLocal(strings.TrimPrefix(location, "file://"))
+	case strings.HasPrefix(location, "s3://"):
+		return NewS3(location, opts.S3, opts.Limiter, opts.StateFile)
+	case strings.HasPrefix(location, config.RcloneTargetPrefix):
+		return NewRclone(location, opts.Rclone, opts.Limiter)
+	case strings.HasPrefix(location, config.SMBTargetPrefix):
+		return NewSMB(location, opts.SMB, opts.Limiter)
+	case strings.Contains(location, "://"):
+		return nil, i18n.Errorf("不支持的存储位置: %s", location)
+	default:
+		return NewLocal(location)
+	}
+}
+
+// validKey 键不能为空，也不能包含 . 或 .. 路径段，避免写到后端根目录之外
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,363 @@
+package remote
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/proxy"
+	"github.com/lucasrui/neo-nas/internal/throttle"
+)
+
+// 空内容的 SHA-256，GET、HEAD、DELETE 请求使用
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// 上传时记录内容哈希的自定义元数据
+const metaSHA256 = "X-Amz-Meta-Sha256"
+
+// S3 兼容 AWS S3 协议的对象存储，使用 Signature V4 签名
+type S3 struct {
+	endpoint     *url.URL
+	bucket       string
+	prefix       string
+	region       string
+	accessKey    string
+	secretKey    string
+	pathStyle    bool
+	storageClass string
+	lockMode     string
+	retain       time.Duration
+	partSize     int64 // 超过该大小的对象使用分块上传
+	uploads      *multipartState
+	http         *http.Client
+	limiter      *throttle.Limiter
+}
+
+// readSeekerAt 分块上传需要按偏移量读取内容
+type readSeekerAt interface {
+	io.ReadSeeker
+	io.ReaderAt
+}
+
+// NewS3 location 的格式为 s3://<bucket>/<前缀>，stateFile 记录未完成的分块上传，为空时不保存
+func NewS3(location string, cfg config.S3Config, limiter *throttle.Limiter, stateFile string) (*S3, error) {
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(location, "s3://"), "/")
+	if bucket == "" {
+		return nil, i18n.Errorf("S3 存储位置缺少存储桶: %s", location)
+	}
+	s := &S3{
+		bucket:       bucket,
+		prefix:       strings.Trim(prefix, "/"),
+		region:       cfg.Region,
+		accessKey:    cfg.AccessKey,
+		secretKey:    cfg.SecretKey,
+		pathStyle:    cfg.PathStyle,
+		storageClass: cfg.StorageClass,
+		partSize:     int64(cfg.PartMB) << 20,
+		uploads:      &multipartState{file: stateFile},
+		limiter:      limiter,
+	}
+	proxyFunc, err := proxy.Func(cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	s.http = &http.Client{Transport: &http.Transport{Proxy: proxyFunc}}
+	if s.region == "" {
+		s.region = "us-east-1"
+	}
+	if s.partSize == 0 {
+		s.partSize = defaultPartSize
+	}
+	if s.partSize < minPartSize {
+		return nil, i18n.Errorf("S3 分块大小不能小于 %d MB", minPartSize>>20)
+	}
+	if s.accessKey == "" {
+		s.accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if s.secretKey == "" {
+		s.secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if s.accessKey == "" || s.secretKey == "" {
+		return nil, i18n.Errorf("S3 存储缺少访问密钥")
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + s.region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, i18n.Errorf("S3 服务地址无效: %s", endpoint)
+	}
+	s.endpoint = u
+
+	switch mode := strings.ToUpper(cfg.ObjectLock.Mode); mode {
+	case "":
+	case "GOVERNANCE", "COMPLIANCE":
+		if cfg.ObjectLock.RetainDays <= 0 {
+			return nil, i18n.Errorf("对象锁定需要设置保留天数")
+		}
+		s.lockMode = mode
+		s.retain = time.Duration(cfg.ObjectLock.RetainDays) * 24 * time.Hour
+	default:
+		return nil, i18n.Errorf("未知的对象锁定模式: %s", cfg.ObjectLock.Mode)
+	}
+	return s, nil
+}
+
+func (s *S3) String() string {
+	if s.prefix == "" {
+		return "s3://" + s.bucket
+	}
+	return "s3://" + s.bucket + "/" + s.prefix
+}
+
+// objectURL 返回对象地址，路径中的每一段都按 S3 的规则编码
+func (s *S3) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	path := "/" + s.fullKey(key)
+	if s.pathStyle {
+		path = "/" + s.bucket + path
+	} else {
+		u.Host = s.bucket + "." + u.Host
+	}
+	u.Path = path
+	u.RawPath = uriEncode(path, false)
+	return &u
+}
+
+// Put 上传对象，开启对象锁定时同时设置保留期限，保留期内对象不能被删除或覆盖
+// 签名和对象锁定都需要提前知道内容的哈希，不能定位的 Reader 先写入临时文件
+// 超过分块大小的对象使用分块上传，中断后可以继续
+func (s *S3) Put(key string, r io.Reader) error {
+	if !validKey(key) {
+		return i18n.Errorf("对象键无效: %s", key)
+	}
+	body, ok := r.(readSeekerAt)
+	if !ok {
+		tmp, err := os.CreateTemp("", "neo-s3-*")
+		if err != nil {
+			return i18n.Errorf("创建临时文件失败: %w", err)
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if _, err := io.Copy(tmp, r); err != nil {
+			return i18n.Errorf("读取上传内容失败: %w", err)
+		}
+		body = tmp
+	}
+
+	start, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	sha, md := sha256.New(), md5.New()
+	size, err := io.Copy(io.MultiWriter(sha, md), body)
+	if err != nil {
+		return i18n.Errorf("读取上传内容失败: %w", err)
+	}
+	if _, err := body.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	if size > s.partSize {
+		return s.putMultipart(key, io.NewSectionReader(body, start, size), size, hex.EncodeToString(sha.Sum(nil)))
+	}
+
+	req, err := http.NewRequest(http.MethodPut, s.objectURL(key).String(), io.NopCloser(s.limiter.Reader(body)))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(md.Sum(nil)))
+	// 记录内容哈希，上传后不需要下载就能校验
+	req.Header.Set(metaSHA256, hex.EncodeToString(sha.Sum(nil)))
+	s.setObjectHeaders(req)
+	resp, err := s.do(req, hex.EncodeToString(sha.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// setObjectHeaders 设置新对象的存储类型和对象锁定
+func (s *S3) setObjectHeaders(req *http.Request) {
+	if s.storageClass != "" {
+		req.Header.Set("X-Amz-Storage-Class", s.storageClass)
+	}
+	if s.lockMode != "" {
+		req.Header.Set("X-Amz-Object-Lock-Mode", s.lockMode)
+		req.Header.Set("X-Amz-Object-Lock-Retain-Until-Date", time.Now().Add(s.retain).UTC().Format("2006-01-02T15:04:05Z"))
+	}
+}
+
+func (s *S3) Get(key string) (io.ReadCloser, error) {
+	if !validKey(key) {
+		return nil, i18n.Errorf("对象键无效: %s", key)
+	}
+	req, err := http.NewRequest(http.MethodGet, s.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req, emptySHA256)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Stat 查询对象的大小和上传时记录的哈希
+func (s *S3) Stat(key string) (Info, error) {
+	if !validKey(key) {
+		return Info{}, i18n.Errorf("对象键无效: %s", key)
+	}
+	req, err := http.NewRequest(http.MethodHead, s.objectURL(key).String(), nil)
+	if err != nil {
+		return Info{}, err
+	}
+	resp, err := s.do(req, emptySHA256)
+	if err != nil {
+		return Info{}, err
+	}
+	resp.Body.Close()
+	return Info{Size: resp.ContentLength, SHA256: resp.Header.Get(metaSHA256)}, nil
+}
+
+func (s *S3) Delete(key string) error {
+	if !validKey(key) {
+		return i18n.Errorf("对象键无效: %s", key)
+	}
+	req, err := http.NewRequest(http.MethodDelete, s.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, emptySHA256)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do 签名并发送请求，非 2xx 响应转换为错误
+func (s *S3) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	s.sign(req, payloadHash, time.Now())
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return nil, i18n.Errorf("请求 S3 失败: %w", err)
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	var e struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if xml.Unmarshal(data, &e) == nil && e.Code != "" {
+		return nil, i18n.Errorf("S3 返回 %s: %s: %s", resp.Status, e.Code, e.Message)
+	}
+	return nil, i18n.Errorf("S3 返回 %s", resp.Status)
+}
+
+// sign 按 AWS Signature V4 签名，请求头中已有的字段全部参与签名
+func (s *S3) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vs := values[k]
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode 除字母、数字和 -._~ 外全部编码，路径中的 / 保留
+func uriEncode(v string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && !encodeSlash {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,146 @@
+package remote
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/smb"
+	"github.com/lucasrui/neo-nas/internal/throttle"
+)
+
+// TimePutter 写入对象时能保留修改时间的后端
+type TimePutter interface {
+	// PutWithModTime 与 Put 相同，写入完成后把对象的修改时间设为 modTime
+	PutWithModTime(key string, r io.Reader, modTime time.Time) error
+}
+
+// SMB 通过 SMB2/3 协议直接读写共享的后端，位置格式为 smb://<服务器>/<共享>/<路径>
+// 不挂载共享，也不需要 root 权限，登录配置使用全局的 smb
+type SMB struct {
+	client  *smb.Client
+	loc     smb.Location
+	limiter *throttle.Limiter
+}
+
+// smbClients 同一共享的多个任务共用一个连接
+var smbClients = struct {
+	sync.Mutex
+	m map[string]*smb.Client
+}{m: make(map[string]*smb.Client)}
+
+func NewSMB(location string, cfg config.SMBConfig, limiter *throttle.Limiter) (*SMB, error) {
+	loc, err := smb.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	smbClients.Lock()
+	defer smbClients.Unlock()
+	key := loc.UNC()
+	c, ok := smbClients.m[key]
+	if !ok {
+		if c, err = smb.NewClient(loc, cfg); err != nil {
+			return nil, err
+		}
+		smbClients.m[key] = c
+	}
+	return &SMB{client: c, loc: loc, limiter: limiter}, nil
+}
+
+func (s *SMB) String() string {
+	if s.loc.Path == "" {
+		return config.SMBTargetPrefix + s.loc.Server + "/" + s.loc.Share
+	}
+	return config.SMBTargetPrefix + s.loc.Server + "/" + s.loc.Share + "/" + s.loc.Path
+}
+
+// path 对象在共享中的路径
+func (s *SMB) path(key string) (string, error) {
+	if !validKey(key) {
+		return "", i18n.Errorf("对象键无效: %s", key)
+	}
+	return path.Join(s.loc.Path, key), nil
+}
+
+// Put 先写入同目录下的临时文件，落盘后改名替换
+func (s *SMB) Put(key string, r io.Reader) error {
+	return s.PutWithModTime(key, r, time.Time{})
+}
+
+// PutWithModTime 在改名之前设置临时文件的修改时间，modTime 为零值时不设置
+func (s *SMB) PutWithModTime(key string, r io.Reader, modTime time.Time) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	dir := path.Dir(p)
+	if err := s.client.MkdirAll(dir); err != nil {
+		return i18n.Errorf("创建存储目录失败: %w", err)
+	}
+	var suffix [8]byte
+	rand.Read(suffix[:])
+	tmp, err := s.client.Create(path.Join(dir, ".neo-put-"+hex.EncodeToString(suffix[:])))
+	if err != nil {
+		return i18n.Errorf("创建临时文件失败: %w", err)
+	}
+	done := false
+	defer func() {
+		if !done {
+			tmp.Remove()
+		}
+		tmp.Close()
+	}()
+
+	if _, err := io.Copy(tmp, s.limiter.Reader(r)); err != nil {
+		return i18n.Errorf("写入对象失败: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return i18n.Errorf("同步对象失败: %w", err)
+	}
+	if !modTime.IsZero() {
+		if err := tmp.SetModTime(modTime); err != nil {
+			return i18n.Errorf("设置修改时间失败: %w", err)
+		}
+	}
+	if err := tmp.Rename(p); err != nil {
+		return i18n.Errorf("保存对象失败: %w", err)
+	}
+	done = true
+	if err := tmp.Close(); err != nil {
+		return i18n.Errorf("关闭对象失败: %w", err)
+	}
+	return nil
+}
+
+func (s *SMB) Get(key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.client.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *SMB) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := s.client.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return i18n.Errorf("删除对象失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package remote
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// Streamer 可以边读取边上传、不需要事先知道大小的后端
+type Streamer interface {
+	// PutStream 写入对象，不在本地写入与对象同样大小的临时文件，读取 r 出错时不留下不完整的对象
+	PutStream(key string, r io.Reader) error
+}
+
+// PutStream 边读取边上传，后端不支持时使用 Put；本地目录和 rclone 的 Put 本身就是边读取边写入
+func PutStream(b Backend, key string, r io.Reader) error {
+	if s, ok := b.(Streamer); ok {
+		return s.PutStream(key, r)
+	}
+	return b.Put(key, r)
+}
+
+// VerifyStream 校验 PutStream 上传的对象，S3 已按分块核对内容，能直接查询时只比较大小，记录了哈希时同时比较哈希
+// 后端无法查询时下载对象重新计算
+func VerifyStream(b Backend, key string, size int64, sum string) error {
+	s, ok := b.(Stater)
+	if !ok {
+		return Verify(b, key, size, sum)
+	}
+	info, err := s.Stat(key)
+	if err == errNoStat {
+		return Verify(b, key, size, sum)
+	}
+	if err != nil {
+		return i18n.Errorf("查询远程对象失败: %w", err)
+	}
+	if info.Size != size {
+		return i18n.Errorf("远程对象大小不匹配: 期望 %d, 实际 %d", size, info.Size)
+	}
+	if info.SHA256 != "" && info.SHA256 != sum {
+		return i18n.Errorf("远程对象哈希不匹配: 期望 %s, 实际 %s", sum, info.SHA256)
+	}
+	return nil
+}
+
+// streamPartSize 流式上传时第 number 个分块的大小，每 1000 块增加一倍的基础大小，16 MB 的分块最多可以上传约 880 GB
+func streamPartSize(base int64, number int) int64 {
+	return base * int64(1+(number-1)/1000)
+}
+
+// PutStream 按分块大小读入内存后逐块上传，总大小不超过一个分块时直接上传
+// 事先不知道内容的哈希，对象不记录哈希元数据；读取出错或上传失败时放弃分块上传，存储上原有的对象保持不变
+func (s *S3) PutStream(key string, r io.Reader) error {
+	if !validKey(key) {
+		return i18n.Errorf("对象键无效: %s", key)
+	}
+	buf := make([]byte, s.partSize)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return s.Put(key, bytes.NewReader(buf[:n]))
+	}
+	if err != nil {
+		return i18n.Errorf("读取上传内容失败: %w", err)
+	}
+
+	uploadID, err := s.createMultipart(key, "")
+	if err != nil {
+		return err
+	}
+	fail := func(err error) error {
+		if aerr := s.abortMultipart(key, uploadID); aerr != nil {
+			logger.Errorf("放弃分块上传失败 %s/%s: %v", s, key, aerr)
+		}
+		return err
+	}
+	var etags []string
+	last := false
+	for number := 1; ; number++ {
+		if number > maxParts {
+			return fail(i18n.Errorf("对象超过 S3 的分块数量上限 %d", maxParts))
+		}
+		etag, err := s.uploadPartRetry(key, uploadID, number, io.NewSectionReader(bytes.NewReader(buf[:n]), 0, int64(n)))
+		if err != nil {
+			return fail(i18n.Errorf("分块上传多次失败: %w", err))
+		}
+		etags = append(etags, etag)
+		logger.Infof("分块上传 %s/%s: %d", s, key, number)
+		if last {
+			break
+		}
+		size := streamPartSize(s.partSize, number+1)
+		if int64(cap(buf)) < size {
+			buf = make([]byte, size)
+		}
+		n, err = io.ReadFull(r, buf[:size])
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF {
+			last = true
+		} else if err != nil {
+			return fail(i18n.Errorf("读取上传内容失败: %w", err))
+		}
+	}
+	if err := s.completeMultipart(key, uploadID, etags); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// PutStream 加密后边读取边上传到内部的后端
+func (e *Encrypted) PutStream(key string, r io.Reader) error {
+	return e.put(key, r, func(stored string, body io.Reader) error {
+		return PutStream(e.inner, stored, body)
+	})
+}
+
+// killOnError 读取出错时先结束 rclone 再返回错误，rclone 在读到结尾之前已被结束，不会把不完整的内容当作完整的对象保存
+type killOnError struct {
+	r   io.Reader
+	cmd *exec.Cmd
+}
+
+func (k *killOnError) Read(p []byte) (int, error) {
+	n, err := k.r.Read(p)
+	if err != nil && err != io.EOF && k.cmd.Process != nil {
+		k.cmd.Process.Kill()
+	}
+	return n, err
+}
@@ -0,0 +1,236 @@
+package restore
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	neozip "github.com/lucasrui/neo-nas/internal/zip"
+)
+
+// RunArchive 把压缩任务生成的压缩文件中的文件解压到 opts.To，format 为压缩格式，key 为压缩时使用的密钥
+// 条目中记录了权限和修改时间时一并恢复，压缩文件中的目录（包括空目录）同样创建
+func RunArchive(archive, format, key string, opts Options) (Stats, error) {
+	var stats Stats
+	to, paths, err := check(archive, &opts)
+	if err != nil {
+		return stats, err
+	}
+	a := &archiveRestorer{
+		restorer: restorer{root: archive, to: to, opts: opts, stats: &stats},
+		paths:    paths,
+		found:    make([]bool, len(paths)),
+	}
+	switch format {
+	case config.FormatTarGz, config.FormatTarZst:
+		err = a.untar(format)
+	default:
+		err = a.unzip(key)
+	}
+	if err != nil {
+		return stats, err
+	}
+	for i, ok := range a.found {
+		if !ok && paths[i] != "." {
+			return stats, i18n.Errorf("压缩文件中不存在: %s", paths[i])
+		}
+	}
+	// 文件全部写入后从最深的目录开始设置权限和时间，避免写入文件时再次改变目录的时间
+	for i := len(a.madeDirs) - 1; i >= 0; i-- {
+		d := a.madeDirs[i]
+		dirMeta(filepath.Join(to, d.rel), d.perm, d.modTime)
+	}
+	return stats, nil
+}
+
+// archiveRestorer 按条目顺序解压压缩文件
+type archiveRestorer struct {
+	restorer
+	paths    []string
+	found    []bool
+	madeDirs []archiveDir
+}
+
+// archiveDir 压缩文件中的目录，perm 为 0 表示条目没有记录权限，modTime 为零表示没有记录时间
+type archiveDir struct {
+	rel     string
+	perm    os.FileMode
+	modTime time.Time
+}
+
+// unzip 解压 zip 格式的压缩文件
+func (a *archiveRestorer) unzip(key string) error {
+	zr, err := zip.OpenReader(a.root)
+	if err != nil {
+		return i18n.Errorf("打开压缩文件失败: %w", err)
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		rel, ok := a.selected(f.Name)
+		if !ok {
+			continue
+		}
+		// 旧版本生成的未加密条目没有记录权限和修改时间
+		var perm os.FileMode
+		if f.ExternalAttrs != 0 {
+			perm = f.Mode().Perm()
+		}
+		var modTime time.Time
+		if f.ModifiedDate != 0 {
+			modTime = f.Modified
+		}
+		if strings.HasSuffix(f.Name, "/") {
+			a.mkdir(rel, perm, modTime)
+			continue
+		}
+		a.entry(rel, int64(f.UncompressedSize64), perm, modTime, func() (io.ReadCloser, error) {
+			return neozip.OpenEntry(f, key)
+		})
+	}
+	return nil
+}
+
+// untar 解压 tar.gz 或 tar.zst 格式的压缩文件，读完后核对整个压缩数据的校验码
+func (a *archiveRestorer) untar(format string) error {
+	in, err := neozip.OpenTar(format, a.root)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tr := tar.NewReader(in)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return i18n.Errorf("读取压缩文件失败: %w", err)
+		}
+		rel, ok := a.selected(hdr.Name)
+		if !ok {
+			continue
+		}
+		perm := os.FileMode(hdr.Mode).Perm()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			a.mkdir(rel, perm, hdr.ModTime)
+		case tar.TypeReg:
+			a.entry(rel, hdr.Size, perm, hdr.ModTime, func() (io.ReadCloser, error) {
+				return io.NopCloser(tr), nil
+			})
+		default:
+			logger.Warnf("跳过压缩文件中不支持的条目: %s", hdr.Name)
+			a.stats.Skipped++
+		}
+	}
+	if err := in.Finish(); err != nil {
+		return i18n.Errorf("压缩文件已损坏，已恢复的文件可能不完整: %w", err)
+	}
+	return nil
+}
+
+// selected 检查条目的路径并判断是否需要恢复，返回条目在压缩文件中的相对路径
+func (a *archiveRestorer) selected(name string) (string, bool) {
+	rel := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(rel) || filepath.VolumeName(rel) != "" || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		logger.Warnf("跳过压缩文件中路径无效的条目: %s", name)
+		a.stats.Failed++
+		return "", false
+	}
+	selected := false
+	for i, p := range a.paths {
+		if p == "." || rel == p || strings.HasPrefix(rel, p+string(filepath.Separator)) {
+			a.found[i], selected = true, true
+		}
+	}
+	if !selected || a.opts.Match != nil && !a.opts.Match(rel) {
+		return "", false
+	}
+	return rel, true
+}
+
+// mkdir 创建压缩文件中的目录，权限和时间在文件全部写入后设置
+func (a *archiveRestorer) mkdir(rel string, perm os.FileMode, modTime time.Time) {
+	if a.opts.DryRun {
+		return
+	}
+	if err := os.MkdirAll(filepath.Join(a.to, rel), 0755); err != nil {
+		logger.Errorf("创建目录失败 %s: %v", rel, err)
+		a.stats.Failed++
+		return
+	}
+	a.madeDirs = append(a.madeDirs, archiveDir{rel: rel, perm: perm, modTime: modTime})
+}
+
+// dirMeta 按条目设置目录的权限和修改时间
+func dirMeta(dst string, perm os.FileMode, modTime time.Time) {
+	if perm != 0 {
+		if err := os.Chmod(dst, perm); err != nil {
+			logger.Errorf("设置目录权限失败: %v", err)
+		}
+	}
+	if !modTime.IsZero() {
+		if err := os.Chtimes(dst, modTime, modTime); err != nil {
+			logger.Errorf("设置目录时间失败: %v", err)
+		}
+	}
+}
+
+// entry 解压一个文件条目，open 打开条目的内容
+func (a *archiveRestorer) entry(rel string, size int64, perm os.FileMode, modTime time.Time, open func() (io.ReadCloser, error)) {
+	dst := filepath.Join(a.to, rel)
+	if !a.prepare(dst, size, modTime) {
+		return
+	}
+	if err := extract(open, dst, perm, modTime); err != nil {
+		logger.Errorf("恢复文件失败 %s: %v", rel, err)
+		a.stats.Failed++
+		return
+	}
+	a.stats.Restored++
+	a.stats.Bytes += size
+	logger.Infof("已恢复: %s", dst)
+}
+
+// extract 先写入临时文件再替换，解密校验失败时不会留下内容错误的文件
+func extract(open func() (io.ReadCloser, error), dst string, perm os.FileMode, modTime time.Time) error {
+	in, err := open()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".neo-restore-*")
+	if err != nil {
+		return i18n.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if _, err := io.Copy(tmp, in); err != nil {
+		return i18n.Errorf("解压文件内容失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return i18n.Errorf("关闭文件失败: %w", err)
+	}
+	// 条目没有记录权限时使用 0644
+	if perm == 0 {
+		perm = 0644
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		logger.Errorf("设置文件权限失败: %v", err)
+	}
+	if !modTime.IsZero() {
+		if err := os.Chtimes(tmp.Name(), modTime, modTime); err != nil {
+			logger.Errorf("设置文件时间失败: %v", err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return i18n.Errorf("写入文件失败: %w", err)
+	}
+	return nil
+}
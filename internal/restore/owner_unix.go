@@ -0,0 +1,19 @@
+//go:build !windows
+
+package restore
+
+import (
+	"os"
+	"syscall"
+)
+
+// copyOwner 以 root 运行时恢复备份文件的所有者，普通用户运行时保留为当前用户
+func copyOwner(path string, info os.FileInfo) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || os.Geteuid() != 0 {
+		return
+	}
+	if err := os.Lchown(path, int(st.Uid), int(st.Gid)); err != nil {
+		logger.Errorf("设置文件所有者失败: %v", err)
+	}
+}
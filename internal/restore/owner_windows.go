@@ -0,0 +1,6 @@
+package restore
+
+import "os"
+
+// copyOwner Windows 上不恢复所有者，文件归当前用户所有
+func copyOwner(path string, info os.FileInfo) {}
@@ -0,0 +1,345 @@
+package restore
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/catalog"
+	"github.com/lucasrui/neo-nas/internal/checksum"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+	"github.com/lucasrui/neo-nas/internal/remote"
+	"github.com/lucasrui/neo-nas/internal/sparse"
+	"github.com/lucasrui/neo-nas/internal/tier"
+	"github.com/lucasrui/neo-nas/internal/xattr"
+)
+
+// logger restore 模块的日志
+var logger = logging.New("restore")
+
+// 目标位置已有同名文件时的处理策略
+const (
+	OverwriteNever  = "never"  // 保留已有的文件（默认）
+	OverwriteNewer  = "newer"  // 备份中的版本较新时覆盖
+	OverwriteAlways = "always" // 总是覆盖
+)
+
+// Options 一次恢复的参数
+type Options struct {
+	To        string                // 恢复到的目录
+	Paths     []string              // 要恢复的文件或目录，相对于备份根目录，为空表示全部
+	Overwrite string                // 已有文件的处理策略
+	DryRun    bool                  // 只输出将要恢复的文件，不写入
+	Remote    remote.Options        // 已迁移到冷存储的文件从冷存储取回
+	Match     func(rel string) bool // 只恢复相对路径满足条件的文件，为 nil 时恢复全部
+
+	// 按时间点恢复：只恢复 AsOf 之前已备份的文件，备份时间取自 Catalog
+	// Snapshot 为 AsOf 之前最近的快照中对应的目录，补充此后已从目标目录删除的文件
+	AsOf     time.Time
+	Catalog  *catalog.Catalog
+	Snapshot string
+}
+
+// Stats 恢复结果
+type Stats struct {
+	Restored int
+	Skipped  int
+	Failed   int
+	Bytes    int64
+}
+
+// Run 把 root（目标目录或快照中对应的目录）中的文件复制到 opts.To，保留权限、修改时间和所有者
+func Run(root string, opts Options) (Stats, error) {
+	var stats Stats
+	to, paths, err := check(root, &opts)
+	if err != nil {
+		return stats, err
+	}
+	r := &restorer{root: root, to: to, opts: opts, stats: &stats, seen: make(map[string]bool)}
+	for _, rel := range paths {
+		if err := r.restore(rel); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// check 检查覆盖策略和恢复目录，返回恢复目录的绝对路径和要恢复的相对路径
+func check(root string, opts *Options) (string, []string, error) {
+	switch opts.Overwrite {
+	case "":
+		opts.Overwrite = OverwriteNever
+	case OverwriteNever, OverwriteNewer, OverwriteAlways:
+	default:
+		return "", nil, i18n.Errorf("未知的覆盖策略: %s", opts.Overwrite)
+	}
+	if opts.To == "" {
+		return "", nil, i18n.Errorf("需要指定恢复到的目录")
+	}
+	to, err := filepath.Abs(opts.To)
+	if err != nil {
+		return "", nil, i18n.Errorf("解析恢复目录失败: %w", err)
+	}
+	if within(to, root) {
+		return "", nil, i18n.Errorf("恢复目录不能位于备份目录中: %s", to)
+	}
+
+	paths := opts.Paths
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	rels := make([]string, 0, len(paths))
+	for _, p := range paths {
+		rel := filepath.Clean(filepath.FromSlash(p))
+		if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", nil, i18n.Errorf("路径必须相对于备份目录: %s", p)
+		}
+		rels = append(rels, rel)
+	}
+	return to, rels, nil
+}
+
+type restorer struct {
+	root  string
+	to    string
+	opts  Options
+	stats *Stats
+	dirs  []dir           // 文件全部写入后再设置目录时间
+	seen  map[string]bool // 按时间点恢复时已从目标目录处理的文件，快照中的同名文件不再处理
+}
+
+// dir 恢复的目录及其在备份中的位置
+type dir struct {
+	base string
+	rel  string
+}
+
+func (r *restorer) restore(rel string) error {
+	r.dirs = r.dirs[:0]
+	found, err := r.walk(r.root, rel)
+	if err != nil {
+		return err
+	}
+	if r.opts.Snapshot != "" {
+		inSnapshot, err := r.walk(r.opts.Snapshot, rel)
+		if err != nil {
+			return err
+		}
+		found = found || inSnapshot
+	}
+	if !found {
+		return i18n.Errorf("备份中不存在: %s", rel)
+	}
+	if r.opts.DryRun {
+		return nil
+	}
+	// 从最深的目录开始设置时间，避免写入子目录时再次改变上层目录的时间
+	for i := len(r.dirs) - 1; i >= 0; i-- {
+		d := r.dirs[i]
+		if info, err := os.Stat(filepath.Join(d.base, d.rel)); err == nil {
+			dst := filepath.Join(r.to, d.rel)
+			if _, err := os.Stat(dst); err == nil {
+				os.Chtimes(dst, info.ModTime(), info.ModTime())
+			}
+		}
+	}
+	return nil
+}
+
+// walk 恢复 base 下的 rel，rel 在 base 中不存在时返回 false
+func (r *restorer) walk(base, rel string) (bool, error) {
+	src := filepath.Join(base, rel)
+	info, err := os.Lstat(src)
+	if err != nil {
+		// 只剩冷存储记录文件时按原文件恢复
+		if os.IsNotExist(err) && tier.HasStub(src) {
+			r.candidate(base, rel, nil)
+			return true, nil
+		}
+		return false, nil
+	}
+	if !info.IsDir() {
+		r.candidate(base, rel, info)
+		return true, nil
+	}
+
+	err = filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			logger.Errorf("访问路径失败 %s: %v", p, err)
+			r.stats.Failed++
+			return nil
+		}
+		// 跳过程序内部使用的目录和文件，例如快照、校验文件和临时文件
+		if p != src && strings.HasPrefix(d.Name(), ".neo-") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		sub, err := filepath.Rel(base, p)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			r.dirs = append(r.dirs, dir{base, sub})
+			return nil
+		}
+		if name, ok := strings.CutSuffix(sub, tier.StubSuffix); ok {
+			if _, err := os.Lstat(filepath.Join(base, name)); os.IsNotExist(err) {
+				r.candidate(base, name, nil)
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || checksum.IsSumsFile(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			r.stats.Failed++
+			return nil
+		}
+		r.candidate(base, sub, info)
+		return nil
+	})
+	return true, err
+}
+
+// candidate 按时间点恢复时跳过恢复时间点之后才备份的文件，再恢复文件
+func (r *restorer) candidate(base, rel string, info os.FileInfo) {
+	if r.opts.Match != nil && !r.opts.Match(rel) {
+		return
+	}
+	src := filepath.Join(base, rel)
+	var stub *tier.Stub
+	if info == nil {
+		var err error
+		if stub, err = tier.ReadStub(src); err != nil {
+			logger.Errorf("读取冷存储记录失败 %s: %v", src, err)
+			r.stats.Failed++
+			return
+		}
+	}
+	if !r.opts.AsOf.IsZero() {
+		if base != r.root {
+			// 快照创建于恢复时间点之前，其中的文件都已存在，目标目录中已处理过的以目标目录为准
+			if r.seen[rel] {
+				return
+			}
+		} else if !r.backedUpBy(src, info, stub) {
+			return
+		}
+		r.seen[rel] = true
+	}
+	r.file(src, rel, info, stub)
+}
+
+// backedUpBy 判断目标目录中的文件是否在恢复时间点之前已经备份
+// 目录索引中没有记录时按修改时间判断，修改时间在时间点之后的文件当时一定还没有备份
+func (r *restorer) backedUpBy(src string, info os.FileInfo, stub *tier.Stub) bool {
+	if r.opts.Catalog != nil {
+		if e, ok := r.opts.Catalog.Get(src); ok && !e.BackupTime.IsZero() {
+			return !e.BackupTime.After(r.opts.AsOf)
+		}
+	}
+	_, modTime := fileMeta(info, stub)
+	return !modTime.After(r.opts.AsOf)
+}
+
+// file 恢复一个文件，stub 不为 nil 表示文件已迁移到冷存储，从记录文件中取得大小和时间
+func (r *restorer) file(src, rel string, info os.FileInfo, stub *tier.Stub) {
+	dst := filepath.Join(r.to, rel)
+	size, modTime := fileMeta(info, stub)
+	if !r.prepare(dst, size, modTime) {
+		return
+	}
+
+	var err error
+	if stub != nil {
+		err = tier.RecallTo(src, dst, r.opts.Remote)
+	} else {
+		err = copyFile(src, dst, info)
+	}
+	if err != nil {
+		logger.Errorf("恢复文件失败 %s: %v", rel, err)
+		r.stats.Failed++
+		return
+	}
+	r.stats.Restored++
+	r.stats.Bytes += size
+	logger.Infof("已恢复: %s", dst)
+}
+
+// prepare 按覆盖策略和演练模式判断是否需要写入 dst，需要时创建所在的目录
+func (r *restorer) prepare(dst string, size int64, modTime time.Time) bool {
+	if existing, err := os.Stat(dst); err == nil {
+		skip := r.opts.Overwrite == OverwriteNever ||
+			r.opts.Overwrite == OverwriteNewer && !modTime.After(existing.ModTime())
+		if skip || existing.IsDir() {
+			r.stats.Skipped++
+			return false
+		}
+	}
+	if r.opts.DryRun {
+		i18n.Printf("将恢复: %s (%d 字节)\n", dst, size)
+		r.stats.Restored++
+		r.stats.Bytes += size
+		return false
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		logger.Errorf("创建目录失败 %s: %v", filepath.Dir(dst), err)
+		r.stats.Failed++
+		return false
+	}
+	return true
+}
+
+func fileMeta(info os.FileInfo, stub *tier.Stub) (int64, time.Time) {
+	if stub != nil {
+		return stub.Size, stub.ModTime
+	}
+	return info.Size(), info.ModTime()
+}
+
+// copyFile 先写入临时文件再替换，中途失败不会留下不完整的文件
+func copyFile(src, dst string, info os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return i18n.Errorf("打开备份文件失败: %w", err)
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".neo-restore-*")
+	if err != nil {
+		return i18n.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if _, err := sparse.Copy(tmp, in, nil); err != nil {
+		return i18n.Errorf("复制文件内容失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return i18n.Errorf("关闭文件失败: %w", err)
+	}
+	if err := xattr.Copy(src, tmp.Name()); err != nil {
+		logger.Errorf("恢复扩展属性失败 %s: %v", dst, err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		logger.Errorf("设置文件权限失败: %v", err)
+	}
+	copyOwner(tmp.Name(), info)
+	if err := os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime()); err != nil {
+		logger.Errorf("设置文件时间失败: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return i18n.Errorf("写入文件失败: %w", err)
+	}
+	return nil
+}
+
+// within 判断 p 是否为 root 或位于 root 之下
+func within(p, root string) bool {
+	rel, err := filepath.Rel(root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,6 @@
+package scrub
+
+import "os"
+
+// copyOwner Windows 上不设置所有者，文件归当前用户所有
+func copyOwner(path string, info os.FileInfo) {}
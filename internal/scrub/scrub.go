@@ -0,0 +1,299 @@
+// Package scrub 定期重新计算目标目录中已备份文件的哈希，与目录索引中记录的哈希比较，发现静默损坏的文件
+// 损坏的文件可以按恢复数据修复，或者在源文件没有变化时重新复制
+package scrub
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/catalog"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+	"github.com/lucasrui/neo-nas/internal/parity"
+	"github.com/lucasrui/neo-nas/internal/sparse"
+	"github.com/lucasrui/neo-nas/internal/tier"
+)
+
+// logger scrub 模块的日志
+var logger = logging.New("scrub")
+
+// 发现的问题类型
+const (
+	KindCorrupt = "损坏"  // 内容与记录的哈希不一致，大小和修改时间没有变化
+	KindMissing = "缺失"  // 目录索引中有记录但文件已不存在
+	KindChanged = "已修改" // 大小或修改时间与记录不一致，可能被其他程序修改，不自动修复
+	KindError   = "错误"  // 读取失败
+)
+
+// progressInterval 记录进度日志的间隔
+const progressInterval = 30 * time.Second
+
+// ErrStopped 校验被中止
+var ErrStopped = i18n.New("校验已中止")
+
+// Problem 一个有问题的文件
+type Problem struct {
+	Path     string `json:"path"`
+	Kind     string `json:"kind"`
+	Detail   string `json:"detail,omitempty"`
+	Repaired bool   `json:"repaired,omitempty"`
+	Repair   string `json:"repair,omitempty"` // 修复的方式或无法修复的原因
+}
+
+// String 路径、详情和修复结果
+func (p Problem) String() string {
+	s := p.Path
+	for _, v := range []string{p.Detail, p.Repair} {
+		if v != "" {
+			s += ", " + v
+		}
+	}
+	return s
+}
+
+// Report 一个目标目录的校验结果
+type Report struct {
+	Target   string    `json:"target"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Checked  int       `json:"checked"`
+	Bytes    int64     `json:"bytes"`
+	Problems []Problem `json:"problems"`
+}
+
+// Unrepaired 未修复的问题数量
+func (r *Report) Unrepaired() int {
+	n := 0
+	for _, p := range r.Problems {
+		if !p.Repaired {
+			n++
+		}
+	}
+	return n
+}
+
+// SaveReports 以 JSON 格式写入多个目标目录的校验结果
+func SaveReports(file string, reports []*Report) error {
+	data, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return i18n.Errorf("写入校验报告失败: %w", err)
+	}
+	return nil
+}
+
+// LoadReports 读取 SaveReports 写入的结果，文件不存在时返回 nil
+func LoadReports(file string) ([]*Report, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var reports []*Report
+	if err := json.Unmarshal(data, &reports); err != nil {
+		return nil, i18n.Errorf("解析校验报告失败: %w", err)
+	}
+	return reports, nil
+}
+
+// Options 校验的参数
+type Options struct {
+	Catalog *catalog.Catalog
+	Repair  bool            // 发现损坏或缺失时修复
+	DryRun  bool            // 只记录将要执行的修复
+	Stop    <-chan struct{} // 关闭时中止校验，返回 ErrStopped
+}
+
+// Run 校验目录索引中 target 下的所有文件
+func Run(target string, opts Options) (*Report, error) {
+	r := &Report{Target: target, Start: time.Now()}
+	entries := opts.Catalog.Entries(target)
+	last := time.Now()
+	for i, e := range entries {
+		select {
+		case <-opts.Stop:
+			r.End = time.Now()
+			return r, ErrStopped
+		default:
+		}
+		if time.Since(last) >= progressInterval {
+			logger.Infof("校验进度 %s: %d/%d 个文件", target, i, len(entries))
+			last = time.Now()
+		}
+		if e.SHA256 == "" {
+			continue
+		}
+		if p := check(e, opts); p != nil {
+			logger.Warnf("校验发现问题 [%s] %s", p.Kind, p.String())
+			r.Problems = append(r.Problems, *p)
+		}
+		r.Checked++
+		r.Bytes += e.Size
+	}
+	r.End = time.Now()
+	return r, nil
+}
+
+// check 检查一个文件，没有问题时返回 nil
+func check(e catalog.Entry, opts Options) *Problem {
+	info, err := os.Stat(e.Path)
+	if os.IsNotExist(err) {
+		// 已迁移到冷存储的文件只留下记录文件
+		if tier.HasStub(e.Path) {
+			return nil
+		}
+		p := &Problem{Path: e.Path, Kind: KindMissing}
+		repair(p, e, nil, opts)
+		return p
+	}
+	if err != nil {
+		return &Problem{Path: e.Path, Kind: KindError, Detail: err.Error()}
+	}
+	if info.Size() != e.Size || !sameTime(info.ModTime(), e.ModTime) {
+		return changed(e, opts)
+	}
+	sum, err := catalog.HashFile(e.Path)
+	if err != nil {
+		return &Problem{Path: e.Path, Kind: KindError, Detail: err.Error()}
+	}
+	if sum == e.SHA256 {
+		return nil
+	}
+	// 备份可能刚刚更新了这个文件，重新读取记录确认
+	if cur, ok := opts.Catalog.Get(e.Path); !ok || cur.SHA256 != e.SHA256 {
+		return nil
+	}
+	p := &Problem{Path: e.Path, Kind: KindCorrupt, Detail: i18n.Sprintf("记录的哈希 %s, 实际 %s", e.SHA256, sum)}
+	repair(p, e, info, opts)
+	return p
+}
+
+// changed 大小或修改时间与记录不一致，备份刚刚更新了文件时记录也已经更新
+func changed(e catalog.Entry, opts Options) *Problem {
+	cur, ok := opts.Catalog.Get(e.Path)
+	if !ok {
+		return nil
+	}
+	if info, err := os.Stat(e.Path); err == nil && (info.Size() != cur.Size || !sameTime(info.ModTime(), cur.ModTime)) {
+		return &Problem{Path: e.Path, Kind: KindChanged, Detail: i18n.Sprintf("记录的大小 %d，实际 %d", cur.Size, info.Size())}
+	}
+	return nil
+}
+
+// timeSlop FAT 等文件系统的时间精度只有 2 秒
+const timeSlop = 2 * time.Second
+
+// sameTime 比较目标文件与记录的修改时间
+func sameTime(a, b time.Time) bool {
+	d := a.Sub(b)
+	return d > -timeSlop && d < timeSlop
+}
+
+// repair 按恢复数据修复，没有恢复数据时从没有变化的源文件重新复制
+func repair(p *Problem, e catalog.Entry, info os.FileInfo, opts Options) {
+	if !opts.Repair {
+		return
+	}
+	if info != nil {
+		if _, err := os.Stat(parity.Sidecar(e.Path)); err == nil {
+			if opts.DryRun {
+				p.Repair = i18n.T("将按恢复数据修复")
+				return
+			}
+			r, err := parity.Repair(e.Path)
+			if err == nil && (r.Repaired || r.OK()) {
+				if sum, err := catalog.HashFile(e.Path); err == nil && sum == e.SHA256 {
+					p.Repaired, p.Repair = true, i18n.T("已按恢复数据修复")
+					return
+				}
+			}
+			// 恢复数据无法修复时再尝试源文件
+		}
+	}
+	if err := sourceIntact(e); err != nil {
+		p.Repair = i18n.T("无法修复: ") + err.Error()
+		return
+	}
+	if opts.DryRun {
+		p.Repair = i18n.T("将从源文件重新复制")
+		return
+	}
+	if err := recopy(e, info); err != nil {
+		p.Repair = i18n.T("无法修复: ") + err.Error()
+		return
+	}
+	p.Repaired, p.Repair = true, i18n.T("已从源文件重新复制")
+}
+
+// sourceIntact 源文件仍然存在，且大小、修改时间和内容都与备份时相同
+func sourceIntact(e catalog.Entry) error {
+	if e.Source == "" {
+		return i18n.Errorf("没有记录源文件")
+	}
+	info, err := os.Stat(e.Source)
+	if err != nil {
+		return i18n.Errorf("源文件不可用: %w", err)
+	}
+	if info.Size() != e.Size || !info.ModTime().Equal(e.ModTime) {
+		return i18n.Errorf("源文件已在备份后修改")
+	}
+	sum, err := catalog.HashFile(e.Source)
+	if err != nil {
+		return i18n.Errorf("读取源文件失败: %w", err)
+	}
+	if sum != e.SHA256 {
+		return i18n.Errorf("源文件的内容与备份时不同")
+	}
+	return nil
+}
+
+// recopy 从源文件复制到同目录下的临时文件，核对哈希后替换目标文件，info 为损坏的目标文件，缺失时为 nil
+func recopy(e catalog.Entry, info os.FileInfo) error {
+	in, err := os.Open(e.Source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(e.Path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(e.Path), ".neo-scrub-*")
+	if err != nil {
+		return i18n.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if _, err := sparse.Copy(tmp, in, nil); err != nil {
+		return i18n.Errorf("复制文件内容失败: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return i18n.Errorf("写入失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return i18n.Errorf("写入失败: %w", err)
+	}
+	if sum, err := catalog.HashFile(tmp.Name()); err != nil || sum != e.SHA256 {
+		return i18n.Errorf("重新复制后内容仍不一致")
+	}
+	mode := os.FileMode(0644)
+	if info != nil {
+		mode = info.Mode().Perm()
+		copyOwner(tmp.Name(), info)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		logger.Errorf("设置文件权限失败: %v", err)
+	}
+	if err := os.Chtimes(tmp.Name(), e.ModTime, e.ModTime); err != nil {
+		logger.Errorf("设置文件时间失败: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), e.Path); err != nil {
+		return i18n.Errorf("替换目标文件失败: %w", err)
+	}
+	return nil
+}
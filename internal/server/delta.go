@@ -0,0 +1,112 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/delta"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// BaseVersion 已有文件的版本标识，由大小和修改时间组成
+func BaseVersion(info os.FileInfo) string {
+	return fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano())
+}
+
+// handleSignatures 返回已有文件的块签名，客户端据此计算增量数据
+func (s *Server) handleSignatures(w http.ResponseWriter, r *http.Request) {
+	host, ok := s.authenticate(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	targetPath, err := s.resolveTarget(host, strings.TrimPrefix(r.URL.Path, SignaturesPath))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f, err := os.Open(targetPath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	sig, err := delta.NewSignature(f, delta.BlockSizeFor(info.Size()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set(HeaderBase, BaseVersion(info))
+	sig.WriteTo(w)
+}
+
+// handleDeltas 将增量数据与已有文件合并为新文件，已有文件在生成签名后被改动时返回 412
+func (s *Server) handleDeltas(w http.ResponseWriter, r *http.Request) {
+	host, ok := s.authenticate(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	targetPath, err := s.resolveTarget(host, strings.TrimPrefix(r.URL.Path, DeltasPath))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	modTime, mode, ok := fileHeaders(w, r)
+	if !ok {
+		return
+	}
+
+	base, err := os.Open(targetPath)
+	if err != nil {
+		http.Error(w, i18n.T("已有文件不存在"), http.StatusPreconditionFailed)
+		return
+	}
+	info, err := base.Stat()
+	if err != nil || BaseVersion(info) != r.Header.Get(HeaderBase) {
+		base.Close()
+		http.Error(w, i18n.T("已有文件已被改动"), http.StatusPreconditionFailed)
+		return
+	}
+
+	// 合并后的内容经管道交给 receive，与普通上传一样校验哈希后再替换目标文件
+	pr, pw := io.Pipe()
+	go func() {
+		err := delta.Apply(base, r.Body, pw)
+		if err == nil {
+			// 读完请求体才能拿到哈希 trailer
+			_, err = io.Copy(io.Discard, r.Body)
+		}
+		base.Close()
+		pw.CloseWithError(err)
+	}()
+	sum, size, err := s.receive(r, pr, targetPath, modTime, mode)
+	pr.Close()
+	if err != nil {
+		logger.Errorf("合并增量数据失败 %s: %v", targetPath, err)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	s.received(host, r.Header.Get(HeaderSource), targetPath, size, modTime, sum)
+	writeJSON(w, http.StatusCreated, FileInfo{Size: size, ModTime: modTime, SHA256: sum})
+}
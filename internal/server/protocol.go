@@ -0,0 +1,34 @@
+package server
+
+import "time"
+
+// 客户端与服务端之间的传输协议
+const (
+	FilesPath      = "/api/v1/files/"      // 文件元数据查询与上传，路径格式为 <命名空间>/<相对路径>
+	UploadsPath    = "/api/v1/uploads/"    // 可续传上传会话，POST 创建，HEAD 查询进度，PATCH 追加数据
+	SignaturesPath = "/api/v1/signatures/" // 已有文件的块签名，用于增量上传
+	DeltasPath     = "/api/v1/deltas/"     // 增量上传，PUT 增量数据，服务端与已有文件合并
+
+	HeaderModTime = "X-Neo-Mtime"  // 源文件修改时间，RFC3339Nano 格式
+	HeaderMode    = "X-Neo-Mode"   // 源文件权限，八进制
+	HeaderSHA256  = "X-Neo-Sha256" // 文件内容哈希，作为 trailer 在上传结束时发送
+	HeaderSource  = "X-Neo-Source" // 客户端本地的源文件路径，仅用于记录
+	HeaderTarget  = "X-Neo-Target" // 可续传上传的目标，格式为 <命名空间>/<相对路径>
+	HeaderBase    = "X-Neo-Base"   // 生成签名时已有文件的版本，增量上传时服务端据此确认文件未被改动
+
+	HeaderUploadOffset = "Upload-Offset" // 服务端已接收的字节数
+	HeaderUploadLength = "Upload-Length" // 文件总大小
+)
+
+// UploadSession 可续传上传会话
+type UploadSession struct {
+	ID     string `json:"id"`
+	Offset int64  `json:"offset"`
+}
+
+// FileInfo 服务端上已存在文件的元数据
+type FileInfo struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	SHA256  string    `json:"sha256,omitempty"`
+}
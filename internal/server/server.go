@@ -0,0 +1,268 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/catalog"
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/httpd"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/immutable"
+	"github.com/lucasrui/neo-nas/internal/logging"
+)
+
+// logger server 模块的日志
+var logger = logging.New("server")
+
+// Server 服务端模式，接收远程客户端推送的文件，按 <root>/<主机名>/<命名空间> 存放
+type Server struct {
+	root        string
+	clients     []config.ServerClient
+	catalog     *catalog.Catalog
+	immutable   bool
+	uploadLocks sync.Map // 上传会话 ID -> *sync.Mutex，同一会话的请求串行处理
+}
+
+func NewServer(cfg config.ServerConfig, cat *catalog.Catalog) (*Server, error) {
+	if cfg.Root == "" {
+		return nil, i18n.Errorf("服务端根目录不能为空")
+	}
+	if len(cfg.Clients) == 0 {
+		return nil, i18n.Errorf("服务端未配置任何客户端")
+	}
+	for _, c := range cfg.Clients {
+		if c.Token == "" || !validName(c.Host) {
+			return nil, i18n.Errorf("客户端配置无效: %q", c.Host)
+		}
+	}
+	s := &Server{
+		root:      cfg.Root,
+		clients:   cfg.Clients,
+		catalog:   cat,
+		immutable: cfg.Immutable,
+	}
+	if err := os.MkdirAll(s.uploadsDir(), 0755); err != nil {
+		return nil, i18n.Errorf("创建服务端根目录失败: %w", err)
+	}
+	s.cleanupUploads()
+	return s, nil
+}
+
+// Register 在内置 HTTP 服务上注册路由
+func (s *Server) Register(h *httpd.Server) {
+	h.Handle(FilesPath, http.HandlerFunc(s.handleFiles))
+	h.Handle(UploadsPath, http.HandlerFunc(s.handleUploads))
+	h.Handle(SignaturesPath, http.HandlerFunc(s.handleSignatures))
+	h.Handle(DeltasPath, http.HandlerFunc(s.handleDeltas))
+	logger.Infof("服务端模式已启用，接收目录: %s", s.root)
+}
+
+// authenticate 根据令牌确定客户端主机名，存放目录只由令牌决定，客户端无法冒充其他主机
+func (s *Server) authenticate(r *http.Request) (string, bool) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		return "", false
+	}
+	for _, c := range s.clients {
+		if subtle.ConstantTimeCompare([]byte(c.Token), []byte(token)) == 1 {
+			return c.Host, true
+		}
+	}
+	return "", false
+}
+
+// resolve 将请求路径转换为服务端的目标文件路径
+func (s *Server) resolve(host, urlPath string) (string, error) {
+	return s.resolveTarget(host, strings.TrimPrefix(urlPath, FilesPath))
+}
+
+// resolveTarget 将 <命名空间>/<相对路径> 转换为服务端的目标文件路径
+// 路径各段不能包含反斜杠或盘符，避免在 Windows 上跳出存放目录；程序内部使用的文件不能访问
+func (s *Server) resolveTarget(host, rel string) (string, error) {
+	parts := strings.Split(rel, "/")
+	if len(parts) < 2 || !validName(parts[0]) {
+		return "", i18n.Errorf("请求路径无效: %s", rel)
+	}
+	for _, p := range parts[1:] {
+		if p == "" || p == "." || p == ".." || strings.Contains(p, `\`) || filepath.VolumeName(p) != "" || httpd.Hidden(p) {
+			return "", i18n.Errorf("请求路径无效: %s", rel)
+		}
+	}
+	base := filepath.Join(s.root, host)
+	target := filepath.Join(base, filepath.FromSlash(rel))
+	if !strings.HasPrefix(target, base+string(filepath.Separator)) {
+		return "", i18n.Errorf("请求路径无效: %s", rel)
+	}
+	return target, nil
+}
+
+func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request) {
+	host, ok := s.authenticate(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	targetPath, err := s.resolve(host, r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.handleStat(w, targetPath)
+	case http.MethodPut:
+		s.handleUpload(w, r, host, targetPath)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleStat(w http.ResponseWriter, targetPath string) {
+	info, err := os.Stat(targetPath)
+	if err != nil || info.IsDir() {
+		http.NotFound(w, nil)
+		return
+	}
+	fi := FileInfo{Size: info.Size(), ModTime: info.ModTime()}
+	// 目录索引中的哈希只有在文件未被改动时才可信
+	if e, ok := s.catalog.Get(targetPath); ok && e.Size == fi.Size && e.ModTime.Equal(fi.ModTime) {
+		fi.SHA256 = e.SHA256
+	}
+	writeJSON(w, http.StatusOK, fi)
+}
+
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request, host, targetPath string) {
+	modTime, mode, ok := fileHeaders(w, r)
+	if !ok {
+		return
+	}
+
+	sum, size, err := s.receive(r, r.Body, targetPath, modTime, mode)
+	if err != nil {
+		logger.Errorf("接收客户端文件失败 %s: %v", targetPath, err)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	s.received(host, r.Header.Get(HeaderSource), targetPath, size, modTime, sum)
+	writeJSON(w, http.StatusCreated, FileInfo{Size: size, ModTime: modTime, SHA256: sum})
+}
+
+// fileHeaders 解析上传请求中的修改时间和权限
+func fileHeaders(w http.ResponseWriter, r *http.Request) (time.Time, os.FileMode, bool) {
+	modTime, err := time.Parse(time.RFC3339Nano, r.Header.Get(HeaderModTime))
+	if err != nil {
+		http.Error(w, i18n.T("缺少修改时间"), http.StatusBadRequest)
+		return time.Time{}, 0, false
+	}
+	mode, err := strconv.ParseUint(r.Header.Get(HeaderMode), 8, 32)
+	if err != nil {
+		mode = 0644
+	}
+	return modTime, os.FileMode(mode).Perm(), true
+}
+
+// received 文件落盘后记录到目录索引
+func (s *Server) received(host, source, targetPath string, size int64, modTime time.Time, sum string) {
+	if err := s.catalog.Put(catalog.Entry{
+		Path:       targetPath,
+		Source:     host + ":" + source,
+		Size:       size,
+		ModTime:    modTime,
+		SHA256:     sum,
+		BackupTime: time.Now(),
+	}); err != nil {
+		logger.Errorf("更新目录索引失败: %v", err)
+	}
+	logger.Infof("已接收客户端文件: %s -> %s", host, targetPath)
+}
+
+// receive 先写入同目录下的临时文件，校验哈希后再替换目标文件，避免留下不完整的文件
+// body 是文件内容，读完后从请求中获取哈希 trailer
+func (s *Server) receive(r *http.Request, body io.Reader, targetPath string, modTime time.Time, mode os.FileMode) (string, int64, error) {
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return "", 0, i18n.Errorf("创建目标目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(targetPath), ".neo-upload-*")
+	if err != nil {
+		return "", 0, i18n.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), body)
+	if err != nil {
+		return "", 0, i18n.Errorf("接收文件内容失败: %w", err)
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	// 哈希既可以作为请求头发送，也可以作为 trailer 在内容结束后发送
+	expected := r.Trailer.Get(HeaderSHA256)
+	if expected == "" {
+		expected = r.Header.Get(HeaderSHA256)
+	}
+	if expected == "" {
+		return "", 0, i18n.Errorf("缺少文件哈希")
+	}
+	if !strings.EqualFold(expected, sum) {
+		return "", 0, i18n.Errorf("文件哈希不匹配: 期望 %s, 实际 %s", expected, sum)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return "", 0, i18n.Errorf("同步临时文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", 0, i18n.Errorf("关闭临时文件失败: %w", err)
+	}
+	if err := s.install(tmp.Name(), targetPath, modTime, mode); err != nil {
+		return "", 0, err
+	}
+	return sum, size, nil
+}
+
+// install 设置临时文件的权限和时间后替换目标文件
+// 客户端上传了新版本时先清除旧文件的不可变属性，替换完成后再重新设置
+func (s *Server) install(tmpPath, targetPath string, modTime time.Time, mode os.FileMode) error {
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		logger.Errorf("设置目标文件权限失败: %v", err)
+	}
+	if err := os.Chtimes(tmpPath, modTime, modTime); err != nil {
+		logger.Errorf("设置目标文件时间失败: %v", err)
+	}
+	if err := immutable.Clear(targetPath); err != nil {
+		return i18n.Errorf("清除目标文件的不可变属性失败: %w", err)
+	}
+	if err := os.Rename(tmpPath, targetPath); err != nil {
+		return i18n.Errorf("替换目标文件失败: %w", err)
+	}
+	if s.immutable {
+		if err := immutable.Set(targetPath); err != nil {
+			logger.Errorf("设置不可变属性失败: %s: %v", targetPath, err)
+		}
+	}
+	return nil
+}
+
+// validName 主机名和命名空间只能作为单级目录名使用，不允许以点开头，避免与服务端内部目录冲突
+func validName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, ".") && !strings.ContainsAny(name, `/\`)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
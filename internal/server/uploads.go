@@ -0,0 +1,330 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/catalog"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// 未完成的上传会话保留时间，超过后清理
+const uploadExpiry = 7 * 24 * time.Hour
+
+// uploadMeta 持久化的上传会话信息，与数据文件一起存放在 <root>/.uploads 下
+type uploadMeta struct {
+	ID        string      `json:"id"`
+	Host      string      `json:"host"`
+	Target    string      `json:"target"` // <命名空间>/<相对路径>
+	Source    string      `json:"source"`
+	Length    int64       `json:"length"`
+	ModTime   time.Time   `json:"mod_time"`
+	Mode      os.FileMode `json:"mode"`
+	SHA256    string      `json:"sha256"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+func (s *Server) uploadsDir() string {
+	return filepath.Join(s.root, ".uploads")
+}
+
+func (s *Server) metaPath(id string) string {
+	return filepath.Join(s.uploadsDir(), id+".json")
+}
+
+func (s *Server) partPath(id string) string {
+	return filepath.Join(s.uploadsDir(), id+".part")
+}
+
+func (s *Server) lockUpload(id string) func() {
+	mu, _ := s.uploadLocks.LoadOrStore(id, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// handleUploads 可续传上传，协议参考 tus：
+// POST /api/v1/uploads/ 创建会话，HEAD 查询已接收的偏移量，PATCH 从该偏移量继续追加，DELETE 放弃上传
+func (s *Server) handleUploads(w http.ResponseWriter, r *http.Request) {
+	host, ok := s.authenticate(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, UploadsPath)
+	if id == "" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.createUpload(w, r, host)
+		return
+	}
+	if !validUploadID(id) {
+		http.NotFound(w, r)
+		return
+	}
+
+	unlock := s.lockUpload(id)
+	defer unlock()
+
+	meta, err := s.loadUpload(id)
+	if err != nil || meta.Host != host {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		offset, err := s.uploadOffset(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(HeaderUploadOffset, strconv.FormatInt(offset, 10))
+		w.Header().Set(HeaderUploadLength, strconv.FormatInt(meta.Length, 10))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPatch:
+		s.appendUpload(w, r, meta)
+	case http.MethodDelete:
+		s.removeUpload(id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) createUpload(w http.ResponseWriter, r *http.Request, host string) {
+	length, err := strconv.ParseInt(r.Header.Get(HeaderUploadLength), 10, 64)
+	if err != nil || length < 0 {
+		http.Error(w, i18n.T("文件大小无效"), http.StatusBadRequest)
+		return
+	}
+	target := r.Header.Get(HeaderTarget)
+	if _, err := s.resolveTarget(host, target); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	modTime, err := time.Parse(time.RFC3339Nano, r.Header.Get(HeaderModTime))
+	if err != nil {
+		http.Error(w, i18n.T("缺少修改时间"), http.StatusBadRequest)
+		return
+	}
+	mode, err := strconv.ParseUint(r.Header.Get(HeaderMode), 8, 32)
+	if err != nil {
+		mode = 0644
+	}
+	sum := strings.ToLower(r.Header.Get(HeaderSHA256))
+	if len(sum) != 64 {
+		http.Error(w, i18n.T("缺少文件哈希"), http.StatusBadRequest)
+		return
+	}
+
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	meta := &uploadMeta{
+		ID:        hex.EncodeToString(idBytes),
+		Host:      host,
+		Target:    target,
+		Source:    r.Header.Get(HeaderSource),
+		Length:    length,
+		ModTime:   modTime,
+		Mode:      os.FileMode(mode).Perm(),
+		SHA256:    sum,
+		CreatedAt: time.Now(),
+	}
+	if err := s.saveUpload(meta); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	part, err := os.Create(s.partPath(meta.ID))
+	if err != nil {
+		s.removeUpload(meta.ID)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	part.Close()
+
+	logger.Infof("创建上传会话: %s, 主机: %s, 目标: %s, 大小: %d", meta.ID, host, target, length)
+	w.Header().Set("Location", UploadsPath+meta.ID)
+	writeJSON(w, http.StatusCreated, UploadSession{ID: meta.ID})
+}
+
+// appendUpload 只接受从当前偏移量开始的数据，偏移量不一致时返回 409，客户端需重新查询
+func (s *Server) appendUpload(w http.ResponseWriter, r *http.Request, meta *uploadMeta) {
+	offset, err := strconv.ParseInt(r.Header.Get(HeaderUploadOffset), 10, 64)
+	if err != nil {
+		http.Error(w, i18n.T("偏移量无效"), http.StatusBadRequest)
+		return
+	}
+	current, err := s.uploadOffset(meta.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if offset != current {
+		w.Header().Set(HeaderUploadOffset, strconv.FormatInt(current, 10))
+		http.Error(w, i18n.T("偏移量不一致"), http.StatusConflict)
+		return
+	}
+
+	part, err := os.OpenFile(s.partPath(meta.ID), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// 连接中断时已写入的部分同样有效，下次从新的偏移量继续
+	n, copyErr := io.Copy(part, io.LimitReader(r.Body, meta.Length-current))
+	syncErr := part.Sync()
+	part.Close()
+	current += n
+	w.Header().Set(HeaderUploadOffset, strconv.FormatInt(current, 10))
+	if copyErr != nil || syncErr != nil {
+		http.Error(w, i18n.T("接收数据中断"), http.StatusInternalServerError)
+		return
+	}
+
+	if current < meta.Length {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	fi, err := s.finishUpload(meta)
+	if err != nil {
+		logger.Errorf("完成上传失败 %s: %v", meta.ID, err)
+		s.removeUpload(meta.ID)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	writeJSON(w, http.StatusCreated, fi)
+}
+
+// finishUpload 校验完整文件的哈希后移动到目标位置
+func (s *Server) finishUpload(meta *uploadMeta) (*FileInfo, error) {
+	targetPath, err := s.resolveTarget(meta.Host, meta.Target)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := catalog.HashFile(s.partPath(meta.ID))
+	if err != nil {
+		return nil, i18n.Errorf("计算文件哈希失败: %w", err)
+	}
+	if sum != meta.SHA256 {
+		return nil, i18n.Errorf("文件哈希不匹配: 期望 %s, 实际 %s", meta.SHA256, sum)
+	}
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return nil, i18n.Errorf("创建目标目录失败: %w", err)
+	}
+	// 上传目录与目标可能不在同一文件系统，先移动到目标目录旁再替换
+	tmpPath := filepath.Join(filepath.Dir(targetPath), ".neo-upload-"+meta.ID)
+	if err := moveFile(s.partPath(meta.ID), tmpPath); err != nil {
+		return nil, err
+	}
+	if err := s.install(tmpPath, targetPath, meta.ModTime, meta.Mode); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	os.Remove(s.metaPath(meta.ID))
+	s.received(meta.Host, meta.Source, targetPath, meta.Length, meta.ModTime, sum)
+	return &FileInfo{Size: meta.Length, ModTime: meta.ModTime, SHA256: sum}, nil
+}
+
+func (s *Server) uploadOffset(id string) (int64, error) {
+	info, err := os.Stat(s.partPath(id))
+	if err != nil {
+		return 0, i18n.Errorf("读取上传数据失败: %w", err)
+	}
+	return info.Size(), nil
+}
+
+func (s *Server) loadUpload(id string) (*uploadMeta, error) {
+	data, err := os.ReadFile(s.metaPath(id))
+	if err != nil {
+		return nil, err
+	}
+	var meta uploadMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+func (s *Server) saveUpload(meta *uploadMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.metaPath(meta.ID), data, 0644)
+}
+
+func (s *Server) removeUpload(id string) {
+	os.Remove(s.partPath(id))
+	os.Remove(s.metaPath(id))
+}
+
+// cleanupUploads 清理过期的上传会话
+func (s *Server) cleanupUploads() {
+	entries, err := os.ReadDir(s.uploadsDir())
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok {
+			continue
+		}
+		meta, err := s.loadUpload(id)
+		if err != nil || time.Since(meta.CreatedAt) > uploadExpiry {
+			logger.Infof("清理过期的上传会话: %s", id)
+			s.removeUpload(id)
+		}
+	}
+}
+
+func validUploadID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// moveFile 优先重命名，跨文件系统时复制后删除
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return i18n.Errorf("打开上传数据失败: %w", err)
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return i18n.Errorf("创建临时文件失败: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return i18n.Errorf("移动上传数据失败: %w", err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return i18n.Errorf("同步临时文件失败: %w", err)
+	}
+	out.Close()
+	return os.Remove(src)
+}
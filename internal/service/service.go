@@ -0,0 +1,12 @@
+package service
+
+import "github.com/lucasrui/neo-nas/internal/i18n"
+
+// Name 默认的服务名称
+const Name = "neo-nas"
+
+// ErrUnsupported 当前平台不支持以系统服务方式运行
+var ErrUnsupported = i18n.New("当前平台不支持 Windows 服务")
+
+// RunFunc 服务的主体，stop 关闭时应尽快停止并返回退出码
+type RunFunc func(stop <-chan struct{}) int
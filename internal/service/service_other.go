@@ -0,0 +1,18 @@
+//go:build !windows
+
+package service
+
+// Run 非 Windows 平台不支持
+func Run(name string, run RunFunc) error { return ErrUnsupported }
+
+// Install 非 Windows 平台不支持
+func Install(name, exe string, args []string) error { return ErrUnsupported }
+
+// Uninstall 非 Windows 平台不支持
+func Uninstall(name string) error { return ErrUnsupported }
+
+// Start 非 Windows 平台不支持
+func Start(name string) error { return ErrUnsupported }
+
+// Stop 非 Windows 平台不支持
+func Stop(name string) error { return ErrUnsupported }
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+)
+
+// logger service 模块的日志
+var logger = logging.New("service")
+
+var (
+	advapi32                       = syscall.NewLazyDLL("advapi32.dll")
+	procStartServiceCtrlDispatcher = advapi32.NewProc("StartServiceCtrlDispatcherW")
+	procRegisterServiceCtrlHandler = advapi32.NewProc("RegisterServiceCtrlHandlerExW")
+	procSetServiceStatus           = advapi32.NewProc("SetServiceStatus")
+)
+
+const (
+	serviceWin32OwnProcess = 0x10
+
+	stateStopped      = 1
+	stateStartPending = 2
+	stateStopPending  = 3
+	stateRunning      = 4
+
+	acceptStop     = 0x1
+	acceptShutdown = 0x4
+
+	controlStop     = 1
+	controlShutdown = 5
+
+	errorServiceSpecific = 1066
+)
+
+// serviceStatus 对应 SERVICE_STATUS
+type serviceStatus struct {
+	ServiceType             uint32
+	CurrentState            uint32
+	ControlsAccepted        uint32
+	Win32ExitCode           uint32
+	ServiceSpecificExitCode uint32
+	CheckPoint              uint32
+	WaitHint                uint32
+}
+
+// tableEntry 对应 SERVICE_TABLE_ENTRYW
+type tableEntry struct {
+	name *uint16
+	proc uintptr
+}
+
+// 服务控制管理器在自己的线程中回调，状态只能放在包级变量中
+var current struct {
+	name   *uint16
+	run    RunFunc
+	handle uintptr
+	stop   chan struct{}
+	once   sync.Once
+}
+
+var (
+	serviceMainCallback = syscall.NewCallback(serviceMain)
+	handlerCallback     = syscall.NewCallback(handler)
+)
+
+// Run 连接服务控制管理器并运行 run，只能由服务控制管理器启动的进程调用，run 返回后服务进入停止状态
+func Run(name string, run RunFunc) error {
+	p, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	current.name, current.run, current.stop = p, run, make(chan struct{})
+	table := []tableEntry{{name: p, proc: serviceMainCallback}, {}}
+	if r, _, err := procStartServiceCtrlDispatcher.Call(uintptr(unsafe.Pointer(&table[0]))); r == 0 {
+		return i18n.Errorf("连接服务控制管理器失败，请通过服务管理器启动: %w", err)
+	}
+	return nil
+}
+
+func serviceMain(argc, argv uintptr) uintptr {
+	h, _, err := procRegisterServiceCtrlHandler.Call(uintptr(unsafe.Pointer(current.name)), handlerCallback, 0)
+	if h == 0 {
+		logger.Errorf("注册服务控制处理函数失败: %v", err)
+		return 0
+	}
+	current.handle = h
+	setStatus(stateStartPending, 0, 0)
+	setStatus(stateRunning, acceptStop|acceptShutdown, 0)
+	code := current.run(current.stop)
+	setStatus(stateStopped, 0, uint32(code))
+	return 0
+}
+
+func handler(control, eventType, eventData, context uintptr) uintptr {
+	switch control {
+	case controlStop, controlShutdown:
+		setStatus(stateStopPending, 0, 0)
+		current.once.Do(func() { close(current.stop) })
+	}
+	return 0
+}
+
+func setStatus(state, accepts, code uint32) {
+	st := serviceStatus{ServiceType: serviceWin32OwnProcess, CurrentState: state, ControlsAccepted: accepts}
+	if code != 0 {
+		st.Win32ExitCode, st.ServiceSpecificExitCode = errorServiceSpecific, code
+	}
+	if state == stateStartPending || state == stateStopPending {
+		st.WaitHint = 60000
+	}
+	if r, _, err := procSetServiceStatus.Call(current.handle, uintptr(unsafe.Pointer(&st))); r == 0 {
+		logger.Errorf("更新服务状态失败: %v", err)
+	}
+}
+
+// Install 注册开机自动启动的服务，异常退出后 1 分钟自动重启，exe 和 args 组成服务的命令行
+func Install(name, exe string, args []string) error {
+	binPath := syscall.EscapeArg(exe)
+	for _, arg := range args {
+		binPath += " " + syscall.EscapeArg(arg)
+	}
+	if err := sc("create", name, "binPath=", binPath, "start=", "auto", "DisplayName=", "Neo NAS"); err != nil {
+		return err
+	}
+	if err := sc("description", name, i18n.T("Neo NAS 备份服务，监控源目录并自动备份")); err != nil {
+		return err
+	}
+	return sc("failure", name, "reset=", "86400", "actions=", "restart/60000/restart/60000/restart/60000")
+}
+
+// Uninstall 删除服务，正在运行的服务在停止后才会被删除
+func Uninstall(name string) error {
+	return sc("delete", name)
+}
+
+// Start 启动服务
+func Start(name string) error {
+	return sc("start", name)
+}
+
+// Stop 停止服务
+func Stop(name string) error {
+	return sc("stop", name)
+}
+
+// sc 调用 sc.exe 管理服务，需要管理员权限
+func sc(args ...string) error {
+	out, err := exec.Command("sc.exe", args...).CombinedOutput()
+	if err != nil {
+		return i18n.Errorf("sc %s 失败: %w, %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,565 @@
+package smb
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// SMB2 命令，见 MS-SMB2 2.2.1
+const (
+	cmdNegotiate    = 0x00
+	cmdSessionSetup = 0x01
+	cmdTreeConnect  = 0x03
+	cmdCreate       = 0x05
+	cmdClose        = 0x06
+	cmdFlush        = 0x07
+	cmdRead         = 0x08
+	cmdWrite        = 0x09
+	cmdSetInfo      = 0x11
+)
+
+// 消息头中的标志
+const (
+	flagResponse = 0x00000001
+	flagAsync    = 0x00000002
+	flagSigned   = 0x00000008
+)
+
+// 支持的协议版本，不支持 SMB1
+const (
+	dialect202 = 0x0202
+	dialect210 = 0x0210
+	dialect300 = 0x0300
+	dialect302 = 0x0302
+	dialect311 = 0x0311
+)
+
+var dialects = []uint16{dialect202, dialect210, dialect300, dialect302, dialect311}
+
+const (
+	securitySigningEnabled  = 0x01
+	securitySigningRequired = 0x02
+
+	capLargeMTU   = 0x00000004
+	capEncryption = 0x00000040
+
+	sessionGuest   = 0x0001
+	sessionNull    = 0x0002
+	sessionEncrypt = 0x0004
+
+	shareEncrypt = 0x00008000
+
+	cipherAES128GCM = 0x0002
+)
+
+// headerSize SMB2 消息头的长度
+const headerSize = 64
+
+// creditSize 一个 credit 可以传输的数据量，大块读写按此计算 CreditCharge
+const creditSize = 64 * 1024
+
+// maxChunk 单次读写的最大数据量
+const maxChunk = 1024 * 1024
+
+// asyncID 服务器主动发送的通知（例如 oplock 中断）使用的 MessageId
+const asyncID = 0xffffffffffffffff
+
+// Status SMB 服务器返回的 NTSTATUS 错误码
+type Status uint32
+
+const (
+	statusSuccess                Status = 0x00000000
+	statusPending                Status = 0x00000103
+	statusMoreProcessingRequired Status = 0xc0000016
+	statusEndOfFile              Status = 0xc0000011
+	statusObjectNameNotFound     Status = 0xc0000034
+	statusObjectNameCollision    Status = 0xc0000035
+	statusObjectPathNotFound     Status = 0xc000003a
+	statusAccessDenied           Status = 0xc0000022
+	statusLogonFailure           Status = 0xc000006d
+	statusBadNetworkName         Status = 0xc00000cc
+	statusNetworkSessionExpired  Status = 0xc000035c
+	statusUserSessionDeleted     Status = 0xc0000203
+)
+
+var statusNames = map[Status]string{
+	statusEndOfFile:             "STATUS_END_OF_FILE",
+	statusObjectNameNotFound:    "STATUS_OBJECT_NAME_NOT_FOUND",
+	statusObjectNameCollision:   "STATUS_OBJECT_NAME_COLLISION",
+	statusObjectPathNotFound:    "STATUS_OBJECT_PATH_NOT_FOUND",
+	statusAccessDenied:          "STATUS_ACCESS_DENIED",
+	statusLogonFailure:          "STATUS_LOGON_FAILURE",
+	statusBadNetworkName:        "STATUS_BAD_NETWORK_NAME",
+	statusNetworkSessionExpired: "STATUS_NETWORK_SESSION_EXPIRED",
+	statusUserSessionDeleted:    "STATUS_USER_SESSION_DELETED",
+	0xc0000033:                  "STATUS_OBJECT_NAME_INVALID",
+	0xc0000043:                  "STATUS_SHARING_VIOLATION",
+	0xc0000056:                  "STATUS_DELETE_PENDING",
+	0xc000007f:                  "STATUS_DISK_FULL",
+	0xc00000ba:                  "STATUS_FILE_IS_A_DIRECTORY",
+	0xc00000bb:                  "STATUS_NOT_SUPPORTED",
+	0xc0000103:                  "STATUS_NOT_A_DIRECTORY",
+}
+
+func (s Status) Error() string {
+	if name, ok := statusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("NTSTATUS 0x%08x", uint32(s))
+}
+
+// Is 文件或路径不存在的错误码与 os.ErrNotExist 相同
+func (s Status) Is(target error) bool {
+	switch target {
+	case os.ErrNotExist:
+		return s == statusObjectNameNotFound || s == statusObjectPathNotFound
+	case os.ErrExist:
+		return s == statusObjectNameCollision
+	case os.ErrPermission:
+		return s == statusAccessDenied
+	}
+	return false
+}
+
+var le = binary.LittleEndian
+
+// Client 通过 SMB2/3 协议直接访问一个共享，不需要挂载，也不需要 root 权限
+// 使用一个 TCP 连接，请求依次发送；连接断开或会话过期后，下一个请求重新连接并登录
+type Client struct {
+	server  string // 主机名或地址，可以带端口，默认 445
+	share   string
+	creds   credentials
+	timeout time.Duration
+
+	mu        sync.Mutex
+	conn      net.Conn
+	epoch     int // 每次重新连接加一，之前打开的文件句柄随之失效
+	msgID     uint64
+	credits   int
+	dialect   uint16
+	largeMTU  bool
+	maxRead   int
+	maxWrite  int
+	cipher    uint16
+	preauth   []byte // SMB 3.1.1 连接的预认证哈希
+	sessionID uint64
+	treeID    uint32
+	signer    *signer // 为空表示不签名，例如来宾登录
+	sealer    *sealer // 为空表示不加密
+	dirs      map[string]bool
+}
+
+// NewClient 创建访问 loc 所在共享的客户端，第一次请求时才连接服务器
+func NewClient(loc Location, cfg config.SMBConfig) (*Client, error) {
+	creds, err := loadCredentials(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{server: loc.Server, share: loc.Share, creds: creds, timeout: 2 * time.Minute}, nil
+}
+
+func (c *Client) String() string {
+	return "//" + c.server + "/" + c.share
+}
+
+// connect 建立连接，依次协商协议版本、登录和连接共享，调用时持有 c.mu
+func (c *Client) connect() error {
+	addr := c.server
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "445")
+	}
+	conn, err := net.DialTimeout("tcp", addr, 30*time.Second)
+	if err != nil {
+		return i18n.Errorf("连接 SMB 服务器 %s 失败: %w", addr, err)
+	}
+	c.conn = conn
+	c.epoch++
+	c.msgID, c.credits = 0, 1
+	c.sessionID, c.treeID = 0, 0
+	c.signer, c.sealer = nil, nil
+	c.dirs = make(map[string]bool)
+	if err := c.negotiate(); err != nil {
+		c.disconnect()
+		return i18n.Errorf("与 SMB 服务器 %s 协商协议失败: %w", addr, err)
+	}
+	if err := c.sessionSetup(); err != nil {
+		c.disconnect()
+		return i18n.Errorf("登录 SMB 服务器 %s 失败: %w", addr, err)
+	}
+	if err := c.treeConnect(); err != nil {
+		c.disconnect()
+		return i18n.Errorf("连接 SMB 共享 %s 失败: %w", c, err)
+	}
+	return nil
+}
+
+func (c *Client) disconnect() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// negotiate 协商协议版本，SMB 3.1.1 同时协商预认证哈希和加密算法
+func (c *Client) negotiate() error {
+	body := make([]byte, 36)
+	le.PutUint16(body[0:], 36)
+	le.PutUint16(body[2:], uint16(len(dialects)))
+	le.PutUint16(body[4:], securitySigningEnabled)
+	le.PutUint32(body[8:], capLargeMTU|capEncryption)
+	copy(body[12:28], randomBytes(16))
+	for _, d := range dialects {
+		body = le.AppendUint16(body, d)
+	}
+	body = pad8(body)
+	le.PutUint32(body[28:], uint32(headerSize+len(body)))
+	le.PutUint16(body[32:], 2)
+	// 预认证哈希：SHA-512，32 字节随机盐
+	preauth := []byte{1, 0, 32, 0, 1, 0}
+	preauth = append(preauth, randomBytes(32)...)
+	body = appendContext(body, 1, preauth)
+	body = pad8(body)
+	body = appendContext(body, 2, []byte{1, 0, byte(cipherAES128GCM), 0})
+
+	req := c.message(cmdNegotiate, 0, body)
+	resp, status, err := c.roundTrip(req, false)
+	if err != nil {
+		return err
+	}
+	if status != statusSuccess {
+		return status
+	}
+	r := resp[headerSize:]
+	if len(r) < 64 {
+		return errShort
+	}
+	c.dialect = le.Uint16(r[4:])
+	if c.dialect < dialect202 || c.dialect > dialect311 {
+		return i18n.Errorf("服务器不支持 SMB2 或更高版本的协议")
+	}
+	caps := le.Uint32(r[24:])
+	c.largeMTU = c.dialect != dialect202 && caps&capLargeMTU != 0
+	c.maxRead, c.maxWrite = int(le.Uint32(r[32:])), int(le.Uint32(r[36:]))
+	c.cipher = 0
+	if c.dialect == dialect311 {
+		h := sha512.Sum512(append(make([]byte, 64), req...))
+		h = sha512.Sum512(append(h[:], resp...))
+		c.preauth = h[:]
+		count, offset := int(le.Uint16(r[6:])), int(le.Uint32(r[60:]))
+		for i := 0; i < count && offset+8 <= len(resp); i++ {
+			typ, size := le.Uint16(resp[offset:]), int(le.Uint16(resp[offset+2:]))
+			data := resp[offset+8 : min(offset+8+size, len(resp))]
+			if typ == 2 && len(data) >= 4 && le.Uint16(data) == 1 {
+				c.cipher = le.Uint16(data[2:])
+			}
+			offset = (offset + 8 + size + 7) &^ 7
+		}
+	} else if c.dialect >= dialect300 && caps&capEncryption != 0 {
+		// SMB 3.0 和 3.0.2 只支持 AES-128-CCM 加密，这里没有实现
+		c.cipher = 1
+	}
+	return nil
+}
+
+func appendContext(b []byte, typ uint16, data []byte) []byte {
+	b = le.AppendUint16(b, typ)
+	b = le.AppendUint16(b, uint16(len(data)))
+	b = append(b, 0, 0, 0, 0)
+	return append(b, data...)
+}
+
+// pad8 按消息头之后的长度补零到 8 字节对齐
+func pad8(b []byte) []byte {
+	for (headerSize+len(b))%8 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// sessionSetup 通过 SPNEGO 包装的 NTLMv2 登录，没有配置用户名时匿名登录，之后按协议版本派生签名和加密密钥
+func (c *Client) sessionSetup() error {
+	ntlm := &ntlmClient{user: c.creds.username, password: c.creds.password, domain: c.creds.domain}
+	hash := c.preauth
+	setup := func(token []byte) ([]byte, Status, error) {
+		body := make([]byte, 24, 24+len(token))
+		le.PutUint16(body[0:], 25)
+		body[3] = securitySigningEnabled
+		le.PutUint16(body[12:], headerSize+24)
+		le.PutUint16(body[14:], uint16(len(token)))
+		body = append(body, token...)
+		// roundTrip 填写 MessageId 后才计算哈希
+		req := c.message(cmdSessionSetup, 1, body)
+		resp, status, err := c.roundTrip(req, false)
+		if hash != nil {
+			h := sha512.Sum512(append(hash, req...))
+			hash = h[:]
+		}
+		return resp, status, err
+	}
+
+	resp, status, err := setup(spnegoInit(ntlm.negotiateMessage()))
+	if err != nil {
+		return err
+	}
+	if status != statusMoreProcessingRequired {
+		return status
+	}
+	if hash != nil {
+		h := sha512.Sum512(append(hash, resp...))
+		hash = h[:]
+	}
+	c.sessionID = le.Uint64(resp[40:])
+	token, rejected, err := spnegoToken(securityBuffer(resp))
+	if err != nil {
+		return err
+	}
+	if rejected {
+		return statusLogonFailure
+	}
+	auth, err := ntlm.authenticateMessage(token)
+	if err != nil {
+		return err
+	}
+	var mic []byte
+	if ntlm.sessionKey != nil {
+		mic = ntlm.mic(mechTypes)
+	}
+	resp, status, err = setup(spnegoResponse(auth, mic))
+	if err != nil {
+		return err
+	}
+	if status != statusSuccess {
+		return status
+	}
+	flags := le.Uint16(resp[headerSize+2:])
+	if flags&(sessionGuest|sessionNull) != 0 || ntlm.sessionKey == nil {
+		if flags&sessionEncrypt != 0 {
+			return i18n.Errorf("服务器要求加密，来宾或匿名登录无法加密，请配置用户名和密码")
+		}
+		return nil
+	}
+
+	key := ntlm.sessionKey
+	switch {
+	case c.dialect == dialect311:
+		c.signer = &signer{key: kdf(key, []byte("SMBSigningKey\x00"), hash), cmac: true}
+	case c.dialect >= dialect300:
+		c.signer = &signer{key: kdf(key, []byte("SMB2AESCMAC\x00"), []byte("SmbSign\x00")), cmac: true}
+	default:
+		c.signer = &signer{key: key}
+	}
+	if le.Uint32(resp[16:])&flagSigned != 0 && !c.signer.verify(resp) {
+		return i18n.Errorf("服务器响应的签名无效")
+	}
+	if flags&sessionEncrypt != 0 {
+		if err := c.enableEncryption(key, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enableEncryption 会话或共享要求加密时派生加密密钥，只支持 SMB 3.1.1 的 AES-128-GCM
+func (c *Client) enableEncryption(key, hash []byte) error {
+	if c.sealer != nil {
+		return nil
+	}
+	if c.dialect != dialect311 || c.cipher != cipherAES128GCM {
+		return i18n.Errorf("服务器要求加密，但没有协商到支持的加密算法（只支持 SMB 3.1.1 的 AES-128-GCM）")
+	}
+	s, err := newSealer(kdf(key, []byte("SMBC2SCipherKey\x00"), hash), kdf(key, []byte("SMBS2CCipherKey\x00"), hash))
+	if err != nil {
+		return err
+	}
+	c.sealer = s
+	return nil
+}
+
+// treeConnect 连接共享，共享要求加密时之后的消息全部加密
+func (c *Client) treeConnect() error {
+	host := c.server
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	path := utf16le(`\\` + host + `\` + c.share)
+	body := make([]byte, 8, 8+len(path))
+	le.PutUint16(body[0:], 9)
+	le.PutUint16(body[4:], headerSize+8)
+	le.PutUint16(body[6:], uint16(len(path)))
+	body = append(body, path...)
+	resp, status, err := c.roundTrip(c.message(cmdTreeConnect, 1, body), true)
+	if err != nil {
+		return err
+	}
+	if status != statusSuccess {
+		return status
+	}
+	if len(resp) < headerSize+16 {
+		return errShort
+	}
+	c.treeID = le.Uint32(resp[36:])
+	if le.Uint32(resp[headerSize+4:])&shareEncrypt != 0 && c.sealer == nil {
+		return i18n.Errorf("共享要求加密，但会话没有协商加密，请配置用户名和密码并使用 SMB 3.1.1")
+	}
+	return nil
+}
+
+// securityBuffer SESSION_SETUP 响应中的安全数据
+func securityBuffer(resp []byte) []byte {
+	if len(resp) < headerSize+8 {
+		return nil
+	}
+	offset, size := int(le.Uint16(resp[headerSize+4:])), int(le.Uint16(resp[headerSize+6:]))
+	if offset+size > len(resp) {
+		return nil
+	}
+	return resp[offset : offset+size]
+}
+
+var errShort = i18n.New("SMB 响应长度不足")
+
+// charge 传输 size 字节需要的 credit 数
+func (c *Client) charge(size int) uint16 {
+	if !c.largeMTU || size <= creditSize {
+		return 1
+	}
+	return uint16((size-1)/creditSize + 1)
+}
+
+// message 生成一条请求，消息头中的 MessageId 在发送时填写
+func (c *Client) message(cmd uint16, charge uint16, body []byte) []byte {
+	msg := make([]byte, headerSize, headerSize+len(body))
+	copy(msg, "\xfeSMB")
+	le.PutUint16(msg[4:], headerSize)
+	if c.dialect != dialect202 {
+		le.PutUint16(msg[6:], charge)
+	}
+	le.PutUint16(msg[12:], cmd)
+	// 多申请一些 credit，之后的大块读写不必等待
+	le.PutUint16(msg[14:], max(charge, 1)+31)
+	le.PutUint32(msg[36:], c.treeID)
+	le.PutUint64(msg[40:], c.sessionID)
+	return append(msg, body...)
+}
+
+// roundTrip 发送一条请求并等待其响应，调用时持有 c.mu；sign 为 true 时按会话的设置签名或加密
+func (c *Client) roundTrip(req []byte, sign bool) ([]byte, Status, error) {
+	id := c.msgID
+	le.PutUint64(req[24:], id)
+	charge := int(max(le.Uint16(req[6:]), 1))
+	c.msgID += uint64(charge)
+	c.credits -= charge
+
+	frame := req
+	switch {
+	case sign && c.sealer != nil:
+		frame = c.sealer.seal(req, c.sessionID, randomBytes(12))
+	case sign && c.signer != nil:
+		c.signer.sign(req)
+	}
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	// NetBIOS 会话头：一个零字节和 24 位大端长度
+	out := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(frame)), uint32(len(frame)))
+	if _, err := c.conn.Write(append(out, frame...)); err != nil {
+		c.disconnect()
+		return nil, 0, err
+	}
+	for {
+		resp, encrypted, err := c.readMessage()
+		if err != nil {
+			c.disconnect()
+			return nil, 0, err
+		}
+		c.credits += int(le.Uint16(resp[14:]))
+		mid := le.Uint64(resp[24:])
+		status := Status(le.Uint32(resp[8:]))
+		flags := le.Uint32(resp[16:])
+		if mid == asyncID || mid != id {
+			continue
+		}
+		if status == statusPending && flags&flagAsync != 0 {
+			// 临时响应，操作完成后服务器再发送最终的响应
+			c.conn.SetDeadline(time.Now().Add(c.timeout))
+			continue
+		}
+		if !encrypted && sign && c.signer != nil && flags&flagSigned != 0 && !c.signer.verify(resp) {
+			c.disconnect()
+			return nil, 0, i18n.Errorf("服务器响应的签名无效")
+		}
+		return resp, status, nil
+	}
+}
+
+// readMessage 读取一条完整的消息，加密的消息解密后返回
+func (c *Client) readMessage() ([]byte, bool, error) {
+	var head [4]byte
+	if _, err := io.ReadFull(c.conn, head[:]); err != nil {
+		return nil, false, err
+	}
+	size := binary.BigEndian.Uint32(head[:]) & 0xffffff
+	if size < headerSize {
+		return nil, false, errShort
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.conn, data); err != nil {
+		return nil, false, err
+	}
+	encrypted := false
+	if bytes.HasPrefix(data, []byte("\xfdSMB")) {
+		if c.sealer == nil {
+			return nil, false, i18n.Errorf("收到无法解密的 SMB 消息")
+		}
+		msg, ok := c.sealer.open(data)
+		if !ok {
+			return nil, false, i18n.Errorf("解密 SMB 消息失败")
+		}
+		data, encrypted = msg, true
+	}
+	if len(data) < headerSize || !bytes.HasPrefix(data, []byte("\xfeSMB")) || le.Uint32(data[16:])&flagResponse == 0 {
+		return nil, false, i18n.Errorf("收到格式错误的 SMB 消息")
+	}
+	return data, encrypted, nil
+}
+
+// call 在当前会话中发送一条请求，尚未连接时先连接，调用时持有 c.mu
+// retry 为 true 时连接已断开或会话已过期也重新连接后再发送一次，只用于不依赖已打开句柄的请求
+func (c *Client) call(cmd uint16, size int, body []byte, retry bool) ([]byte, Status, error) {
+	for attempt := 0; ; attempt++ {
+		if c.conn == nil {
+			if err := c.connect(); err != nil {
+				return nil, 0, err
+			}
+		}
+		resp, status, err := c.roundTrip(c.message(cmd, c.charge(size), body), true)
+		if err == nil && (status == statusNetworkSessionExpired || status == statusUserSessionDeleted) {
+			c.disconnect()
+			err = status
+		}
+		if err == nil {
+			return resp, status, nil
+		}
+		if !retry || attempt > 0 {
+			return nil, 0, err
+		}
+		logger.Debugf("SMB 连接 %s 已断开，重新连接: %v", c, err)
+	}
+}
+
+// chunk 单次读写的数据量，受服务器的限制和当前的 credit 数限制
+func (c *Client) chunk(limit int) int {
+	n := min(limit, maxChunk)
+	if !c.largeMTU {
+		return min(n, creditSize)
+	}
+	return min(n, max(c.credits, 1)*creditSize)
+}
@@ -0,0 +1,158 @@
+package smb
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// cmac 计算 RFC 4493 定义的 AES-CMAC，SMB 3.x 用于消息签名，标准库中没有提供
+func cmac(key, msg []byte) []byte {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	// 子密钥：L = AES(K, 0)，K1 = L<<1，K2 = K1<<1，最高位溢出时异或 0x87
+	k1 := make([]byte, aes.BlockSize)
+	block.Encrypt(k1, k1)
+	shift := func(b []byte) {
+		carry := b[0] >> 7
+		for i := 0; i < len(b)-1; i++ {
+			b[i] = b[i]<<1 | b[i+1]>>7
+		}
+		b[len(b)-1] = b[len(b)-1]<<1 ^ 0x87*carry
+	}
+	shift(k1)
+	k2 := append([]byte(nil), k1...)
+	shift(k2)
+
+	n := (len(msg) + aes.BlockSize - 1) / aes.BlockSize
+	last := make([]byte, aes.BlockSize)
+	if n > 0 && len(msg)%aes.BlockSize == 0 {
+		copy(last, msg[(n-1)*aes.BlockSize:])
+		xor(last, k1)
+	} else {
+		if n == 0 {
+			n = 1
+		}
+		rest := msg[(n-1)*aes.BlockSize:]
+		copy(last, rest)
+		last[len(rest)] = 0x80
+		xor(last, k2)
+	}
+	x := make([]byte, aes.BlockSize)
+	for i := 0; i < n-1; i++ {
+		xor(x, msg[i*aes.BlockSize:(i+1)*aes.BlockSize])
+		block.Encrypt(x, x)
+	}
+	xor(x, last)
+	block.Encrypt(x, x)
+	return x
+}
+
+func xor(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+// kdf SMB 3.x 的密钥派生，NIST SP 800-108 计数器模式，PRF 为 HMAC-SHA256，输出 128 位
+func kdf(key, label, context []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte{0, 0, 0, 1})
+	h.Write(label)
+	h.Write([]byte{0})
+	h.Write(context)
+	h.Write([]byte{0, 0, 0, 128})
+	return h.Sum(nil)[:16]
+}
+
+// signer 计算和核对 SMB2 消息的签名，签名位于消息头的 48 到 64 字节
+type signer struct {
+	key  []byte
+	cmac bool // SMB 3.x 使用 AES-CMAC，SMB 2.x 使用 HMAC-SHA256
+}
+
+// sum 计算消息的签名，计算时签名字段视为全零
+func (s *signer) sum(msg []byte) []byte {
+	var saved [16]byte
+	copy(saved[:], msg[48:64])
+	clear(msg[48:64])
+	defer copy(msg[48:64], saved[:])
+	if s.cmac {
+		return cmac(s.key, msg)
+	}
+	h := hmac.New(sha256.New, s.key)
+	h.Write(msg)
+	return h.Sum(nil)[:16]
+}
+
+// sign 设置签名标志并写入签名
+func (s *signer) sign(msg []byte) {
+	binary.LittleEndian.PutUint32(msg[16:], binary.LittleEndian.Uint32(msg[16:])|flagSigned)
+	copy(msg[48:64], s.sum(msg))
+}
+
+// verify 核对服务器消息的签名
+func (s *signer) verify(msg []byte) bool {
+	return hmac.Equal(s.sum(msg), msg[48:64])
+}
+
+// transformHeaderSize 加密消息的 SMB2 TRANSFORM_HEADER 长度
+const transformHeaderSize = 52
+
+// sealer 使用 AES-128-GCM 加密和解密 SMB 3.1.1 的消息
+type sealer struct {
+	enc cipher.AEAD // 客户端到服务器
+	dec cipher.AEAD // 服务器到客户端
+}
+
+func newSealer(encKey, decKey []byte) (*sealer, error) {
+	enc, err := newGCM(encKey)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := newGCM(decKey)
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{enc: enc, dec: dec}, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal 把消息放入 TRANSFORM_HEADER 中加密，nonce 为 12 字节，附加数据为消息头从 nonce 开始的 32 字节
+func (s *sealer) seal(msg []byte, sessionID uint64, nonce []byte) []byte {
+	out := make([]byte, transformHeaderSize, transformHeaderSize+len(msg)+16)
+	copy(out, "\xfdSMB")
+	copy(out[20:], nonce)
+	binary.LittleEndian.PutUint32(out[36:], uint32(len(msg)))
+	binary.LittleEndian.PutUint16(out[42:], 1) // Flags: Encrypted
+	binary.LittleEndian.PutUint64(out[44:], sessionID)
+	sealed := s.enc.Seal(out[transformHeaderSize:], out[20:32], msg, out[20:transformHeaderSize])
+	// 认证标签放在消息头的签名字段中
+	tag := sealed[len(sealed)-16:]
+	copy(out[4:20], tag)
+	return out[:transformHeaderSize+len(msg)]
+}
+
+// open 解密服务器发来的加密消息
+func (s *sealer) open(data []byte) ([]byte, bool) {
+	if len(data) < transformHeaderSize {
+		return nil, false
+	}
+	ct := append(append([]byte(nil), data[transformHeaderSize:]...), data[4:20]...)
+	msg, err := s.dec.Open(nil, data[20:32], ct, data[20:transformHeaderSize])
+	if err != nil || len(msg) != int(binary.LittleEndian.Uint32(data[36:])) {
+		return nil, false
+	}
+	return msg, true
+}
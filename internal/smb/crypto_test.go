@@ -0,0 +1,78 @@
+package smb
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// RFC 1320 A.5
+func TestMD4(t *testing.T) {
+	cases := []struct{ in, sum string }{
+		{"", "31d6cfe0d16ae931b73c59d7e0c089c0"},
+		{"a", "bde52cb31de33e46245e05fbdbd6fb24"},
+		{"abc", "a448017aaf21d8525fc10ae87aa6729d"},
+		{"message digest", "d9130a8164549fe818874806e1c7014b"},
+		{"abcdefghijklmnopqrstuvwxyz", "d79e1c308aa5bbcdeea8ed63df412da9"},
+		{strings.Repeat("1234567890", 8), "e33b4ddc9c38f2199c3e7b164fcc0536"},
+	}
+	for _, c := range cases {
+		if got := md4([]byte(c.in)); hex.EncodeToString(got[:]) != c.sum {
+			t.Errorf("md4(%q) = %x, want %s", c.in, got, c.sum)
+		}
+	}
+}
+
+// RFC 4493 4
+func TestCMAC(t *testing.T) {
+	key := unhex(t, "2b7e151628aed2a6abf7158809cf4f3c")
+	msg := unhex(t, "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710")
+	cases := []struct {
+		n   int
+		mac string
+	}{
+		{0, "bb1d6929e95937287fa37d129b756746"},
+		{16, "070a16b46b4d4144f79bdd9dd04a287c"},
+		{40, "dfa66747de9ae63030ca32611497c827"},
+		{64, "51f0bebf7e3b9d92fc49741779363cfe"},
+	}
+	for _, c := range cases {
+		if got := hex.EncodeToString(cmac(key, msg[:c.n])); got != c.mac {
+			t.Errorf("cmac(%d bytes) = %s, want %s", c.n, got, c.mac)
+		}
+	}
+}
+
+// MS-NLMP 4.2.2.1.2 和 4.2.4.1.1
+func TestNTOWF(t *testing.T) {
+	if got := md4(utf16le("Password")); hex.EncodeToString(got[:]) != "a4f49c406510bdcab6824ee7c30fd852" {
+		t.Errorf("NTOWFv1 = %x", got)
+	}
+	if got := hex.EncodeToString(ntowfv2("User", "Password", "Domain")); got != "0c868a403bfd7a93a3001ef22ef02e3f" {
+		t.Errorf("NTOWFv2 = %s", got)
+	}
+}
+
+func TestSealRoundTrip(t *testing.T) {
+	a, _ := newSealer(unhex(t, "000102030405060708090a0b0c0d0e0f"), unhex(t, "0f0e0d0c0b0a09080706050403020100"))
+	b, _ := newSealer(unhex(t, "0f0e0d0c0b0a09080706050403020100"), unhex(t, "000102030405060708090a0b0c0d0e0f"))
+	msg := []byte("\xfeSMB" + strings.Repeat("x", 100))
+	sealed := a.seal(msg, 0x1122, unhex(t, "aabbccddeeff001122334455"))
+	got, ok := b.open(sealed)
+	if !ok || string(got) != string(msg) {
+		t.Fatalf("open = %q, %v", got, ok)
+	}
+	sealed[transformHeaderSize] ^= 1
+	if _, ok := b.open(sealed); ok {
+		t.Fatal("tampered message opened")
+	}
+}
@@ -0,0 +1,320 @@
+package smb
+
+import (
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// CREATE 请求的访问权限、共享方式、打开方式和选项，见 MS-SMB2 2.2.13
+const (
+	accessReadData       = 0x00000001
+	accessWriteData      = 0x00000002
+	accessReadAttributes = 0x00000080
+	accessWriteAttrs     = 0x00000100
+	accessDelete         = 0x00010000
+	accessSynchronize    = 0x00100000
+
+	shareRead   = 0x1
+	shareWrite  = 0x2
+	shareDelete = 0x4
+
+	dispositionOpen        = 1
+	dispositionOpenIf      = 3
+	dispositionOverwriteIf = 5
+
+	optionDirectory     = 0x00000001
+	optionNonDirectory  = 0x00000040
+	optionDeleteOnClose = 0x00001000
+
+	attributeNormal = 0x00000080
+)
+
+// SET_INFO 使用的文件信息类别
+const (
+	infoFile         = 1
+	classBasic       = 4 // 时间为 0 的字段不修改
+	classRename      = 10
+	classDisposition = 13
+)
+
+// File 共享中打开的文件，读写位置从头开始顺序前进
+type File struct {
+	c      *Client
+	name   string
+	id     []byte
+	epoch  int
+	offset int64
+	closed bool
+}
+
+// smbPath 把 / 分隔的路径转换为 SMB 使用的 \ 分隔的相对路径
+func smbPath(name string) string {
+	return strings.ReplaceAll(strings.Trim(name, "/"), "/", `\`)
+}
+
+// create 打开或创建 name，返回的句柄属于当前连接
+func (c *Client) create(name string, access, disposition, options uint32) (*File, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := utf16le(smbPath(name))
+	body := make([]byte, 56, 57+len(n))
+	le.PutUint16(body[0:], 57)
+	le.PutUint32(body[4:], 2) // Impersonation
+	le.PutUint32(body[24:], access)
+	if options&optionDirectory == 0 {
+		le.PutUint32(body[28:], attributeNormal)
+	}
+	le.PutUint32(body[32:], shareRead|shareWrite|shareDelete)
+	le.PutUint32(body[36:], disposition)
+	le.PutUint32(body[40:], options)
+	le.PutUint16(body[44:], headerSize+56)
+	le.PutUint16(body[46:], uint16(len(n)))
+	body = append(body, n...)
+	if len(n) == 0 {
+		body = append(body, 0)
+	}
+	resp, status, err := c.call(cmdCreate, 0, body, true)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	if status != statusSuccess {
+		return nil, &os.PathError{Op: "open", Path: name, Err: status}
+	}
+	if len(resp) < headerSize+88 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: errShort}
+	}
+	id := append([]byte(nil), resp[headerSize+64:headerSize+80]...)
+	return &File{c: c, name: name, id: id, epoch: c.epoch}, nil
+}
+
+// Open 打开文件用于读取
+func (c *Client) Open(name string) (*File, error) {
+	return c.create(name, accessReadData|accessReadAttributes|accessSynchronize, dispositionOpen, optionNonDirectory)
+}
+
+// Create 创建文件用于写入，已存在时清空；句柄带有删除权限，可以随后改名或删除
+func (c *Client) Create(name string) (*File, error) {
+	access := uint32(accessWriteData | accessReadAttributes | accessWriteAttrs | accessDelete | accessSynchronize)
+	return c.create(name, access, dispositionOverwriteIf, optionNonDirectory)
+}
+
+// Remove 删除文件
+func (c *Client) Remove(name string) error {
+	f, err := c.create(name, accessDelete|accessSynchronize, dispositionOpen, optionNonDirectory|optionDeleteOnClose)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// MkdirAll 逐级创建目录，已存在的目录不报错；创建过的目录在连接期间记住，不再重复请求
+func (c *Client) MkdirAll(dir string) error {
+	dir = strings.Trim(dir, "/")
+	if dir == "" {
+		return nil
+	}
+	parts := strings.Split(dir, "/")
+	for i := range parts {
+		p := strings.Join(parts[:i+1], "/")
+		c.mu.Lock()
+		done := c.dirs[p]
+		c.mu.Unlock()
+		if done {
+			continue
+		}
+		f, err := c.create(p, accessReadAttributes|accessSynchronize, dispositionOpenIf, optionDirectory)
+		if err != nil {
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.dirs[p] = true
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// call 发送一条使用文件句柄的请求，连接重新建立后句柄失效，不再重试
+func (f *File) call(op string, cmd uint16, size int, body []byte) ([]byte, Status, error) {
+	if f.closed || f.c.conn == nil || f.epoch != f.c.epoch {
+		return nil, 0, &os.PathError{Op: op, Path: f.name, Err: errStale}
+	}
+	resp, status, err := f.c.call(cmd, size, body, false)
+	if err != nil {
+		return nil, 0, &os.PathError{Op: op, Path: f.name, Err: err}
+	}
+	return resp, status, nil
+}
+
+var errStale = i18n.New("SMB 连接已断开，文件句柄已失效")
+
+// Read 从当前位置读取，读到文件末尾时返回 io.EOF
+func (f *File) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	f.c.mu.Lock()
+	defer f.c.mu.Unlock()
+	n := f.c.chunk(min(len(p), f.c.maxRead))
+	body := make([]byte, 49)
+	le.PutUint16(body[0:], 49)
+	body[2] = headerSize + 16
+	le.PutUint32(body[4:], uint32(n))
+	le.PutUint64(body[8:], uint64(f.offset))
+	copy(body[16:], f.id)
+	resp, status, err := f.call("read", cmdRead, n, body)
+	if err != nil {
+		return 0, err
+	}
+	if status == statusEndOfFile {
+		return 0, io.EOF
+	}
+	if status != statusSuccess {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: status}
+	}
+	if len(resp) < headerSize+16 {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: errShort}
+	}
+	offset, size := int(resp[headerSize+2]), int(le.Uint32(resp[headerSize+4:]))
+	if size == 0 {
+		return 0, io.EOF
+	}
+	if offset+size > len(resp) || size > len(p) {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: errShort}
+	}
+	copy(p, resp[offset:offset+size])
+	f.offset += int64(size)
+	return size, nil
+}
+
+// Write 从当前位置写入，数据较多时分成多次请求
+func (f *File) Write(p []byte) (int, error) {
+	f.c.mu.Lock()
+	defer f.c.mu.Unlock()
+	written := 0
+	for written < len(p) {
+		n := min(len(p)-written, f.c.chunk(f.c.maxWrite))
+		body := make([]byte, 48, 48+n)
+		le.PutUint16(body[0:], 49)
+		le.PutUint16(body[2:], headerSize+48)
+		le.PutUint32(body[4:], uint32(n))
+		le.PutUint64(body[8:], uint64(f.offset))
+		copy(body[16:], f.id)
+		body = append(body, p[written:written+n]...)
+		resp, status, err := f.call("write", cmdWrite, n, body)
+		if err != nil {
+			return written, err
+		}
+		if status != statusSuccess {
+			return written, &os.PathError{Op: "write", Path: f.name, Err: status}
+		}
+		if len(resp) < headerSize+8 {
+			return written, &os.PathError{Op: "write", Path: f.name, Err: errShort}
+		}
+		count := int(le.Uint32(resp[headerSize+4:]))
+		if count <= 0 || count > n {
+			return written, &os.PathError{Op: "write", Path: f.name, Err: io.ErrShortWrite}
+		}
+		written += count
+		f.offset += int64(count)
+	}
+	return written, nil
+}
+
+// Sync 要求服务器把写入的数据落盘
+func (f *File) Sync() error {
+	f.c.mu.Lock()
+	defer f.c.mu.Unlock()
+	body := make([]byte, 24)
+	le.PutUint16(body[0:], 24)
+	copy(body[8:], f.id)
+	_, status, err := f.call("flush", cmdFlush, 0, body)
+	if err != nil {
+		return err
+	}
+	if status != statusSuccess {
+		return &os.PathError{Op: "flush", Path: f.name, Err: status}
+	}
+	return nil
+}
+
+// setInfo 设置文件信息
+func (f *File) setInfo(op string, class byte, info []byte) error {
+	f.c.mu.Lock()
+	defer f.c.mu.Unlock()
+	body := make([]byte, 32, 32+len(info))
+	le.PutUint16(body[0:], 33)
+	body[2] = infoFile
+	body[3] = class
+	le.PutUint32(body[4:], uint32(len(info)))
+	le.PutUint16(body[8:], headerSize+32)
+	copy(body[16:], f.id)
+	body = append(body, info...)
+	_, status, err := f.call(op, cmdSetInfo, len(info), body)
+	if err != nil {
+		return err
+	}
+	if status != statusSuccess {
+		return &os.PathError{Op: op, Path: f.name, Err: status}
+	}
+	return nil
+}
+
+// Rename 把打开的文件改名为 name，已存在的同名文件被替换
+func (f *File) Rename(name string) error {
+	n := utf16le(smbPath(name))
+	info := make([]byte, 20, 24+len(n))
+	info[0] = 1 // ReplaceIfExists
+	le.PutUint32(info[16:], uint32(len(n)))
+	info = append(info, n...)
+	for len(info) < 24 {
+		info = append(info, 0)
+	}
+	if err := f.setInfo("rename", classRename, info); err != nil {
+		return err
+	}
+	f.name = name
+	return nil
+}
+
+// SetModTime 设置文件的修改时间，其他时间和属性不变
+func (f *File) SetModTime(t time.Time) error {
+	info := make([]byte, 40)
+	le.PutUint64(info[16:], filetime(t))
+	return f.setInfo("chtimes", classBasic, info)
+}
+
+// Remove 标记删除文件，关闭句柄后生效
+func (f *File) Remove() error {
+	return f.setInfo("remove", classDisposition, []byte{1})
+}
+
+// Close 关闭句柄，连接已经重新建立时服务器已经关闭了旧的句柄
+func (f *File) Close() error {
+	f.c.mu.Lock()
+	defer f.c.mu.Unlock()
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	if f.c.conn == nil || f.epoch != f.c.epoch {
+		return nil
+	}
+	body := make([]byte, 24)
+	le.PutUint16(body[0:], 24)
+	copy(body[8:], f.id)
+	_, status, err := f.c.call(cmdClose, 0, body, false)
+	if err != nil {
+		return &os.PathError{Op: "close", Path: f.name, Err: err}
+	}
+	if status != statusSuccess {
+		return &os.PathError{Op: "close", Path: f.name, Err: status}
+	}
+	return nil
+}
@@ -0,0 +1,386 @@
+package smb
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"encoding/binary"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// NTLMSSP 协商标志，见 MS-NLMP 2.2.2.5
+const (
+	ntlmUnicode          = 0x00000001
+	ntlmRequestTarget    = 0x00000004
+	ntlmSign             = 0x00000010
+	ntlmSeal             = 0x00000020
+	ntlmNTLM             = 0x00000200
+	ntlmAnonymous        = 0x00000800
+	ntlmAlwaysSign       = 0x00008000
+	ntlmExtendedSecurity = 0x00080000
+	ntlmTargetInfo       = 0x00800000
+	ntlmVersion          = 0x02000000
+	ntlm128              = 0x20000000
+	ntlmKeyExch          = 0x40000000
+	ntlm56               = 0x80000000
+
+	ntlmFlags = ntlmUnicode | ntlmRequestTarget | ntlmSign | ntlmSeal | ntlmNTLM | ntlmAlwaysSign |
+		ntlmExtendedSecurity | ntlmTargetInfo | ntlmVersion | ntlm128 | ntlmKeyExch | ntlm56
+)
+
+// NTLMSSP 目标信息中的 AV_PAIR 类型
+const (
+	avEOL       = 0
+	avFlags     = 6
+	avTimestamp = 7
+)
+
+// ntlmSignature NTLMSSP 消息的开头
+const ntlmSignature = "NTLMSSP\x00"
+
+// ntlmVersionInfo 消息中的版本信息：Windows 10.0，NTLM 修订版 15
+var ntlmVersionInfo = []byte{10, 0, 0x63, 0x45, 0, 0, 0, 15}
+
+// ntlmClient 一次 NTLMv2 认证，保存三条消息用于计算 MIC
+type ntlmClient struct {
+	user, password, domain string
+	workstation            string
+
+	negotiate  []byte
+	challenge  []byte
+	flags      uint32 // 双方协商的标志
+	sessionKey []byte // 认证完成后的导出会话密钥，匿名登录时为空
+
+	// 用于测试，默认使用随机数和当前时间
+	clientChallenge []byte
+	randomKey       []byte
+	now             func() time.Time
+}
+
+// negotiateMessage 第一条消息 NEGOTIATE_MESSAGE，不带域名和计算机名
+func (n *ntlmClient) negotiateMessage() []byte {
+	flags := uint32(ntlmFlags)
+	if n.user == "" {
+		flags |= ntlmAnonymous
+	}
+	msg := make([]byte, 40)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], flags)
+	binary.LittleEndian.PutUint32(msg[20:], 40)
+	binary.LittleEndian.PutUint32(msg[28:], 40)
+	copy(msg[32:], ntlmVersionInfo)
+	n.negotiate = msg
+	return msg
+}
+
+// authenticateMessage 根据服务器的 CHALLENGE_MESSAGE 生成 AUTHENTICATE_MESSAGE
+func (n *ntlmClient) authenticateMessage(challenge []byte) ([]byte, error) {
+	if len(challenge) < 48 || string(challenge[:8]) != ntlmSignature || binary.LittleEndian.Uint32(challenge[8:]) != 2 {
+		return nil, i18n.Errorf("服务器的 NTLM 质询格式错误")
+	}
+	n.challenge = challenge
+	flags := binary.LittleEndian.Uint32(challenge[20:]) & ntlmFlags
+	serverChallenge := challenge[24:32]
+	targetInfo, ok := field(challenge, 40)
+	if !ok {
+		return nil, i18n.Errorf("服务器的 NTLM 质询格式错误")
+	}
+
+	var lm, nt, encryptedKey []byte
+	var exportedKey []byte
+	user, domain := utf16le(n.user), utf16le(n.domain)
+	if n.user == "" {
+		// 匿名登录：LM 响应为一个零字节，NT 响应为空，没有会话密钥
+		flags |= ntlmAnonymous
+		flags &^= ntlmKeyExch
+		lm = []byte{0}
+	} else {
+		clientChallenge := n.clientChallenge
+		if clientChallenge == nil {
+			clientChallenge = randomBytes(8)
+		}
+		info, timestamp, hasTimestamp := withMICFlag(targetInfo)
+		if !hasTimestamp {
+			now := time.Now
+			if n.now != nil {
+				now = n.now
+			}
+			timestamp = filetime(now())
+		}
+		key := ntowfv2(n.user, n.password, n.domain)
+		temp := make([]byte, 0, 28+len(info)+4)
+		temp = append(temp, 1, 1, 0, 0, 0, 0, 0, 0)
+		temp = binary.LittleEndian.AppendUint64(temp, timestamp)
+		temp = append(temp, clientChallenge...)
+		temp = append(temp, 0, 0, 0, 0)
+		temp = append(temp, info...)
+		temp = append(temp, 0, 0, 0, 0)
+		proof := hmacMD5(key, serverChallenge, temp)
+		nt = append(proof, temp...)
+		if hasTimestamp {
+			// 服务器提供了时间戳时不发送 LM 响应
+			lm = make([]byte, 24)
+		} else {
+			lm = append(hmacMD5(key, serverChallenge, clientChallenge), clientChallenge...)
+		}
+		exportedKey = hmacMD5(key, proof)
+		if flags&ntlmKeyExch != 0 {
+			random := n.randomKey
+			if random == nil {
+				random = randomBytes(16)
+			}
+			c, _ := rc4.NewCipher(exportedKey)
+			encryptedKey = make([]byte, 16)
+			c.XORKeyStream(encryptedKey, random)
+			exportedKey = random
+		}
+	}
+
+	workstation := utf16le(n.workstation)
+	payload := [][]byte{lm, nt, domain, user, workstation, encryptedKey}
+	msg := make([]byte, 88)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 3)
+	offset := len(msg)
+	for i, p := range payload {
+		binary.LittleEndian.PutUint16(msg[12+i*8:], uint16(len(p)))
+		binary.LittleEndian.PutUint16(msg[14+i*8:], uint16(len(p)))
+		binary.LittleEndian.PutUint32(msg[16+i*8:], uint32(offset))
+		offset += len(p)
+	}
+	binary.LittleEndian.PutUint32(msg[60:], flags)
+	copy(msg[64:], ntlmVersionInfo)
+	for _, p := range payload {
+		msg = append(msg, p...)
+	}
+	if exportedKey != nil {
+		// MIC 覆盖三条消息，计算时 MIC 字段为零
+		copy(msg[72:88], hmacMD5(exportedKey, n.negotiate, n.challenge, msg))
+	}
+	n.flags, n.sessionKey = flags, exportedKey
+	return msg, nil
+}
+
+// mic 用客户端签名密钥签名 data，SPNEGO 的 mechListMIC 使用，序号为 0
+func (n *ntlmClient) mic(data []byte) []byte {
+	signKey := md5.Sum(append(append([]byte(nil), n.sessionKey...), "session key to client-to-server signing key magic constant\x00"...))
+	sealKey := md5.Sum(append(append([]byte(nil), n.sessionKey...), "session key to client-to-server sealing key magic constant\x00"...))
+	seq := []byte{0, 0, 0, 0}
+	checksum := hmacMD5(signKey[:], seq, data)[:8]
+	if n.flags&ntlmKeyExch != 0 {
+		c, _ := rc4.NewCipher(sealKey[:])
+		c.XORKeyStream(checksum, checksum)
+	}
+	sig := []byte{1, 0, 0, 0}
+	sig = append(sig, checksum...)
+	return append(sig, seq...)
+}
+
+// withMICFlag 复制服务器的目标信息，在 MsvAvFlags 中加上提供了 MIC 的标志，并返回其中的时间戳
+func withMICFlag(info []byte) ([]byte, uint64, bool) {
+	var out []byte
+	var timestamp uint64
+	hasTimestamp, hasFlags := false, false
+	for len(info) >= 4 {
+		id := binary.LittleEndian.Uint16(info)
+		size := int(binary.LittleEndian.Uint16(info[2:]))
+		if id == avEOL || 4+size > len(info) {
+			break
+		}
+		value := append([]byte(nil), info[4:4+size]...)
+		switch {
+		case id == avTimestamp && size == 8:
+			timestamp, hasTimestamp = binary.LittleEndian.Uint64(value), true
+		case id == avFlags && size == 4:
+			binary.LittleEndian.PutUint32(value, binary.LittleEndian.Uint32(value)|0x2)
+			hasFlags = true
+		}
+		out = appendAV(out, id, value)
+		info = info[4+size:]
+	}
+	if !hasFlags {
+		out = appendAV(out, avFlags, []byte{2, 0, 0, 0})
+	}
+	return appendAV(out, avEOL, nil), timestamp, hasTimestamp
+}
+
+func appendAV(b []byte, id uint16, value []byte) []byte {
+	b = binary.LittleEndian.AppendUint16(b, id)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(value)))
+	return append(b, value...)
+}
+
+// field 读取 NTLMSSP 消息中 offset 处的长度和偏移描述的字段
+func field(msg []byte, offset int) ([]byte, bool) {
+	size := int(binary.LittleEndian.Uint16(msg[offset:]))
+	start := int(binary.LittleEndian.Uint32(msg[offset+4:]))
+	if start+size > len(msg) || start < 0 {
+		return nil, false
+	}
+	return msg[start : start+size], true
+}
+
+// ntowfv2 NTLMv2 的密钥：HMAC_MD5(MD4(密码), 大写的用户名 + 域名)
+func ntowfv2(user, password, domain string) []byte {
+	hash := md4(utf16le(password))
+	return hmacMD5(hash[:], utf16le(strings.ToUpper(user)+domain))
+}
+
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	h := hmac.New(md5.New, key)
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+func utf16le(s string) []byte {
+	u := utf16.Encode([]rune(s))
+	b := make([]byte, len(u)*2)
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(b[i*2:], c)
+	}
+	return b
+}
+
+// filetime Windows 的时间：从 1601-01-01 起的 100 纳秒数
+func filetime(t time.Time) uint64 {
+	return uint64(t.UnixNano()/100 + 116444736000000000)
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// SPNEGO 中的对象标识，已按 DER 编码
+var (
+	oidSPNEGO = []byte{0x06, 0x06, 0x2b, 0x06, 0x01, 0x05, 0x05, 0x02}
+	oidNTLM   = []byte{0x06, 0x0a, 0x2b, 0x06, 0x01, 0x04, 0x01, 0x82, 0x37, 0x02, 0x02, 0x0a}
+)
+
+// mechTypes SPNEGO 的 MechTypeList，只提供 NTLMSSP，mechListMIC 对其签名
+var mechTypes = der(0x30, oidNTLM)
+
+// spnegoInit 把 NTLM 的第一条消息放入 SPNEGO 的 NegTokenInit
+func spnegoInit(token []byte) []byte {
+	init := der(0x30, der(0xa0, mechTypes), der(0xa2, der(0x04, token)))
+	return der(0x60, oidSPNEGO, der(0xa0, init))
+}
+
+// spnegoResponse 把 NTLM 的最后一条消息放入 SPNEGO 的 NegTokenResp，mic 为空时不带 mechListMIC
+func spnegoResponse(token, mic []byte) []byte {
+	fields := [][]byte{der(0xa2, der(0x04, token))}
+	if mic != nil {
+		fields = append(fields, der(0xa3, der(0x04, mic)))
+	}
+	return der(0xa1, der(0x30, fields...))
+}
+
+// spnegoToken 从服务器的 NegTokenResp 中取出 responseToken，并返回是否拒绝
+func spnegoToken(data []byte) ([]byte, bool, error) {
+	bad := i18n.Errorf("服务器的认证响应格式错误")
+	tag, body, ok := parseDER(data)
+	if !ok || tag != 0xa1 {
+		return nil, false, bad
+	}
+	tag, body, ok = parseDER(body)
+	if !ok || tag != 0x30 {
+		return nil, false, bad
+	}
+	var token []byte
+	rejected := false
+	for len(body) > 0 {
+		tag, value, ok := parseDER(body)
+		if !ok {
+			return nil, false, bad
+		}
+		body = body[derSize(body):]
+		switch tag {
+		case 0xa0:
+			// negState：0 完成，1 继续，2 拒绝
+			if t, v, ok := parseDER(value); ok && t == 0x0a && len(v) == 1 && v[0] == 2 {
+				rejected = true
+			}
+		case 0xa2:
+			if t, v, ok := parseDER(value); ok && t == 0x04 {
+				token = v
+			}
+		}
+	}
+	return token, rejected, nil
+}
+
+// der 按 DER 编码一个元素，内容为 parts 依次连接
+func der(tag byte, parts ...[]byte) []byte {
+	body := bytes.Join(parts, nil)
+	out := []byte{tag}
+	switch n := len(body); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n < 0x100:
+		out = append(out, 0x81, byte(n))
+	case n < 0x10000:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	default:
+		out = append(out, 0x83, byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(out, body...)
+}
+
+// parseDER 解析 data 开头的一个元素，返回标签和内容
+func parseDER(data []byte) (byte, []byte, bool) {
+	n := derSize(data)
+	if n == 0 {
+		return 0, nil, false
+	}
+	header := n - derLength(data)
+	return data[0], data[header:n], true
+}
+
+// derSize data 开头的元素的总长度，格式错误时返回 0
+func derSize(data []byte) int {
+	if len(data) < 2 {
+		return 0
+	}
+	header, length := 2, int(data[1])
+	if length >= 0x80 {
+		k := length & 0x7f
+		if k == 0 || k > 3 || len(data) < 2+k {
+			return 0
+		}
+		length = 0
+		for _, b := range data[2 : 2+k] {
+			length = length<<8 | int(b)
+		}
+		header += k
+	}
+	if header+length > len(data) {
+		return 0
+	}
+	return header + length
+}
+
+// derLength data 开头的元素的内容长度，调用前已由 derSize 检查
+func derLength(data []byte) int {
+	length := int(data[1])
+	if length < 0x80 {
+		return length
+	}
+	k := length & 0x7f
+	length = 0
+	for _, b := range data[2 : 2+k] {
+		length = length<<8 | int(b)
+	}
+	return length
+}
@@ -0,0 +1,175 @@
+package smb
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+)
+
+// logger smb 模块的日志
+var logger = logging.New("smb")
+
+// Location 解析后的 smb://<服务器>/<共享>/<路径>
+type Location struct {
+	Server string
+	Share  string
+	Path   string // 共享中的目录，/ 分隔，可以为空
+}
+
+// Parse 解析 smb:// 目标
+func Parse(target string) (Location, error) {
+	rest, ok := strings.CutPrefix(target, config.SMBTargetPrefix)
+	if !ok {
+		return Location{}, i18n.Errorf("不是 SMB 目标: %s", target)
+	}
+	parts := strings.SplitN(strings.Trim(rest, "/"), "/", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return Location{}, i18n.Errorf("SMB 目标格式应为 smb://<服务器>/<共享>/<路径>: %s", target)
+	}
+	loc := Location{Server: parts[0], Share: parts[1]}
+	if len(parts) == 3 {
+		loc.Path = path.Clean(parts[2])
+		if loc.Path == ".." || strings.HasPrefix(loc.Path, "../") {
+			return Location{}, i18n.Errorf("SMB 目标路径无效: %s", target)
+		}
+	}
+	return loc, nil
+}
+
+// UNC 共享的 //<服务器>/<共享> 形式
+func (l Location) UNC() string {
+	return "//" + l.Server + "/" + l.Share
+}
+
+// credentials 登录共享使用的用户名、密码和域
+type credentials struct {
+	username string
+	password string
+	domain   string
+}
+
+// loadCredentials 读取配置中或登录信息文件中的用户名和密码
+func loadCredentials(cfg config.SMBConfig) (credentials, error) {
+	if cfg.CredentialsFile == "" {
+		c := credentials{username: cfg.Username, password: cfg.Password, domain: cfg.Domain}
+		if c.password == "" {
+			c.password = os.Getenv("SMB_PASSWORD")
+		}
+		return c, nil
+	}
+	data, err := os.ReadFile(cfg.CredentialsFile)
+	if err != nil {
+		return credentials{}, i18n.Errorf("读取 SMB 登录信息失败: %w", err)
+	}
+	var c credentials
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return credentials{}, i18n.Errorf("SMB 登录信息格式错误: %s", cfg.CredentialsFile)
+		}
+		switch strings.TrimSpace(key) {
+		case "username", "user":
+			c.username = value
+		case "password", "pass":
+			c.password = value
+		case "domain", "workgroup":
+			c.domain = value
+		}
+	}
+	return c, nil
+}
+
+// Mounter 挂载 smb:// 目标所在的共享，同一个共享只挂载一次，停止时卸载由它挂载的共享
+type Mounter struct {
+	cfg     config.SMBConfig
+	mu      sync.Mutex
+	shares  map[string]string // 共享对应的本地路径
+	mounted []string          // 本进程挂载的共享，停止时卸载
+}
+
+// NewMounter 创建 Mounter
+func NewMounter(cfg config.SMBConfig) *Mounter {
+	return &Mounter{cfg: cfg, shares: make(map[string]string)}
+}
+
+// Enabled 是否挂载共享后按本机目录写入，未开启 smb.mount 时备份使用内置的 SMB 客户端，不需要挂载
+func (m *Mounter) Enabled() bool {
+	return m != nil && m.cfg.Mount
+}
+
+// Mount 挂载目标所在的共享，返回目标在本机上的路径，共享已经挂载时直接使用
+func (m *Mounter) Mount(target string) (string, error) {
+	loc, err := Parse(target)
+	if err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	local, ok := m.shares[loc.UNC()]
+	if !ok {
+		creds, err := loadCredentials(m.cfg)
+		if err != nil {
+			return "", err
+		}
+		var mountedNow bool
+		local, mountedNow, err = mountShare(loc, creds, m.cfg)
+		if err != nil {
+			return "", i18n.Errorf("挂载 SMB 共享 %s 失败: %w", loc.UNC(), err)
+		}
+		m.shares[loc.UNC()] = local
+		if mountedNow {
+			logger.Infof("已挂载 SMB 共享 %s 到 %s", loc.UNC(), local)
+			m.mounted = append(m.mounted, loc.UNC())
+		}
+	}
+	return filepath.Join(local, filepath.FromSlash(loc.Path)), nil
+}
+
+// UnmountAll 卸载本进程挂载的共享，其他程序或之前的运行挂载的共享保持不变
+func (m *Mounter) UnmountAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, unc := range m.mounted {
+		if err := unmountShare(m.shares[unc], unc); err != nil {
+			logger.Errorf("卸载 SMB 共享 %s 失败: %v", unc, err)
+			continue
+		}
+		logger.Infof("已卸载 SMB 共享 %s", unc)
+		delete(m.shares, unc)
+	}
+	m.mounted = nil
+}
+
+// mountPoint 共享在 mount_dir 中的挂载点
+func mountPoint(loc Location, cfg config.SMBConfig) string {
+	name := strings.NewReplacer(":", "_", `\`, "_").Replace(loc.Server + "_" + loc.Share)
+	return filepath.Join(cfg.MountDir, name)
+}
+
+// run 执行外部命令，失败时把命令输出附加到错误信息中，参数中可能有密码，不放入错误信息
+func run(env []string, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
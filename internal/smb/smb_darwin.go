@@ -0,0 +1,36 @@
+package smb
+
+import (
+	"net/url"
+	"os"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// mountShare 使用 mount_smbfs 把共享挂载到 mount_dir 下
+// 未配置密码时使用钥匙串中保存的密码，配置的密码需要放在地址中传给 mount_smbfs
+func mountShare(loc Location, creds credentials, cfg config.SMBConfig) (string, bool, error) {
+	mp := mountPoint(loc, cfg)
+	if err := os.MkdirAll(mp, 0700); err != nil {
+		return "", false, i18n.Errorf("创建挂载点失败: %w", err)
+	}
+	if isMountPoint(mp) {
+		return mp, false, nil
+	}
+	user := "guest"
+	if creds.username != "" {
+		user = url.PathEscape(creds.username)
+		if creds.domain != "" {
+			user = url.PathEscape(creds.domain) + ";" + user
+		}
+	}
+	if creds.password != "" {
+		user += ":" + url.PathEscape(creds.password)
+	}
+	addr := "//" + user + "@" + loc.Server + "/" + url.PathEscape(loc.Share)
+	if err := run(nil, "mount_smbfs", "-N", addr, mp); err != nil {
+		return "", false, err
+	}
+	return mp, true, nil
+}
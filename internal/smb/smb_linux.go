@@ -0,0 +1,46 @@
+package smb
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// mountShare 使用 cifs-utils 的 mount.cifs 把共享挂载到 mount_dir 下，密码通过环境变量 PASSWD 传入，不出现在命令行中
+// 程序不包含 SMB 协议的实现，需要以 root 运行并安装 cifs-utils，validate 会检查这两项
+func mountShare(loc Location, creds credentials, cfg config.SMBConfig) (string, bool, error) {
+	mp := mountPoint(loc, cfg)
+	if err := os.MkdirAll(mp, 0700); err != nil {
+		return "", false, i18n.Errorf("创建挂载点失败: %w", err)
+	}
+	if isMountPoint(mp) {
+		return mp, false, nil
+	}
+	if os.Geteuid() != 0 {
+		return "", false, i18n.Errorf("Linux 上通过 mount.cifs 挂载 SMB 共享，需要以 root 运行；也可以先挂载共享后使用本地目录")
+	}
+	opts := []string{fmt.Sprintf("uid=%d", os.Getuid()), fmt.Sprintf("gid=%d", os.Getgid())}
+	var env []string
+	switch {
+	case cfg.CredentialsFile != "":
+		opts = append(opts, "credentials="+cfg.CredentialsFile)
+	case creds.username != "":
+		opts = append(opts, "username="+creds.username)
+		if creds.domain != "" {
+			opts = append(opts, "domain="+creds.domain)
+		}
+		env = append(env, "PASSWD="+creds.password)
+	default:
+		opts = append(opts, "guest")
+	}
+	if cfg.Options != "" {
+		opts = append(opts, cfg.Options)
+	}
+	if err := run(env, "mount", "-t", "cifs", loc.UNC(), mp, "-o", strings.Join(opts, ",")); err != nil {
+		return "", false, err
+	}
+	return mp, true, nil
+}
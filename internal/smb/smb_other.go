@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !windows
+
+package smb
+
+import (
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+func mountShare(loc Location, creds credentials, cfg config.SMBConfig) (string, bool, error) {
+	return "", false, i18n.Errorf("当前系统不支持 smb:// 目标，请先挂载共享后使用本地目录")
+}
+
+func unmountShare(local, unc string) error {
+	return nil
+}
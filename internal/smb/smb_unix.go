@@ -0,0 +1,28 @@
+//go:build linux || darwin
+
+package smb
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// isMountPoint 目录与上一级目录不在同一个设备上时即为挂载点
+func isMountPoint(dir string) bool {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return false
+	}
+	parent, err := os.Stat(filepath.Dir(dir))
+	if err != nil {
+		return false
+	}
+	st, ok1 := info.Sys().(*syscall.Stat_t)
+	pst, ok2 := parent.Sys().(*syscall.Stat_t)
+	return ok1 && ok2 && st.Dev != pst.Dev
+}
+
+func unmountShare(local, unc string) error {
+	return run(nil, "umount", local)
+}
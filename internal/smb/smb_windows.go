@@ -0,0 +1,32 @@
+package smb
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+)
+
+// mountShare Windows 直接使用 \\<服务器>\<共享> 路径，配置了用户名时先用 net use 登录
+func mountShare(loc Location, creds credentials, cfg config.SMBConfig) (string, bool, error) {
+	unc := filepath.FromSlash(loc.UNC())
+	if creds.username == "" {
+		// 使用当前用户的身份访问
+		if _, err := os.Stat(unc); err != nil {
+			return "", false, err
+		}
+		return unc, false, nil
+	}
+	user := creds.username
+	if creds.domain != "" {
+		user = creds.domain + `\` + user
+	}
+	if err := run(nil, "net", "use", unc, creds.password, "/user:"+user, "/persistent:no"); err != nil {
+		return "", false, err
+	}
+	return unc, true, nil
+}
+
+func unmountShare(local, unc string) error {
+	return run(nil, "net", "use", local, "/delete", "/y")
+}
@@ -0,0 +1,67 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/mount"
+)
+
+// btrfs 目标目录需要是一个子卷，快照默认存放在子卷内的 .neo-snapshots 目录下
+type btrfs struct {
+	subvolume string
+	dir       string
+}
+
+func newBtrfs(target, dir string) (*btrfs, error) {
+	fsType, err := mount.FSType(target)
+	if err != nil {
+		return nil, i18n.Errorf("获取目标文件系统类型失败: %w", err)
+	}
+	if fsType != "btrfs" {
+		return nil, i18n.Errorf("目标目录不在 btrfs 上: %s (%s)", target, fsType)
+	}
+	if _, err := run("btrfs", "subvolume", "show", target); err != nil {
+		return nil, i18n.Errorf("目标目录不是 btrfs 子卷: %w", err)
+	}
+	if dir == "" {
+		dir = filepath.Join(target, ".neo-snapshots")
+	}
+	return &btrfs{subvolume: target, dir: dir}, nil
+}
+
+func (b *btrfs) Create(name string) error {
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return i18n.Errorf("创建快照目录失败: %w", err)
+	}
+	_, err := run("btrfs", "subvolume", "snapshot", "-r", b.subvolume, filepath.Join(b.dir, name))
+	return err
+}
+
+func (b *btrfs) List() ([]string, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+func (b *btrfs) Delete(name string) error {
+	_, err := run("btrfs", "subvolume", "delete", filepath.Join(b.dir, name))
+	return err
+}
+
+// Path 快照是整个子卷的只读副本，目录本身即与目标目录对应
+func (b *btrfs) Path(name string) (string, error) {
+	return filepath.Join(b.dir, name), nil
+}
@@ -0,0 +1,126 @@
+package snapshot
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// hardlink 不依赖文件系统的快照：把目标目录中的每个文件硬链接到 <快照目录>/<名称> 下
+// 备份更新文件时写入新文件再改名，快照中仍然是旧版本，没有变化的文件只占用一份空间
+type hardlink struct {
+	target string
+	dir    string
+}
+
+func newHardlink(target, dir string) (*hardlink, error) {
+	if dir == "" {
+		dir = filepath.Join(target, ".neo-snapshots")
+	}
+	return &hardlink{target: filepath.Clean(target), dir: filepath.Clean(dir)}, nil
+}
+
+// 复制中的临时文件和断点不放入快照
+var hardlinkSkip = []string{".neo-partial-", ".neo-resume-"}
+
+func (h *hardlink) Create(name string) error {
+	if err := os.MkdirAll(h.dir, 0755); err != nil {
+		return i18n.Errorf("创建快照目录失败: %w", err)
+	}
+	dst := filepath.Join(h.dir, name)
+	if _, err := os.Lstat(dst); err == nil {
+		return i18n.Errorf("快照已存在: %s", dst)
+	}
+	// 先在临时目录中创建，完成后再改名，中途失败不会留下不完整的快照
+	tmp := filepath.Join(h.dir, ".tmp-"+name)
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	dirs, err := h.link(tmp)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	// 创建文件时修改了目录时间，从最深的目录开始恢复
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chtimes(dirs[i].path, dirs[i].modTime, dirs[i].modTime); err != nil {
+			logger.Errorf("设置快照目录时间失败: %v", err)
+		}
+	}
+	return os.Rename(tmp, dst)
+}
+
+type linkedDir struct {
+	path    string
+	modTime time.Time
+}
+
+// link 在 root 下按目标目录的结构创建目录，文件创建为硬链接，返回创建的目录
+func (h *hardlink) link(root string) ([]linkedDir, error) {
+	var dirs []linkedDir
+	err := filepath.WalkDir(h.target, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == h.dir {
+			return filepath.SkipDir
+		}
+		for _, prefix := range hardlinkSkip {
+			if strings.HasPrefix(d.Name(), prefix) {
+				return nil
+			}
+		}
+		rel, err := filepath.Rel(h.target, p)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(root, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if err := os.MkdirAll(dst, info.Mode().Perm()); err != nil {
+				return i18n.Errorf("创建快照目录失败: %w", err)
+			}
+			dirs = append(dirs, linkedDir{path: dst, modTime: info.ModTime()})
+			return nil
+		}
+		// 符号链接本身创建硬链接，不跟随
+		if err := os.Link(p, dst); err != nil {
+			return i18n.Errorf("创建硬链接失败: %w", err)
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+func (h *hardlink) List() ([]string, error) {
+	entries, err := os.ReadDir(h.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+func (h *hardlink) Delete(name string) error {
+	return os.RemoveAll(filepath.Join(h.dir, name))
+}
+
+// Path 快照目录的结构与目标目录相同
+func (h *hardlink) Path(name string) (string, error) {
+	return filepath.Join(h.dir, name), nil
+}
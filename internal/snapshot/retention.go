@@ -0,0 +1,77 @@
+package snapshot
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// retention 快照的保留策略，满足任意一条规则的快照都会保留，全部为 0 时不清理
+type retention struct {
+	last   int // 保留最近的快照数量
+	daily  int // 最近这么多天中每天保留最新的一个
+	weekly int // 最近这么多周中每周保留最新的一个
+}
+
+func (r retention) enabled() bool {
+	return r.last > 0 || r.daily > 0 || r.weekly > 0
+}
+
+// expired 返回不满足任何规则的快照，snaps 必须按时间从旧到新排列
+func (r retention) expired(snaps []Snapshot, now time.Time) []Snapshot {
+	if !r.enabled() {
+		return nil
+	}
+	keep := make(map[int]bool)
+	for i := len(snaps) - 1; i >= 0 && i >= len(snaps)-r.last; i-- {
+		keep[i] = true
+	}
+	r.keepPeriods(snaps, keep, r.daily, dayStart(now).AddDate(0, 0, 1-r.daily), func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	r.keepPeriods(snaps, keep, r.weekly, weekStart(now).AddDate(0, 0, 7*(1-r.weekly)), func(t time.Time) string {
+		y, w := t.ISOWeek()
+		return fmt.Sprintf("%d-%02d", y, w)
+	})
+
+	var expired []Snapshot
+	for i, s := range snaps {
+		if !keep[i] {
+			expired = append(expired, s)
+		}
+	}
+	return expired
+}
+
+// keepPeriods 在 since 之后的每个周期中保留最新的快照，period 返回快照所在的周期
+func (r retention) keepPeriods(snaps []Snapshot, keep map[int]bool, count int, since time.Time, period func(time.Time) string) {
+	if count <= 0 {
+		return
+	}
+	seen := make(map[string]bool)
+	for i := len(snaps) - 1; i >= 0; i-- {
+		t := snaps[i].Time
+		if t.Before(since) {
+			break
+		}
+		if p := period(t); !seen[p] {
+			seen[p] = true
+			keep[i] = true
+		}
+	}
+}
+
+func dayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// weekStart 所在周的周一零点
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return dayStart(t).AddDate(0, 0, -offset)
+}
+
+// sortByTime 按时间从旧到新排列
+func sortByTime(snaps []Snapshot) {
+	sort.SliceStable(snaps, func(i, j int) bool { return snaps[i].Time.Before(snaps[j].Time) })
+}
@@ -0,0 +1,248 @@
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+	"github.com/lucasrui/neo-nas/internal/mount"
+)
+
+// logger snapshot 模块的日志
+var logger = logging.New("snapshot")
+
+// 默认的快照名称模板
+const defaultName = "neo-{date}-{time}"
+
+// Provider 不同文件系统的快照实现
+type Provider interface {
+	// Create 创建只读快照
+	Create(name string) error
+	// List 返回已有的快照名称
+	List() ([]string, error)
+	// Delete 删除快照
+	Delete(name string) error
+	// Path 返回快照中与目标目录对应的只读目录
+	Path(name string) (string, error)
+}
+
+// Snapshot 一个已有的快照，不是按名称模板创建的快照时间为零值
+type Snapshot struct {
+	Name string
+	Time time.Time
+}
+
+// Snapshotter 扫描成功后为目标目录创建快照，并按保留数量清理旧快照
+type Snapshotter struct {
+	provider Provider
+	target   string
+	name     string
+	host     string
+	pattern  *regexp.Regexp // 匹配程序按模板创建的快照，并从名称中取出时间
+	policy   retention
+}
+
+// New 根据配置创建快照管理器，未配置快照类型或自动检测时目标不支持快照时返回 nil
+func New(cfg config.SnapshotConfig, target, host string) (*Snapshotter, error) {
+	typ := cfg.Type
+	if typ == "auto" {
+		fsType, err := mount.FSType(target)
+		if err != nil {
+			return nil, i18n.Errorf("获取目标文件系统类型失败: %w", err)
+		}
+		if fsType != "btrfs" && fsType != "zfs" {
+			logger.Infof("目标文件系统不支持快照，不创建快照: %s (%s)", target, fsType)
+			return nil, nil
+		}
+		typ = fsType
+	}
+
+	var p Provider
+	var err error
+	switch typ {
+	case "":
+		return nil, nil
+	case "btrfs":
+		p, err = newBtrfs(target, cfg.Dir)
+	case "zfs":
+		p, err = newZFS(target, cfg.Dataset)
+	case "hardlink":
+		p, err = newHardlink(target, cfg.Dir)
+	default:
+		return nil, i18n.Errorf("不支持的快照类型: %s", cfg.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Keep < 0 || cfg.KeepDaily < 0 || cfg.KeepWeekly < 0 {
+		return nil, i18n.Errorf("快照保留数量不能为负数")
+	}
+	s := &Snapshotter{
+		provider: p,
+		target:   target,
+		name:     cfg.Name,
+		host:     host,
+		policy:   retention{last: cfg.Keep, daily: cfg.KeepDaily, weekly: cfg.KeepWeekly},
+	}
+	if s.name == "" {
+		s.name = defaultName
+	}
+	if s.pattern, err = compileName(s.name, host); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// compileName 把名称模板转换为正则表达式，模板必须包含日期和时间，清理时据此判断快照的先后
+func compileName(name, host string) (*regexp.Regexp, error) {
+	if !strings.Contains(name, "{date}") || !strings.Contains(name, "{time}") {
+		return nil, i18n.Errorf("快照名称模板必须包含 {date} 和 {time}: %s", name)
+	}
+	if strings.ContainsAny(name, "/@ ") {
+		return nil, i18n.Errorf("快照名称模板不能包含 /、@ 或空格: %s", name)
+	}
+	expr := regexp.QuoteMeta(name)
+	expr = strings.Replace(expr, regexp.QuoteMeta("{date}"), `(?P<date>\d{8})`, 1)
+	expr = strings.Replace(expr, regexp.QuoteMeta("{time}"), `(?P<time>\d{6})`, 1)
+	expr = strings.ReplaceAll(expr, regexp.QuoteMeta("{host}"), regexp.QuoteMeta(host))
+	return regexp.Compile("^" + expr + "$")
+}
+
+func (s *Snapshotter) format(t time.Time) string {
+	return strings.NewReplacer(
+		"{date}", t.Format("20060102"),
+		"{time}", t.Format("150405"),
+		"{host}", s.host,
+	).Replace(s.name)
+}
+
+// parse 返回程序创建的快照的时间，不是按模板创建的快照返回 false
+func (s *Snapshotter) parse(name string) (time.Time, bool) {
+	m := s.pattern.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation("20060102150405", m[s.pattern.SubexpIndex("date")]+m[s.pattern.SubexpIndex("time")], time.Local)
+	return t, err == nil
+}
+
+// Take 创建快照并清理超出保留数量的旧快照
+func (s *Snapshotter) Take() error {
+	name := s.format(time.Now())
+	if err := s.provider.Create(name); err != nil {
+		return i18n.Errorf("创建快照失败: %w", err)
+	}
+	logger.Infof("已创建快照: %s@%s", s.target, name)
+	return s.prune()
+}
+
+// List 返回目标目录的所有快照，按时间从旧到新排列
+func (s *Snapshotter) List() ([]Snapshot, error) {
+	names, err := s.provider.List()
+	if err != nil {
+		return nil, i18n.Errorf("列出快照失败: %w", err)
+	}
+	snaps := make([]Snapshot, 0, len(names))
+	for _, name := range names {
+		t, _ := s.parse(name)
+		snaps = append(snaps, Snapshot{Name: name, Time: t})
+	}
+	sortByTime(snaps)
+	return snaps, nil
+}
+
+// Path 返回快照中与目标目录对应的目录，快照不存在时返回错误
+func (s *Snapshotter) Path(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, "/@") {
+		return "", i18n.Errorf("快照名称无效: %s", name)
+	}
+	p, err := s.provider.Path(name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(p); err != nil {
+		return "", i18n.Errorf("快照不存在: %s", name)
+	}
+	return p, nil
+}
+
+// Retains 是否配置了保留策略，没有配置时不清理任何快照
+func (s *Snapshotter) Retains() bool {
+	return s.policy.enabled()
+}
+
+// Expired 返回不满足保留策略的快照，按时间从旧到新排列，只包含程序按模板创建的快照
+func (s *Snapshotter) Expired() ([]Snapshot, error) {
+	if !s.policy.enabled() {
+		return nil, nil
+	}
+	names, err := s.provider.List()
+	if err != nil {
+		return nil, i18n.Errorf("列出快照失败: %w", err)
+	}
+	var ours []Snapshot
+	for _, name := range names {
+		if t, ok := s.parse(name); ok {
+			ours = append(ours, Snapshot{Name: name, Time: t})
+		}
+	}
+	sortByTime(ours)
+	return s.policy.expired(ours, time.Now()), nil
+}
+
+// Remove 删除快照
+func (s *Snapshotter) Remove(name string) error {
+	if err := s.provider.Delete(name); err != nil {
+		return i18n.Errorf("删除快照失败 %s: %w", name, err)
+	}
+	logger.Infof("已删除旧快照: %s@%s", s.target, name)
+	return nil
+}
+
+// Size 返回删除快照后可以释放的空间，文件系统无法统计时返回 -1
+func (s *Snapshotter) Size(name string) int64 {
+	if sz, ok := s.provider.(sizer); ok {
+		if n, err := sz.Size(name); err == nil {
+			return n
+		}
+	}
+	return -1
+}
+
+// sizer 可以统计快照独占空间的文件系统实现该接口
+type sizer interface {
+	Size(name string) (int64, error)
+}
+
+func (s *Snapshotter) prune() error {
+	expired, err := s.Expired()
+	if err != nil {
+		return err
+	}
+	for _, snap := range expired {
+		if err := s.Remove(snap.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run 执行外部命令，失败时把命令输出附加到错误信息中
+func run(name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
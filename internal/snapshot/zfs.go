@@ -0,0 +1,86 @@
+package snapshot
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// zfs 快照作用于整个数据集，目标目录不是数据集的挂载点时快照也包含数据集中的其他内容
+type zfs struct {
+	dataset string
+	target  string
+}
+
+func newZFS(target, dataset string) (*zfs, error) {
+	if dataset == "" {
+		// 传入路径时 zfs list 返回包含该路径的数据集
+		out, err := run("zfs", "list", "-H", "-o", "name", target)
+		if err != nil {
+			return nil, i18n.Errorf("找不到目标目录所在的 zfs 数据集: %w", err)
+		}
+		dataset = strings.TrimSpace(string(out))
+	} else if _, err := run("zfs", "list", "-H", "-o", "name", dataset); err != nil {
+		return nil, i18n.Errorf("zfs 数据集不存在: %w", err)
+	}
+	if dataset == "" {
+		return nil, i18n.Errorf("找不到目标目录所在的 zfs 数据集: %s", target)
+	}
+	return &zfs{dataset: dataset, target: target}, nil
+}
+
+func (z *zfs) Create(name string) error {
+	_, err := run("zfs", "snapshot", z.dataset+"@"+name)
+	return err
+}
+
+func (z *zfs) List() ([]string, error) {
+	out, err := run("zfs", "list", "-H", "-t", "snapshot", "-o", "name", "-d", "1", z.dataset)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if name, ok := strings.CutPrefix(strings.TrimSpace(line), z.dataset+"@"); ok {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (z *zfs) Delete(name string) error {
+	_, err := run("zfs", "destroy", z.dataset+"@"+name)
+	return err
+}
+
+// Size 返回快照独占的空间，即删除快照后释放的空间
+func (z *zfs) Size(name string) (int64, error) {
+	out, err := run("zfs", "get", "-Hp", "-o", "value", "used", z.dataset+"@"+name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+}
+
+// Path 快照通过数据集挂载点下的 .zfs/snapshot/<名称> 访问，目标目录不是挂载点时再加上相对路径
+func (z *zfs) Path(name string) (string, error) {
+	out, err := run("zfs", "get", "-H", "-o", "value", "mountpoint", z.dataset)
+	if err != nil {
+		return "", i18n.Errorf("获取 zfs 挂载点失败: %w", err)
+	}
+	mountpoint := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(mountpoint) {
+		return "", i18n.Errorf("zfs 数据集没有挂载: %s (%s)", z.dataset, mountpoint)
+	}
+	target, err := filepath.Abs(z.target)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(mountpoint, target)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", i18n.Errorf("目标目录不在数据集的挂载点下: %s", z.target)
+	}
+	return filepath.Join(mountpoint, ".zfs", "snapshot", name, rel), nil
+}
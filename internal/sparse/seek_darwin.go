@@ -0,0 +1,17 @@
+package sparse
+
+import (
+	"errors"
+	"syscall"
+)
+
+// macOS 的 SEEK_HOLE 和 SEEK_DATA 与 Linux 的取值相反
+const (
+	supported = true
+	seekHole  = 3
+	seekData  = 4
+)
+
+func isNoData(err error) bool {
+	return errors.Is(err, syscall.ENXIO)
+}
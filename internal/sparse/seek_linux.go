@@ -0,0 +1,16 @@
+package sparse
+
+import (
+	"errors"
+	"syscall"
+)
+
+const (
+	supported = true
+	seekData  = 3
+	seekHole  = 4
+)
+
+func isNoData(err error) bool {
+	return errors.Is(err, syscall.ENXIO)
+}
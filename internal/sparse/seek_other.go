@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package sparse
+
+// 其他平台不查询空洞，按普通方式复制
+const (
+	supported = false
+	seekData  = 0
+	seekHole  = 0
+)
+
+func isNoData(err error) bool {
+	return false
+}
@@ -0,0 +1,117 @@
+package sparse
+
+import (
+	"io"
+	"os"
+
+	"github.com/lucasrui/neo-nas/internal/throttle"
+)
+
+// extent 文件中有数据的一段，[start, end)
+type extent struct {
+	start, end int64
+}
+
+// Copy 把 src 的内容复制到 dst，源文件中的空洞在目标中同样保留为空洞，不写入零
+// 同时把完整的内容（空洞按零计算）写入 w，用于计算哈希，w 可以为 nil
+// 文件系统不支持查询空洞或文件没有空洞时按普通方式复制
+func Copy(dst, src *os.File, w io.Writer) (int64, error) {
+	return CopyLimited(dst, src, w, nil)
+}
+
+// CopyLimited 与 Copy 相同，按 limiter 限制读取源文件的速度，空洞不读取也不计入限额，limiter 为 nil 时不限速
+func CopyLimited(dst, src *os.File, w io.Writer, limiter *throttle.Limiter) (int64, error) {
+	info, err := src.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+	in := limiter.Reader(src)
+	out := io.Writer(dst)
+	if w != nil {
+		out = io.MultiWriter(dst, w)
+	}
+	extents, err := dataExtents(src, size)
+	if err != nil || extents == nil {
+		if _, err := src.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		return io.Copy(out, in)
+	}
+
+	var pos int64
+	for _, e := range extents {
+		if err := skip(dst, w, e.start-pos); err != nil {
+			return 0, err
+		}
+		if _, err := src.Seek(e.start, io.SeekStart); err != nil {
+			return 0, err
+		}
+		if _, err := io.CopyN(out, in, e.end-e.start); err != nil {
+			return 0, err
+		}
+		pos = e.end
+	}
+	if err := skip(dst, w, size-pos); err != nil {
+		return 0, err
+	}
+	// 末尾的空洞需要通过设置长度生成
+	if err := dst.Truncate(size); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// skip 在目标中跳过 n 字节留下空洞，哈希按零计算
+func skip(dst *os.File, w io.Writer, n int64) error {
+	if n <= 0 {
+		return nil
+	}
+	if _, err := dst.Seek(n, io.SeekCurrent); err != nil {
+		return err
+	}
+	if w != nil {
+		if _, err := io.CopyN(w, zeros{}, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// dataExtents 使用 SEEK_DATA/SEEK_HOLE 列出有数据的区域，文件没有空洞时返回 nil
+func dataExtents(f *os.File, size int64) ([]extent, error) {
+	if !supported || size == 0 {
+		return nil, nil
+	}
+	var extents []extent
+	for off := int64(0); off < size; {
+		start, err := f.Seek(off, seekData)
+		if err != nil {
+			// 之后没有数据，只剩空洞
+			if isNoData(err) {
+				break
+			}
+			return nil, err
+		}
+		end, err := f.Seek(start, seekHole)
+		if err != nil {
+			return nil, err
+		}
+		if end > size {
+			end = size
+		}
+		extents = append(extents, extent{start, end})
+		off = end
+	}
+	if len(extents) == 1 && extents[0].start == 0 && extents[0].end == size {
+		return nil, nil
+	}
+	return extents, nil
+}
@@ -0,0 +1,106 @@
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/history"
+	"github.com/lucasrui/neo-nas/internal/httpd"
+	"github.com/lucasrui/neo-nas/internal/logging"
+	"github.com/lucasrui/neo-nas/internal/version"
+)
+
+// logger status 模块的日志
+var logger = logging.New("status")
+
+// Prefix 状态接口的路径
+const Prefix = "/status"
+
+// Report 守护进程当前的状态
+type Report struct {
+	Version  string          `json:"version"`
+	Build    version.Build   `json:"build"`            // 版本、提交号和构建时间，用于确认正在运行的是哪个构建
+	Update   *version.Result `json:"update,omitempty"` // 最近一次检查新版本的结果，未开启检查时为空
+	Hostname string          `json:"hostname"`
+	Started  time.Time       `json:"started"`
+	Healthy  bool            `json:"healthy"` // 所有任务都已启动，且最近一次运行都没有失败
+	Backups  []Backup        `json:"backups"`
+	Zips     []Zip           `json:"zips"`
+}
+
+// Backup 一个备份任务的状态，数量为正在进行或最近一次扫描的统计
+type Backup struct {
+	ID         int          `json:"id"` // 任务序号，从 1 开始，与配置中的顺序相同，管理接口中使用
+	Source     string       `json:"source"`
+	Target     string       `json:"target"`
+	Active     bool         `json:"active"` // 监控已启动，配置错误等原因未能启动时为 false
+	Online     bool         `json:"online"` // 源目录已插入或挂载
+	BackingUp  bool         `json:"backing_up"`
+	SourceDown bool         `json:"source_down"`    // 网络共享暂时不可用
+	Paused     bool         `json:"paused"`         // 通过管理接口暂停
+	Ejected    bool         `json:"safe_to_unplug"` // 备份完成后已弹出设备，可以直接拔出
+	LastSync   *time.Time   `json:"last_sync,omitempty"`
+	Total      int          `json:"total"`
+	Success    int          `json:"success"`
+	Failed     int          `json:"failed"`
+	Skipped    int          `json:"skipped"`
+	LastRun    *history.Run `json:"last_run,omitempty"`
+}
+
+// Zip 一个压缩任务的状态
+type Zip struct {
+	ID       int          `json:"id"` // 任务序号，从 1 开始
+	Source   string       `json:"source"`
+	Target   string       `json:"target"`
+	Running  bool         `json:"running"`
+	Progress *ZipProgress `json:"progress,omitempty"` // 正在压缩时的进度
+	LastRun  *history.Run `json:"last_run,omitempty"`
+	NextRun  *time.Time   `json:"next_run,omitempty"`
+}
+
+// ZipProgress 正在执行的压缩任务的进度，字节数为读取的源文件大小
+type ZipProgress struct {
+	File       string     `json:"file"` // 正在压缩的文件，写完所有文件后校验期间为空
+	BytesDone  int64      `json:"bytes_done"`
+	BytesTotal int64      `json:"bytes_total"`
+	Percent    int        `json:"percent"`
+	Started    time.Time  `json:"started"`
+	ETA        *time.Time `json:"eta,omitempty"` // 按目前的平均速度估计的完成时间
+}
+
+// Handler 以 JSON 输出 collect 返回的状态
+type Handler struct {
+	collect func() Report
+}
+
+func NewHandler(collect func() Report) *Handler {
+	return &Handler{collect: collect}
+}
+
+// Register 在内置 HTTP 服务上注册状态接口
+func (h *Handler) Register(s *httpd.Server, cfg config.StatusConfig) {
+	s.Handle(Prefix, httpd.BasicAuth(cfg.Username, cfg.Password, "neo-nas", h))
+	logger.Infof("状态接口已启用: %s", Prefix)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	report := h.collect()
+	if report.Backups == nil {
+		report.Backups = []Backup{}
+	}
+	if report.Zips == nil {
+		report.Zips = []Zip{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+}
@@ -0,0 +1,182 @@
+package throttle
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+)
+
+// logger throttle 模块的日志
+var logger = logging.New("throttle")
+
+// 每次读取的最大字节数，限速较低时也能平稳传输
+const maxReadSize = 32 << 10
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+type schedule struct {
+	start, end int // 一天中的分钟数
+	days       map[time.Weekday]bool
+	rate       int64 // 字节/秒，0 表示不限速
+}
+
+// contains 判断时间是否在时段内，结束时间早于开始时间时表示跨过午夜
+func (s schedule) contains(t time.Time) bool {
+	if len(s.days) > 0 && !s.days[t.Weekday()] {
+		return false
+	}
+	m := t.Hour()*60 + t.Minute()
+	if s.start <= s.end {
+		return m >= s.start && m < s.end
+	}
+	return m >= s.start || m < s.end
+}
+
+// Limiter 按时段限制所有远程传输的总带宽，同一时段内的多个传输共享限额
+type Limiter struct {
+	schedules []schedule
+	mu        sync.Mutex
+	tokens    float64
+	last      time.Time
+	lastRate  int64
+}
+
+// NewLimiter 解析带宽时段配置，未配置任何时段时返回 nil，nil 表示不限速
+func NewLimiter(cfg config.BandwidthConfig) (*Limiter, error) {
+	if len(cfg.Schedules) == 0 {
+		return nil, nil
+	}
+	l := &Limiter{lastRate: -1}
+	for _, c := range cfg.Schedules {
+		s, err := parseSchedule(c)
+		if err != nil {
+			return nil, err
+		}
+		l.schedules = append(l.schedules, s)
+	}
+	return l, nil
+}
+
+// NewFixed 创建始终按 rate（字节/秒）限速的 Limiter，rate 不大于 0 时返回 nil，表示不限速
+func NewFixed(rate int64) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	// 全天生效的时段，lastRate 与之相同，不记录限速变化的日志
+	return &Limiter{
+		schedules: []schedule{{start: 0, end: 24 * 60, rate: rate}},
+		lastRate:  rate,
+	}
+}
+
+func parseSchedule(c config.BandwidthSchedule) (schedule, error) {
+	s := schedule{rate: int64(c.LimitKBps) << 10}
+	var err error
+	if s.start, err = parseClock(c.Start); err != nil {
+		return s, err
+	}
+	if s.end, err = parseClock(c.End); err != nil {
+		return s, err
+	}
+	if c.LimitKBps < 0 {
+		return s, i18n.Errorf("带宽限制不能为负数: %d", c.LimitKBps)
+	}
+	for _, d := range c.Days {
+		wd, ok := weekdays[strings.ToLower(d)[:min(3, len(d))]]
+		if !ok {
+			return s, i18n.Errorf("无法识别的星期: %s", d)
+		}
+		if s.days == nil {
+			s.days = make(map[time.Weekday]bool)
+		}
+		s.days[wd] = true
+	}
+	return s, nil
+}
+
+// parseClock 解析 HH:MM 格式的时间
+func parseClock(v string) (int, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, i18n.Errorf("时间格式无效，应为 HH:MM: %s", v)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Rate 返回当前时段的限速（字节/秒），第一个匹配的时段生效，没有匹配的时段时不限速
+func (l *Limiter) Rate(now time.Time) int64 {
+	for _, s := range l.schedules {
+		if s.contains(now) {
+			return s.rate
+		}
+	}
+	return 0
+}
+
+// Wait 传输 n 字节前调用，超出当前时段的限额时阻塞
+func (l *Limiter) Wait(n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	rate := l.Rate(now)
+	if rate != l.lastRate {
+		if rate > 0 {
+			logger.Infof("带宽限制: %d KB/s", rate>>10)
+		} else if l.lastRate > 0 {
+			logger.Infof("带宽限制已解除")
+		}
+		l.lastRate = rate
+		l.tokens = 0
+		l.last = now
+	}
+	if rate <= 0 {
+		l.mu.Unlock()
+		return
+	}
+
+	// 令牌桶，最多积累 1 秒的限额
+	l.tokens += now.Sub(l.last).Seconds() * float64(rate)
+	l.last = now
+	if l.tokens > float64(rate) {
+		l.tokens = float64(rate)
+	}
+	l.tokens -= float64(n)
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / float64(rate) * float64(time.Second))
+	}
+	l.mu.Unlock()
+	time.Sleep(wait)
+}
+
+// Reader 返回受带宽限制的 Reader
+func (l *Limiter) Reader(r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &reader{r: r, l: l}
+}
+
+type reader struct {
+	r io.Reader
+	l *Limiter
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > maxReadSize {
+		p = p[:maxReadSize]
+	}
+	n, err := r.r.Read(p)
+	r.l.Wait(n)
+	return n, err
+}
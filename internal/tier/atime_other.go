@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !windows
+
+package tier
+
+import (
+	"os"
+	"time"
+)
+
+// accessTime 无法获取访问时间的平台只按修改时间判断
+func accessTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
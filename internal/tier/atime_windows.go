@@ -0,0 +1,15 @@
+package tier
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// accessTime 返回文件的最后访问时间
+func accessTime(info os.FileInfo) time.Time {
+	if attr, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return time.Unix(0, attr.LastAccessTime.Nanoseconds())
+	}
+	return info.ModTime()
+}
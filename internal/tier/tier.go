@@ -0,0 +1,327 @@
+package tier
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/catalog"
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/dryrun"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/immutable"
+	"github.com/lucasrui/neo-nas/internal/logging"
+	"github.com/lucasrui/neo-nas/internal/priority"
+	"github.com/lucasrui/neo-nas/internal/remote"
+)
+
+// logger tier 模块的日志
+var logger = logging.New("tier")
+
+// StubSuffix 迁移到冷存储的文件在原位置留下的记录文件后缀
+const StubSuffix = ".neo-cold"
+
+// Stub 记录文件的内容，恢复时据此从冷存储取回原文件
+type Stub struct {
+	Cold     string      `json:"cold"`
+	Key      string      `json:"key"`
+	Size     int64       `json:"size"`
+	Mode     os.FileMode `json:"mode"`
+	ModTime  time.Time   `json:"mod_time"`
+	SHA256   string      `json:"sha256"`
+	TieredAt time.Time   `json:"tiered_at"`
+}
+
+// HasStub 判断目标文件是否已迁移到冷存储
+func HasStub(targetPath string) bool {
+	_, err := os.Stat(targetPath + StubSuffix)
+	return err == nil
+}
+
+// Job 定时把目标目录中长期未使用的文件迁移到冷存储
+type Job struct {
+	target   string
+	cold     remote.Backend
+	after    time.Duration
+	minSize  int64
+	interval time.Duration
+	catalog  *catalog.Catalog
+	stopChan chan struct{}
+	done     chan struct{} // 定时协程退出后关闭
+}
+
+func NewJob(cfg config.TierConfig, cat *catalog.Catalog, opts remote.Options) (*Job, error) {
+	if cfg.Target == "" {
+		return nil, i18n.Errorf("迁移目标目录不能为空")
+	}
+	if cfg.AfterDays <= 0 {
+		return nil, i18n.Errorf("迁移天数必须大于 0")
+	}
+	cold, err := remote.Open(cfg.Cold, opts)
+	if err != nil {
+		return nil, err
+	}
+	target, err := filepath.Abs(cfg.Target)
+	if err != nil {
+		return nil, i18n.Errorf("解析目标目录失败: %w", err)
+	}
+	inner := cold
+	if u, ok := cold.(interface{ Unwrap() remote.Backend }); ok {
+		inner = u.Unwrap()
+	}
+	if _, ok := inner.(*remote.Local); ok {
+		c := cold.String()
+		if c == target || strings.HasPrefix(c, target+string(filepath.Separator)) || strings.HasPrefix(target, c+string(filepath.Separator)) {
+			return nil, i18n.Errorf("冷存储目录不能与目标目录相同或互相包含: %s", c)
+		}
+	}
+	j := &Job{
+		target:   target,
+		cold:     cold,
+		after:    time.Duration(cfg.AfterDays) * 24 * time.Hour,
+		minSize:  int64(cfg.MinSizeKB) << 10,
+		interval: time.Duration(cfg.IntervalHours) * time.Hour,
+		catalog:  cat,
+		stopChan: make(chan struct{}),
+	}
+	if j.interval <= 0 {
+		j.interval = 24 * time.Hour
+	}
+	return j, nil
+}
+
+func (j *Job) Start() {
+	j.done = make(chan struct{})
+	go func() {
+		priority.Lower()
+		defer close(j.done)
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+		j.runLogged()
+		for {
+			select {
+			case <-ticker.C:
+				j.runLogged()
+			case <-j.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止定时执行，正在进行的迁移完成后返回
+func (j *Job) Stop() {
+	close(j.stopChan)
+	if j.done != nil {
+		<-j.done
+	}
+	logger.Infof("停止冷存储迁移: %s", j.target)
+}
+
+func (j *Job) runLogged() {
+	if err := j.Run(); err != nil {
+		logger.With(logging.KeyTask, j.target, logging.KeyStatus, logging.StatusFailed, logging.KeyError, err.Error()).
+			Errorf("冷存储迁移失败 %s: %v", j.target, err)
+	}
+}
+
+// Run 执行一次迁移
+func (j *Job) Run() error {
+	if _, err := os.Stat(j.target); err != nil {
+		return i18n.Errorf("目标目录不存在: %s", j.target)
+	}
+	start := time.Now()
+	cutoff := start.Add(-j.after)
+	var moved, failed int
+	var bytes int64
+	err := filepath.WalkDir(j.target, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// 跳过程序内部使用的文件和目录，例如回收目录和临时文件
+		if strings.HasPrefix(d.Name(), ".neo-") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasSuffix(p, StubSuffix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if info.Size() < j.minSize || info.ModTime().After(cutoff) || accessTime(info).After(cutoff) {
+			return nil
+		}
+		if dryrun.Skip("迁移到冷存储: %s -> %s", p, j.cold) {
+			return nil
+		}
+		if err := j.tier(p, info); err != nil {
+			failed++
+			logger.Errorf("迁移到冷存储失败 %s: %v", p, err)
+			return nil
+		}
+		moved++
+		bytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return i18n.Errorf("扫描目标目录失败: %w", err)
+	}
+	if moved+failed > 0 {
+		status := logging.StatusSuccess
+		if failed > 0 {
+			status = logging.StatusFailed
+		}
+		logger.With(logging.KeyTask, j.target, logging.KeyBytes, bytes, logging.KeyDuration, time.Since(start), logging.KeyStatus, status,
+			"moved", moved, "failed", failed).
+			Infof("冷存储迁移完成: %s -> %s, 迁移: %d 个文件, 共 %d 字节, 失败: %d", j.target, j.cold, moved, bytes, failed)
+	}
+	return nil
+}
+
+// tier 上传到冷存储、校验副本并写入记录文件后才删除原文件，任何一步失败都保留原文件
+func (j *Job) tier(p string, info os.FileInfo) error {
+	rel, err := filepath.Rel(j.target, p)
+	if err != nil {
+		return err
+	}
+	key := filepath.ToSlash(rel)
+
+	f, err := os.Open(p)
+	if err != nil {
+		return i18n.Errorf("打开文件失败: %w", err)
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if err := j.cold.Put(key, io.TeeReader(f, hash)); err != nil {
+		return err
+	}
+
+	stub := Stub{
+		Cold:     j.cold.String(),
+		Key:      key,
+		Size:     info.Size(),
+		Mode:     info.Mode().Perm(),
+		ModTime:  info.ModTime(),
+		SHA256:   hex.EncodeToString(hash.Sum(nil)),
+		TieredAt: time.Now(),
+	}
+	// 与目录索引中的哈希不一致说明文件已损坏或被改动，不迁移
+	if e, ok := j.catalog.Get(p); ok && e.Size == stub.Size && e.ModTime.Equal(stub.ModTime) && e.SHA256 != stub.SHA256 {
+		j.cold.Delete(key)
+		return i18n.Errorf("文件哈希与目录索引不一致")
+	}
+	// 确认冷存储中的副本完整后才删除原文件
+	if err := remote.Verify(j.cold, key, stub.Size, stub.SHA256); err != nil {
+		j.cold.Delete(key)
+		return err
+	}
+	if err := writeStub(p+StubSuffix, stub); err != nil {
+		return err
+	}
+	f.Close()
+	if err := immutable.Clear(p); err != nil {
+		os.Remove(p + StubSuffix)
+		return i18n.Errorf("清除不可变属性失败: %w", err)
+	}
+	if err := os.Remove(p); err != nil {
+		os.Remove(p + StubSuffix)
+		return i18n.Errorf("删除原文件失败: %w", err)
+	}
+	logger.Infof("已迁移到冷存储: %s -> %s/%s", p, j.cold, key)
+	return nil
+}
+
+func writeStub(file string, stub Stub) error {
+	data, err := json.MarshalIndent(stub, "", "  ")
+	if err != nil {
+		return i18n.Errorf("序列化记录文件失败: %w", err)
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return i18n.Errorf("写入记录文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return i18n.Errorf("写入记录文件失败: %w", err)
+	}
+	return nil
+}
+
+// ReadStub 读取目标文件的冷存储记录
+func ReadStub(targetPath string) (*Stub, error) {
+	data, err := os.ReadFile(targetPath + StubSuffix)
+	if err != nil {
+		return nil, err
+	}
+	var stub Stub
+	if err := json.Unmarshal(data, &stub); err != nil {
+		return nil, i18n.Errorf("解析记录文件失败: %w", err)
+	}
+	return &stub, nil
+}
+
+// Recall 从冷存储取回文件放回原位置，校验哈希后删除记录文件，冷存储中的副本保留
+// 存储类型为 GLACIER 等归档类型时，需要先在存储服务上恢复对象才能取回
+func Recall(targetPath string, opts remote.Options) error {
+	if err := RecallTo(targetPath, targetPath, opts); err != nil {
+		return err
+	}
+	if err := os.Remove(targetPath + StubSuffix); err != nil {
+		logger.Errorf("删除记录文件失败: %v", err)
+	}
+	return nil
+}
+
+// RecallTo 从冷存储取回文件写入 dst 并校验哈希，不改动目标目录中的记录文件，用于恢复到其他位置
+func RecallTo(targetPath, dst string, opts remote.Options) error {
+	stub, err := ReadStub(targetPath)
+	if err != nil {
+		return err
+	}
+	cold, err := remote.Open(stub.Cold, opts)
+	if err != nil {
+		return err
+	}
+	r, err := cold.Get(stub.Key)
+	if err != nil {
+		return i18n.Errorf("从冷存储读取失败: %w", err)
+	}
+	defer r.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".neo-recall-*")
+	if err != nil {
+		return i18n.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), r); err != nil {
+		return i18n.Errorf("从冷存储读取失败: %w", err)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != stub.SHA256 {
+		return i18n.Errorf("冷存储中的文件哈希不匹配: 期望 %s, 实际 %s", stub.SHA256, sum)
+	}
+	if err := tmp.Close(); err != nil {
+		return i18n.Errorf("关闭临时文件失败: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), stub.Mode); err != nil {
+		logger.Errorf("设置文件权限失败: %v", err)
+	}
+	if err := os.Chtimes(tmp.Name(), stub.ModTime, stub.ModTime); err != nil {
+		logger.Errorf("设置文件时间失败: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return i18n.Errorf("恢复文件失败: %w", err)
+	}
+	logger.Infof("已从冷存储取回: %s/%s -> %s", cold, stub.Key, dst)
+	return nil
+}
@@ -0,0 +1,211 @@
+package verify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/checksum"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+	"github.com/lucasrui/neo-nas/internal/tier"
+)
+
+// logger verify 模块的日志
+var logger = logging.New("verify")
+
+// 比较方式
+const (
+	ModeQuick = "quick" // 比较大小和修改时间（默认）
+	ModeHash  = "hash"  // 另外读取两边的内容比较 SHA256
+)
+
+// 差异类型
+const (
+	Missing = "missing" // 源文件没有备份
+	Size    = "size"    // 大小不同
+	ModTime = "mtime"   // 修改时间不同，通常是源文件在备份后被修改
+	Content = "content" // 大小和时间相同但内容不同
+	Error   = "error"   // 无法读取
+)
+
+// FAT 等文件系统的时间精度为 2 秒，差距在此范围内视为相同
+const timeSlop = 2 * time.Second
+
+// Discrepancy 一个文件的差异
+type Discrepancy struct {
+	Path   string `json:"path"` // 相对于源目录的路径
+	Kind   string `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Report 一次校验的结果
+type Report struct {
+	Source        string        `json:"source"`
+	Target        string        `json:"target"`
+	Mode          string        `json:"mode"`
+	Time          time.Time     `json:"time"`
+	Checked       int           `json:"checked"`
+	Discrepancies []Discrepancy `json:"discrepancies"`
+	Extra         []string      `json:"extra,omitempty"` // 只存在于目标目录中的文件，源文件已删除，不算作差异
+}
+
+// OK 没有需要处理的差异，只存在于目标目录中的文件不影响结果
+func (r *Report) OK() bool {
+	return len(r.Discrepancies) == 0
+}
+
+// Save 以 JSON 格式写入报告
+func (r *Report) Save(file string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return i18n.Errorf("写入校验报告失败: %w", err)
+	}
+	return nil
+}
+
+// Run 遍历源目录和目标目录，按 mode 比较每个文件，skip 不为 nil 时跳过其返回 true 的源文件和目录
+// 已迁移到冷存储的文件与记录文件中的大小、时间和哈希比较，不从冷存储取回
+func Run(source, target, mode string, skip func(path string) bool) (*Report, error) {
+	switch mode {
+	case "":
+		mode = ModeQuick
+	case ModeQuick, ModeHash:
+	default:
+		return nil, i18n.Errorf("未知的校验方式: %s", mode)
+	}
+	if _, err := os.Stat(source); err != nil {
+		return nil, i18n.Errorf("源目录不可用: %w", err)
+	}
+	if _, err := os.Stat(target); err != nil {
+		return nil, i18n.Errorf("目标目录不可用: %w", err)
+	}
+	r := &Report{Source: source, Target: target, Mode: mode, Time: time.Now()}
+	seen := make(map[string]bool)
+
+	err := filepath.WalkDir(source, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			logger.Errorf("访问路径失败 %s: %v", p, err)
+			return nil
+		}
+		if p != source && skip != nil && skip(p) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(source, p)
+		if err != nil {
+			return err
+		}
+		// 与备份一致，符号链接按指向的文件比较
+		info, err := os.Stat(p)
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		seen[rel] = true
+		r.Checked++
+		if diff := compare(p, filepath.Join(target, rel), info, mode); diff != nil {
+			diff.Path = filepath.ToSlash(rel)
+			r.Discrepancies = append(r.Discrepancies, *diff)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, i18n.Errorf("扫描源目录失败: %w", err)
+	}
+
+	err = filepath.WalkDir(target, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if p != target && strings.HasPrefix(d.Name(), ".neo-") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || checksum.IsSumsFile(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(target, p)
+		if err != nil {
+			return err
+		}
+		rel = strings.TrimSuffix(rel, tier.StubSuffix)
+		if !seen[rel] {
+			seen[rel] = true
+			r.Extra = append(r.Extra, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, i18n.Errorf("扫描目标目录失败: %w", err)
+	}
+	sort.Strings(r.Extra)
+	return r, nil
+}
+
+// compare 比较一个源文件和对应的备份，没有差异时返回 nil
+func compare(src, dst string, info os.FileInfo, mode string) *Discrepancy {
+	size, modTime, sum := int64(0), time.Time{}, ""
+	if dstInfo, err := os.Stat(dst); err == nil {
+		size, modTime = dstInfo.Size(), dstInfo.ModTime()
+	} else if stub, err := tier.ReadStub(dst); err == nil {
+		size, modTime, sum = stub.Size, stub.ModTime, stub.SHA256
+	} else if tier.HasStub(dst) {
+		return &Discrepancy{Kind: Error, Detail: i18n.Sprintf("读取冷存储记录失败: %v", err)}
+	} else {
+		return &Discrepancy{Kind: Missing}
+	}
+
+	if size != info.Size() {
+		return &Discrepancy{Kind: Size, Detail: i18n.Sprintf("源 %d 字节, 备份 %d 字节", info.Size(), size)}
+	}
+	if d := modTime.Sub(info.ModTime()); d > timeSlop || d < -timeSlop {
+		return &Discrepancy{Kind: ModTime, Detail: i18n.Sprintf("源 %s, 备份 %s", info.ModTime().Format("2006-01-02 15:04:05"), modTime.Format("2006-01-02 15:04:05"))}
+	}
+	if mode != ModeHash {
+		return nil
+	}
+
+	srcSum, err := hashFile(src)
+	if err != nil {
+		return &Discrepancy{Kind: Error, Detail: i18n.Sprintf("读取源文件失败: %v", err)}
+	}
+	if sum == "" {
+		if sum, err = hashFile(dst); err != nil {
+			return &Discrepancy{Kind: Error, Detail: i18n.Sprintf("读取备份失败: %v", err)}
+		}
+	}
+	if sum != srcSum {
+		return &Discrepancy{Kind: Content, Detail: i18n.Sprintf("源 %s, 备份 %s", srcSum, sum)}
+	}
+	return nil
+}
+
+func hashFile(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,280 @@
+package version
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+	"github.com/lucasrui/neo-nas/internal/proxy"
+)
+
+// logger version 模块的日志
+var logger = logging.New("version")
+
+// 构建信息，构建时通过 -ldflags 写入，例如
+// -X github.com/lucasrui/neo-nas/internal/version.Version=v1.2.3
+// -X github.com/lucasrui/neo-nas/internal/version.Commit=$(git rev-parse HEAD)
+// -X github.com/lucasrui/neo-nas/internal/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+var (
+	Version   = ""
+	Commit    = ""
+	BuildDate = ""
+)
+
+// DefaultFeed 默认的发布信息地址
+const DefaultFeed = "https://api.github.com/repos/lucasrui/neo-nas/releases/latest"
+
+// Current 返回当前程序的版本，构建时没有写入版本时使用 Go 模块版本或提交号
+func Current() string {
+	if Version != "" {
+		return Version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" && len(s.Value) >= 12 {
+			return "dev-" + s.Value[:12]
+		}
+	}
+	return "dev"
+}
+
+// Build 当前程序的构建信息
+type Build struct {
+	Version  string `json:"version"`
+	Commit   string `json:"commit,omitempty"`
+	Date     string `json:"date,omitempty"` // 构建时间，没有写入时为提交时间
+	Go       string `json:"go"`
+	Platform string `json:"platform"`
+}
+
+// Info 返回当前程序的构建信息，构建时没有写入提交号和构建时间时从 Go 记录的版本控制信息中读取
+func Info() Build {
+	b := Build{Version: Current(), Commit: Commit, Date: BuildDate, Go: runtime.Version(), Platform: runtime.GOOS + "/" + runtime.GOARCH}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		modified := false
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if b.Commit == "" {
+					b.Commit = s.Value
+				}
+			case "vcs.time":
+				if b.Date == "" {
+					b.Date = s.Value
+				}
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+		if modified && Commit == "" && b.Commit != "" {
+			b.Commit += "-dirty"
+		}
+	}
+	return b
+}
+
+// String 单行的构建信息，例如 v1.2.3 (commit 0123456789ab, 2024-05-01T00:00:00Z, go1.21.0, linux/amd64)
+func (b Build) String() string {
+	var parts []string
+	if b.Commit != "" {
+		commit, dirty := strings.CutSuffix(b.Commit, "-dirty")
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		if dirty {
+			commit += "-dirty"
+		}
+		parts = append(parts, "commit "+commit)
+	}
+	if b.Date != "" {
+		parts = append(parts, b.Date)
+	}
+	parts = append(parts, b.Go, b.Platform)
+	return b.Version + " (" + strings.Join(parts, ", ") + ")"
+}
+
+// Result 最近一次检查新版本的结果，保存在配置目录中供 list 命令显示
+type Result struct {
+	Checked time.Time `json:"checked"`
+	Current string    `json:"current"`
+	Latest  string    `json:"latest,omitempty"`
+	URL     string    `json:"url,omitempty"`
+	Newer   bool      `json:"newer"` // 有比当前版本更新的发布
+	Error   string    `json:"error,omitempty"`
+	Build   *Build    `json:"build,omitempty"` // version 命令输出时附带，不保存到检查结果文件
+}
+
+// release 发布信息中用到的字段，与 GitHub releases 接口的格式相同
+type release struct {
+	TagName string `json:"tag_name"`
+	HTMLURL string `json:"html_url"`
+}
+
+// Check 查询最新的发布版本并与当前版本比较
+func Check(cfg config.UpdateCheckConfig) Result {
+	r := Result{Checked: time.Now(), Current: Current()}
+	latest, err := fetch(cfg)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.Latest, r.URL = latest.TagName, latest.HTMLURL
+	r.Newer = Newer(r.Latest, r.Current)
+	return r
+}
+
+func fetch(cfg config.UpdateCheckConfig) (release, error) {
+	var rel release
+	proxyFunc, err := proxy.Func(cfg.Proxy)
+	if err != nil {
+		return rel, err
+	}
+	feed := cfg.Feed
+	if feed == "" {
+		feed = DefaultFeed
+	}
+	client := &http.Client{Timeout: 30 * time.Second, Transport: &http.Transport{Proxy: proxyFunc}}
+	req, err := http.NewRequest(http.MethodGet, feed, nil)
+	if err != nil {
+		return rel, i18n.Errorf("发布信息地址无效: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "neo-nas/"+Current())
+	resp, err := client.Do(req)
+	if err != nil {
+		return rel, i18n.Errorf("查询最新版本失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return rel, i18n.Errorf("查询最新版本失败: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
+		return rel, i18n.Errorf("解析发布信息失败: %w", err)
+	}
+	if rel.TagName == "" {
+		return rel, i18n.Errorf("发布信息中没有版本号")
+	}
+	return rel, nil
+}
+
+// Newer 判断 latest 是否比 current 新，版本号格式为 v1.2.3 或 1.2.3-rc1
+// 开发版本等无法解析的版本号不做比较
+func Newer(latest, current string) bool {
+	l, lok := parse(latest)
+	c, cok := parse(current)
+	if !lok || !cok {
+		return false
+	}
+	for i := range l.nums {
+		if l.nums[i] != c.nums[i] {
+			return l.nums[i] > c.nums[i]
+		}
+	}
+	// 版本号相同时正式版比预发布版新
+	if (l.pre == "") != (c.pre == "") {
+		return l.pre == ""
+	}
+	return l.pre > c.pre
+}
+
+type semver struct {
+	nums [3]int
+	pre  string
+}
+
+func parse(v string) (semver, bool) {
+	var s semver
+	v = strings.TrimPrefix(v, "v")
+	v, _, _ = strings.Cut(v, "+")
+	v, s.pre, _ = strings.Cut(v, "-")
+	parts := strings.Split(v, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return s, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return s, false
+		}
+		s.nums[i] = n
+	}
+	return s, true
+}
+
+// Save 保存检查结果
+func (r Result) Save(file string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// Load 读取最近一次检查的结果，没有检查过时返回 false
+func Load(file string) (Result, bool) {
+	var r Result
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return r, false
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, false
+	}
+	return r, true
+}
+
+// Start 在后台按间隔检查新版本，结果写入 file，发现新版本时记录日志
+func Start(cfg config.UpdateCheckConfig, file string, stop <-chan struct{}) {
+	if !cfg.Enabled {
+		return
+	}
+	interval := time.Duration(cfg.IntervalHours) * time.Hour
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+	go func() {
+		// 上次检查的结果还没有过期时不立即检查，避免频繁重启时反复查询
+		wait := time.Duration(0)
+		if last, ok := Load(file); ok && last.Current == Current() {
+			wait = max(time.Until(last.Checked.Add(interval)), 0)
+		}
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-timer.C:
+			}
+			r := Check(cfg)
+			switch {
+			case r.Error != "":
+				logger.Warnf("检查新版本失败: %s", r.Error)
+			case r.Newer:
+				logger.Infof("有新版本可用: %s（当前 %s）%s", r.Latest, r.Current, r.URL)
+			}
+			if err := r.Save(file); err != nil {
+				logger.Errorf("保存版本检查结果失败: %v", err)
+			}
+			timer.Reset(interval)
+		}
+	}()
+}
@@ -0,0 +1,92 @@
+package watcher
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/mount"
+)
+
+// automounter 按卷指定的源目录插入后没有被系统挂载时由程序挂载，扫描完成后卸载
+// 只在监控的 goroutine 中使用，单次运行模式中只在 RunOnce 中使用
+type automounter struct {
+	task     config.Config
+	dir      string // 卷的挂载点，位于 mount_dir 中以 UUID 或卷标命名的子目录
+	mounted  bool   // 当前的挂载由程序挂载，扫描完成后卸载
+	released bool   // 扫描完成后已卸载，卷拔出之前不再挂载
+}
+
+func newAutomounter(task config.Config) *automounter {
+	uuid, label, _ := task.SourceVolume()
+	name := uuid
+	if name == "" {
+		name = label
+	}
+	name = strings.NewReplacer("/", "_", `\`, "_", ":", "_").Replace(name)
+	return &automounter{task: task, dir: filepath.Join(task.Automount.MountDir, name)}
+}
+
+// locate 查找源目录当前的路径，卷已插入但没有挂载时挂载到 mount_dir 中，用于 Watcher.resolveSource
+func (a *automounter) locate() (string, error) {
+	dir, err := a.task.ResolveSource()
+	if err == nil {
+		// 之前的运行挂载后没有卸载，同样在扫描完成后卸载
+		if within(dir, a.dir) {
+			a.mounted = true
+		}
+		return dir, nil
+	}
+	if !errors.Is(err, mount.ErrVolumeMissing) {
+		return "", err
+	}
+	uuid, label, sub := a.task.SourceVolume()
+	device, err := mount.VolumeDevice(uuid, label)
+	if err != nil {
+		// 卷已拔出，没来得及卸载的挂载已经失效，下次插入时重新挂载
+		if a.mounted {
+			a.mounted = false
+			mount.UnmountVolume(a.dir)
+		}
+		a.released = false
+		return "", err
+	}
+	if a.released {
+		return "", mount.ErrVolumeMissing
+	}
+	if err := mount.MountVolume(device, a.dir, a.task.Automount.FSType, a.task.Automount.Options); err != nil {
+		return "", i18n.Errorf("挂载 %s 到 %s 失败: %w", device, a.dir, err)
+	}
+	a.mounted = true
+	logger.Infof("已挂载 %s 到 %s", device, a.dir)
+	return filepath.Join(a.dir, filepath.FromSlash(sub)), nil
+}
+
+// release 卸载由程序挂载的卷，卷拔出之前不再挂载；卸载失败时保持挂载，返回 false
+func (a *automounter) release() bool {
+	if !a.mounted {
+		return false
+	}
+	if err := mount.UnmountVolume(a.dir); err != nil {
+		logger.Errorf("卸载 %s 失败: %v", a.dir, err)
+		return false
+	}
+	a.mounted = false
+	a.released = true
+	logger.Infof("扫描完成，已卸载 %s", a.dir)
+	return true
+}
+
+// releaseVolume 自动挂载的卷扫描完成后卸载，之后视为离线，卷拔出再插入或收到扫描请求时重新挂载
+func (w *Watcher) releaseVolume() {
+	if w.automount == nil || !w.automount.release() {
+		return
+	}
+	w.statusMu.Lock()
+	w.status.IsLastCheckExists = false
+	w.statusMu.Unlock()
+	w.stopRealtime()
+	w.source = ""
+}
@@ -0,0 +1,79 @@
+package watcher
+
+// Rescan 请求立即完整扫描一次，在监控协程中执行；源目录不在线时忽略，正在扫描时在本次扫描结束后执行
+func (w *Watcher) Rescan() {
+	select {
+	case w.rescanChan <- struct{}{}:
+	default:
+	}
+}
+
+// Check 请求立即检查源目录是否出现或离线，与定期检查相同，插入或拔出磁盘时调用
+func (w *Watcher) Check() {
+	select {
+	case w.checkChan <- struct{}{}:
+	default:
+	}
+}
+
+// rescanNow 视为源目录重新挂载，按常规检查的流程扫描
+func (w *Watcher) rescanNow() {
+	if w.Status().Paused {
+		logger.Warnf("监控已暂停，忽略扫描请求: %s", w.sourceDir)
+		return
+	}
+	logger.Infof("收到扫描请求: %s", w.sourceDir)
+	w.statusMu.Lock()
+	w.status.IsLastCheckExists = false
+	w.statusMu.Unlock()
+	if w.automount != nil {
+		// 扫描完成后已卸载的卷重新挂载
+		w.automount.released = false
+	}
+	if err := w.checkDirectoryExists(); err != nil {
+		logger.Errorf("检查目录失败: %v", err)
+	}
+}
+
+// Pause 暂停监控，正在进行的扫描在复制下一个文件之前等待，恢复之前不检查源目录
+func (w *Watcher) Pause() {
+	w.pauseMu.Lock()
+	defer w.pauseMu.Unlock()
+	if w.resumed != nil {
+		return
+	}
+	w.resumed = make(chan struct{})
+	w.statusMu.Lock()
+	w.status.Paused = true
+	w.statusMu.Unlock()
+	logger.Warnf("已暂停监控: %s", w.sourceDir)
+}
+
+// Resume 恢复暂停的监控
+func (w *Watcher) Resume() {
+	w.pauseMu.Lock()
+	defer w.pauseMu.Unlock()
+	if w.resumed == nil {
+		return
+	}
+	close(w.resumed)
+	w.resumed = nil
+	w.statusMu.Lock()
+	w.status.Paused = false
+	w.statusMu.Unlock()
+	logger.Infof("已恢复监控: %s", w.sourceDir)
+}
+
+// waitResumed 暂停时等待恢复或程序停止
+func (w *Watcher) waitResumed() {
+	w.pauseMu.Lock()
+	resumed := w.resumed
+	w.pauseMu.Unlock()
+	if resumed == nil {
+		return
+	}
+	select {
+	case <-resumed:
+	case <-w.stopChan:
+	}
+}
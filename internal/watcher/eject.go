@@ -0,0 +1,81 @@
+package watcher
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/lucasrui/neo-nas/internal/mount"
+)
+
+// finishScan 扫描结束后按配置弹出设备，或者卸载自动挂载的卷
+// 只在扫描成功且没有文件失败时弹出，失败时保持插入，便于检查原因
+func (w *Watcher) finishScan(err error) {
+	if w.eject && err == nil && w.status.FailedFiles == 0 && w.ejectVolume() {
+		return
+	}
+	w.releaseVolume()
+}
+
+// ejectVolume 同步、卸载并断开源目录所在设备的电源，之后视为离线并标记为可以拔出，返回是否已卸载
+func (w *Watcher) ejectVolume() bool {
+	if w.backupMgr.DryRun().Skip("弹出源目录所在的设备: %s", w.sourceDir) {
+		return false
+	}
+	device, err := mount.Eject(w.source)
+	if err != nil {
+		logger.Errorf("弹出源目录所在的设备失败 %s: %v", w.sourceDir, err)
+		return false
+	}
+	if err := mount.PowerOff(device); err != nil {
+		// 已经卸载，数据都已写入，断电失败不影响拔出
+		logger.Warnf("已卸载 %s，但没有断开电源: %v", device, err)
+	}
+	// 卸载后留下的空挂载点，再次出现的不是它时才算重新插入
+	w.ejectedStale, _ = os.Stat(w.source)
+	if w.automount != nil {
+		w.automount.mounted = false
+		w.automount.released = true
+		os.Remove(w.automount.dir)
+	}
+	w.statusMu.Lock()
+	w.status.IsLastCheckExists = false
+	w.status.SafeToUnplug = true
+	w.statusMu.Unlock()
+	w.stopRealtime()
+	if w.resolveSource != nil {
+		w.source = ""
+	}
+	logger.Infof("备份完成，已弹出 %s，可以拔出: %s", device, w.sourceDir)
+	w.runEjectHook("on")
+	return true
+}
+
+// replugged 弹出后源目录再次出现时调用，info 为源目录的信息；返回 false 表示只是弹出后留下的空挂载点
+func (w *Watcher) replugged(info os.FileInfo) bool {
+	if !w.status.SafeToUnplug {
+		return true
+	}
+	if w.ejectedStale != nil && os.SameFile(w.ejectedStale, info) {
+		return false
+	}
+	w.ejectedStale = nil
+	w.statusMu.Lock()
+	w.status.SafeToUnplug = false
+	w.statusMu.Unlock()
+	w.runEjectHook("off")
+	return true
+}
+
+// runEjectHook 在后台执行 eject_hook，参数为 on 或 off，环境变量 NEO_SOURCE 为配置中的源目录
+func (w *Watcher) runEjectHook(state string) {
+	if w.ejectHook == "" {
+		return
+	}
+	cmd := exec.Command(w.ejectHook, state)
+	cmd.Env = append(os.Environ(), "NEO_SOURCE="+w.sourceDir)
+	go func() {
+		if out, err := cmd.CombinedOutput(); err != nil {
+			logger.Errorf("执行 eject_hook 失败 %s %s: %v: %s", w.ejectHook, state, err, out)
+		}
+	}()
+}
@@ -0,0 +1,105 @@
+//go:build darwin && cgo
+
+package watcher
+
+/*
+#cgo LDFLAGS: -framework CoreServices
+#include <stdlib.h>
+#include <CoreServices/CoreServices.h>
+#include <dispatch/dispatch.h>
+
+extern void goFSEvents(uintptr_t handle, size_t n, char **paths, FSEventStreamEventFlags *flags);
+
+static void fseventsCallback(ConstFSEventStreamRef stream, void *info, size_t n, void *paths,
+	const FSEventStreamEventFlags flags[], const FSEventStreamEventId ids[]) {
+	goFSEvents((uintptr_t)info, n, (char **)paths, (FSEventStreamEventFlags *)flags);
+}
+
+// fseventsStart 监控 path 及其所有子目录，回调在 queue 上依次执行
+static FSEventStreamRef fseventsStart(const char *path, uintptr_t handle, dispatch_queue_t queue, double latency) {
+	CFStringRef p = CFStringCreateWithCString(NULL, path, kCFStringEncodingUTF8);
+	if (p == NULL) {
+		return NULL;
+	}
+	CFArrayRef paths = CFArrayCreate(NULL, (const void **)&p, 1, &kCFTypeArrayCallBacks);
+	FSEventStreamContext ctx = {0, (void *)handle, NULL, NULL, NULL};
+	FSEventStreamRef s = FSEventStreamCreate(NULL, fseventsCallback, &ctx, paths, kFSEventStreamEventIdSinceNow,
+		latency, kFSEventStreamCreateFlagFileEvents | kFSEventStreamCreateFlagNoDefer);
+	CFRelease(paths);
+	CFRelease(p);
+	if (s == NULL) {
+		return NULL;
+	}
+	FSEventStreamSetDispatchQueue(s, queue);
+	if (!FSEventStreamStart(s)) {
+		FSEventStreamInvalidate(s);
+		FSEventStreamRelease(s);
+		return NULL;
+	}
+	return s;
+}
+
+static void fseventsStop(FSEventStreamRef s) {
+	FSEventStreamStop(s);
+	FSEventStreamInvalidate(s);
+	FSEventStreamRelease(s);
+}
+
+static void fseventsNoop(void *ctx) {
+}
+
+static dispatch_queue_t fseventsNewQueue(void) {
+	return dispatch_queue_create("neo-nas.fsevents", DISPATCH_QUEUE_SERIAL);
+}
+
+// fseventsRelease 等待已经开始的回调执行完后释放队列
+static void fseventsRelease(dispatch_queue_t queue) {
+	dispatch_sync_f(queue, NULL, fseventsNoop);
+	dispatch_release(queue);
+}
+*/
+import "C"
+
+import (
+	"time"
+	"unsafe"
+)
+
+// fseventsLatency FSEvents 合并通知的时间，文件稳定前还要再等待 settleDelay
+const fseventsLatency = 500 * time.Millisecond
+
+// FSEvents 的事件标志
+const (
+	fsMustScanSubDirs = C.kFSEventStreamEventFlagMustScanSubDirs
+	fsUserDropped     = C.kFSEventStreamEventFlagUserDropped
+	fsKernelDropped   = C.kFSEventStreamEventFlagKernelDropped
+	fsItemCreated     = C.kFSEventStreamEventFlagItemCreated
+	fsItemRenamed     = C.kFSEventStreamEventFlagItemRenamed
+	fsItemModified    = C.kFSEventStreamEventFlagItemModified
+	fsItemIsFile      = C.kFSEventStreamEventFlagItemIsFile
+	fsItemIsDir       = C.kFSEventStreamEventFlagItemIsDir
+	fsItemIsSymlink   = C.kFSEventStreamEventFlagItemIsSymlink
+)
+
+type fseventsQueue = C.dispatch_queue_t
+
+type fseventsStream = C.FSEventStreamRef
+
+func newFSEventsQueue() fseventsQueue {
+	return C.fseventsNewQueue()
+}
+
+func releaseFSEventsQueue(q fseventsQueue) {
+	C.fseventsRelease(q)
+}
+
+// startFSEvents 开始监控 path，handle 在回调中用于找到对应的 fsevents；失败时返回 nil
+func startFSEvents(path string, handle uintptr, q fseventsQueue) fseventsStream {
+	p := C.CString(path)
+	defer C.free(unsafe.Pointer(p))
+	return C.fseventsStart(p, C.uintptr_t(handle), q, C.double(fseventsLatency.Seconds()))
+}
+
+func stopFSEvents(s fseventsStream) {
+	C.fseventsStop(s)
+}
@@ -0,0 +1,31 @@
+package watcher
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// errNotifyUnsupported 当前平台不支持实时监控，只在源目录出现时扫描
+var errNotifyUnsupported = i18n.New("当前平台不支持实时监控")
+
+// notifyEvent 源目录中的一次变化
+type notifyEvent struct {
+	path     string
+	dir      bool // 新建或移入的目录，需要扫描其中的内容
+	overflow bool // 通知队列溢出，丢失了部分变化，需要完整扫描
+}
+
+// notifier 监控目录中文件写入完成、移入和新建子目录，子目录需要逐个添加
+type notifier interface {
+	Add(dir string) error
+	Events() <-chan notifyEvent
+	Close() error
+}
+
+// within 判断 path 是否为 root 或其中的文件，按目录树监控的实现用于跳过已经覆盖的子目录
+func within(path, root string) bool {
+	sep := string(filepath.Separator)
+	return path == root || strings.HasPrefix(path, strings.TrimSuffix(root, sep)+sep)
+}
@@ -0,0 +1,140 @@
+//go:build darwin && cgo
+
+package watcher
+
+// #include <CoreServices/CoreServices.h>
+import "C"
+
+import (
+	"path/filepath"
+	"runtime/cgo"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// fsevents 基于 macOS FSEvents 的实时监控
+// FSEvents 按目录树监控，添加源目录后其中的子目录不需要再逐个添加；没有写入完成的通知，写入中的文件会多次通知，由 settleDelay 合并
+type fsevents struct {
+	mu     sync.Mutex
+	closed bool
+	roots  []fseventsRoot
+	handle cgo.Handle // 回调中用于找到对应的 fsevents
+	queue  fseventsQueue
+	done   chan struct{}
+	events chan notifyEvent
+}
+
+// fseventsRoot 一个 FSEvents 监控的目录树
+type fseventsRoot struct {
+	dir      string // 添加时的路径
+	resolved string // 解析符号链接后的路径，通知中使用该路径
+	stream   fseventsStream
+}
+
+func newNotifier() (notifier, error) {
+	n := &fsevents{
+		queue:  newFSEventsQueue(),
+		done:   make(chan struct{}),
+		events: make(chan notifyEvent, 1024),
+	}
+	n.handle = cgo.NewHandle(n)
+	return n, nil
+}
+
+// Add 监控目录及其所有子目录，已在监控中的目录不重复添加
+func (n *fsevents) Add(dir string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return nil
+	}
+	for _, r := range n.roots {
+		if within(dir, r.dir) {
+			return nil
+		}
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	s := startFSEvents(resolved, uintptr(n.handle), n.queue)
+	if s == nil {
+		return i18n.Errorf("启动 FSEvents 监控失败: %s", dir)
+	}
+	n.roots = append(n.roots, fseventsRoot{dir: dir, resolved: resolved, stream: s})
+	return nil
+}
+
+func (n *fsevents) Events() <-chan notifyEvent {
+	return n.events
+}
+
+// Close 停止所有的监控，等待正在执行的回调返回后关闭通知
+func (n *fsevents) Close() error {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return nil
+	}
+	n.closed = true
+	roots := n.roots
+	n.roots = nil
+	n.mu.Unlock()
+
+	close(n.done)
+	for _, r := range roots {
+		stopFSEvents(r.stream)
+	}
+	releaseFSEventsQueue(n.queue)
+	n.handle.Delete()
+	close(n.events)
+	return nil
+}
+
+// event 把一条 FSEvents 通知转换为 notifyEvent，删除等不需要处理的变化返回 false
+func (n *fsevents) event(path string, flags uint32) (notifyEvent, bool) {
+	if flags&(fsMustScanSubDirs|fsUserDropped|fsKernelDropped) != 0 {
+		return notifyEvent{overflow: true}, true
+	}
+	n.mu.Lock()
+	for _, r := range n.roots {
+		if r.resolved != r.dir && within(path, r.resolved) {
+			path = r.dir + strings.TrimPrefix(path, r.resolved)
+			break
+		}
+	}
+	n.mu.Unlock()
+	switch {
+	case flags&fsItemIsDir != 0:
+		if flags&(fsItemCreated|fsItemRenamed) != 0 {
+			return notifyEvent{path: path, dir: true}, true
+		}
+	case flags&(fsItemIsFile|fsItemIsSymlink) != 0:
+		if flags&(fsItemCreated|fsItemModified|fsItemRenamed) != 0 {
+			return notifyEvent{path: path}, true
+		}
+	}
+	return notifyEvent{}, false
+}
+
+//export goFSEvents
+func goFSEvents(handle C.uintptr_t, count C.size_t, paths **C.char, flags *C.FSEventStreamEventFlags) {
+	n := cgo.Handle(handle).Value().(*fsevents)
+	ps := unsafe.Slice(paths, int(count))
+	fs := unsafe.Slice(flags, int(count))
+	for i := range ps {
+		ev, ok := n.event(C.GoString(ps[i]), uint32(fs[i]))
+		if !ok {
+			continue
+		}
+		// 通知队列满时阻塞 FSEvents 的队列，积压过多时 FSEvents 会丢弃通知并报告溢出
+		select {
+		case n.events <- ev:
+		case <-n.done:
+			return
+		}
+	}
+}
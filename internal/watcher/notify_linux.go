@@ -0,0 +1,113 @@
+//go:build linux
+
+package watcher
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// inotifyMask 只关心写入完成和移入，刚创建还在写入中的文件不处理
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE | syscall.IN_ONLYDIR
+
+// inotify 基于 Linux inotify 的实时监控
+type inotify struct {
+	fd     int
+	f      *os.File
+	mu     sync.Mutex
+	closed bool
+	dirs   map[int32]string // 监控描述符对应的目录
+	events chan notifyEvent
+}
+
+func newNotifier() (notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, i18n.Errorf("初始化 inotify 失败: %w", err)
+	}
+	n := &inotify{
+		// 非阻塞的描述符由运行时轮询，关闭时读取立即返回
+		// 之后不能再调用 f.Fd()，否则描述符会被改回阻塞模式
+		fd:     fd,
+		f:      os.NewFile(uintptr(fd), "inotify"),
+		dirs:   make(map[int32]string),
+		events: make(chan notifyEvent, 1024),
+	}
+	go n.read()
+	return n, nil
+}
+
+// Add 监控目录，目录被移动后再次添加时更新对应的路径
+func (n *inotify) Add(dir string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return nil
+	}
+	wd, err := syscall.InotifyAddWatch(n.fd, dir, inotifyMask)
+	if err != nil {
+		if err == syscall.ENOSPC {
+			return i18n.Errorf("监控的目录数量达到上限，可以调大 fs.inotify.max_user_watches: %w", err)
+		}
+		return err
+	}
+	n.dirs[int32(wd)] = dir
+	return nil
+}
+
+func (n *inotify) Events() <-chan notifyEvent {
+	return n.events
+}
+
+func (n *inotify) Close() error {
+	n.mu.Lock()
+	n.closed = true
+	n.mu.Unlock()
+	return n.f.Close()
+}
+
+func (n *inotify) read() {
+	defer close(n.events)
+	buf := make([]byte, 64<<10)
+	for {
+		size, err := n.f.Read(buf)
+		if err != nil {
+			return
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= size; {
+			wd := int32(binary.NativeEndian.Uint32(buf[off:]))
+			mask := binary.NativeEndian.Uint32(buf[off+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[off+12:]))
+			name := string(bytes.TrimRight(buf[off+syscall.SizeofInotifyEvent:off+syscall.SizeofInotifyEvent+nameLen], "\x00"))
+			off += syscall.SizeofInotifyEvent + nameLen
+
+			if mask&syscall.IN_Q_OVERFLOW != 0 {
+				n.events <- notifyEvent{overflow: true}
+				continue
+			}
+			n.mu.Lock()
+			dir, ok := n.dirs[wd]
+			if mask&syscall.IN_IGNORED != 0 {
+				delete(n.dirs, wd)
+			}
+			n.mu.Unlock()
+			if !ok || name == "" {
+				continue
+			}
+			path := filepath.Join(dir, name)
+			if mask&syscall.IN_ISDIR != 0 {
+				if mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+					n.events <- notifyEvent{path: path, dir: true}
+				}
+			} else if mask&(syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO) != 0 {
+				n.events <- notifyEvent{path: path}
+			}
+		}
+	}
+}
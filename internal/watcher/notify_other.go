@@ -0,0 +1,19 @@
+//go:build !linux && !windows && !(darwin && cgo)
+
+package watcher
+
+import (
+	"runtime"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// errNotifyNoCgo macOS 的 FSEvents 需要通过 cgo 调用
+var errNotifyNoCgo = i18n.New("编译时没有启用 cgo，无法使用 FSEvents")
+
+func newNotifier() (notifier, error) {
+	if runtime.GOOS == "darwin" {
+		return nil, errNotifyNoCgo
+	}
+	return nil, errNotifyUnsupported
+}
@@ -0,0 +1,185 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// rdcMask 文件和目录的新建、改名以及写入，删除不需要处理
+const rdcMask = syscall.FILE_NOTIFY_CHANGE_FILE_NAME | syscall.FILE_NOTIFY_CHANGE_DIR_NAME | syscall.FILE_NOTIFY_CHANGE_LAST_WRITE
+
+// errorNotifyEnumDir ERROR_NOTIFY_ENUM_DIR，与读取到 0 字节相同，表示变化太多需要重新扫描
+const errorNotifyEnumDir = syscall.Errno(1022)
+
+// rdc 基于 ReadDirectoryChangesW 的实时监控
+// 按目录树监控，添加源目录后其中的子目录不需要再逐个添加；没有写入完成的通知，写入中的文件会多次通知，由 settleDelay 合并
+type rdc struct {
+	port    syscall.Handle // 所有目录共用的完成端口，由 read 协程读取
+	mu      sync.Mutex
+	closed  bool
+	nextKey uint32
+	roots   map[uint32]*rdcRoot // 完成端口的键对应的目录
+	events  chan notifyEvent
+}
+
+// rdcRoot 一个监控中的目录树
+type rdcRoot struct {
+	buf    [64 << 10]byte // 放在第一个字段，保证 ReadDirectoryChangesW 要求的 4 字节对齐；网络共享上不能超过 64KB
+	ov     syscall.Overlapped
+	dir    string
+	handle syscall.Handle
+}
+
+func newNotifier() (notifier, error) {
+	port, err := syscall.CreateIoCompletionPort(syscall.InvalidHandle, 0, 0, 0)
+	if err != nil {
+		return nil, i18n.Errorf("创建完成端口失败: %w", err)
+	}
+	n := &rdc{
+		port:   port,
+		roots:  make(map[uint32]*rdcRoot),
+		events: make(chan notifyEvent, 1024),
+	}
+	go n.read()
+	return n, nil
+}
+
+// Add 监控目录及其所有子目录，已在监控中的目录不重复添加
+func (n *rdc) Add(dir string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return nil
+	}
+	for _, r := range n.roots {
+		if within(dir, r.dir) {
+			return nil
+		}
+	}
+	p, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return err
+	}
+	h, err := syscall.CreateFile(p, syscall.FILE_LIST_DIRECTORY,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE, nil,
+		syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS|syscall.FILE_FLAG_OVERLAPPED, 0)
+	if err != nil {
+		return err
+	}
+	// 键从 1 开始，0 留给 Close 唤醒 read 协程
+	n.nextKey++
+	if _, err := syscall.CreateIoCompletionPort(h, n.port, n.nextKey, 0); err != nil {
+		syscall.CloseHandle(h)
+		return err
+	}
+	r := &rdcRoot{dir: dir, handle: h}
+	if err := r.watch(); err != nil {
+		syscall.CloseHandle(h)
+		return err
+	}
+	n.roots[n.nextKey] = r
+	return nil
+}
+
+// watch 开始下一次读取，结果由完成端口返回
+func (r *rdcRoot) watch() error {
+	r.ov = syscall.Overlapped{}
+	return syscall.ReadDirectoryChanges(r.handle, &r.buf[0], uint32(len(r.buf)), true, rdcMask, nil, &r.ov, 0)
+}
+
+func (n *rdc) Events() <-chan notifyEvent {
+	return n.events
+}
+
+// Close 取消所有目录的读取，read 协程收到全部取消结果后关闭通知
+func (n *rdc) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return nil
+	}
+	n.closed = true
+	for _, r := range n.roots {
+		syscall.CancelIoEx(r.handle, &r.ov)
+	}
+	return syscall.PostQueuedCompletionStatus(n.port, 0, 0, nil)
+}
+
+func (n *rdc) read() {
+	defer close(n.events)
+	defer syscall.CloseHandle(n.port)
+	for {
+		var size, key uint32
+		var ov *syscall.Overlapped
+		err := syscall.GetQueuedCompletionStatus(n.port, &size, &key, &ov, syscall.INFINITE)
+		n.mu.Lock()
+		r := n.roots[key]
+		if r == nil {
+			done := n.closed && len(n.roots) == 0
+			n.mu.Unlock()
+			if done {
+				return
+			}
+			continue
+		}
+		n.mu.Unlock()
+
+		if err == errorNotifyEnumDir {
+			err = nil
+		}
+		switch {
+		case err != nil:
+			// 已取消，或者设备被拔出、共享断开，由定期检查发现源目录离线
+		case size == 0:
+			// 缓冲区放不下这段时间的所有变化
+			n.events <- notifyEvent{overflow: true}
+		default:
+			r.parse(size, n.events)
+		}
+
+		n.mu.Lock()
+		if err != nil || n.closed || r.watch() != nil {
+			syscall.CloseHandle(r.handle)
+			delete(n.roots, key)
+		}
+		done := n.closed && len(n.roots) == 0
+		n.mu.Unlock()
+		if done {
+			return
+		}
+	}
+}
+
+// parse 解析一次读取到的 FILE_NOTIFY_INFORMATION 列表
+func (r *rdcRoot) parse(size uint32, events chan<- notifyEvent) {
+	for off := uint32(0); off < size; {
+		info := (*syscall.FileNotifyInformation)(unsafe.Pointer(&r.buf[off]))
+		name := syscall.UTF16ToString(unsafe.Slice(&info.FileName, info.FileNameLength/2))
+		path := filepath.Join(r.dir, name)
+		switch info.Action {
+		case syscall.FILE_ACTION_ADDED, syscall.FILE_ACTION_RENAMED_NEW_NAME, syscall.FILE_ACTION_MODIFIED:
+			st, err := os.Lstat(path)
+			if err != nil {
+				// 已被删除或移走
+				break
+			}
+			if st.IsDir() {
+				// 目录中的文件变化时目录本身也会通知修改，只处理新建和移入
+				if info.Action != syscall.FILE_ACTION_MODIFIED {
+					events <- notifyEvent{path: path, dir: true}
+				}
+			} else {
+				events <- notifyEvent{path: path}
+			}
+		}
+		if info.NextEntryOffset == 0 {
+			break
+		}
+		off += info.NextEntryOffset
+	}
+}
@@ -0,0 +1,175 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/backup"
+)
+
+// settleDelay 文件最后一次变化后等待的时间，连续多次写入同一文件时只备份一次
+const settleDelay = 2 * time.Second
+
+// change 等待处理的变化
+type change struct {
+	at  time.Time
+	dir bool
+}
+
+// startRealtime 源目录出现后开始实时监控，在首次扫描之前调用，扫描时逐个添加目录
+func (w *Watcher) startRealtime() {
+	if !w.realtime || w.notify != nil {
+		return
+	}
+	n, err := newNotifier()
+	if err != nil {
+		logger.Warnf("无法启用实时监控，只在源目录出现时扫描 %s: %v", w.sourceDir, err)
+		return
+	}
+	if w.networkSource {
+		logger.Infof("源目录位于网络共享上，其他机器上的修改不会产生通知: %s", w.sourceDir)
+	}
+	w.notify = n
+	w.pending = make(map[string]change)
+	logger.Infof("已启用实时监控: %s", w.sourceDir)
+}
+
+// stopRealtime 源目录离线或程序停止时关闭实时监控，未处理的变化在下次扫描时备份
+func (w *Watcher) stopRealtime() {
+	if w.notify == nil {
+		return
+	}
+	w.notify.Close()
+	for range w.notify.Events() {
+	}
+	w.notify = nil
+	w.pending = nil
+}
+
+// watchDir 扫描到的目录加入实时监控，失败时只记录一次日志
+func (w *Watcher) watchDir(dir string) {
+	if w.notify == nil {
+		return
+	}
+	if err := w.notify.Add(dir); err != nil {
+		w.notifyErr.Do(func() {
+			logger.Warnf("添加实时监控失败，部分目录的变化要等到下次扫描才会备份 %s: %v", dir, err)
+		})
+	}
+}
+
+// queueChange 记录一次变化，等文件稳定后再处理
+func (w *Watcher) queueChange(ev notifyEvent) {
+	if ev.overflow {
+		logger.Warnf("实时监控的通知过多，部分变化已丢失，重新扫描: %s", w.sourceDir)
+		w.rescan = true
+		return
+	}
+	if w.backupMgr.Excluded(ev.path) {
+		return
+	}
+	c := w.pending[ev.path]
+	c.at = time.Now()
+	c.dir = c.dir || ev.dir
+	w.pending[ev.path] = c
+}
+
+// flushChanges 处理已经稳定的变化，新目录扫描其中的内容，文件直接备份
+func (w *Watcher) flushChanges() {
+	if w.notify == nil {
+		return
+	}
+	if w.rescan {
+		w.rescan = false
+		clear(w.pending)
+		w.statusMu.Lock()
+		w.status.IsBackingUp = true
+		w.statusMu.Unlock()
+		w.scanDirectory()
+		return
+	}
+	for path, c := range w.pending {
+		if time.Since(c.at) < settleDelay {
+			continue
+		}
+		delete(w.pending, path)
+		select {
+		case <-w.stopChan:
+			return
+		default:
+		}
+		w.applyChange(path, c)
+	}
+}
+
+func (w *Watcher) applyChange(path string, c change) {
+	info, err := os.Stat(path)
+	if err != nil {
+		// 已被删除或移走
+		return
+	}
+	targetPath := w.backupMgr.BuildTargetPath(path)
+	if targetPath == "" {
+		return
+	}
+	if !w.ensureParent(path, targetPath) {
+		return
+	}
+	if info.IsDir() {
+		w.scanChangedDir(path, targetPath, info)
+		return
+	}
+	if !w.backupMgr.Included(path) {
+		return
+	}
+	switch w.backupMgr.BackupChanged(path) {
+	case backup.Failed:
+		logger.Errorf("备份文件失败: %v", path)
+	case backup.Success:
+		w.statusMu.Lock()
+		w.status.LastSync = time.Now()
+		w.statusMu.Unlock()
+	}
+}
+
+// ensureParent 目标中还没有文件所在的目录时创建，扫描时没有文件的新目录会被删除
+func (w *Watcher) ensureParent(path, targetPath string) bool {
+	if w.backupMgr.IsRemote() {
+		return true
+	}
+	parent := filepath.Dir(targetPath)
+	if _, err := os.Stat(parent); err == nil {
+		return true
+	}
+	if w.backupMgr.DryRun().Skip("创建目录: %s", parent) {
+		return true
+	}
+	mode := os.FileMode(0755)
+	if info, err := os.Stat(filepath.Dir(path)); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(parent, mode); err != nil {
+		logger.Errorf("创建目标目录失败: %v", err)
+		return false
+	}
+	return true
+}
+
+// scanChangedDir 扫描新建或移入的目录，其中的文件同样不按上次同步时间跳过
+func (w *Watcher) scanChangedDir(path, targetPath string, info os.FileInfo) {
+	w.changed = true
+	defer func() { w.changed = false }()
+	jobs := make(chan fileJob, queueSize)
+	copied := make(chan struct{})
+	go func() {
+		w.copyFiles(jobs)
+		close(copied)
+	}()
+	err := w.scanDir(path, targetPath, info, jobs)
+	close(jobs)
+	<-copied
+	if err != nil && err != errStopped {
+		logger.Errorf("扫描新目录失败 %s: %v", path, err)
+	}
+}
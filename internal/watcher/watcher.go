@@ -1,27 +1,74 @@
 package watcher
 
 import (
-	"fmt"
-	"log"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/lucasrui/neo-nas/internal/backoff"
 	"github.com/lucasrui/neo-nas/internal/backup"
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/history"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+	"github.com/lucasrui/neo-nas/internal/mount"
+	"github.com/lucasrui/neo-nas/internal/priority"
+	"github.com/lucasrui/neo-nas/internal/snapshot"
 )
 
+// logger watcher 模块的日志
+var logger = logging.New("watcher")
+
+// ErrSourceMissing 源目录不存在，例如 U 盘未插入
+var ErrSourceMissing = i18n.New("源目录不存在")
+
+// errStopped 程序正在停止，扫描中止
+var errStopped = i18n.New("扫描已中止")
+
 type Watcher struct {
-	sourceDir    string
-	targetDir    string
-	progressFile string
-	backupMgr    *backup.Manager
-	stopChan     chan struct{}
-	status       *DirectoryStatus
+	sourceDir     string                 // 配置中的源目录，按卷指定时为 UUID=... 或 LABEL=...
+	source        string                 // 扫描的路径，按卷指定时为卷当前的挂载点，没有插入时为空
+	resolveSource func() (string, error) // 按卷指定源目录时查找当前的挂载点，否则为 nil
+	automount     *automounter           // 开启 automount 时由程序挂载卷，否则为 nil
+	eject         bool                   // 扫描成功后弹出源目录所在的设备
+	ejectHook     string                 // 可以拔出的状态变化时执行的命令
+	ejectedStale  os.FileInfo            // 弹出后留下的空挂载点，没有时为 nil
+	targetDir     string
+	networkSource bool
+	probeTimeout  time.Duration
+	pollInterval  time.Duration // 检查源目录是否出现或离线的间隔
+	walkers       chan struct{} // 并行扫描子目录的协程数量，为 nil 时只在一个协程中扫描
+	copiers       int           // 同时复制文件的协程数量
+	backupMgr     *backup.Manager
+	snapshotter   *snapshot.Snapshotter
+	history       *history.Store
+	hostname      string
+	stopChan      chan struct{}
+	done          chan struct{} // 监控协程退出后关闭
+	status        *DirectoryStatus
+	statusMu      sync.Mutex        // 监控协程修改 status 时加锁，Status 可以在其他协程中读取
+	realtime      bool              // 源目录出现后实时监控其中的变化
+	notify        notifier          // 正在使用的实时监控，未启用或源目录离线时为 nil
+	notifyErr     sync.Once         // 添加监控失败时只记录一次日志
+	pending       map[string]change // 等待稳定后处理的变化
+	rescan        bool              // 通知丢失，需要完整扫描
+	changed       bool              // 正在扫描实时监控发现的新目录
+	rescanChan    chan struct{}     // 管理接口请求立即扫描
+	checkChan     chan struct{}     // 插入或拔出磁盘后请求立即检查
+	resumed       chan struct{}     // 暂停时不为 nil，恢复时关闭
+	pauseMu       sync.Mutex
 }
 
+// DirectoryStatus 监控目录的状态和最近一次扫描的统计
 type DirectoryStatus struct {
 	IsBackingUp       bool
 	IsLastCheckExists bool
+	IsSourceDown      bool // 网络共享暂时不可用
+	Paused            bool // 通过管理接口暂停
+	SafeToUnplug      bool // 扫描完成后已弹出设备，可以直接拔出，重新插入之前保持
 	LastSync          time.Time
 	TotalFiles        int
 	SuccessFiles      int
@@ -29,43 +76,145 @@ type DirectoryStatus struct {
 	SkippedFiles      int
 }
 
-func NewWatcher(sourceDir, targetDir, targetUser, progressFile string) (*Watcher, error) {
+func NewWatcher(cfg config.Config, opts backup.Options) (*Watcher, error) {
 	w := &Watcher{
-		sourceDir:    sourceDir,
-		targetDir:    targetDir,
-		progressFile: progressFile,
-		stopChan:     make(chan struct{}),
-		status:       &DirectoryStatus{},
+		sourceDir:     cfg.SourceDir,
+		source:        cfg.SourceDir,
+		targetDir:     cfg.TargetDir,
+		eject:         cfg.EjectAfterBackup,
+		ejectHook:     cfg.EjectHook,
+		history:       opts.History,
+		hostname:      opts.Hostname,
+		networkSource: cfg.NetworkSource,
+		probeTimeout:  time.Duration(cfg.ProbeTimeout) * time.Second,
+		stopChan:      make(chan struct{}),
+		rescanChan:    make(chan struct{}, 1),
+		checkChan:     make(chan struct{}, 1),
+		status:        &DirectoryStatus{},
+		realtime:      cfg.Realtime,
+	}
+	if w.probeTimeout <= 0 {
+		w.probeTimeout = 10 * time.Second
+	}
+	w.pollInterval = time.Duration(cfg.PollInterval) * time.Second
+	if w.pollInterval <= 0 {
+		w.pollInterval = 5 * time.Second
+	}
+	// 当前协程算作一个，其余的子目录最多同时在 scan_parallelism-1 个协程中扫描
+	if cfg.ScanParallelism > 1 {
+		w.walkers = make(chan struct{}, cfg.ScanParallelism-1)
 	}
+	w.copiers = max(cfg.Concurrency, 1)
 
-	// 创建备份管理器
+	// 开启 smb.mount 时共享挂载后与本机目录相同，备份状态和运行记录仍然按配置中的 smb:// 目标记录
+	// 否则备份管理器通过内置的客户端直接写入共享
 	var err error
-	w.backupMgr, err = backup.NewManager(sourceDir, targetDir, targetUser, progressFile)
+	if cfg.IsSMBTarget() && opts.SMB.Enabled() {
+		if cfg.TargetDir, err = opts.SMB.Mount(cfg.TargetDir); err != nil {
+			return w, err
+		}
+	}
 
+	// 创建备份管理器
+	w.backupMgr, err = backup.NewManager(cfg, opts)
+	if err != nil {
+		return w, err
+	}
+	if cfg.IsVolumeSource() {
+		w.source = ""
+		w.resolveSource = cfg.ResolveSource
+	}
+	if cfg.Automount.Enabled {
+		if !cfg.IsVolumeSource() {
+			return w, i18n.Errorf("自动挂载只能用于按 UUID 或卷标指定的源目录")
+		}
+		if !mount.AutomountSupported {
+			return w, mount.ErrAutomountUnsupported
+		}
+		w.automount = newAutomounter(cfg)
+		w.resolveSource = w.automount.locate
+	}
+
+	if cfg.Snapshot.Type != "" && !cfg.IsLocalTarget() {
+		return w, i18n.Errorf("推送到服务端或远程存储的目标不支持快照")
+	}
+	// 不可变的文件不能再创建硬链接，快照中的链接也无法删除
+	if cfg.Snapshot.Type == "hardlink" && cfg.Immutable {
+		return w, i18n.Errorf("硬链接快照不能与不可变属性同时使用")
+	}
+	w.snapshotter, err = snapshot.New(cfg.Snapshot, cfg.TargetDir, opts.Hostname)
 	return w, err
 }
 
 func (w *Watcher) Start() error {
+	w.done = make(chan struct{})
 	go w.checkDirectory()
 	return nil
 }
 
+// Status 返回当前状态的副本
+func (w *Watcher) Status() DirectoryStatus {
+	w.statusMu.Lock()
+	defer w.statusMu.Unlock()
+	return *w.status
+}
+
+// SourceDir 监控的源目录
+func (w *Watcher) SourceDir() string {
+	return w.sourceDir
+}
+
+// TargetDir 备份的目标目录
+func (w *Watcher) TargetDir() string {
+	return w.targetDir
+}
+
+// Stop 中止正在进行的扫描，等待正在复制的文件完成后返回
 func (w *Watcher) Stop() error {
 	close(w.stopChan)
+	if w.done != nil {
+		<-w.done
+	}
+	w.backupMgr.WaitForCompletion()
+	w.statusMu.Lock()
 	w.status.IsBackingUp = false
-	log.Printf("停止监控目录: %s", w.sourceDir)
+	w.statusMu.Unlock()
+	logger.Infof("停止监控目录: %s", w.sourceDir)
 	return nil
 }
 
+// checkDirectory 按 poll_interval_seconds 检查源目录是否出现或离线，启用实时监控时同时处理变化通知
 func (w *Watcher) checkDirectory() {
-	ticker := time.NewTicker(5 * time.Second)
+	priority.Lower()
+	ticker := time.NewTicker(w.pollInterval)
 	defer ticker.Stop()
+	flush := time.NewTicker(time.Second)
+	defer flush.Stop()
+	defer close(w.done)
+	defer w.stopRealtime()
 
 	for {
+		var events <-chan notifyEvent
+		if w.notify != nil {
+			events = w.notify.Events()
+		}
 		select {
 		case <-ticker.C:
-			if err := w.checkDirectoryExists(); err != nil {
-				log.Printf("检查目录失败: %v", err)
+			w.pollSource()
+		case <-w.checkChan:
+			w.pollSource()
+		case <-w.rescanChan:
+			w.rescanNow()
+		case ev, ok := <-events:
+			if !ok {
+				logger.Infof("实时监控已停止: %s", w.sourceDir)
+				w.notify = nil
+				continue
+			}
+			w.queueChange(ev)
+		case <-flush.C:
+			if !w.Status().Paused {
+				w.flushChanges()
 			}
 		case <-w.stopChan:
 			return
@@ -73,130 +222,482 @@ func (w *Watcher) checkDirectory() {
 	}
 }
 
+// pollSource 定期或插拔磁盘后检查源目录，暂停时不检查
+func (w *Watcher) pollSource() {
+	if w.Status().Paused {
+		return
+	}
+	if err := w.checkDirectoryExists(); err != nil {
+		logger.Errorf("检查目录失败: %v", err)
+	}
+}
+
 func (w *Watcher) checkDirectoryExists() error {
-	// 检查源目录是否存在
-	if _, err := os.Stat(w.sourceDir); err != nil {
-		if os.IsNotExist(err) {
-			if w.status.IsLastCheckExists {
-				log.Printf("检测到源目录已离线：%s", w.sourceDir)
-				w.status.IsLastCheckExists = false
-			}
+	located, err := w.locateSource()
+	if err != nil {
+		return err
+	}
+	if !located {
+		w.setOffline()
+		return nil
+	}
+	if w.networkSource && !w.checkNetworkSource() {
+		return nil
+	}
+
+	// 检查源目录是否存在，设备已拔出但系统还没有移除挂载点或盘符时同样视为离线
+	info, err := os.Stat(w.source)
+	if err != nil {
+		if os.IsNotExist(err) || mount.IsUnavailable(err) {
+			w.setOffline()
 			return nil
 		}
-		return fmt.Errorf("检查源目录失败: %w", err)
+		return i18n.Errorf("检查源目录失败: %w", err)
 		// 这里也可以考虑认为源目录不存在了
 	}
+	if !w.replugged(info) {
+		return nil
+	}
 
 	// 如果目录存在且上次是未挂载，重新启动监控 TODO 可以考虑支持定时备份，暂时用不到
 	if !w.status.IsBackingUp && !w.status.IsLastCheckExists {
-		log.Printf("检测到源目录已创建或挂载，开始监控: %s", w.sourceDir)
+		logger.Infof("检测到源目录已创建或挂载，开始监控: %s", w.sourceDir)
+		w.statusMu.Lock()
 		w.status.IsLastCheckExists = true
 		w.status.IsBackingUp = true
-		// 执行初始目录扫描
-		go w.scanDirectory()
-
+		w.statusMu.Unlock()
+		// 执行初始目录扫描，扫描期间暂停检查，停止时等待扫描中止
+		// 实时监控在扫描之前启用，扫描期间的变化同样会收到通知
+		w.startRealtime()
+		w.finishScan(w.scanDirectory())
 	}
 
 	return nil
 }
 
+// setOffline 源目录不存在或卷没有插入，上次检查时还在线则停止实时监控
+func (w *Watcher) setOffline() {
+	if !w.status.IsLastCheckExists {
+		return
+	}
+	logger.Warnf("检测到源目录已离线：%s", w.sourceDir)
+	w.statusMu.Lock()
+	w.status.IsLastCheckExists = false
+	w.statusMu.Unlock()
+	w.stopRealtime()
+}
+
+// locateSource 按卷指定源目录时查找卷当前的挂载点，卷没有插入时返回 false
+// 挂载点与上次不同时（例如从 /media/usb1 变为 /media/usb2）视为重新挂载，之后完整扫描一次
+func (w *Watcher) locateSource() (bool, error) {
+	if w.resolveSource == nil {
+		return true, nil
+	}
+	dir, err := w.resolveSource()
+	if errors.Is(err, mount.ErrVolumeMissing) {
+		return false, nil
+	}
+	if err != nil {
+		return false, i18n.Errorf("查找源目录所在的卷失败: %w", err)
+	}
+	if dir != w.source {
+		w.setOffline()
+		logger.Infof("源目录 %s 当前位于: %s", w.sourceDir, dir)
+		w.source = dir
+		w.backupMgr.SetSourceDir(dir)
+	}
+	return true, nil
+}
+
+// checkNetworkSource 探测网络共享，返回 false 表示共享暂时不可用，本轮不做任何处理
+// 共享掉线、未挂载或突然变空都视为不可用，保留已有的备份和备份状态，恢复后重新扫描
+func (w *Watcher) checkNetworkSource() bool {
+	// 扫描进行中不重复探测，扫描本身遇到共享掉线会中止
+	if w.status.IsBackingUp {
+		return true
+	}
+	res, err := mount.ProbeShare(w.source, w.probeTimeout)
+	if err == nil && res.Exists && res.Empty && w.backupMgr.HasBackups() {
+		err = i18n.Errorf("源目录为空，但目标中已有备份，可能是共享未挂载")
+	}
+	if err != nil {
+		if !mount.IsUnavailable(err) && res.Exists {
+			// 其他错误交给常规检查处理
+			return true
+		}
+		if !w.status.IsSourceDown {
+			logger.Warnf("网络共享暂时不可用，等待恢复: %s, 原因: %v", w.sourceDir, err)
+			w.statusMu.Lock()
+			w.status.IsSourceDown = true
+			w.statusMu.Unlock()
+		}
+		return false
+	}
+	if w.status.IsSourceDown {
+		w.statusMu.Lock()
+		w.status.IsSourceDown = false
+		if res.Exists {
+			// 视为重新挂载，触发完整扫描
+			w.status.IsLastCheckExists = false
+		}
+		w.statusMu.Unlock()
+		if res.Exists {
+			logger.Infof("网络共享已恢复: %s", w.sourceDir)
+		}
+	}
+	return true
+}
+
 func (w *Watcher) handleFileChange(filePath string) {
 	// 执行备份
-	status := w.backupMgr.Backup(filePath)
+	var status backup.BackupStatus
+	if w.changed {
+		status = w.backupMgr.BackupChanged(filePath)
+	} else {
+		status = w.backupMgr.Backup(filePath)
+	}
+	if status == backup.Failed {
+		logger.With(logging.KeyTask, w.sourceDir, logging.KeyFile, filePath, logging.KeyStatus, logging.StatusFailed).Errorf("备份文件失败: %v", filePath)
+	}
+	w.statusMu.Lock()
+	defer w.statusMu.Unlock()
 	switch status {
 	case backup.Success:
 		w.status.SuccessFiles++
 	case backup.Failed:
-		log.Printf("备份文件失败: %v", filePath)
 		w.status.FailedFiles++
 	case backup.Skipped:
 		w.status.SkippedFiles++
 	}
 }
 
-func (w *Watcher) scanDirectory() {
-	log.Printf("开始扫描目录: %s", w.sourceDir)
+// RunOnce 立即扫描一次并等待完成，返回本次扫描的统计，用于单次运行模式
+func (w *Watcher) RunOnce() (DirectoryStatus, error) {
+	if w.networkSource && !w.checkNetworkSource() {
+		return *w.status, i18n.Errorf("网络共享不可用: %s", w.sourceDir)
+	}
+	if located, err := w.locateSource(); err != nil {
+		return *w.status, err
+	} else if !located {
+		return *w.status, ErrSourceMissing
+	}
+	if _, err := os.Stat(w.source); err != nil {
+		if os.IsNotExist(err) {
+			return *w.status, ErrSourceMissing
+		}
+		return *w.status, i18n.Errorf("源目录不可用: %w", err)
+	}
+	w.statusMu.Lock()
+	w.status.IsBackingUp = true
+	w.statusMu.Unlock()
+	err := w.scanDirectory()
+	w.finishScan(err)
+	return *w.status, err
+}
+
+// scanLog 附带任务、用时、结果和各项数量的日志，记录一次扫描的结果
+func (w *Watcher) scanLog(start time.Time, status string, err error) *logging.Logger {
+	l := logger.With(logging.KeyTask, w.sourceDir, logging.KeyDuration, time.Since(start), logging.KeyStatus, status,
+		"files", w.status.TotalFiles, "succeeded", w.status.SuccessFiles, "failed", w.status.FailedFiles, "skipped", w.status.SkippedFiles)
+	if err != nil {
+		l = l.With(logging.KeyError, err.Error())
+	}
+	return l
+}
+
+func (w *Watcher) scanDirectory() error {
+	if w.backupMgr.NeedsSeed() && !w.backupMgr.DryRun().Skip("从目标目录导入已有备份: %s", w.targetDir) {
+		if _, err := w.backupMgr.Seed(); err != nil {
+			logger.Errorf("从目标目录导入失败: %v", err)
+		}
+	}
+	logger.Infof("开始扫描目录: %s", w.sourceDir)
+	w.backupMgr.ResetLinks()
+	start := time.Now()
 	// 清空数量记录数
+	w.statusMu.Lock()
 	w.status.TotalFiles = 0
 	w.status.SuccessFiles = 0
 	w.status.FailedFiles = 0
 	w.status.SkippedFiles = 0
-	err := w.scanSubDirectory(w.sourceDir)
+	w.statusMu.Unlock()
+	jobs := make(chan fileJob, queueSize)
+	copied := make(chan struct{})
+	go func() {
+		w.copyFiles(jobs)
+		close(copied)
+	}()
+	err := w.scanSubDirectory(w.source, jobs)
+	close(jobs)
+	<-copied
+	if err == errStopped {
+		// 已复制的文件已经记录状态，下次启动时重新扫描
+		w.scanLog(start, logging.StatusAborted, nil).Warnf("程序正在停止，扫描已中止: %s, 扫描数量: %d, 同步成功: %d, 失败: %d, 跳过: %d", w.sourceDir, w.status.TotalFiles, w.status.SuccessFiles, w.status.FailedFiles, w.status.SkippedFiles)
+		w.statusMu.Lock()
+		w.status.IsBackingUp = false
+		w.statusMu.Unlock()
+		return err
+	}
 
 	// 扫描数量 = 同步成功 + 失败 + 跳过，结果日志包含这些信息，失败了也需要这些信息
 	if err != nil {
-		log.Printf("目录扫描失败: %s, 扫描数量: %d, 同步成功: %d, 失败: %d, 跳过: %d, 错误原因: %v", w.sourceDir, w.status.TotalFiles, w.status.SuccessFiles, w.status.FailedFiles, w.status.SkippedFiles, err)
+		w.scanLog(start, logging.StatusFailed, err).Errorf("目录扫描失败: %s, 扫描数量: %d, 同步成功: %d, 失败: %d, 跳过: %d, 错误原因: %v", w.sourceDir, w.status.TotalFiles, w.status.SuccessFiles, w.status.FailedFiles, w.status.SkippedFiles, err)
 	} else {
-		log.Printf("目录扫描完成: %s, 扫描数量: %d, 同步成功: %d, 失败: %d, 跳过: %d", w.sourceDir, w.status.TotalFiles, w.status.SuccessFiles, w.status.FailedFiles, w.status.SkippedFiles)
+		w.scanLog(start, logging.StatusSuccess, nil).Infof("目录扫描完成: %s, 扫描数量: %d, 同步成功: %d, 失败: %d, 跳过: %d", w.sourceDir, w.status.TotalFiles, w.status.SuccessFiles, w.status.FailedFiles, w.status.SkippedFiles)
 		// 所有文件处理完成后，更新同步时间
+		w.statusMu.Lock()
 		w.status.LastSync = time.Now()
-		if err := w.backupMgr.SaveProgress(); err != nil {
-			log.Printf("保存进度失败: %v", err)
+		w.statusMu.Unlock()
+		if err := w.backupMgr.WriteChecksums(); err != nil {
+			logger.Errorf("写入校验文件失败: %v", err)
+		}
+		if err = w.backupMgr.SaveState(); err != nil {
+			logger.Errorf("保存备份状态失败: %v", err)
+		} else if w.snapshotter != nil && w.status.SuccessFiles > 0 && w.status.FailedFiles == 0 && !w.backupMgr.DryRun().Skip("创建快照: %s", w.targetDir) {
+			// 只在有新文件且全部成功时创建快照，快照中的内容才是完整的
+			if err := w.snapshotter.Take(); err != nil {
+				logger.Errorf("快照失败: %v", err)
+			}
 		}
 	}
+	if !w.backupMgr.DryRun().Enabled() {
+		w.record(start, err)
+	}
+	w.statusMu.Lock()
 	w.status.IsBackingUp = false
+	w.statusMu.Unlock()
+	return err
 }
 
-// scanSubDirectory 递归处理子目录
-func (w *Watcher) scanSubDirectory(dirPath string) error {
-	return filepath.WalkDir(dirPath, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			log.Printf("访问路径失败 %s: %v", path, err)
-			return nil
+// record 保存本次扫描的结果，有文件失败时同样记为失败
+func (w *Watcher) record(start time.Time, err error) {
+	r := history.Run{
+		Kind:    history.KindBackup,
+		Host:    w.hostname,
+		Source:  w.sourceDir,
+		Target:  w.targetDir,
+		Start:   start,
+		End:     time.Now(),
+		Result:  history.ResultSuccess,
+		Total:   w.status.TotalFiles,
+		Success: w.status.SuccessFiles,
+		Failed:  w.status.FailedFiles,
+		Skipped: w.status.SkippedFiles,
+	}
+	if err != nil || r.Failed > 0 {
+		r.Result = history.ResultFailed
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	w.history.Record(r)
+}
+
+// fileJob 扫描时交给复制协程的文件
+type fileJob struct {
+	path string
+	dir  *sync.WaitGroup // 文件所在目录的待复制计数，目录处理完成前等待其中的文件复制完成
+}
+
+const (
+	scanBatch = 256  // 每次从目录中读取的目录项数量
+	queueSize = 1024 // 等待复制的文件数量上限，扫描快于复制时暂停扫描
+)
+
+// copyFiles 在 concurrency 个协程中复制队列中的文件，全部完成后返回，程序正在停止时丢弃剩余的文件
+func (w *Watcher) copyFiles(jobs <-chan fileJob) {
+	var wg sync.WaitGroup
+	for i := 0; i < w.copiers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.copyWorker(jobs)
+		}()
+	}
+	wg.Wait()
+}
+
+// copyWorker 依次复制队列中的文件，直到队列关闭
+func (w *Watcher) copyWorker(jobs <-chan fileJob) {
+	priority.Lower()
+	for job := range jobs {
+		// 系统负载过高或使用电池供电时在这里等待，复制队列满后扫描随之暂停
+		backoff.Wait(w.stopChan)
+		w.waitResumed()
+		select {
+		case <-w.stopChan:
+		default:
+			w.statusMu.Lock()
+			w.status.TotalFiles++
+			w.statusMu.Unlock()
+			// 处理文件，不更新时间
+			w.handleFileChange(job.path)
+		}
+		job.dir.Done()
+	}
+}
+
+// dirState 正在扫描的目录，子目录可能在其他协程中扫描
+type dirState struct {
+	pending sync.WaitGroup // 待复制的文件和正在并行扫描的子目录
+	mu      sync.Mutex
+	err     error // 并行扫描的子目录返回的第一个错误
+}
+
+func (st *dirState) setErr(err error) {
+	st.mu.Lock()
+	if st.err == nil {
+		st.err = err
+	}
+	st.mu.Unlock()
+}
+
+// scanSubDirectory 分批读取目录项，文件放入复制队列，子目录递归处理
+// 单个目录中有大量文件时只占用固定的内存，返回前等待该目录中的文件复制完成
+func (w *Watcher) scanSubDirectory(dirPath string, jobs chan<- fileJob) error {
+	w.watchDir(dirPath)
+	st := &dirState{}
+	err := w.readDir(dirPath, jobs, st)
+	st.pending.Wait()
+	if err == nil {
+		err = st.err
+	}
+	return err
+}
+
+func (w *Watcher) readDir(dirPath string, jobs chan<- fileJob, st *dirState) error {
+	f, err := os.Open(dirPath)
+	if err != nil {
+		return w.walkError(dirPath, err)
+	}
+	defer f.Close()
+	for {
+		entries, err := f.ReadDir(scanBatch)
+		for _, d := range entries {
+			if err := w.scanEntry(filepath.Join(dirPath, d.Name()), d, jobs, st); err != nil {
+				return err
+			}
 		}
-		// 跳过自身
-		if dirPath == path {
+		if err == io.EOF {
 			return nil
 		}
-		// 获取源文件/目录信息
-		srcInfo, err := os.Stat(path)
 		if err != nil {
-			return fmt.Errorf("获取文件信息失败: %w", err)
+			return w.walkError(dirPath, err)
 		}
+	}
+}
 
-		// 构建目标路径
-		targetPath := w.backupMgr.BuildTargetPath(path)
-		if targetPath == "" {
-			return fmt.Errorf("无法构建目标路径: %s", path)
-		}
+// walkError 读取目录失败时的处理，网络共享中途掉线时中止扫描，恢复后重新扫描
+func (w *Watcher) walkError(path string, err error) error {
+	if mount.IsUnavailable(err) {
+		return i18n.Errorf("源目录不可用: %w", err)
+	}
+	logger.Errorf("访问路径失败 %s: %v", path, err)
+	return nil
+}
 
-		if d.IsDir() {
-			isNewDir := false
-			if _, err := os.Stat(targetPath); err != nil {
-				if err := os.MkdirAll(targetPath, srcInfo.Mode()); err != nil {
-					return fmt.Errorf("创建目标目录失败: %w", err)
-				}
-				isNewDir = true
-			}
+// scanEntry 处理目录中的一项
+func (w *Watcher) scanEntry(path string, d os.DirEntry, jobs chan<- fileJob, st *dirState) error {
+	select {
+	case <-w.stopChan:
+		return errStopped
+	default:
+	}
+	// 默认不备份 macOS 系统元数据，以及匹配 exclude 规则的文件和目录
+	if w.backupMgr.Excluded(path) {
+		return nil
+	}
+	if !d.IsDir() && !w.backupMgr.Included(path) {
+		return nil
+	}
+	// 获取源文件/目录信息
+	srcInfo, err := os.Stat(path)
+	if err != nil {
+		return i18n.Errorf("获取文件信息失败: %w", err)
+	}
+
+	// 构建目标路径
+	targetPath := w.backupMgr.BuildTargetPath(path)
+	if targetPath == "" {
+		return i18n.Errorf("无法构建目标路径: %s", path)
+	}
 
-			// 递归处理子目录
-			w.scanSubDirectory(path)
-
-			// 如果是新创建的目录，且里面不存在文件，说明是无效目录，需要删除
-			if isNewDir {
-				// check files count in target path
-				files, err := os.ReadDir(targetPath)
-				if err == nil && len(files) == 0 {
-					// 删除targetPath目录
-					if err := os.Remove(targetPath); err != nil {
-						log.Printf("删除目标目录失败: %v", err)
-					}
-					return filepath.SkipDir
-				}
+	if !d.IsDir() {
+		st.pending.Add(1)
+		jobs <- fileJob{path: path, dir: &st.pending}
+		return nil
+	}
+	// 有空闲的扫描协程时在其中扫描子目录，否则在当前协程中扫描
+	select {
+	case w.walkers <- struct{}{}:
+		st.pending.Add(1)
+		go func() {
+			priority.Lower()
+			defer st.pending.Done()
+			defer func() { <-w.walkers }()
+			if err := w.scanDir(path, targetPath, srcInfo, jobs); err != nil {
+				st.setErr(err)
 			}
-			// 同步目录时间 TODO 设置用户属性
-			atime := srcInfo.ModTime() // 使用修改时间作为访问时间
-			mtime := srcInfo.ModTime() // 修改时间
-			if err := os.Chtimes(targetPath, atime, mtime); err != nil {
-				log.Printf("设置目录时间失败: %v", err)
+		}()
+		return nil
+	default:
+		return w.scanDir(path, targetPath, srcInfo, jobs)
+	}
+}
+
+// scanDir 创建目标目录并扫描子目录，完成后同步目录时间
+func (w *Watcher) scanDir(path, targetPath string, srcInfo os.FileInfo, jobs chan<- fileJob) error {
+	if w.backupMgr.IsRemote() {
+		// 服务端会自动创建目录，只需递归处理子目录
+		if err := w.scanSubDirectory(path, jobs); aborted(err) {
+			return err
+		}
+		return nil
+	}
+
+	isNewDir := false
+	if _, err := os.Stat(targetPath); err != nil {
+		if w.backupMgr.DryRun().Skip("创建目录: %s", targetPath) {
+			if err := w.scanSubDirectory(path, jobs); aborted(err) {
+				return err
 			}
+			return nil
+		}
+		if err := os.MkdirAll(targetPath, srcInfo.Mode()); err != nil {
+			return i18n.Errorf("创建目标目录失败: %w", err)
+		}
+		isNewDir = true
+	}
 
-			return filepath.SkipDir
-		} else {
-			w.status.TotalFiles++
-			// 处理文件，不更新时间
-			w.handleFileChange(path)
+	// 递归处理子目录，返回时其中的文件都已复制完成
+	if err := w.scanSubDirectory(path, jobs); aborted(err) {
+		return err
+	}
+	// 如果是新创建的目录，且里面不存在文件，说明是无效目录，需要删除
+	if isNewDir {
+		// check files count in target path
+		files, err := os.ReadDir(targetPath)
+		if err == nil && len(files) == 0 {
+			// 删除targetPath目录
+			if err := os.Remove(targetPath); err != nil {
+				logger.Errorf("删除目标目录失败: %v", err)
+			}
+			return nil
 		}
+	}
+	if w.backupMgr.DryRun().Enabled() {
 		return nil
-	})
+	}
+	// 同步目录时间 TODO 设置用户属性
+	atime := srcInfo.ModTime() // 使用修改时间作为访问时间
+	mtime := srcInfo.ModTime() // 修改时间
+	if err := os.Chtimes(targetPath, atime, mtime); err != nil {
+		logger.Errorf("设置目录时间失败: %v", err)
+	}
+	return nil
+}
+
+// aborted 子目录扫描需要中止整个扫描：网络共享掉线或程序正在停止
+func aborted(err error) bool {
+	return err != nil && (err == errStopped || mount.IsUnavailable(err))
 }
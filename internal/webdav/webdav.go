@@ -0,0 +1,558 @@
+package webdav
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/httpd"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+)
+
+// logger webdav 模块的日志
+var logger = logging.New("webdav")
+
+// Prefix WebDAV 的 URL 前缀
+const Prefix = "/dav/"
+
+// Handler 将备份目标目录以 WebDAV 协议暴露，第一级为各个根目录
+// 只读模式只支持 PROPFIND/GET，读写模式额外支持 PUT/DELETE/MKCOL/MOVE/COPY 和简单的 LOCK
+type Handler struct {
+	roots     []httpd.Root
+	readWrite bool
+}
+
+func NewHandler(roots []httpd.Root, readWrite bool) *Handler {
+	return &Handler{roots: roots, readWrite: readWrite}
+}
+
+// Register 在内置 HTTP 服务上注册路由
+func (h *Handler) Register(s *httpd.Server, cfg config.WebDAVConfig) {
+	s.Handle(Prefix, httpd.BasicAuth(cfg.Username, cfg.Password, "neo-nas", h))
+	mode := i18n.T("只读")
+	if h.readWrite {
+		mode = i18n.T("读写")
+	}
+	logger.Infof("WebDAV 已启用（%s），共 %d 个目录", mode, len(h.roots))
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "OPTIONS":
+		h.handleOptions(w)
+		return
+	case "PROPFIND":
+		h.handlePropfind(w, r)
+		return
+	case http.MethodGet, http.MethodHead:
+		h.handleGet(w, r)
+		return
+	}
+
+	if !h.readWrite {
+		http.Error(w, "read only", http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		h.handlePut(w, r)
+	case http.MethodDelete:
+		h.handleDelete(w, r)
+	case "MKCOL":
+		h.handleMkcol(w, r)
+	case "MOVE", "COPY":
+		h.handleMoveCopy(w, r)
+	case "PROPPATCH":
+		h.handleProppatch(w, r)
+	case "LOCK":
+		h.handleLock(w, r)
+	case "UNLOCK":
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// resource 请求路径对应的资源，root 为空表示虚拟的顶层目录
+type resource struct {
+	root  *httpd.Root
+	rel   string // 根目录下的相对路径，以 / 分隔
+	path  string // 文件系统路径
+	href  string // URL 路径
+	isTop bool
+}
+
+func (h *Handler) lookup(urlPath string) (*resource, bool) {
+	clean := strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(urlPath, Prefix)), "/")
+	if clean == "" {
+		return &resource{href: Prefix, isTop: true}, true
+	}
+	for _, part := range strings.Split(clean, "/") {
+		if httpd.Hidden(part) {
+			return nil, false
+		}
+	}
+	name, rel, _ := strings.Cut(clean, "/")
+	for i := range h.roots {
+		if h.roots[i].Name != name {
+			continue
+		}
+		fullPath, ok := httpd.Resolve(h.roots[i].Path, rel)
+		if !ok {
+			return nil, false
+		}
+		return &resource{root: &h.roots[i], rel: rel, path: fullPath, href: Prefix + clean}, true
+	}
+	return nil, false
+}
+
+func (h *Handler) handleOptions(w http.ResponseWriter) {
+	allow := "OPTIONS, PROPFIND, GET, HEAD"
+	dav := "1"
+	if h.readWrite {
+		allow += ", PUT, DELETE, MKCOL, MOVE, COPY, PROPPATCH, LOCK, UNLOCK"
+		dav = "1, 2"
+	}
+	w.Header().Set("Allow", allow)
+	w.Header().Set("DAV", dav)
+	w.Header().Set("MS-Author-Via", "DAV")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) handleGet(w http.ResponseWriter, r *http.Request) {
+	res, ok := h.lookup(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if res.isTop {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, root := range h.roots {
+			fmt.Fprintln(w, root.Name+"/")
+		}
+		return
+	}
+	info, err := os.Stat(res.path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if info.IsDir() {
+		entries, err := os.ReadDir(res.path)
+		if err != nil {
+			http.Error(w, i18n.T("无法读取目录"), http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, e := range entries {
+			if httpd.Hidden(e.Name()) {
+				continue
+			}
+			if e.IsDir() {
+				fmt.Fprintln(w, e.Name()+"/")
+			} else {
+				fmt.Fprintln(w, e.Name())
+			}
+		}
+		return
+	}
+	f, err := os.Open(res.path)
+	if err != nil {
+		http.Error(w, i18n.T("无法读取文件"), http.StatusForbidden)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("ETag", etag(info))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+type propEntry struct {
+	href  string
+	name  string
+	info  os.FileInfo // 为空表示虚拟目录
+	isDir bool
+}
+
+func (h *Handler) handlePropfind(w http.ResponseWriter, r *http.Request) {
+	res, ok := h.lookup(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	// 请求体中指定的属性被忽略，总是返回全部常用属性
+	io.Copy(io.Discard, r.Body)
+	depth := r.Header.Get("Depth")
+
+	var entries []propEntry
+	if res.isTop {
+		entries = append(entries, propEntry{href: Prefix, name: "neo-nas", isDir: true})
+		if depth != "0" {
+			for _, root := range h.roots {
+				info, err := os.Stat(root.Path)
+				if err != nil {
+					continue
+				}
+				entries = append(entries, propEntry{href: Prefix + url.PathEscape(root.Name) + "/", name: root.Name, info: info, isDir: true})
+			}
+		}
+	} else {
+		info, err := os.Stat(res.path)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		href := escapeHref(res.href)
+		if info.IsDir() {
+			href += "/"
+		}
+		entries = append(entries, propEntry{href: href, name: info.Name(), info: info, isDir: info.IsDir()})
+		// Depth: infinity 按 1 处理，避免遍历整个备份目录
+		if info.IsDir() && depth != "0" {
+			children, err := os.ReadDir(res.path)
+			if err != nil {
+				http.Error(w, i18n.T("无法读取目录"), http.StatusForbidden)
+				return
+			}
+			for _, c := range children {
+				if httpd.Hidden(c.Name()) {
+					continue
+				}
+				ci, err := c.Info()
+				if err != nil {
+					continue
+				}
+				if ci.Mode()&os.ModeSymlink != 0 {
+					if ci, err = os.Stat(filepath.Join(res.path, c.Name())); err != nil {
+						continue
+					}
+				}
+				childHref := href + url.PathEscape(c.Name())
+				if ci.IsDir() {
+					childHref += "/"
+				}
+				entries = append(entries, propEntry{href: childHref, name: c.Name(), info: ci, isDir: ci.IsDir()})
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", `application/xml; charset="utf-8"`)
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?>`+"\n"+`<D:multistatus xmlns:D="DAV:">`+"\n")
+	for _, e := range entries {
+		writeProps(w, e)
+	}
+	io.WriteString(w, "</D:multistatus>\n")
+}
+
+func writeProps(w io.Writer, e propEntry) {
+	fmt.Fprintf(w, "<D:response><D:href>%s</D:href><D:propstat><D:prop>", xmlEscape(e.href))
+	fmt.Fprintf(w, "<D:displayname>%s</D:displayname>", xmlEscape(e.name))
+	if e.isDir {
+		io.WriteString(w, "<D:resourcetype><D:collection/></D:resourcetype>")
+	} else {
+		io.WriteString(w, "<D:resourcetype/>")
+	}
+	if e.info != nil {
+		fmt.Fprintf(w, "<D:getlastmodified>%s</D:getlastmodified>", e.info.ModTime().UTC().Format(http.TimeFormat))
+		fmt.Fprintf(w, "<D:creationdate>%s</D:creationdate>", e.info.ModTime().UTC().Format(time.RFC3339))
+		if !e.isDir {
+			fmt.Fprintf(w, "<D:getcontentlength>%d</D:getcontentlength>", e.info.Size())
+			fmt.Fprintf(w, "<D:getetag>%s</D:getetag>", xmlEscape(etag(e.info)))
+			ctype := mime.TypeByExtension(path.Ext(e.name))
+			if ctype == "" {
+				ctype = "application/octet-stream"
+			}
+			fmt.Fprintf(w, "<D:getcontenttype>%s</D:getcontenttype>", xmlEscape(ctype))
+		}
+	}
+	io.WriteString(w, "</D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>\n")
+}
+
+// writable 写操作只允许发生在某个根目录之内，且不能修改根目录本身
+func writable(res *resource) bool {
+	return res != nil && !res.isTop && strings.Trim(res.rel, "/") != ""
+}
+
+func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request) {
+	res, ok := h.lookup(r.URL.Path)
+	if !ok || !writable(res) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if info, err := os.Stat(filepath.Dir(res.path)); err != nil || !info.IsDir() {
+		http.Error(w, i18n.T("父目录不存在"), http.StatusConflict)
+		return
+	}
+	_, statErr := os.Stat(res.path)
+
+	tmp, err := os.CreateTemp(filepath.Dir(res.path), ".neo-dav-*")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r.Body); err != nil {
+		tmp.Close()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	os.Chmod(tmp.Name(), 0644)
+	if err := os.Rename(tmp.Name(), res.path); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	logger.Infof("WebDAV 写入文件: %s", res.path)
+	if statErr == nil {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	res, ok := h.lookup(r.URL.Path)
+	if !ok || !writable(res) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if _, err := os.Lstat(res.path); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := os.RemoveAll(res.path); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	logger.Infof("WebDAV 删除: %s", res.path)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleMkcol(w http.ResponseWriter, r *http.Request) {
+	res, ok := h.lookup(r.URL.Path)
+	if !ok || !writable(res) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if r.ContentLength > 0 {
+		http.Error(w, "unsupported media type", http.StatusUnsupportedMediaType)
+		return
+	}
+	if _, err := os.Lstat(res.path); err == nil {
+		http.Error(w, "already exists", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := os.Mkdir(res.path, 0755); err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, i18n.T("父目录不存在"), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *Handler) handleMoveCopy(w http.ResponseWriter, r *http.Request) {
+	src, ok := h.lookup(r.URL.Path)
+	if !ok || !writable(src) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	destURL, err := url.Parse(r.Header.Get("Destination"))
+	if err != nil || destURL.Path == "" {
+		http.Error(w, i18n.T("Destination 无效"), http.StatusBadRequest)
+		return
+	}
+	dst, ok := h.lookup(destURL.Path)
+	if !ok || !writable(dst) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if src.path == dst.path {
+		http.Error(w, i18n.T("源与目标相同"), http.StatusForbidden)
+		return
+	}
+	if _, err := os.Lstat(src.path); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	created := true
+	if _, err := os.Lstat(dst.path); err == nil {
+		if r.Header.Get("Overwrite") == "F" {
+			http.Error(w, i18n.T("目标已存在"), http.StatusPreconditionFailed)
+			return
+		}
+		if err := os.RemoveAll(dst.path); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		created = false
+	}
+
+	if r.Method == "MOVE" {
+		err = os.Rename(src.path, dst.path)
+	} else {
+		err = copyTree(src.path, dst.path)
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, i18n.T("父目录不存在"), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	logger.Infof("WebDAV %s: %s -> %s", r.Method, src.path, dst.path)
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleProppatch 不支持自定义属性，对每个属性返回 403
+func (h *Handler) handleProppatch(w http.ResponseWriter, r *http.Request) {
+	res, ok := h.lookup(r.URL.Path)
+	if !ok || res.isTop {
+		http.NotFound(w, r)
+		return
+	}
+	var req struct {
+		Props []struct {
+			Inner []struct {
+				XMLName xml.Name
+			} `xml:",any"`
+		} `xml:"set>prop"`
+		RemoveProps []struct {
+			Inner []struct {
+				XMLName xml.Name
+			} `xml:",any"`
+		} `xml:"remove>prop"`
+	}
+	xml.NewDecoder(r.Body).Decode(&req)
+
+	w.Header().Set("Content-Type", `application/xml; charset="utf-8"`)
+	w.WriteHeader(http.StatusMultiStatus)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>`+"\n"+`<D:multistatus xmlns:D="DAV:"><D:response><D:href>%s</D:href><D:propstat><D:prop>`, xmlEscape(escapeHref(res.href)))
+	for _, group := range append(req.Props, req.RemoveProps...) {
+		for _, p := range group.Inner {
+			fmt.Fprintf(w, `<x:%s xmlns:x="%s"/>`, p.XMLName.Local, xmlEscape(p.XMLName.Space))
+		}
+	}
+	io.WriteString(w, "</D:prop><D:status>HTTP/1.1 403 Forbidden</D:status></D:propstat></D:response></D:multistatus>\n")
+}
+
+// handleLock 不做真正的锁管理，只返回锁令牌，满足 Finder 和 Windows 资源管理器的写入要求
+func (h *Handler) handleLock(w http.ResponseWriter, r *http.Request) {
+	res, ok := h.lookup(r.URL.Path)
+	if !ok || !writable(res) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	io.Copy(io.Discard, r.Body)
+
+	status := http.StatusOK
+	if _, err := os.Stat(res.path); os.IsNotExist(err) {
+		// 锁定不存在的资源时创建空文件
+		f, err := os.OpenFile(res.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		f.Close()
+		status = http.StatusCreated
+	}
+
+	token := r.Header.Get("If")
+	token = strings.Trim(token, "()<>")
+	if !strings.HasPrefix(token, "opaquelocktoken:") {
+		b := make([]byte, 16)
+		rand.Read(b)
+		token = "opaquelocktoken:" + hex.EncodeToString(b)
+	}
+	w.Header().Set("Lock-Token", "<"+token+">")
+	w.Header().Set("Content-Type", `application/xml; charset="utf-8"`)
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<D:prop xmlns:D="DAV:"><D:lockdiscovery><D:activelock><D:locktype><D:write/></D:locktype><D:lockscope><D:exclusive/></D:lockscope><D:depth>0</D:depth><D:timeout>Second-3600</D:timeout><D:locktoken><D:href>%s</D:href></D:locktoken><D:lockroot><D:href>%s</D:href></D:lockroot></D:activelock></D:lockdiscovery></D:prop>
+`, xmlEscape(token), xmlEscape(escapeHref(res.href)))
+}
+
+// copyTree 递归复制文件或目录，保留修改时间
+func copyTree(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return copyFile(src, dst, info)
+	}
+	if err := os.Mkdir(dst, info.Mode().Perm()); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := copyTree(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+			return err
+		}
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+func copyFile(src, dst string, info os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+func etag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+func escapeHref(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,103 @@
+package xattr
+
+import (
+	"bytes"
+	"syscall"
+	"unsafe"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// Copy 把 src 的扩展属性复制到 dst，资源分叉以 com.apple.ResourceFork 属性的形式一并复制
+// 源或目标的文件系统不支持扩展属性时不做任何操作
+func Copy(src, dst string) error {
+	names, err := list(src)
+	if err != nil {
+		if err == syscall.ENOTSUP {
+			return nil
+		}
+		return i18n.Errorf("读取扩展属性失败: %w", err)
+	}
+	for _, name := range names {
+		value, err := get(src, name)
+		if err != nil {
+			return i18n.Errorf("读取扩展属性 %s 失败: %w", name, err)
+		}
+		if err := set(dst, name, value); err != nil {
+			if err == syscall.ENOTSUP {
+				return nil
+			}
+			return i18n.Errorf("写入扩展属性 %s 失败: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func list(path string) ([]string, error) {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	size, _, errno := syscall.Syscall6(syscall.SYS_LISTXATTR, uintptr(unsafe.Pointer(p)), 0, 0, 0, 0, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	buf := make([]byte, size)
+	n, _, errno := syscall.Syscall6(syscall.SYS_LISTXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&buf[0])), size, 0, 0, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	var names []string
+	for _, name := range bytes.Split(buf[:n], []byte{0}) {
+		if len(name) > 0 {
+			names = append(names, string(name))
+		}
+	}
+	return names, nil
+}
+
+func get(path, name string) ([]byte, error) {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	a, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	size, _, errno := syscall.Syscall6(syscall.SYS_GETXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(a)), 0, 0, 0, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	buf := make([]byte, size)
+	n, _, errno := syscall.Syscall6(syscall.SYS_GETXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(a)), uintptr(unsafe.Pointer(&buf[0])), size, 0, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	return buf[:n], nil
+}
+
+func set(path, name string, value []byte) error {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	a, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return err
+	}
+	var v unsafe.Pointer
+	if len(value) > 0 {
+		v = unsafe.Pointer(&value[0])
+	}
+	if _, _, errno := syscall.Syscall6(syscall.SYS_SETXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(a)), uintptr(v), uintptr(len(value)), 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !darwin
+
+package xattr
+
+// Copy 目前只在 macOS 上复制扩展属性，其他平台不做任何操作
+func Copy(src, dst string) error {
+	return nil
+}
@@ -0,0 +1,321 @@
+package zip
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// WinZip AES 加密（AE-2），7-Zip、WinRAR、macOS 归档工具等都支持
+// 格式说明见 https://www.winzip.com/en/support/aes-encryption/
+const (
+	methodAES     = 99     // 加密条目的压缩方法，实际的压缩方法记录在扩展字段中
+	aesExtraID    = 0x9901 // AES 扩展字段
+	aesStrength   = 3      // AES-256
+	aesKeySize    = 32
+	aesSaltSize   = 16
+	aesMACSize    = 10 // HMAC-SHA1 截取前 10 字节
+	pbkdf2Rounds  = 1000
+	flagEncrypted = 0x1
+	flagStreaming = 0x8 // 大小写在数据之后的数据描述符中
+)
+
+// createEncrypted 在压缩文件中创建一个 AES-256 加密的条目，内容按压缩级别 c 压缩后再加密
+// 返回的 Writer 关闭时写入校验码并更新条目的大小，需要在创建下一个条目之前关闭
+func createEncrypted(zw *zip.Writer, fh *zip.FileHeader, password string, c compression) (io.WriteCloser, error) {
+	salt := make([]byte, aesSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, i18n.Errorf("生成随机数失败: %w", err)
+	}
+	keys := pbkdf2SHA1([]byte(password), salt, pbkdf2Rounds, 2*aesKeySize+2)
+	block, err := aes.NewCipher(keys[:aesKeySize])
+	if err != nil {
+		return nil, err
+	}
+
+	// 扩展字段：版本 AE-2（不记录 CRC32）、厂商 "AE"、密钥长度和实际的压缩方法
+	extra := make([]byte, 11)
+	binary.LittleEndian.PutUint16(extra[0:], aesExtraID)
+	binary.LittleEndian.PutUint16(extra[2:], 7)
+	binary.LittleEndian.PutUint16(extra[4:], 2)
+	copy(extra[6:], "AE")
+	extra[8] = aesStrength
+	binary.LittleEndian.PutUint16(extra[9:], c.method)
+
+	fh.Method = methodAES
+	fh.Flags |= flagEncrypted | flagStreaming
+	fh.Extra = append(fh.Extra, extra...)
+	fh.CRC32 = 0
+	raw, err := zw.CreateRaw(fh)
+	if err != nil {
+		return nil, err
+	}
+
+	enc := &ctrWriter{
+		raw: &countingWriter{w: raw},
+		ctr: newCTR(block),
+		mac: hmac.New(sha1.New, keys[aesKeySize:2*aesKeySize]),
+	}
+	if _, err := enc.raw.Write(salt); err != nil {
+		return nil, err
+	}
+	if _, err := enc.raw.Write(keys[2*aesKeySize:]); err != nil {
+		return nil, err
+	}
+	if c.method == zip.Store {
+		return &aesWriter{fh: fh, body: nopWriteCloser{enc}, enc: enc}, nil
+	}
+	deflate, err := flate.NewWriter(enc, c.level)
+	if err != nil {
+		return nil, err
+	}
+	return &aesWriter{fh: fh, body: deflate, enc: enc}, nil
+}
+
+// aesWriter 压缩条目的内容，压缩后的数据交给 ctrWriter 加密
+type aesWriter struct {
+	fh   *zip.FileHeader
+	body io.WriteCloser // 不压缩时直接写入 enc
+	enc  *ctrWriter
+	size uint64
+}
+
+// nopWriteCloser 不压缩的条目没有需要在关闭时写入的数据
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// Write 写入未压缩的内容
+func (e *aesWriter) Write(p []byte) (int, error) {
+	n, err := e.body.Write(p)
+	e.size += uint64(n)
+	return n, err
+}
+
+// Close 写入剩余的压缩数据和校验码，更新条目的大小
+func (e *aesWriter) Close() error {
+	if err := e.body.Close(); err != nil {
+		return err
+	}
+	if _, err := e.enc.raw.Write(e.enc.mac.Sum(nil)[:aesMACSize]); err != nil {
+		return err
+	}
+	// 条目以数据描述符结尾，关闭条目时写入这里更新后的大小
+	compressed := e.enc.raw.n
+	e.fh.CompressedSize64, e.fh.UncompressedSize64 = compressed, e.size
+	if compressed < 1<<32-1 && e.size < 1<<32-1 {
+		e.fh.CompressedSize, e.fh.UncompressedSize = uint32(compressed), uint32(e.size)
+	} else {
+		e.fh.CompressedSize, e.fh.UncompressedSize = 1<<32-1, 1<<32-1
+	}
+	return nil
+}
+
+// aesCTR WinZip 使用的 AES-CTR，计数器为小端序并从 1 开始，与标准库的 CTR 模式不同
+type aesCTR struct {
+	block   cipher.Block
+	counter [aes.BlockSize]byte
+	stream  [aes.BlockSize]byte
+	used    int // stream 中已使用的字节数，为 0 时需要生成下一块
+}
+
+func newCTR(block cipher.Block) *aesCTR {
+	c := &aesCTR{block: block}
+	c.counter[0] = 1
+	return c
+}
+
+// xor 把 src 与密钥流异或后写入 dst，加密和解密相同
+func (c *aesCTR) xor(dst, src []byte) {
+	for i, b := range src {
+		if c.used == 0 {
+			c.block.Encrypt(c.stream[:], c.counter[:])
+			for j := range c.counter {
+				c.counter[j]++
+				if c.counter[j] != 0 {
+					break
+				}
+			}
+		}
+		dst[i] = b ^ c.stream[c.used]
+		c.used = (c.used + 1) % aes.BlockSize
+	}
+}
+
+// ctrWriter 加密写入，同时对密文计算 HMAC-SHA1
+type ctrWriter struct {
+	raw *countingWriter
+	ctr *aesCTR
+	mac hash.Hash
+}
+
+func (c *ctrWriter) Write(p []byte) (int, error) {
+	buf := make([]byte, len(p))
+	c.ctr.xor(buf, p)
+	c.mac.Write(buf)
+	if _, err := c.raw.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// openEncrypted 解密 AES 加密的条目，读到末尾时核对校验码，密码错误或内容被修改时返回错误
+func openEncrypted(f *zip.File, password string) (io.ReadCloser, error) {
+	if password == "" {
+		return nil, i18n.Errorf("条目已加密，需要密钥: %s", f.Name)
+	}
+	method, err := aesMethod(f.Extra)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name, err)
+	}
+	overhead := uint64(aesSaltSize + 2 + aesMACSize)
+	if f.CompressedSize64 < overhead {
+		return nil, i18n.Errorf("加密条目已损坏: %s", f.Name)
+	}
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+	head := make([]byte, aesSaltSize+2)
+	if _, err := io.ReadFull(raw, head); err != nil {
+		return nil, i18n.Errorf("读取加密条目失败: %w", err)
+	}
+	keys := pbkdf2SHA1([]byte(password), head[:aesSaltSize], pbkdf2Rounds, 2*aesKeySize+2)
+	if !hmac.Equal(keys[2*aesKeySize:], head[aesSaltSize:]) {
+		return nil, i18n.Errorf("密钥错误: %s", f.Name)
+	}
+	block, err := aes.NewCipher(keys[:aesKeySize])
+	if err != nil {
+		return nil, err
+	}
+	dec := &ctrReader{
+		raw:  io.LimitReader(raw, int64(f.CompressedSize64-overhead)),
+		tail: raw,
+		ctr:  newCTR(block),
+		mac:  hmac.New(sha1.New, keys[aesKeySize:2*aesKeySize]),
+	}
+	switch method {
+	case zip.Store:
+		return io.NopCloser(dec), nil
+	case zip.Deflate:
+		return &aesReader{body: flate.NewReader(dec), dec: dec}, nil
+	default:
+		return nil, i18n.Errorf("不支持的压缩方法 %d: %s", method, f.Name)
+	}
+}
+
+// aesReader 解压解密后的内容，解压完成时读完剩余的密文，确保核对过校验码
+type aesReader struct {
+	body io.ReadCloser
+	dec  *ctrReader
+}
+
+func (a *aesReader) Read(p []byte) (int, error) {
+	n, err := a.body.Read(p)
+	if err == io.EOF {
+		if _, derr := io.Copy(io.Discard, a.dec); derr != nil {
+			return n, derr
+		}
+	}
+	return n, err
+}
+
+func (a *aesReader) Close() error {
+	return a.body.Close()
+}
+
+// aesMethod 从 AES 扩展字段中取得实际的压缩方法
+func aesMethod(extra []byte) (uint16, error) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		if len(extra) < 4+size {
+			break
+		}
+		if id == aesExtraID && size == 7 {
+			if extra[4+4] != aesStrength {
+				return 0, i18n.Errorf("只支持 AES-256 加密")
+			}
+			return binary.LittleEndian.Uint16(extra[4+5:]), nil
+		}
+		extra = extra[4+size:]
+	}
+	return 0, i18n.Errorf("缺少 AES 扩展字段")
+}
+
+// ctrReader 解密读取，读完密文后核对末尾的校验码
+type ctrReader struct {
+	raw     io.Reader // 密文部分
+	tail    io.Reader // 密文之后的校验码
+	ctr     *aesCTR
+	mac     hash.Hash
+	checked bool
+}
+
+func (c *ctrReader) Read(p []byte) (int, error) {
+	if c.checked {
+		return 0, io.EOF
+	}
+	n, err := c.raw.Read(p)
+	c.mac.Write(p[:n])
+	c.ctr.xor(p[:n], p[:n])
+	if err == io.EOF {
+		c.checked = true
+		sum := make([]byte, aesMACSize)
+		if _, rerr := io.ReadFull(c.tail, sum); rerr != nil {
+			return n, i18n.Errorf("读取校验码失败: %w", rerr)
+		}
+		if !hmac.Equal(sum, c.mac.Sum(nil)[:aesMACSize]) {
+			return n, i18n.Errorf("校验码不匹配，内容可能已损坏")
+		}
+	}
+	return n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n uint64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += uint64(n)
+	return n, err
+}
+
+// pbkdf2SHA1 按 RFC 8018 从密码派生密钥
+func pbkdf2SHA1(password, salt []byte, rounds, keyLen int) []byte {
+	prf := hmac.New(sha1.New, password)
+	var out []byte
+	for block := uint32(1); len(out) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(binary.BigEndian.AppendUint32(nil, block))
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < rounds; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:keyLen]
+}
@@ -0,0 +1,151 @@
+package zip
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+)
+
+// RFC 6070 2，跳过 16777216 轮的用例
+func TestPBKDF2SHA1(t *testing.T) {
+	cases := []struct {
+		password, salt string
+		rounds, keyLen int
+		key            string
+	}{
+		{"password", "salt", 1, 20, "0c60c80f961f0e71f3a9b524af6012062fe037a6"},
+		{"password", "salt", 2, 20, "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957"},
+		{"password", "salt", 4096, 20, "4b007901b765489abead49d926f721d065a429c1"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, 25, "3d2eec4fe41c849b80c8d83662c0e44a8b291a964cf2f07038"},
+		{"pass\x00word", "sa\x00lt", 4096, 16, "56fa6aa75548099dcc37d7f03425e0c3"},
+	}
+	for _, c := range cases {
+		got := pbkdf2SHA1([]byte(c.password), []byte(c.salt), c.rounds, c.keyLen)
+		if hex.EncodeToString(got) != c.key {
+			t.Errorf("pbkdf2SHA1(%q, %q, %d) = %x, want %s", c.password, c.salt, c.rounds, got, c.key)
+		}
+	}
+}
+
+// writeEncrypted 生成只有一个加密条目的压缩文件
+func writeEncrypted(t *testing.T, c compression, password string, content []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := createEncrypted(zw, &zip.FileHeader{Name: "a.txt"}, password, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// readEncrypted 用标准库读取压缩文件，再通过 openEncrypted 解密唯一的条目
+func readEncrypted(t *testing.T, data []byte, password string) ([]byte, error) {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 1 {
+		t.Fatalf("got %d entries, want 1", len(zr.File))
+	}
+	f := zr.File[0]
+	if f.Method != methodAES || f.Flags&flagEncrypted == 0 {
+		t.Fatalf("entry method %d flags %#x, want AES encrypted", f.Method, f.Flags)
+	}
+	r, err := openEncrypted(f, password)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	content := []byte(strings.Repeat("neo-nas 加密条目测试\n", 5000))
+	levels := map[string]compression{
+		"store":   {zip.Store, flate.NoCompression},
+		"deflate": {zip.Deflate, flate.DefaultCompression},
+	}
+	for name, c := range levels {
+		t.Run(name, func(t *testing.T) {
+			data := writeEncrypted(t, c, "secret", content)
+			if bytes.Contains(data, content[:64]) {
+				t.Fatal("plaintext found in archive")
+			}
+			got, err := readEncrypted(t, data, "secret")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Fatalf("decrypted %d bytes, want %d", len(got), len(content))
+			}
+		})
+	}
+}
+
+func TestEncryptedEmpty(t *testing.T) {
+	data := writeEncrypted(t, compression{zip.Store, flate.NoCompression}, "secret", nil)
+	got, err := readEncrypted(t, data, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("decrypted %d bytes, want 0", len(got))
+	}
+}
+
+func TestEncryptedWrongPassword(t *testing.T) {
+	data := writeEncrypted(t, compression{zip.Deflate, flate.DefaultCompression}, "secret", []byte("hello"))
+	if _, err := readEncrypted(t, data, "wrong"); err == nil {
+		t.Fatal("wrong password accepted")
+	}
+	if _, err := readEncrypted(t, data, ""); err == nil {
+		t.Fatal("empty password accepted")
+	}
+}
+
+// entryData 条目数据在压缩文件中的位置：盐值和密码校验值、密文、校验码
+func entryData(t *testing.T, data []byte) (start, end int64) {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, err = zr.File[0].DataOffset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return start, start + int64(zr.File[0].CompressedSize64)
+}
+
+func TestEncryptedTampered(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 100))
+	c := compression{zip.Store, flate.NoCompression}
+	cases := map[string]func(start, end int64) int64{
+		"ciphertext": func(start, end int64) int64 { return start + aesSaltSize + 2 + 10 },
+		"mac":        func(start, end int64) int64 { return end - 1 },
+	}
+	for name, offset := range cases {
+		t.Run(name, func(t *testing.T) {
+			data := writeEncrypted(t, c, "secret", content)
+			data[offset(entryData(t, data))] ^= 0x01
+			if _, err := readEncrypted(t, data, "secret"); err == nil {
+				t.Fatal("tampered entry accepted")
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package zip
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// archiveWriter 按压缩格式写入压缩文件中的条目
+type archiveWriter interface {
+	// add 把普通文件的内容 src 写入压缩文件中的 name
+	add(ctx context.Context, name string, src io.Reader, info os.FileInfo) error
+	// addDir 在压缩文件中创建目录 name
+	addDir(name string, info os.FileInfo) error
+	// Close 写入剩余的数据，压缩文件完整时返回 nil
+	Close() error
+}
+
+// Check 检查压缩任务的压缩格式、压缩级别和远程目标等配置，供 validate 命令使用
+func Check(item config.ZipItem) error {
+	return checkFormat(item)
+}
+
+// checkFormat 检查压缩格式、压缩级别及其需要的命令，在创建压缩文件之前检查，配置错误时不会清空已有的压缩文件
+func checkFormat(item config.ZipItem) error {
+	switch item.Format {
+	case "", config.FormatZip:
+	case config.FormatTarGz, config.FormatTarZst:
+		if item.Key != "" {
+			return i18n.Errorf("%s 格式不支持加密，需要加密时使用 zip 格式", item.Format)
+		}
+		if item.Format == config.FormatTarZst {
+			if _, err := exec.LookPath("zstd"); err != nil {
+				return i18n.Errorf("tar.zst 格式需要安装 zstd 命令: %w", err)
+			}
+		}
+	default:
+		return i18n.Errorf("不支持的压缩格式: %s，可选 zip、tar.gz 或 tar.zst", item.Format)
+	}
+	if err := checkStream(item); err != nil {
+		return err
+	}
+	if item.Workers < 0 {
+		return i18n.Errorf("压缩线程数不能小于 0: %d", item.Workers)
+	}
+	if item.MaxTotalBytes < 0 {
+		return i18n.Errorf("max_total_bytes 不能小于 0: %d", item.MaxTotalBytes)
+	}
+	_, err := parseLevel(item.Level)
+	return err
+}
+
+// workers 压缩使用的线程数，默认使用全部 CPU 核心
+func workers(item config.ZipItem) int {
+	if item.Workers > 0 {
+		return item.Workers
+	}
+	return runtime.NumCPU()
+}
+
+// compression 压缩级别对应的 zip 压缩方法和 flate 级别，gzip 使用相同的级别
+type compression struct {
+	method uint16
+	level  int
+}
+
+// parseLevel 解析压缩级别：store 不压缩，fast 最快，best 压缩率最高，默认使用各格式的默认级别
+func parseLevel(s string) (compression, error) {
+	switch s {
+	case "":
+		return compression{zip.Deflate, flate.DefaultCompression}, nil
+	case config.LevelStore:
+		return compression{zip.Store, flate.NoCompression}, nil
+	case config.LevelFast:
+		return compression{zip.Deflate, flate.BestSpeed}, nil
+	case config.LevelBest:
+		return compression{zip.Deflate, flate.BestCompression}, nil
+	default:
+		return compression{}, i18n.Errorf("不支持的压缩级别: %s，可选 store、fast 或 best", s)
+	}
+}
+
+// newArchive 按 item.Format 和 item.Level 创建写入 w 的 archiveWriter，item 需要已通过 checkFormat
+func newArchive(w io.Writer, item config.ZipItem) (archiveWriter, error) {
+	c, err := parseLevel(item.Level)
+	if err != nil {
+		return nil, err
+	}
+	switch item.Format {
+	case config.FormatTarGz:
+		if n := workers(item); n > 1 {
+			return newTarArchive(newParallelGzip(w, c.level, n)), nil
+		}
+		gw, err := gzip.NewWriterLevel(w, c.level)
+		if err != nil {
+			return nil, err
+		}
+		return newTarArchive(gw), nil
+	case config.FormatTarZst:
+		comp, err := newZstdWriter(w, item.Level, workers(item))
+		if err != nil {
+			return nil, err
+		}
+		return newTarArchive(comp), nil
+	default:
+		zw := zip.NewWriter(w)
+		if c.method == zip.Deflate && c.level != flate.DefaultCompression {
+			zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+				return flate.NewWriter(out, c.level)
+			})
+		}
+		return &zipArchive{zw: zw, key: item.Key, comp: c}, nil
+	}
+}
+
+// zipArchive zip 格式，设置了密钥时使用 AES-256 加密每个条目
+type zipArchive struct {
+	zw   *zip.Writer
+	key  string
+	comp compression
+}
+
+func (a *zipArchive) add(ctx context.Context, name string, src io.Reader, info os.FileInfo) error {
+	return addFile(ctx, a.zw, name, src, info, a.key, a.comp)
+}
+
+func (a *zipArchive) addDir(name string, info os.FileInfo) error {
+	fh, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return i18n.Errorf("创建压缩文件中的目录失败: %w", err)
+	}
+	fh.Name, fh.Method = filepath.ToSlash(name)+"/", zip.Store
+	fh.CompressedSize64, fh.UncompressedSize64 = 0, 0
+	fh.CompressedSize, fh.UncompressedSize = 0, 0
+	if _, err := a.zw.CreateHeader(fh); err != nil {
+		return i18n.Errorf("创建压缩文件中的目录失败: %w", err)
+	}
+	return nil
+}
+
+func (a *zipArchive) Close() error {
+	return a.zw.Close()
+}
+
+// tarArchive tar 格式，条目中记录修改时间、权限和所有者，整个文件由 comp 压缩
+type tarArchive struct {
+	tw   *tar.Writer
+	comp io.WriteCloser
+}
+
+func newTarArchive(comp io.WriteCloser) *tarArchive {
+	return &tarArchive{tw: tar.NewWriter(comp), comp: comp}
+}
+
+func (a *tarArchive) add(ctx context.Context, name string, src io.Reader, info os.FileInfo) error {
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return i18n.Errorf("创建压缩文件中的文件失败: %w", err)
+	}
+	hdr.Name = filepath.ToSlash(name)
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return i18n.Errorf("创建压缩文件中的文件失败: %w", err)
+	}
+	// 源文件在压缩过程中变长时只写入记录的大小，tar 条目的大小必须与头部一致
+	n, err := io.Copy(a.tw, io.LimitReader(ctxReader{ctx, src}, hdr.Size))
+	if err != nil {
+		return i18n.Errorf("复制文件内容到压缩文件失败: %w", err)
+	}
+	if n < hdr.Size {
+		return i18n.Errorf("复制文件内容到压缩文件失败: 源文件在压缩过程中变短: %s", name)
+	}
+	return nil
+}
+
+func (a *tarArchive) addDir(name string, info os.FileInfo) error {
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return i18n.Errorf("创建压缩文件中的目录失败: %w", err)
+	}
+	hdr.Name = filepath.ToSlash(name) + "/"
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return i18n.Errorf("创建压缩文件中的目录失败: %w", err)
+	}
+	return nil
+}
+
+func (a *tarArchive) Close() error {
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	return a.comp.Close()
+}
+
+// zstdWriter 通过 zstd 命令压缩，数据从标准输入写入，压缩结果写到 w
+type zstdWriter struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr strings.Builder
+}
+
+// newZstdWriter 启动使用 workers 个线程的 zstd，zstd 没有不压缩的模式，store 和 fast 都使用最快的级别
+func newZstdWriter(w io.Writer, level string, workers int) (*zstdWriter, error) {
+	args := []string{"-q", "-c", "-T" + strconv.Itoa(workers)}
+	switch level {
+	case config.LevelStore, config.LevelFast:
+		args = append(args, "--fast")
+	case config.LevelBest:
+		args = append(args, "-19")
+	}
+	z := &zstdWriter{cmd: exec.Command("zstd", args...)}
+	z.cmd.Stdout = w
+	z.cmd.Stderr = &z.stderr
+	stdin, err := z.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	z.stdin = stdin
+	if err := z.cmd.Start(); err != nil {
+		return nil, i18n.Errorf("启动 zstd 失败: %w", err)
+	}
+	return z, nil
+}
+
+func (z *zstdWriter) Write(p []byte) (int, error) {
+	return z.stdin.Write(p)
+}
+
+// Close 关闭标准输入并等待 zstd 写完压缩结果
+func (z *zstdWriter) Close() error {
+	z.stdin.Close()
+	if err := z.cmd.Wait(); err != nil {
+		return fmt.Errorf("zstd: %w: %s", err, strings.TrimSpace(z.stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package zip
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/parity"
+)
+
+// defaultFullIntervalDays 增量压缩时默认每隔几天生成一次完整的压缩文件
+const defaultFullIntervalDays = 7
+
+// itemState 一个压缩任务最近一次成功运行的时间，增量压缩只写入之后修改的文件
+type itemState struct {
+	LastFull time.Time  `json:"last_full"`         // 最近一次完整压缩开始的时间
+	LastRun  time.Time  `json:"last_run"`          // 最近一次完整或增量压缩开始的时间
+	Full     string     `json:"full,omitempty"`    // 最近一次完整压缩生成的文件，保留多个压缩文件时带有时间
+	Uploads  [][]string `json:"uploads,omitempty"` // 设置了 remote_keep 时上传位置中的副本，每组为一个完整的压缩文件及其增量压缩文件，按上传顺序
+}
+
+// current 最近一次完整压缩生成的文件
+func (st itemState) current(item config.ZipItem) string {
+	if st.Full != "" {
+		return st.Full
+	}
+	return expand(item, time.Time{})
+}
+
+// stateStore 保存各压缩任务的 itemState，以压缩文件路径区分
+type stateStore struct {
+	file  string
+	mu    sync.Mutex
+	items map[string]itemState
+}
+
+func openState(file string) *stateStore {
+	s := &stateStore{file: file, items: make(map[string]itemState)}
+	if file == "" {
+		return s
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Errorf("读取压缩任务状态失败: %v", err)
+		}
+		return s
+	}
+	if err := json.Unmarshal(data, &s.items); err != nil {
+		logger.Warnf("解析压缩任务状态失败，下次压缩时生成完整的压缩文件: %v", err)
+		s.items = make(map[string]itemState)
+	}
+	return s
+}
+
+func (s *stateStore) get(target string) (itemState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.items[target]
+	return st, ok
+}
+
+// set 更新并写入状态文件
+func (s *stateStore) set(target string, st itemState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[target] = st
+	if s.file == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.items, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return i18n.Errorf("写入压缩任务状态失败: %w", err)
+	}
+	return os.Rename(tmp, s.file)
+}
+
+// needFull 增量压缩时判断这次是否需要生成完整的压缩文件
+func needFull(item config.ZipItem, st itemState, ok bool, now time.Time) bool {
+	if !item.Incremental || !ok || st.LastFull.IsZero() {
+		return true
+	}
+	if _, err := os.Stat(st.current(item)); err != nil {
+		return true
+	}
+	days := item.FullIntervalDays
+	if days <= 0 {
+		days = defaultFullIntervalDays
+	}
+	return now.Sub(st.LastFull) >= time.Duration(days)*24*time.Hour
+}
+
+// splitExt 把压缩文件路径分为名称和扩展名，tar.gz 和 tar.zst 作为一个扩展名
+func splitExt(target string) (string, string) {
+	for _, ext := range []string{".tar.gz", ".tar.zst"} {
+		if strings.HasSuffix(target, ext) {
+			return strings.TrimSuffix(target, ext), ext
+		}
+	}
+	ext := filepath.Ext(target)
+	return strings.TrimSuffix(target, ext), ext
+}
+
+// stampLayout 压缩文件名中的时间格式，按文件名排序即按时间排序
+const stampLayout = "20060102-150405"
+
+// stampedPath 在压缩文件名的扩展名前加上 -<prefix><时间>
+func stampedPath(target, prefix string, t time.Time) string {
+	base, ext := splitExt(target)
+	return base + "-" + prefix + t.Format(stampLayout) + ext
+}
+
+// stampedFiles 与压缩文件同一目录中由 stampedPath 生成的文件，按时间排序
+func stampedFiles(target, prefix string) []string {
+	base, ext := splitExt(filepath.Base(target))
+	entries, _ := os.ReadDir(filepath.Dir(target))
+	var result []string
+	for _, e := range entries {
+		stamp, ok := strings.CutPrefix(e.Name(), base+"-"+prefix)
+		if !ok || !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+		if _, err := time.Parse(stampLayout, strings.TrimSuffix(stamp, ext)); err == nil {
+			result = append(result, filepath.Join(filepath.Dir(target), e.Name()))
+		}
+	}
+	return result
+}
+
+// incrementalPath 增量压缩文件的路径，full 为完整的压缩文件，例如 foo.zip 在 2024-05-01 12:00:00 的增量为 foo-inc-20240501-120000.zip
+func incrementalPath(full string, start time.Time) string {
+	return stampedPath(full, "inc-", start)
+}
+
+// incrementalFiles 完整的压缩文件 full 之后生成的增量压缩文件，按生成时间排序
+func incrementalFiles(full string) []string {
+	return stampedFiles(full, "inc-")
+}
+
+// removeIncrementals 删除完整的压缩文件 full 之后生成的增量压缩文件及其恢复数据
+func removeIncrementals(full string) {
+	for _, f := range incrementalFiles(full) {
+		if err := os.Remove(f); err != nil {
+			logger.Errorf("删除旧的增量压缩文件失败: %v", err)
+			continue
+		}
+		os.Remove(parity.Sidecar(f))
+		logger.Infof("已删除旧的增量压缩文件: %s", f)
+	}
+}
+
+// Produces 判断 archive 是否为压缩任务生成的压缩文件，包括保留的旧压缩文件和增量压缩文件
+func Produces(item config.ZipItem, archive string) bool {
+	archive = filepath.Clean(archive)
+	for _, full := range append([]string{filepath.Clean(expand(item, time.Time{}))}, rotatedFiles(item)...) {
+		if full == archive {
+			return true
+		}
+		for _, f := range incrementalFiles(full) {
+			if f == archive {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -2,150 +2,624 @@ package zip
 
 import (
 	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/cron"
+	"github.com/lucasrui/neo-nas/internal/dryrun"
+	"github.com/lucasrui/neo-nas/internal/history"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/logging"
+	"github.com/lucasrui/neo-nas/internal/parity"
+	"github.com/lucasrui/neo-nas/internal/priority"
+	"github.com/lucasrui/neo-nas/internal/remote"
 )
 
+// logger zip 模块的日志
+var logger = logging.New("zip")
+
 type ZipManager struct {
 	IntervalSeconds int              `json:"interval_seconds"` // 压缩间隔时间
 	Items           []config.ZipItem `json:"items"`            // 压缩配置列表
+	remote          remote.Options   // 上传压缩文件时使用
+	history         *history.Store   // 记录每次压缩的结果
+	state           *stateStore      // 各压缩任务最近一次运行的时间，用于增量压缩
+	mu              sync.Mutex
+	running         string     // 正在执行的压缩任务的目标路径
+	progress        *Progress  // 正在写入的压缩文件的进度
+	lastProgressLog time.Time  // 最近一次记录进度日志的时间
+	runMu           sync.Mutex // 同一时间只执行一个压缩任务，定时任务和管理接口触发的任务不会同时写入
+	ctx             context.Context
+	cancel          context.CancelFunc // 中止正在写入的压缩文件
+	stop            chan struct{}      // Stop 时关闭，不再开始新的压缩任务
+	reload          chan struct{}      // Update 替换配置后通知定时任务
+	stopOnce        sync.Once
 }
 
-func StartZipManager(config config.ZipConfig) {
-	zipMgr := &ZipManager{
+// NewZipManager 创建压缩任务管理器，不启动定时任务，stateFile 记录各压缩任务最近一次运行的时间
+func NewZipManager(config config.ZipConfig, opts remote.Options, hist *history.Store, stateFile string) *ZipManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ZipManager{
 		IntervalSeconds: config.IntervalSeconds,
 		Items:           config.Items,
+		remote:          opts,
+		history:         hist,
+		state:           openState(stateFile),
+		ctx:             ctx,
+		cancel:          cancel,
+		stop:            make(chan struct{}),
+		reload:          make(chan struct{}, 1),
 	}
-	// 判断items的长度，如果为0，则不启动压缩任务
-	if len(zipMgr.Items) == 0 {
-		log.Printf("压缩任务列表为空，不启动压缩任务")
-		return
-	}
-	log.Printf("已配置 %d 个压缩任务", len(zipMgr.Items))
-	zipMgr.Start()
 }
 
+// Start 按压缩间隔定时执行没有设置 cron 的压缩任务，设置了 cron 的压缩任务按各自的时间执行，直到 Stop
+// 压缩间隔为 0 且没有压缩任务设置 cron 时只等待 Update 或 Stop
 func (z *ZipManager) Start() {
+	priority.Lower()
 	// 以intervalSeconds为时间间隔启动定时任务
-	ticker := time.NewTicker(time.Duration(z.IntervalSeconds) * time.Second)
-	defer ticker.Stop()
+	var ticker *time.Ticker
+	var tick <-chan time.Time
+	// 设置了 cron 的压缩任务下一次运行的时间，定时器在其中最早的时间触发
+	var cronTimer *time.Timer
+	var cronTick <-chan time.Time
+	next := make(map[string]time.Time)
+	arm := func() {
+		if cronTimer != nil {
+			cronTimer.Stop()
+			cronTimer, cronTick = nil, nil
+		}
+		var earliest time.Time
+		for _, t := range next {
+			if earliest.IsZero() || t.Before(earliest) {
+				earliest = t
+			}
+		}
+		if !earliest.IsZero() {
+			cronTimer = time.NewTimer(time.Until(earliest))
+			cronTick = cronTimer.C
+		}
+	}
+	reset := func() {
+		if ticker != nil {
+			ticker.Stop()
+			ticker, tick = nil, nil
+		}
+		if interval, _ := z.config(); interval > 0 {
+			ticker = time.NewTicker(interval)
+			tick = ticker.C
+		}
+		_, items := z.config()
+		next = make(map[string]time.Time)
+		for _, item := range items {
+			if item.Cron == "" {
+				continue
+			}
+			if t := nextCron(item, time.Now()); !t.IsZero() {
+				next[item.Target] = t
+			}
+		}
+		arm()
+	}
+	reset()
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+		if cronTimer != nil {
+			cronTimer.Stop()
+		}
+	}()
 
-	for range ticker.C {
+	for {
+		cronDue := false
+		select {
+		case <-tick:
+		case <-cronTick:
+			cronDue = true
+		case <-z.reload:
+			reset()
+			continue
+		case <-z.stop:
+			return
+		}
 		// 遍历items，执行压缩任务
-		for _, item := range z.Items {
-			z.Zip(item)
+		_, items := z.config()
+		now := time.Now()
+		for _, item := range items {
+			if z.stopped() {
+				return
+			}
+			if !cronDue && item.Cron == "" {
+				z.zipLogged(item)
+				continue
+			}
+			if t, ok := next[item.Target]; cronDue && item.Cron != "" && ok && !t.After(now) {
+				z.zipLogged(item)
+				// 压缩期间错过的时间不再补执行
+				if t := nextCron(item, time.Now()); !t.IsZero() {
+					next[item.Target] = t
+				} else {
+					delete(next, item.Target)
+				}
+			}
+		}
+		if cronDue {
+			arm()
+		}
+	}
+}
+
+// nextCron 设置了 cron 的压缩任务在 t 之后下一次运行的时间，表达式无效时记录日志并返回零值
+func nextCron(item config.ZipItem, t time.Time) time.Time {
+	sched, err := cron.Parse(item.Cron)
+	if err != nil {
+		logger.Warnf("压缩任务的 cron 表达式无效，不定时执行 %s: %v", item.Target, err)
+		return time.Time{}
+	}
+	return sched.Next(t)
+}
+
+// Update 替换压缩间隔和压缩任务列表，正在执行的压缩任务不受影响，定时从替换时重新开始计算
+func (z *ZipManager) Update(cfg config.ZipConfig) {
+	z.mu.Lock()
+	z.IntervalSeconds, z.Items = cfg.IntervalSeconds, cfg.Items
+	z.mu.Unlock()
+	select {
+	case z.reload <- struct{}{}:
+	default:
+	}
+}
+
+func (z *ZipManager) config() (time.Duration, []config.ZipItem) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return time.Duration(z.IntervalSeconds) * time.Second, z.Items
+}
+
+// Stop 不再开始新的压缩任务，等待正在写入的压缩文件完成
+// ctx 结束时仍未完成则中止，删除写了一半的压缩文件，下次启动时重新压缩
+func (z *ZipManager) Stop(ctx context.Context) {
+	z.stopOnce.Do(func() { close(z.stop) })
+	finished := make(chan struct{})
+	go func() {
+		z.runMu.Lock()
+		z.runMu.Unlock()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		logger.Warnf("等待压缩任务完成超时，中止压缩: %s", z.Running())
+		z.cancel()
+		<-finished
+	}
+	z.cancel()
+}
+
+func (z *ZipManager) stopped() bool {
+	select {
+	case <-z.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// Due 压缩文件不存在或距上次生成已超过压缩间隔时返回 true，增量压缩时按上次增量的时间计算
+// 设置了 cron 的压缩任务在上次生成之后又到了 cron 的时间时返回 true，没有设置 cron 且压缩间隔为 0 时返回 false
+func (z *ZipManager) Due(item config.ZipItem, now time.Time) bool {
+	interval, _ := z.config()
+	if item.Cron == "" && interval <= 0 {
+		return false
+	}
+	var last time.Time
+	if item.IsRemoteTarget() {
+		// 远程的压缩文件只按记录的运行时间计算
+		st, ok := z.state.get(item.Target)
+		if !ok {
+			return true
+		}
+		last = st.LastRun
+	} else {
+		current := expand(item, now)
+		if files := rotatedFiles(item); (rotating(item) || timed(item.Target)) && len(files) > 0 {
+			current = files[len(files)-1]
+		}
+		info, err := os.Stat(current)
+		if err != nil {
+			return true
 		}
+		last = info.ModTime()
+		if st, ok := z.state.get(item.Target); ok && st.LastRun.After(last) {
+			last = st.LastRun
+		}
+	}
+	if item.Cron != "" {
+		t := nextCron(item, last)
+		return !t.IsZero() && !t.After(now)
 	}
+	return now.Sub(last) >= interval
+}
+
+func (z *ZipManager) zipLogged(item config.ZipItem) {
+	if err := z.Zip(item); err != nil {
+		logFailure(item, err)
+	}
+}
+
+// logFailure 记录压缩任务失败
+func logFailure(item config.ZipItem, err error) {
+	logger.With(logging.KeyTask, item.Target, logging.KeyStatus, logging.StatusFailed, logging.KeyError, err.Error()).
+		Errorf("压缩任务失败，源路径: %s, 目标路径: %s, 错误原因: %v", item.Source, item.Target, err)
+}
+
+// doneLog 附带任务、压缩文件、大小、用时和文件数的日志，记录压缩任务完成；直接写入远程存储时没有本地文件，不记录大小
+func doneLog(item config.ZipItem, out string, added int, start time.Time) *logging.Logger {
+	l := logger.With(logging.KeyTask, item.Target, logging.KeyFile, out, logging.KeyDuration, time.Since(start),
+		logging.KeyStatus, logging.StatusSuccess, "files", added)
+	if !item.IsRemoteTarget() {
+		if info, err := os.Stat(out); err == nil {
+			l = l.With(logging.KeyBytes, info.Size())
+		}
+	}
+	return l
+}
+
+// Trigger 在后台立即执行一个压缩任务，已有压缩任务正在执行时返回 false
+func (z *ZipManager) Trigger(item config.ZipItem) bool {
+	if !z.runMu.TryLock() {
+		return false
+	}
+	if z.stopped() {
+		z.runMu.Unlock()
+		return false
+	}
+	go func() {
+		defer z.runMu.Unlock()
+		if err := z.run(item); err != nil {
+			logFailure(item, err)
+		}
+	}()
+	return true
+}
+
+// Zip 执行一个压缩任务并记录结果
+func (z *ZipManager) Zip(item config.ZipItem) error {
+	z.runMu.Lock()
+	defer z.runMu.Unlock()
+	return z.run(item)
+}
+
+func (z *ZipManager) run(item config.ZipItem) error {
+	if dryrun.Enabled() {
+		if _, err := os.Stat(item.Source); err != nil {
+			return i18n.Errorf("源路径不存在: %w", err)
+		}
+		if err := checkFormat(item); err != nil {
+			return err
+		}
+		dryrun.Skip("写入压缩文件: %s -> %s", item.Source, item.Target)
+		if item.Upload != "" {
+			dryrun.Skip("上传压缩文件: %s -> %s", item.Target, item.Upload)
+		}
+		return nil
+	}
+	z.setRunning(item.Target)
+	defer z.setRunning("")
+	defer z.endProgress()
+	start := time.Now()
+	err := z.zip(item)
+	r := history.Run{Kind: history.KindZip, Source: item.Source, Target: item.Target, Start: start, End: time.Now(), Result: history.ResultSuccess}
+	if err != nil {
+		r.Result, r.Error = history.ResultFailed, err.Error()
+	}
+	z.history.Record(r)
+	return err
+}
+
+func (z *ZipManager) setRunning(target string) {
+	z.mu.Lock()
+	z.running = target
+	z.mu.Unlock()
+}
+
+// Running 返回正在执行的压缩任务的目标路径，没有时返回空字符串
+func (z *ZipManager) Running() string {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return z.running
 }
 
 // 压缩实现方法
-func (z *ZipManager) Zip(item config.ZipItem) {
+func (z *ZipManager) zip(item config.ZipItem) error {
 	// 输入item的日志
-	log.Printf("执行压缩任务，源路径: %s, 目标路径: %s", item.Source, item.Target)
+	logger.Infof("执行压缩任务，源路径: %s, 目标路径: %s", item.Source, item.Target)
 
-	// 创建压缩文件
-	zipFile, err := os.Create(item.Target)
+	// 检查item.Source是否存在，以及是否为文件夹、文件
+	info, err := os.Stat(item.Source)
 	if err != nil {
-		log.Printf("创建压缩文件失败: %v", err)
-		return
+		return i18n.Errorf("源路径不存在: %w", err)
 	}
+
+	if err := checkFormat(item); err != nil {
+		return err
+	}
+	if item.IsRemoteTarget() {
+		return z.zipRemote(item, info)
+	}
+
+	// 增量压缩时只写入上次压缩之后修改的文件，到期时生成完整的压缩文件
+	start := time.Now()
+	st, ok := z.state.get(item.Target)
+	full := needFull(item, st, ok, start)
+	out, since := fullPath(item, start), time.Time{}
+	if !full {
+		out, since = incrementalPath(st.current(item), start), st.LastRun
+		logger.Infof("增量压缩，只写入 %s 之后修改的文件: %s", since.Format(time.DateTime), out)
+	}
+	added, err := z.write(item, out, info, since)
+	if err != nil {
+		return err
+	}
+	next := itemState{LastFull: st.LastFull, LastRun: start, Full: st.Full, Uploads: st.Uploads}
+	if full {
+		next.LastFull, next.Full = start, out
+	}
+	if !full && added == 0 {
+		os.Remove(out)
+		logger.Infof("上次压缩之后没有修改的文件，不生成增量压缩文件: %s", item.Source)
+		return z.state.set(item.Target, next)
+	}
+
+	// 设置压缩文件的所有者
+	if item.TargetUser != "" {
+		// 从targetUser中解析出uid和gid，格式为uid:gid
+		uidGid := strings.Split(item.TargetUser, ":")
+		if len(uidGid) == 2 {
+			targetUid, _ := strconv.Atoi(uidGid[0])
+			targetGid, _ := strconv.Atoi(uidGid[1])
+			if err := os.Chown(out, targetUid, targetGid); err != nil {
+				logger.Errorf("设置压缩文件所有者失败: %v", err)
+			}
+		}
+	}
+
+	doneLog(item, out, added, start).Infof("压缩任务完成，源路径: %s, 目标路径: %s, 文件: %d", item.Source, out, added)
+
+	if item.ParityPercent > 0 {
+		if err := parity.Create(out, item.ParityPercent); err != nil {
+			return i18n.Errorf("生成恢复数据失败: %w", err)
+		}
+		logger.Infof("恢复数据已生成: %s", parity.Sidecar(out))
+	}
+
+	if item.Upload != "" {
+		if err := z.upload(item, out); err != nil {
+			return i18n.Errorf("上传压缩文件失败: %w", err)
+		}
+		if item.RemoteKeep > 0 && rotating(item) {
+			next.Uploads = z.pruneUploads(item, recordUpload(st.Uploads, full, filepath.Base(out)))
+		}
+	}
+	// 新的完整压缩文件已经包含了之前增量中的文件，保留多个压缩文件时增量压缩文件随其完整的压缩文件删除
+	// 设置了 max_total_bytes 时增量压缩之后也检查总大小
+	if rotating(item) && (full || item.MaxTotalBytes > 0) {
+		z.rotate(item)
+	} else if full && item.Incremental {
+		removeIncrementals(st.current(item))
+	}
+	return z.state.set(item.Target, next)
+}
+
+// partSuffix 正在写入的压缩文件的后缀
+const partSuffix = ".part"
+
+// write 把源路径中 since 之后修改的文件写入压缩文件 out，since 为零值时写入全部文件，返回写入的文件数量
+// 先写入 <out>.part，校验通过后再替换 out，中途失败或程序崩溃时 out 保持上一次的内容
+func (z *ZipManager) write(item config.ZipItem, out string, info os.FileInfo, since time.Time) (int, error) {
+	// 路径的目录中有占位符时先创建目录
+	if filepath.Dir(out) != filepath.Dir(item.Target) {
+		if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+			return 0, i18n.Errorf("创建压缩文件所在的目录失败: %w", err)
+		}
+	}
+
+	// 创建临时的压缩文件
+	part := out + partSuffix
+	zipFile, err := os.Create(part)
+	if err != nil {
+		return 0, i18n.Errorf("创建压缩文件失败: %w", err)
+	}
+	defer os.Remove(part)
 	defer zipFile.Close()
 
-	// 创建 zip.Writer
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
+	// 按压缩格式创建 archiveWriter
+	archive, err := newArchive(zipFile, item)
+	if err != nil {
+		return 0, err
+	}
+	defer archive.Close()
 
-	// 检查item.Source是否存在，以及是否为文件夹、文件
-	info, err := os.Stat(item.Source)
+	names, added, err := z.fill(archive, item, info, since)
 	if err != nil {
-		log.Printf("源路径不存在: %v", err)
-		return
+		return 0, err
 	}
+
+	// 关闭压缩文件，确保内容已全部写入
+	if err := archive.Close(); err != nil {
+		return 0, i18n.Errorf("关闭压缩文件失败: %w", err)
+	}
+	if err := zipFile.Close(); err != nil {
+		return 0, i18n.Errorf("关闭压缩文件失败: %w", err)
+	}
+
+	// 重新读取压缩文件，确认每个条目都能完整解压
+	if err := verify(z.ctx, item, part, names); err != nil {
+		return 0, i18n.Errorf("校验压缩文件失败: %w", err)
+	}
+	if err := os.Rename(part, out); err != nil {
+		return 0, i18n.Errorf("替换压缩文件失败: %w", err)
+	}
+	return added, nil
+}
+
+// fill 把源路径中 since 之后修改的文件写入 archive，返回写入的条目名称（目录以 / 结尾）和文件数量
+func (z *ZipManager) fill(archive archiveWriter, item config.ZipItem, info os.FileInfo, since time.Time) ([]string, int, error) {
+	var names []string // 写入的条目，写完后核对
+	added := 0
+	// 先统计总大小，用于估计剩余时间
+	z.startProgress(item.Target, sourceSize(item.Source, info, since))
+	defer z.setProgressFile("")
+	add := func(name, file string, info os.FileInfo) error {
+		// 符号链接写入其指向的文件
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Stat(file)
+			if err != nil {
+				return i18n.Errorf("读取源文件失败: %w", err)
+			}
+			info = target
+		}
+		if !info.Mode().IsRegular() {
+			logger.Warnf("跳过不是普通文件的源文件: %s", file)
+			return nil
+		}
+		if !since.IsZero() && !info.ModTime().After(since) {
+			return nil
+		}
+		srcFile, err := os.Open(file)
+		if err != nil {
+			return i18n.Errorf("打开源文件失败: %w", err)
+		}
+		defer srcFile.Close()
+		added++
+		names = append(names, filepath.ToSlash(name))
+		z.setProgressFile(name)
+		return archive.add(z.ctx, name, progressReader{z, srcFile}, info)
+	}
+	var err error
 	if info.IsDir() {
 		// 遍历源路径中的文件并添加到压缩文件中
 		err = filepath.Walk(item.Source, func(file string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
-			if info.IsDir() {
-				return nil // 跳过目录
-			}
-
 			// 创建压缩文件中的文件
 			// 获取相对路径
 			relPath, err := filepath.Rel(item.Source, file)
 			if err != nil {
 				return err
 			}
-			zipFileWriter, err := zipWriter.Create(relPath)
-			if err != nil {
-				return err
-			}
-
-			// 打开源文件
-			srcFile, err := os.Open(file)
-			if err != nil {
-				return err
+			if info.IsDir() {
+				// 记录目录的权限和修改时间，空目录解压后同样存在
+				if relPath == "." || !since.IsZero() && !info.ModTime().After(since) {
+					return nil
+				}
+				names = append(names, filepath.ToSlash(relPath)+"/")
+				return archive.addDir(relPath, info)
 			}
-			defer srcFile.Close()
-
-			// 复制文件内容到压缩文件
-			_, err = io.Copy(zipFileWriter, srcFile)
-			return err
+			return add(relPath, file, info)
 		})
 	} else {
 		// 创建压缩文件中的文件
-		// 获取相对路径
-		relPath, err := filepath.Rel(filepath.Dir(item.Source), item.Source)
-		if err != nil {
-			return
-		}
-		zipFileWriter, err := zipWriter.Create(relPath)
-		if err != nil {
-			log.Printf("创建压缩文件中的文件失败: %v", err)
-			return
+		err = add(filepath.Base(item.Source), item.Source, info)
+	}
+	if err != nil {
+		if z.ctx.Err() != nil {
+			return nil, 0, i18n.Errorf("程序正在停止，压缩已中止，已删除不完整的压缩文件")
 		}
+		return nil, 0, i18n.Errorf("压缩文件失败: %w", err)
+	}
+	return names, added, nil
+}
 
-		// 打开源文件
-		srcFile, err := os.Open(item.Source)
+// addFile 把文件内容 src 按压缩级别 c 写入压缩文件中的 name，设置了 key 时使用 AES-256 加密
+func addFile(ctx context.Context, zipWriter *zip.Writer, name string, src io.Reader, info os.FileInfo, key string, c compression) error {
+	// 条目中记录权限和修改时间
+	fh, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return i18n.Errorf("创建压缩文件中的文件失败: %w", err)
+	}
+	fh.Name, fh.Method = filepath.ToSlash(name), c.method
+	var zipFileWriter io.Writer
+	if key != "" {
+		// 加密条目的大小在写完后更新；CreateRaw 不会根据 Modified 填写修改时间，这里直接设置
+		fh.CompressedSize64, fh.UncompressedSize64 = 0, 0
+		fh.CompressedSize, fh.UncompressedSize = 0, 0
+		fh.SetModTime(info.ModTime())
+		w, err := createEncrypted(zipWriter, fh, key, c)
 		if err != nil {
-			log.Printf("打开源文件失败: %v", err)
-			return
+			return i18n.Errorf("创建加密文件失败: %w", err)
 		}
-		defer srcFile.Close()
-
-		// 复制文件内容到压缩文件
-		_, err = io.Copy(zipFileWriter, srcFile)
+		zipFileWriter = w
+	} else {
+		w, err := zipWriter.CreateHeader(fh)
 		if err != nil {
-			log.Printf("复制文件内容到压缩文件失败: %v", err)
-			return
+			return i18n.Errorf("创建压缩文件中的文件失败: %w", err)
 		}
+		zipFileWriter = w
 	}
 
-	if err != nil {
-		log.Printf("压缩文件失败: %v", err)
-		return
+	// 复制文件内容到压缩文件
+	if _, err := io.Copy(zipFileWriter, ctxReader{ctx, src}); err != nil {
+		return i18n.Errorf("复制文件内容到压缩文件失败: %w", err)
 	}
-
-	// 设置压缩文件的所有者
-	if item.TargetUser != "" {
-		// 从targetUser中解析出uid和gid，格式为uid:gid
-		uidGid := strings.Split(item.TargetUser, ":")
-		if len(uidGid) == 2 {
-			targetUid, _ := strconv.Atoi(uidGid[0])
-			targetGid, _ := strconv.Atoi(uidGid[1])
-			if err := os.Chown(item.Target, targetUid, targetGid); err != nil {
-				log.Printf("设置压缩文件所有者失败: %v", err)
-			}
+	if w, ok := zipFileWriter.(io.Closer); ok {
+		if err := w.Close(); err != nil {
+			return i18n.Errorf("写入加密文件失败: %w", err)
 		}
 	}
+	return nil
+}
 
-	log.Printf("压缩任务完成，源路径: %s, 目标路径: %s", item.Source, item.Target)
+// ctxReader 每次读取前检查 ctx，中止时尽快停止写入
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// upload 把压缩文件 file 上传到配置的存储位置，对象名称与压缩文件名相同
+func (z *ZipManager) upload(item config.ZipItem, file string) error {
+	backend, err := remote.Open(item.Upload, z.remote)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	key := filepath.Base(file)
+	// 压缩文件可以定位，先计算哈希再上传，上传时不需要另外写临时文件
+	hash := sha256.New()
+	n, err := io.Copy(hash, f)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := backend.Put(key, f); err != nil {
+		return err
+	}
+	if err := remote.Verify(backend, key, n, hex.EncodeToString(hash.Sum(nil))); err != nil {
+		return err
+	}
+	logger.Infof("压缩文件已上传并校验: %s -> %s/%s", file, backend, key)
+	return nil
 }
@@ -0,0 +1,153 @@
+package zip
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"sync"
+)
+
+// 并行 gzip 的块大小和作为下一块字典的长度（deflate 的窗口大小）
+const (
+	gzipBlockSize = 1 << 20
+	gzipDictSize  = 32 << 10
+)
+
+// parallelGzip 与 pigz 相同，把数据按块分给多个线程压缩，每块以上一块的末尾作为字典
+// 各块以同步标记结束后按顺序拼接，结果是一个标准的 gzip 文件，解压时与普通 gzip 没有区别
+type parallelGzip struct {
+	w       io.Writer
+	level   int
+	buf     []byte
+	dict    []byte
+	crc     uint32
+	size    uint32
+	pending chan chan gzipBlock // 按顺序等待写入的块，容量限制同时压缩的块数
+	done    chan struct{}
+	closed  bool
+
+	mu  sync.Mutex
+	err error
+}
+
+// gzipBlock 一块压缩后的数据
+type gzipBlock struct {
+	data []byte
+	err  error
+}
+
+// newParallelGzip 创建使用 workers 个线程压缩的 gzip 写入器
+func newParallelGzip(w io.Writer, level, workers int) *parallelGzip {
+	z := &parallelGzip{
+		w:       w,
+		level:   level,
+		buf:     make([]byte, 0, gzipBlockSize),
+		pending: make(chan chan gzipBlock, workers),
+		done:    make(chan struct{}),
+	}
+	go z.drain()
+	return z
+}
+
+// drain 写入 gzip 头部，再按顺序写入压缩好的各块，出错后只取出剩余的块
+func (z *parallelGzip) drain() {
+	defer close(z.done)
+	// 没有文件名和修改时间，操作系统为未知
+	_, err := z.w.Write([]byte{0x1f, 0x8b, 8, 0, 0, 0, 0, 0, 0, 255})
+	for res := range z.pending {
+		b := <-res
+		if err == nil {
+			err = b.err
+		}
+		if err == nil {
+			_, err = z.w.Write(b.data)
+		}
+		if err != nil {
+			z.setErr(err)
+		}
+	}
+}
+
+func (z *parallelGzip) setErr(err error) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if z.err == nil {
+		z.err = err
+	}
+}
+
+func (z *parallelGzip) getErr() error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return z.err
+}
+
+func (z *parallelGzip) Write(p []byte) (int, error) {
+	if err := z.getErr(); err != nil {
+		return 0, err
+	}
+	z.crc = crc32.Update(z.crc, crc32.IEEETable, p)
+	z.size += uint32(len(p))
+	n := len(p)
+	for len(p) > 0 {
+		k := copy(z.buf[len(z.buf):cap(z.buf)], p)
+		z.buf, p = z.buf[:len(z.buf)+k], p[k:]
+		if len(z.buf) == cap(z.buf) {
+			z.flush(false)
+		}
+	}
+	return n, nil
+}
+
+// flush 把当前的块交给一个线程压缩，last 为 true 时写入 deflate 的结束块
+func (z *parallelGzip) flush(last bool) {
+	data, dict := z.buf, z.dict
+	if len(data) >= gzipDictSize {
+		z.dict = data[len(data)-gzipDictSize:]
+	} else {
+		z.dict = append(append([]byte(nil), dict...), data...)
+		if len(z.dict) > gzipDictSize {
+			z.dict = z.dict[len(z.dict)-gzipDictSize:]
+		}
+	}
+	z.buf = make([]byte, 0, gzipBlockSize)
+
+	res := make(chan gzipBlock, 1)
+	z.pending <- res
+	go func() {
+		var out bytes.Buffer
+		fw, err := flate.NewWriterDict(&out, z.level, dict)
+		if err == nil {
+			_, err = fw.Write(data)
+		}
+		if err == nil {
+			if last {
+				err = fw.Close()
+			} else {
+				err = fw.Flush()
+			}
+		}
+		res <- gzipBlock{data: out.Bytes(), err: err}
+	}()
+}
+
+// Close 压缩最后一块，等待所有块写入后写入 CRC32 和长度
+func (z *parallelGzip) Close() error {
+	if z.closed {
+		return z.getErr()
+	}
+	z.closed = true
+	z.flush(true)
+	close(z.pending)
+	<-z.done
+	if err := z.getErr(); err != nil {
+		return err
+	}
+	var trailer [8]byte
+	binary.LittleEndian.PutUint32(trailer[:4], z.crc)
+	binary.LittleEndian.PutUint32(trailer[4:], z.size)
+	_, err := z.w.Write(trailer[:])
+	return err
+}
@@ -0,0 +1,151 @@
+package zip
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// progressLogInterval 压缩过程中记录进度日志的间隔
+const progressLogInterval = time.Minute
+
+// Progress 正在执行的压缩任务的进度
+type Progress struct {
+	Target  string    // 压缩任务的目标路径
+	File    string    // 正在压缩的文件，源路径中的相对路径；写完所有文件后为空
+	Done    int64     // 已读取的源文件字节数
+	Total   int64     // 需要压缩的源文件总字节数，开始压缩前遍历源路径统计
+	Started time.Time // 开始写入压缩文件的时间
+}
+
+// Remaining 按目前的平均速度估计的剩余时间，还没有读取任何内容时无法估计，返回 false
+func (p Progress) Remaining() (time.Duration, bool) {
+	elapsed := time.Since(p.Started)
+	if p.Done <= 0 || elapsed <= 0 {
+		return 0, false
+	}
+	left := p.Total - p.Done
+	if left < 0 {
+		left = 0
+	}
+	return time.Duration(float64(elapsed) / float64(p.Done) * float64(left)), true
+}
+
+// Percent 已完成的百分比
+func (p Progress) Percent() int {
+	if p.Total <= 0 {
+		return 100
+	}
+	if p.Done >= p.Total {
+		return 100
+	}
+	return int(p.Done * 100 / p.Total)
+}
+
+// Progress 返回正在执行的压缩任务的进度，没有正在写入的压缩文件时返回 false
+func (z *ZipManager) Progress() (Progress, bool) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if z.progress == nil {
+		return Progress{}, false
+	}
+	return *z.progress, true
+}
+
+// startProgress 开始记录写入 target 的进度，total 为需要压缩的源文件总字节数
+func (z *ZipManager) startProgress(target string, total int64) {
+	z.mu.Lock()
+	z.progress = &Progress{Target: target, Total: total, Started: time.Now()}
+	z.lastProgressLog = time.Now()
+	z.mu.Unlock()
+}
+
+// endProgress 压缩文件写完或失败后清除进度
+func (z *ZipManager) endProgress() {
+	z.mu.Lock()
+	z.progress = nil
+	z.mu.Unlock()
+}
+
+// setProgressFile 记录正在压缩的文件
+func (z *ZipManager) setProgressFile(name string) {
+	z.mu.Lock()
+	if z.progress != nil {
+		z.progress.File = name
+	}
+	z.mu.Unlock()
+}
+
+// addProgress 增加已读取的字节数，距上一次记录超过 progressLogInterval 时记录进度日志
+func (z *ZipManager) addProgress(n int) {
+	z.mu.Lock()
+	if z.progress == nil {
+		z.mu.Unlock()
+		return
+	}
+	z.progress.Done += int64(n)
+	var p *Progress
+	if time.Since(z.lastProgressLog) >= progressLogInterval {
+		z.lastProgressLog = time.Now()
+		copied := *z.progress
+		p = &copied
+	}
+	z.mu.Unlock()
+	if p != nil {
+		logProgress(*p)
+	}
+}
+
+// logProgress 记录一条进度日志
+func logProgress(p Progress) {
+	eta := i18n.T("未知")
+	if d, ok := p.Remaining(); ok {
+		eta = d.Round(time.Second).String()
+	}
+	logger.Infof("压缩进度: %s, %d%% (%d / %d 字节), 当前文件: %s, 预计剩余: %s", p.Target, p.Percent(), p.Done, p.Total, p.File, eta)
+}
+
+// progressReader 读取源文件时累计进度
+type progressReader struct {
+	z *ZipManager
+	r io.Reader
+}
+
+func (p progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.z.addProgress(n)
+	}
+	return n, err
+}
+
+// sourceSize 统计源路径中 since 之后修改的普通文件的总大小，与 fill 写入的文件相同，只读取元数据
+func sourceSize(source string, info os.FileInfo, since time.Time) int64 {
+	var total int64
+	count := func(file string, info os.FileInfo) {
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Stat(file)
+			if err != nil {
+				return
+			}
+			info = target
+		}
+		if info.Mode().IsRegular() && (since.IsZero() || info.ModTime().After(since)) {
+			total += info.Size()
+		}
+	}
+	if !info.IsDir() {
+		count(source, info)
+		return total
+	}
+	filepath.Walk(source, func(file string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			count(file, info)
+		}
+		return nil
+	})
+	return total
+}
@@ -0,0 +1,102 @@
+package zip
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// OpenEntry 打开压缩文件中的条目，AES 加密的条目使用压缩时的密钥解密并核对校验码
+func OpenEntry(f *zip.File, key string) (io.ReadCloser, error) {
+	if f.Method == methodAES {
+		return openEncrypted(f, key)
+	}
+	return f.Open()
+}
+
+// FormatOf 按扩展名判断压缩文件的格式，.tar.gz 和 .tgz 为 tar.gz，.tar.zst 为 tar.zst，其他按 zip 读取
+func FormatOf(archive string) string {
+	name := strings.ToLower(archive)
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return config.FormatTarGz
+	case strings.HasSuffix(name, ".tar.zst"):
+		return config.FormatTarZst
+	default:
+		return config.FormatZip
+	}
+}
+
+// TarStream tar.gz 或 tar.zst 压缩文件解压后的 tar 数据
+// 读完所有条目后调用 Finish 核对整个压缩数据的校验码，最后总是调用 Close
+type TarStream struct {
+	io.Reader
+	finish func() error
+	close  func()
+}
+
+// Finish 读完 tar 结尾之后的数据，gzip 在读到末尾时核对 CRC32，zstd 以退出状态报告数据损坏
+func (s *TarStream) Finish() error {
+	if _, err := io.Copy(io.Discard, s.Reader); err != nil {
+		return i18n.Errorf("读取压缩文件失败: %w", err)
+	}
+	return s.finish()
+}
+
+// Close 关闭压缩文件，没有调用 Finish 时结束 zstd
+func (s *TarStream) Close() {
+	s.close()
+}
+
+// OpenTar 打开 tar.gz 或 tar.zst 压缩文件，tar.zst 通过 zstd 命令解压
+func OpenTar(format, file string) (*TarStream, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, i18n.Errorf("打开压缩文件失败: %w", err)
+	}
+	if format != config.FormatTarZst {
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, i18n.Errorf("读取压缩文件失败: %w", err)
+		}
+		return &TarStream{Reader: gr, finish: gr.Close, close: func() { f.Close() }}, nil
+	}
+	var stderr strings.Builder
+	cmd := exec.Command("zstd", "-q", "-d", "-c")
+	cmd.Stdin, cmd.Stderr = f, &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		f.Close()
+		return nil, i18n.Errorf("启动 zstd 失败: %w", err)
+	}
+	waited := false
+	s := &TarStream{Reader: out}
+	s.finish = func() error {
+		waited = true
+		if err := cmd.Wait(); err != nil {
+			return fmt.Errorf("zstd: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return nil
+	}
+	s.close = func() {
+		// 中途失败时结束 zstd
+		if !waited {
+			cmd.Process.Kill()
+			cmd.Wait()
+		}
+		f.Close()
+	}
+	return s, nil
+}
@@ -0,0 +1,126 @@
+package zip
+
+import (
+	"os"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/parity"
+	"github.com/lucasrui/neo-nas/internal/remote"
+)
+
+// rotating 是否保留多个压缩文件并按数量或总大小删除旧的压缩文件
+func rotating(item config.ZipItem) bool {
+	return item.Keep > 0 || item.MaxTotalBytes > 0
+}
+
+// fullPath 完整压缩文件的路径，替换路径中的占位符
+// 设置了 keep 或 max_total_bytes 且路径中没有日期或时间占位符时在文件名中加上生成时间，例如 foo-20240501-120000.zip，不覆盖之前的压缩文件
+func fullPath(item config.ZipItem, start time.Time) string {
+	target := expand(item, start)
+	if rotating(item) && !timed(item.Target) {
+		return stampedPath(target, "", start)
+	}
+	return target
+}
+
+// rotatedFiles 之前生成的带时间的压缩文件，按生成时间排序
+func rotatedFiles(item config.ZipItem) []string {
+	if timed(item.Target) {
+		return templateFiles(item)
+	}
+	return stampedFiles(expand(item, time.Time{}), "")
+}
+
+// rotate 只保留最近的 keep 个压缩文件，设置了 max_total_bytes 时再从最早的开始删除，直到总大小不超过预算
+// 删除压缩文件时同时删除其恢复数据和增量压缩文件；只删除本地的文件，上传的副本由 remote_keep 单独清理；最新的压缩文件总是保留
+func (z *ZipManager) rotate(item config.ZipItem) {
+	files := rotatedFiles(item)
+	drop := 0
+	if item.Keep > 0 && len(files) > item.Keep {
+		drop = len(files) - item.Keep
+	}
+	if item.MaxTotalBytes > 0 && len(files) > 0 {
+		// 从最新的开始累计大小，放不下的压缩文件及更早的全部删除
+		var total int64
+		for i := len(files) - 1; i >= drop; i-- {
+			total += setSize(files[i])
+			if total > item.MaxTotalBytes {
+				drop = i + 1
+				if i == len(files)-1 {
+					logger.Warnf("最新的压缩文件已超过 max_total_bytes %d: %s, 大小: %d", item.MaxTotalBytes, files[i], total)
+					drop = i
+				}
+				break
+			}
+		}
+	}
+	if drop == 0 {
+		return
+	}
+	for _, f := range files[:drop] {
+		for _, old := range append(incrementalFiles(f), f) {
+			if err := os.Remove(old); err != nil {
+				logger.Errorf("删除旧压缩文件失败: %v", err)
+				continue
+			}
+			os.Remove(parity.Sidecar(old))
+			logger.Infof("已删除旧压缩文件: %s", old)
+		}
+	}
+}
+
+// recordUpload 记录上传的压缩文件 key，完整的压缩文件开始新的一组，增量压缩文件加入最近的一组
+// 返回新的切片，不修改 stateStore 中保存的记录
+func recordUpload(sets [][]string, full bool, key string) [][]string {
+	sets = append([][]string(nil), sets...)
+	if full || len(sets) == 0 {
+		return append(sets, []string{key})
+	}
+	last := len(sets) - 1
+	sets[last] = append(append([]string(nil), sets[last]...), key)
+	return sets
+}
+
+// pruneUploads 上传位置只保留最近 remote_keep 组压缩文件（完整的压缩文件及其增量压缩文件），删除更早的副本
+// 返回仍在上传位置中的组，删除失败的副本保留在记录中，下次再删除
+func (z *ZipManager) pruneUploads(item config.ZipItem, sets [][]string) [][]string {
+	if len(sets) <= item.RemoteKeep {
+		return sets
+	}
+	backend, err := remote.Open(item.Upload, z.remote)
+	if err != nil {
+		logger.Warnf("打开上传位置失败，不删除旧压缩文件的副本: %v", err)
+		return sets
+	}
+	drop := len(sets) - item.RemoteKeep
+	var kept [][]string
+	for _, set := range sets[:drop] {
+		var failed []string
+		for _, key := range set {
+			if err := backend.Delete(key); err != nil {
+				logger.Errorf("删除上传的旧压缩文件失败: %v", err)
+				failed = append(failed, key)
+				continue
+			}
+			logger.Infof("按 remote_keep %d 删除了上传位置中的旧压缩文件: %s/%s", item.RemoteKeep, backend, key)
+		}
+		if len(failed) > 0 {
+			kept = append(kept, failed)
+		}
+	}
+	return append(kept, sets[drop:]...)
+}
+
+// setSize 完整压缩文件与其增量压缩文件、恢复数据占用的总大小
+func setSize(full string) int64 {
+	var total int64
+	for _, f := range append(incrementalFiles(full), full) {
+		for _, name := range []string{f, parity.Sidecar(f)} {
+			if info, err := os.Stat(name); err == nil {
+				total += info.Size()
+			}
+		}
+	}
+	return total
+}
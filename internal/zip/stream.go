@@ -0,0 +1,142 @@
+package zip
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+	"github.com/lucasrui/neo-nas/internal/remote"
+)
+
+// errUploadEnded 上传提前结束，压缩随之中止
+var errUploadEnded = i18n.New("上传已结束")
+
+// checkStream 压缩文件直接写入远程存储时不在本地保存，不能使用需要本地压缩文件的功能
+func checkStream(item config.ZipItem) error {
+	if !item.IsRemoteTarget() {
+		return nil
+	}
+	switch {
+	case item.Incremental:
+		return i18n.Errorf("目标为远程存储时不支持增量压缩")
+	case item.Keep > 0:
+		return i18n.Errorf("目标为远程存储时不支持 keep，可以在 target 中使用 {date} 或 {time} 每次生成不同的对象")
+	case item.MaxTotalBytes > 0:
+		return i18n.Errorf("目标为远程存储时不支持 max_total_bytes")
+	case item.ParityPercent > 0:
+		return i18n.Errorf("目标为远程存储时不支持生成恢复数据")
+	case item.TargetUser != "":
+		return i18n.Errorf("目标为远程存储时不支持设置所有者")
+	case item.Upload != "":
+		return i18n.Errorf("目标为远程存储时不需要设置 upload")
+	}
+	if _, _, err := splitRemote(item.Target); err != nil {
+		return err
+	}
+	return nil
+}
+
+// splitRemote 把远程压缩文件路径分为存储位置和对象键，例如 s3://bucket/archives/docs.zip 分为 s3://bucket/archives 和 docs.zip
+func splitRemote(target string) (string, string, error) {
+	i := strings.LastIndex(target, "/")
+	if strings.HasPrefix(target, config.RcloneTargetPrefix) && i < 0 {
+		// rclone:<远程名>:<文件名>
+		i = strings.LastIndex(target, ":")
+	}
+	if i < 0 || i == len(target)-1 || strings.HasPrefix(target, config.S3TargetPrefix) && i < len(config.S3TargetPrefix) {
+		return "", "", i18n.Errorf("远程压缩文件路径中缺少文件名: %s", target)
+	}
+	location, key := target[:i], target[i+1:]
+	if target[i] == ':' {
+		location += ":"
+	}
+	return location, key, nil
+}
+
+// zipRemote 压缩并直接上传到远程存储，完成后记录运行时间
+func (z *ZipManager) zipRemote(item config.ZipItem, info os.FileInfo) error {
+	start := time.Now()
+	target := expand(item, start)
+	added, err := z.stream(item, target, info)
+	if err != nil {
+		return err
+	}
+	doneLog(item, target, added, start).Infof("压缩任务完成，源路径: %s, 目标路径: %s, 文件: %d", item.Source, target, added)
+	return z.state.set(item.Target, itemState{LastFull: start, LastRun: start})
+}
+
+// stream 边压缩边上传到 target，本地不需要与压缩文件同样大小的空间
+// 压缩或上传中途失败时放弃上传，S3 上原有的对象保持不变；上传后核对远程对象的大小和哈希
+func (z *ZipManager) stream(item config.ZipItem, target string, info os.FileInfo) (int, error) {
+	location, key, err := splitRemote(target)
+	if err != nil {
+		return 0, err
+	}
+	backend, err := remote.Open(location, z.remote)
+	if err != nil {
+		return 0, err
+	}
+
+	pr, pw := io.Pipe()
+	hash := sha256.New()
+	counter := &countWriter{}
+	type result struct {
+		added int
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		added, err := func() (int, error) {
+			archive, err := newArchive(io.MultiWriter(pw, hash, counter), item)
+			if err != nil {
+				return 0, err
+			}
+			_, added, err := z.fill(archive, item, info, time.Time{})
+			if err != nil {
+				archive.Close()
+				return 0, err
+			}
+			if err := archive.Close(); err != nil {
+				return 0, i18n.Errorf("关闭压缩文件失败: %w", err)
+			}
+			return added, nil
+		}()
+		// 出错时上传读到错误而不是正常的结尾
+		pw.CloseWithError(err)
+		done <- result{added, err}
+	}()
+	err = remote.PutStream(backend, key, pr)
+	// 上传提前结束时让压缩中止写入
+	pr.CloseWithError(errUploadEnded)
+	res := <-done
+	if res.err != nil && !errors.Is(res.err, errUploadEnded) {
+		return 0, res.err
+	}
+	if err != nil {
+		return 0, i18n.Errorf("上传压缩文件失败: %w", err)
+	}
+	if res.err != nil {
+		return 0, res.err
+	}
+	if err := remote.VerifyStream(backend, key, counter.n, hex.EncodeToString(hash.Sum(nil))); err != nil {
+		return 0, i18n.Errorf("校验远程压缩文件失败: %w", err)
+	}
+	logger.Infof("压缩文件已上传并校验: %s, 大小: %d", target, counter.n)
+	return res.added, nil
+}
+
+// countWriter 记录写入的字节数
+type countWriter struct {
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
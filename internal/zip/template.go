@@ -0,0 +1,91 @@
+package zip
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+)
+
+// 压缩文件路径中可以使用的占位符
+const (
+	placeholderDate       = "{date}"        // 压缩开始的日期，例如 2024-05-01
+	placeholderTime       = "{time}"        // 压缩开始的时间，例如 120000
+	placeholderSourceBase = "{source_base}" // 源路径的最后一级名称
+)
+
+// timed 压缩文件路径中有日期或时间占位符，每次压缩生成不同的文件
+func timed(target string) bool {
+	return strings.Contains(target, placeholderDate) || strings.Contains(target, placeholderTime)
+}
+
+// expand 按压缩开始的时间 t 替换压缩文件路径中的占位符
+func expand(item config.ZipItem, t time.Time) string {
+	return strings.NewReplacer(
+		placeholderDate, t.Format("2006-01-02"),
+		placeholderTime, t.Format("150405"),
+		placeholderSourceBase, filepath.Base(item.Source),
+	).Replace(item.Target)
+}
+
+// templateFiles 按压缩文件路径中的占位符找到之前生成的压缩文件，按修改时间排序
+func templateFiles(item config.ZipItem) []string {
+	target := filepath.Clean(strings.ReplaceAll(item.Target, placeholderSourceBase, filepath.Base(item.Source)))
+	var glob, expr strings.Builder
+	expr.WriteString("^")
+	last := 0
+	for _, loc := range placeholderExpr.FindAllStringIndex(target, -1) {
+		glob.WriteString(globEscape(target[last:loc[0]]) + "*")
+		expr.WriteString(regexp.QuoteMeta(target[last:loc[0]]))
+		if target[loc[0]:loc[1]] == placeholderDate {
+			expr.WriteString(`\d{4}-\d{2}-\d{2}`)
+		} else {
+			expr.WriteString(`\d{6}`)
+		}
+		last = loc[1]
+	}
+	glob.WriteString(globEscape(target[last:]))
+	expr.WriteString(regexp.QuoteMeta(target[last:]) + "$")
+
+	matches, _ := filepath.Glob(glob.String())
+	re := regexp.MustCompile(expr.String())
+	type file struct {
+		path    string
+		modTime time.Time
+	}
+	var files []file
+	for _, m := range matches {
+		if !re.MatchString(m) {
+			continue
+		}
+		if info, err := os.Stat(m); err == nil && info.Mode().IsRegular() {
+			files = append(files, file{m, info.ModTime()})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].modTime.Equal(files[j].modTime) {
+			return files[i].modTime.Before(files[j].modTime)
+		}
+		return files[i].path < files[j].path
+	})
+	result := make([]string, len(files))
+	for i, f := range files {
+		result[i] = f.path
+	}
+	return result
+}
+
+// placeholderExpr 日期和时间占位符
+var placeholderExpr = regexp.MustCompile(`\{date\}|\{time\}`)
+
+// globEscape 转义 filepath.Glob 中的特殊字符，Windows 上反斜杠是路径分隔符，不能用于转义
+func globEscape(s string) string {
+	if filepath.Separator == '\\' {
+		return s
+	}
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`).Replace(s)
+}
@@ -0,0 +1,109 @@
+package zip
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lucasrui/neo-nas/internal/config"
+	"github.com/lucasrui/neo-nas/internal/i18n"
+)
+
+// verify 重新打开写好的压缩文件，读取每个条目核对校验码，并核对条目与写入的 names 一致
+// names 为压缩文件中的条目名称，目录以 / 结尾
+func verify(ctx context.Context, item config.ZipItem, file string, names []string) error {
+	var entries []string
+	var err error
+	switch item.Format {
+	case config.FormatTarGz, config.FormatTarZst:
+		entries, err = verifyTar(ctx, item.Format, file)
+	default:
+		entries, err = verifyZip(ctx, file, item.Key)
+	}
+	if err != nil {
+		return err
+	}
+	return sameEntries(names, entries)
+}
+
+// verifyZip 解压每个条目，zip 按 CRC32 核对，加密的条目按校验码核对
+func verifyZip(ctx context.Context, file, key string) ([]string, error) {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, i18n.Errorf("打开压缩文件失败: %w", err)
+	}
+	defer zr.Close()
+	var entries []string
+	for _, f := range zr.File {
+		entries = append(entries, f.Name)
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		in, err := OpenEntry(f, key)
+		if err != nil {
+			return nil, err
+		}
+		n, err := io.Copy(io.Discard, ctxReader{ctx, in})
+		in.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		if uint64(n) != f.UncompressedSize64 {
+			return nil, i18n.Errorf("%s: 大小与记录的不一致", f.Name)
+		}
+	}
+	return entries, nil
+}
+
+// verifyTar 读取每个条目，gzip 和 zstd 在读完时核对整个压缩数据的校验码
+func verifyTar(ctx context.Context, format, file string) ([]string, error) {
+	in, err := OpenTar(format, file)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	var entries []string
+	tr := tar.NewReader(in)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, i18n.Errorf("读取压缩文件失败: %w", err)
+		}
+		entries = append(entries, hdr.Name)
+		if _, err := io.Copy(io.Discard, ctxReader{ctx, tr}); err != nil {
+			return nil, fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+	}
+	if err := in.Finish(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// sameEntries 核对压缩文件中的条目与写入的条目一致
+func sameEntries(names, entries []string) error {
+	want := make(map[string]bool, len(names))
+	for _, name := range names {
+		want[name] = true
+	}
+	for _, e := range entries {
+		if !want[e] {
+			return i18n.Errorf("压缩文件中有多余的条目: %s", e)
+		}
+		delete(want, e)
+	}
+	for name := range want {
+		return i18n.Errorf("压缩文件中缺少条目: %s", name)
+	}
+	if len(entries) != len(names) {
+		return i18n.Errorf("压缩文件中有重复的条目")
+	}
+	return nil
+}